@@ -0,0 +1,283 @@
+package hedl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CanonOptions controls optional rewrites applied before canonicalization.
+type CanonOptions struct {
+	// NormalizeScalars rewrites date-like strings and numbers to a single
+	// representation: dates become zero-padded YYYY-MM-DD, RFC 3339
+	// timestamps are re-emitted in RFC 3339 form, and floats use their
+	// shortest decimal form. Row IDs and references are left unchanged, so
+	// references keep resolving.
+	NormalizeScalars bool
+	// SortSchemas orders %STRUCT and %NEST declarations alphabetically by
+	// type name. When false, declarations keep the order the parsed text
+	// declared them in, with types it did not declare after them in
+	// alphabetical order; documents not parsed from HEDL text, such as those
+	// from FromJSON, have no declaration order and are always sorted. Plain
+	// Canonicalize always sorts. Body keys are sorted by name either way.
+	SortSchemas bool
+}
+
+// CanonicalizeWithOptions converts the document to canonical HEDL form after
+// applying the rewrites selected in opts. The document itself is not
+// modified.
+func (d *Document) CanonicalizeWithOptions(opts CanonOptions) (string, error) {
+	doc := d
+	if opts.NormalizeScalars {
+		normalized, err := d.WithCanonOptions(opts)
+		if err != nil {
+			return "", err
+		}
+		defer normalized.Close()
+		doc = normalized
+	}
+
+	canonical, err := doc.Canonicalize()
+	if err != nil || opts.SortSchemas {
+		return canonical, err
+	}
+	return orderDeclarations(canonical, doc.schemaOrder), nil
+}
+
+// WithCanonOptions returns a new document with the rewrites selected in opts
+// applied, so that conversions such as ToJSON reflect them too. The document
+// itself is not modified.
+func (d *Document) WithCanonOptions(opts CanonOptions) (*Document, error) {
+	doc := d
+	if opts.NormalizeScalars {
+		normalized, err := d.normalizeScalars()
+		if err != nil || !opts.SortSchemas {
+			return normalized, err
+		}
+		defer normalized.Close()
+		doc = normalized
+	}
+
+	m, err := doc.model()
+	if err != nil {
+		return nil, err
+	}
+	if opts.SortSchemas {
+		m.sortSchemas()
+	}
+	return documentFromModel(m)
+}
+
+// sortSchemas orders schema and nest declarations by type name.
+func (m *docModel) sortSchemas() {
+	sort.SliceStable(m.structs, func(i, j int) bool {
+		return m.structs[i].name < m.structs[j].name
+	})
+	sort.SliceStable(m.nests, func(i, j int) bool {
+		if m.nests[i][0] != m.nests[j][0] {
+			return m.nests[i][0] < m.nests[j][0]
+		}
+		return m.nests[i][1] < m.nests[j][1]
+	})
+}
+
+// orderSchemas orders schema and nest declarations as the type names in
+// order, keeping the current order of types it does not list after them.
+func (m *docModel) orderSchemas(order []string) {
+	rank := schemaRanks(order)
+	sort.SliceStable(m.structs, func(i, j int) bool {
+		return rank(m.structs[i].name) < rank(m.structs[j].name)
+	})
+	sort.SliceStable(m.nests, func(i, j int) bool {
+		return rank(m.nests[i][0]) < rank(m.nests[j][0])
+	})
+}
+
+// schemaRanks returns the position of a type name in order, or len(order)
+// for a name it does not list.
+func schemaRanks(order []string) func(name string) int {
+	positions := make(map[string]int, len(order))
+	for i, name := range order {
+		if _, ok := positions[name]; !ok {
+			positions[name] = i
+		}
+	}
+	return func(name string) int {
+		if i, ok := positions[name]; ok {
+			return i
+		}
+		return len(order)
+	}
+}
+
+// declaredSchemas returns the names of the %STRUCT declarations in the
+// header of source, in order.
+func declaredSchemas(source string) []string {
+	var names []string
+	for rest := source; rest != ""; {
+		var line string
+		line, rest, _ = strings.Cut(rest, "\n")
+		line = strings.TrimSpace(line)
+		if line == "---" {
+			break
+		}
+		decl, ok := strings.CutPrefix(line, "%STRUCT:")
+		if !ok {
+			continue
+		}
+		decl = strings.TrimSpace(decl)
+		if end := strings.IndexAny(decl, ": ("); end > 0 {
+			// source may be freed after parsing, as in ParseReader.
+			names = append(names, strings.Clone(decl[:end]))
+		}
+	}
+	return names
+}
+
+// orderDeclarations reorders the %STRUCT and %NEST lines of a canonical
+// header as the type names in order, as orderSchemas does for a model.
+func orderDeclarations(canonical string, order []string) string {
+	if len(order) == 0 {
+		return canonical
+	}
+	end := strings.Index(canonical, "\n---\n")
+	if end < 0 {
+		return canonical
+	}
+	header, body := canonical[:end+1], canonical[end+1:]
+
+	lines := strings.SplitAfter(header, "\n")
+	type declaration struct {
+		line string
+		name string
+	}
+	var structs, nests []declaration
+	first := -1
+	kept := lines[:0]
+	for _, line := range lines {
+		var list *[]declaration
+		var decl string
+		if rest, ok := strings.CutPrefix(line, "%STRUCT:"); ok {
+			list, decl = &structs, rest
+		} else if rest, ok := strings.CutPrefix(line, "%NEST:"); ok {
+			list, decl = &nests, rest
+		} else {
+			kept = append(kept, line)
+			continue
+		}
+		if first < 0 {
+			first = len(kept)
+		}
+		decl = strings.TrimSpace(decl)
+		name := decl
+		if end := strings.IndexAny(decl, ": (>"); end >= 0 {
+			name = strings.TrimSpace(decl[:end])
+		}
+		*list = append(*list, declaration{line, name})
+	}
+	if first < 0 {
+		return canonical
+	}
+
+	rank := schemaRanks(order)
+	var declarations []string
+	for _, list := range [][]declaration{structs, nests} {
+		sort.SliceStable(list, func(i, j int) bool {
+			return rank(list[i].name) < rank(list[j].name)
+		})
+		for _, decl := range list {
+			declarations = append(declarations, decl.line)
+		}
+	}
+	lines = append(kept[:first:first], append(declarations, kept[first:]...)...)
+	return strings.Join(lines, "") + body
+}
+
+// CanonicalizeIndent returns the canonical HEDL of the document with each
+// two-space indent level of the body replaced by indent, which must be a
+// non-empty run of spaces and tabs; anything else returns an
+// ErrInvalidArgument error. Header lines and the contents of block strings
+// are left untouched.
+//
+// HEDL itself only accepts two-space indentation, so output produced with
+// any other unit is for display and will not parse.
+func (d *Document) CanonicalizeIndent(indent string) (string, error) {
+	if indent == "" || strings.Trim(indent, " \t") != "" {
+		return "", &HedlError{
+			Message: fmt.Sprintf("indent %q must be a non-empty run of spaces and tabs", indent),
+			Code:    ErrInvalidArgument,
+		}
+	}
+	canonical, err := d.Canonicalize()
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.SplitAfter(canonical, "\n")
+	inBody, inBlock := false, false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case !inBody:
+			inBody = trimmed == "---"
+		case inBlock:
+			inBlock = trimmed != `"""`
+		default:
+			inBlock = strings.HasSuffix(trimmed, `: """`)
+			body := strings.TrimLeft(line, " ")
+			spaces := len(line) - len(body)
+			lines[i] = strings.Repeat(indent, spaces/2) + strings.Repeat(" ", spaces%2) + body
+		}
+	}
+
+	output := strings.Join(lines, "")
+	if err := checkStringOutputSize(output); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// Fingerprint returns the hex-encoded SHA-256 of the document's canonical
+// HEDL, so documents that canonicalize identically share a fingerprint.
+func (d *Document) Fingerprint() (string, error) {
+	canonical, err := d.Canonicalize()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// VerifyFingerprint checks that the document's Fingerprint equals expected,
+// ignoring the case of the hex digits. On a mismatch it returns a HedlError
+// with code ErrFingerprintMismatch naming both fingerprints.
+func (d *Document) VerifyFingerprint(expected string) error {
+	actual, err := d.Fingerprint()
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(actual, expected) {
+		return &HedlError{
+			Message: fmt.Sprintf("fingerprint mismatch: expected %s, got %s", expected, actual),
+			Code:    ErrFingerprintMismatch,
+		}
+	}
+	return nil
+}
+
+// ShortID returns a compact identifier for log lines: the first 8 hex
+// characters of Fingerprint followed by the root item count, such as
+// "a1b2c3d4/2". It is meant for correlating logs, not for integrity checks.
+func (d *Document) ShortID() (string, error) {
+	fingerprint, err := d.Fingerprint()
+	if err != nil {
+		return "", err
+	}
+	count, err := d.RootItemCount()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%d", fingerprint[:8], count), nil
+}
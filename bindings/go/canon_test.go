@@ -0,0 +1,406 @@
+package hedl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const mixedDatesHEDL = `%VERSION: 1.0
+%STRUCT: Event: [id, day, amount]
+---
+events: @Event
+  | e1, 2024-1-5, 1.50
+  | e2, 2024-01-05, 1.5
+`
+
+func TestCanonicalizeNormalizeScalars(t *testing.T) {
+	doc, err := Parse(mixedDatesHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	plain, err := doc.CanonicalizeWithOptions(CanonOptions{})
+	if err != nil {
+		t.Fatalf("CanonicalizeWithOptions failed: %v", err)
+	}
+	if !strings.Contains(plain, "2024-1-5") {
+		t.Fatalf("Expected unnormalized date without NormalizeScalars, got:\n%s", plain)
+	}
+
+	normalized, err := doc.CanonicalizeWithOptions(CanonOptions{NormalizeScalars: true})
+	if err != nil {
+		t.Fatalf("CanonicalizeWithOptions failed: %v", err)
+	}
+	if strings.Contains(normalized, "2024-1-5") {
+		t.Fatalf("Expected 2024-1-5 to be normalized, got:\n%s", normalized)
+	}
+	if !strings.Contains(normalized, "|e1,2024-01-05,1.5") {
+		t.Fatalf("Expected normalized first row, got:\n%s", normalized)
+	}
+	if !strings.Contains(normalized, "|e2,^,^") {
+		t.Fatalf("Expected second row to ditto the normalized values, got:\n%s", normalized)
+	}
+}
+
+const unorderedSchemasHEDL = `%VERSION: 1.0
+%STRUCT: Order: [id, customer]
+%STRUCT: Customer: [id, name]
+---
+orders: @Order
+  | o1, @Customer:c1
+customers: @Customer
+  | c1, Alice
+`
+
+func TestCanonicalizeSortSchemas(t *testing.T) {
+	opts := CanonOptions{SortSchemas: true}
+	var first string
+	for i := 0; i < 3; i++ {
+		doc, err := Parse(unorderedSchemasHEDL, true)
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		sorted, err := doc.WithCanonOptions(opts)
+		doc.Close()
+		if err != nil {
+			t.Fatalf("WithCanonOptions failed: %v", err)
+		}
+		json, err := sorted.ToJSON(false)
+		sorted.Close()
+		if err != nil {
+			t.Fatalf("ToJSON failed: %v", err)
+		}
+		if i == 0 {
+			first = json
+			continue
+		}
+		if json != first {
+			t.Fatalf("Expected stable ToJSON output across runs:\n%s\n%s", first, json)
+		}
+	}
+	if strings.Index(first, `"customers"`) > strings.Index(first, `"orders"`) {
+		t.Errorf("Expected customers before orders in:\n%s", first)
+	}
+
+	doc, err := Parse(unorderedSchemasHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+	canonical, err := doc.CanonicalizeWithOptions(opts)
+	if err != nil {
+		t.Fatalf("CanonicalizeWithOptions failed: %v", err)
+	}
+	if strings.Index(canonical, "%STRUCT: Customer") > strings.Index(canonical, "%STRUCT: Order") {
+		t.Errorf("Expected Customer declared before Order in:\n%s", canonical)
+	}
+
+	for _, opts := range []CanonOptions{{}, {NormalizeScalars: true}} {
+		declared, err := doc.CanonicalizeWithOptions(opts)
+		if err != nil {
+			t.Fatalf("CanonicalizeWithOptions failed: %v", err)
+		}
+		if declared == canonical {
+			t.Errorf("Expected %+v to differ from SortSchemas output:\n%s", opts, declared)
+		}
+		if strings.Index(declared, "%STRUCT: Order") > strings.Index(declared, "%STRUCT: Customer") {
+			t.Errorf("Expected %+v to keep Order declared before Customer in:\n%s", opts, declared)
+		}
+	}
+}
+
+func TestNormalizeScalarsKeepsReferences(t *testing.T) {
+	doc, err := Parse("%VERSION: 1.0\n%STRUCT: Day: [id, date, next]\n---\n"+
+		"start: 2024-1-5\ndays: @Day\n  | d1, 2024-1-5, @Day:d2\n  | d2, 2024-1-6, @Day:d1\n", true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	normalized, err := doc.CanonicalizeWithOptions(CanonOptions{NormalizeScalars: true})
+	if err != nil {
+		t.Fatalf("CanonicalizeWithOptions failed: %v", err)
+	}
+	for _, want := range []string{"start: 2024-01-05", "|d1,2024-01-05,@Day:d2", "|d2,2024-01-06,@Day:d1"} {
+		if !strings.Contains(normalized, want) {
+			t.Errorf("Expected %q in:\n%s", want, normalized)
+		}
+	}
+}
+
+func TestNormalizeScalarValues(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"2024-1-5", "2024-01-05"},
+		{"2024-01-05", "2024-01-05"},
+		{"2024-13-40", "2024-13-40"},
+		{"2024-01-05T10:00:00+00:00", "2024-01-05T10:00:00Z"},
+		{"2024-01-05T10:00:00.500-05:00", "2024-01-05T10:00:00.5-05:00"},
+		{"1.50", "1.5"},
+		{"hello", "hello"},
+	}
+	for _, c := range cases {
+		doc, err := Parse("%VERSION: 1.0\n---\nvalue: "+c.in+"\n", true)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", c.in, err)
+		}
+		normalized, err := doc.CanonicalizeWithOptions(CanonOptions{NormalizeScalars: true})
+		doc.Close()
+		if err != nil {
+			t.Fatalf("CanonicalizeWithOptions failed: %v", err)
+		}
+		if !strings.Contains(normalized, "value: "+c.want+"\n") {
+			t.Errorf("Expected %s to normalize to %s, got:\n%s", c.in, c.want, normalized)
+		}
+	}
+}
+
+const nestedConfigHEDL = `%VERSION: 1.0
+%STRUCT: User: [id, name]
+%STRUCT: Replica: [id, host]
+---
+users: @User
+  | alice, Alice
+config:
+  name: demo
+  database:
+    host: localhost
+    port: 5432
+    replicas: @Replica
+      | r1, db1.internal
+`
+
+func TestCanonicalizePath(t *testing.T) {
+	doc, err := Parse(nestedConfigHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	fragment, err := doc.CanonicalizePath("config.database")
+	if err != nil {
+		t.Fatalf("CanonicalizePath failed: %v", err)
+	}
+	for _, want := range []string{"database:", "host: localhost", "port: 5432", "Replica", "db1.internal"} {
+		if !strings.Contains(fragment, want) {
+			t.Errorf("Expected fragment to contain %q:\n%s", want, fragment)
+		}
+	}
+	for _, unwanted := range []string{"User", "alice", "demo"} {
+		if strings.Contains(fragment, unwanted) {
+			t.Errorf("Expected fragment not to contain %q:\n%s", unwanted, fragment)
+		}
+	}
+
+	again, err := doc.CanonicalizePath("config.database")
+	if err != nil {
+		t.Fatalf("CanonicalizePath failed: %v", err)
+	}
+	if again != fragment {
+		t.Errorf("Expected CanonicalizePath to be deterministic")
+	}
+}
+
+func TestCanonicalizeIndent(t *testing.T) {
+	doc, err := Parse(nestedConfigHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	canonical, err := doc.Canonicalize()
+	if err != nil {
+		t.Fatalf("Canonicalize failed: %v", err)
+	}
+	twoSpace, err := doc.CanonicalizeIndent("  ")
+	if err != nil {
+		t.Fatalf("CanonicalizeIndent failed: %v", err)
+	}
+	if twoSpace != canonical {
+		t.Errorf("Expected two-space indent to match Canonicalize:\n%s\ngot:\n%s", canonical, twoSpace)
+	}
+
+	tabs, err := doc.CanonicalizeIndent("\t")
+	if err != nil {
+		t.Fatalf("CanonicalizeIndent failed: %v", err)
+	}
+	twoLines := strings.Split(twoSpace, "\n")
+	tabLines := strings.Split(tabs, "\n")
+	if len(tabLines) != len(twoLines) {
+		t.Fatalf("Expected %d lines, got %d:\n%s", len(twoLines), len(tabLines), tabs)
+	}
+	for i, line := range twoLines {
+		body := strings.TrimLeft(line, " ")
+		level := (len(line) - len(body)) / 2
+		if want := strings.Repeat("\t", level) + body; tabLines[i] != want {
+			t.Errorf("Line %d: expected %q, got %q", i+1, want, tabLines[i])
+		}
+	}
+	if !strings.Contains(tabs, "\t\thost: localhost\n") {
+		t.Errorf("Expected nested keys indented with two tabs in:\n%s", tabs)
+	}
+
+	var hedlErr *HedlError
+	for _, indent := range []string{"", "--", " x"} {
+		if _, err := doc.CanonicalizeIndent(indent); !errors.As(err, &hedlErr) || hedlErr.Code != ErrInvalidArgument {
+			t.Errorf("CanonicalizeIndent(%q): expected ErrInvalidArgument, got %v", indent, err)
+		}
+	}
+}
+
+func TestCanonicalizePathNotFound(t *testing.T) {
+	doc, err := Parse(nestedConfigHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	for _, path := range []string{"missing", "config.missing", "config.name.deeper"} {
+		_, err := doc.CanonicalizePath(path)
+		var hedlErr *HedlError
+		if !errors.As(err, &hedlErr) || hedlErr.Code != ErrNotFound {
+			t.Errorf("CanonicalizePath(%q): expected ErrNotFound, got %v", path, err)
+		}
+	}
+}
+
+const gitConfigHEDL = `%VERSION: 1.0
+%STRUCT: Host: [id, region, weight]
+---
+service:
+  name: api
+  port: 8080
+hosts: @Host
+  | h1, eu-west, 1.50
+  | h2, eu-west, 1.50
+  | h3, us-east, 2
+`
+
+// changedLines counts lines of b that do not appear in a, and of a that do
+// not appear in b.
+func changedLines(a, b string) (added, removed int) {
+	count := func(s string) map[string]int {
+		lines := make(map[string]int)
+		for _, line := range strings.Split(s, "\n") {
+			lines[line]++
+		}
+		return lines
+	}
+	before, after := count(a), count(b)
+	for line, n := range after {
+		if n > before[line] {
+			added += n - before[line]
+		}
+	}
+	for line, n := range before {
+		if n > after[line] {
+			removed += n - after[line]
+		}
+	}
+	return added, removed
+}
+
+func gitFriendly(t *testing.T, content string) string {
+	t.Helper()
+	doc, err := Parse(content, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+	out, err := doc.ToGitFriendly()
+	if err != nil {
+		t.Fatalf("ToGitFriendly failed: %v", err)
+	}
+	return out
+}
+
+func TestToGitFriendly(t *testing.T) {
+	base := gitFriendly(t, gitConfigHEDL)
+	if strings.Contains(base, "^") {
+		t.Errorf("Expected no ditto markers:\n%s", base)
+	}
+	if strings.Index(base, "hosts:") > strings.Index(base, "service:") {
+		t.Errorf("Expected top-level keys to be sorted:\n%s", base)
+	}
+
+	withField := gitFriendly(t, strings.Replace(gitConfigHEDL, "  port: 8080\n", "  port: 8080\n  debug: true\n", 1))
+	if added, removed := changedLines(base, withField); added != 1 || removed != 0 {
+		t.Errorf("Adding one field: expected 1 added and 0 removed lines, got %d and %d", added, removed)
+	}
+
+	editedRow := gitFriendly(t, strings.Replace(gitConfigHEDL, "| h1, eu-west", "| h1, eu-north", 1))
+	if added, removed := changedLines(base, editedRow); added != 1 || removed != 1 {
+		t.Errorf("Editing one row: expected 1 added and 1 removed line, got %d and %d", added, removed)
+	}
+
+	doc, err := Parse(base, true)
+	if err != nil {
+		t.Fatalf("Git-friendly output does not parse: %v\n%s", err, base)
+	}
+	doc.Close()
+}
+
+func TestShortID(t *testing.T) {
+	ids := make([]string, 2)
+	for i := range ids {
+		doc, err := Parse(sampleHEDL, true)
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		id, err := doc.ShortID()
+		doc.Close()
+		if err != nil {
+			t.Fatalf("ShortID failed: %v", err)
+		}
+		ids[i] = id
+	}
+	if ids[0] != ids[1] {
+		t.Errorf("Expected ShortID to be stable across reparses, got %q and %q", ids[0], ids[1])
+	}
+	if len(ids[0]) != 10 || !strings.HasSuffix(ids[0], "/1") {
+		t.Errorf("Expected 8 hex characters and the root item count, got %q", ids[0])
+	}
+
+	other, err := Parse(mixedDatesHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer other.Close()
+	id, err := other.ShortID()
+	if err != nil {
+		t.Fatalf("ShortID failed: %v", err)
+	}
+	if id == ids[0] {
+		t.Errorf("Expected distinct documents to have distinct ShortIDs, both %q", id)
+	}
+}
+
+func TestVerifyFingerprint(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	fingerprint, err := doc.Fingerprint()
+	if err != nil {
+		t.Fatalf("Fingerprint failed: %v", err)
+	}
+	if err := doc.VerifyFingerprint(strings.ToUpper(fingerprint)); err != nil {
+		t.Errorf("Expected the document's own fingerprint to verify, got %v", err)
+	}
+
+	wrong := strings.Repeat("0", len(fingerprint))
+	err = doc.VerifyFingerprint(wrong)
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrFingerprintMismatch {
+		t.Fatalf("Expected ErrFingerprintMismatch, got %v", err)
+	}
+	if !strings.Contains(hedlErr.Message, wrong) || !strings.Contains(hedlErr.Message, fingerprint) {
+		t.Errorf("Expected both fingerprints in %q", hedlErr.Message)
+	}
+}
@@ -0,0 +1,152 @@
+package hedl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// RowHashes returns the content hash of every schemaName row keyed by the
+// row's ID, as HEDL cell text. Store the result as a snapshot and pass it to
+// ChangedSince later to find rows that are new or changed.
+//
+// A hash is the hex SHA-256 of the row's cells in HEDL syntax, so it changes
+// whenever any field of the row changes.
+func (d *Document) RowHashes(schemaName string) (map[string]string, error) {
+	m, err := d.model()
+	if err != nil {
+		return nil, err
+	}
+	lists, err := m.listsOf(schemaName)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make(map[string]string)
+	for _, list := range lists {
+		for _, row := range list.rows {
+			key, hash, err := rowHash(row)
+			if err != nil {
+				return nil, err
+			}
+			hashes[key] = hash
+		}
+	}
+	return hashes, nil
+}
+
+// ChangedSince returns the indices of schemaName rows that are new or
+// changed compared to priorKeys, a snapshot produced by RowHashes. Indices
+// count rows of the schema across all of its lists in document order.
+func (d *Document) ChangedSince(schemaName string, priorKeys map[string]string) ([]int, error) {
+	m, err := d.model()
+	if err != nil {
+		return nil, err
+	}
+	lists, err := m.listsOf(schemaName)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := []int{}
+	index := 0
+	for _, list := range lists {
+		for _, row := range list.rows {
+			key, hash, err := rowHash(row)
+			if err != nil {
+				return nil, err
+			}
+			if prior, ok := priorKeys[key]; !ok || prior != hash {
+				changed = append(changed, index)
+			}
+			index++
+		}
+	}
+	return changed, nil
+}
+
+// rowHash returns the key and content hash of a row. A row without values
+// has no ID to key it by and returns ErrNotFound.
+func rowHash(row *matrixRow) (string, string, error) {
+	if len(row.values) == 0 {
+		return "", "", &HedlError{Message: "row has no ID field", Code: ErrNotFound}
+	}
+	columns := make([]int, len(row.values))
+	for i := range columns {
+		columns[i] = i
+	}
+	content, err := rowKey(row, columns)
+	if err != nil {
+		return "", "", err
+	}
+	key, err := formatCell(row.values[0])
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(content))
+	return key, hex.EncodeToString(sum[:]), nil
+}
+
+// MetadataDiff compares the metadata of d and other: the %VERSION and %ALIAS
+// header directives and every scalar key-value pair outside matrix lists,
+// such as generated_at or source. The result maps each key whose value
+// differs, or that only one document has, to [old, new], where old is d's
+// value and new is other's. Rows are not compared; use RowHashes for that.
+//
+// Header entries are keyed "%VERSION" and "%ALIAS:name", key-value pairs by
+// their dot-separated path. Values are in HEDL cell syntax, so an empty
+// string is `""` and null is `~`, leaving "" to mark an absent key.
+func (d *Document) MetadataDiff(other *Document) (map[string][2]string, error) {
+	mine, err := d.metadata()
+	if err != nil {
+		return nil, err
+	}
+	theirs, err := other.metadata()
+	if err != nil {
+		return nil, err
+	}
+
+	diff := make(map[string][2]string)
+	for key, old := range mine {
+		if updated, ok := theirs[key]; !ok || updated != old {
+			diff[key] = [2]string{old, updated}
+		}
+	}
+	for key, added := range theirs {
+		if _, ok := mine[key]; !ok {
+			diff[key] = [2]string{"", added}
+		}
+	}
+	return diff, nil
+}
+
+// metadata returns the entries compared by MetadataDiff.
+func (d *Document) metadata() (map[string]string, error) {
+	m, err := d.model()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := map[string]string{
+		"%VERSION": fmt.Sprintf("%d.%d", m.major, m.minor),
+	}
+	for _, alias := range m.aliases {
+		value, err := formatCell(alias.value)
+		if err != nil {
+			return nil, err
+		}
+		entries["%ALIAS:"+alias.key] = value
+	}
+	var cellErr error
+	m.eachKeyValue(func(path string, value interface{}) {
+		text, err := formatCell(value)
+		if err != nil && cellErr == nil {
+			cellErr = err
+		}
+		entries[path] = text
+	})
+	if cellErr != nil {
+		return nil, cellErr
+	}
+	return entries, nil
+}
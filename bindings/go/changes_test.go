@@ -0,0 +1,109 @@
+package hedl
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestChangedSince(t *testing.T) {
+	before, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer before.Close()
+
+	snapshot, err := before.RowHashes("User")
+	if err != nil {
+		t.Fatalf("RowHashes failed: %v", err)
+	}
+	if len(snapshot) != 2 {
+		t.Fatalf("Expected 2 row hashes, got %v", snapshot)
+	}
+
+	after, err := Parse(`%VERSION: 1.0
+%STRUCT: User: [id, name, email]
+---
+users: @User
+  | alice, Alice Smith, alice@example.com
+  | bob, Bob Jones, bob@example.org
+  | carol, Carol White, carol@example.com
+`, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer after.Close()
+
+	changed, err := after.ChangedSince("User", snapshot)
+	if err != nil {
+		t.Fatalf("ChangedSince failed: %v", err)
+	}
+	if got := fmt.Sprint(changed); got != "[1 2]" {
+		t.Errorf("Expected changed rows [1 2], got %s", got)
+	}
+
+	unchanged, err := before.ChangedSince("User", snapshot)
+	if err != nil {
+		t.Fatalf("ChangedSince failed: %v", err)
+	}
+	if len(unchanged) != 0 {
+		t.Errorf("Expected no changes against its own snapshot, got %v", unchanged)
+	}
+}
+
+func TestRowHashEmptyRow(t *testing.T) {
+	_, _, err := rowHash(&matrixRow{})
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for a row without values, got %v", err)
+	}
+}
+
+func TestMetadataDiff(t *testing.T) {
+	before, err := Parse(`%VERSION: 1.0
+%STRUCT: User: [id, name]
+---
+generated_at: 2024-01-01T00:00:00Z
+source: crm
+users: @User
+  | alice, Alice
+`, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer before.Close()
+
+	after, err := Parse(`%VERSION: 1.0
+%STRUCT: User: [id, name]
+---
+generated_at: 2024-02-01T00:00:00Z
+reviewed_by: ops
+users: @User
+  | alice, Alice Smith
+`, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer after.Close()
+
+	diff, err := before.MetadataDiff(after)
+	if err != nil {
+		t.Fatalf("MetadataDiff failed: %v", err)
+	}
+	want := map[string][2]string{
+		"generated_at": {"2024-01-01T00:00:00Z", "2024-02-01T00:00:00Z"},
+		"source":       {"crm", ""},
+		"reviewed_by":  {"", "ops"},
+	}
+	if fmt.Sprint(diff) != fmt.Sprint(want) {
+		t.Errorf("Expected %v, got %v", want, diff)
+	}
+
+	same, err := before.MetadataDiff(before)
+	if err != nil {
+		t.Fatalf("MetadataDiff failed: %v", err)
+	}
+	if len(same) != 0 {
+		t.Errorf("Expected no differences against itself, got %v", same)
+	}
+}
@@ -0,0 +1,211 @@
+package hedl
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// severityName returns the label used for severity in diagnostic messages.
+func severityName(severity int) string {
+	switch severity {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return "hint"
+}
+
+// newDiagnostic builds a diagnostic whose message follows the native lint
+// format, "[rule] severity: message".
+func newDiagnostic(severity int, rule, format string, args ...interface{}) *Diagnostic {
+	return &Diagnostic{
+		Message:  fmt.Sprintf("[%s] %s: %s", rule, severityName(severity), fmt.Sprintf(format, args...)),
+		Severity: severity,
+		RuleID:   rule,
+	}
+}
+
+// ForeignKey declares that the FromField of every FromSchema row holds the
+// ToKey value of some ToSchema row.
+type ForeignKey struct {
+	FromSchema string
+	FromField  string
+	ToSchema   string
+	ToKey      string
+}
+
+// String formats the key as "FromSchema.FromField -> ToSchema.ToKey".
+func (k ForeignKey) String() string {
+	return fmt.Sprintf("%s.%s -> %s.%s", k.FromSchema, k.FromField, k.ToSchema, k.ToKey)
+}
+
+// CheckEnums reports values outside their field's allowed set. HEDL has no
+// syntax for enum annotations, so enums maps "Schema.field" to the allowed
+// values, compared as text so numbers and booleans match their literal
+// form. Every other value is reported as an error diagnostic naming the row
+// index, counted across the schema's lists in document order. Null values
+// are skipped; use RowsWithMissing to find them.
+//
+// A key not of the form "Schema.field" returns an ErrInvalidArgument error,
+// and an unknown schema or field returns ErrNotFound.
+func (d *Document) CheckEnums(enums map[string][]string) (*Diagnostics, error) {
+	m, err := d.model()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(enums))
+	for key := range enums {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var items []*Diagnostic
+	for _, key := range keys {
+		schemaName, field, lists, err := m.qualifiedField(key)
+		if err != nil {
+			return nil, err
+		}
+		allowed := make(map[string]bool, len(enums[key]))
+		for _, value := range enums[key] {
+			allowed[value] = true
+		}
+
+		index := 0
+		for _, list := range lists {
+			for _, row := range list.rows {
+				if value, ok := list.value(row, field); ok && value != nil {
+					text, err := valueText(value)
+					if err != nil || !allowed[text] {
+						cell, _ := formatCell(value)
+						items = append(items, newDiagnostic(SeverityError, "enum",
+							"%s row %d field %q is not one of [%s] (got %s)",
+							schemaName, index, field, strings.Join(enums[key], ", "), cell))
+					}
+				}
+				index++
+			}
+		}
+	}
+	return newDiagnostics(items), nil
+}
+
+// qualifiedField resolves a "Schema.field" key to its schema name, field
+// name and the lists holding the schema's rows.
+func (m *docModel) qualifiedField(key string) (string, string, []*matrixList, error) {
+	dot := strings.LastIndex(key, ".")
+	if dot < 0 {
+		return "", "", nil, &HedlError{
+			Message: fmt.Sprintf("field %q is not of the form Schema.field", key),
+			Code:    ErrInvalidArgument,
+		}
+	}
+	schemaName, field := key[:dot], key[dot+1:]
+	columns, err := m.schemaColumns(schemaName)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if _, err := fieldIndex(schemaName, columns, field); err != nil {
+		return "", "", nil, err
+	}
+	lists, err := m.listsOf(schemaName)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return schemaName, field, lists, nil
+}
+
+// Constraint is a business rule checked by CheckConstraints: the Field of
+// every Schema row must satisfy "Field Op Value".
+//
+// Op is one of ==, !=, <, <=, >, >= or matches. For the comparison
+// operators Value is HEDL cell text, compared as in Pipeline.Filter; for
+// matches it is a regular expression that string values must match.
+type Constraint struct {
+	Schema string
+	Field  string
+	Op     string
+	Value  string
+}
+
+// String formats the constraint as "Schema.Field Op Value".
+func (c Constraint) String() string {
+	return fmt.Sprintf("%s.%s %s %s", c.Schema, c.Field, c.Op, c.Value)
+}
+
+// CheckConstraints evaluates rules against every row of their schemas and
+// reports an error diagnostic for each violating row, naming its index
+// among the schema's rows in document order. Null values are skipped; use
+// RowsWithMissing to find them.
+//
+// An unknown operator or invalid regular expression returns a HedlError
+// with code ErrInvalidArgument, and an unknown schema or field returns
+// ErrNotFound.
+func (d *Document) CheckConstraints(rules []Constraint) (*Diagnostics, error) {
+	m, err := d.model()
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*Diagnostic
+	for _, rule := range rules {
+		check, err := constraintCheck(rule)
+		if err != nil {
+			return nil, err
+		}
+		columns, err := m.schemaColumns(rule.Schema)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := fieldIndex(rule.Schema, columns, rule.Field); err != nil {
+			return nil, err
+		}
+		lists, err := m.listsOf(rule.Schema)
+		if err != nil {
+			return nil, err
+		}
+
+		index := 0
+		for _, list := range lists {
+			for _, row := range list.rows {
+				if value, ok := list.value(row, rule.Field); ok && value != nil && !check(value) {
+					cell, _ := formatCell(value)
+					items = append(items, newDiagnostic(SeverityError, "constraint",
+						"%s row %d violates %s (got %s)", rule.Schema, index, rule, cell))
+				}
+				index++
+			}
+		}
+	}
+	return newDiagnostics(items), nil
+}
+
+// constraintCheck compiles a rule into a predicate over cell values.
+func constraintCheck(rule Constraint) (func(value interface{}) bool, error) {
+	switch rule.Op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		want := parseCell(rule.Value)
+		return func(value interface{}) bool {
+			return matchesFilter(value, rule.Op, want)
+		}, nil
+	case "matches":
+		re, err := regexp.Compile(rule.Value)
+		if err != nil {
+			return nil, &HedlError{
+				Message: fmt.Sprintf("invalid pattern in constraint %s: %v", rule, err),
+				Code:    ErrInvalidArgument,
+			}
+		}
+		return func(value interface{}) bool {
+			s, ok := value.(string)
+			return ok && re.MatchString(s)
+		}, nil
+	}
+	return nil, &HedlError{
+		Message: fmt.Sprintf("unsupported operator %q in constraint %s", rule.Op, rule),
+		Code:    ErrInvalidArgument,
+	}
+}
@@ -0,0 +1,505 @@
+package hedl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const undefinedSchemaHEDL = `%VERSION: 1.0
+%STRUCT: Order: [id, customer]
+---
+orders: @Order
+  | o1, @Customer:c1
+`
+
+func TestCheckSchemaReferences(t *testing.T) {
+	doc, err := Parse(undefinedSchemaHEDL, false)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	diag, err := doc.CheckSchemaReferences()
+	if err != nil {
+		t.Fatalf("CheckSchemaReferences failed: %v", err)
+	}
+	defer diag.Close()
+
+	errs, err := diag.Errors()
+	if err != nil {
+		t.Fatalf("Errors failed: %v", err)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0], `"Customer"`) {
+		t.Fatalf("Expected one error naming Customer, got %v", errs)
+	}
+}
+
+func TestCheckSchemaReferencesClean(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	diag, err := doc.CheckSchemaReferences()
+	if err != nil {
+		t.Fatalf("CheckSchemaReferences failed: %v", err)
+	}
+	defer diag.Close()
+
+	if diag.Count() != 0 {
+		t.Fatalf("Expected no diagnostics, got %d", diag.Count())
+	}
+}
+
+const incompleteRowsHEDL = `%VERSION: 1.0
+%STRUCT: User: [id, name, email]
+---
+users: @User
+  | alice, Alice Smith, alice@example.com
+  | bob, ~, bob@example.com
+  | carol, Carol White, alice@example.com
+  | dave, Dave Green, ""
+`
+
+func TestRowsWithMissing(t *testing.T) {
+	doc, err := Parse(incompleteRowsHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	rows, err := doc.RowsWithMissing("User")
+	if err != nil {
+		t.Fatalf("RowsWithMissing failed: %v", err)
+	}
+	if fmt.Sprint(rows) != "[1 3]" {
+		t.Errorf("Expected rows [1 3], got %v", rows)
+	}
+
+	if _, err := doc.RowsWithMissing("Missing"); CategoryOf(err) != CategoryNotFound {
+		t.Errorf("Expected not_found error for unknown schema, got %v", err)
+	}
+}
+
+const salariesHEDL = `%VERSION: 1.0
+%STRUCT: Employee: [id, email, salary]
+---
+employees: @Employee
+  | e1, alice@example.com, 52000
+  | e2, bob.example.com, -100
+  | e3, carol@example.com, ~
+`
+
+func TestCheckConstraints(t *testing.T) {
+	doc, err := Parse(salariesHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	diag, err := doc.CheckConstraints([]Constraint{
+		{Schema: "Employee", Field: "salary", Op: ">", Value: "0"},
+		{Schema: "Employee", Field: "email", Op: "matches", Value: `^[^@]+@[^@]+$`},
+	})
+	if err != nil {
+		t.Fatalf("CheckConstraints failed: %v", err)
+	}
+	defer diag.Close()
+
+	errs, err := diag.Errors()
+	if err != nil {
+		t.Fatalf("Errors failed: %v", err)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 violations, got %v", errs)
+	}
+	if !strings.Contains(errs[0], "Employee row 1 violates Employee.salary > 0 (got -100)") {
+		t.Errorf("Unexpected salary diagnostic: %s", errs[0])
+	}
+	if !strings.Contains(errs[1], "Employee row 1 violates Employee.email matches") {
+		t.Errorf("Unexpected email diagnostic: %s", errs[1])
+	}
+}
+
+func TestCheckConstraintsInvalid(t *testing.T) {
+	doc, err := Parse(salariesHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	var hedlErr *HedlError
+	_, err = doc.CheckConstraints([]Constraint{{Schema: "Employee", Field: "salary", Op: "~=", Value: "0"}})
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrInvalidArgument {
+		t.Errorf("Expected ErrInvalidArgument for an unknown operator, got %v", err)
+	}
+	_, err = doc.CheckConstraints([]Constraint{{Schema: "Employee", Field: "bonus", Op: ">", Value: "0"}})
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for an unknown field, got %v", err)
+	}
+}
+
+const danglingOrdersHEDL = `%VERSION: 1.0
+%STRUCT: User: [id, name]
+%STRUCT: Order: [id, user]
+---
+users: @User
+  | alice, Alice
+  | bob, Bob
+orders: @Order
+  | o1, alice
+  | o2, zed
+  | o3, @User:bob
+`
+
+func TestCheckForeignKeys(t *testing.T) {
+	doc, err := Parse(danglingOrdersHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	diag, err := doc.CheckForeignKeys([]ForeignKey{
+		{FromSchema: "Order", FromField: "user", ToSchema: "User", ToKey: "id"},
+	})
+	if err != nil {
+		t.Fatalf("CheckForeignKeys failed: %v", err)
+	}
+	defer diag.Close()
+
+	errs, err := diag.Errors()
+	if err != nil {
+		t.Fatalf("Errors failed: %v", err)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("Expected 1 dangling reference, got %v", errs)
+	}
+	if !strings.Contains(errs[0], "Order row 1 has no match for Order.user -> User.id (got zed)") {
+		t.Errorf("Unexpected diagnostic: %s", errs[0])
+	}
+
+	_, err = doc.CheckForeignKeys([]ForeignKey{
+		{FromSchema: "Order", FromField: "user", ToSchema: "Customer", ToKey: "id"},
+	})
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for an unknown schema, got %v", err)
+	}
+}
+
+func TestCheckRanges(t *testing.T) {
+	doc, err := Parse(salariesHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	diag, err := doc.CheckRanges(map[string][2]float64{"Employee.salary": {0, 50000}})
+	if err != nil {
+		t.Fatalf("CheckRanges failed: %v", err)
+	}
+	defer diag.Close()
+
+	errs, err := diag.Errors()
+	if err != nil {
+		t.Fatalf("Errors failed: %v", err)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 out-of-range values, got %v", errs)
+	}
+	if !strings.Contains(errs[0], `Employee row 0 field "salary" is outside [0, 50000] (got 52000)`) {
+		t.Errorf("Unexpected diagnostic for a value above max: %s", errs[0])
+	}
+	if !strings.Contains(errs[1], "Employee row 1") || !strings.Contains(errs[1], "(got -100)") {
+		t.Errorf("Unexpected diagnostic for a value below min: %s", errs[1])
+	}
+
+	var hedlErr *HedlError
+	_, err = doc.CheckRanges(map[string][2]float64{"Employee.salary": {10, 0}})
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrInvalidArgument {
+		t.Errorf("Expected ErrInvalidArgument for an inverted range, got %v", err)
+	}
+	_, err = doc.CheckRanges(map[string][2]float64{"Employee.bonus": {0, 1}})
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for an unknown field, got %v", err)
+	}
+}
+
+// ticketsHEDL has a status outside the open/closed workflow in row 1.
+const ticketsHEDL = `%VERSION: 1.0
+%STRUCT: Ticket: [id, status, priority]
+---
+tickets: @Ticket
+  | t1, open, 1
+  | t2, archived, 2
+  | t3, closed, ~
+`
+
+func TestCheckEnums(t *testing.T) {
+	doc, err := Parse(ticketsHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	diag, err := doc.CheckEnums(map[string][]string{
+		"Ticket.status":   {"open", "closed"},
+		"Ticket.priority": {"1", "2", "3"},
+	})
+	if err != nil {
+		t.Fatalf("CheckEnums failed: %v", err)
+	}
+	defer diag.Close()
+
+	errs, err := diag.Errors()
+	if err != nil {
+		t.Fatalf("Errors failed: %v", err)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0], `Ticket row 1 field "status" is not one of [open, closed] (got archived)`) {
+		t.Errorf("Expected one disallowed status, got %v", errs)
+	}
+
+	var hedlErr *HedlError
+	_, err = doc.CheckEnums(map[string][]string{"status": {"open"}})
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrInvalidArgument {
+		t.Errorf("Expected ErrInvalidArgument for an unqualified field, got %v", err)
+	}
+}
+
+// decomposedHEDL spells "Café" with a combining acute accent (NFD) except
+// in row p1, which uses the precomposed character (NFC).
+const decomposedHEDL = "%VERSION: 1.0\n" +
+	"%STRUCT: Place: [id, name]\n" +
+	"---\n" +
+	"title: Cafe\u0301 guide\n" +
+	"places: @Place\n" +
+	"  | p1, Caf\u00e9 Central\n" +
+	"  | p2, Cafe\u0301 Sacher\n"
+
+func TestCheckUnicodeNormalization(t *testing.T) {
+	doc, err := Parse(decomposedHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	diag, err := doc.CheckUnicodeNormalization("NFC")
+	if err != nil {
+		t.Fatalf("CheckUnicodeNormalization failed: %v", err)
+	}
+	defer diag.Close()
+
+	errs, err := diag.Errors()
+	if err != nil {
+		t.Fatalf("Errors failed: %v", err)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 errors, got %d: %v", len(errs), errs)
+	}
+	if !strings.Contains(errs[0], "title") || !strings.Contains(errs[1], `Place row 1 field "name"`) {
+		t.Errorf("Unexpected errors: %v", errs)
+	}
+
+	nfd, err := doc.CheckUnicodeNormalization("nfd")
+	if err != nil {
+		t.Fatalf("CheckUnicodeNormalization failed: %v", err)
+	}
+	defer nfd.Close()
+	if nfd.Count() != 1 {
+		t.Errorf("Expected only the precomposed value to fail NFD, got %d diagnostics", nfd.Count())
+	}
+}
+
+func TestCheckUnicodeNormalizationUnknownForm(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	_, err = doc.CheckUnicodeNormalization("NFX")
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrInvalidArgument {
+		t.Errorf("Expected ErrInvalidArgument, got %v", err)
+	}
+}
+
+// paddedNamesHEDL has accidental whitespace around the team key-value and
+// the name of row u1.
+const paddedNamesHEDL = `%VERSION: 1.0
+%STRUCT: User: [id, name]
+---
+team: " Core"
+users: @User
+  | u1, " Alice "
+  | u2, Bob
+`
+
+func TestCheckWhitespace(t *testing.T) {
+	doc, err := Parse(paddedNamesHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	diag, err := doc.CheckWhitespace()
+	if err != nil {
+		t.Fatalf("CheckWhitespace failed: %v", err)
+	}
+	defer diag.Close()
+
+	warnings, err := diag.Warnings()
+	if err != nil {
+		t.Fatalf("Warnings failed: %v", err)
+	}
+	if len(warnings) != 2 {
+		t.Fatalf("Expected 2 warnings, got %d: %v", len(warnings), warnings)
+	}
+	if !strings.Contains(warnings[0], "team") || !strings.Contains(warnings[1], `User row 0 field "name"`) {
+		t.Errorf("Unexpected warnings: %v", warnings)
+	}
+
+	trimmed, err := doc.TrimStrings()
+	if err != nil {
+		t.Fatalf("TrimStrings failed: %v", err)
+	}
+	defer trimmed.Close()
+
+	jsonStr, err := trimmed.ToJSON(false)
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	if !strings.Contains(jsonStr, `"Alice"`) || strings.Contains(jsonStr, `" Alice "`) {
+		t.Errorf("Expected name trimmed to \"Alice\", got %s", jsonStr)
+	}
+
+	clean, err := trimmed.CheckWhitespace()
+	if err != nil {
+		t.Fatalf("CheckWhitespace failed: %v", err)
+	}
+	defer clean.Close()
+	if clean.Count() != 0 {
+		t.Errorf("Expected no whitespace diagnostics after TrimStrings, got %d", clean.Count())
+	}
+}
+
+func TestCheckUnique(t *testing.T) {
+	doc, err := Parse(incompleteRowsHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	diag, err := doc.CheckUnique("User", "email")
+	if err != nil {
+		t.Fatalf("CheckUnique failed: %v", err)
+	}
+	defer diag.Close()
+
+	errs, err := diag.Errors()
+	if err != nil {
+		t.Fatalf("Errors failed: %v", err)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("Expected 1 duplicate, got %v", errs)
+	}
+	if !strings.Contains(errs[0], "alice@example.com") || !strings.Contains(errs[0], "rows 0, 2") {
+		t.Errorf("Expected the diagnostic to name rows 0 and 2, got %q", errs[0])
+	}
+
+	ids, err := doc.CheckUnique("User", "id")
+	if err != nil {
+		t.Fatalf("CheckUnique failed: %v", err)
+	}
+	defer ids.Close()
+	if ids.Count() != 0 {
+		t.Errorf("Expected unique IDs, got %d diagnostics", ids.Count())
+	}
+}
+
+// mixedUserListsHEDL has two User lists whose inline schemas differ, so
+// name is missing from the second list and email from the first.
+const mixedUserListsHEDL = `%VERSION: 1.0
+---
+a: @User[id, name]
+  | u1, Alice
+  | u2, Alice
+b: @User[id, email]
+  | u3, carol@example.com
+`
+
+func TestCheckUniqueMixedSchemas(t *testing.T) {
+	doc, err := Parse(mixedUserListsHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	diag, err := doc.CheckUnique("User", "name")
+	if err != nil {
+		t.Fatalf("CheckUnique failed: %v", err)
+	}
+	defer diag.Close()
+
+	errs, err := diag.Errors()
+	if err != nil {
+		t.Fatalf("Errors failed: %v", err)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0], "rows 0, 1") {
+		t.Errorf("Expected rows 0 and 1 to share a name, got %v", errs)
+	}
+}
+
+func TestCheckRangesMixedSchemas(t *testing.T) {
+	doc, err := Parse(mixedUserListsHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	diag, err := doc.CheckRanges(map[string][2]float64{"User.name": {0, 1}})
+	if err != nil {
+		t.Fatalf("CheckRanges failed: %v", err)
+	}
+	defer diag.Close()
+	if diag.Count() != 2 {
+		t.Errorf("Expected only the two named rows to be checked, got %d diagnostics", diag.Count())
+	}
+}
+
+func TestCheckConstraintsMixedSchemas(t *testing.T) {
+	doc, err := Parse(mixedUserListsHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	diag, err := doc.CheckConstraints([]Constraint{{Schema: "User", Field: "name", Op: "!=", Value: "Alice"}})
+	if err != nil {
+		t.Fatalf("CheckConstraints failed: %v", err)
+	}
+	defer diag.Close()
+	if diag.Count() != 2 {
+		t.Errorf("Expected only the two named rows to be checked, got %d diagnostics", diag.Count())
+	}
+}
+
+func TestCheckForeignKeysMixedSchemas(t *testing.T) {
+	doc, err := Parse(mixedUserListsHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	diag, err := doc.CheckForeignKeys([]ForeignKey{{FromSchema: "User", FromField: "name", ToSchema: "User", ToKey: "name"}})
+	if err != nil {
+		t.Fatalf("CheckForeignKeys failed: %v", err)
+	}
+	defer diag.Close()
+	if diag.Count() != 0 {
+		t.Errorf("Expected every name to match itself, got %d diagnostics", diag.Count())
+	}
+}
@@ -0,0 +1,203 @@
+package hedl
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ImportOptions controls how FromCSVWithOptions reads CSV.
+type ImportOptions struct {
+	// TypeName is the %STRUCT name given to the rows. The rows are stored
+	// under its lowercase plural, so "Row", the default, becomes "rows".
+	TypeName string
+	// Delimiter separates fields. Zero means ','; CSV written with a
+	// comma decimal separator commonly uses ';'.
+	Delimiter rune
+	// DecimalSeparator separates the integer and fractional parts of
+	// numbers. Zero means '.'; use ',' for European formats like "3,14".
+	DecimalSeparator rune
+	// ThousandsSeparator groups the digits of the integer part in threes,
+	// as in "1.234.567". Zero means numbers have no grouping.
+	ThousandsSeparator rune
+}
+
+// FromCSVWithOptions converts CSV with a header row into a document holding
+// one matrix list.
+//
+// The header names become the fields of a single %STRUCT, lowercased and
+// with other characters replaced by underscores to form valid keys. The
+// first column holds the row IDs and is kept as text. Every other column is
+// typed from its values: a column whose non-empty values are all true/false
+// becomes bool, all numbers becomes int or float, and anything else stays
+// string. Empty cells and "~" are null. Numbers are read using the
+// separators in opts and stored in standard form, so "1.234,5" with ','
+// as DecimalSeparator and '.' as ThousandsSeparator becomes 1234.5.
+//
+// Quoted fields may contain the delimiter and newlines. Rows with a
+// different number of fields than the header return an ErrCSV error.
+func FromCSVWithOptions(content string, opts ImportOptions) (*Document, error) {
+	typeName := opts.TypeName
+	if typeName == "" {
+		typeName = "Row"
+	}
+	decimal := opts.DecimalSeparator
+	if decimal == 0 {
+		decimal = '.'
+	}
+	if decimal == opts.ThousandsSeparator {
+		return nil, &HedlError{
+			Message: fmt.Sprintf("decimal and thousands separators are both %q", decimal),
+			Code:    ErrInvalidArgument,
+		}
+	}
+
+	r := csv.NewReader(strings.NewReader(content))
+	if opts.Delimiter != 0 {
+		r.Comma = opts.Delimiter
+	}
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, &HedlError{Message: fmt.Sprintf("invalid CSV: %v", err), Code: ErrCSV}
+	}
+	if len(records) == 0 {
+		return nil, &HedlError{Message: "CSV has no header row", Code: ErrCSV}
+	}
+
+	columns := csvFieldNames(records[0])
+	rows := make([]*matrixRow, len(records)-1)
+	for i := range rows {
+		rows[i] = &matrixRow{values: make([]interface{}, len(columns))}
+	}
+	for col := range columns {
+		cells := make([]string, len(rows))
+		for i, record := range records[1:] {
+			cells[i] = record[col]
+		}
+		values := csvColumnValues(cells, col == 0, decimal, opts.ThousandsSeparator)
+		for i, value := range values {
+			rows[i].values[col] = value
+		}
+	}
+
+	m := &docModel{
+		major:   1,
+		root:    newObject(),
+		structs: []schemaDef{{name: typeName, columns: columns}},
+	}
+	m.root.set(strings.ToLower(typeName)+"s", &matrixList{typeName: typeName, schema: columns, rows: rows})
+	return documentFromModel(m)
+}
+
+// csvFieldNames turns header names into unique key tokens.
+func csvFieldNames(header []string) []string {
+	names := make([]string, len(header))
+	seen := make(map[string]bool)
+	for i, h := range header {
+		var b strings.Builder
+		for _, r := range strings.ToLower(strings.TrimSpace(h)) {
+			if r == '_' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+				b.WriteRune(r)
+			} else {
+				b.WriteByte('_')
+			}
+		}
+		name := b.String()
+		if name == "" {
+			name = fmt.Sprintf("column_%d", i+1)
+		} else if name[0] >= '0' && name[0] <= '9' {
+			name = "_" + name
+		}
+		for base, n := name, 2; seen[name]; n++ {
+			name = fmt.Sprintf("%s_%d", base, n)
+		}
+		seen[name] = true
+		names[i] = name
+	}
+	return names
+}
+
+// csvColumnValues converts the cells of one column to model values, typing
+// the whole column at once. ID columns are kept as strings.
+func csvColumnValues(cells []string, isID bool, decimal, thousands rune) []interface{} {
+	values := make([]interface{}, len(cells))
+	isBool, isNumber := !isID, !isID
+	for i, cell := range cells {
+		if cell == "" || cell == "~" {
+			continue
+		}
+		values[i] = cell
+		if cell != "true" && cell != "false" {
+			isBool = false
+		}
+		if n, ok := parseLocaleNumber(cell, decimal, thousands); ok {
+			values[i] = n
+		} else {
+			isNumber = false
+		}
+	}
+	for i, cell := range cells {
+		switch {
+		case values[i] == nil:
+		case isBool:
+			values[i] = cell == "true"
+		case isNumber:
+		default:
+			values[i] = cell
+		}
+	}
+	return values
+}
+
+// parseLocaleNumber reads s as a number written with the given separators
+// and returns it in standard form. Thousands separators must group digits
+// in threes, so "1.5" is not read as 15 when '.' groups thousands.
+func parseLocaleNumber(s string, decimal, thousands rune) (json.Number, bool) {
+	var b strings.Builder
+	rest := s
+	if rest != "" && (rest[0] == '-' || rest[0] == '+') {
+		if rest[0] == '-' {
+			b.WriteByte('-')
+		}
+		rest = rest[1:]
+	}
+	intPart, fracPart, hasFrac := strings.Cut(rest, string(decimal))
+	if thousands != 0 && strings.ContainsRune(intPart, thousands) {
+		groups := strings.Split(intPart, string(thousands))
+		for i, group := range groups {
+			if len(group) == 0 || len(group) > 3 || i > 0 && len(group) != 3 {
+				return "", false
+			}
+		}
+		intPart = strings.Join(groups, "")
+	}
+	if !isDigits(intPart) || hasFrac && !isDigits(fracPart) {
+		return "", false
+	}
+	b.WriteString(intPart)
+	if hasFrac {
+		b.WriteByte('.')
+		b.WriteString(fracPart)
+		if _, err := strconv.ParseFloat(b.String(), 64); err != nil {
+			return "", false
+		}
+	} else if _, err := strconv.ParseInt(b.String(), 10, 64); err != nil {
+		return "", false
+	}
+	return json.Number(b.String()), true
+}
+
+// isDigits reports whether s is a non-empty run of ASCII digits.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
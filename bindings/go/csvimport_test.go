@@ -0,0 +1,78 @@
+package hedl
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestFromCSVWithOptionsEuropeanNumbers(t *testing.T) {
+	csv := "id;product;price;stock\n" +
+		"p1;Widget;1.234,56;1.200\n" +
+		"p2;\"Gadget; large\";0,99;7\n"
+	doc, err := FromCSVWithOptions(csv, ImportOptions{
+		TypeName:           "Product",
+		Delimiter:          ';',
+		DecimalSeparator:   ',',
+		ThousandsSeparator: '.',
+	})
+	if err != nil {
+		t.Fatalf("FromCSVWithOptions failed: %v", err)
+	}
+	defer doc.Close()
+
+	jsonStr, err := doc.ToJSON(false)
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	var data map[string][]map[string]interface{}
+	if err := json.Unmarshal([]byte(jsonStr), &data); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	items := data["products"]
+	if len(items) != 2 {
+		t.Fatalf("Expected 2 products, got %d: %s", len(items), jsonStr)
+	}
+	if price, ok := items[0]["price"].(float64); !ok || price != 1234.56 {
+		t.Errorf("Expected price 1234.56, got %#v", items[0]["price"])
+	}
+	if stock, ok := items[0]["stock"].(float64); !ok || stock != 1200 {
+		t.Errorf("Expected stock 1200, got %#v", items[0]["stock"])
+	}
+	if price, ok := items[1]["price"].(float64); !ok || price != 0.99 {
+		t.Errorf("Expected price 0.99, got %#v", items[1]["price"])
+	}
+	if name := items[1]["product"]; name != "Gadget; large" {
+		t.Errorf("Expected quoted product name, got %#v", name)
+	}
+}
+
+func TestFromCSVWithOptionsRagged(t *testing.T) {
+	_, err := FromCSVWithOptions("id,name\nu1,Alice\nu2\n", ImportOptions{})
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrCSV {
+		t.Errorf("Expected ErrCSV for a ragged row, got %v", err)
+	}
+}
+
+func TestParseLocaleNumber(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"1.234,56", "1234.56", true},
+		{"-0,5", "-0.5", true},
+		{"12.345.678", "12345678", true},
+		{"1.5", "", false},
+		{"12,34,5", "", false},
+		{"1.23.4", "", false},
+		{"abc", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := parseLocaleNumber(tt.in, ',', '.')
+		if ok != tt.ok || string(got) != tt.want {
+			t.Errorf("parseLocaleNumber(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package hedl
+
+import (
+	"bytes"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encodings reported by DetectEncoding.
+const (
+	EncodingUTF8    = "utf-8"
+	EncodingUTF16LE = "utf-16le"
+	EncodingUTF16BE = "utf-16be"
+	EncodingLatin1  = "latin-1"
+)
+
+// ParseOptions configures ParseBytes.
+type ParseOptions struct {
+	// Strict enables reference validation, as in Parse.
+	Strict bool
+	// Transcode converts UTF-16 and Latin-1 input to UTF-8 before parsing,
+	// using the encoding reported by DetectEncoding.
+	Transcode bool
+	// RetainSource keeps the parsed text on the Document, available from
+	// Source.
+	RetainSource bool
+	// TrackProvenance records the source line of every matrix row,
+	// available from RowSourceLine.
+	TrackProvenance bool
+}
+
+// DetectEncoding guesses the text encoding of data. A byte order mark is
+// trusted when present. Otherwise valid UTF-8 is reported as UTF-8, input
+// with NUL bytes in alternating positions as UTF-16, and anything else as
+// Latin-1, which can decode any byte sequence. UTF-32 byte order marks
+// return a HedlError with code ErrInvalidUTF8.
+func DetectEncoding(data []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE, 0x00, 0x00}),
+		bytes.HasPrefix(data, []byte{0x00, 0x00, 0xFE, 0xFF}):
+		return "", &HedlError{Message: "UTF-32 input is not supported", Code: ErrInvalidUTF8}
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		return EncodingUTF8, nil
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return EncodingUTF16LE, nil
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return EncodingUTF16BE, nil
+	}
+
+	if len(data) >= 2 && len(data)%2 == 0 {
+		var evenNUL, oddNUL int
+		for i, b := range data {
+			if b != 0 {
+				continue
+			}
+			if i%2 == 0 {
+				evenNUL++
+			} else {
+				oddNUL++
+			}
+		}
+		half := len(data) / 2
+		switch {
+		case oddNUL > half/2 && evenNUL == 0:
+			return EncodingUTF16LE, nil
+		case evenNUL > half/2 && oddNUL == 0:
+			return EncodingUTF16BE, nil
+		}
+	}
+	if utf8.Valid(data) {
+		return EncodingUTF8, nil
+	}
+	return EncodingLatin1, nil
+}
+
+// ParseBytes parses HEDL content from raw bytes. With opts.Transcode set,
+// UTF-16 and Latin-1 input is converted to UTF-8 first and a leading byte
+// order mark is dropped; otherwise the bytes must already be UTF-8.
+func ParseBytes(data []byte, opts ParseOptions) (*Document, error) {
+	content := string(data)
+	if opts.Transcode {
+		encoding, err := DetectEncoding(data)
+		if err != nil {
+			return nil, err
+		}
+		content = decodeText(data, encoding)
+	}
+	parse := Parse
+	if opts.TrackProvenance {
+		parse = parseWithProvenance
+	}
+	doc, err := parse(content, opts.Strict)
+	if err != nil {
+		return nil, err
+	}
+	if opts.RetainSource {
+		doc.source, doc.hasSource = content, true
+	}
+	return doc, nil
+}
+
+// Source returns the text the document was parsed from, after any
+// transcoding, when it was parsed with ParseOptions.RetainSource. The text
+// is not updated by in-place edits such as RenameField and stays available
+// after Close.
+func (d *Document) Source() (string, bool) {
+	return d.source, d.hasSource
+}
+
+// decodeText converts data in the given encoding to a UTF-8 string without
+// a byte order mark.
+func decodeText(data []byte, encoding string) string {
+	switch encoding {
+	case EncodingUTF16LE, EncodingUTF16BE:
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			lo, hi := data[2*i], data[2*i+1]
+			if encoding == EncodingUTF16BE {
+				lo, hi = hi, lo
+			}
+			units[i] = uint16(lo) | uint16(hi)<<8
+		}
+		if len(units) > 0 && units[0] == 0xFEFF {
+			units = units[1:]
+		}
+		return string(utf16.Decode(units))
+	case EncodingLatin1:
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	}
+	return string(bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF}))
+}
@@ -0,0 +1,97 @@
+package hedl
+
+import (
+	"testing"
+	"unicode/utf16"
+)
+
+// encodeUTF16LE encodes s as UTF-16LE with a byte order mark.
+func encodeUTF16LE(s string) []byte {
+	data := []byte{0xFF, 0xFE}
+	for _, unit := range utf16.Encode([]rune(s)) {
+		data = append(data, byte(unit), byte(unit>>8))
+	}
+	return data
+}
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"utf-8", []byte("key: café"), EncodingUTF8},
+		{"utf-8 bom", []byte("\xEF\xBB\xBFkey: value"), EncodingUTF8},
+		{"utf-16le bom", encodeUTF16LE("key: value"), EncodingUTF16LE},
+		{"utf-16le", encodeUTF16LE("key: value")[2:], EncodingUTF16LE},
+		{"utf-16be bom", []byte{0xFE, 0xFF, 0x00, 'k', 0x00, 'e'}, EncodingUTF16BE},
+		{"latin-1", []byte("key: caf\xE9"), EncodingLatin1},
+		{"empty", nil, EncodingUTF8},
+	}
+	for _, tt := range tests {
+		got, err := DetectEncoding(tt.data)
+		if err != nil {
+			t.Errorf("%s: DetectEncoding failed: %v", tt.name, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, got)
+		}
+	}
+
+	if _, err := DetectEncoding([]byte{0xFF, 0xFE, 0x00, 0x00}); err == nil {
+		t.Error("Expected error for UTF-32 input")
+	}
+}
+
+func TestParseBytesTranscode(t *testing.T) {
+	data := encodeUTF16LE(sampleHEDL)
+
+	if doc, err := ParseBytes(data, ParseOptions{Strict: true}); err == nil {
+		doc.Close()
+		t.Fatal("Expected UTF-16 input to fail without transcoding")
+	}
+
+	doc, err := ParseBytes(data, ParseOptions{Strict: true, Transcode: true})
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	defer doc.Close()
+
+	count, err := doc.SchemaCount()
+	if err != nil {
+		t.Fatalf("SchemaCount failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 schema, got %d", count)
+	}
+}
+
+func TestParseBytesRetainSource(t *testing.T) {
+	doc, err := ParseBytes([]byte(sampleHEDL), ParseOptions{Strict: true, RetainSource: true})
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	defer doc.Close()
+	if source, ok := doc.Source(); !ok || source != sampleHEDL {
+		t.Errorf("Expected the original source to be retained, got %q (%v)", source, ok)
+	}
+
+	plain, err := ParseBytes([]byte(sampleHEDL), ParseOptions{Strict: true})
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	defer plain.Close()
+	if source, ok := plain.Source(); ok || source != "" {
+		t.Errorf("Expected no source without RetainSource, got %q (%v)", source, ok)
+	}
+}
+
+func TestDecodeText(t *testing.T) {
+	if got := decodeText(encodeUTF16LE("héllo"), EncodingUTF16LE); got != "héllo" {
+		t.Errorf("Unexpected UTF-16LE decoding: %q", got)
+	}
+	if got := decodeText([]byte("caf\xE9"), EncodingLatin1); got != "café" {
+		t.Errorf("Unexpected Latin-1 decoding: %q", got)
+	}
+}
@@ -0,0 +1,143 @@
+package hedl
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strings"
+)
+
+// CSVOptions controls ToCSVWithOptions.
+type CSVOptions struct {
+	// NewlineReplacement, when set, replaces line breaks in string values,
+	// as EscapeNewlines does, so every record stays on one line. When empty,
+	// values with line breaks are kept and quoted as CSV allows.
+	NewlineReplacement string
+}
+
+// ToCSVWithOptions converts the document to CSV like ToCSV, applying opts.
+// The document itself is not modified.
+func (d *Document) ToCSVWithOptions(opts CSVOptions) (string, error) {
+	if opts.NewlineReplacement == "" {
+		return d.ToCSV()
+	}
+	doc, err := d.EscapeNewlines(opts.NewlineReplacement)
+	if err != nil {
+		return "", err
+	}
+	defer doc.Close()
+	return doc.ToCSV()
+}
+
+// ToCSVSorted converts the rows of schemaName to CSV sorted by keyField, so
+// that diffs of the output line up row by row. The header lists the schema's
+// fields in declaration order, and rows are ordered as in Pipeline.Sort:
+// nulls first, then booleans, numbers and strings, with ties kept in
+// document order. Fields missing from a list's inline schema are written
+// as empty, so rows of a list without keyField sort first. An unknown
+// schema or field returns an ErrNotFound error.
+func (d *Document) ToCSVSorted(schemaName, keyField string) (string, error) {
+	m, err := d.model()
+	if err != nil {
+		return "", err
+	}
+	columns, err := m.schemaColumns(schemaName)
+	if err != nil {
+		return "", err
+	}
+	if _, err := fieldIndex(schemaName, columns, keyField); err != nil {
+		return "", err
+	}
+	lists, err := m.listsOf(schemaName)
+	if err != nil {
+		return "", err
+	}
+
+	type keyedRow struct {
+		key    interface{}
+		values []interface{}
+	}
+	var rows []keyedRow
+	for _, list := range lists {
+		for _, row := range list.rows {
+			// Values are laid out by the declared columns, since a list's
+			// inline schema may differ from the declaration.
+			values := make([]interface{}, len(columns))
+			for i, column := range columns {
+				values[i], _ = list.value(row, column)
+			}
+			key, _ := list.value(row, keyField)
+			rows = append(rows, keyedRow{key, values})
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		return compareValues(rows[i].key, rows[j].key) < 0
+	})
+
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	if err := w.Write(columns); err != nil {
+		return "", err
+	}
+	for _, row := range rows {
+		record, err := textRecord(row.values)
+		if err != nil {
+			return "", err
+		}
+		if err := w.Write(record); err != nil {
+			return "", err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", err
+	}
+
+	output := b.String()
+	if err := checkStringOutputSize(output); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// limitedWriter counts the bytes written to w and fails a write that would
+// take the total past limit.
+type limitedWriter struct {
+	w     io.Writer
+	n     int64
+	limit int64
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if err := checkOutputLimit(l.n+int64(len(p)), l.limit); err != nil {
+		return 0, err
+	}
+	n, err := l.w.Write(p)
+	l.n += int64(n)
+	return n, err
+}
+
+// textRecord renders row values as plain text fields: strings as-is, null as
+// an empty field and everything else in HEDL syntax.
+func textRecord(values []interface{}) ([]string, error) {
+	record := make([]string, len(values))
+	for i, value := range values {
+		text, err := valueText(value)
+		if err != nil {
+			return nil, err
+		}
+		record[i] = text
+	}
+	return record, nil
+}
+
+// valueText renders a single value as plain text.
+func valueText(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	return formatScalar(value)
+}
@@ -0,0 +1,255 @@
+package hedl
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestToCSVZip(t *testing.T) {
+	fixtures := GetGlobalFixtures()
+	large, err := fixtures.LargeHEDL()
+	if err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+
+	doc, err := Parse(large, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	data, err := doc.ToCSVZip()
+	if err != nil {
+		t.Fatalf("ToCSVZip failed: %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Failed to open archive: %v", err)
+	}
+	var names []string
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+
+	want := []string{"Order.csv", "Product.csv", "User.csv"}
+	if len(names) != len(want) {
+		t.Fatalf("Expected entries %v, got %v", want, names)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("Expected entries %v, got %v", want, names)
+		}
+	}
+}
+
+const unsortedUsersHEDL = `%VERSION: 1.0
+%STRUCT: User: [id, name]
+---
+users: @User
+  | carol, Carol
+  | alice, Alice
+  | bob, Bob
+`
+
+func TestToCSVSorted(t *testing.T) {
+	doc, err := Parse(unsortedUsersHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	csv, err := doc.ToCSVSorted("User", "id")
+	if err != nil {
+		t.Fatalf("ToCSVSorted failed: %v", err)
+	}
+	want := "id,name\nalice,Alice\nbob,Bob\ncarol,Carol\n"
+	if csv != want {
+		t.Errorf("Expected rows in key order:\n%s\ngot:\n%s", want, csv)
+	}
+
+	if _, err := doc.ToCSVSorted("User", "email"); err == nil {
+		t.Error("Expected error for an unknown key field")
+	}
+}
+
+func TestToCSVSortedMixedSchemas(t *testing.T) {
+	doc, err := Parse(mixedUserListsHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	csv, err := doc.ToCSVSorted("User", "name")
+	if err != nil {
+		t.Fatalf("ToCSVSorted failed: %v", err)
+	}
+	want := "id,name\nu3,\nu1,Alice\nu2,Alice\n"
+	if csv != want {
+		t.Errorf("Expected rows laid out by the declared columns:\n%s\ngot:\n%s", want, csv)
+	}
+}
+
+const orderRefsHEDL = `%VERSION: 1.0
+%STRUCT: Customer: [id, name]
+%STRUCT: Order: [id, customer]
+---
+customers: @Customer
+  | c1, Alice
+orders: @Order
+  | o1, @Customer:c1
+`
+
+func TestToMermaidER(t *testing.T) {
+	fixtures := GetGlobalFixtures()
+	large, err := fixtures.LargeHEDL()
+	if err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+
+	doc, err := Parse(large, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	diagram, err := doc.ToMermaidER()
+	if err != nil {
+		t.Fatalf("ToMermaidER failed: %v", err)
+	}
+	if !strings.HasPrefix(diagram, "erDiagram") {
+		t.Errorf("Expected diagram to start with erDiagram, got %q", diagram)
+	}
+	for _, name := range []string{"User", "Product", "Order"} {
+		if !strings.Contains(diagram, name+" {") {
+			t.Errorf("Expected entity for %s in:\n%s", name, diagram)
+		}
+	}
+}
+
+func TestToMermaidERReferences(t *testing.T) {
+	doc, err := Parse(orderRefsHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	diagram, err := doc.ToMermaidER()
+	if err != nil {
+		t.Fatalf("ToMermaidER failed: %v", err)
+	}
+	want := `Order }o--|| Customer : "customer"`
+	if !strings.Contains(diagram, want) {
+		t.Errorf("Expected relationship %q in:\n%s", want, diagram)
+	}
+}
+
+func TestWriteCSVStream(t *testing.T) {
+	fixtures := GetGlobalFixtures()
+	large, err := fixtures.LargeHEDL()
+	if err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+
+	doc, err := Parse(large, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	var buf bytes.Buffer
+	n, err := doc.WriteCSVStream(&buf, "User")
+	if err != nil {
+		t.Fatalf("WriteCSVStream failed: %v", err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("Expected %d bytes written, got %d", buf.Len(), n)
+	}
+
+	imported, err := FromCSVWithOptions(buf.String(), ImportOptions{TypeName: "User"})
+	if err != nil {
+		t.Fatalf("FromCSVWithOptions failed: %v", err)
+	}
+	defer imported.Close()
+
+	var again bytes.Buffer
+	if _, err := imported.WriteCSVStream(&again, "User"); err != nil {
+		t.Fatalf("WriteCSVStream failed: %v", err)
+	}
+	if again.String() != buf.String() {
+		t.Errorf("CSV changed after a round trip:\ngot:\n%s\nwant:\n%s", again.String(), buf.String())
+	}
+	if !strings.HasPrefix(buf.String(), "id,name,email,age,country\nu1,Alice Smith,") {
+		t.Errorf("Unexpected CSV:\n%s", buf.String())
+	}
+	_, err = doc.WriteCSVStream(&again, "Missing")
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for an unknown schema, got %v", err)
+	}
+}
+
+func TestToCSVWithOptionsNewlineReplacement(t *testing.T) {
+	doc, err := Parse("%VERSION: 1.0\n%STRUCT: Note: [id, body]\n---\nnotes: @Note\n  | n1, \"first line\\nsecond line\"\n", true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	csv, err := doc.ToCSVWithOptions(CSVOptions{NewlineReplacement: " / "})
+	if err != nil {
+		t.Fatalf("ToCSVWithOptions failed: %v", err)
+	}
+	if !strings.Contains(csv, "first line / second line") {
+		t.Errorf("Expected the newline to be replaced in:\n%s", csv)
+	}
+	if strings.Contains(csv, "first line\n") {
+		t.Errorf("Expected no embedded newline in:\n%s", csv)
+	}
+
+	quoted, err := doc.ToCSVWithOptions(CSVOptions{})
+	if err != nil {
+		t.Fatalf("ToCSVWithOptions failed: %v", err)
+	}
+	if !strings.Contains(quoted, "first line\nsecond line") {
+		t.Errorf("Expected the newline to be kept by default in:\n%s", quoted)
+	}
+}
+
+func TestToYAMLMulti(t *testing.T) {
+	fixtures := GetGlobalFixtures()
+	large, err := fixtures.LargeHEDL()
+	if err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+
+	doc, err := Parse(large, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	yaml, err := doc.ToYAMLMulti()
+	if err != nil {
+		t.Fatalf("ToYAMLMulti failed: %v", err)
+	}
+	count, err := doc.RootItemCount()
+	if err != nil {
+		t.Fatalf("RootItemCount failed: %v", err)
+	}
+
+	separators := 0
+	for _, line := range strings.Split(yaml, "\n") {
+		if line == "---" {
+			separators++
+		}
+	}
+	if separators != count-1 {
+		t.Errorf("Expected %d separators for %d root items, got %d in:\n%s", count-1, count, separators, yaml)
+	}
+}
@@ -0,0 +1,166 @@
+package hedl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// roundTrip exports d to format and reads the result back.
+func roundTrip(d *Document, format string) (*Document, error) {
+	text := func(fn func(string) (*Document, error)) func([]byte) (*Document, error) {
+		return func(data []byte) (*Document, error) { return fn(string(data)) }
+	}
+
+	var importer func([]byte) (*Document, error)
+	switch strings.ToLower(format) {
+	case "hedl":
+		importer = text(func(s string) (*Document, error) { return Parse(s, true) })
+	case "json":
+		importer = text(FromJSON)
+	case "yaml":
+		importer = text(FromYAML)
+	case "xml":
+		importer = text(FromXML)
+	case "csv":
+		importer = text(FromCSV)
+	case "parquet":
+		importer = FromParquet
+	case "toml":
+		// TOML is not a Transcode format, so it is exported here directly.
+		s, err := d.ToTOML()
+		if err != nil {
+			return nil, err
+		}
+		return FromTOML(s)
+	default:
+		return nil, unknownFormat(format)
+	}
+
+	export, err := exporterFor(format)
+	if err != nil {
+		return nil, err
+	}
+	data, err := export(d)
+	if err != nil {
+		return nil, err
+	}
+	return importer(data)
+}
+
+// FidelityScore exports the document to format, reads it back and returns
+// the fraction of values that survived the round trip exactly, from 0 to 1;
+// 1.0 means the format is lossless for this document.
+//
+// format is one of "hedl", "json", "yaml", "xml", "csv", "parquet" or
+// "toml", exported as by Transcode. Every key-value pair and matrix cell
+// counts as one value. Key-values are matched by path and cells by schema,
+// row index and field, so a value whose type or text changes (a number read
+// back as a string, say) counts as lost as well as one that is dropped. A
+// value that only the read-back document has counts as a change too, so
+// the score is the preserved values over the values of either document.
+// Each lost, changed or added value is reported as a warning with rule
+// "fidelity". A document without values scores 1.0. An unknown format
+// returns ErrInvalidArgument.
+func (d *Document) FidelityScore(format string) (float64, *Diagnostics, error) {
+	back, err := roundTrip(d, format)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer back.Close()
+
+	before, err := d.model()
+	if err != nil {
+		return 0, nil, err
+	}
+	after, err := back.model()
+	if err != nil {
+		return 0, nil, err
+	}
+	original, err := fidelityValues(before)
+	if err != nil {
+		return 0, nil, err
+	}
+	returned, err := fidelityValues(after)
+	if err != nil {
+		return 0, nil, err
+	}
+	score, items := compareFidelity(original, returned, format)
+	return score, newDiagnostics(items), nil
+}
+
+// compareFidelity scores the values returned by a round trip through
+// format against the original ones and lists the differences.
+func compareFidelity(original, returned []fidelityValue, format string) (float64, []*Diagnostic) {
+	texts := make(map[string]string, len(returned))
+	for _, v := range returned {
+		texts[v.location] = v.text
+	}
+
+	var items []*Diagnostic
+	preserved := 0
+	seen := make(map[string]bool, len(original))
+	for _, v := range original {
+		seen[v.location] = true
+		got, ok := texts[v.location]
+		switch {
+		case !ok:
+			items = append(items, newDiagnostic(SeverityWarning, "fidelity",
+				"%s lost in %s round trip", v.location, format))
+		case got != v.text:
+			items = append(items, newDiagnostic(SeverityWarning, "fidelity",
+				"%s changed from %s to %s in %s round trip", v.location, v.text, got, format))
+		default:
+			preserved++
+		}
+	}
+	total := len(original)
+	for _, v := range returned {
+		if !seen[v.location] {
+			total++
+			items = append(items, newDiagnostic(SeverityWarning, "fidelity",
+				"%s added as %s in %s round trip", v.location, v.text, format))
+		}
+	}
+	if total == 0 {
+		return 1, nil
+	}
+	return float64(preserved) / float64(total), items
+}
+
+// fidelityValue is a value of a document with its location and HEDL text.
+type fidelityValue struct {
+	location string
+	text     string
+}
+
+// fidelityValues lists every key-value pair and matrix cell of m. Rows are
+// indexed per schema in document order, as in RowsWithMissing.
+func fidelityValues(m *docModel) ([]fidelityValue, error) {
+	var values []fidelityValue
+	var err error
+	add := func(location string, value interface{}) {
+		if err != nil {
+			return
+		}
+		var text string
+		text, err = formatCell(value)
+		values = append(values, fidelityValue{location, text})
+	}
+
+	m.eachKeyValue(func(path string, value interface{}) {
+		add(path, value)
+	})
+	rowsSeen := make(map[string]int)
+	m.eachList(func(list *matrixList) {
+		for _, row := range list.rows {
+			index := rowsSeen[list.typeName]
+			rowsSeen[list.typeName]++
+			for c, value := range row.values {
+				if c < len(list.schema) {
+					add(fmt.Sprintf("%s row %d field %q", list.typeName, index, list.schema[c]), value)
+				}
+			}
+		}
+	})
+	return values, err
+}
@@ -0,0 +1,81 @@
+package hedl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// nullableHEDL holds a null, which TOML cannot represent.
+const nullableHEDL = `%VERSION: 1.0
+---
+name: Alice
+count: 3
+missing: ~
+`
+
+func TestFidelityScore(t *testing.T) {
+	doc, err := Parse(nullableHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	score, diag, err := doc.FidelityScore("json")
+	if err != nil {
+		t.Fatalf("FidelityScore(json) failed: %v", err)
+	}
+	defer diag.Close()
+	if score != 1 || diag.Count() != 0 {
+		t.Errorf("Expected a lossless JSON round trip, got score %v with %d diagnostics", score, diag.Count())
+	}
+
+	score, diag, err = doc.FidelityScore("toml")
+	if err != nil {
+		t.Fatalf("FidelityScore(toml) failed: %v", err)
+	}
+	defer diag.Close()
+	if score != 2.0/3 {
+		t.Errorf("Expected TOML, which has no null, to score 2/3, got %v", score)
+	}
+	warnings, err := diag.Warnings()
+	if err != nil {
+		t.Fatalf("Warnings failed: %v", err)
+	}
+	found := false
+	for _, w := range warnings {
+		if strings.Contains(w, "missing lost in toml round trip") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected missing to be reported lost, got %q", warnings)
+	}
+}
+
+func TestCompareFidelityAddedValues(t *testing.T) {
+	original := []fidelityValue{{"name", "Alice"}}
+	returned := []fidelityValue{{"name", "Alice"}, {"extra", "~"}}
+
+	score, items := compareFidelity(original, returned, "xml")
+	if score != 0.5 {
+		t.Errorf("Expected an added value to halve the score, got %v", score)
+	}
+	if len(items) != 1 || !strings.HasSuffix(items[0].Message, "extra added as ~ in xml round trip") {
+		t.Fatalf("Expected the added value to be reported, got %d diagnostics", len(items))
+	}
+}
+
+func TestFidelityScoreUnknownFormat(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	_, _, err = doc.FidelityScore("cypher")
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrInvalidArgument {
+		t.Errorf("Expected ErrInvalidArgument for a format that cannot be read back, got %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package hedl
+
+import (
+	"strings"
+	"testing"
+)
+
+// messyUsersHEDL has padded strings, a CRLF line ending, numbers and
+// booleans quoted as strings and an unused schema.
+const messyUsersHEDL = `%VERSION: 1.0
+%STRUCT: User: [id, name, age, active, notes]
+%STRUCT: Tag: [id, label]
+---
+team: "  Core  "
+users: @User
+  | alice, " Alice ", 30, true, "line one\r\nline two"
+  | bob, Bob, "41", "False", ~
+`
+
+func TestAutoFix(t *testing.T) {
+	doc, err := Parse(messyUsersHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	fixed, changes, err := doc.AutoFix()
+	if err != nil {
+		t.Fatalf("AutoFix failed: %v", err)
+	}
+	defer fixed.Close()
+
+	want := []string{
+		"trimmed whitespace in team",
+		`trimmed whitespace in User row 0 field "name"`,
+		`normalized line endings in User row 0 field "notes"`,
+		`coerced User row 1 field "age" from "41" to int`,
+		`coerced User row 1 field "active" from "False" to bool`,
+		"removed unused schema Tag",
+	}
+	if strings.Join(changes, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Unexpected changelog:\ngot:  %q\nwant: %q", changes, want)
+	}
+
+	diag, err := fixed.Lint()
+	if err != nil {
+		t.Fatalf("Lint failed: %v", err)
+	}
+	defer diag.Close()
+	if diag.Count() != 0 {
+		all, _ := diag.All()
+		for _, d := range all {
+			t.Errorf("Unexpected diagnostic after AutoFix: %s", d.Message)
+		}
+	}
+
+	_, again, err := fixed.AutoFix()
+	if err != nil {
+		t.Fatalf("AutoFix failed: %v", err)
+	}
+	if len(again) != 0 {
+		t.Errorf("Expected no further fixes, got %q", again)
+	}
+}
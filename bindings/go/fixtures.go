@@ -121,6 +121,12 @@ func (f *Fixtures) ListsHEDL() (string, error) {
 
 // Performance fixtures
 
+// MediumHEDL returns a medium-sized HEDL document with 50 rows spread over
+// 10 departments.
+func (f *Fixtures) MediumHEDL() (string, error) {
+	return f.readFile(f.manifest.Fixtures["medium"].Files["hedl"])
+}
+
 // LargeHEDL returns a large HEDL document for performance testing.
 func (f *Fixtures) LargeHEDL() (string, error) {
 	return f.readFile(f.manifest.Fixtures["large"].Files["hedl"])
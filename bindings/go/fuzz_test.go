@@ -0,0 +1,69 @@
+package hedl
+
+import (
+	"errors"
+	"testing"
+)
+
+// FuzzParse feeds arbitrary input through the parsing entry points to make
+// sure malformed documents surface as errors rather than crashes.
+//
+// The seed corpus is built from every shared fixture, including the error
+// fixtures. Run with:
+//
+//	go test -run='^$' -fuzz=FuzzParse
+func FuzzParse(f *testing.F) {
+	fixtures := GetGlobalFixtures()
+	for category, entry := range fixtures.manifest.Fixtures {
+		if _, ok := entry.Files["hedl"]; !ok {
+			continue
+		}
+		content, err := fixtures.GetFixture(category, "hedl")
+		if err != nil {
+			f.Fatalf("Failed to load fixture %q: %v", category, err)
+		}
+		f.Add(content, true)
+		f.Add(content, false)
+	}
+	for errorType := range fixtures.manifest.Errors {
+		content, err := fixtures.GetErrorFixture(errorType)
+		if err != nil {
+			f.Fatalf("Failed to load error fixture %q: %v", errorType, err)
+		}
+		f.Add(content, true)
+	}
+	f.Add("", true)
+	f.Add("%VERSION: 1.0\n---\n", false)
+	f.Add("%VERSION: 1.0\n---\nkey: \x00value\n", true)
+	f.Add("%VERSION: 1.0\n---\nkey: \xff\xfe\n", true)
+
+	f.Fuzz(func(t *testing.T, content string, strict bool) {
+		valid := Validate(content, strict)
+
+		doc, err := Parse(content, strict)
+		if err != nil {
+			if doc != nil {
+				t.Fatal("Expected nil document alongside a parse error")
+			}
+			var hedlErr *HedlError
+			if !errors.As(err, &hedlErr) {
+				t.Fatalf("Expected *HedlError, got %T", err)
+			}
+			if valid {
+				t.Fatal("Validate accepted input that Parse rejected")
+			}
+			return
+		}
+		if !valid {
+			t.Fatal("Validate rejected input that Parse accepted")
+		}
+
+		if _, err := doc.Canonicalize(); err != nil {
+			if _, ok := err.(*HedlError); !ok {
+				t.Fatalf("Expected *HedlError from Canonicalize, got %T", err)
+			}
+		}
+		doc.Close()
+		doc.Close()
+	})
+}
@@ -101,39 +101,73 @@ package hedl
 #define HEDL_ERR_PARQUET      -10
 #define HEDL_ERR_LINT         -11
 #define HEDL_ERR_NEO4J        -12
+#define HEDL_ERR_TIMEOUT      -13
+#define HEDL_ERR_NOT_FOUND    -14
+#define HEDL_ERR_TOML         -15
+#define HEDL_ERR_CONFLICT     -16
+#define HEDL_ERR_INVALID_ARGUMENT -17
+#define HEDL_ERR_SQLITE       -18
 
 // Opaque types
 typedef struct HedlDocument HedlDocument;
 typedef struct HedlDiagnostics HedlDiagnostics;
+typedef struct HedlCsvCursor HedlCsvCursor;
 
 // Error handling
 extern const char* hedl_get_last_error(void);
+extern int hedl_get_last_error_location(int* line, int* column);
 
 // Memory management
 extern void hedl_free_string(char* s);
 extern void hedl_free_document(HedlDocument* doc);
+extern long long hedl_live_document_count(void);
 extern void hedl_free_diagnostics(HedlDiagnostics* diag);
+extern void hedl_free_csv_cursor(HedlCsvCursor* cursor);
 extern void hedl_free_bytes(uint8_t* data, size_t len);
 
 // Parsing
 extern int hedl_parse(const char* input, int input_len, int strict, HedlDocument** out_doc);
 extern int hedl_validate(const char* input, int input_len, int strict);
+extern int hedl_validate_range(const char* input, int input_len, int start_line, int end_line, int strict, HedlDiagnostics** out_diag);
+extern int hedl_parse_with_deadline(const char* input, int input_len, int strict, long long timeout_ms, HedlDocument** out_doc);
+extern int hedl_parse_with_provenance(const char* input, int input_len, int strict, HedlDocument** out_doc);
 
 // Document info
 extern int hedl_get_version(const HedlDocument* doc, int* major, int* minor);
 extern int hedl_schema_count(const HedlDocument* doc);
+extern int hedl_schema_names(const HedlDocument* doc, char** out_str);
+extern int hedl_schema_checksum(const HedlDocument* doc, const char* schema_name, char** out_str);
 extern int hedl_alias_count(const HedlDocument* doc);
+extern int hedl_aliases(const HedlDocument* doc, char** out_str);
+extern int hedl_largest_values(const HedlDocument* doc, int n, char** out_str);
+extern int hedl_document_memory(const HedlDocument* doc, long long* out_bytes);
 extern int hedl_root_item_count(const HedlDocument* doc);
+extern int hedl_row_source_line(const HedlDocument* doc, const char* schema_name, int index, int* out_line);
 
 // Canonicalization
 extern int hedl_canonicalize(const HedlDocument* doc, char** out_str);
+extern int hedl_canonicalize_path(const HedlDocument* doc, const char* path, char** out_str);
+extern int hedl_to_git_friendly(const HedlDocument* doc, char** out_str);
+extern int hedl_normalize_scalars(const HedlDocument* doc, HedlDocument** out_doc);
 
 // JSON
 extern int hedl_to_json(const HedlDocument* doc, int include_metadata, char** out_str);
+extern int hedl_to_grouped_json(const HedlDocument* doc, const char* schema_name, const char* group_by, char** out_str);
+extern int hedl_to_json_page(const HedlDocument* doc, const char* schema_name, int offset, int limit, char** out_str);
+extern int hedl_get_by_key(const HedlDocument* doc, const char* schema_name, const char* key_field, const char* key_value, int first_wins, char** out_str);
+extern int hedl_index_by(const HedlDocument* doc, const char* schema_name, const char* key_field, int allow_duplicates, char** out_str);
+extern int hedl_example_json(const HedlDocument* doc, char** out_str);
 extern int hedl_from_json(const char* json, int json_len, HedlDocument** out_doc);
 
+// Streaming
+typedef int (*hedl_chunk_callback)(const char* data, size_t len, void* user_data);
+extern int hedl_to_json_chunked(const HedlDocument* doc, int include_metadata, size_t chunk_size, hedl_chunk_callback callback, void* user_data);
+
+extern int hedlWriteChunk(char* data, size_t len, void* user_data);
+
 // YAML
 extern int hedl_to_yaml(const HedlDocument* doc, int include_metadata, char** out_str);
+extern int hedl_to_yaml_multi(const HedlDocument* doc, char** out_str);
 extern int hedl_from_yaml(const char* yaml, int yaml_len, HedlDocument** out_doc);
 
 // XML
@@ -142,27 +176,84 @@ extern int hedl_from_xml(const char* xml, int xml_len, HedlDocument** out_doc);
 
 // CSV
 extern int hedl_to_csv(const HedlDocument* doc, char** out_str);
+extern int hedl_to_csv_zip(const HedlDocument* doc, uint8_t** out_data, size_t* out_len);
+extern int hedl_csv_cursor_open(const HedlDocument* doc, const char* schema_name, HedlCsvCursor** out_cursor);
+extern int hedl_csv_cursor_next(HedlCsvCursor* cursor, int max_rows, char** out_str);
+extern int hedl_from_csv(const char* csv, int csv_len, HedlDocument** out_doc);
 
 // Parquet
 extern int hedl_to_parquet(const HedlDocument* doc, uint8_t** out_data, size_t* out_len);
 extern int hedl_from_parquet(const uint8_t* data, size_t len, HedlDocument** out_doc);
 
+// SQLite
+extern int hedl_to_sqlite(const HedlDocument* doc, uint8_t** out_data, size_t* out_len);
+
+// TOML
+extern int hedl_to_toml(const HedlDocument* doc, char** out_str);
+extern int hedl_from_toml(const char* toml, int toml_len, HedlDocument** out_doc);
+
 // Neo4j
 extern int hedl_to_neo4j_cypher(const HedlDocument* doc, int use_merge, char** out_str);
 
+// Mermaid
+extern int hedl_schema_descriptors(const HedlDocument* doc, char** out_str);
+extern int hedl_to_mermaid_er(const HedlDocument* doc, char** out_str);
+extern int hedl_to_properties(const HedlDocument* doc, char** out_str);
+extern int hedl_to_influx_line_protocol(const HedlDocument* doc, const char* measurement, const char* time_field, const char* const* tag_fields, int tag_count, char** out_str);
+
 // Linting
 extern int hedl_lint(const HedlDocument* doc, HedlDiagnostics** out_diag);
+extern int hedl_lint_warning_count(const HedlDocument* doc);
+extern int hedl_check_unicode_normalization(const HedlDocument* doc, const char* form, HedlDiagnostics** out_diag);
+extern int hedl_check_schema_references(const HedlDocument* doc, HedlDiagnostics** out_diag);
+extern int hedl_rows_with_missing(const HedlDocument* doc, const char* schema_name, char** out_str);
+extern int hedl_unused_fields(const HedlDocument* doc, const char* schema_name, char** out_str);
+extern int hedl_mixed_type_fields(const HedlDocument* doc, const char* schema_name, char** out_str);
+extern int hedl_field_stats(const HedlDocument* doc, const char* schema_name, int* out_rows, char** out_str);
+extern int hedl_find_reference_cycle(const HedlDocument* doc, char** out_str);
+extern int hedl_check_unique(const HedlDocument* doc, const char* schema_name, const char* field, HedlDiagnostics** out_diag);
+extern int hedl_check_ranges(const HedlDocument* doc, const char* const* fields, const double* mins, const double* maxs, int field_count, HedlDiagnostics** out_diag);
+extern int hedl_check_foreign_keys(const HedlDocument* doc, const char* const* from_fields, const char* const* to_fields, int key_count, HedlDiagnostics** out_diag);
+extern int hedl_check_whitespace(const HedlDocument* doc, HedlDiagnostics** out_diag);
+extern int hedl_trim_strings(const HedlDocument* doc, HedlDocument** out_doc);
+extern int hedl_auto_fix(const HedlDocument* doc, HedlDocument** out_doc, char** out_changes);
+extern int hedl_partition_keys(const HedlDocument* doc, const char* schema_name, const char* field, char** out_str);
+extern int hedl_partition(const HedlDocument* doc, const char* schema_name, const char* field, const char* key, HedlDocument** out_doc);
+extern int hedl_dedup(HedlDocument* doc, const char* schema_name, const char* const* fields, int field_count, int* out_removed);
+extern int hedl_normalize_dates(const HedlDocument* doc, const char* const* fields, int field_count, const char* target_format, HedlDocument** out_doc, HedlDiagnostics** out_diag);
+extern int hedl_tail(const HedlDocument* doc, int n, HedlDocument** out_doc);
+extern int hedl_head(const HedlDocument* doc, int n, HedlDocument** out_doc);
+extern int hedl_token_count(const char* model, const char* text, int text_len, int* out_count);
+extern int hedl_fit_to_tokens(const HedlDocument* doc, const char* model, int max_tokens, char** out_str, HedlDiagnostics** out_diag);
+extern int hedl_rename_field(HedlDocument* doc, const char* schema_name, const char* old_name, const char* new_name);
+extern int hedl_rename_schema(HedlDocument* doc, const char* old_name, const char* new_name);
+extern int hedl_normalize_references(HedlDocument* doc, const char* mode);
+extern int hedl_join(const HedlDocument* doc, const HedlDocument* other, const char* schema_name, const char* key_field, const char* const* add_fields, int field_count, HedlDocument** out_doc);
 extern int hedl_diagnostics_count(const HedlDiagnostics* diag);
 extern int hedl_diagnostics_get(const HedlDiagnostics* diag, int index, char** out_str);
 extern int hedl_diagnostics_severity(const HedlDiagnostics* diag, int index);
+extern int hedl_diagnostics_line(const HedlDiagnostics* diag, int index);
+extern int hedl_diagnostics_column(const HedlDiagnostics* diag, int index);
+extern int hedl_diagnostics_rule(const HedlDiagnostics* diag, int index, char** out_str);
 */
 import "C"
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"math"
 	"os"
 	"runtime"
+	"runtime/cgo"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
@@ -182,6 +273,26 @@ func init() {
 	}
 }
 
+// maxOutputSizeKey is the context key for per-request output size limits.
+type maxOutputSizeKey struct{}
+
+// WithMaxOutputSize returns a copy of ctx carrying an output size limit in
+// bytes. The Context variants of the conversion methods use it in place of
+// the HEDL_MAX_OUTPUT_SIZE default, so per-tenant limits can flow through a
+// request without changing process-wide state.
+func WithMaxOutputSize(ctx context.Context, maxBytes int64) context.Context {
+	return context.WithValue(ctx, maxOutputSizeKey{}, maxBytes)
+}
+
+// outputLimit returns the output size limit carried by ctx, falling back to
+// the global default.
+func outputLimit(ctx context.Context) int64 {
+	if limit, ok := ctx.Value(maxOutputSizeKey{}).(int64); ok {
+		return limit
+	}
+	return maxOutputSize
+}
+
 // Error codes
 const (
 	ErrNullPtr     = -1
@@ -196,6 +307,22 @@ const (
 	ErrParquet     = -10
 	ErrLint        = -11
 	ErrNeo4j       = -12
+	ErrTimeout     = -13
+)
+
+// Binding-level error codes. These are reported by the Go bindings themselves;
+// the native HEDL_ERR_NOT_FOUND, HEDL_ERR_TOML, HEDL_ERR_CONFLICT,
+// HEDL_ERR_INVALID_ARGUMENT and HEDL_ERR_SQLITE codes are also reported as
+// ErrNotFound, ErrTOML, ErrConflict, ErrInvalidArgument and ErrSQLite.
+const (
+	ErrNotFound            = -100
+	ErrCyclicReference     = -101
+	ErrInvalidArgument     = -102
+	ErrConflict            = -103
+	ErrIO                  = -104
+	ErrTOML                = -105
+	ErrFingerprintMismatch = -106
+	ErrSQLite              = -107
 )
 
 // Severity levels for diagnostics
@@ -215,6 +342,121 @@ func (e *HedlError) Error() string {
 	return e.Message
 }
 
+// Is reports whether target is a *HedlError with the same Code, so that
+// errors.Is(err, ErrParseFailed) matches any parse error whatever its
+// message.
+func (e *HedlError) Is(target error) bool {
+	t, ok := target.(*HedlError)
+	return ok && t.Code == e.Code
+}
+
+// Sentinel errors for the native error codes, for use with errors.Is. Each
+// matches any HedlError with the corresponding code.
+var (
+	ErrNullPointer        = &HedlError{Message: "null pointer", Code: ErrNullPtr}
+	ErrInvalidUTF8Input   = &HedlError{Message: "invalid UTF-8 input", Code: ErrInvalidUTF8}
+	ErrParseFailed        = &HedlError{Message: "parse failed", Code: ErrParse}
+	ErrCanonicalizeFailed = &HedlError{Message: "canonicalization failed", Code: ErrCanonicalize}
+	ErrJSONConversion     = &HedlError{Message: "JSON conversion failed", Code: ErrJSON}
+	ErrAllocExceeded      = &HedlError{Message: "allocation or output size limit exceeded", Code: ErrAlloc}
+	ErrYAMLConversion     = &HedlError{Message: "YAML conversion failed", Code: ErrYAML}
+	ErrXMLConversion      = &HedlError{Message: "XML conversion failed", Code: ErrXML}
+	ErrCSVConversion      = &HedlError{Message: "CSV conversion failed", Code: ErrCSV}
+	ErrParquetConversion  = &HedlError{Message: "Parquet conversion failed", Code: ErrParquet}
+	ErrLintFailed         = &HedlError{Message: "lint failed", Code: ErrLint}
+	ErrNeo4jConversion    = &HedlError{Message: "Neo4j conversion failed", Code: ErrNeo4j}
+	ErrTimedOut           = &HedlError{Message: "operation timed out", Code: ErrTimeout}
+)
+
+// ParseError is the error returned by the Parse functions when the content is
+// malformed. It carries the position of the problem as reported by the
+// native parser, and unwraps to its HedlError, whose Code is ErrParse.
+type ParseError struct {
+	HedlError
+	// Line and Column are 1-based; Column is 0 when the parser did not
+	// report one.
+	Line   int
+	Column int
+	// Snippet is the source line at Line, without its line terminator.
+	Snippet string
+}
+
+// Unwrap returns the underlying HedlError.
+func (e *ParseError) Unwrap() error {
+	return &e.HedlError
+}
+
+// ioError is a HedlError with code ErrIO that keeps the read or write error
+// it reports, so errors.Is and errors.As match either.
+type ioError struct {
+	HedlError
+	cause error
+}
+
+// newIOError wraps err as an ErrIO HedlError.
+func newIOError(err error) error {
+	return &ioError{HedlError: HedlError{Message: err.Error(), Code: ErrIO}, cause: err}
+}
+
+// Unwrap returns the underlying HedlError and the wrapped error.
+func (e *ioError) Unwrap() []error {
+	return []error{&e.HedlError, e.cause}
+}
+
+// Error categories returned by CategoryOf.
+const (
+	CategoryParse           = "parse"
+	CategoryFormat          = "format"
+	CategoryAlloc           = "alloc"
+	CategoryLint            = "lint"
+	CategoryIO              = "io"
+	CategoryNotFound        = "not_found"
+	CategoryTimeout         = "timeout"
+	CategoryInvalidArgument = "invalid_argument"
+	CategoryConflict        = "conflict"
+	CategoryInternal        = "internal"
+	CategoryUnknown         = "unknown"
+)
+
+// CategoryOf maps an error to a low-cardinality category suitable for metric
+// labels. HedlError codes map to parse, format, alloc, lint, io, not_found,
+// timeout, invalid_argument, conflict or internal; filesystem errors also map
+// to io. Anything else, including nil, returns unknown.
+func CategoryOf(err error) string {
+	var hedlErr *HedlError
+	if errors.As(err, &hedlErr) {
+		switch hedlErr.Code {
+		case ErrParse, ErrInvalidUTF8, ErrCyclicReference:
+			return CategoryParse
+		case ErrCanonicalize, ErrJSON, ErrYAML, ErrXML, ErrCSV, ErrParquet, ErrNeo4j, ErrTOML, ErrSQLite:
+			return CategoryFormat
+		case ErrAlloc:
+			return CategoryAlloc
+		case ErrLint:
+			return CategoryLint
+		case ErrIO:
+			return CategoryIO
+		case ErrNotFound:
+			return CategoryNotFound
+		case ErrTimeout:
+			return CategoryTimeout
+		case ErrInvalidArgument:
+			return CategoryInvalidArgument
+		case ErrConflict, ErrFingerprintMismatch:
+			return CategoryConflict
+		case ErrNullPtr:
+			return CategoryInternal
+		}
+		return CategoryUnknown
+	}
+
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return CategoryIO
+	}
+	return CategoryUnknown
+}
+
 func newError(code C.int) error {
 	errStr := C.hedl_get_last_error()
 	var msg string
@@ -223,14 +465,55 @@ func newError(code C.int) error {
 	} else {
 		msg = fmt.Sprintf("HEDL error code %d", code)
 	}
+	switch code {
+	case C.HEDL_ERR_NOT_FOUND:
+		return &HedlError{Message: msg, Code: ErrNotFound}
+	case C.HEDL_ERR_TOML:
+		return &HedlError{Message: msg, Code: ErrTOML}
+	case C.HEDL_ERR_CONFLICT:
+		return &HedlError{Message: msg, Code: ErrConflict}
+	case C.HEDL_ERR_INVALID_ARGUMENT:
+		return &HedlError{Message: msg, Code: ErrInvalidArgument}
+	case C.HEDL_ERR_SQLITE:
+		return &HedlError{Message: msg, Code: ErrSQLite}
+	}
 	return &HedlError{Message: msg, Code: int(code)}
 }
 
+// newParseError returns the error for a parse of source that failed with
+// code: a *ParseError when the native parser reported where the problem is,
+// and a plain HedlError otherwise.
+func newParseError(code C.int, source string) error {
+	err := newError(code)
+	if code != ErrParse {
+		return err
+	}
+	var line, column C.int
+	if C.hedl_get_last_error_location(&line, &column) != 0 || line <= 0 {
+		return err
+	}
+	lines := strings.SplitN(source, "\n", int(line)+1)
+	snippet := ""
+	if int(line) <= len(lines) {
+		snippet = strings.Clone(strings.TrimSuffix(lines[int(line)-1], "\r"))
+	}
+	return &ParseError{
+		HedlError: *err.(*HedlError),
+		Line:      int(line),
+		Column:    int(column),
+		Snippet:   snippet,
+	}
+}
+
 func checkOutputSize(data []byte) error {
-	size := int64(len(data))
-	if size > maxOutputSize {
+	return checkOutputLimit(int64(len(data)), maxOutputSize)
+}
+
+// checkOutputLimit reports an ErrAlloc error when size exceeds limit.
+func checkOutputLimit(size, limit int64) error {
+	if size > limit {
 		actualMB := float64(size) / 1048576.0
-		limitMB := float64(maxOutputSize) / 1048576.0
+		limitMB := float64(limit) / 1048576.0
 		return &HedlError{
 			Message: fmt.Sprintf("Output size (%.2fMB) exceeds limit (%.2fMB). Set HEDL_MAX_OUTPUT_SIZE to increase.", actualMB, limitMB),
 			Code:    ErrAlloc,
@@ -240,32 +523,145 @@ func checkOutputSize(data []byte) error {
 }
 
 func checkStringOutputSize(s string) error {
-	return checkOutputSize([]byte(s))
+	return checkOutputLimit(int64(len(s)), maxOutputSize)
+}
+
+// inputLength converts an input length to the C int expected by the FFI.
+// Inputs that do not fit would wrap to a negative length, which the native
+// side interprets as "null-terminated", so they are rejected up front.
+func inputLength(n int) (C.int, error) {
+	if n > math.MaxInt32 {
+		return 0, &HedlError{
+			Message: fmt.Sprintf("Input size (%d bytes) exceeds the maximum supported input size (%d bytes)", n, math.MaxInt32),
+			Code:    ErrAlloc,
+		}
+	}
+	return C.int(n), nil
 }
 
 // Document represents a parsed HEDL document.
 type Document struct {
 	ptr *C.HedlDocument
+
+	// source is the parsed text, kept when ParseOptions.RetainSource is set.
+	source    string
+	hasSource bool
+
+	// schemaOrder lists the struct types in the order the parsed text
+	// declared them; see CanonOptions.SortSchemas.
+	schemaOrder []string
+
+	// profile is the most recent operation profile; see EnableProfiling.
+	profile OperationProfile
+}
+
+// openDocuments counts native documents that have been handed out and not yet
+// freed.
+var openDocuments int64
+
+// OpenDocuments returns the number of Documents whose native resources have
+// not been released yet. It is intended for leak checks in tests and metrics.
+func OpenDocuments() int64 {
+	return atomic.LoadInt64(&openDocuments)
+}
+
+// nativeDocuments returns the number of documents the native library has
+// handed out and not yet freed, counting any a binding failed to wrap.
+func nativeDocuments() int64 {
+	return int64(C.hedl_live_document_count())
+}
+
+// wrapParsedDocument is wrapDocument for the Parse functions, reporting
+// malformed source as a *ParseError and recording the declaration order of
+// the source's schemas.
+func wrapParsedDocument(result C.int, docPtr *C.HedlDocument, source string) (*Document, error) {
+	if result == ErrParse {
+		err := newParseError(result, source)
+		if docPtr != nil {
+			C.hedl_free_document(docPtr)
+		}
+		return nil, err
+	}
+	doc, err := wrapDocument(result, docPtr)
+	if err != nil {
+		return nil, err
+	}
+	doc.schemaOrder = declaredSchemas(source)
+	return doc, nil
+}
+
+// wrapDocument takes ownership of a document pointer returned by a parse or
+// import call. On a nonzero result any pointer the FFI populated anyway is
+// freed so error paths never leak native memory.
+func wrapDocument(result C.int, docPtr *C.HedlDocument) (*Document, error) {
+	if result != 0 {
+		err := newError(result)
+		if docPtr != nil {
+			C.hedl_free_document(docPtr)
+		}
+		return nil, err
+	}
+	if docPtr == nil {
+		return nil, &HedlError{Message: "FFI returned a null document", Code: ErrNullPtr}
+	}
+
+	atomic.AddInt64(&openDocuments, 1)
+	doc := &Document{ptr: docPtr}
+	runtime.SetFinalizer(doc, (*Document).Close)
+	return doc, nil
 }
 
 // Diagnostics represents lint diagnostics.
+//
+// Diagnostics are either backed by the native lint results or, for checks
+// implemented in the bindings, by a list of diagnostics built in Go.
 type Diagnostics struct {
-	ptr *C.HedlDiagnostics
+	ptr   *C.HedlDiagnostics
+	items []*Diagnostic
+}
+
+// newDiagnostics returns Diagnostics holding Go-produced items.
+func newDiagnostics(items []*Diagnostic) *Diagnostics {
+	if items == nil {
+		items = []*Diagnostic{}
+	}
+	return &Diagnostics{items: items}
 }
 
 // Diagnostic represents a single lint diagnostic.
 type Diagnostic struct {
 	Message  string
 	Severity int
+	// Line and Column give the 1-based start of the source range the
+	// diagnostic refers to, and EndLine and EndColumn its end; they are 0
+	// when unknown. Native lint rules report whole lines, so Column and
+	// EndColumn are 0 and EndLine equals Line. Checks implemented in the
+	// bindings have no source positions.
+	Line      int
+	Column    int
+	EndLine   int
+	EndColumn int
+	// RuleID names the rule that produced the diagnostic, such as
+	// "unused-schema" or, for the checks in the bindings, "enum".
+	RuleID string
 }
 
 // Parse parses HEDL content into a Document.
 //
 // If strict is true, reference validation is enabled.
 // The returned Document must be closed with Close() when done.
+// Malformed content is reported as a *ParseError giving the line and column
+// of the problem; it unwraps to a HedlError with code ErrParse.
 func Parse(content string, strict bool) (*Document, error) {
+	cLen, err := inputLength(len(content))
+	if err != nil {
+		return nil, err
+	}
+
+	t := startOp("Parse")
 	cContent := C.CString(content)
 	defer C.free(unsafe.Pointer(cContent))
+	t.copiedIn()
 
 	strictInt := 0
 	if strict {
@@ -273,312 +669,2502 @@ func Parse(content string, strict bool) (*Document, error) {
 	}
 
 	var docPtr *C.HedlDocument
-	result := C.hedl_parse(cContent, C.int(len(content)), C.int(strictInt), &docPtr)
-	if result != 0 {
-		return nil, newError(result)
+	result := C.hedl_parse(cContent, cLen, C.int(strictInt), &docPtr)
+	t.called()
+	doc, err := wrapParsedDocument(result, docPtr, content)
+	t.finish(doc)
+	return doc, err
+}
+
+// ParseDeadline is like Parse but gives up once deadline has passed,
+// returning a HedlError with code ErrTimeout. The native parser checks the
+// deadline periodically and stops cleanly, so no goroutine is left running
+// after a timeout. A deadline that has already passed fails without parsing.
+func ParseDeadline(content string, strict bool, deadline time.Time) (*Document, error) {
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return nil, &HedlError{Message: "parse deadline exceeded", Code: ErrTimeout}
+	}
+	timeoutMs := remaining.Milliseconds()
+	if timeoutMs == 0 {
+		timeoutMs = 1
 	}
 
-	doc := &Document{ptr: docPtr}
-	runtime.SetFinalizer(doc, (*Document).Close)
-	return doc, nil
-}
+	cLen, err := inputLength(len(content))
+	if err != nil {
+		return nil, err
+	}
 
-// Validate validates HEDL content without creating a document.
-func Validate(content string, strict bool) bool {
+	t := startOp("ParseDeadline")
 	cContent := C.CString(content)
 	defer C.free(unsafe.Pointer(cContent))
+	t.copiedIn()
 
 	strictInt := 0
 	if strict {
 		strictInt = 1
 	}
 
-	result := C.hedl_validate(cContent, C.int(len(content)), C.int(strictInt))
-	return result == 0
+	var docPtr *C.HedlDocument
+	result := C.hedl_parse_with_deadline(cContent, cLen, C.int(strictInt), C.longlong(timeoutMs), &docPtr)
+	t.called()
+	doc, err := wrapParsedDocument(result, docPtr, content)
+	t.finish(doc)
+	return doc, err
 }
 
-// FromJSON parses JSON content into a HEDL Document.
-func FromJSON(content string) (*Document, error) {
+
+// parseWithProvenance is like Parse but has the native library record the
+// source line of every matrix row, for ParseOptions.TrackProvenance.
+func parseWithProvenance(content string, strict bool) (*Document, error) {
+	cLen, err := inputLength(len(content))
+	if err != nil {
+		return nil, err
+	}
+
+	t := startOp("ParseWithProvenance")
 	cContent := C.CString(content)
 	defer C.free(unsafe.Pointer(cContent))
+	t.copiedIn()
 
-	var docPtr *C.HedlDocument
-	result := C.hedl_from_json(cContent, C.int(len(content)), &docPtr)
-	if result != 0 {
-		return nil, newError(result)
+	strictInt := 0
+	if strict {
+		strictInt = 1
 	}
 
-	doc := &Document{ptr: docPtr}
-	runtime.SetFinalizer(doc, (*Document).Close)
-	return doc, nil
+	var docPtr *C.HedlDocument
+	result := C.hedl_parse_with_provenance(cContent, cLen, C.int(strictInt), &docPtr)
+	t.called()
+	doc, err := wrapParsedDocument(result, docPtr, content)
+	t.finish(doc)
+	return doc, err
 }
 
-// FromYAML parses YAML content into a HEDL Document.
-func FromYAML(content string) (*Document, error) {
-	cContent := C.CString(content)
-	defer C.free(unsafe.Pointer(cContent))
+// parseReadChunk is the initial size of the buffer ParseReader reads into.
+const parseReadChunk = 64 * 1024
+
+// ParseReader is like Parse but reads the content from r, for example an
+// HTTP body or a gzip stream. The stream is read in chunks straight into
+// native memory, which is handed to the parser without first building a Go
+// string, so the content is held in memory once rather than twice.
+//
+// The native parser only accepts whole documents, so the stream is read to
+// the end before parsing and malformed content is reported afterwards, as a
+// *ParseError like Parse. Read errors are returned as a HedlError with code
+// ErrIO that wraps the reader's error.
+// Reading stops one byte past the maximum input size, failing with ErrAlloc.
+func ParseReader(r io.Reader, strict bool) (*Document, error) {
+	t := startOp("ParseReader")
+	capacity := int64(parseReadChunk)
+	buf := C.malloc(C.size_t(capacity))
+	if buf == nil {
+		return nil, &HedlError{Message: "failed to allocate the read buffer", Code: ErrAlloc}
+	}
+	defer func() { C.free(buf) }()
+
+	var size int64
+	for {
+		if size == capacity {
+			if capacity > math.MaxInt32 {
+				break
+			}
+			capacity *= 2
+			if capacity > math.MaxInt32+1 {
+				capacity = math.MaxInt32 + 1
+			}
+			grown := C.realloc(buf, C.size_t(capacity))
+			if grown == nil {
+				return nil, &HedlError{
+					Message: fmt.Sprintf("failed to grow the read buffer to %d bytes", capacity),
+					Code:    ErrAlloc,
+				}
+			}
+			buf = grown
+		}
+		n, err := r.Read(unsafe.Slice((*byte)(buf), capacity)[size:])
+		size += int64(n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, newIOError(err)
+		}
+	}
+	if size > math.MaxInt32 {
+		return nil, &HedlError{
+			Message: fmt.Sprintf("Input exceeds the maximum supported input size (%d bytes)", math.MaxInt32),
+			Code:    ErrAlloc,
+		}
+	}
+	t.copiedIn()
+
+	strictInt := 0
+	if strict {
+		strictInt = 1
+	}
 
 	var docPtr *C.HedlDocument
-	result := C.hedl_from_yaml(cContent, C.int(len(content)), &docPtr)
-	if result != 0 {
-		return nil, newError(result)
+	result := C.hedl_parse((*C.char)(buf), C.int(size), C.int(strictInt), &docPtr)
+	t.called()
+	// The snippet of a ParseError is cloned, so buf may be freed afterwards.
+	doc, err := wrapParsedDocument(result, docPtr, unsafe.String((*byte)(buf), size))
+	t.finish(doc)
+	return doc, err
+}
+
+// ParseFile parses the HEDL file at path, like Parse on its contents. The
+// file is read straight into native memory as by ParseReader and closed
+// before ParseFile returns. Errors opening or reading the file are returned
+// as a HedlError with code ErrIO.
+func ParseFile(path string, strict bool) (*Document, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, newIOError(err)
 	}
+	defer f.Close()
+	return ParseReader(f, strict)
+}
 
-	doc := &Document{ptr: docPtr}
-	runtime.SetFinalizer(doc, (*Document).Close)
-	return doc, nil
+// defaultStrict is the strictness used by ParseDefault. It defaults to true,
+// matching the native parser, and is guarded by defaultStrictMu.
+var (
+	defaultStrictMu sync.RWMutex
+	defaultStrict   = true
+)
+
+// SetDefaultStrict sets the package-wide strictness used by ParseDefault. It
+// is safe to call concurrently with ParseDefault.
+func SetDefaultStrict(strict bool) {
+	defaultStrictMu.Lock()
+	defaultStrict = strict
+	defaultStrictMu.Unlock()
 }
 
-// FromXML parses XML content into a HEDL Document.
-func FromXML(content string) (*Document, error) {
+// ParseDefault parses HEDL content using the package-wide strictness set by
+// SetDefaultStrict (true unless changed).
+func ParseDefault(content string) (*Document, error) {
+	defaultStrictMu.RLock()
+	strict := defaultStrict
+	defaultStrictMu.RUnlock()
+	return Parse(content, strict)
+}
+
+// Validate validates HEDL content without creating a document.
+func Validate(content string, strict bool) bool {
+	cLen, err := inputLength(len(content))
+	if err != nil {
+		return false
+	}
+
 	cContent := C.CString(content)
 	defer C.free(unsafe.Pointer(cContent))
 
-	var docPtr *C.HedlDocument
-	result := C.hedl_from_xml(cContent, C.int(len(content)), &docPtr)
-	if result != 0 {
-		return nil, newError(result)
+	strictInt := 0
+	if strict {
+		strictInt = 1
 	}
 
-	doc := &Document{ptr: docPtr}
-	runtime.SetFinalizer(doc, (*Document).Close)
-	return doc, nil
+	result := C.hedl_validate(cContent, cLen, C.int(strictInt))
+	return result == 0
 }
 
-// FromParquet parses Parquet content into a HEDL Document.
-func FromParquet(data []byte) (*Document, error) {
-	if len(data) == 0 {
-		return nil, errors.New("empty parquet data")
+// ValidateFile validates the HEDL file at path without creating a document.
+// It reports whether the file is valid; errors reading the file are
+// returned as a HedlError with code ErrIO.
+func ValidateFile(path string, strict bool) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, newIOError(err)
+	}
+	return Validate(string(data), strict), nil
+}
+
+// ValidateRange validates lines startLine through endLine (1-based,
+// inclusive) of content, for editors that revalidate the region being edited.
+//
+// Only an excerpt is parsed natively: the header, the range, and the lines it
+// needs to parse in place, such as the list header and parent rows it sits
+// under. A parse failure in the excerpt is reported as an error diagnostic
+// with rule ID "parse" on the line it occurred, which may be a header or
+// context line outside the range. Lines outside the excerpt are not
+// validated, and references are not resolved whatever strict is; use
+// Validate for the whole document. An invalid range returns a HedlError with
+// code ErrInvalidArgument.
+func ValidateRange(content string, startLine, endLine int, strict bool) (*Diagnostics, error) {
+	if startLine < 1 || endLine < startLine {
+		return nil, &HedlError{
+			Message: fmt.Sprintf("invalid line range %d-%d", startLine, endLine),
+			Code:    ErrInvalidArgument,
+		}
 	}
 
-	var docPtr *C.HedlDocument
-	result := C.hedl_from_parquet((*C.uint8_t)(unsafe.Pointer(&data[0])), C.size_t(len(data)), &docPtr)
+	cLen, err := inputLength(len(content))
+	if err != nil {
+		return nil, err
+	}
+
+	cContent := C.CString(content)
+	defer C.free(unsafe.Pointer(cContent))
+
+	strictInt := 0
+	if strict {
+		strictInt = 1
+	}
+
+	var diagPtr *C.HedlDiagnostics
+	result := C.hedl_validate_range(cContent, cLen, C.int(clamp(startLine, 1, math.MaxInt32)),
+		C.int(clamp(endLine, 1, math.MaxInt32)), C.int(strictInt), &diagPtr)
 	if result != 0 {
 		return nil, newError(result)
 	}
 
-	doc := &Document{ptr: docPtr}
-	runtime.SetFinalizer(doc, (*Document).Close)
-	return doc, nil
+	diag := &Diagnostics{ptr: diagPtr}
+	runtime.SetFinalizer(diag, (*Diagnostics).Close)
+	return diag, nil
 }
 
-// Close frees the document resources.
-func (d *Document) Close() {
-	if d.ptr != nil {
-		C.hedl_free_document(d.ptr)
-		d.ptr = nil
+// ValidateStrict validates content and also lints it, for CI gates that
+// should fail on warnings. It returns true when the content parses and lint
+// reports no errors and, if warningsAsErrors is set, no warnings. The
+// diagnostics hold every lint result, or the parse error as a single error
+// diagnostic; the returned error is reserved for failures other than
+// invalid content.
+func ValidateStrict(content string, strict bool, warningsAsErrors bool) (bool, *Diagnostics, error) {
+	doc, err := Parse(content, strict)
+	if err != nil {
+		var hedlErr *HedlError
+		if !errors.As(err, &hedlErr) || hedlErr.Code != ErrParse {
+			return false, nil, err
+		}
+		return false, newDiagnostics([]*Diagnostic{parseErrorDiagnostic(err, hedlErr.Message)}), nil
 	}
-}
+	defer doc.Close()
 
-// Version returns the HEDL version as (major, minor).
-func (d *Document) Version() (int, int, error) {
-	if d.ptr == nil {
-		return 0, 0, errors.New("document closed")
+	lint, err := doc.Lint()
+	if err != nil {
+		return false, nil, err
+	}
+	all, err := lint.All()
+	lint.Close()
+	if err != nil {
+		return false, nil, err
 	}
 
-	var major, minor C.int
-	result := C.hedl_get_version(d.ptr, &major, &minor)
-	if result != 0 {
-		return 0, 0, newError(result)
+	valid := true
+	for _, diag := range all {
+		if diag.Severity == SeverityError || (warningsAsErrors && diag.Severity == SeverityWarning) {
+			valid = false
+		}
 	}
-	return int(major), int(minor), nil
+	return valid, newDiagnostics(all), nil
 }
 
-// SchemaCount returns the number of schema definitions.
-func (d *Document) SchemaCount() (int, error) {
-	if d.ptr == nil {
-		return 0, errors.New("document closed")
-	}
-	count := C.hedl_schema_count(d.ptr)
-	if count < 0 {
-		return 0, newError(count)
+// parseErrorDiagnostic reports a parse failure as an error diagnostic with
+// rule ID "parse", positioned at the error when err is a *ParseError.
+func parseErrorDiagnostic(err error, message string) *Diagnostic {
+	diag := &Diagnostic{Message: message, Severity: SeverityError, RuleID: "parse"}
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		diag.Line, diag.Column = parseErr.Line, parseErr.Column
+		diag.EndLine, diag.EndColumn = parseErr.Line, parseErr.Column
 	}
-	return int(count), nil
+	return diag
 }
 
-// AliasCount returns the number of alias definitions.
-func (d *Document) AliasCount() (int, error) {
-	if d.ptr == nil {
-		return 0, errors.New("document closed")
-	}
-	count := C.hedl_alias_count(d.ptr)
-	if count < 0 {
-		return 0, newError(count)
-	}
-	return int(count), nil
+// diagnosticLine extracts the source line from a lint message ("line 3: ...")
+// or parse error ("... at line 3: ..."). It returns 0 when there is none.
+func diagnosticLine(msg string) int {
+	return numberAfter(msg, "line ")
 }
 
-// RootItemCount returns the number of root items.
-func (d *Document) RootItemCount() (int, error) {
-	if d.ptr == nil {
-		return 0, errors.New("document closed")
+// numberAfter returns the number that follows the first occurrence of label
+// in msg, or 0 if there is none.
+func numberAfter(msg, label string) int {
+	idx := strings.Index(msg, label)
+	if idx < 0 {
+		return 0
 	}
-	count := C.hedl_root_item_count(d.ptr)
-	if count < 0 {
-		return 0, newError(count)
+	digits := msg[idx+len(label):]
+	end := 0
+	for end < len(digits) && digits[end] >= '0' && digits[end] <= '9' {
+		end++
 	}
-	return int(count), nil
+	n, err := strconv.Atoi(digits[:end])
+	if err != nil {
+		return 0
+	}
+	return n
 }
 
-// Canonicalize converts the document to canonical HEDL form.
-func (d *Document) Canonicalize() (string, error) {
+// FromJSON parses JSON content into a HEDL Document.
+func FromJSON(content string) (*Document, error) {
+	cLen, err := inputLength(len(content))
+	if err != nil {
+		return nil, err
+	}
+
+	t := startOp("FromJSON")
+	cContent := C.CString(content)
+	defer C.free(unsafe.Pointer(cContent))
+	t.copiedIn()
+
+	var docPtr *C.HedlDocument
+	result := C.hedl_from_json(cContent, cLen, &docPtr)
+	t.called()
+	doc, err := wrapDocument(result, docPtr)
+	t.finish(doc)
+	return doc, err
+}
+
+// FromYAML parses YAML content into a HEDL Document.
+func FromYAML(content string) (*Document, error) {
+	cLen, err := inputLength(len(content))
+	if err != nil {
+		return nil, err
+	}
+
+	t := startOp("FromYAML")
+	cContent := C.CString(content)
+	defer C.free(unsafe.Pointer(cContent))
+	t.copiedIn()
+
+	var docPtr *C.HedlDocument
+	result := C.hedl_from_yaml(cContent, cLen, &docPtr)
+	t.called()
+	doc, err := wrapDocument(result, docPtr)
+	t.finish(doc)
+	return doc, err
+}
+
+// FromTOML parses TOML content into a HEDL Document.
+//
+// Tables become nested objects and arrays of tables become matrix lists,
+// typed as FromJSON types arrays of objects: the key is singularized and
+// capitalized for the struct name, so [[users]] becomes "users: @User".
+// Dates and times are kept as strings. Syntax errors, and infinite or NaN
+// floats, which have no JSON form, return an ErrTOML error.
+func FromTOML(content string) (*Document, error) {
+	cLen, err := inputLength(len(content))
+	if err != nil {
+		return nil, err
+	}
+
+	t := startOp("FromTOML")
+	cContent := C.CString(content)
+	defer C.free(unsafe.Pointer(cContent))
+	t.copiedIn()
+
+	var docPtr *C.HedlDocument
+	result := C.hedl_from_toml(cContent, cLen, &docPtr)
+	t.called()
+	doc, err := wrapDocument(result, docPtr)
+	t.finish(doc)
+	return doc, err
+}
+
+// FromXML parses XML content into a HEDL Document.
+func FromXML(content string) (*Document, error) {
+	cLen, err := inputLength(len(content))
+	if err != nil {
+		return nil, err
+	}
+
+	t := startOp("FromXML")
+	cContent := C.CString(content)
+	defer C.free(unsafe.Pointer(cContent))
+	t.copiedIn()
+
+	var docPtr *C.HedlDocument
+	result := C.hedl_from_xml(cContent, cLen, &docPtr)
+	t.called()
+	doc, err := wrapDocument(result, docPtr)
+	t.finish(doc)
+	return doc, err
+}
+
+// FromCSV parses CSV with a header row into a HEDL Document holding a single
+// Row struct and a "rows" list. The first column holds the row IDs and is
+// named id; the other header names become the struct's fields, lowercased
+// and with characters not valid in a key replaced by underscores. Quoted
+// fields may contain commas and newlines. A row with a different number of
+// fields than the header returns an ErrCSV error.
+//
+// Use FromCSVWithOptions for other type names, delimiters or number
+// formats.
+func FromCSV(content string) (*Document, error) {
+	cLen, err := inputLength(len(content))
+	if err != nil {
+		return nil, err
+	}
+
+	t := startOp("FromCSV")
+	cContent := C.CString(content)
+	defer C.free(unsafe.Pointer(cContent))
+	t.copiedIn()
+
+	var docPtr *C.HedlDocument
+	result := C.hedl_from_csv(cContent, cLen, &docPtr)
+	t.called()
+	doc, err := wrapDocument(result, docPtr)
+	t.finish(doc)
+	return doc, err
+}
+
+// FromParquet parses Parquet content into a HEDL Document.
+func FromParquet(data []byte) (*Document, error) {
+	if len(data) == 0 {
+		return nil, errors.New("empty parquet data")
+	}
+
+	var docPtr *C.HedlDocument
+	result := C.hedl_from_parquet((*C.uint8_t)(unsafe.Pointer(&data[0])), C.size_t(len(data)), &docPtr)
+	return wrapDocument(result, docPtr)
+}
+
+// Close frees the document resources.
+//
+// Close is safe to call more than once and on a nil Document.
+func (d *Document) Close() {
+	if d != nil && d.ptr != nil {
+		C.hedl_free_document(d.ptr)
+		d.ptr = nil
+		atomic.AddInt64(&openDocuments, -1)
+	}
+}
+
+// swap moves the native document owned by other into d, freeing the document
+// d held before. other is left closed.
+func (d *Document) swap(other *Document) {
+	old := d.ptr
+	d.ptr = other.ptr
+	d.schemaOrder = other.schemaOrder
+	other.ptr = nil
+	runtime.SetFinalizer(other, nil)
+	if old != nil {
+		C.hedl_free_document(old)
+		atomic.AddInt64(&openDocuments, -1)
+	}
+}
+
+// Version returns the HEDL version as (major, minor).
+func (d *Document) Version() (int, int, error) {
+	if d.ptr == nil {
+		return 0, 0, errors.New("document closed")
+	}
+
+	var major, minor C.int
+	result := C.hedl_get_version(d.ptr, &major, &minor)
+	if result != 0 {
+		return 0, 0, newError(result)
+	}
+	return int(major), int(minor), nil
+}
+
+// Version is a HEDL format version.
+type Version struct {
+	Major int
+	Minor int
+}
+
+// Compare returns -1, 0 or 1 as v is older than, equal to or newer than
+// other.
+func (v Version) Compare(other Version) int {
+	switch {
+	case v.Major != other.Major:
+		if v.Major < other.Major {
+			return -1
+		}
+		return 1
+	case v.Minor < other.Minor:
+		return -1
+	case v.Minor > other.Minor:
+		return 1
+	}
+	return 0
+}
+
+// String formats the version as "major.minor".
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// SemVer returns the document's HEDL version as a comparable Version.
+func (d *Document) SemVer() (Version, error) {
+	major, minor, err := d.Version()
+	if err != nil {
+		return Version{}, err
+	}
+	return Version{Major: major, Minor: minor}, nil
+}
+
+// SchemaCount returns the number of schema definitions.
+func (d *Document) SchemaCount() (int, error) {
+	if d.ptr == nil {
+		return 0, errors.New("document closed")
+	}
+	count := C.hedl_schema_count(d.ptr)
+	if count < 0 {
+		return 0, newError(count)
+	}
+	return int(count), nil
+}
+
+// SchemaNames returns the names of the %STRUCT definitions in declaration
+// order. Documents that were not parsed from HEDL text, such as imports,
+// have no declaration order, and their schemas are sorted by name, as
+// canonical output declares them. A document without schemas returns an
+// empty slice.
+func (d *Document) SchemaNames() ([]string, error) {
+	if d.ptr == nil {
+		return nil, errors.New("document closed")
+	}
+
+	var outStr *C.char
+	result := C.hedl_schema_names(d.ptr, &outStr)
+	if result != 0 {
+		return nil, newError(result)
+	}
+	defer C.hedl_free_string(outStr)
+
+	text := C.GoString(outStr)
+	if text == "" {
+		return []string{}, nil
+	}
+	names := strings.Split(text, "\n")
+	rank := schemaRanks(d.schemaOrder)
+	sort.SliceStable(names, func(i, j int) bool {
+		return rank(names[i]) < rank(names[j])
+	})
+	return names, nil
+}
+
+// SchemaChecksum returns a hex SHA-256 digest of schemaName's definition:
+// its %STRUCT declaration as written in canonical HEDL, without the row count
+// the canonical form adds. Only the name and the ordered field names are
+// hashed, so the checksum ignores row values entirely, and comparing
+// checksums across versions of a document flags schema drift. An unknown
+// schema returns ErrNotFound.
+func (d *Document) SchemaChecksum(schemaName string) (string, error) {
+	if d.ptr == nil {
+		return "", errors.New("document closed")
+	}
+
+	cSchema := C.CString(schemaName)
+	defer C.free(unsafe.Pointer(cSchema))
+
+	var outStr *C.char
+	result := C.hedl_schema_checksum(d.ptr, cSchema, &outStr)
+	if result != 0 {
+		return "", newError(result)
+	}
+	defer C.hedl_free_string(outStr)
+
+	return C.GoString(outStr), nil
+}
+
+// AliasCount returns the number of alias definitions.
+func (d *Document) AliasCount() (int, error) {
+	if d.ptr == nil {
+		return 0, errors.New("document closed")
+	}
+	count := C.hedl_alias_count(d.ptr)
+	if count < 0 {
+		return 0, newError(count)
+	}
+	return int(count), nil
+}
+
+// Aliases returns the %ALIAS definitions, mapping each alias name to its
+// expansion. A document without aliases returns an empty map.
+func (d *Document) Aliases() (map[string]string, error) {
+	if d.ptr == nil {
+		return nil, errors.New("document closed")
+	}
+
+	var outStr *C.char
+	result := C.hedl_aliases(d.ptr, &outStr)
+	if result != 0 {
+		return nil, newError(result)
+	}
+	defer C.hedl_free_string(outStr)
+
+	aliases := make(map[string]string)
+	lines := C.GoString(outStr)
+	if lines == "" {
+		return aliases, nil
+	}
+	for _, line := range strings.Split(lines, "\n") {
+		name, value, ok := strings.Cut(line, "\t")
+		if !ok {
+			return nil, fmt.Errorf("malformed alias entry %q", line)
+		}
+		aliases[name] = unescapeAlias(value)
+	}
+	return aliases, nil
+}
+
+// unescapeAlias reverses the escaping of backslashes, tabs and newlines in
+// alias expansions returned by hedl_aliases, and in the partition keys of
+// hedl_partition_keys, which are escaped the same way.
+func unescapeAlias(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// RootItemCount returns the number of root items.
+func (d *Document) RootItemCount() (int, error) {
+	if d.ptr == nil {
+		return 0, errors.New("document closed")
+	}
+	count := C.hedl_root_item_count(d.ptr)
+	if count < 0 {
+		return 0, newError(count)
+	}
+	return int(count), nil
+}
+
+
+// RowSourceLine returns the 1-based line of the source text on which row
+// index of schemaName was defined, for documents parsed with
+// ParseOptions.TrackProvenance. Rows are indexed as in RowsWithMissing,
+// across every list of the schema including nested ones.
+//
+// Rows are matched to the source by ID, so lines stay correct after
+// in-place edits that keep IDs, but always refer to the original text.
+// A document parsed without provenance, or a row with no recorded line,
+// returns ErrNotFound, as does an unknown schema; an index out of range
+// returns ErrInvalidArgument.
+func (d *Document) RowSourceLine(schemaName string, index int) (int, error) {
+	if d.ptr == nil {
+		return 0, errors.New("document closed")
+	}
+
+	cSchema := C.CString(schemaName)
+	defer C.free(unsafe.Pointer(cSchema))
+
+	var line C.int
+	result := C.hedl_row_source_line(d.ptr, cSchema, C.int(clamp(index, -1, math.MaxInt32)), &line)
+	if result != 0 {
+		return 0, newError(result)
+	}
+	return int(line), nil
+}
+
+// documentBytes returns the native library's estimate of the memory held by
+// the document.
+func (d *Document) documentBytes() (int64, error) {
+	if d.ptr == nil {
+		return 0, errors.New("document closed")
+	}
+
+	var size C.longlong
+	result := C.hedl_document_memory(d.ptr, &size)
+	if result != 0 {
+		return 0, newError(result)
+	}
+	return int64(size), nil
+}
+
+// PartitionBy splits the document by the value of field in the rows of
+// schemaName, returning one document per distinct value. Each document is a
+// copy of the original that keeps only the rows of schemaName with that
+// value, in every list including nested ones; other content is copied
+// unchanged. Values are keyed by their HEDL text with strings always
+// quoted, so null is ~, the string "~" is "\"~\"" and the empty string is
+// "\"\"".
+//
+// The documents are independent and must each be closed. An unknown schema
+// or field returns ErrNotFound.
+func (d *Document) PartitionBy(schemaName, field string) (map[string]*Document, error) {
+	if d.ptr == nil {
+		return nil, errors.New("document closed")
+	}
+
+	cSchema := C.CString(schemaName)
+	defer C.free(unsafe.Pointer(cSchema))
+	cField := C.CString(field)
+	defer C.free(unsafe.Pointer(cField))
+
+	var outStr *C.char
+	result := C.hedl_partition_keys(d.ptr, cSchema, cField, &outStr)
+	if result != 0 {
+		return nil, newError(result)
+	}
+	keys := C.GoString(outStr)
+	C.hedl_free_string(outStr)
+
+	partitions := make(map[string]*Document)
+	if keys == "" {
+		return partitions, nil
+	}
+	for _, line := range strings.Split(keys, "\n") {
+		key := unescapeAlias(line)
+		cKey := C.CString(key)
+		var docPtr *C.HedlDocument
+		result := C.hedl_partition(d.ptr, cSchema, cField, cKey, &docPtr)
+		C.free(unsafe.Pointer(cKey))
+		doc, err := wrapDocument(result, docPtr)
+		if err != nil {
+			for _, partition := range partitions {
+				partition.Close()
+			}
+			return nil, err
+		}
+		partitions[key] = doc
+	}
+	return partitions, nil
+}
+
+// csvFlushRows is how many rows WriteCSVStream reads from the native cursor
+// per chunk.
+const csvFlushRows = 1000
+
+// WriteCSVStream writes the rows of schemaName to w as CSV, a header row of
+// the schema's fields followed by one record per row in document order, and
+// returns the number of bytes written. Strings are written as-is, null as an
+// empty field and other values in HEDL syntax.
+//
+// Rows are read through a native cursor csvFlushRows at a time and each
+// chunk is written to w before the next is read, so the export is never
+// held in memory as a whole. The HEDL_MAX_OUTPUT_SIZE limit applies to the
+// total written: once the next chunk would exceed it, writing stops with an
+// ErrAlloc error, leaving the output truncated. An unknown schema returns
+// ErrNotFound.
+func (d *Document) WriteCSVStream(w io.Writer, schemaName string) (int64, error) {
+	if d.ptr == nil {
+		return 0, errors.New("document closed")
+	}
+
+	cSchema := C.CString(schemaName)
+	defer C.free(unsafe.Pointer(cSchema))
+
+	var cursor *C.HedlCsvCursor
+	result := C.hedl_csv_cursor_open(d.ptr, cSchema, &cursor)
+	if result != 0 {
+		return 0, newError(result)
+	}
+	defer C.hedl_free_csv_cursor(cursor)
+	defer runtime.KeepAlive(d)
+
+	out := &limitedWriter{w: w, limit: maxOutputSize}
+	for {
+		var outStr *C.char
+		rows := C.hedl_csv_cursor_next(cursor, csvFlushRows, &outStr)
+		if rows < 0 {
+			return out.n, newError(rows)
+		}
+		_, err := io.WriteString(out, C.GoString(outStr))
+		C.hedl_free_string(outStr)
+		if err != nil || rows == 0 {
+			return out.n, err
+		}
+	}
+}
+
+// Dedup removes consecutive rows of schemaName that are equal on fields and
+// returns the number of rows removed. When fields is nil every column except
+// the ID column is compared, since IDs are unique per row.
+//
+// Each list of the schema, including nested ones, is deduplicated on its own
+// and the document is modified in place; references to removed rows are left
+// as they are. An unknown schema or field returns ErrNotFound.
+func (d *Document) Dedup(schemaName string, fields []string) (int, error) {
+	if d.ptr == nil {
+		return 0, errors.New("document closed")
+	}
+
+	cSchema := C.CString(schemaName)
+	defer C.free(unsafe.Pointer(cSchema))
+
+	fieldCount := -1
+	var cFields **C.char
+	if fields != nil {
+		fieldCount = len(fields)
+		ptrs := make([]*C.char, len(fields)+1)
+		for i, field := range fields {
+			ptrs[i] = C.CString(field)
+			defer C.free(unsafe.Pointer(ptrs[i]))
+		}
+		cFields = &ptrs[0]
+	}
+
+	var removed C.int
+	result := C.hedl_dedup(d.ptr, cSchema, cFields, C.int(fieldCount), &removed)
+	if result != 0 {
+		return 0, newError(result)
+	}
+	return int(removed), nil
+}
+
+// NormalizeDates returns a copy of the document with the date values of
+// fields rewritten in targetFormat, a time layout such as time.RFC3339 or
+// "2006-01-02". fields name "Schema.field" columns as in CheckEnums.
+//
+// String values are read as RFC 3339 timestamps, YYYY-MM-DD and
+// month-first MM/DD/YYYY dates with an optional HH:MM[:SS] time,
+// YYYY/MM/DD, "2 Jan 2006", "Jan 2, 2006" and "January 2, 2006" dates, or
+// RFC 1123 timestamps with a numeric zone; dates without a zone are taken
+// as UTC. Values that are not a recognized date are left as they are and
+// reported as warnings with rule "date", naming the row index counted
+// across the schema's lists in document order. Null values are skipped.
+//
+// A field not of the form "Schema.field", an empty targetFormat or one
+// using zone names or seconds in zone offsets returns an
+// ErrInvalidArgument error, and an unknown schema or field returns
+// ErrNotFound.
+func (d *Document) NormalizeDates(fields []string, targetFormat string) (*Document, *Diagnostics, error) {
+	if d.ptr == nil {
+		return nil, nil, errors.New("document closed")
+	}
+	format, err := strftimeFormat(targetFormat)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keys := append([]string(nil), fields...)
+	sort.Strings(keys)
+
+	cFormat := C.CString(format)
+	defer C.free(unsafe.Pointer(cFormat))
+	ptrs := make([]*C.char, len(keys)+1)
+	for i, key := range keys {
+		ptrs[i] = C.CString(key)
+		defer C.free(unsafe.Pointer(ptrs[i]))
+	}
+
+	var docPtr *C.HedlDocument
+	var diagPtr *C.HedlDiagnostics
+	result := C.hedl_normalize_dates(d.ptr, &ptrs[0], C.int(len(keys)), cFormat, &docPtr, &diagPtr)
+	if result != 0 && diagPtr != nil {
+		C.hedl_free_diagnostics(diagPtr)
+	}
+	normalized, err := wrapDocument(result, docPtr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	diag := &Diagnostics{ptr: diagPtr}
+	runtime.SetFinalizer(diag, (*Diagnostics).Close)
+	return normalized, diag, nil
+}
+
+// Tail returns a new document keeping only the last n top-level rows of each
+// schema, counted across all lists of that schema in document order. Nested
+// child rows stay with their parents, and n larger than the number of rows
+// keeps them all. A negative n returns ErrInvalidArgument. The receiver is
+// left unchanged.
+func (d *Document) Tail(n int) (*Document, error) {
+	if d.ptr == nil {
+		return nil, errors.New("document closed")
+	}
+
+	var docPtr *C.HedlDocument
+	result := C.hedl_tail(d.ptr, C.int(clamp(n, -1, math.MaxInt32)), &docPtr)
+	doc, err := wrapDocument(result, docPtr)
+	if err != nil {
+		return nil, err
+	}
+	doc.schemaOrder = d.schemaOrder
+	return doc, nil
+}
+
+// Head returns a new document keeping only the first n top-level rows of
+// each schema, the counterpart of Tail.
+func (d *Document) Head(n int) (*Document, error) {
+	if d.ptr == nil {
+		return nil, errors.New("document closed")
+	}
+
+	var docPtr *C.HedlDocument
+	result := C.hedl_head(d.ptr, C.int(clamp(n, -1, math.MaxInt32)), &docPtr)
+	doc, err := wrapDocument(result, docPtr)
+	if err != nil {
+		return nil, err
+	}
+	doc.schemaOrder = d.schemaOrder
+	return doc, nil
+}
+
+
+// TokenCount estimates the number of tokens text takes up in the context of
+// model, using the heuristic of "hedl stats --tokens": about four bytes
+// per token of content and three whitespace characters per token. It is
+// meant for budgeting, not billing. The models accepted are
+// cl100k_base, gpt-4, gpt-4-turbo and gpt-3.5-turbo, which share an
+// encoding; any other model returns ErrInvalidArgument.
+func TokenCount(model, text string) (int, error) {
+	cLen, err := inputLength(len(text))
+	if err != nil {
+		return 0, err
+	}
+
+	cModel := C.CString(model)
+	defer C.free(unsafe.Pointer(cModel))
+	cText := C.CString(text)
+	defer C.free(unsafe.Pointer(cText))
+
+	var count C.int
+	result := C.hedl_token_count(cModel, cText, cLen, &count)
+	if result != 0 {
+		return 0, newError(result)
+	}
+	return int(count), nil
+}
+
+// FitToTokens returns the document as canonical HEDL that fits in maxTokens
+// tokens of model, as counted by TokenCount, for use in an LLM context with
+// a fixed budget.
+//
+// When the whole document fits it is returned unchanged with no
+// diagnostics. Otherwise trailing top-level rows are dropped, together with
+// any rows nested under them, keeping the first rows of each schema as
+// Head does and as many of them as fit; key-value pairs and schemas are
+// always kept. Each truncated list is reported as a warning with rule
+// "truncated". If the document does not fit even with every list emptied,
+// ErrInvalidArgument is returned.
+func (d *Document) FitToTokens(model string, maxTokens int) (string, *Diagnostics, error) {
+	if d.ptr == nil {
+		return "", nil, errors.New("document closed")
+	}
+
+	cModel := C.CString(model)
+	defer C.free(unsafe.Pointer(cModel))
+
+	var outStr *C.char
+	var diagPtr *C.HedlDiagnostics
+	result := C.hedl_fit_to_tokens(d.ptr, cModel, C.int(clamp(maxTokens, 0, math.MaxInt32)), &outStr, &diagPtr)
+	if result != 0 {
+		return "", nil, newError(result)
+	}
+	defer C.hedl_free_string(outStr)
+
+	diag := &Diagnostics{ptr: diagPtr}
+	runtime.SetFinalizer(diag, (*Diagnostics).Close)
+	return C.GoString(outStr), diag, nil
+}
+
+// RenameField renames a field of schemaName in its definition and every list
+// of that type. It returns ErrNotFound if the schema or field does not exist
+// and ErrConflict if the schema already has a field named newName.
+func (d *Document) RenameField(schemaName, oldName, newName string) error {
+	if d.ptr == nil {
+		return errors.New("document closed")
+	}
+
+	cSchema := C.CString(schemaName)
+	defer C.free(unsafe.Pointer(cSchema))
+	cOld := C.CString(oldName)
+	defer C.free(unsafe.Pointer(cOld))
+	cNew := C.CString(newName)
+	defer C.free(unsafe.Pointer(cNew))
+
+	result := C.hedl_rename_field(d.ptr, cSchema, cOld, cNew)
+	if result != 0 {
+		return newError(result)
+	}
+	return nil
+}
+
+// RenameSchema renames a schema in its declaration, its lists, %NEST
+// declarations and qualified references (@Old:id becomes @New:id). It
+// returns ErrNotFound if the schema does not exist and ErrConflict if a
+// schema named newName already exists.
+func (d *Document) RenameSchema(oldName, newName string) error {
+	if d.ptr == nil {
+		return errors.New("document closed")
+	}
+
+	cOld := C.CString(oldName)
+	defer C.free(unsafe.Pointer(cOld))
+	cNew := C.CString(newName)
+	defer C.free(unsafe.Pointer(cNew))
+
+	result := C.hedl_rename_schema(d.ptr, cOld, cNew)
+	if result != 0 {
+		return newError(result)
+	}
+
+	order := make([]string, len(d.schemaOrder))
+	for i, name := range d.schemaOrder {
+		if name == oldName {
+			name = newName
+		}
+		order[i] = name
+	}
+	d.schemaOrder = order
+	return nil
+}
+
+// NormalizeReferences rewrites the references of the document in place to
+// one style:
+//
+//   - "inline" replaces every key-value reference that resolves to a row with
+//     a copy of that row: an object holding the row's fields under its
+//     column names, plus a "__type__" key naming its type as in JSON exported
+//     with metadata. Nested child rows are not copied. Matrix cells can only
+//     hold scalars, so a document with a resolvable reference in a matrix
+//     cell returns an ErrInvalidArgument error and is left unchanged.
+//   - "id" turns rows embedded that way, objects whose "__type__" and ID
+//     field name an existing row, back into references, and writes every
+//     reference in the qualified @Type:id form, so each names its target
+//     without relying on context.
+//
+// References that do not resolve to a row are left as written, so a
+// document whose references are qualified is restored exactly by converting
+// it to "inline" and back to "id". An unknown mode returns an
+// ErrInvalidArgument error.
+func (d *Document) NormalizeReferences(mode string) error {
+	if d.ptr == nil {
+		return errors.New("document closed")
+	}
+
+	cMode := C.CString(mode)
+	defer C.free(unsafe.Pointer(cMode))
+
+	result := C.hedl_normalize_references(d.ptr, cMode)
+	if result != 0 {
+		return newError(result)
+	}
+	return nil
+}
+
+// Join returns a new document in which every schemaName row of d gains the
+// addFields columns of the other document's schemaName row with the same
+// keyField value, or null when there is none: a left join. Keys match only
+// when their values have the same type and text, so the string "1" never
+// matches the integer 1; a null key matches nothing, so left rows without a
+// key get nulls and right rows without one are ignored. d and other are not
+// modified.
+//
+// Rows of other are read by the schema of their own list. It returns
+// ErrNotFound when either document lacks the schema, keyField or one of
+// addFields, ErrConflict when one of addFields already exists in d's schema
+// or a key repeats among other's rows, and ErrInvalidArgument when a
+// schemaName list of d has an inline schema that differs from the
+// declaration.
+func (d *Document) Join(other *Document, schemaName, keyField string, addFields []string) (*Document, error) {
+	if d.ptr == nil || other.ptr == nil {
+		return nil, errors.New("document closed")
+	}
+
+	cSchema := C.CString(schemaName)
+	defer C.free(unsafe.Pointer(cSchema))
+	cKey := C.CString(keyField)
+	defer C.free(unsafe.Pointer(cKey))
+	ptrs := make([]*C.char, len(addFields)+1)
+	for i, field := range addFields {
+		ptrs[i] = C.CString(field)
+		defer C.free(unsafe.Pointer(ptrs[i]))
+	}
+
+	var docPtr *C.HedlDocument
+	result := C.hedl_join(d.ptr, other.ptr, cSchema, cKey, &ptrs[0], C.int(len(addFields)), &docPtr)
+	doc, err := wrapDocument(result, docPtr)
+	if err != nil {
+		return nil, err
+	}
+	doc.schemaOrder = d.schemaOrder
+	return doc, nil
+}
+
+// strftimeLayouts maps the elements of Go time layouts to the strftime
+// directives hedl_normalize_dates writes, longest first where one is a
+// prefix of another. An empty directive marks an element it cannot write.
+var strftimeLayouts = []struct{ layout, directive string }{
+	{"January", "%B"}, {"Jan", "%b"}, {"Monday", "%A"}, {"Mon", "%a"}, {"MST", ""},
+	{"2006", "%Y"}, {"002", "%j"}, {"01", "%m"}, {"02", "%d"}, {"03", "%I"},
+	{"04", "%M"}, {"05", "%S"}, {"06", "%y"}, {"_2", "%e"}, {"15", "%H"},
+	{"1", "%-m"}, {"2", "%-d"}, {"3", "%-I"}, {"4", "%-M"}, {"5", "%-S"},
+	{"PM", "%p"}, {"pm", "%P"},
+	{"Z07:00:00", ""}, {"Z070000", ""}, {"Z07:00", "%#z"}, {"Z0700", ""}, {"Z07", ""},
+	{"-07:00:00", ""}, {"-070000", ""}, {"-07:00", "%:z"}, {"-0700", "%z"}, {"-07", "%:::z"},
+}
+
+// strftimeFormat translates a Go time layout into a strftime format for
+// hedl_normalize_dates. Fractional seconds are written as .000, .000000 or
+// .000000000, or as .9 repeated to trim trailing zeros.
+func strftimeFormat(layout string) (string, error) {
+	if layout == "" {
+		return "", &HedlError{Message: "targetFormat must not be empty", Code: ErrInvalidArgument}
+	}
+	unsupported := func(element string) error {
+		return &HedlError{
+			Message: fmt.Sprintf("targetFormat element %q is not supported", element),
+			Code:    ErrInvalidArgument,
+		}
+	}
+
+	var b strings.Builder
+next:
+	for i := 0; i < len(layout); {
+		rest := layout[i:]
+		if rest[0] == '.' || rest[0] == ',' {
+			digits := len(rest[1:]) - len(strings.TrimLeft(rest[1:], "09"))
+			if digits > 0 && (len(rest) == digits+1 || !isDigit(rest[digits+1])) {
+				fraction := rest[:digits+1]
+				switch {
+				case rest[0] == '.' && strings.Trim(fraction[1:], "9") == "":
+					b.WriteString("%.f")
+				case rest[0] == '.' && strings.Trim(fraction[1:], "0") == "" && digits%3 == 0 && digits <= 9:
+					fmt.Fprintf(&b, "%%.%df", digits)
+				default:
+					return "", unsupported(fraction)
+				}
+				i += len(fraction)
+				continue
+			}
+		}
+		for _, e := range strftimeLayouts {
+			if strings.HasPrefix(rest, e.layout) {
+				if e.directive == "" {
+					return "", unsupported(e.layout)
+				}
+				b.WriteString(e.directive)
+				i += len(e.layout)
+				continue next
+			}
+		}
+		if rest[0] == '%' {
+			b.WriteString("%%")
+		} else {
+			b.WriteByte(rest[0])
+		}
+		i++
+	}
+	return b.String(), nil
+}
+
+// isDigit reports whether c is an ASCII digit.
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// ValueLocation identifies a scalar value and its size, as returned by
+// LargestValues.
+type ValueLocation struct {
+	// Schema is the schema of the row holding the value, or "" for a
+	// key-value pair outside matrix lists.
+	Schema string
+	// Field is the schema field, or the dot-separated key path of a
+	// key-value pair.
+	Field string
+	// RowIndex is the index of the row among all rows of Schema, as in
+	// RowsWithMissing, or -1 for a key-value pair.
+	RowIndex int
+	// Bytes is the length of a string in UTF-8 bytes, or of the HEDL text
+	// of any other value.
+	Bytes int
+}
+
+// LargestValues returns the n largest scalar values of the document, largest
+// first, to help find the fields that make a document bloated. Values of
+// equal size are returned in a stable order. n must be positive.
+func (d *Document) LargestValues(n int) ([]ValueLocation, error) {
+	if d.ptr == nil {
+		return nil, errors.New("document closed")
+	}
+	if n <= 0 {
+		return nil, &HedlError{Message: "n must be positive", Code: ErrInvalidArgument}
+	}
+
+	var outStr *C.char
+	result := C.hedl_largest_values(d.ptr, C.int(n), &outStr)
+	if result != 0 {
+		return nil, newError(result)
+	}
+	defer C.hedl_free_string(outStr)
+
+	values := []ValueLocation{}
+	lines := C.GoString(outStr)
+	if lines == "" {
+		return values, nil
+	}
+	for _, line := range strings.Split(lines, "\n") {
+		parts := strings.Split(line, "\t")
+		if len(parts) != 4 {
+			return nil, fmt.Errorf("malformed value entry %q", line)
+		}
+		row, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("malformed value entry %q", line)
+		}
+		size, err := strconv.Atoi(parts[3])
+		if err != nil {
+			return nil, fmt.Errorf("malformed value entry %q", line)
+		}
+		values = append(values, ValueLocation{Schema: parts[0], Field: parts[1], RowIndex: row, Bytes: size})
+	}
+	return values, nil
+}
+
+// Canonicalize converts the document to canonical HEDL form.
+func (d *Document) Canonicalize() (string, error) {
+	if d.ptr == nil {
+		return "", errors.New("document closed")
+	}
+
+	t := startOp("Canonicalize")
+	defer t.finish(d)
+
+	var outStr *C.char
+	result := C.hedl_canonicalize(d.ptr, &outStr)
+	t.called()
+	if result != 0 {
+		return "", newError(result)
+	}
+	defer C.hedl_free_string(outStr)
+	output := C.GoString(outStr)
+	t.copiedOut()
+	if err := checkStringOutputSize(output); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// CanonicalizePath returns the canonical HEDL of the subtree at path, a
+// dot-separated key path such as "metadata" or "config.database". The result
+// is a standalone document holding only that node under its own key, with
+// the header reduced to the schemas its lists use. A missing path returns an
+// ErrNotFound error.
+func (d *Document) CanonicalizePath(path string) (string, error) {
+	if d.ptr == nil {
+		return "", errors.New("document closed")
+	}
+
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+
+	t := startOp("CanonicalizePath")
+	defer t.finish(d)
+
+	var outStr *C.char
+	result := C.hedl_canonicalize_path(d.ptr, cPath, &outStr)
+	t.called()
+	if result != 0 {
+		return "", newError(result)
+	}
+	defer C.hedl_free_string(outStr)
+	output := C.GoString(outStr)
+	t.copiedOut()
+	if err := checkStringOutputSize(output); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// ToGitFriendly returns the document as HEDL laid out for minimal diffs in
+// version control: object keys are sorted at every level, date-like strings
+// are normalized as with CanonOptions.NormalizeScalars, every key-value pair
+// and row sits on its own line, and cells are written in full rather than
+// with the ditto marker (^), so editing one row never changes the next. The
+// output parses to the same document.
+func (d *Document) ToGitFriendly() (string, error) {
+	if d.ptr == nil {
+		return "", errors.New("document closed")
+	}
+
+	t := startOp("ToGitFriendly")
+	defer t.finish(d)
+
+	var outStr *C.char
+	result := C.hedl_to_git_friendly(d.ptr, &outStr)
+	t.called()
+	if result != 0 {
+		return "", newError(result)
+	}
+	defer C.hedl_free_string(outStr)
+	output := C.GoString(outStr)
+	t.copiedOut()
+	if err := checkStringOutputSize(output); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+
+// normalizeScalars returns a copy of the document with its date-like strings
+// normalized by hedl_normalize_scalars, for CanonOptions.NormalizeScalars.
+func (d *Document) normalizeScalars() (*Document, error) {
+	if d.ptr == nil {
+		return nil, errors.New("document closed")
+	}
+
+	var docPtr *C.HedlDocument
+	result := C.hedl_normalize_scalars(d.ptr, &docPtr)
+	doc, err := wrapDocument(result, docPtr)
+	if err != nil {
+		return nil, err
+	}
+	doc.schemaOrder = d.schemaOrder
+	return doc, nil
+}
+
+// ToJSON converts the document to JSON.
+func (d *Document) ToJSON(includeMetadata bool) (string, error) {
+	return d.toJSON(includeMetadata, maxOutputSize)
+}
+
+// ToJSONLimited is like ToJSON but checks the output against maxBytes
+// instead of the HEDL_MAX_OUTPUT_SIZE default, so a single large export can
+// be allowed without raising the limit for the whole process. A maxBytes of
+// zero or less uses the default.
+func (d *Document) ToJSONLimited(includeMetadata bool, maxBytes int64) (string, error) {
+	if maxBytes <= 0 {
+		maxBytes = maxOutputSize
+	}
+	return d.toJSON(includeMetadata, maxBytes)
+}
+
+// ToJSONContext is like ToJSON but honors an output size limit set on ctx with
+// WithMaxOutputSize.
+func (d *Document) ToJSONContext(ctx context.Context, includeMetadata bool) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return d.toJSON(includeMetadata, outputLimit(ctx))
+}
+
+func (d *Document) toJSON(includeMetadata bool, limit int64) (string, error) {
+	if d.ptr == nil {
+		return "", errors.New("document closed")
+	}
+
+	metaInt := 0
+	if includeMetadata {
+		metaInt = 1
+	}
+
+	t := startOp("ToJSON")
+	defer t.finish(d)
+
+	var outStr *C.char
+	result := C.hedl_to_json(d.ptr, C.int(metaInt), &outStr)
+	t.called()
+	if result != 0 {
+		return "", newError(result)
+	}
+	defer C.hedl_free_string(outStr)
+	output := C.GoString(outStr)
+	t.copiedOut()
+	if err := checkOutputLimit(int64(len(output)), limit); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// ToGroupedJSON converts the rows of schemaName to a JSON object keyed by the
+// distinct values of field groupBy, each key holding the array of rows with
+// that value, as in {"<groupValue>": [rows...], ...}. Keys are sorted and rows
+// keep their document order and nested children. Rows are collected from
+// every list of the schema, including nested ones; a null value is grouped
+// under "~". An unknown schema or field returns ErrNotFound.
+func (d *Document) ToGroupedJSON(schemaName, groupBy string) (string, error) {
+	if d.ptr == nil {
+		return "", errors.New("document closed")
+	}
+
+	cSchema := C.CString(schemaName)
+	defer C.free(unsafe.Pointer(cSchema))
+	cGroupBy := C.CString(groupBy)
+	defer C.free(unsafe.Pointer(cGroupBy))
+
+	t := startOp("ToGroupedJSON")
+	defer t.finish(d)
+
+	var outStr *C.char
+	result := C.hedl_to_grouped_json(d.ptr, cSchema, cGroupBy, &outStr)
+	t.called()
+	if result != 0 {
+		return "", newError(result)
+	}
+	defer C.hedl_free_string(outStr)
+	output := C.GoString(outStr)
+	t.copiedOut()
+	if err := checkStringOutputSize(output); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// ToJSONPage returns rows [offset, offset+limit) of schemaName as a JSON
+// array, in the same row shape as ToJSON without metadata: one object per
+// row keyed by field, with nested children under their type name. Rows are
+// counted across all lists of the schema in document order, including
+// nested ones, and only the rows of the page are serialized. offset and
+// limit are clamped to the available rows, so a page past the end is "[]".
+// An unknown schema returns ErrNotFound.
+func (d *Document) ToJSONPage(schemaName string, offset, limit int) (string, error) {
+	if d.ptr == nil {
+		return "", errors.New("document closed")
+	}
+
+	cSchema := C.CString(schemaName)
+	defer C.free(unsafe.Pointer(cSchema))
+
+	t := startOp("ToJSONPage")
+	defer t.finish(d)
+
+	var outStr *C.char
+	result := C.hedl_to_json_page(d.ptr, cSchema,
+		C.int(clamp(offset, 0, math.MaxInt32)), C.int(clamp(limit, 0, math.MaxInt32)), &outStr)
+	t.called()
+	if result != 0 {
+		return "", newError(result)
+	}
+	defer C.hedl_free_string(outStr)
+	output := C.GoString(outStr)
+	t.copiedOut()
+	if err := checkStringOutputSize(output); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// LookupOptions configures GetByKeyWithOptions.
+type LookupOptions struct {
+	// FirstWins returns the first matching row in document order instead
+	// of failing with ErrConflict when several rows share the key.
+	FirstWins bool
+}
+
+// GetByKey returns the JSON of the schemaName row whose keyField value is
+// keyValue, in the row shape used by ToJSONPage. Values are compared by their
+// plain text as in IndexBy, and rows with a null key never match. No match,
+// or an unknown schema or field, returns an ErrNotFound error and several
+// matches return ErrConflict; use GetByKeyWithOptions to take the first.
+func (d *Document) GetByKey(schemaName, keyField, keyValue string) (string, error) {
+	return d.GetByKeyWithOptions(schemaName, keyField, keyValue, LookupOptions{})
+}
+
+// GetByKeyWithOptions is GetByKey with options.
+func (d *Document) GetByKeyWithOptions(schemaName, keyField, keyValue string, opts LookupOptions) (string, error) {
+	if d.ptr == nil {
+		return "", errors.New("document closed")
+	}
+
+	cSchema := C.CString(schemaName)
+	defer C.free(unsafe.Pointer(cSchema))
+	cField := C.CString(keyField)
+	defer C.free(unsafe.Pointer(cField))
+	cValue := C.CString(keyValue)
+	defer C.free(unsafe.Pointer(cValue))
+	firstWins := 0
+	if opts.FirstWins {
+		firstWins = 1
+	}
+
+	t := startOp("GetByKey")
+	defer t.finish(d)
+
+	var outStr *C.char
+	result := C.hedl_get_by_key(d.ptr, cSchema, cField, cValue, C.int(firstWins), &outStr)
+	t.called()
+	if result != 0 {
+		return "", newError(result)
+	}
+	defer C.hedl_free_string(outStr)
+	output := C.GoString(outStr)
+	t.copiedOut()
+	return output, nil
+}
+
+
+// ReKeyOptions configures IndexByWithOptions.
+type ReKeyOptions struct {
+	// AllowDuplicates keeps the last row for a repeated key instead of
+	// failing with ErrConflict.
+	AllowDuplicates bool
+}
+
+// IndexBy maps the value of keyField in every schemaName row to that row's
+// JSON, in the row shape used by ToJSONPage. Keys are the plain text of the
+// value, so the integer 7 and the string "7" share a key; rows with a null
+// key, and rows of lists whose inline schema lacks keyField, are skipped.
+// A repeated key returns an ErrConflict error; use
+// IndexByWithOptions to keep the last row instead.
+func (d *Document) IndexBy(schemaName, keyField string) (map[string]string, error) {
+	return d.IndexByWithOptions(schemaName, keyField, ReKeyOptions{})
+}
+
+// IndexByWithOptions is IndexBy with options.
+func (d *Document) IndexByWithOptions(schemaName, keyField string, opts ReKeyOptions) (map[string]string, error) {
+	if d.ptr == nil {
+		return nil, errors.New("document closed")
+	}
+
+	cSchema := C.CString(schemaName)
+	defer C.free(unsafe.Pointer(cSchema))
+	cField := C.CString(keyField)
+	defer C.free(unsafe.Pointer(cField))
+	allowDuplicates := 0
+	if opts.AllowDuplicates {
+		allowDuplicates = 1
+	}
+
+	t := startOp("IndexBy")
+	defer t.finish(d)
+
+	var outStr *C.char
+	result := C.hedl_index_by(d.ptr, cSchema, cField, C.int(allowDuplicates), &outStr)
+	t.called()
+	if result != 0 {
+		return nil, newError(result)
+	}
+	defer C.hedl_free_string(outStr)
+	output := C.GoString(outStr)
+	t.copiedOut()
+
+	var rows map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(output), &rows); err != nil {
+		return nil, err
+	}
+	index := make(map[string]string, len(rows))
+	for key, row := range rows {
+		index[key] = string(row)
+	}
+	return index, nil
+}
+
+// ExampleJSON returns a JSON object with one example row per schema, keyed by
+// schema name, for API documentation. Every declared field is present; each
+// takes its value from the first row of the schema where it is not null,
+// and fields without any value, as in schemas without rows, are the string
+// "example". Nested children are left out.
+func (d *Document) ExampleJSON() (string, error) {
+	if d.ptr == nil {
+		return "", errors.New("document closed")
+	}
+
+	var outStr *C.char
+	result := C.hedl_example_json(d.ptr, &outStr)
+	if result != 0 {
+		return "", newError(result)
+	}
+	defer C.hedl_free_string(outStr)
+	output := C.GoString(outStr)
+	if err := checkStringOutputSize(output); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// writeChunkSize is the chunk size WriteJSON asks the native library for.
+const writeChunkSize = 64 * 1024
+
+// WriteJSON converts the document to JSON like ToJSON, streaming the output
+// to w in chunks instead of returning it as a string, and returns the number
+// of bytes written. The JSON text is never held in memory as a whole, so
+// HEDL_MAX_OUTPUT_SIZE, which guards in-memory output, does not apply.
+//
+// An error from w stops the conversion and is returned as is, leaving the
+// output truncated.
+func (d *Document) WriteJSON(w io.Writer, includeMetadata bool) (int64, error) {
+	if d.ptr == nil {
+		return 0, errors.New("document closed")
+	}
+
+	metaInt := 0
+	if includeMetadata {
+		metaInt = 1
+	}
+
+	sink := &chunkSink{w: w}
+	handle := cgo.NewHandle(sink)
+	defer handle.Delete()
+
+	result := C.hedl_to_json_chunked(d.ptr, C.int(metaInt), C.size_t(writeChunkSize),
+		C.hedl_chunk_callback(C.hedlWriteChunk), unsafe.Pointer(&handle))
+	if sink.err != nil {
+		return sink.n, sink.err
+	}
+	if result != 0 {
+		return sink.n, newError(result)
+	}
+	return sink.n, nil
+}
+
+// ToYAML converts the document to YAML.
+func (d *Document) ToYAML(includeMetadata bool) (string, error) {
+	return d.toYAML(includeMetadata, maxOutputSize)
+}
+
+// ToYAMLContext is like ToYAML but honors an output size limit set on ctx with
+// WithMaxOutputSize.
+func (d *Document) ToYAMLContext(ctx context.Context, includeMetadata bool) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return d.toYAML(includeMetadata, outputLimit(ctx))
+}
+
+func (d *Document) toYAML(includeMetadata bool, limit int64) (string, error) {
+	if d.ptr == nil {
+		return "", errors.New("document closed")
+	}
+
+	metaInt := 0
+	if includeMetadata {
+		metaInt = 1
+	}
+
+	t := startOp("ToYAML")
+	defer t.finish(d)
+
+	var outStr *C.char
+	result := C.hedl_to_yaml(d.ptr, C.int(metaInt), &outStr)
+	t.called()
+	if result != 0 {
+		return "", newError(result)
+	}
+	defer C.hedl_free_string(outStr)
+	output := C.GoString(outStr)
+	t.copiedOut()
+	if err := checkOutputLimit(int64(len(output)), limit); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// ToYAMLMulti converts the document to a multi-document YAML stream with
+// one YAML document per root item, in key order, separated by "---" lines.
+// Each document is converted without metadata.
+func (d *Document) ToYAMLMulti() (string, error) {
+	if d.ptr == nil {
+		return "", errors.New("document closed")
+	}
+
+	t := startOp("ToYAMLMulti")
+	defer t.finish(d)
+
+	var outStr *C.char
+	result := C.hedl_to_yaml_multi(d.ptr, &outStr)
+	t.called()
+	if result != 0 {
+		return "", newError(result)
+	}
+	defer C.hedl_free_string(outStr)
+	output := C.GoString(outStr)
+	t.copiedOut()
+	if err := checkStringOutputSize(output); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+
+// ToTOML converts the document to TOML.
+//
+// Objects become tables and every matrix row becomes an entry in an array of
+// tables named after its key, so "users: @User" rows are written as
+// [[users]] blocks. Nested child rows are written as arrays of tables under
+// their parent row, named after the child type. TOML has no null, so null
+// values are omitted; references and expressions are written as strings.
+func (d *Document) ToTOML() (string, error) {
+	if d.ptr == nil {
+		return "", errors.New("document closed")
+	}
+
+	t := startOp("ToTOML")
+	defer t.finish(d)
+
+	var outStr *C.char
+	result := C.hedl_to_toml(d.ptr, &outStr)
+	t.called()
+	if result != 0 {
+		return "", newError(result)
+	}
+	defer C.hedl_free_string(outStr)
+	output := C.GoString(outStr)
+	t.copiedOut()
+	if err := checkStringOutputSize(output); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// ToXML converts the document to XML.
+func (d *Document) ToXML() (string, error) {
+	return d.toXML(maxOutputSize)
+}
+
+// ToXMLContext is like ToXML but honors an output size limit set on ctx with
+// WithMaxOutputSize.
+func (d *Document) ToXMLContext(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return d.toXML(outputLimit(ctx))
+}
+
+func (d *Document) toXML(limit int64) (string, error) {
+	if d.ptr == nil {
+		return "", errors.New("document closed")
+	}
+
+	t := startOp("ToXML")
+	defer t.finish(d)
+
+	var outStr *C.char
+	result := C.hedl_to_xml(d.ptr, &outStr)
+	t.called()
+	if result != 0 {
+		return "", newError(result)
+	}
+	defer C.hedl_free_string(outStr)
+	output := C.GoString(outStr)
+	t.copiedOut()
+	if err := checkOutputLimit(int64(len(output)), limit); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// ToCSV converts the document to CSV.
+func (d *Document) ToCSV() (string, error) {
+	return d.toCSV(maxOutputSize)
+}
+
+
+// ToCSVZip converts the document to a zip archive holding one CSV file per
+// schema, named "<schema>.csv". Each file starts with a header row of the
+// schema's fields followed by every row of that type in document order,
+// including nested rows; fields missing from a list's inline schema are
+// empty. Declared schemas come first in canonical order, followed by inline
+// schemas.
+func (d *Document) ToCSVZip() ([]byte, error) {
+	if d.ptr == nil {
+		return nil, errors.New("document closed")
+	}
+
+	t := startOp("ToCSVZip")
+	defer t.finish(d)
+
+	var dataPtr *C.uint8_t
+	var dataLen C.size_t
+	result := C.hedl_to_csv_zip(d.ptr, &dataPtr, &dataLen)
+	t.called()
+	if result != 0 {
+		return nil, newError(result)
+	}
+	defer C.hedl_free_bytes(dataPtr, dataLen)
+
+	if uint64(dataLen) > math.MaxInt32 {
+		return nil, &HedlError{
+			Message: fmt.Sprintf("CSV archive (%d bytes) is too large to copy into Go memory", uint64(dataLen)),
+			Code:    ErrAlloc,
+		}
+	}
+
+	data := C.GoBytes(unsafe.Pointer(dataPtr), C.int(dataLen))
+	t.copiedOut()
+	if err := checkOutputSize(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// ToCSVContext is like ToCSV but honors an output size limit set on ctx with
+// WithMaxOutputSize.
+func (d *Document) ToCSVContext(ctx context.Context) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return d.toCSV(outputLimit(ctx))
+}
+
+func (d *Document) toCSV(limit int64) (string, error) {
+	if d.ptr == nil {
+		return "", errors.New("document closed")
+	}
+
+	t := startOp("ToCSV")
+	defer t.finish(d)
+
+	var outStr *C.char
+	result := C.hedl_to_csv(d.ptr, &outStr)
+	t.called()
+	if result != 0 {
+		return "", newError(result)
+	}
+	defer C.hedl_free_string(outStr)
+	output := C.GoString(outStr)
+	t.copiedOut()
+	if err := checkOutputLimit(int64(len(output)), limit); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// ToParquet converts the document to Parquet format.
+func (d *Document) ToParquet() ([]byte, error) {
+	if d.ptr == nil {
+		return nil, errors.New("document closed")
+	}
+
+	t := startOp("ToParquet")
+	defer t.finish(d)
+
+	var dataPtr *C.uint8_t
+	var dataLen C.size_t
+	result := C.hedl_to_parquet(d.ptr, &dataPtr, &dataLen)
+	t.called()
+	if result != 0 {
+		return nil, newError(result)
+	}
+	defer C.hedl_free_bytes(dataPtr, dataLen)
+
+	if uint64(dataLen) > math.MaxInt32 {
+		return nil, &HedlError{
+			Message: fmt.Sprintf("Parquet output (%d bytes) is too large to copy into Go memory", uint64(dataLen)),
+			Code:    ErrAlloc,
+		}
+	}
+
+	// Copy the data before freeing
+	data := C.GoBytes(unsafe.Pointer(dataPtr), C.int(dataLen))
+	t.copiedOut()
+	if err := checkOutputSize(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// ToSQLite converts the document to the bytes of a SQLite 3 database file.
+// The native library builds the database in memory and serializes it. It has
+// one table per schema, named after the schema, with a column per field.
+// Every row of the schema, including rows nested under other rows, becomes a
+// table row in document order.
+//
+// Column types are inferred from the values: int and bool become INTEGER,
+// float becomes REAL and fields of mixed type are left untyped; everything
+// else is TEXT. Booleans are stored as 0 and 1, and references, expressions
+// and tensors as their HEDL text. Tables have no keys or indexes, so rows are
+// addressed by their rowid.
+func (d *Document) ToSQLite() ([]byte, error) {
+	if d.ptr == nil {
+		return nil, errors.New("document closed")
+	}
+
+	t := startOp("ToSQLite")
+	defer t.finish(d)
+
+	var dataPtr *C.uint8_t
+	var dataLen C.size_t
+	result := C.hedl_to_sqlite(d.ptr, &dataPtr, &dataLen)
+	t.called()
+	if result != 0 {
+		return nil, newError(result)
+	}
+	defer C.hedl_free_bytes(dataPtr, dataLen)
+
+	if uint64(dataLen) > math.MaxInt32 {
+		return nil, &HedlError{
+			Message: fmt.Sprintf("SQLite output (%d bytes) is too large to copy into Go memory", uint64(dataLen)),
+			Code:    ErrAlloc,
+		}
+	}
+
+	data := C.GoBytes(unsafe.Pointer(dataPtr), C.int(dataLen))
+	t.copiedOut()
+	if err := checkOutputSize(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// ToCypher converts the document to Neo4j Cypher queries.
+func (d *Document) ToCypher(useMerge bool) (string, error) {
+	if d.ptr == nil {
+		return "", errors.New("document closed")
+	}
+
+	mergeInt := 0
+	if useMerge {
+		mergeInt = 1
+	}
+
+	t := startOp("ToCypher")
+	defer t.finish(d)
+
+	var outStr *C.char
+	result := C.hedl_to_neo4j_cypher(d.ptr, C.int(mergeInt), &outStr)
+	t.called()
+	if result != 0 {
+		return "", newError(result)
+	}
+	defer C.hedl_free_string(outStr)
+	output := C.GoString(outStr)
+	t.copiedOut()
+	if err := checkStringOutputSize(output); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+
+// SchemaDescriptor describes a schema and its fields.
+type SchemaDescriptor struct {
+	Name   string
+	Fields []FieldDescriptor
+}
+
+// FieldDescriptor describes a single schema field.
+//
+// HEDL schemas do not declare field types, so Type is inferred from the
+// values in the document: one of "string", "int", "float", "bool",
+// "reference", "expression" or "tensor". Fields whose values span several
+// types, or that hold no non-null values, are reported as "any". Integer
+// and float values together are reported as "float". Optional is true when
+// at least one row holds null.
+type FieldDescriptor struct {
+	Name     string
+	Type     string
+	Optional bool
+}
+
+// SchemaDescriptors returns a descriptor for every schema in the document,
+// declared schemas first in canonical order followed by inline schemas.
+// Rows are read by the schema of their own list.
+func (d *Document) SchemaDescriptors() ([]SchemaDescriptor, error) {
+	if d.ptr == nil {
+		return nil, errors.New("document closed")
+	}
+
+	var outStr *C.char
+	result := C.hedl_schema_descriptors(d.ptr, &outStr)
+	if result != 0 {
+		return nil, newError(result)
+	}
+	defer C.hedl_free_string(outStr)
+
+	descriptors := []SchemaDescriptor{}
+	text := C.GoString(outStr)
+	if text == "" {
+		return descriptors, nil
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if !strings.HasPrefix(line, "\t") {
+			descriptors = append(descriptors, SchemaDescriptor{Name: line, Fields: []FieldDescriptor{}})
+			continue
+		}
+		parts := strings.Split(line[1:], "\t")
+		if len(parts) != 3 || len(descriptors) == 0 {
+			return nil, fmt.Errorf("malformed schema descriptor %q", line)
+		}
+		desc := &descriptors[len(descriptors)-1]
+		desc.Fields = append(desc.Fields, FieldDescriptor{Name: parts[0], Type: parts[1], Optional: parts[2] == "1"})
+	}
+	return descriptors, nil
+}
+
+// ToMermaidER renders the document's schemas as a Mermaid erDiagram.
+//
+// Every schema becomes an entity listing its fields with the type of their
+// non-null values, as in SchemaDescriptors; declared schemas come first, by
+// name. Qualified references held in a field become many-to-one
+// relationships labelled with the field name, and %NEST declarations become
+// one-to-many relationships labelled "contains".
+func (d *Document) ToMermaidER() (string, error) {
+	if d.ptr == nil {
+		return "", errors.New("document closed")
+	}
+
+	t := startOp("ToMermaidER")
+	defer t.finish(d)
+
+	var outStr *C.char
+	result := C.hedl_to_mermaid_er(d.ptr, &outStr)
+	t.called()
+	if result != 0 {
+		return "", newError(result)
+	}
+	defer C.hedl_free_string(outStr)
+	output := C.GoString(outStr)
+	t.copiedOut()
+	if err := checkStringOutputSize(output); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// ToProperties converts the document to a Java .properties file.
+//
+// Nested keys are flattened with dots, so "database: host: x" becomes
+// "database.host=x". Matrix rows are numbered from zero under their list key
+// and keyed by field, as in "users.0.name=Alice", with nested child rows
+// continuing the path under their type name. Values are plain text as in
+// ToCSV; properties have no null, so null values are omitted. Keys and values
+// are escaped as by java.util.Properties.store, including \uXXXX escapes for
+// non-ASCII characters, so the output loads under the ISO-8859-1 default.
+func (d *Document) ToProperties() (string, error) {
+	if d.ptr == nil {
+		return "", errors.New("document closed")
+	}
+
+	t := startOp("ToProperties")
+	defer t.finish(d)
+
+	var outStr *C.char
+	result := C.hedl_to_properties(d.ptr, &outStr)
+	t.called()
+	if result != 0 {
+		return "", newError(result)
+	}
+	defer C.hedl_free_string(outStr)
+	output := C.GoString(outStr)
+	t.copiedOut()
+	if err := checkStringOutputSize(output); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+
+// ToInfluxLineProtocol converts the document to InfluxDB line protocol,
+// writing one line per row of every schema that has timeField, in document
+// order with nested rows included.
+//
+// Each line is the measurement, the tagFields present in the row as tags
+// in the given order, every other non-null field as a field, and the
+// timestamp taken from timeField. Integer timestamps are written as they
+// are, so they should be nanoseconds since the epoch; strings are read as
+// RFC 3339 times or YYYY-MM-DD dates and converted to nanoseconds. Rows with
+// a null timestamp are written without one, so the server assigns it.
+//
+// Field values follow the inferred type of their column: ints are written
+// with the "i" suffix, numbers in float columns as floats, booleans as
+// true/false and everything else, including columns of mixed type, as
+// strings. Rows with no non-null fields cannot be represented and are
+// skipped.
+//
+// A timeField or tag field found in no schema returns ErrNotFound. An empty
+// measurement, a timestamp that cannot be read or a newline in a tag
+// returns ErrInvalidArgument.
+func (d *Document) ToInfluxLineProtocol(measurement, timeField string, tagFields []string) (string, error) {
+	if d.ptr == nil {
+		return "", errors.New("document closed")
+	}
+
+	t := startOp("ToInfluxLineProtocol")
+	defer t.finish(d)
+
+	cMeasurement := C.CString(measurement)
+	defer C.free(unsafe.Pointer(cMeasurement))
+	cTime := C.CString(timeField)
+	defer C.free(unsafe.Pointer(cTime))
+	ptrs := make([]*C.char, len(tagFields)+1)
+	for i, tag := range tagFields {
+		ptrs[i] = C.CString(tag)
+		defer C.free(unsafe.Pointer(ptrs[i]))
+	}
+
+	var outStr *C.char
+	result := C.hedl_to_influx_line_protocol(d.ptr, cMeasurement, cTime, &ptrs[0], C.int(len(tagFields)), &outStr)
+	t.called()
+	if result != 0 {
+		return "", newError(result)
+	}
+	defer C.hedl_free_string(outStr)
+	output := C.GoString(outStr)
+	t.copiedOut()
+	if err := checkStringOutputSize(output); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// Lint runs linting on the document.
+func (d *Document) Lint() (*Diagnostics, error) {
+	if d.ptr == nil {
+		return nil, errors.New("document closed")
+	}
+
+	var diagPtr *C.HedlDiagnostics
+	result := C.hedl_lint(d.ptr, &diagPtr)
+	if result != 0 {
+		return nil, newError(result)
+	}
+
+	diag := &Diagnostics{ptr: diagPtr}
+	runtime.SetFinalizer(diag, (*Diagnostics).Close)
+	return diag, nil
+}
+
+// WarningCount returns the number of lint warnings for the document without
+// collecting the diagnostic messages.
+func (d *Document) WarningCount() (int, error) {
 	if d.ptr == nil {
-		return "", errors.New("document closed")
+		return 0, errors.New("document closed")
 	}
 
-	var outStr *C.char
-	result := C.hedl_canonicalize(d.ptr, &outStr)
+	count := C.hedl_lint_warning_count(d.ptr)
+	if count < 0 {
+		return 0, newError(count)
+	}
+	return int(count), nil
+}
+
+// CheckUnicodeNormalization reports every string value, in key-value pairs
+// and matrix cells, that is not in the Unicode normalization form named by
+// form: "NFC", "NFD", "NFKC" or "NFKD", case-insensitively. Each violation
+// is an error diagnostic naming the key path, or the list row and field
+// with rows counted per type across lists. An unknown form returns a
+// HedlError with code ErrInvalidArgument.
+func (d *Document) CheckUnicodeNormalization(form string) (*Diagnostics, error) {
+	if d.ptr == nil {
+		return nil, errors.New("document closed")
+	}
+
+	cForm := C.CString(form)
+	defer C.free(unsafe.Pointer(cForm))
+
+	var diagPtr *C.HedlDiagnostics
+	result := C.hedl_check_unicode_normalization(d.ptr, cForm, &diagPtr)
 	if result != 0 {
-		return "", newError(result)
+		return nil, newError(result)
 	}
-	defer C.hedl_free_string(outStr)
-	output := C.GoString(outStr)
-	if err := checkStringOutputSize(output); err != nil {
-		return "", err
+
+	diag := &Diagnostics{ptr: diagPtr}
+	runtime.SetFinalizer(diag, (*Diagnostics).Close)
+	return diag, nil
+}
+
+// CheckSchemaReferences reports qualified references (@Type:id) whose type is
+// not a schema defined in the document. Strict parsing rejects these, but in
+// non-strict mode they parse silently. Each one is an error diagnostic with
+// rule "undefined-schema" naming the key path, or the list row and field
+// with rows counted per type across lists.
+func (d *Document) CheckSchemaReferences() (*Diagnostics, error) {
+	if d.ptr == nil {
+		return nil, errors.New("document closed")
 	}
-	return output, nil
+
+	var diagPtr *C.HedlDiagnostics
+	result := C.hedl_check_schema_references(d.ptr, &diagPtr)
+	if result != 0 {
+		return nil, newError(result)
+	}
+
+	diag := &Diagnostics{ptr: diagPtr}
+	runtime.SetFinalizer(diag, (*Diagnostics).Close)
+	return diag, nil
 }
 
-// ToJSON converts the document to JSON.
-func (d *Document) ToJSON(includeMetadata bool) (string, error) {
+// RowsWithMissing returns the indices of schemaName rows that hold a null or
+// empty string in any field. HEDL schemas have no optional fields, so every
+// field counts as required. Indices count rows of the schema across all of
+// its lists in document order. An unknown schema returns ErrNotFound.
+func (d *Document) RowsWithMissing(schemaName string) ([]int, error) {
 	if d.ptr == nil {
-		return "", errors.New("document closed")
+		return nil, errors.New("document closed")
 	}
 
-	metaInt := 0
-	if includeMetadata {
-		metaInt = 1
+	cSchema := C.CString(schemaName)
+	defer C.free(unsafe.Pointer(cSchema))
+
+	var outStr *C.char
+	result := C.hedl_rows_with_missing(d.ptr, cSchema, &outStr)
+	if result != 0 {
+		return nil, newError(result)
 	}
+	defer C.hedl_free_string(outStr)
+
+	return parseIndices(C.GoString(outStr))
+}
+
+// UnusedFields returns the fields of schemaName, in schema order, that hold
+// null or an empty string in every row across all of the schema's lists.
+// Such fields carry no data and are candidates for DropField. A schema with
+// no rows reports no unused fields. An unknown schema returns ErrNotFound.
+func (d *Document) UnusedFields(schemaName string) ([]string, error) {
+	if d.ptr == nil {
+		return nil, errors.New("document closed")
+	}
+
+	cSchema := C.CString(schemaName)
+	defer C.free(unsafe.Pointer(cSchema))
 
 	var outStr *C.char
-	result := C.hedl_to_json(d.ptr, C.int(metaInt), &outStr)
+	result := C.hedl_unused_fields(d.ptr, cSchema, &outStr)
 	if result != 0 {
-		return "", newError(result)
+		return nil, newError(result)
 	}
 	defer C.hedl_free_string(outStr)
-	output := C.GoString(outStr)
-	if err := checkStringOutputSize(output); err != nil {
-		return "", err
+
+	text := C.GoString(outStr)
+	if text == "" {
+		return []string{}, nil
 	}
-	return output, nil
+	return strings.Split(text, "\n"), nil
 }
 
-// ToYAML converts the document to YAML.
-func (d *Document) ToYAML(includeMetadata bool) (string, error) {
+// MixedTypeFields returns the fields of schemaName whose non-null values span
+// more than one type, mapped to the sorted type names seen. These are the
+// fields SchemaDescriptors reports as "any"; as there, integers and floats
+// together count as "float". Columns mixing numbers and strings are a common
+// artifact of importing loosely typed data. Fields with a single type are
+// omitted, so a clean schema yields an empty map. An unknown schema returns
+// ErrNotFound.
+func (d *Document) MixedTypeFields(schemaName string) (map[string][]string, error) {
 	if d.ptr == nil {
-		return "", errors.New("document closed")
+		return nil, errors.New("document closed")
 	}
 
-	metaInt := 0
-	if includeMetadata {
-		metaInt = 1
-	}
+	cSchema := C.CString(schemaName)
+	defer C.free(unsafe.Pointer(cSchema))
 
 	var outStr *C.char
-	result := C.hedl_to_yaml(d.ptr, C.int(metaInt), &outStr)
+	result := C.hedl_mixed_type_fields(d.ptr, cSchema, &outStr)
 	if result != 0 {
-		return "", newError(result)
+		return nil, newError(result)
 	}
 	defer C.hedl_free_string(outStr)
-	output := C.GoString(outStr)
-	if err := checkStringOutputSize(output); err != nil {
-		return "", err
+
+	mixed := make(map[string][]string)
+	text := C.GoString(outStr)
+	if text == "" {
+		return mixed, nil
 	}
-	return output, nil
+	for _, line := range strings.Split(text, "\n") {
+		parts := strings.Split(line, "\t")
+		mixed[parts[0]] = parts[1:]
+	}
+	return mixed, nil
 }
 
-// ToXML converts the document to XML.
-func (d *Document) ToXML() (string, error) {
+
+// FieldStat profiles the values of one schema field.
+type FieldStat struct {
+	Name string
+	// NonNullCount is the number of rows whose value is not null.
+	NonNullCount int
+	// FillRate is NonNullCount divided by the number of rows, or 0 when the
+	// schema has no rows.
+	FillRate float64
+	// DistinctCount is the number of distinct non-null values.
+	DistinctCount int
+}
+
+// FieldStats returns a FieldStat for every field of schemaName, in schema
+// order, computed in a single native pass over the schema's rows in all of
+// its lists. Values are compared by their HEDL text, so 1 and 1.0 are
+// distinct. An unknown schema returns ErrNotFound.
+func (d *Document) FieldStats(schemaName string) ([]FieldStat, error) {
 	if d.ptr == nil {
-		return "", errors.New("document closed")
+		return nil, errors.New("document closed")
 	}
 
+	cSchema := C.CString(schemaName)
+	defer C.free(unsafe.Pointer(cSchema))
+
+	var rows C.int
 	var outStr *C.char
-	result := C.hedl_to_xml(d.ptr, &outStr)
+	result := C.hedl_field_stats(d.ptr, cSchema, &rows, &outStr)
 	if result != 0 {
-		return "", newError(result)
+		return nil, newError(result)
 	}
 	defer C.hedl_free_string(outStr)
-	output := C.GoString(outStr)
-	if err := checkStringOutputSize(output); err != nil {
-		return "", err
+
+	stats := []FieldStat{}
+	text := C.GoString(outStr)
+	if text == "" {
+		return stats, nil
 	}
-	return output, nil
+	for _, line := range strings.Split(text, "\n") {
+		parts := strings.Split(line, "\t")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("malformed field statistics %q", line)
+		}
+		nonNull, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("malformed field statistics %q", line)
+		}
+		distinct, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("malformed field statistics %q", line)
+		}
+		stat := FieldStat{Name: parts[0], NonNullCount: nonNull, DistinctCount: distinct}
+		if rows > 0 {
+			stat.FillRate = float64(nonNull) / float64(rows)
+		}
+		stats = append(stats, stat)
+	}
+	return stats, nil
 }
 
-// ToCSV converts the document to CSV.
-func (d *Document) ToCSV() (string, error) {
+// parseIndices reads the row indices returned one per line by native checks.
+func parseIndices(text string) ([]int, error) {
+	indices := []int{}
+	if text == "" {
+		return indices, nil
+	}
+	for _, line := range strings.Split(text, "\n") {
+		index, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, fmt.Errorf("malformed row index %q", line)
+		}
+		indices = append(indices, index)
+	}
+	return indices, nil
+}
+
+// HasCycles reports whether references between rows form a cycle, without
+// modifying the document. When they do, it also returns the rows in the
+// first cycle found, as "Type:id" in reference order; a row referencing
+// itself is a cycle of one. Rows are visited in document order, so the
+// result is deterministic.
+func (d *Document) HasCycles() (bool, []string, error) {
 	if d.ptr == nil {
-		return "", errors.New("document closed")
+		return false, nil, errors.New("document closed")
 	}
 
 	var outStr *C.char
-	result := C.hedl_to_csv(d.ptr, &outStr)
+	result := C.hedl_find_reference_cycle(d.ptr, &outStr)
 	if result != 0 {
-		return "", newError(result)
+		return false, nil, newError(result)
 	}
 	defer C.hedl_free_string(outStr)
-	output := C.GoString(outStr)
-	if err := checkStringOutputSize(output); err != nil {
-		return "", err
+
+	text := C.GoString(outStr)
+	if text == "" {
+		return false, nil, nil
 	}
-	return output, nil
+	return true, strings.Split(text, "\n"), nil
 }
 
-// ToParquet converts the document to Parquet format.
-func (d *Document) ToParquet() ([]byte, error) {
+// CheckUnique reports every value of field that appears in more than one
+// schemaName row, as one error diagnostic per value naming the indices of
+// the rows that hold it. Indices count rows of the schema across all of its
+// lists in document order, and null values are skipped, as in a SQL unique
+// constraint. Values of different types never match, so the string "1" and
+// the integer 1 are distinct. The parser already rejects duplicate IDs, so
+// this is mainly useful for other key fields. An unknown schema or field
+// returns ErrNotFound.
+func (d *Document) CheckUnique(schemaName, field string) (*Diagnostics, error) {
 	if d.ptr == nil {
 		return nil, errors.New("document closed")
 	}
 
-	var dataPtr *C.uint8_t
-	var dataLen C.size_t
-	result := C.hedl_to_parquet(d.ptr, &dataPtr, &dataLen)
+	cSchema := C.CString(schemaName)
+	defer C.free(unsafe.Pointer(cSchema))
+	cField := C.CString(field)
+	defer C.free(unsafe.Pointer(cField))
+
+	var diagPtr *C.HedlDiagnostics
+	result := C.hedl_check_unique(d.ptr, cSchema, cField, &diagPtr)
 	if result != 0 {
 		return nil, newError(result)
 	}
-	defer C.hedl_free_bytes(dataPtr, dataLen)
 
-	// Copy the data before freeing
-	data := C.GoBytes(unsafe.Pointer(dataPtr), C.int(dataLen))
-	if err := checkOutputSize(data); err != nil {
-		return nil, err
-	}
-	return data, nil
+	diag := &Diagnostics{ptr: diagPtr}
+	runtime.SetFinalizer(diag, (*Diagnostics).Close)
+	return diag, nil
 }
 
-// ToCypher converts the document to Neo4j Cypher queries.
-func (d *Document) ToCypher(useMerge bool) (string, error) {
+// CheckRanges reports numeric values outside their allowed range. HEDL has
+// no syntax for range annotations, so ranges maps "Schema.field" to an
+// inclusive [min, max] pair. Every value below min or above max is reported
+// as an error diagnostic naming the row index, counted across the schema's
+// lists in document order; non-numeric values are reported too. Null values
+// are skipped; use RowsWithMissing to find them.
+//
+// A key not of the form "Schema.field" or a range whose min exceeds its max
+// returns an ErrInvalidArgument error, and an unknown schema or field returns
+// ErrNotFound.
+func (d *Document) CheckRanges(ranges map[string][2]float64) (*Diagnostics, error) {
 	if d.ptr == nil {
-		return "", errors.New("document closed")
+		return nil, errors.New("document closed")
 	}
 
-	mergeInt := 0
-	if useMerge {
-		mergeInt = 1
+	// Check fields in a stable order so diagnostics are deterministic.
+	keys := make([]string, 0, len(ranges))
+	for key := range ranges {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	ptrs := make([]*C.char, len(keys)+1)
+	mins := make([]C.double, len(keys)+1)
+	maxs := make([]C.double, len(keys)+1)
+	for i, key := range keys {
+		ptrs[i] = C.CString(key)
+		defer C.free(unsafe.Pointer(ptrs[i]))
+		mins[i], maxs[i] = C.double(ranges[key][0]), C.double(ranges[key][1])
 	}
 
-	var outStr *C.char
-	result := C.hedl_to_neo4j_cypher(d.ptr, C.int(mergeInt), &outStr)
+	var diagPtr *C.HedlDiagnostics
+	result := C.hedl_check_ranges(d.ptr, &ptrs[0], &mins[0], &maxs[0], C.int(len(keys)), &diagPtr)
 	if result != 0 {
-		return "", newError(result)
+		return nil, newError(result)
 	}
-	defer C.hedl_free_string(outStr)
-	output := C.GoString(outStr)
-	if err := checkStringOutputSize(output); err != nil {
-		return "", err
+
+	diag := &Diagnostics{ptr: diagPtr}
+	runtime.SetFinalizer(diag, (*Diagnostics).Close)
+	return diag, nil
+}
+
+// CheckForeignKeys reports every row whose foreign key value has no match
+// among the rows of the referenced schema, as an error diagnostic naming the
+// row index counted across the schema's lists in document order. Values are
+// compared by their plain text as in IndexBy. A reference value matches by
+// its ID, and a qualified reference to a type other than ToSchema never
+// matches. Null values are skipped; use RowsWithMissing to find them.
+//
+// An unknown schema or field returns an ErrNotFound error.
+func (d *Document) CheckForeignKeys(refs []ForeignKey) (*Diagnostics, error) {
+	if d.ptr == nil {
+		return nil, errors.New("document closed")
 	}
-	return output, nil
+
+	from := make([]*C.char, len(refs)+1)
+	to := make([]*C.char, len(refs)+1)
+	for i, ref := range refs {
+		from[i] = C.CString(ref.FromSchema + "." + ref.FromField)
+		defer C.free(unsafe.Pointer(from[i]))
+		to[i] = C.CString(ref.ToSchema + "." + ref.ToKey)
+		defer C.free(unsafe.Pointer(to[i]))
+	}
+
+	var diagPtr *C.HedlDiagnostics
+	result := C.hedl_check_foreign_keys(d.ptr, &from[0], &to[0], C.int(len(refs)), &diagPtr)
+	if result != 0 {
+		return nil, newError(result)
+	}
+
+	diag := &Diagnostics{ptr: diagPtr}
+	runtime.SetFinalizer(diag, (*Diagnostics).Close)
+	return diag, nil
 }
 
-// Lint runs linting on the document.
-func (d *Document) Lint() (*Diagnostics, error) {
+// CheckWhitespace reports every string value, in key-value pairs and matrix
+// cells, with leading or trailing whitespace. Each one is a warning naming
+// the key path, or the list row and field with rows counted per type across
+// lists; TrimStrings removes them.
+func (d *Document) CheckWhitespace() (*Diagnostics, error) {
 	if d.ptr == nil {
 		return nil, errors.New("document closed")
 	}
 
 	var diagPtr *C.HedlDiagnostics
-	result := C.hedl_lint(d.ptr, &diagPtr)
+	result := C.hedl_check_whitespace(d.ptr, &diagPtr)
 	if result != 0 {
 		return nil, newError(result)
 	}
@@ -588,19 +3174,90 @@ func (d *Document) Lint() (*Diagnostics, error) {
 	return diag, nil
 }
 
+// TrimStrings returns a new document with leading and trailing whitespace
+// removed from every string value, fixing what CheckWhitespace reports.
+// References, expressions and other non-string values are unchanged.
+func (d *Document) TrimStrings() (*Document, error) {
+	if d.ptr == nil {
+		return nil, errors.New("document closed")
+	}
+
+	var docPtr *C.HedlDocument
+	result := C.hedl_trim_strings(d.ptr, &docPtr)
+	doc, err := wrapDocument(result, docPtr)
+	if err != nil {
+		return nil, err
+	}
+	doc.schemaOrder = d.schemaOrder
+	return doc, nil
+}
+
+
+// AutoFix returns a copy of the document with safe fixes applied, and a
+// changelog with one entry per change, for a "hedl fix" command. The fixes
+// are, in order:
+//
+//   - CRLF and lone CR line endings in strings become LF.
+//   - Leading and trailing whitespace is trimmed from strings.
+//   - A string in a column whose other values are all numbers, or all
+//     booleans, becomes that type if it reads as one, so "42" becomes 42
+//     and "False" becomes false. Key-value pairs have no column to go by
+//     and are left as strings.
+//   - %STRUCT and %NEST declarations no list uses are dropped, which
+//     clears the unused-schema lint warning.
+//
+// Object keys need no fixing: the native library always keeps them sorted
+// by name.
+//
+// Locations in the changelog name key paths, or list rows and fields as in
+// the other checks. When nothing needs fixing the changelog is empty.
+func (d *Document) AutoFix() (*Document, []string, error) {
+	if d.ptr == nil {
+		return nil, nil, errors.New("document closed")
+	}
+
+	var docPtr *C.HedlDocument
+	var outChanges *C.char
+	result := C.hedl_auto_fix(d.ptr, &docPtr, &outChanges)
+	if result != 0 {
+		return nil, nil, newError(result)
+	}
+	defer C.hedl_free_string(outChanges)
+	doc, err := wrapDocument(result, docPtr)
+	if err != nil {
+		return nil, nil, err
+	}
+	doc.schemaOrder = d.schemaOrder
+
+	changes := []string{}
+	if text := C.GoString(outChanges); text != "" {
+		changes = strings.Split(text, "\n")
+	}
+	return doc, changes, nil
+}
+
 // Close frees the diagnostics resources.
+//
+// Close is safe to call more than once and on nil Diagnostics.
 func (d *Diagnostics) Close() {
+	if d == nil {
+		return
+	}
 	if d.ptr != nil {
 		C.hedl_free_diagnostics(d.ptr)
 		d.ptr = nil
 	}
+	d.items = nil
 }
 
 // Count returns the number of diagnostics.
 func (d *Diagnostics) Count() int {
-	if d.ptr == nil {
+	if d == nil {
 		return 0
 	}
+	if d.ptr == nil {
+		return len(d.items)
+	}
 	count := C.hedl_diagnostics_count(d.ptr)
 	if count < 0 {
 		return 0
@@ -610,9 +3267,16 @@ func (d *Diagnostics) Count() int {
 
 // Get returns the diagnostic at the given index.
 func (d *Diagnostics) Get(index int) (*Diagnostic, error) {
-	if d.ptr == nil {
+	if d == nil || (d.ptr == nil && d.items == nil) {
 		return nil, errors.New("diagnostics closed")
 	}
+	if index < 0 || index >= d.Count() {
+		return nil, fmt.Errorf("diagnostic index %d out of range", index)
+	}
+	if d.ptr == nil {
+		item := *d.items[index]
+		return &item, nil
+	}
 
 	var msgStr *C.char
 	result := C.hedl_diagnostics_get(d.ptr, C.int(index), &msgStr)
@@ -621,10 +3285,23 @@ func (d *Diagnostics) Get(index int) (*Diagnostic, error) {
 	}
 	defer C.hedl_free_string(msgStr)
 
+	var ruleStr *C.char
+	result = C.hedl_diagnostics_rule(d.ptr, C.int(index), &ruleStr)
+	if result != 0 {
+		return nil, newError(result)
+	}
+	defer C.hedl_free_string(ruleStr)
+
 	severity := C.hedl_diagnostics_severity(d.ptr, C.int(index))
+	line := int(C.hedl_diagnostics_line(d.ptr, C.int(index)))
 	return &Diagnostic{
-		Message:  C.GoString(msgStr),
-		Severity: int(severity),
+		Message:   C.GoString(msgStr),
+		Severity:  int(severity),
+		Line:      line,
+		Column:    int(C.hedl_diagnostics_column(d.ptr, C.int(index))),
+		EndLine:   line,
+		EndColumn: 0,
+		RuleID:    C.GoString(ruleStr),
 	}, nil
 }
 
@@ -642,32 +3319,65 @@ func (d *Diagnostics) All() ([]*Diagnostic, error) {
 	return result, nil
 }
 
-// Errors returns all error messages.
-func (d *Diagnostics) Errors() ([]string, error) {
+// BySeverity returns the diagnostics with a severity of at least min, most
+// severe first and, within a severity, in source order by Line and Column.
+// Diagnostics without a position follow those with one, in their original
+// order. An empty slice is returned when nothing matches, so
+// BySeverity(SeverityWarning) gives everything warning-or-worse for gating.
+func (d *Diagnostics) BySeverity(min int) ([]*Diagnostic, error) {
 	all, err := d.All()
 	if err != nil {
 		return nil, err
 	}
-	var result []string
+	result := []*Diagnostic{}
 	for _, diag := range all {
-		if diag.Severity == SeverityError {
-			result = append(result, diag.Message)
+		if diag.Severity >= min {
+			result = append(result, diag)
 		}
 	}
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Severity != b.Severity {
+			return a.Severity > b.Severity
+		}
+		if (a.Line == 0) != (b.Line == 0) {
+			return b.Line == 0
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
 	return result, nil
 }
 
-// Warnings returns all warning messages.
-func (d *Diagnostics) Warnings() ([]string, error) {
-	all, err := d.All()
+// messages returns the messages of the diagnostics with exactly severity,
+// in the order of BySeverity.
+func (d *Diagnostics) messages(severity int) ([]string, error) {
+	matched, err := d.BySeverity(severity)
 	if err != nil {
 		return nil, err
 	}
 	var result []string
-	for _, diag := range all {
-		if diag.Severity == SeverityWarning {
+	for _, diag := range matched {
+		if diag.Severity == severity {
 			result = append(result, diag.Message)
 		}
 	}
 	return result, nil
 }
+
+// Errors returns all error messages.
+func (d *Diagnostics) Errors() ([]string, error) {
+	return d.messages(SeverityError)
+}
+
+// Warnings returns all warning messages.
+func (d *Diagnostics) Warnings() ([]string, error) {
+	return d.messages(SeverityWarning)
+}
+
+// Hints returns all hint messages.
+func (d *Diagnostics) Hints() ([]string, error) {
+	return d.messages(SeverityHint)
+}
@@ -1,7 +1,19 @@
 package hedl
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
 	"testing"
+	"testing/iotest"
+	"time"
 )
 
 // Use shared fixtures from common/fixtures directory
@@ -62,6 +74,207 @@ func TestParseInvalid(t *testing.T) {
 	}
 }
 
+func TestParseErrorLocation(t *testing.T) {
+	for name, parse := range map[string]func() (*Document, error){
+		"Parse": func() (*Document, error) { return Parse(shapeErrorHEDL, true) },
+		"ParseReader": func() (*Document, error) {
+			return ParseReader(strings.NewReader(shapeErrorHEDL), true)
+		},
+	} {
+		_, err := parse()
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("%s: expected *ParseError, got %T: %v", name, err, err)
+		}
+		if parseErr.Line != 6 || parseErr.Snippet != "  | bob" {
+			t.Errorf("%s: expected line 6 \"  | bob\", got line %d %q", name, parseErr.Line, parseErr.Snippet)
+		}
+		var hedlErr *HedlError
+		if !errors.As(err, &hedlErr) || hedlErr.Code != ErrParse || !errors.Is(err, ErrParseFailed) {
+			t.Errorf("%s: expected the error to unwrap to an ErrParse HedlError, got %v", name, err)
+		}
+	}
+}
+
+func TestParseDefault(t *testing.T) {
+	defer SetDefaultStrict(true)
+
+	SetDefaultStrict(false)
+	doc, err := ParseDefault(undefinedSchemaHEDL)
+	if err != nil {
+		t.Fatalf("Expected non-strict ParseDefault to succeed: %v", err)
+	}
+	doc.Close()
+
+	SetDefaultStrict(true)
+	doc, err = ParseDefault(undefinedSchemaHEDL)
+	if err == nil {
+		doc.Close()
+		t.Fatal("Expected strict ParseDefault to reject undefined references")
+	}
+}
+
+func TestParseDeadline(t *testing.T) {
+	before := runtime.NumGoroutine()
+	doc, err := ParseDeadline(sampleHEDL, true, time.Now().Add(-time.Second))
+	if err == nil {
+		doc.Close()
+		t.Fatal("Expected error for a past deadline")
+	}
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrTimeout {
+		t.Fatalf("Expected ErrTimeout, got %v", err)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("Expected no leaked goroutines, had %d and now %d", before, after)
+	}
+
+	doc, err = ParseDeadline(sampleHEDL, true, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("ParseDeadline failed: %v", err)
+	}
+	doc.Close()
+}
+
+func TestParseDeadlineNative(t *testing.T) {
+	// The deadline is still ahead when ParseDeadline is called, so it is the
+	// native parser that has to notice it passing.
+	var b strings.Builder
+	b.WriteString("%VERSION: 1.0\n%STRUCT: Row: [id, name]\n---\nrows: @Row\n")
+	for i := 0; i < 500000; i++ {
+		fmt.Fprintf(&b, "  | r%d, name %d\n", i, i)
+	}
+	doc, err := ParseDeadline(b.String(), true, time.Now().Add(time.Millisecond))
+	if err == nil {
+		doc.Close()
+		t.Fatal("Expected the native parser to time out")
+	}
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrTimeout {
+		t.Fatalf("Expected ErrTimeout, got %v", err)
+	}
+
+	// Other errors are parse errors, however close the deadline.
+	_, err = ParseDeadline("not valid hedl", true, time.Now().Add(time.Millisecond))
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrParse {
+		t.Fatalf("Expected ErrParse, got %v", err)
+	}
+}
+
+func TestParseReader(t *testing.T) {
+	large, err := GetGlobalFixtures().LargeHEDL()
+	if err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+	want, err := Transcode(large, "hedl", "json", true)
+	if err != nil {
+		t.Fatalf("Transcode failed: %v", err)
+	}
+
+	// HalfReader returns short reads, so the buffer is filled and grown in
+	// many steps.
+	doc, err := ParseReader(iotest.HalfReader(strings.NewReader(large)), true)
+	if err != nil {
+		t.Fatalf("ParseReader failed: %v", err)
+	}
+	defer doc.Close()
+
+	got, err := doc.ToJSON(false)
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	if got != string(want) {
+		t.Error("ParseReader result differs from Parse")
+	}
+}
+
+func TestParseReaderFullBuffer(t *testing.T) {
+	// The input fills the read buffer exactly, and DataErrReader returns
+	// io.EOF with the last bytes, so the buffer is never grown and holds
+	// no terminator after the content.
+	prefix := "%VERSION: 1.0\n---\nnote: "
+	content := prefix + strings.Repeat("x", parseReadChunk-len(prefix)-1) + "\n"
+	if len(content) != parseReadChunk {
+		t.Fatalf("Content is %d bytes, want %d", len(content), parseReadChunk)
+	}
+
+	doc, err := ParseReader(iotest.DataErrReader(strings.NewReader(content)), true)
+	if err != nil {
+		t.Fatalf("ParseReader failed: %v", err)
+	}
+	defer doc.Close()
+
+	got, err := doc.ToJSON(false)
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	if want := parseReadChunk - len(prefix) - 1; !strings.Contains(got, strings.Repeat("x", want)+`"`) {
+		t.Errorf("Expected a %d byte note", want)
+	}
+}
+
+func TestParseReaderErrors(t *testing.T) {
+	invalidSyntax, err := GetGlobalFixtures().ErrorInvalidSyntax()
+	if err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+	_, err = ParseReader(strings.NewReader(invalidSyntax), true)
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrParse {
+		t.Errorf("Expected ErrParse for invalid content, got %v", err)
+	}
+
+	readErr := errors.New("connection reset")
+	_, err = ParseReader(io.MultiReader(strings.NewReader(sampleHEDL), iotest.ErrReader(readErr)), true)
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrIO || !errors.Is(err, readErr) {
+		t.Errorf("Expected ErrIO wrapping the read error, got %v", err)
+	}
+	if got := CategoryOf(err); got != CategoryIO {
+		t.Errorf("Expected category %q, got %q", CategoryIO, got)
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "sample.hedl", sampleHEDL)
+
+	before := OpenDocuments()
+	doc, err := ParseFile(path, true)
+	if err != nil {
+		t.Fatalf("ParseFile failed: %v", err)
+	}
+	if got := OpenDocuments(); got != before+1 {
+		t.Errorf("Expected %d open documents, got %d", before+1, got)
+	}
+	doc.Close()
+
+	_, err = ParseFile(filepath.Join(t.TempDir(), "missing.hedl"), true)
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrIO {
+		t.Errorf("Expected ErrIO for a missing file, got %v", err)
+	}
+}
+
+func TestValidateFile(t *testing.T) {
+	dir := t.TempDir()
+	invalidSyntax, err := GetGlobalFixtures().ErrorInvalidSyntax()
+	if err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+
+	valid, err := ValidateFile(writeFile(t, dir, "valid.hedl", sampleHEDL), true)
+	if err != nil || !valid {
+		t.Errorf("Expected a valid file, got %v, %v", valid, err)
+	}
+	valid, err = ValidateFile(writeFile(t, dir, "invalid.hedl", invalidSyntax), true)
+	if err != nil || valid {
+		t.Errorf("Expected an invalid file, got %v, %v", valid, err)
+	}
+	_, err = ValidateFile(filepath.Join(dir, "missing.hedl"), true)
+	if CategoryOf(err) != CategoryIO {
+		t.Errorf("Expected an io error for a missing file, got %v", err)
+	}
+}
+
 func TestValidate(t *testing.T) {
 	if !Validate(sampleHEDL, true) {
 		t.Fatal("Expected valid content to pass validation")
@@ -96,6 +309,46 @@ func TestDocumentVersion(t *testing.T) {
 	}
 }
 
+func TestDocumentSemVer(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	v, err := doc.SemVer()
+	if err != nil {
+		t.Fatalf("SemVer failed: %v", err)
+	}
+	if v != (Version{Major: 1, Minor: 0}) {
+		t.Errorf("Expected version 1.0, got %v", v)
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	tests := []struct {
+		a, b Version
+		want int
+	}{
+		{Version{1, 0}, Version{1, 0}, 0},
+		{Version{1, 0}, Version{1, 1}, -1},
+		{Version{1, 2}, Version{1, 1}, 1},
+		{Version{1, 9}, Version{2, 0}, -1},
+		{Version{2, 0}, Version{1, 10}, 1},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Compare(tt.b); got != tt.want {
+			t.Errorf("%v.Compare(%v) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestVersionString(t *testing.T) {
+	if got := (Version{Major: 1, Minor: 10}).String(); got != "1.10" {
+		t.Errorf("Expected 1.10, got %s", got)
+	}
+}
+
 func TestDocumentSchemaCount(t *testing.T) {
 	doc, err := Parse(sampleHEDL, true)
 	if err != nil {
@@ -112,6 +365,36 @@ func TestDocumentSchemaCount(t *testing.T) {
 	}
 }
 
+func TestDocumentSchemaNames(t *testing.T) {
+	doc, err := Parse(unorderedSchemasHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	names, err := doc.SchemaNames()
+	if err != nil {
+		t.Fatalf("SchemaNames failed: %v", err)
+	}
+	if strings.Join(names, ",") != "Order,Customer" {
+		t.Errorf("Expected [Order Customer], got %q", names)
+	}
+
+	empty, err := Parse("%VERSION: 1.0\n---\nname: test\n", true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer empty.Close()
+
+	names, err = empty.SchemaNames()
+	if err != nil {
+		t.Fatalf("SchemaNames failed: %v", err)
+	}
+	if names == nil || len(names) != 0 {
+		t.Errorf("Expected an empty non-nil slice, got %#v", names)
+	}
+}
+
 func TestCanonicalize(t *testing.T) {
 	doc, err := Parse(sampleHEDL, true)
 	if err != nil {
@@ -144,6 +427,42 @@ func TestToJSON(t *testing.T) {
 	}
 }
 
+func TestWriteJSON(t *testing.T) {
+	large, err := GetGlobalFixtures().LargeHEDL()
+	if err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+	doc, err := Parse(large, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	want, err := doc.ToJSON(true)
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	var buf bytes.Buffer
+	n, err := doc.WriteJSON(&buf, true)
+	if err != nil {
+		t.Fatalf("WriteJSON failed: %v", err)
+	}
+	if buf.String() != want {
+		t.Error("Expected WriteJSON to match ToJSON")
+	}
+	if n != int64(len(want)) {
+		t.Errorf("Expected %d bytes written, got %d", len(want), n)
+	}
+
+	// A closed pipe makes every write fail with the given error.
+	pr, pw := io.Pipe()
+	writeErr := errors.New("disk full")
+	pr.CloseWithError(writeErr)
+	if _, err := doc.WriteJSON(pw, true); !errors.Is(err, writeErr) {
+		t.Errorf("Expected the writer's error, got %v", err)
+	}
+}
+
 func TestToYAML(t *testing.T) {
 	doc, err := Parse(sampleHEDL, true)
 	if err != nil {
@@ -256,6 +575,41 @@ func TestFromXML(t *testing.T) {
 	defer doc.Close()
 }
 
+func TestFromCSV(t *testing.T) {
+	content := "id,Full Name,note\n" +
+		"1,\"Smith, Jane\",\"line one\nline two\"\n" +
+		"2,Bob,ok\n"
+	doc, err := FromCSV(content)
+	if err != nil {
+		t.Fatalf("FromCSV failed: %v", err)
+	}
+	defer doc.Close()
+
+	names, err := doc.SchemaNames()
+	if err != nil {
+		t.Fatalf("SchemaNames failed: %v", err)
+	}
+	if strings.Join(names, ",") != "Row" {
+		t.Errorf("Expected a single Row schema, got %q", names)
+	}
+
+	jsonStr, err := doc.ToJSON(false)
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	for _, want := range []string{`"full_name"`, `"Smith, Jane"`, `"line one\nline two"`} {
+		if !strings.Contains(jsonStr, want) {
+			t.Errorf("Expected %s in JSON:\n%s", want, jsonStr)
+		}
+	}
+
+	_, err = FromCSV("id,name\n1,Alice,extra\n")
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrCSV {
+		t.Errorf("Expected ErrCSV for a ragged row, got %v", err)
+	}
+}
+
 func TestLint(t *testing.T) {
 	doc, err := Parse(sampleHEDL, true)
 	if err != nil {
@@ -275,6 +629,48 @@ func TestLint(t *testing.T) {
 	}
 }
 
+func TestWarningCount(t *testing.T) {
+	fixtures := GetGlobalFixtures()
+	inputs := map[string]string{
+		"unused schema": "%VERSION: 1.0\n%STRUCT: Unused: [id, name]\n---\nkey: value\n",
+	}
+	for _, category := range []string{"basic", "scalars", "large"} {
+		content, err := fixtures.GetFixture(category, "hedl")
+		if err != nil {
+			t.Fatalf("Failed to load fixture %q: %v", category, err)
+		}
+		inputs[category] = content
+	}
+
+	for category, content := range inputs {
+		doc, err := Parse(content, true)
+		if err != nil {
+			t.Fatalf("Parse %q failed: %v", category, err)
+		}
+
+		count, err := doc.WarningCount()
+		if err != nil {
+			t.Fatalf("WarningCount %q failed: %v", category, err)
+		}
+		diag, err := doc.Lint()
+		if err != nil {
+			t.Fatalf("Lint %q failed: %v", category, err)
+		}
+		warnings, err := diag.Warnings()
+		if err != nil {
+			t.Fatalf("Warnings %q failed: %v", category, err)
+		}
+		if count != len(warnings) {
+			t.Errorf("%s: WarningCount() = %d, but Warnings() has %d entries", category, count, len(warnings))
+		}
+		if category == "unused schema" && count == 0 {
+			t.Errorf("%s: expected a warning", category)
+		}
+		diag.Close()
+		doc.Close()
+	}
+}
+
 func TestDoubleClose(t *testing.T) {
 	doc, err := Parse(sampleHEDL, true)
 	if err != nil {
@@ -283,3 +679,562 @@ func TestDoubleClose(t *testing.T) {
 	doc.Close()
 	doc.Close() // Should not panic
 }
+
+func TestCloseNil(t *testing.T) {
+	var doc *Document
+	doc.Close() // Should not panic
+
+	var diag *Diagnostics
+	diag.Close() // Should not panic
+	if diag.Count() != 0 {
+		t.Fatal("Expected nil diagnostics to report zero count")
+	}
+}
+
+// settledNativeDocuments returns the native live-document count once the
+// finalizers of Documents that are already unreachable have run.
+func settledNativeDocuments() int64 {
+	count := int64(-1)
+	for i := 0; i < 10; i++ {
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+		n := nativeDocuments()
+		if n == count {
+			break
+		}
+		count = n
+	}
+	return count
+}
+
+func TestImportErrorsDoNotLeak(t *testing.T) {
+	fixtures := GetGlobalFixtures()
+	invalidSyntax, err := fixtures.ErrorInvalidSyntax()
+	if err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+
+	// The native count also covers documents the FFI returned alongside an
+	// error that the binding never wrapped.
+	before := settledNativeDocuments()
+	openBefore := OpenDocuments()
+
+	importers := map[string]func() (*Document, error){
+		"Parse":       func() (*Document, error) { return Parse(invalidSyntax, true) },
+		"FromJSON":    func() (*Document, error) { return FromJSON(`{"users": [`) },
+		"FromYAML":    func() (*Document, error) { return FromYAML("users: [\n  - : :\n") },
+		"FromXML":     func() (*Document, error) { return FromXML("<root><users>") },
+		"FromCSV":     func() (*Document, error) { return FromCSV("id,name\n\"unterminated") },
+		"FromTOML":    func() (*Document, error) { return FromTOML("users = [") },
+		"FromParquet": func() (*Document, error) { return FromParquet([]byte("not parquet")) },
+	}
+	for name, importer := range importers {
+		doc, err := importer()
+		if err == nil {
+			doc.Close()
+			t.Fatalf("%s: expected error for invalid input", name)
+		}
+		if doc != nil {
+			t.Fatalf("%s: expected nil document on error", name)
+		}
+	}
+
+	if after := nativeDocuments(); after != before {
+		t.Fatalf("Expected %d native documents after failed imports, got %d", before, after)
+	}
+	if after := OpenDocuments(); after != openBefore {
+		t.Fatalf("Expected %d open documents after failed imports, got %d", openBefore, after)
+	}
+}
+
+func TestOpenDocuments(t *testing.T) {
+	before := OpenDocuments()
+
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if got := OpenDocuments(); got != before+1 {
+		t.Fatalf("Expected %d open documents, got %d", before+1, got)
+	}
+
+	doc.Close()
+	doc.Close()
+	if got := OpenDocuments(); got != before {
+		t.Fatalf("Expected %d open documents after Close, got %d", before, got)
+	}
+}
+
+func TestToJSONContextMaxOutputSize(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	ctx := WithMaxOutputSize(context.Background(), 8)
+	_, err = doc.ToJSONContext(ctx, false)
+	if err == nil {
+		t.Fatal("Expected output size error")
+	}
+	hedlErr, ok := err.(*HedlError)
+	if !ok || hedlErr.Code != ErrAlloc {
+		t.Fatalf("Expected HedlError with ErrAlloc, got %v", err)
+	}
+
+	json, err := doc.ToJSONContext(context.Background(), false)
+	if err != nil {
+		t.Fatalf("ToJSONContext failed: %v", err)
+	}
+	if len(json) == 0 {
+		t.Fatal("Expected non-empty JSON output")
+	}
+}
+
+func TestToJSONLimited(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	_, err = doc.ToJSONLimited(false, 8)
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrAlloc {
+		t.Fatalf("Expected ErrAlloc for an 8 byte limit, got %v", err)
+	}
+
+	want, err := doc.ToJSON(false)
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	for _, limit := range []int64{int64(len(want)), 0} {
+		json, err := doc.ToJSONLimited(false, limit)
+		if err != nil {
+			t.Fatalf("ToJSONLimited(%d) failed: %v", limit, err)
+		}
+		if json != want {
+			t.Errorf("Expected ToJSONLimited(%d) to match ToJSON", limit)
+		}
+	}
+}
+
+func TestSentinelErrors(t *testing.T) {
+	invalidSyntax, err := GetGlobalFixtures().ErrorInvalidSyntax()
+	if err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+	_, err = Parse(invalidSyntax, true)
+	if !errors.Is(err, ErrParseFailed) {
+		t.Errorf("Expected errors.Is(err, ErrParseFailed), got %v", err)
+	}
+	if errors.Is(err, ErrAllocExceeded) {
+		t.Errorf("Did not expect a parse error to match ErrAllocExceeded")
+	}
+
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+	_, err = doc.ToJSONLimited(false, 8)
+	if !errors.Is(fmt.Errorf("export: %w", err), ErrAllocExceeded) {
+		t.Errorf("Expected a wrapped output limit error to match ErrAllocExceeded, got %v", err)
+	}
+	if errors.Is(err, ErrParseFailed) {
+		t.Errorf("Did not expect an output limit error to match ErrParseFailed")
+	}
+}
+
+func TestCategoryOf(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{&HedlError{Code: ErrNullPtr}, CategoryInternal},
+		{&HedlError{Code: ErrInvalidUTF8}, CategoryParse},
+		{&HedlError{Code: ErrParse}, CategoryParse},
+		{&HedlError{Code: ErrCanonicalize}, CategoryFormat},
+		{&HedlError{Code: ErrJSON}, CategoryFormat},
+		{&HedlError{Code: ErrAlloc}, CategoryAlloc},
+		{&HedlError{Code: ErrYAML}, CategoryFormat},
+		{&HedlError{Code: ErrXML}, CategoryFormat},
+		{&HedlError{Code: ErrCSV}, CategoryFormat},
+		{&HedlError{Code: ErrParquet}, CategoryFormat},
+		{&HedlError{Code: ErrLint}, CategoryLint},
+		{&HedlError{Code: ErrNeo4j}, CategoryFormat},
+		{&HedlError{Code: ErrNotFound}, CategoryNotFound},
+		{&HedlError{Code: ErrCyclicReference}, CategoryParse},
+		{&HedlError{Code: ErrTimeout}, CategoryTimeout},
+		{&HedlError{Code: ErrIO}, CategoryIO},
+		{&HedlError{Code: ErrTOML}, CategoryFormat},
+		{&HedlError{Code: ErrSQLite}, CategoryFormat},
+		{&HedlError{Code: ErrInvalidArgument}, CategoryInvalidArgument},
+		{&HedlError{Code: ErrConflict}, CategoryConflict},
+		{&HedlError{Code: ErrFingerprintMismatch}, CategoryConflict},
+		{&HedlError{Code: 42}, CategoryUnknown},
+		{fmt.Errorf("wrapped: %w", &HedlError{Code: ErrParse}), CategoryParse},
+		{&fs.PathError{Op: "open", Path: "x.hedl", Err: fs.ErrNotExist}, CategoryIO},
+		{errors.New("plain"), CategoryUnknown},
+		{nil, CategoryUnknown},
+	}
+	for _, c := range cases {
+		if got := CategoryOf(c.err); got != c.want {
+			t.Errorf("CategoryOf(%v) = %q, want %q", c.err, got, c.want)
+		}
+	}
+}
+
+const shapeErrorHEDL = `%VERSION: 1.0
+%STRUCT: User: [id, name]
+---
+users: @User
+  | alice, Alice
+  | bob
+`
+
+func TestValidateRange(t *testing.T) {
+	diag, err := ValidateRange(shapeErrorHEDL, 6, 6, true)
+	if err != nil {
+		t.Fatalf("ValidateRange failed: %v", err)
+	}
+	defer diag.Close()
+
+	errs, err := diag.Errors()
+	if err != nil {
+		t.Fatalf("Errors failed: %v", err)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("Expected 1 error on line 6, got %v", errs)
+	}
+
+	outside, err := ValidateRange(shapeErrorHEDL, 1, 5, true)
+	if err != nil {
+		t.Fatalf("ValidateRange failed: %v", err)
+	}
+	defer outside.Close()
+	if outside.Count() != 0 {
+		t.Fatalf("Expected no diagnostics on lines 1-5, got %d", outside.Count())
+	}
+
+	broken := strings.Replace(shapeErrorHEDL, "[id, name]", "[id, name", 1)
+	header, err := ValidateRange(broken, 5, 5, true)
+	if err != nil {
+		t.Fatalf("ValidateRange failed: %v", err)
+	}
+	defer header.Close()
+	headerDiags, err := header.All()
+	if err != nil {
+		t.Fatalf("All failed: %v", err)
+	}
+	if len(headerDiags) != 1 || headerDiags[0].Line != 2 {
+		t.Fatalf("Expected the header error on line 2, got %v", headerDiags)
+	}
+
+	_, err = ValidateRange(sampleHEDL, 3, 2, true)
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrInvalidArgument {
+		t.Fatalf("Expected ErrInvalidArgument for inverted range, got %v", err)
+	}
+}
+
+// unusedSchemaHEDL parses cleanly but lints with an unused-schema warning.
+const unusedSchemaHEDL = `%VERSION: 1.0
+%STRUCT: User: [id, name]
+%STRUCT: Orphan: [id, label]
+---
+users: @User
+  | alice, Alice
+`
+
+func TestValidateStrict(t *testing.T) {
+	valid, diag, err := ValidateStrict(unusedSchemaHEDL, true, false)
+	if err != nil {
+		t.Fatalf("ValidateStrict failed: %v", err)
+	}
+	defer diag.Close()
+	if !valid {
+		t.Error("Expected warnings alone to pass validation")
+	}
+	warnings, err := diag.Warnings()
+	if err != nil {
+		t.Fatalf("Warnings failed: %v", err)
+	}
+	if len(warnings) == 0 {
+		t.Fatal("Expected an unused-schema warning")
+	}
+
+	valid, gated, err := ValidateStrict(unusedSchemaHEDL, true, true)
+	if err != nil {
+		t.Fatalf("ValidateStrict failed: %v", err)
+	}
+	defer gated.Close()
+	if valid {
+		t.Error("Expected warnings to fail validation with warningsAsErrors")
+	}
+}
+
+func TestValidateStrictParseError(t *testing.T) {
+	valid, diag, err := ValidateStrict(shapeErrorHEDL, true, false)
+	if err != nil {
+		t.Fatalf("ValidateStrict failed: %v", err)
+	}
+	defer diag.Close()
+	if valid {
+		t.Error("Expected invalid content to fail validation")
+	}
+	if errs, _ := diag.Errors(); len(errs) != 1 {
+		t.Errorf("Expected the parse error as a diagnostic, got %v", errs)
+	}
+}
+
+func TestDiagnosticsHints(t *testing.T) {
+	diag := newDiagnostics([]*Diagnostic{
+		newDiagnostic(SeverityHint, "style", "consider a count hint"),
+		newDiagnostic(SeverityWarning, "style", "unused schema"),
+		newDiagnostic(SeverityHint, "style", "consider sorting keys"),
+	})
+
+	hints, err := diag.Hints()
+	if err != nil {
+		t.Fatalf("Hints failed: %v", err)
+	}
+	want := []string{"[style] hint: consider a count hint", "[style] hint: consider sorting keys"}
+	if !reflect.DeepEqual(hints, want) {
+		t.Errorf("Expected %q, got %q", want, hints)
+	}
+}
+
+func TestDiagnosticsBySeverity(t *testing.T) {
+	diag := newDiagnostics([]*Diagnostic{
+		{Message: "hint", Severity: SeverityHint, Line: 1},
+		{Message: "warning at 7", Severity: SeverityWarning, Line: 7},
+		{Message: "error without position", Severity: SeverityError},
+		{Message: "warning at 3:9", Severity: SeverityWarning, Line: 3, Column: 9},
+		{Message: "error at 5", Severity: SeverityError, Line: 5},
+		{Message: "warning at 3:2", Severity: SeverityWarning, Line: 3, Column: 2},
+	})
+
+	matched, err := diag.BySeverity(SeverityWarning)
+	if err != nil {
+		t.Fatalf("BySeverity failed: %v", err)
+	}
+	var got []string
+	for _, d := range matched {
+		got = append(got, d.Message)
+	}
+	want := []string{"error at 5", "error without position", "warning at 3:2", "warning at 3:9", "warning at 7"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	none, err := newDiagnostics([]*Diagnostic{{Message: "hint", Severity: SeverityHint}}).BySeverity(SeverityError)
+	if err != nil {
+		t.Fatalf("BySeverity failed: %v", err)
+	}
+	if none == nil || len(none) != 0 {
+		t.Errorf("Expected an empty slice, got %#v", none)
+	}
+}
+
+func TestDiagnosticPositionAndRule(t *testing.T) {
+	doc, err := Parse(unusedSchemaHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	lint, err := doc.Lint()
+	if err != nil {
+		t.Fatalf("Lint failed: %v", err)
+	}
+	defer lint.Close()
+	all, err := lint.All()
+	if err != nil {
+		t.Fatalf("All failed: %v", err)
+	}
+	found := false
+	for _, d := range all {
+		if d.RuleID == "unused-schema" {
+			found = true
+		}
+		if d.EndLine != d.Line {
+			t.Errorf("Expected a lint diagnostic to end on its line, got %+v", d)
+		}
+	}
+	if !found {
+		t.Errorf("Expected an unused-schema diagnostic, got %+v", all)
+	}
+
+	_, diag, err := ValidateStrict(shapeErrorHEDL, true, false)
+	if err != nil {
+		t.Fatalf("ValidateStrict failed: %v", err)
+	}
+	defer diag.Close()
+	parseDiag, err := diag.Get(0)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if parseDiag.RuleID != "parse" || parseDiag.Line != 6 || parseDiag.EndLine != 6 {
+		t.Errorf("Expected a parse diagnostic on line 6, got %+v", parseDiag)
+	}
+}
+
+func TestDiagnosticLine(t *testing.T) {
+	cases := map[string]int{
+		"line 4: [unused-alias] warning: alias never used":       4,
+		"Parse error: ShapeError at line 12: expected 2 columns": 12,
+		"[id-naming] hint: no position":                          0,
+	}
+	for msg, want := range cases {
+		if got := diagnosticLine(msg); got != want {
+			t.Errorf("diagnosticLine(%q) = %d, want %d", msg, got, want)
+		}
+	}
+}
+
+func TestDocumentAliases(t *testing.T) {
+	doc, err := Parse("%VERSION: 1.0\n%ALIAS: %active: \"true\"\n%ALIAS: %region: \"eu west\"\n---\nname: test\n", true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	aliases, err := doc.Aliases()
+	if err != nil {
+		t.Fatalf("Aliases failed: %v", err)
+	}
+	want := map[string]string{"active": "true", "region": "eu west"}
+	if !reflect.DeepEqual(aliases, want) {
+		t.Errorf("Expected %v, got %v", want, aliases)
+	}
+
+	plain, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer plain.Close()
+
+	aliases, err = plain.Aliases()
+	if err != nil {
+		t.Fatalf("Aliases failed: %v", err)
+	}
+	if aliases == nil || len(aliases) != 0 {
+		t.Errorf("Expected an empty map, got %#v", aliases)
+	}
+}
+
+func TestPartitionBy(t *testing.T) {
+	medium, err := GetGlobalFixtures().MediumHEDL()
+	if err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+	doc, err := Parse(medium, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	partitions, err := doc.PartitionBy("Employee", "dept")
+	if err != nil {
+		t.Fatalf("PartitionBy failed: %v", err)
+	}
+	if len(partitions) != 10 {
+		t.Errorf("Expected 10 partitions, got %d", len(partitions))
+	}
+	for dept, partition := range partitions {
+		rows, err := partition.RowHashes("Employee")
+		if err != nil {
+			t.Fatalf("RowHashes failed for %s: %v", dept, err)
+		}
+		if len(rows) != 5 {
+			t.Errorf("Expected 5 rows in %s, got %d", dept, len(rows))
+		}
+		stats, err := partition.FieldStats("Employee")
+		if err != nil {
+			t.Fatalf("FieldStats failed for %s: %v", dept, err)
+		}
+		if stats[2].DistinctCount != 1 {
+			t.Errorf("Expected a single department in %s, got %d", dept, stats[2].DistinctCount)
+		}
+		partition.Close()
+	}
+
+	if _, ok := partitions[`"Engineering"`]; !ok {
+		t.Errorf("Expected a quoted \"Engineering\" key, got %v", partitions)
+	}
+
+	for _, names := range [][2]string{{"Employee", "region"}, {"Contractor", "dept"}} {
+		_, err = doc.PartitionBy(names[0], names[1])
+		var hedlErr *HedlError
+		if !errors.As(err, &hedlErr) || hedlErr.Code != ErrNotFound {
+			t.Errorf("Expected ErrNotFound for %s.%s, got %v", names[0], names[1], err)
+		}
+	}
+}
+
+func TestPartitionByTypedKeys(t *testing.T) {
+	doc, err := Parse("%VERSION: 1.0\n%STRUCT: Item: [id, tag]\n---\nitems: @Item\n"+
+		"  | a, ~\n  | b, \"~\"\n  | c, \"\"\n  | d, true\n  | e, \"true\"\n", true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	partitions, err := doc.PartitionBy("Item", "tag")
+	if err != nil {
+		t.Fatalf("PartitionBy failed: %v", err)
+	}
+	want := map[string]string{`~`: "a", `"~"`: "b", `""`: "c", `true`: "d", `"true"`: "e"}
+	if len(partitions) != len(want) {
+		t.Errorf("Expected %d partitions, got %d", len(want), len(partitions))
+	}
+	for key, partition := range partitions {
+		rows, err := partition.RowHashes("Item")
+		if err != nil {
+			t.Fatalf("RowHashes failed for %s: %v", key, err)
+		}
+		if _, ok := rows[want[key]]; len(rows) != 1 || !ok {
+			t.Errorf("Expected partition %s to hold row %s, got %v", key, want[key], rows)
+		}
+		partition.Close()
+	}
+}
+
+func TestLargestValues(t *testing.T) {
+	medium, err := GetGlobalFixtures().MediumHEDL()
+	if err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+	doc, err := Parse(medium+"notes: \""+strings.Repeat("x", 200)+"\"\n", true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	values, err := doc.LargestValues(10)
+	if err != nil {
+		t.Fatalf("LargestValues failed: %v", err)
+	}
+	if len(values) != 10 {
+		t.Fatalf("Expected 10 values, got %d", len(values))
+	}
+	for i := 1; i < len(values); i++ {
+		if values[i].Bytes > values[i-1].Bytes {
+			t.Errorf("Values not sorted by size: %+v before %+v", values[i-1], values[i])
+		}
+	}
+	want := ValueLocation{Field: "notes", RowIndex: -1, Bytes: 200}
+	if values[0] != want {
+		t.Errorf("Expected %+v first, got %+v", want, values[0])
+	}
+	if values[1].Schema != "Employee" {
+		t.Errorf("Expected Employee values after notes, got %+v", values[1])
+	}
+
+	_, err = doc.LargestValues(0)
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrInvalidArgument {
+		t.Errorf("Expected ErrInvalidArgument for n = 0, got %v", err)
+	}
+}
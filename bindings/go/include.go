@@ -0,0 +1,98 @@
+package hedl
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const includeDirective = "%INCLUDE:"
+
+// ParseWithIncludes parses the HEDL file at path, resolving
+// "%INCLUDE: other.hedl" header directives first.
+//
+// Include paths are resolved relative to the file that contains them and are
+// followed recursively. An included file contributes its header directives
+// (other than %VERSION) in place of the directive and its body ahead of the
+// including file's body. An include cycle is reported as a HedlError with
+// code ErrCyclicReference, and errors reading the file or any file it
+// includes as a HedlError with code ErrIO.
+func ParseWithIncludes(path string, strict bool) (*Document, error) {
+	header, body, err := expandIncludes(path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	for _, line := range header {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	b.WriteString("---\n")
+	for _, line := range body {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return Parse(b.String(), strict)
+}
+
+// expandIncludes reads the file at path and returns its header and body lines
+// with include directives replaced by the files they name. stack holds the
+// absolute paths of the files currently being expanded.
+func expandIncludes(path string, stack []string) ([]string, []string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, nil, newIOError(err)
+	}
+	for _, seen := range stack {
+		if seen == abs {
+			return nil, nil, &HedlError{
+				Message: fmt.Sprintf("include cycle: %s", strings.Join(append(stack, abs), " -> ")),
+				Code:    ErrCyclicReference,
+			}
+		}
+	}
+	stack = append(stack, abs)
+
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, nil, newIOError(err)
+	}
+
+	var header, body, included []string
+	inHeader := true
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if !inHeader {
+			body = append(body, line)
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "---":
+			inHeader = false
+		case strings.HasPrefix(trimmed, includeDirective):
+			target := strings.TrimSpace(strings.TrimPrefix(trimmed, includeDirective))
+			if unquoted, err := strconv.Unquote(target); err == nil {
+				target = unquoted
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(filepath.Dir(abs), target)
+			}
+			childHeader, childBody, err := expandIncludes(target, stack)
+			if err != nil {
+				return nil, nil, err
+			}
+			header = append(header, childHeader...)
+			included = append(included, childBody...)
+		case len(stack) > 1 && strings.HasPrefix(trimmed, "%VERSION:"):
+			// Only the outermost file's version applies.
+		default:
+			header = append(header, line)
+		}
+	}
+	return header, append(included, body...), nil
+}
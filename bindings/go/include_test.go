@@ -0,0 +1,74 @@
+package hedl
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestParseWithIncludes(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "schema.hedl", "%VERSION: 1.0\n%STRUCT: User: [id, name]\n---\n")
+	base := writeFile(t, dir, "base.hedl", `%VERSION: 1.0
+%INCLUDE: schema.hedl
+---
+users: @User
+  | alice, Alice Smith
+  | bob, Bob Jones
+`)
+
+	doc, err := ParseWithIncludes(base, true)
+	if err != nil {
+		t.Fatalf("ParseWithIncludes failed: %v", err)
+	}
+	defer doc.Close()
+
+	count, err := doc.SchemaCount()
+	if err != nil {
+		t.Fatalf("SchemaCount failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 schema from the included file, got %d", count)
+	}
+}
+
+func TestParseWithIncludesCycle(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "a.hedl", "%VERSION: 1.0\n%INCLUDE: b.hedl\n---\n")
+	writeFile(t, dir, "b.hedl", "%VERSION: 1.0\n%INCLUDE: a.hedl\n---\n")
+
+	doc, err := ParseWithIncludes(filepath.Join(dir, "a.hedl"), true)
+	if err == nil {
+		doc.Close()
+		t.Fatal("Expected error for include cycle")
+	}
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrCyclicReference {
+		t.Errorf("Expected ErrCyclicReference, got %v", err)
+	}
+}
+
+func TestParseWithIncludesMissingFile(t *testing.T) {
+	dir := t.TempDir()
+	base := writeFile(t, dir, "base.hedl", "%VERSION: 1.0\n%INCLUDE: missing.hedl\n---\n")
+
+	doc, err := ParseWithIncludes(base, true)
+	if err == nil {
+		doc.Close()
+		t.Fatal("Expected error for a missing include")
+	}
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrIO {
+		t.Errorf("Expected ErrIO, got %v", err)
+	}
+}
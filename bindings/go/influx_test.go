@@ -0,0 +1,49 @@
+package hedl
+
+import (
+	"errors"
+	"testing"
+)
+
+// sensorReadingsHEDL mixes a nanosecond timestamp with an RFC 3339 one and
+// ints with floats in the temp column.
+const sensorReadingsHEDL = `%VERSION: 1.0
+%STRUCT: Reading: [id, sensor, site, temp, ok, time]
+---
+readings: @Reading
+  | r1, s1, north, 21.5, true, 1700000000000000000
+  | r2, s2, "south east", 19, false, "2024-01-02T03:04:05Z"
+`
+
+func TestToInfluxLineProtocol(t *testing.T) {
+	doc, err := Parse(sensorReadingsHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	lines, err := doc.ToInfluxLineProtocol("weather", "time", []string{"sensor", "site"})
+	if err != nil {
+		t.Fatalf("ToInfluxLineProtocol failed: %v", err)
+	}
+	want := `weather,sensor=s1,site=north id="r1",temp=21.5,ok=true 1700000000000000000
+weather,sensor=s2,site=south\ east id="r2",temp=19,ok=false 1704164645000000000
+`
+	if lines != want {
+		t.Errorf("Unexpected line protocol:\ngot:\n%s\nwant:\n%s", lines, want)
+	}
+}
+
+func TestToInfluxLineProtocolMissingTimeField(t *testing.T) {
+	doc, err := Parse(sensorReadingsHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	_, err = doc.ToInfluxLineProtocol("weather", "timestamp", nil)
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for a missing time field, got %v", err)
+	}
+}
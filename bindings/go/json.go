@@ -0,0 +1,165 @@
+package hedl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ToJSONChunks splits the rows of schemaName into JSON arrays of at most
+// maxBytes bytes each, in document order and in the row shape used by
+// ToJSONPage. Chunks break between rows, so concatenating their elements
+// gives every row exactly once; a schema without rows yields no chunks.
+//
+// maxBytes below 2, too small for "[]", returns an ErrInvalidArgument error
+// and a row too large for a chunk on its own returns an ErrAlloc error.
+func (d *Document) ToJSONChunks(schemaName string, maxBytes int) ([]string, error) {
+	if maxBytes < 2 {
+		return nil, &HedlError{
+			Message: fmt.Sprintf("chunk size %d is too small", maxBytes),
+			Code:    ErrInvalidArgument,
+		}
+	}
+	m, err := d.model()
+	if err != nil {
+		return nil, err
+	}
+	lists, err := m.listsOf(schemaName)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := []string{}
+	var chunk, row bytes.Buffer
+	flush := func() {
+		if chunk.Len() > 0 {
+			chunk.WriteByte(']')
+			chunks = append(chunks, chunk.String())
+			chunk.Reset()
+		}
+	}
+	index := 0
+	for _, list := range lists {
+		for _, r := range list.rows {
+			row.Reset()
+			if err := writeJSON(&row, rowObject(list, r)); err != nil {
+				return nil, err
+			}
+			if row.Len()+2 > maxBytes {
+				return nil, &HedlError{
+					Message: fmt.Sprintf("%s row %d (%d bytes) does not fit in a %d-byte chunk", schemaName, index, row.Len(), maxBytes),
+					Code:    ErrAlloc,
+				}
+			}
+			// The row needs a separator (or the opening bracket) before it
+			// and the closing bracket after it.
+			if chunk.Len() > 0 && chunk.Len()+1+row.Len()+1 > maxBytes {
+				flush()
+			}
+			if chunk.Len() == 0 {
+				chunk.WriteByte('[')
+			} else {
+				chunk.WriteByte(',')
+			}
+			chunk.Write(row.Bytes())
+			index++
+		}
+	}
+	flush()
+	return chunks, nil
+}
+
+// clamp limits n to the range [lo, hi].
+func clamp(n, lo, hi int) int {
+	if n < lo {
+		return lo
+	}
+	if n > hi {
+		return hi
+	}
+	return n
+}
+
+// rowObject converts a row to its JSON form, as produced by the native JSON
+// export without metadata.
+func rowObject(list *matrixList, row *matrixRow) *object {
+	obj := newObject()
+	for i, col := range list.schema {
+		if i < len(row.values) {
+			obj.set(col, jsonValue(row.values[i]))
+		}
+	}
+	for _, child := range row.children {
+		items := make([]interface{}, 0, len(child.rows))
+		for _, childRow := range child.rows {
+			items = append(items, rowObject(child, childRow))
+		}
+		obj.set(child.typeName, items)
+	}
+	return obj
+}
+
+// jsonValue converts a model scalar to its JSON form.
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case reference:
+		ref := newObject()
+		ref.set("@ref", string(v))
+		return ref
+	case expression:
+		return string(v)
+	}
+	return value
+}
+
+// writeJSON writes a decoded JSON value compactly, keeping object key order.
+func writeJSON(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case *object:
+		buf.WriteByte('{')
+		for i, key := range v.keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSONString(buf, key); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if err := writeJSON(buf, v.values[key]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case string:
+		return writeJSONString(buf, v)
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+	}
+	return nil
+}
+
+// writeJSONString writes s as a JSON string without HTML escaping.
+func writeJSONString(buf *bytes.Buffer, s string) error {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		return err
+	}
+	buf.Truncate(buf.Len() - 1) // Encode appends a newline
+	return nil
+}
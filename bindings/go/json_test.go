@@ -0,0 +1,329 @@
+package hedl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestToJSONPage(t *testing.T) {
+	fixtures := GetGlobalFixtures()
+	large, err := fixtures.LargeHEDL()
+	if err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+	doc, err := Parse(large, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	page, err := doc.ToJSONPage("Order", 5, 10)
+	if err != nil {
+		t.Fatalf("ToJSONPage failed: %v", err)
+	}
+	var rows []map[string]interface{}
+	if err := json.Unmarshal([]byte(page), &rows); err != nil {
+		t.Fatalf("Page is not a JSON array: %v\n%s", err, page)
+	}
+	if len(rows) != 10 {
+		t.Fatalf("Expected 10 rows, got %d", len(rows))
+	}
+	if id := rows[0]["order_id"]; id != "o1006" {
+		t.Errorf("Expected first order_id 1006, got %v", id)
+	}
+
+	tests := []struct {
+		offset, limit, want int
+	}{
+		{15, 10, 5},
+		{-3, 2, 2},
+		{100, 10, 0},
+		{0, -1, 0},
+	}
+	for _, tt := range tests {
+		page, err := doc.ToJSONPage("Order", tt.offset, tt.limit)
+		if err != nil {
+			t.Fatalf("ToJSONPage(%d, %d) failed: %v", tt.offset, tt.limit, err)
+		}
+		var rows []interface{}
+		if err := json.Unmarshal([]byte(page), &rows); err != nil {
+			t.Fatalf("ToJSONPage(%d, %d) is not a JSON array: %v", tt.offset, tt.limit, err)
+		}
+		if len(rows) != tt.want {
+			t.Errorf("ToJSONPage(%d, %d): expected %d rows, got %d", tt.offset, tt.limit, tt.want, len(rows))
+		}
+	}
+
+	_, err = doc.ToJSONPage("Invoice", 0, 10)
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for an unknown schema, got %v", err)
+	}
+}
+
+func TestIndexBy(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	index, err := doc.IndexBy("User", "id")
+	if err != nil {
+		t.Fatalf("IndexBy failed: %v", err)
+	}
+	if len(index) != 2 {
+		t.Fatalf("Expected 2 keys, got %d", len(index))
+	}
+	var bob map[string]interface{}
+	if err := json.Unmarshal([]byte(index["bob"]), &bob); err != nil {
+		t.Fatalf("Row is not a JSON object: %v\n%s", err, index["bob"])
+	}
+	if bob["name"] != "Bob Jones" || bob["email"] != "bob@example.com" {
+		t.Errorf("Unexpected row for bob: %v", bob)
+	}
+}
+
+func TestIndexByDuplicates(t *testing.T) {
+	doc, err := Parse(incompleteRowsHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	_, err = doc.IndexBy("User", "email")
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrConflict {
+		t.Fatalf("Expected ErrConflict for duplicate emails, got %v", err)
+	}
+
+	index, err := doc.IndexByWithOptions("User", "email", ReKeyOptions{AllowDuplicates: true})
+	if err != nil {
+		t.Fatalf("IndexByWithOptions failed: %v", err)
+	}
+	var row map[string]interface{}
+	if err := json.Unmarshal([]byte(index["alice@example.com"]), &row); err != nil {
+		t.Fatalf("Row is not a JSON object: %v", err)
+	}
+	if row["id"] != "carol" {
+		t.Errorf("Expected the last duplicate (carol) to win, got %v", row["id"])
+	}
+}
+
+func TestIndexByMixedSchemas(t *testing.T) {
+	doc, err := Parse(mixedUserListsHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	index, err := doc.IndexByWithOptions("User", "name", ReKeyOptions{AllowDuplicates: true})
+	if err != nil {
+		t.Fatalf("IndexByWithOptions failed: %v", err)
+	}
+	if len(index) != 1 || !strings.Contains(index["Alice"], `"u2"`) {
+		t.Errorf("Expected only the named rows to be indexed, got %v", index)
+	}
+}
+
+func TestGetByKey(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	row, err := doc.GetByKey("User", "id", "alice")
+	if err != nil {
+		t.Fatalf("GetByKey failed: %v", err)
+	}
+	var alice map[string]interface{}
+	if err := json.Unmarshal([]byte(row), &alice); err != nil {
+		t.Fatalf("Row is not a JSON object: %v\n%s", err, row)
+	}
+	if alice["name"] != "Alice Smith" {
+		t.Errorf("Unexpected row for alice: %v", alice)
+	}
+
+	for _, lookup := range [][3]string{{"User", "id", "mallory"}, {"User", "login", "alice"}, {"Admin", "id", "alice"}} {
+		_, err = doc.GetByKey(lookup[0], lookup[1], lookup[2])
+		var hedlErr *HedlError
+		if !errors.As(err, &hedlErr) || hedlErr.Code != ErrNotFound {
+			t.Errorf("Expected ErrNotFound for %v, got %v", lookup, err)
+		}
+	}
+}
+
+func TestGetByKeyDuplicates(t *testing.T) {
+	doc, err := Parse(incompleteRowsHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	_, err = doc.GetByKey("User", "email", "alice@example.com")
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrConflict {
+		t.Fatalf("Expected ErrConflict for a duplicate email, got %v", err)
+	}
+
+	row, err := doc.GetByKeyWithOptions("User", "email", "alice@example.com", LookupOptions{FirstWins: true})
+	if err != nil {
+		t.Fatalf("GetByKeyWithOptions failed: %v", err)
+	}
+	var first map[string]interface{}
+	if err := json.Unmarshal([]byte(row), &first); err != nil {
+		t.Fatalf("Row is not a JSON object: %v", err)
+	}
+	if first["id"] != "alice" {
+		t.Errorf("Expected the first duplicate (alice) to win, got %v", first["id"])
+	}
+}
+
+func TestToJSONChunks(t *testing.T) {
+	fixtures := GetGlobalFixtures()
+	large, err := fixtures.LargeHEDL()
+	if err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+	doc, err := Parse(large, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	const maxBytes = 300
+	chunks, err := doc.ToJSONChunks("Order", maxBytes)
+	if err != nil {
+		t.Fatalf("ToJSONChunks failed: %v", err)
+	}
+	if len(chunks) < 2 {
+		t.Fatalf("Expected several chunks, got %d", len(chunks))
+	}
+
+	var all []map[string]interface{}
+	for i, chunk := range chunks {
+		if len(chunk) > maxBytes {
+			t.Errorf("Chunk %d is %d bytes, over the %d-byte limit", i, len(chunk), maxBytes)
+		}
+		var rows []map[string]interface{}
+		if err := json.Unmarshal([]byte(chunk), &rows); err != nil {
+			t.Fatalf("Chunk %d is not a JSON array: %v\n%s", i, err, chunk)
+		}
+		all = append(all, rows...)
+	}
+	if len(all) != 20 {
+		t.Fatalf("Expected 20 rows across chunks, got %d", len(all))
+	}
+	for i, row := range all {
+		if want := fmt.Sprintf("o%d", 1001+i); row["order_id"] != want {
+			t.Errorf("Row %d: expected order_id %s, got %v", i, want, row["order_id"])
+		}
+	}
+}
+
+func TestToJSONChunksRowTooLarge(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	_, err = doc.ToJSONChunks("User", 10)
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrAlloc {
+		t.Errorf("Expected ErrAlloc for a row larger than the chunk, got %v", err)
+	}
+}
+
+func TestToGroupedJSON(t *testing.T) {
+	fixtures := GetGlobalFixtures()
+	medium, err := fixtures.MediumHEDL()
+	if err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+	doc, err := Parse(medium, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	stats, err := doc.FieldStats("Employee")
+	if err != nil {
+		t.Fatalf("FieldStats failed: %v", err)
+	}
+	grouped, err := doc.ToGroupedJSON("Employee", "dept")
+	if err != nil {
+		t.Fatalf("ToGroupedJSON failed: %v", err)
+	}
+	var groups map[string][]map[string]interface{}
+	if err := json.Unmarshal([]byte(grouped), &groups); err != nil {
+		t.Fatalf("Output is not an object of row arrays: %v", err)
+	}
+	if len(groups) != stats[2].DistinctCount {
+		t.Errorf("Expected %d groups, got %d", stats[2].DistinctCount, len(groups))
+	}
+	rows := 0
+	for dept, group := range groups {
+		for _, row := range group {
+			if row["dept"] != dept {
+				t.Errorf("Row %v grouped under %q", row, dept)
+			}
+		}
+		rows += len(group)
+	}
+	if rows != stats[2].NonNullCount {
+		t.Errorf("Expected %d rows across groups, got %d", stats[2].NonNullCount, rows)
+	}
+
+	for _, args := range [][2]string{{"Employee", "missing"}, {"Missing", "dept"}} {
+		_, err = doc.ToGroupedJSON(args[0], args[1])
+		var hedlErr *HedlError
+		if !errors.As(err, &hedlErr) || hedlErr.Code != ErrNotFound {
+			t.Errorf("ToGroupedJSON(%s, %s): expected ErrNotFound, got %v", args[0], args[1], err)
+		}
+	}
+}
+
+func TestExampleJSON(t *testing.T) {
+	doc, err := Parse(unusedSchemaHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	example, err := doc.ExampleJSON()
+	if err != nil {
+		t.Fatalf("ExampleJSON failed: %v", err)
+	}
+	var objects map[string]map[string]interface{}
+	if err := json.Unmarshal([]byte(example), &objects); err != nil {
+		t.Fatalf("Output is not an object per schema: %v\n%s", err, example)
+	}
+
+	schemas := map[string][]string{
+		"User":   {"id", "name"},
+		"Orphan": {"id", "label"},
+	}
+	if len(objects) != len(schemas) {
+		t.Errorf("Expected %d example objects, got %d", len(schemas), len(objects))
+	}
+	for name, fields := range schemas {
+		object, ok := objects[name]
+		if !ok {
+			t.Errorf("Missing example for %s", name)
+			continue
+		}
+		for _, field := range fields {
+			if _, ok := object[field]; !ok {
+				t.Errorf("Example for %s is missing field %q", name, field)
+			}
+		}
+	}
+	if objects["User"]["name"] != "Alice" || objects["Orphan"]["label"] != "example" {
+		t.Errorf("Unexpected example values: %v", objects)
+	}
+}
@@ -0,0 +1,455 @@
+package hedl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// FromJSONValidated validates content against jsonSchema and, when it
+// conforms, converts it like FromJSON. Violations are returned as error
+// diagnostics with the rule "json-schema", located by the JSON Pointer of
+// the offending value, and no document is built.
+//
+// The validator covers the structural keywords of JSON Schema: type, enum,
+// const, the numeric, string, array and object bounds, pattern, properties,
+// required, additionalProperties, items, uniqueItems, allOf, anyOf, oneOf
+// and not. exclusiveMinimum and exclusiveMaximum may be numbers or, as in
+// draft 4, booleans applying to minimum and maximum. Annotations such as
+// title, description and format are ignored, and patterns use Go regexp
+// syntax rather than ECMA-262.
+// Keywords it cannot enforce, such as $ref, return an ErrInvalidArgument
+// error rather than being skipped, as does a schema that is not valid JSON.
+// Content that is not valid JSON returns an ErrJSON error.
+func FromJSONValidated(content string, jsonSchema string) (*Document, *Diagnostics, error) {
+	schema, err := decodeJSONDocument(jsonSchema)
+	if err != nil {
+		return nil, nil, &HedlError{
+			Message: fmt.Sprintf("invalid JSON Schema: %v", err),
+			Code:    ErrInvalidArgument,
+		}
+	}
+	instance, err := decodeJSONDocument(content)
+	if err != nil {
+		return nil, nil, &HedlError{
+			Message: fmt.Sprintf("invalid JSON: %v", err),
+			Code:    ErrJSON,
+		}
+	}
+
+	v := &schemaValidator{}
+	if err := v.validate(schema, instance, ""); err != nil {
+		return nil, nil, err
+	}
+	if len(v.items) > 0 {
+		return nil, newDiagnostics(v.items), nil
+	}
+
+	doc, err := FromJSON(content)
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc, newDiagnostics(nil), nil
+}
+
+// decodeJSONDocument decodes a single JSON value, keeping numbers as
+// json.Number.
+func decodeJSONDocument(data string) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(data))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the top-level value")
+	}
+	return value, nil
+}
+
+// unsupportedSchemaKeywords affect validation but are not implemented.
+var unsupportedSchemaKeywords = []string{
+	"$ref", "$dynamicRef", "$recursiveRef", "additionalItems", "contains",
+	"dependencies", "dependentRequired", "dependentSchemas", "else", "if",
+	"multipleOf", "patternProperties", "prefixItems", "propertyNames", "then",
+	"unevaluatedItems", "unevaluatedProperties",
+}
+
+// schemaValidator collects the diagnostics of a JSON Schema validation.
+type schemaValidator struct {
+	items []*Diagnostic
+}
+
+func (v *schemaValidator) fail(path, format string, args ...interface{}) {
+	location := path
+	if location == "" {
+		location = "/"
+	}
+	v.items = append(v.items, newDiagnostic(SeverityError, "json-schema",
+		"%s: %s", location, fmt.Sprintf(format, args...)))
+}
+
+// matches reports whether instance conforms to schema, without recording
+// diagnostics.
+func (v *schemaValidator) matches(schema, instance interface{}, path string) (bool, error) {
+	sub := &schemaValidator{}
+	if err := sub.validate(schema, instance, path); err != nil {
+		return false, err
+	}
+	return len(sub.items) == 0, nil
+}
+
+// validate checks instance, found at the JSON Pointer path, against schema.
+// Errors are reserved for schemas the validator cannot apply.
+func (v *schemaValidator) validate(schema, instance interface{}, path string) error {
+	switch s := schema.(type) {
+	case bool:
+		if !s {
+			v.fail(path, "no value is allowed here")
+		}
+		return nil
+	case map[string]interface{}:
+		return v.validateObject(s, instance, path)
+	}
+	return &HedlError{
+		Message: fmt.Sprintf("schema at %q must be an object or a boolean", path),
+		Code:    ErrInvalidArgument,
+	}
+}
+
+func (v *schemaValidator) validateObject(s map[string]interface{}, instance interface{}, path string) error {
+	for _, keyword := range unsupportedSchemaKeywords {
+		if _, ok := s[keyword]; ok {
+			return &HedlError{
+				Message: fmt.Sprintf("JSON Schema keyword %q is not supported", keyword),
+				Code:    ErrInvalidArgument,
+			}
+		}
+	}
+
+	if types, ok := s["type"]; ok && !matchesSchemaType(types, instance) {
+		v.fail(path, "expected type %s, got %s", describeSchemaType(types), jsonTypeName(instance))
+		return nil
+	}
+	if options, ok := s["enum"].([]interface{}); ok {
+		found := false
+		for _, option := range options {
+			if jsonEqual(option, instance) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.fail(path, "value %s is not one of the allowed values", jsonText(instance))
+		}
+	}
+	if constant, ok := s["const"]; ok && !jsonEqual(constant, instance) {
+		v.fail(path, "value %s does not equal %s", jsonText(instance), jsonText(constant))
+	}
+
+	switch value := instance.(type) {
+	case json.Number:
+		v.checkNumber(s, value, path)
+	case string:
+		if err := v.checkString(s, value, path); err != nil {
+			return err
+		}
+	case []interface{}:
+		if err := v.checkArray(s, value, path); err != nil {
+			return err
+		}
+	case map[string]interface{}:
+		if err := v.checkProperties(s, value, path); err != nil {
+			return err
+		}
+	}
+
+	return v.checkCombinators(s, instance, path)
+}
+
+func (v *schemaValidator) checkNumber(s map[string]interface{}, value json.Number, path string) {
+	n, err := value.Float64()
+	if err != nil {
+		return
+	}
+	bound := func(keyword string, violated func(n, limit float64) bool, relation string) {
+		limit, ok := s[keyword].(json.Number)
+		if !ok {
+			return
+		}
+		if l, err := limit.Float64(); err == nil && violated(n, l) {
+			v.fail(path, "value %s is %s %s", value, relation, limit)
+		}
+	}
+	// Draft 4 spells exclusive bounds as booleans that make minimum and
+	// maximum exclusive; later drafts give the limit itself.
+	if exclusive, _ := s["exclusiveMinimum"].(bool); exclusive {
+		bound("minimum", func(n, l float64) bool { return n <= l }, "not greater than the exclusive minimum")
+	} else {
+		bound("minimum", func(n, l float64) bool { return n < l }, "less than the minimum")
+	}
+	if exclusive, _ := s["exclusiveMaximum"].(bool); exclusive {
+		bound("maximum", func(n, l float64) bool { return n >= l }, "not less than the exclusive maximum")
+	} else {
+		bound("maximum", func(n, l float64) bool { return n > l }, "greater than the maximum")
+	}
+	bound("exclusiveMinimum", func(n, l float64) bool { return n <= l }, "not greater than")
+	bound("exclusiveMaximum", func(n, l float64) bool { return n >= l }, "not less than")
+}
+
+func (v *schemaValidator) checkString(s map[string]interface{}, value string, path string) error {
+	length := utf8.RuneCountInString(value)
+	if limit, ok := schemaInt(s, "minLength"); ok && length < limit {
+		v.fail(path, "string is shorter than %d characters", limit)
+	}
+	if limit, ok := schemaInt(s, "maxLength"); ok && length > limit {
+		v.fail(path, "string is longer than %d characters", limit)
+	}
+	if pattern, ok := s["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return &HedlError{
+				Message: fmt.Sprintf("invalid pattern %q in JSON Schema: %v", pattern, err),
+				Code:    ErrInvalidArgument,
+			}
+		}
+		if !re.MatchString(value) {
+			v.fail(path, "string %q does not match pattern %q", value, pattern)
+		}
+	}
+	return nil
+}
+
+func (v *schemaValidator) checkArray(s map[string]interface{}, value []interface{}, path string) error {
+	if limit, ok := schemaInt(s, "minItems"); ok && len(value) < limit {
+		v.fail(path, "array has fewer than %d items", limit)
+	}
+	if limit, ok := schemaInt(s, "maxItems"); ok && len(value) > limit {
+		v.fail(path, "array has more than %d items", limit)
+	}
+	if unique, _ := s["uniqueItems"].(bool); unique {
+	search:
+		for i := range value {
+			for j := 0; j < i; j++ {
+				if jsonEqual(value[i], value[j]) {
+					v.fail(path, "items %d and %d are equal", j, i)
+					break search
+				}
+			}
+		}
+	}
+	if items, ok := s["items"]; ok {
+		for i, item := range value {
+			if err := v.validate(items, item, fmt.Sprintf("%s/%d", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v *schemaValidator) checkProperties(s map[string]interface{}, value map[string]interface{}, path string) error {
+	if limit, ok := schemaInt(s, "minProperties"); ok && len(value) < limit {
+		v.fail(path, "object has fewer than %d properties", limit)
+	}
+	if limit, ok := schemaInt(s, "maxProperties"); ok && len(value) > limit {
+		v.fail(path, "object has more than %d properties", limit)
+	}
+	if required, ok := s["required"].([]interface{}); ok {
+		for _, name := range required {
+			if key, ok := name.(string); ok {
+				if _, present := value[key]; !present {
+					v.fail(path, "missing required property %q", key)
+				}
+			}
+		}
+	}
+
+	properties, _ := s["properties"].(map[string]interface{})
+	additional, hasAdditional := s["additionalProperties"]
+	keys := make([]string, 0, len(value))
+	for key := range value {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		child := path + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+		if sub, ok := properties[key]; ok {
+			if err := v.validate(sub, value[key], child); err != nil {
+				return err
+			}
+			continue
+		}
+		if !hasAdditional {
+			continue
+		}
+		if allowed, ok := additional.(bool); ok && !allowed {
+			v.fail(path, "property %q is not allowed", key)
+			continue
+		}
+		if err := v.validate(additional, value[key], child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *schemaValidator) checkCombinators(s map[string]interface{}, instance interface{}, path string) error {
+	if all, ok := s["allOf"].([]interface{}); ok {
+		for _, sub := range all {
+			if err := v.validate(sub, instance, path); err != nil {
+				return err
+			}
+		}
+	}
+	for _, keyword := range []string{"anyOf", "oneOf"} {
+		options, ok := s[keyword].([]interface{})
+		if !ok {
+			continue
+		}
+		matched := 0
+		for _, sub := range options {
+			ok, err := v.matches(sub, instance, path)
+			if err != nil {
+				return err
+			}
+			if ok {
+				matched++
+			}
+		}
+		switch {
+		case matched == 0:
+			v.fail(path, "value does not match any schema in %s", keyword)
+		case keyword == "oneOf" && matched > 1:
+			v.fail(path, "value matches %d schemas in oneOf, expected exactly 1", matched)
+		}
+	}
+	if not, ok := s["not"]; ok {
+		ok, err := v.matches(not, instance, path)
+		if err != nil {
+			return err
+		}
+		if ok {
+			v.fail(path, "value must not match the schema in not")
+		}
+	}
+	return nil
+}
+
+// schemaInt returns a non-negative integer keyword of s.
+func schemaInt(s map[string]interface{}, keyword string) (int, bool) {
+	n, ok := s[keyword].(json.Number)
+	if !ok {
+		return 0, false
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return 0, false
+	}
+	return int(f), true
+}
+
+// matchesSchemaType reports whether instance has one of the JSON Schema
+// types named by types, a string or an array of strings.
+func matchesSchemaType(types, instance interface{}) bool {
+	names, ok := types.([]interface{})
+	if !ok {
+		names = []interface{}{types}
+	}
+	actual := jsonTypeName(instance)
+	for _, name := range names {
+		if name == actual || (name == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// describeSchemaType formats the type keyword for messages.
+func describeSchemaType(types interface{}) string {
+	names, ok := types.([]interface{})
+	if !ok {
+		return fmt.Sprint(types)
+	}
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprint(name)
+	}
+	return strings.Join(parts, " or ")
+}
+
+// jsonTypeName returns the JSON Schema type of a decoded value; numbers with
+// an integral value are "integer".
+func jsonTypeName(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case json.Number:
+		if f, err := v.Float64(); err == nil && f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	}
+	return "unknown"
+}
+
+// jsonEqual compares two decoded values, numbers by value.
+func jsonEqual(a, b interface{}) bool {
+	if an, ok := a.(json.Number); ok {
+		bn, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, errX := an.Float64()
+		y, errY := bn.Float64()
+		return errX == nil && errY == nil && x == y
+	}
+	switch av := a.(type) {
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !jsonEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for key, value := range av {
+			other, ok := bv[key]
+			if !ok || !jsonEqual(value, other) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// jsonText renders a decoded value as compact JSON for messages.
+func jsonText(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
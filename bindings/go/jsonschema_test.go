@@ -0,0 +1,97 @@
+package hedl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const usersJSONSchema = `{
+	"type": "object",
+	"required": ["users"],
+	"properties": {
+		"users": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"required": ["id", "email"],
+				"properties": {
+					"id": {"type": "string"},
+					"email": {"type": "string", "pattern": "@"}
+				}
+			}
+		}
+	}
+}`
+
+func TestFromJSONValidated(t *testing.T) {
+	doc, diag, err := FromJSONValidated(`{"users": [{"id": "alice", "email": "alice@example.com"}]}`, usersJSONSchema)
+	if err != nil {
+		t.Fatalf("FromJSONValidated failed: %v", err)
+	}
+	defer doc.Close()
+	if diag.Count() != 0 {
+		t.Errorf("Expected no diagnostics for conforming JSON, got %d", diag.Count())
+	}
+}
+
+func TestFromJSONValidatedViolation(t *testing.T) {
+	doc, diag, err := FromJSONValidated(`{"users": [{"id": "alice"}, {"id": 7, "email": "bob"}]}`, usersJSONSchema)
+	if err != nil {
+		t.Fatalf("FromJSONValidated failed: %v", err)
+	}
+	if doc != nil {
+		doc.Close()
+		t.Fatal("Expected no document for JSON violating the schema")
+	}
+	defer diag.Close()
+
+	errs, err := diag.Errors()
+	if err != nil {
+		t.Fatalf("Errors failed: %v", err)
+	}
+	want := []string{
+		`/users/0: missing required property "email"`,
+		`/users/1/email: string "bob" does not match pattern "@"`,
+		`/users/1/id: expected type string, got integer`,
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("Expected %d diagnostics, got %v", len(want), errs)
+	}
+	for i := range want {
+		if !strings.Contains(errs[i], want[i]) {
+			t.Errorf("Diagnostic %d: expected %q, got %q", i, want[i], errs[i])
+		}
+	}
+}
+
+func TestFromJSONValidatedUnsupported(t *testing.T) {
+	_, _, err := FromJSONValidated(`{}`, `{"$ref": "#/definitions/user"}`)
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrInvalidArgument {
+		t.Errorf("Expected ErrInvalidArgument for $ref, got %v", err)
+	}
+}
+
+func TestFromJSONValidatedExclusiveBounds(t *testing.T) {
+	schemas := map[string]string{
+		"draft 4":  `{"properties": {"n": {"minimum": 0, "exclusiveMinimum": true, "maximum": 10, "exclusiveMaximum": true}}}`,
+		"draft 6+": `{"properties": {"n": {"exclusiveMinimum": 0, "exclusiveMaximum": 10}}}`,
+	}
+	for name, schema := range schemas {
+		for n, valid := range map[string]bool{"0": false, "1": true, "9": true, "10": false} {
+			content := `{"n": ` + n + `}`
+			doc, diag, err := FromJSONValidated(content, schema)
+			if err != nil {
+				t.Fatalf("%s: FromJSONValidated(%s) failed: %v", name, content, err)
+			}
+			if doc != nil {
+				doc.Close()
+			}
+			if got := diag.Count() == 0; got != valid {
+				t.Errorf("%s: expected %s valid=%v, got %d diagnostics", name, content, valid, diag.Count())
+			}
+			diag.Close()
+		}
+	}
+}
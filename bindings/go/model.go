@@ -0,0 +1,805 @@
+package hedl
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// This file implements a Go-side view of a document's content. The native
+// library exposes documents only through conversions, so the model is built
+// from the canonical header (for %ALIAS, %STRUCT and %NEST directives) and the
+// metadata-annotated JSON export (for the body). A model can be written back
+// out as HEDL and re-parsed, which is how the binding implements document
+// transformations without dedicated FFI exports.
+//
+// Scalar values in the model use the following Go types:
+//
+//	nil          null (~)
+//	bool         true / false
+//	json.Number  integers and floats, keeping their textual form
+//	string       strings
+//	reference    references such as @user_1 or @User:user_1
+//	expression   expressions such as $(x + 1)
+//	[]interface{} tensors (nested arrays of json.Number)
+
+// reference is a HEDL reference, stored including its leading '@'.
+type reference string
+
+// typeName returns the type of a qualified reference (@Type:id).
+func (r reference) typeName() (string, bool) {
+	idx := strings.Index(string(r), ":")
+	if idx < 0 {
+		return "", false
+	}
+	return string(r[1:idx]), true
+}
+
+// expression is a HEDL expression, stored including its $( ) delimiters.
+type expression string
+
+// object is an ordered HEDL object.
+type object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newObject() *object {
+	return &object{values: make(map[string]interface{})}
+}
+
+// get returns the value stored under key.
+func (o *object) get(key string) (interface{}, bool) {
+	v, ok := o.values[key]
+	return v, ok
+}
+
+// set stores value under key, appending the key if it is new.
+func (o *object) set(key string, value interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// matrixList is a typed list of rows sharing a schema.
+type matrixList struct {
+	typeName string
+	schema   []string
+	rows     []*matrixRow
+}
+
+// column returns the index of field in the list schema, or -1.
+func (l *matrixList) column(field string) int {
+	for i, name := range l.schema {
+		if name == field {
+			return i
+		}
+	}
+	return -1
+}
+
+// value returns the value of field in row, a row of l. It is false when
+// the list's schema has no such field, as with a list whose inline schema
+// differs from the declaration, or when the row is cut short.
+func (l *matrixList) value(row *matrixRow, field string) (interface{}, bool) {
+	col := l.column(field)
+	if col < 0 || col >= len(row.values) {
+		return nil, false
+	}
+	return row.values[col], true
+}
+
+// matrixRow is a single row. Values are positional and follow the schema of
+// the owning list; children holds rows nested under this row via %NEST.
+type matrixRow struct {
+	values   []interface{}
+	children []*matrixList
+}
+
+// schemaDef is a %STRUCT declaration.
+type schemaDef struct {
+	name    string
+	columns []string
+}
+
+// aliasDef is a %ALIAS declaration.
+type aliasDef struct {
+	key   string
+	value string
+}
+
+// docModel is the full Go-side view of a document.
+type docModel struct {
+	major, minor int
+	aliases      []aliasDef
+	structs      []schemaDef
+	nests        [][2]string
+	root         *object
+}
+
+// schema returns the declared columns of typeName.
+func (m *docModel) schema(typeName string) ([]string, bool) {
+	for _, def := range m.structs {
+		if def.name == typeName {
+			return def.columns, true
+		}
+	}
+	return nil, false
+}
+
+// allSchemas returns the declared schemas followed by any inline schemas
+// used by matrix lists without a %STRUCT declaration.
+func (m *docModel) allSchemas() []schemaDef {
+	structs := append([]schemaDef(nil), m.structs...)
+	m.eachList(func(list *matrixList) {
+		for _, def := range structs {
+			if def.name == list.typeName {
+				return
+			}
+		}
+		structs = append(structs, schemaDef{name: list.typeName, columns: list.schema})
+	})
+	return structs
+}
+
+// eachList calls fn for every matrix list in document order, including lists
+// of rows nested under other rows.
+func (m *docModel) eachList(fn func(list *matrixList)) {
+	var walkRows func(rows []*matrixRow)
+	walkRows = func(rows []*matrixRow) {
+		for _, row := range rows {
+			for _, child := range row.children {
+				fn(child)
+				walkRows(child.rows)
+			}
+		}
+	}
+	var walkObject func(obj *object)
+	walkObject = func(obj *object) {
+		for _, key := range obj.keys {
+			switch v := obj.values[key].(type) {
+			case *object:
+				walkObject(v)
+			case *matrixList:
+				fn(v)
+				walkRows(v.rows)
+			}
+		}
+	}
+	walkObject(m.root)
+}
+
+// eachTopList calls fn for every matrix list held by an object, in document
+// order, skipping lists of rows nested under other rows.
+func (m *docModel) eachTopList(fn func(list *matrixList)) {
+	var walkObject func(obj *object)
+	walkObject = func(obj *object) {
+		for _, key := range obj.keys {
+			switch v := obj.values[key].(type) {
+			case *object:
+				walkObject(v)
+			case *matrixList:
+				fn(v)
+			}
+		}
+	}
+	walkObject(m.root)
+}
+
+// mapScalars replaces every scalar value in the document, in both key-value
+// pairs and matrix cells, with the result of fn.
+func (m *docModel) mapScalars(fn func(value interface{}) interface{}) {
+	var walkObject func(obj *object)
+	walkObject = func(obj *object) {
+		for _, key := range obj.keys {
+			switch v := obj.values[key].(type) {
+			case *object:
+				walkObject(v)
+			case *matrixList:
+			default:
+				obj.values[key] = fn(v)
+			}
+		}
+	}
+	walkObject(m.root)
+	m.eachList(func(list *matrixList) {
+		for _, row := range list.rows {
+			for i, value := range row.values {
+				row.values[i] = fn(value)
+			}
+		}
+	})
+}
+
+// eachKeyValue calls fn for every scalar key-value pair outside matrix
+// lists, passing its dot-separated path.
+func (m *docModel) eachKeyValue(fn func(path string, value interface{})) {
+	var walk func(prefix string, obj *object)
+	walk = func(prefix string, obj *object) {
+		for _, key := range obj.keys {
+			switch v := obj.values[key].(type) {
+			case *object:
+				walk(prefix+key+".", v)
+			case *matrixList:
+			default:
+				fn(prefix+key, v)
+			}
+		}
+	}
+	walk("", m.root)
+}
+
+// eachCell calls fn for every matrix cell with its list, row and column.
+func (m *docModel) eachCell(fn func(list *matrixList, row, col int, value interface{})) {
+	m.eachList(func(list *matrixList) {
+		for r, row := range list.rows {
+			for c, value := range row.values {
+				fn(list, r, c, value)
+			}
+		}
+	})
+}
+
+// listsOf returns every matrix list whose type is schemaName.
+func (m *docModel) listsOf(schemaName string) ([]*matrixList, error) {
+	var lists []*matrixList
+	m.eachList(func(list *matrixList) {
+		if list.typeName == schemaName {
+			lists = append(lists, list)
+		}
+	})
+	if len(lists) == 0 {
+		if _, ok := m.schema(schemaName); !ok {
+			return nil, &HedlError{
+				Message: fmt.Sprintf("schema %q not found", schemaName),
+				Code:    ErrNotFound,
+			}
+		}
+	}
+	return lists, nil
+}
+
+// model builds the Go-side view of the document.
+func (d *Document) model() (*docModel, error) {
+	if d.ptr == nil {
+		return nil, errors.New("document closed")
+	}
+
+	canonical, err := d.Canonicalize()
+	if err != nil {
+		return nil, err
+	}
+	m, err := parseHeader(canonical)
+	if err != nil {
+		return nil, err
+	}
+	m.orderSchemas(d.schemaOrder)
+
+	body, err := d.ToJSON(true)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := decodeOrderedJSON(body)
+	if err != nil {
+		return nil, &HedlError{Message: "failed to decode document JSON: " + err.Error(), Code: ErrJSON}
+	}
+	root, ok := raw.(*object)
+	if !ok {
+		return nil, &HedlError{Message: "document JSON is not an object", Code: ErrJSON}
+	}
+	m.root = m.convertObject(root)
+	return m, nil
+}
+
+// replaceWith rebuilds the document from m, releasing the previous native
+// document only once the new one has parsed successfully.
+func (d *Document) replaceWith(m *docModel) error {
+	doc, err := documentFromModel(m)
+	if err != nil {
+		return err
+	}
+	d.swap(doc)
+	return nil
+}
+
+// documentFromModel serializes m and parses it into a new Document.
+func documentFromModel(m *docModel) (*Document, error) {
+	content, err := m.marshal()
+	if err != nil {
+		return nil, err
+	}
+	return Parse(content, false)
+}
+
+// parseHeader reads the directives of a canonical document.
+func parseHeader(canonical string) (*docModel, error) {
+	m := &docModel{major: 1, root: newObject()}
+	for _, line := range strings.Split(canonical, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "---" {
+			return m, nil
+		}
+		switch {
+		case strings.HasPrefix(line, "%VERSION:"):
+			version := strings.TrimSpace(strings.TrimPrefix(line, "%VERSION:"))
+			parts := strings.SplitN(version, ".", 2)
+			if len(parts) != 2 {
+				return nil, headerError(line)
+			}
+			major, err1 := strconv.Atoi(parts[0])
+			minor, err2 := strconv.Atoi(parts[1])
+			if err1 != nil || err2 != nil {
+				return nil, headerError(line)
+			}
+			m.major, m.minor = major, minor
+		case strings.HasPrefix(line, "%ALIAS: %"):
+			rest := strings.TrimPrefix(line, "%ALIAS: %")
+			idx := strings.Index(rest, ": ")
+			if idx < 0 {
+				return nil, headerError(line)
+			}
+			value := strings.TrimSpace(rest[idx+2:])
+			if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+				return nil, headerError(line)
+			}
+			value = strings.ReplaceAll(value[1:len(value)-1], `""`, `"`)
+			m.aliases = append(m.aliases, aliasDef{key: rest[:idx], value: value})
+		case strings.HasPrefix(line, "%STRUCT:"):
+			rest := strings.TrimSpace(strings.TrimPrefix(line, "%STRUCT:"))
+			idx := strings.Index(rest, ": [")
+			if idx < 0 || !strings.HasSuffix(rest, "]") {
+				return nil, headerError(line)
+			}
+			name := rest[:idx]
+			if paren := strings.Index(name, " ("); paren >= 0 {
+				name = name[:paren]
+			}
+			var columns []string
+			for _, col := range strings.Split(rest[idx+3:len(rest)-1], ",") {
+				columns = append(columns, strings.TrimSpace(col))
+			}
+			m.structs = append(m.structs, schemaDef{name: strings.TrimSpace(name), columns: columns})
+		case strings.HasPrefix(line, "%NEST:"):
+			rest := strings.TrimSpace(strings.TrimPrefix(line, "%NEST:"))
+			parts := strings.SplitN(rest, ">", 2)
+			if len(parts) != 2 {
+				return nil, headerError(line)
+			}
+			m.nests = append(m.nests, [2]string{strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])})
+		}
+	}
+	return nil, &HedlError{Message: "canonical output has no header separator", Code: ErrCanonicalize}
+}
+
+func headerError(line string) error {
+	return &HedlError{Message: fmt.Sprintf("unexpected canonical header line %q", line), Code: ErrCanonicalize}
+}
+
+// decodeOrderedJSON decodes JSON keeping object key order. Objects decode to
+// *object, arrays to []interface{} and numbers to json.Number.
+func decodeOrderedJSON(data string) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(data))
+	dec.UseNumber()
+	v, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+	return v, nil
+}
+
+func decodeJSONValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			obj := newObject()
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				key, ok := keyTok.(string)
+				if !ok {
+					return nil, fmt.Errorf("unexpected object key %v", keyTok)
+				}
+				value, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				obj.set(key, value)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return obj, nil
+		case '[':
+			arr := []interface{}{}
+			for dec.More() {
+				value, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, value)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return arr, nil
+		}
+		return nil, fmt.Errorf("unexpected delimiter %v", t)
+	default:
+		return tok, nil
+	}
+}
+
+// convertObject turns a decoded JSON object into model values.
+func (m *docModel) convertObject(raw *object) *object {
+	obj := newObject()
+	for _, key := range raw.keys {
+		obj.set(key, m.convertValue(raw.values[key]))
+	}
+	return obj
+}
+
+func (m *docModel) convertValue(raw interface{}) interface{} {
+	switch v := raw.(type) {
+	case *object:
+		if ref, ok := v.values["@ref"].(string); ok && len(v.keys) == 1 {
+			return reference(ref)
+		}
+		if typeName, ok := v.values["__type__"].(string); ok {
+			if items, ok := v.values["items"].([]interface{}); ok {
+				var schema []string
+				if cols, ok := v.values["__schema__"].([]interface{}); ok {
+					for _, col := range cols {
+						if s, ok := col.(string); ok {
+							schema = append(schema, s)
+						}
+					}
+				}
+				return m.convertList(typeName, schema, items)
+			}
+		}
+		return m.convertObject(v)
+	case string:
+		if strings.HasPrefix(v, "$(") && strings.HasSuffix(v, ")") {
+			return expression(v)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// convertList builds a matrix list from its JSON items. A nil schema is taken
+// from the %STRUCT declarations, falling back to the first item's keys.
+func (m *docModel) convertList(typeName string, schema []string, items []interface{}) *matrixList {
+	if schema == nil {
+		if declared, ok := m.schema(typeName); ok {
+			schema = declared
+		} else if len(items) > 0 {
+			if first, ok := items[0].(*object); ok {
+				for _, key := range first.keys {
+					if !isChildKey(key) && key != "__type__" {
+						schema = append(schema, key)
+					}
+				}
+			}
+		}
+	}
+
+	list := &matrixList{typeName: typeName, schema: schema}
+	for _, item := range items {
+		obj, ok := item.(*object)
+		if !ok {
+			continue
+		}
+		row := &matrixRow{values: make([]interface{}, len(schema))}
+		for i, col := range schema {
+			row.values[i] = m.convertValue(obj.values[col])
+		}
+		for _, key := range obj.keys {
+			if !isChildKey(key) {
+				continue
+			}
+			if children, ok := obj.values[key].([]interface{}); ok {
+				row.children = append(row.children, m.convertList(key, nil, children))
+			}
+		}
+		list.rows = append(list.rows, row)
+	}
+	return list
+}
+
+// valueType names the HEDL type of a model value.
+func valueType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "bool"
+	case json.Number:
+		if strings.ContainsAny(v.String(), ".eE") {
+			return "float"
+		}
+		return "int"
+	case string:
+		return "string"
+	case reference:
+		return "reference"
+	case expression:
+		return "expression"
+	case []interface{}:
+		return "tensor"
+	}
+	return "unknown"
+}
+
+// isChildKey reports whether a row key names a nested child list. Field names
+// are lowercase key tokens while child lists are keyed by their TypeName.
+func isChildKey(key string) bool {
+	return key != "" && key[0] >= 'A' && key[0] <= 'Z'
+}
+
+// marshal writes the model as a HEDL document.
+func (m *docModel) marshal() (string, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%%VERSION: %d.%d\n", m.major, m.minor)
+	for _, alias := range m.aliases {
+		fmt.Fprintf(&buf, "%%ALIAS: %%%s: \"%s\"\n", alias.key, strings.ReplaceAll(alias.value, `"`, `""`))
+	}
+
+	for _, def := range m.allSchemas() {
+		fmt.Fprintf(&buf, "%%STRUCT: %s: [%s]\n", def.name, strings.Join(def.columns, ","))
+	}
+	for _, nest := range m.nests {
+		fmt.Fprintf(&buf, "%%NEST: %s > %s\n", nest[0], nest[1])
+	}
+	buf.WriteString("---\n")
+
+	if err := writeObject(&buf, m.root, 0); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func writeObject(buf *bytes.Buffer, obj *object, indent int) error {
+	pad := strings.Repeat("  ", indent)
+	for _, key := range obj.keys {
+		switch v := obj.values[key].(type) {
+		case *object:
+			fmt.Fprintf(buf, "%s%s:\n", pad, key)
+			if err := writeObject(buf, v, indent+1); err != nil {
+				return err
+			}
+		case *matrixList:
+			fmt.Fprintf(buf, "%s%s: @%s\n", pad, key, v.typeName)
+			if err := writeRows(buf, v.rows, indent+1); err != nil {
+				return err
+			}
+		case string:
+			if strings.Contains(v, "\n") {
+				fmt.Fprintf(buf, "%s%s: \"\"\"\n%s\n\"\"\"\n", pad, key, v)
+				continue
+			}
+			fmt.Fprintf(buf, "%s%s: %s\n", pad, key, formatKVString(v))
+		default:
+			text, err := formatScalar(v)
+			if err != nil {
+				return fmt.Errorf("key %q: %w", key, err)
+			}
+			fmt.Fprintf(buf, "%s%s: %s\n", pad, key, text)
+		}
+	}
+	return nil
+}
+
+func writeRows(buf *bytes.Buffer, rows []*matrixRow, indent int) error {
+	pad := strings.Repeat("  ", indent)
+	cells := make([]string, 0)
+	for _, row := range rows {
+		cells = cells[:0]
+		for _, value := range row.values {
+			cell, err := formatCell(value)
+			if err != nil {
+				return err
+			}
+			cells = append(cells, cell)
+		}
+		fmt.Fprintf(buf, "%s|%s\n", pad, strings.Join(cells, ","))
+		for _, child := range row.children {
+			if err := writeRows(buf, child.rows, indent+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// formatScalar formats a non-string scalar in HEDL syntax.
+func formatScalar(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "~", nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case json.Number:
+		return formatNumber(v), nil
+	case reference:
+		return string(v), nil
+	case expression:
+		return string(v), nil
+	case []interface{}:
+		return formatTensor(v)
+	}
+	return "", fmt.Errorf("unsupported value of type %T", value)
+}
+
+// formatNumber keeps the JSON text of a number unless it uses an exponent,
+// which HEDL does not accept.
+func formatNumber(n json.Number) string {
+	text := n.String()
+	if !strings.ContainsAny(text, "eE") {
+		return text
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return text
+	}
+	text = strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.Contains(text, ".") {
+		text += ".0"
+	}
+	return text
+}
+
+func formatTensor(items []interface{}) (string, error) {
+	parts := make([]string, 0, len(items))
+	for _, item := range items {
+		switch v := item.(type) {
+		case json.Number:
+			parts = append(parts, formatNumber(v))
+		case []interface{}:
+			inner, err := formatTensor(v)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, inner)
+		default:
+			return "", fmt.Errorf("tensor element of type %T is not numeric", item)
+		}
+	}
+	return "[" + strings.Join(parts, ", ") + "]", nil
+}
+
+// formatKVString formats a string for a key-value line, quoting it when the
+// bare text would be read back as something else.
+func formatKVString(s string) string {
+	if needsQuoting(s, "~@$%[") {
+		return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+	}
+	return s
+}
+
+// formatCell formats a value for a matrix cell.
+func formatCell(value interface{}) (string, error) {
+	s, ok := value.(string)
+	if !ok {
+		return formatScalar(value)
+	}
+	if s == "" || needsQuoting(s, "~@$%^[") || strings.ContainsAny(s, ",|\n\t\r\\") {
+		var b strings.Builder
+		b.WriteByte('"')
+		for _, r := range s {
+			switch r {
+			case '"':
+				b.WriteString(`""`)
+			case '\n':
+				b.WriteString(`\n`)
+			case '\t':
+				b.WriteString(`\t`)
+			case '\r':
+				b.WriteString(`\r`)
+			case '\\':
+				b.WriteString(`\\`)
+			default:
+				b.WriteRune(r)
+			}
+		}
+		b.WriteByte('"')
+		return b.String(), nil
+	}
+	return s, nil
+}
+
+// parseCell reads a value written as HEDL cell text, the inverse of
+// formatCell.
+func parseCell(text string) interface{} {
+	text = strings.TrimSpace(text)
+	switch {
+	case text == "~":
+		return nil
+	case text == "true" || text == "false":
+		return text == "true"
+	case len(text) >= 2 && text[0] == '"' && text[len(text)-1] == '"':
+		return unquoteCell(text[1 : len(text)-1])
+	case strings.HasPrefix(text, "@"):
+		return reference(text)
+	case strings.HasPrefix(text, "$(") && strings.HasSuffix(text, ")"):
+		return expression(text)
+	case strings.HasPrefix(text, "["):
+		if tensor, err := decodeOrderedJSON(text); err == nil {
+			if items, ok := tensor.([]interface{}); ok {
+				return items
+			}
+		}
+	}
+	if _, err := strconv.ParseFloat(text, 64); err == nil {
+		return json.Number(text)
+	}
+	return text
+}
+
+// unquoteCell resolves the escapes of a quoted cell body.
+func unquoteCell(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '"' && i+1 < len(s) && s[i+1] == '"':
+			b.WriteByte('"')
+			i++
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			default:
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// needsQuoting reports whether an unquoted string would be misread.
+func needsQuoting(s, specialFirst string) bool {
+	if s == "" {
+		return true
+	}
+	if s != strings.TrimSpace(s) || strings.ContainsAny(s, "#\"") {
+		return true
+	}
+	if strings.ContainsRune(specialFirst, rune(s[0])) {
+		return true
+	}
+	if s == "true" || s == "false" {
+		return true
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return true
+	}
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f {
+			return true
+		}
+	}
+	return false
+}
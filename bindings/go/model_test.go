@@ -0,0 +1,44 @@
+package hedl
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseCell(t *testing.T) {
+	tests := []struct {
+		text string
+		want interface{}
+	}{
+		{"~", nil},
+		{"true", true},
+		{"42", json.Number("42")},
+		{"-1.5", json.Number("-1.5")},
+		{"hello", "hello"},
+		{` padded `, "padded"},
+		{`"42"`, "42"},
+		{`"say ""hi"""`, `say "hi"`},
+		{`"a\nb"`, "a\nb"},
+		{"@User:alice", reference("@User:alice")},
+		{"$(x + 1)", expression("$(x + 1)")},
+		{"[1, 2]", []interface{}{json.Number("1"), json.Number("2")}},
+	}
+	for _, tt := range tests {
+		if got := parseCell(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseCell(%q) = %#v, want %#v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParseCellRoundTrip(t *testing.T) {
+	for _, s := range []string{"", "true", "1.0", "a,b", `quote "q"`, "tab\there", `back\slash`} {
+		cell, err := formatCell(s)
+		if err != nil {
+			t.Fatalf("formatCell(%q) failed: %v", s, err)
+		}
+		if got := parseCell(cell); got != s {
+			t.Errorf("parseCell(formatCell(%q)) = %#v", s, got)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package hedl
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Pipeline chains row transformations that are applied together when Result
+// is called, so intermediate documents are never built. Create one with
+// Document.Pipe.
+//
+// Filter and Sort apply to every top-level matrix list whose schema has the
+// fields the step names, and nested child rows stay with their parents.
+// Project also applies to lists nested under other rows, since every list
+// of a type shares its %STRUCT declaration. Other lists pass through
+// unchanged.
+//
+// The steps run in Go, not in the native library: Result reads the
+// document once through Canonicalize and ToJSON, applies every step to that
+// copy and parses the outcome as the new document.
+type Pipeline struct {
+	doc   *Document
+	steps []pipelineStep
+	err   error
+}
+
+// pipelineStep is one transformation of a Pipeline.
+type pipelineStep struct {
+	apply func(list *matrixList) error
+	// nested applies the step to lists nested under other rows too.
+	nested bool
+}
+
+// Pipe starts a pipeline over the document's rows. The document itself is
+// not modified.
+func (d *Document) Pipe() *Pipeline {
+	return &Pipeline{doc: d}
+}
+
+var filterExpr = regexp.MustCompile(`^\s*([A-Za-z_][A-Za-z0-9_-]*)\s*(==|!=|<=|>=|<|>)\s*(.*?)\s*$`)
+
+// Filter keeps rows matching expr, written as "field op value" where op is
+// one of ==, !=, <, <=, > or >= and value is HEDL cell text, for example
+// `age >= 30` or `country == "USA"`. Numbers compare numerically and strings
+// lexically; other values only support == and !=. An invalid expression is
+// reported by Result.
+func (p *Pipeline) Filter(expr string) *Pipeline {
+	match := filterExpr.FindStringSubmatch(expr)
+	if match == nil {
+		p.fail(fmt.Sprintf("invalid filter expression %q", expr))
+		return p
+	}
+	field, op, want := match[1], match[2], parseCell(match[3])
+
+	p.steps = append(p.steps, pipelineStep{apply: func(list *matrixList) error {
+		col := list.column(field)
+		if col < 0 {
+			return nil
+		}
+		kept := list.rows[:0]
+		for _, row := range list.rows {
+			if matchesFilter(row.values[col], op, want) {
+				kept = append(kept, row)
+			}
+		}
+		list.rows = kept
+		return nil
+	}})
+	return p
+}
+
+// Project keeps only the named fields, in the given order. The ID column is
+// always kept as the first column. Result returns ErrInvalidArgument when
+// only some lists of a type have every named field, since the lists would
+// no longer share one declaration.
+func (p *Pipeline) Project(fields []string) *Pipeline {
+	p.steps = append(p.steps, pipelineStep{nested: true, apply: func(list *matrixList) error {
+		columns := []int{0}
+		for _, field := range fields {
+			col := list.column(field)
+			if col < 0 {
+				return nil
+			}
+			if col != 0 {
+				columns = append(columns, col)
+			}
+		}
+
+		schema := make([]string, len(columns))
+		for i, col := range columns {
+			schema[i] = list.schema[col]
+		}
+		for _, row := range list.rows {
+			values := make([]interface{}, len(columns))
+			for i, col := range columns {
+				values[i] = row.values[col]
+			}
+			row.values = values
+		}
+		list.schema = schema
+		return nil
+	}})
+	return p
+}
+
+// Sort orders rows by field, descending when desc is set. The sort is
+// stable; nulls sort first, then booleans, numbers and strings.
+func (p *Pipeline) Sort(field string, desc bool) *Pipeline {
+	p.steps = append(p.steps, pipelineStep{apply: func(list *matrixList) error {
+		col := list.column(field)
+		if col < 0 {
+			return nil
+		}
+		sort.SliceStable(list.rows, func(i, j int) bool {
+			a, b := list.rows[i].values[col], list.rows[j].values[col]
+			if desc {
+				a, b = b, a
+			}
+			return compareValues(a, b) < 0
+		})
+		return nil
+	}})
+	return p
+}
+
+// Result applies the pipeline and returns the resulting document.
+func (p *Pipeline) Result() (*Document, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	m, err := p.doc.model()
+	if err != nil {
+		return nil, err
+	}
+
+	top := make(map[*matrixList]bool)
+	m.eachTopList(func(list *matrixList) {
+		top[list] = true
+	})
+	var stepErr error
+	m.eachList(func(list *matrixList) {
+		for _, step := range p.steps {
+			if stepErr == nil && (step.nested || top[list]) {
+				stepErr = step.apply(list)
+			}
+		}
+	})
+	if stepErr != nil {
+		return nil, stepErr
+	}
+
+	// Projected lists no longer match their %STRUCT declarations.
+	for i, def := range m.structs {
+		var columns []string
+		found, mixed := false, false
+		m.eachList(func(list *matrixList) {
+			switch {
+			case list.typeName != def.name:
+			case !found:
+				columns, found = list.schema, true
+			case strings.Join(list.schema, ",") != strings.Join(columns, ","):
+				mixed = true
+			}
+		})
+		if mixed {
+			return nil, &HedlError{
+				Message: fmt.Sprintf("projection leaves %s lists with different fields", def.name),
+				Code:    ErrInvalidArgument,
+			}
+		}
+		if found {
+			m.structs[i].columns = columns
+		}
+	}
+	return documentFromModel(m)
+}
+
+// fail records the first pipeline error.
+func (p *Pipeline) fail(msg string) {
+	if p.err == nil {
+		p.err = &HedlError{Message: msg, Code: ErrInvalidArgument}
+	}
+}
+
+// matchesFilter compares value against want with op.
+func matchesFilter(value interface{}, op string, want interface{}) bool {
+	cmp, ordered := compareOrdered(value, want)
+	switch op {
+	case "==":
+		return sameValue(value, want)
+	case "!=":
+		return !sameValue(value, want)
+	case "<":
+		return ordered && cmp < 0
+	case "<=":
+		return ordered && cmp <= 0
+	case ">":
+		return ordered && cmp > 0
+	case ">=":
+		return ordered && cmp >= 0
+	}
+	return false
+}
+
+// sameValue reports whether two values are equal, comparing numbers by value.
+func sameValue(a, b interface{}) bool {
+	if cmp, ok := compareOrdered(a, b); ok {
+		return cmp == 0
+	}
+	ca, errA := formatCell(a)
+	cb, errB := formatCell(b)
+	return errA == nil && errB == nil && ca == cb && valueType(a) == valueType(b)
+}
+
+// compareOrdered compares two numbers or two strings. ok is false for any
+// other combination.
+func compareOrdered(a, b interface{}) (cmp int, ok bool) {
+	switch av := a.(type) {
+	case json.Number:
+		bv, isNum := b.(json.Number)
+		if !isNum {
+			return 0, false
+		}
+		x, errX := av.Float64()
+		y, errY := bv.Float64()
+		if errX != nil || errY != nil {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		bv, isStr := b.(string)
+		if !isStr {
+			return 0, false
+		}
+		return strings.Compare(av, bv), true
+	}
+	return 0, false
+}
+
+// compareValues orders any two values: nulls first, then booleans, numbers,
+// strings and finally other values by their HEDL text.
+func compareValues(a, b interface{}) int {
+	if cmp, ok := compareOrdered(a, b); ok {
+		return cmp
+	}
+	ra, rb := valueRank(a), valueRank(b)
+	if ra != rb {
+		return ra - rb
+	}
+	if ba, ok := a.(bool); ok {
+		bb := b.(bool)
+		switch {
+		case ba == bb:
+			return 0
+		case !ba:
+			return -1
+		}
+		return 1
+	}
+	ca, _ := formatCell(a)
+	cb, _ := formatCell(b)
+	return strings.Compare(ca, cb)
+}
+
+func valueRank(value interface{}) int {
+	switch value.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case json.Number:
+		return 2
+	case string:
+		return 3
+	}
+	return 4
+}
@@ -0,0 +1,155 @@
+package hedl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	fixtures := GetGlobalFixtures()
+	large, err := fixtures.LargeHEDL()
+	if err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+
+	doc, err := Parse(large, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	piped, err := doc.Pipe().
+		Filter("age >= 30").
+		Project([]string{"name", "age"}).
+		Sort("age", true).
+		Result()
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+	defer piped.Close()
+
+	filtered, err := doc.Pipe().Filter("age >= 30").Result()
+	if err != nil {
+		t.Fatalf("Filter failed: %v", err)
+	}
+	defer filtered.Close()
+	projected, err := filtered.Pipe().Project([]string{"name", "age"}).Result()
+	if err != nil {
+		t.Fatalf("Project failed: %v", err)
+	}
+	defer projected.Close()
+	stepwise, err := projected.Pipe().Sort("age", true).Result()
+	if err != nil {
+		t.Fatalf("Sort failed: %v", err)
+	}
+	defer stepwise.Close()
+
+	if a, b := mustCanonicalize(t, piped), mustCanonicalize(t, stepwise); a != b {
+		t.Errorf("Pipeline result differs from step-by-step result:\n%s\nvs\n%s", a, b)
+	}
+
+	m, err := piped.model()
+	if err != nil {
+		t.Fatalf("model failed: %v", err)
+	}
+	lists, err := m.listsOf("User")
+	if err != nil {
+		t.Fatalf("listsOf failed: %v", err)
+	}
+	var ages []interface{}
+	for _, row := range lists[0].rows {
+		ages = append(ages, row.values[2])
+	}
+	if got := fmt.Sprint(lists[0].schema, ages); got != "[id name age] [50 45 40 35 33 32 30]" {
+		t.Errorf("Unexpected pipeline rows: %s", got)
+	}
+	if orders, _ := m.listsOf("Order"); len(orders[0].rows) != 20 {
+		t.Errorf("Expected lists without the filtered field to pass through, got %d orders", len(orders[0].rows))
+	}
+}
+
+// nestedUsersHEDL has User rows both at the top level and nested under
+// Team rows.
+const nestedUsersHEDL = `%VERSION: 1.0
+%STRUCT: Team: [id, name]
+%STRUCT: User: [id, name, email]
+%NEST: Team > User
+---
+users: @User
+  | u1, Alice, alice@example.com
+teams: @Team
+  |[1] t1, Core
+    | u2, Bob, bob@example.com
+`
+
+func TestPipelineProjectNested(t *testing.T) {
+	doc, err := Parse(nestedUsersHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	piped, err := doc.Pipe().Project([]string{"name"}).Result()
+	if err != nil {
+		t.Fatalf("Pipeline failed: %v", err)
+	}
+	defer piped.Close()
+
+	canonical := mustCanonicalize(t, piped)
+	for _, want := range []string{"User (1): [id,name]\n", "|u1,Alice\n", "|u2,Bob\n"} {
+		if !strings.Contains(canonical, want) {
+			t.Errorf("Expected %q in projected document:\n%s", want, canonical)
+		}
+	}
+}
+
+func TestPipelineInvalidFilter(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	_, err = doc.Pipe().Filter("name ~ alice").Result()
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrInvalidArgument {
+		t.Errorf("Expected ErrInvalidArgument, got %v", err)
+	}
+}
+
+func TestMatchesFilter(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		op    string
+		want  string
+		match bool
+	}{
+		{json.Number("30"), ">=", "30", true},
+		{json.Number("29"), ">=", "30", false},
+		{json.Number("1.50"), "==", "1.5", true},
+		{"USA", "==", "USA", true},
+		{"USA", "!=", `"USA"`, false},
+		{"Alice", "<", "Bob", true},
+		{nil, "==", "~", true},
+		{true, "==", "true", true},
+		{true, ">", "false", false},
+		{"30", "==", "30", false},
+	}
+	for _, tt := range tests {
+		if got := matchesFilter(tt.value, tt.op, parseCell(tt.want)); got != tt.match {
+			t.Errorf("matchesFilter(%#v, %q, %q) = %v, want %v", tt.value, tt.op, tt.want, got, tt.match)
+		}
+	}
+}
+
+func mustCanonicalize(t *testing.T, doc *Document) string {
+	t.Helper()
+	canonical, err := doc.Canonicalize()
+	if err != nil {
+		t.Fatalf("Canonicalize failed: %v", err)
+	}
+	return canonical
+}
@@ -0,0 +1,142 @@
+package hedl
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// exporters holds the formats added with RegisterExporter, keyed by
+// lowercase name and guarded by exportersMu.
+var (
+	exportersMu sync.RWMutex
+	exporters   = make(map[string]func(*Document, io.Writer) error)
+)
+
+// RegisterExporter makes a Go-implemented export format available to
+// ExportTo under name, which is case-insensitive. It is meant to be called
+// from an init function and, like database/sql.Register, panics if fn is nil
+// or name is already taken by a built-in or registered format.
+func RegisterExporter(name string, fn func(*Document, io.Writer) error) {
+	if fn == nil {
+		panic("hedl: RegisterExporter exporter is nil")
+	}
+	key := strings.ToLower(name)
+	if _, err := exporterFor(key); err == nil {
+		panic("hedl: RegisterExporter called for built-in format " + name)
+	}
+
+	exportersMu.Lock()
+	defer exportersMu.Unlock()
+	if _, dup := exporters[key]; dup {
+		panic("hedl: RegisterExporter called twice for format " + name)
+	}
+	exporters[key] = fn
+}
+
+// ExportTo writes the document to w in the named format: either a built-in
+// format accepted by Transcode or one added with RegisterExporter. An
+// unknown name returns an ErrInvalidArgument error.
+func (d *Document) ExportTo(name string, w io.Writer) error {
+	exportersMu.RLock()
+	fn, ok := exporters[strings.ToLower(name)]
+	exportersMu.RUnlock()
+	if ok {
+		return fn(d, w)
+	}
+
+	export, err := exporterFor(name)
+	if err != nil {
+		return err
+	}
+	data, err := export(d)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// scalarTypes holds the validators added with RegisterScalarType, keyed by
+// type name and guarded by scalarTypesMu.
+var (
+	scalarTypesMu sync.RWMutex
+	scalarTypes   = make(map[string]func(string) error)
+)
+
+// RegisterScalarType adds a domain scalar type, such as "money", whose values
+// the core parser reads as plain strings or numbers. CheckScalarTypes calls
+// validate with the text of each value of a field bound to the type and
+// reports the values it rejects. Like RegisterExporter, it panics if
+// validate is nil or name is already registered.
+func RegisterScalarType(name string, validate func(string) error) {
+	if validate == nil {
+		panic("hedl: RegisterScalarType validator is nil")
+	}
+	scalarTypesMu.Lock()
+	defer scalarTypesMu.Unlock()
+	if _, dup := scalarTypes[name]; dup {
+		panic("hedl: RegisterScalarType called twice for type " + name)
+	}
+	scalarTypes[name] = validate
+}
+
+// CheckScalarTypes validates fields bound to scalar types added with
+// RegisterScalarType. HEDL has no syntax for field types, so fields maps
+// "Schema.field" to the type name. Every non-null value the type's validator
+// rejects is reported as an error diagnostic naming the row index, counted
+// across the schema's lists in document order, and the validator's message.
+//
+// An unregistered type returns an ErrInvalidArgument error and an unknown
+// schema or field returns ErrNotFound.
+func (d *Document) CheckScalarTypes(fields map[string]string) (*Diagnostics, error) {
+	m, err := d.model()
+	if err != nil {
+		return nil, err
+	}
+
+	// Check fields in a stable order so diagnostics are deterministic.
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var items []*Diagnostic
+	for _, key := range keys {
+		typeName := fields[key]
+		scalarTypesMu.RLock()
+		validate, ok := scalarTypes[typeName]
+		scalarTypesMu.RUnlock()
+		if !ok {
+			return nil, &HedlError{
+				Message: fmt.Sprintf("scalar type %q is not registered", typeName),
+				Code:    ErrInvalidArgument,
+			}
+		}
+		schemaName, field, lists, err := m.qualifiedField(key)
+		if err != nil {
+			return nil, err
+		}
+
+		index := 0
+		for _, list := range lists {
+			for _, row := range list.rows {
+				if value, ok := list.value(row, field); ok && value != nil {
+					text, err := valueText(value)
+					if err != nil {
+						return nil, err
+					}
+					if err := validate(text); err != nil {
+						items = append(items, newDiagnostic(SeverityError, "scalar-type",
+							"%s row %d field %q is not a valid %s: %v", schemaName, index, field, typeName, err))
+					}
+				}
+				index++
+			}
+		}
+	}
+	return newDiagnostics(items), nil
+}
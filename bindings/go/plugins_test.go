@@ -0,0 +1,151 @@
+package hedl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var moneyPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]{1,2})?$`)
+
+func init() {
+	RegisterScalarType("money", func(text string) error {
+		if !moneyPattern.MatchString(text) {
+			return errors.New("expected an amount with at most two decimals")
+		}
+		return nil
+	})
+	RegisterExporter("row-count", func(d *Document, w io.Writer) error {
+		n, err := d.RootItemCount()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%d root items\n", n)
+		return err
+	})
+}
+
+func TestExportToRegistered(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	var buf bytes.Buffer
+	if err := doc.ExportTo("Row-Count", &buf); err != nil {
+		t.Fatalf("ExportTo failed: %v", err)
+	}
+	if got := buf.String(); got != "1 root items\n" {
+		t.Errorf("Unexpected exporter output %q", got)
+	}
+}
+
+func TestExportToBuiltin(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	want, err := doc.ToXML()
+	if err != nil {
+		t.Fatalf("ToXML failed: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := doc.ExportTo("xml", &buf); err != nil {
+		t.Fatalf("ExportTo failed: %v", err)
+	}
+	if buf.String() != want {
+		t.Errorf("ExportTo(xml) differs from ToXML")
+	}
+
+	err = doc.ExportTo("unknown", &buf)
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrInvalidArgument {
+		t.Errorf("Expected ErrInvalidArgument for an unknown format, got %v", err)
+	}
+}
+
+func TestRegisterExporterDuplicate(t *testing.T) {
+	for _, name := range []string{"row-count", "JSON"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected RegisterExporter(%q) to panic", name)
+				}
+			}()
+			RegisterExporter(name, func(*Document, io.Writer) error { return nil })
+		}()
+	}
+}
+
+const invoicesHEDL = `%VERSION: 1.0
+%STRUCT: Invoice: [id, total, note]
+---
+invoices: @Invoice
+  | i1, 12.50, paid
+  | i2, abc, ~
+  | i3, 3.999, late
+  | i4, ~, void
+`
+
+func TestCheckScalarTypes(t *testing.T) {
+	doc, err := Parse(invoicesHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	diag, err := doc.CheckScalarTypes(map[string]string{"Invoice.total": "money"})
+	if err != nil {
+		t.Fatalf("CheckScalarTypes failed: %v", err)
+	}
+	defer diag.Close()
+
+	errs, err := diag.Errors()
+	if err != nil {
+		t.Fatalf("Errors failed: %v", err)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 invalid money values, got %v", errs)
+	}
+	if !strings.Contains(errs[0], `row 1 field "total" is not a valid money`) || !strings.Contains(errs[1], "row 2") {
+		t.Errorf("Unexpected diagnostics: %v", errs)
+	}
+}
+
+func TestCheckScalarTypesMixedSchemas(t *testing.T) {
+	doc, err := Parse(mixedUserListsHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	diag, err := doc.CheckScalarTypes(map[string]string{"User.name": "money"})
+	if err != nil {
+		t.Fatalf("CheckScalarTypes failed: %v", err)
+	}
+	defer diag.Close()
+	if diag.Count() != 2 {
+		t.Errorf("Expected only the two named rows to be checked, got %d diagnostics", diag.Count())
+	}
+}
+
+func TestCheckScalarTypesUnregistered(t *testing.T) {
+	doc, err := Parse(invoicesHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	_, err = doc.CheckScalarTypes(map[string]string{"Invoice.total": "currency"})
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrInvalidArgument {
+		t.Errorf("Expected ErrInvalidArgument for an unregistered type, got %v", err)
+	}
+}
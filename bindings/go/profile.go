@@ -0,0 +1,97 @@
+package hedl
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// profiling is nonzero while EnableProfiling is on.
+var profiling int32
+
+// EnableProfiling turns phase timing of FFI operations on or off for the
+// whole package. It is off by default; when off, operations only pay for
+// one atomic load. It is safe to call concurrently with other operations.
+func EnableProfiling(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&profiling, v)
+}
+
+// OperationProfile holds the phase timings of one FFI operation.
+//
+// CopyIn is the time spent copying Go input into C memory, Native the time
+// inside the FFI call and CopyOut the time copying the result back into Go
+// memory. Total is the wall time from the start of the copy in to the
+// end of the operation, so Total minus the phases is the remaining binding
+// overhead such as wrapping the result and freeing native memory.
+type OperationProfile struct {
+	Operation string
+	CopyIn    time.Duration
+	Native    time.Duration
+	CopyOut   time.Duration
+	Total     time.Duration
+}
+
+// LastProfile returns the profile of the most recent operation on the
+// document, including the parse or import that created it, recorded while
+// profiling was enabled. It is the zero OperationProfile when none was
+// recorded. Like the document itself, it is not synchronized, so concurrent
+// operations on one document may overwrite each other's profile.
+func (d *Document) LastProfile() OperationProfile {
+	return d.profile
+}
+
+// opTimer measures the phases of one operation. A nil *opTimer, returned
+// while profiling is off, ignores every call.
+type opTimer struct {
+	start, mark time.Time
+	profile     OperationProfile
+}
+
+// startOp starts timing operation op, or returns nil when profiling is off.
+func startOp(op string) *opTimer {
+	if atomic.LoadInt32(&profiling) == 0 {
+		return nil
+	}
+	now := time.Now()
+	return &opTimer{start: now, mark: now, profile: OperationProfile{Operation: op}}
+}
+
+// lap adds the time since the previous mark to phase.
+func (t *opTimer) lap(phase *time.Duration) {
+	now := time.Now()
+	*phase += now.Sub(t.mark)
+	t.mark = now
+}
+
+// copiedIn ends the CopyIn phase.
+func (t *opTimer) copiedIn() {
+	if t != nil {
+		t.lap(&t.profile.CopyIn)
+	}
+}
+
+// called ends the Native phase.
+func (t *opTimer) called() {
+	if t != nil {
+		t.lap(&t.profile.Native)
+	}
+}
+
+// copiedOut ends the CopyOut phase.
+func (t *opTimer) copiedOut() {
+	if t != nil {
+		t.lap(&t.profile.CopyOut)
+	}
+}
+
+// finish records the profile on d. A nil d, as on a failed parse, drops it.
+func (t *opTimer) finish(d *Document) {
+	if t == nil || d == nil {
+		return
+	}
+	t.profile.Total = time.Since(t.start)
+	d.profile = t.profile
+}
@@ -0,0 +1,62 @@
+package hedl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLastProfile(t *testing.T) {
+	large, err := GetGlobalFixtures().LargeHEDL()
+	if err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+
+	EnableProfiling(true)
+	defer EnableProfiling(false)
+
+	doc, err := Parse(large, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+	if op := doc.LastProfile().Operation; op != "Parse" {
+		t.Errorf("Expected the Parse profile after parsing, got %q", op)
+	}
+
+	start := time.Now()
+	if _, err := doc.ToJSON(false); err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	wall := time.Since(start)
+
+	p := doc.LastProfile()
+	if p.Operation != "ToJSON" {
+		t.Fatalf("Expected the ToJSON profile, got %q", p.Operation)
+	}
+	if p.Native <= 0 || p.CopyOut <= 0 {
+		t.Errorf("Expected nonzero Native and CopyOut phases, got %+v", p)
+	}
+	if p.Total > wall {
+		t.Errorf("Profile total %v exceeds the measured wall time %v", p.Total, wall)
+	}
+	sum := p.CopyIn + p.Native + p.CopyOut
+	if sum > p.Total || sum < p.Total/2 {
+		t.Errorf("Expected phases (%v) to account for most of the total %v: %+v", sum, p.Total, p)
+	}
+}
+
+func TestLastProfileDisabled(t *testing.T) {
+	EnableProfiling(false)
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	if _, err := doc.ToJSON(false); err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	if p := doc.LastProfile(); p != (OperationProfile{}) {
+		t.Errorf("Expected no profile with profiling disabled, got %+v", p)
+	}
+}
@@ -0,0 +1,60 @@
+package hedl
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToProperties(t *testing.T) {
+	doc, err := Parse(nestedConfigHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	props, err := doc.ToProperties()
+	if err != nil {
+		t.Fatalf("ToProperties failed: %v", err)
+	}
+	for _, line := range []string{
+		"users.0.id=alice\n",
+		"config.name=demo\n",
+		"config.database.host=localhost\n",
+		"config.database.port=5432\n",
+		"config.database.replicas.0.host=db1.internal\n",
+	} {
+		if !strings.Contains(props, line) {
+			t.Errorf("Expected %q in:\n%s", line, props)
+		}
+	}
+}
+
+func TestToPropertiesEscaping(t *testing.T) {
+	const notesHEDL = `%VERSION: 1.0
+---
+note: "url=http://x#y"
+padded: " padded value"
+path: "C:\dir"
+city: café
+`
+	doc, err := Parse(notesHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	props, err := doc.ToProperties()
+	if err != nil {
+		t.Fatalf("ToProperties failed: %v", err)
+	}
+	for _, line := range []string{
+		`note=url\=http\://x\#y` + "\n",
+		`padded=\ padded value` + "\n",
+		`path=C\:\\dir` + "\n",
+		`city=caf\u00E9` + "\n",
+	} {
+		if !strings.Contains(props, line) {
+			t.Errorf("Expected %q in:\n%s", line, props)
+		}
+	}
+}
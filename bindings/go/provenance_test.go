@@ -0,0 +1,75 @@
+package hedl
+
+import (
+	"errors"
+	"testing"
+)
+
+// projectTasksHEDL nests tasks under projects and has a block string with a
+// row-like line, which must not be taken for a row.
+const projectTasksHEDL = `%VERSION: 1.0
+%STRUCT: Project: [id, name]
+%STRUCT: Task: [id, title]
+%NEST: Project > Task
+---
+notes: """
+| not, a row
+"""
+projects: @Project
+  # Active projects
+  |[2] apollo, Apollo
+    | t1, Design
+    | t2, Build
+  |[1] zephyr, Zephyr
+    | t3, Launch
+`
+
+func TestRowSourceLine(t *testing.T) {
+	doc, err := ParseBytes([]byte(projectTasksHEDL), ParseOptions{Strict: true, TrackProvenance: true})
+	if err != nil {
+		t.Fatalf("ParseBytes failed: %v", err)
+	}
+	defer doc.Close()
+
+	tests := []struct {
+		schema string
+		index  int
+		line   int
+	}{
+		{"Project", 0, 11},
+		{"Project", 1, 14},
+		{"Task", 0, 12},
+		{"Task", 1, 13},
+		{"Task", 2, 15},
+	}
+	for _, tt := range tests {
+		line, err := doc.RowSourceLine(tt.schema, tt.index)
+		if err != nil {
+			t.Errorf("RowSourceLine(%s, %d) failed: %v", tt.schema, tt.index, err)
+			continue
+		}
+		if line != tt.line {
+			t.Errorf("RowSourceLine(%s, %d) = %d, want %d", tt.schema, tt.index, line, tt.line)
+		}
+	}
+
+	_, err = doc.RowSourceLine("Task", 3)
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrInvalidArgument {
+		t.Errorf("Expected ErrInvalidArgument for an index out of range, got %v", err)
+	}
+}
+
+func TestRowSourceLineWithoutProvenance(t *testing.T) {
+	doc, err := Parse(projectTasksHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	_, err = doc.RowSourceLine("Project", 0)
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrNotFound {
+		t.Errorf("Expected ErrNotFound without TrackProvenance, got %v", err)
+	}
+}
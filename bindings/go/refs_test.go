@@ -0,0 +1,136 @@
+package hedl
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const cyclicRefsHEDL = `%VERSION: 1.0
+%STRUCT: Employee: [id, name, manager]
+---
+employees: @Employee
+  | e1, Alice, @e3
+  | e2, Bob, @e1
+  | e3, Carol, @Employee:e2
+`
+
+func TestHasCycles(t *testing.T) {
+	doc, err := Parse(cyclicRefsHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	found, cycle, err := doc.HasCycles()
+	if err != nil {
+		t.Fatalf("HasCycles failed: %v", err)
+	}
+	if !found {
+		t.Fatal("Expected a cycle to be detected")
+	}
+	want := []string{"Employee:e1", "Employee:e3", "Employee:e2"}
+	if !reflect.DeepEqual(cycle, want) {
+		t.Errorf("Expected cycle %v, got %v", want, cycle)
+	}
+}
+
+func TestHasCyclesAcyclic(t *testing.T) {
+	doc, err := Parse(orderRefsHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	found, cycle, err := doc.HasCycles()
+	if err != nil {
+		t.Fatalf("HasCycles failed: %v", err)
+	}
+	if found || cycle != nil {
+		t.Errorf("Expected no cycle, got %v", cycle)
+	}
+}
+
+func normalizedCanonical(t *testing.T, doc *Document, mode string) string {
+	t.Helper()
+	if err := doc.NormalizeReferences(mode); err != nil {
+		t.Fatalf("NormalizeReferences(%q) failed: %v", mode, err)
+	}
+	canonical, err := doc.Canonicalize()
+	if err != nil {
+		t.Fatalf("Canonicalize failed: %v", err)
+	}
+	return canonical
+}
+
+const keyValueRefsHEDL = `%VERSION: 1.0
+%STRUCT: User: [id, name]
+---
+users: @User
+  | alice, Alice Smith
+  | bob, Bob Jones
+owner: @User:bob
+team:
+  lead: @alice
+  missing: @User:nobody
+`
+
+func TestNormalizeReferences(t *testing.T) {
+	doc, err := Parse(keyValueRefsHEDL, false)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	ids := normalizedCanonical(t, doc, "id")
+	for _, want := range []string{"owner: @User:bob", "lead: @User:alice", "missing: @User:nobody"} {
+		if !strings.Contains(ids, want) {
+			t.Errorf("Expected %q in id form:\n%s", want, ids)
+		}
+	}
+
+	inline := normalizedCanonical(t, doc, "inline")
+	for _, want := range []string{"name: Bob Jones", "name: Alice Smith", "__type__: User", "missing: @User:nobody"} {
+		if !strings.Contains(inline, want) {
+			t.Errorf("Expected %q in inline form:\n%s", want, inline)
+		}
+	}
+	if strings.Contains(inline, "@User:bob") || strings.Contains(inline, "@User:alice") {
+		t.Errorf("Expected resolvable references to be inlined:\n%s", inline)
+	}
+
+	if again := normalizedCanonical(t, doc, "id"); again != ids {
+		t.Errorf("Round trip changed the document:\nbefore:\n%s\nafter:\n%s", ids, again)
+	}
+}
+
+func TestNormalizeReferencesMatrixCells(t *testing.T) {
+	doc, err := Parse(cyclicRefsHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	ids := normalizedCanonical(t, doc, "id")
+	for _, want := range []string{"@Employee:e3", "@Employee:e1", "@Employee:e2"} {
+		if !strings.Contains(ids, want) {
+			t.Errorf("Expected %s in id form:\n%s", want, ids)
+		}
+	}
+
+	// Matrix cells cannot hold an inline row.
+	err = doc.NormalizeReferences("inline")
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrInvalidArgument {
+		t.Errorf("Expected ErrInvalidArgument for references in matrix cells, got %v", err)
+	}
+	if after, _ := doc.Canonicalize(); after != ids {
+		t.Errorf("Expected the document unchanged after a failed inline:\n%s", after)
+	}
+
+	err = doc.NormalizeReferences("embedded")
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrInvalidArgument {
+		t.Errorf("Expected ErrInvalidArgument for an unknown mode, got %v", err)
+	}
+}
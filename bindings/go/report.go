@@ -0,0 +1,109 @@
+package hedl
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// ParseReport holds telemetry gathered by ParseWithReport.
+type ParseReport struct {
+	// Duration is the wall-clock time spent in the native parser.
+	Duration time.Duration
+	// InputBytes is the size of the parsed content.
+	InputBytes int
+	// DocumentBytes approximates the memory held by the parsed document.
+	DocumentBytes int64
+}
+
+// ParseWithReport parses HEDL content like Parse and also reports how long
+// parsing took and how large the input and resulting document are.
+//
+// The report is filled in even when parsing fails, minus DocumentBytes.
+func ParseWithReport(content string, strict bool) (*Document, ParseReport, error) {
+	report := ParseReport{InputBytes: len(content)}
+
+	start := time.Now()
+	doc, err := Parse(content, strict)
+	report.Duration = time.Since(start)
+	if err != nil {
+		return nil, report, err
+	}
+
+	size, err := doc.documentBytes()
+	if err != nil {
+		doc.Close()
+		return nil, report, err
+	}
+	report.DocumentBytes = size
+	return doc, report, nil
+}
+
+// parseMemoryFactor and parseMemoryOverhead model the native memory a parse
+// needs: a fixed overhead plus a multiple of the input size covering the
+// C copy of the input, the parsed node tree and the parser's transient
+// allocations. A finished document of small rows holds about 12 times its
+// input, and the peak while parsing is about twice that, so the factor
+// leaves some headroom above the peak; TestEstimateParseMemoryCoversDocument
+// checks it against the native size of a parsed document.
+const (
+	parseMemoryFactor   = 32
+	parseMemoryOverhead = 64 << 10
+)
+
+// EstimateParseMemory returns an estimate, in bytes, of the native memory
+// needed to parse and hold a document of inputBytes bytes, so callers can
+// reject inputs that would not fit before handing them to the parser. The
+// estimate is an upper bound for typical documents, not a guarantee.
+func EstimateParseMemory(inputBytes int64) int64 {
+	if inputBytes <= 0 {
+		return parseMemoryOverhead
+	}
+	if inputBytes > (math.MaxInt64-parseMemoryOverhead)/parseMemoryFactor {
+		return math.MaxInt64
+	}
+	return inputBytes*parseMemoryFactor + parseMemoryOverhead
+}
+
+// Info returns a short human-readable summary of the document: its version,
+// each schema with its row count, the alias count, the total number of
+// scalar values and how much smaller the canonical HEDL is than the JSON
+// export without metadata.
+func (d *Document) Info() (string, error) {
+	m, err := d.model()
+	if err != nil {
+		return "", err
+	}
+	canonical, err := d.Canonicalize()
+	if err != nil {
+		return "", err
+	}
+	json, err := d.ToJSON(false)
+	if err != nil {
+		return "", err
+	}
+
+	rows := make(map[string]int)
+	m.eachList(func(list *matrixList) {
+		rows[list.typeName] += len(list.rows)
+	})
+	values := 0
+	m.eachKeyValue(func(string, interface{}) { values++ })
+	m.eachCell(func(*matrixList, int, int, interface{}) { values++ })
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Version:     %d.%d\n", m.major, m.minor)
+	schemas := m.allSchemas()
+	fmt.Fprintf(&b, "Schemas:     %d\n", len(schemas))
+	for _, def := range schemas {
+		fmt.Fprintf(&b, "  %-10s %d rows\n", def.name, rows[def.name])
+	}
+	fmt.Fprintf(&b, "Aliases:     %d\n", len(m.aliases))
+	fmt.Fprintf(&b, "Values:      %d\n", values)
+	if len(canonical) > 0 {
+		fmt.Fprintf(&b, "Compression: %.2fx vs JSON (%d bytes HEDL, %d bytes JSON)\n",
+			float64(len(json))/float64(len(canonical)), len(canonical), len(json))
+	}
+	return b.String(), nil
+}
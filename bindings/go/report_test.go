@@ -0,0 +1,109 @@
+package hedl
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseWithReport(t *testing.T) {
+	doc, report, err := ParseWithReport(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("ParseWithReport failed: %v", err)
+	}
+	defer doc.Close()
+
+	if report.Duration <= 0 {
+		t.Errorf("Expected nonzero duration, got %v", report.Duration)
+	}
+	if report.InputBytes != len(sampleHEDL) {
+		t.Errorf("Expected %d input bytes, got %d", len(sampleHEDL), report.InputBytes)
+	}
+	if report.DocumentBytes <= 0 {
+		t.Errorf("Expected positive document size, got %d", report.DocumentBytes)
+	}
+
+	long, report, err := ParseWithReport("%VERSION: 1.0\n---\nbody: "+strings.Repeat("x", 10000)+"\n", true)
+	if err != nil {
+		t.Fatalf("ParseWithReport failed: %v", err)
+	}
+	defer long.Close()
+	if report.DocumentBytes < 10000 {
+		t.Errorf("Expected the document size to cover a 10000-byte value, got %d", report.DocumentBytes)
+	}
+}
+
+func TestParseWithReportError(t *testing.T) {
+	doc, report, err := ParseWithReport("invalid content", true)
+	if err == nil {
+		doc.Close()
+		t.Fatal("Expected error for invalid content")
+	}
+	if report.InputBytes != len("invalid content") {
+		t.Errorf("Expected input bytes to be reported on failure, got %d", report.InputBytes)
+	}
+}
+
+func TestInfo(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	info, err := doc.Info()
+	if err != nil {
+		t.Fatalf("Info failed: %v", err)
+	}
+	for _, want := range []string{"Version:     1.0", "User", "2 rows", "Aliases:     0", "Values:      6", "Compression:"} {
+		if !strings.Contains(info, want) {
+			t.Errorf("Expected %q in report:\n%s", want, info)
+		}
+	}
+}
+
+func TestEstimateParseMemory(t *testing.T) {
+	small := EstimateParseMemory(1 << 20)
+	large := EstimateParseMemory(10 << 20)
+	if small <= 1<<20 || small > 64<<20 {
+		t.Errorf("Expected a plausible multiple of 1 MiB, got %d", small)
+	}
+	if large <= small*9 || large >= small*11 {
+		t.Errorf("Expected the estimate to scale with input size, got %d for 1 MiB and %d for 10 MiB", small, large)
+	}
+	if got := EstimateParseMemory(math.MaxInt64); got != math.MaxInt64 {
+		t.Errorf("Expected the estimate to saturate, got %d", got)
+	}
+}
+
+// TestEstimateParseMemoryCoversDocument checks the estimate against the
+// native library's own count of the memory a parsed document holds, with room
+// for the parse peak of about twice that. Process-wide measures such as the
+// resident set also move with the Go runtime and other tests, so they are not
+// used here.
+func TestEstimateParseMemoryCoversDocument(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("%VERSION: 1.0\n%STRUCT: Employee: [id, name, dept, salary]\n---\nemployees: @Employee\n")
+	for i := 0; i < 100000; i++ {
+		fmt.Fprintf(&b, "  | e%d, Employee %d, Dept %d, %d\n", i, i, i%10, 50000+i)
+	}
+	content := b.String()
+
+	doc, err := Parse(content, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+	held, err := doc.documentBytes()
+	if err != nil {
+		t.Fatalf("documentBytes failed: %v", err)
+	}
+
+	estimate := EstimateParseMemory(int64(len(content)))
+	t.Logf("input %d bytes, document %d bytes (%.1fx), estimate %d bytes",
+		len(content), held, float64(held)/float64(len(content)), estimate)
+	if 2*held > estimate {
+		t.Errorf("Twice the document size, %d, exceeds estimate %d; raise parseMemoryFactor", 2*held, estimate)
+	}
+}
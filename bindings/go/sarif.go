@@ -0,0 +1,146 @@
+package hedl
+
+import "encoding/json"
+
+// SARIF 2.1.0 log structure, limited to what ToSARIF emits.
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules,omitempty"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId,omitempty"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation *sarifArtifactLocation `json:"artifactLocation,omitempty"`
+	Region           *sarifRegion           `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+// ToSARIF renders the diagnostics as a SARIF 2.1.0 log with a single run
+// attributed to toolName and one result per diagnostic.
+//
+// Errors map to the "error" level, warnings to "warning" and hints to
+// "note". The rule ID is the diagnostic's RuleID and the region is taken
+// from its Line, Column, EndLine and EndColumn; positions that are 0 are
+// left out, and a diagnostic without a line has no location. Locations
+// carry no artifact; use ToSARIFWithArtifact to name the file the
+// diagnostics belong to.
+func (d *Diagnostics) ToSARIF(toolName string) (string, error) {
+	return d.toSARIF(toolName, "")
+}
+
+// ToSARIFWithArtifact renders the diagnostics as ToSARIF does, with every
+// result located in the artifact artifactURI, such as "config/users.hedl".
+// A diagnostic without a line is located in the artifact with no region.
+// An empty artifactURI returns ErrInvalidArgument.
+func (d *Diagnostics) ToSARIFWithArtifact(toolName, artifactURI string) (string, error) {
+	if artifactURI == "" {
+		return "", &HedlError{Message: "artifact URI is empty", Code: ErrInvalidArgument}
+	}
+	return d.toSARIF(toolName, artifactURI)
+}
+
+// toSARIF renders the SARIF log, locating results in artifactURI unless it
+// is empty.
+func (d *Diagnostics) toSARIF(toolName, artifactURI string) (string, error) {
+	all, err := d.All()
+	if err != nil {
+		return "", err
+	}
+
+	run := sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: toolName}},
+		Results: make([]sarifResult, 0, len(all)),
+	}
+	seen := make(map[string]bool)
+	for _, diag := range all {
+		var location sarifPhysicalLocation
+		if artifactURI != "" {
+			location.ArtifactLocation = &sarifArtifactLocation{URI: artifactURI}
+		}
+		if diag.Line > 0 {
+			location.Region = &sarifRegion{
+				StartLine:   diag.Line,
+				StartColumn: diag.Column,
+				EndLine:     diag.EndLine,
+				EndColumn:   diag.EndColumn,
+			}
+		}
+		result := sarifResult{
+			RuleID:  diag.RuleID,
+			Level:   sarifLevel(diag.Severity),
+			Message: sarifMessage{Text: diag.Message},
+		}
+		if location.ArtifactLocation != nil || location.Region != nil {
+			result.Locations = []sarifLocation{{PhysicalLocation: location}}
+		}
+		if result.RuleID != "" && !seen[result.RuleID] {
+			seen[result.RuleID] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: result.RuleID})
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	data, err := json.MarshalIndent(sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// sarifLevel maps a diagnostic severity to a SARIF result level.
+func sarifLevel(severity int) string {
+	switch severity {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return "note"
+}
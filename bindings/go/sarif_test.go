@@ -0,0 +1,122 @@
+package hedl
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// sarifDiagnostics holds a located warning and an error without a line.
+func sarifDiagnostics() *Diagnostics {
+	return newDiagnostics([]*Diagnostic{
+		{
+			Message:  "line 3: [id-naming] warning: ID should be lowercase",
+			Severity: SeverityWarning,
+			Line:     3,
+			EndLine:  3,
+			RuleID:   "id-naming",
+		},
+		newDiagnostic(SeverityError, "undefined-schema", "orders references undefined schema %q", "Customer"),
+	})
+}
+
+// sarifOutput is the part of a SARIF log the tests inspect.
+type sarifOutput struct {
+	Version string `json:"version"`
+	Runs    []struct {
+		Tool struct {
+			Driver struct {
+				Name string `json:"name"`
+			} `json:"driver"`
+		} `json:"tool"`
+		Results []struct {
+			RuleID    string `json:"ruleId"`
+			Level     string `json:"level"`
+			Locations []struct {
+				PhysicalLocation struct {
+					ArtifactLocation struct {
+						URI string `json:"uri"`
+					} `json:"artifactLocation"`
+					Region *struct {
+						StartLine   int `json:"startLine"`
+						StartColumn int `json:"startColumn"`
+						EndLine     int `json:"endLine"`
+					} `json:"region"`
+				} `json:"physicalLocation"`
+			} `json:"locations"`
+		} `json:"results"`
+	} `json:"runs"`
+}
+
+func TestToSARIF(t *testing.T) {
+	sarif, err := sarifDiagnostics().ToSARIF("hedl-lint")
+	if err != nil {
+		t.Fatalf("ToSARIF failed: %v", err)
+	}
+
+	var log sarifOutput
+	if err := json.Unmarshal([]byte(sarif), &log); err != nil {
+		t.Fatalf("Output is not valid JSON: %v\n%s", err, sarif)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || log.Runs[0].Tool.Driver.Name != "hedl-lint" {
+		t.Fatalf("Unexpected SARIF envelope:\n%s", sarif)
+	}
+
+	results := log.Runs[0].Results
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	if results[0].RuleID != "id-naming" || results[0].Level != "warning" {
+		t.Errorf("Unexpected first result: %+v", results[0])
+	}
+	if len(results[0].Locations) != 1 {
+		t.Fatalf("Expected one location for the first result, got %+v", results[0].Locations)
+	}
+	location := results[0].Locations[0].PhysicalLocation
+	if location.ArtifactLocation.URI != "" {
+		t.Errorf("Expected no artifact, got %q", location.ArtifactLocation.URI)
+	}
+	if location.Region == nil || location.Region.StartLine != 3 || location.Region.EndLine != 3 || location.Region.StartColumn != 0 {
+		t.Errorf("Expected a region on line 3 without a column, got %+v", location.Region)
+	}
+	if results[1].RuleID != "undefined-schema" || results[1].Level != "error" {
+		t.Errorf("Unexpected second result: %+v", results[1])
+	}
+	if len(results[1].Locations) != 0 {
+		t.Errorf("Expected no location for a diagnostic without a line, got %+v", results[1].Locations)
+	}
+}
+
+func TestToSARIFWithArtifact(t *testing.T) {
+	sarif, err := sarifDiagnostics().ToSARIFWithArtifact("hedl-lint", "config/users.hedl")
+	if err != nil {
+		t.Fatalf("ToSARIFWithArtifact failed: %v", err)
+	}
+
+	var log sarifOutput
+	if err := json.Unmarshal([]byte(sarif), &log); err != nil {
+		t.Fatalf("Output is not valid JSON: %v\n%s", err, sarif)
+	}
+	results := log.Runs[0].Results
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+	for i, result := range results {
+		if len(result.Locations) != 1 || result.Locations[0].PhysicalLocation.ArtifactLocation.URI != "config/users.hedl" {
+			t.Errorf("Expected result %d to be located in config/users.hedl, got %+v", i, result.Locations)
+		}
+	}
+	region := results[0].Locations[0].PhysicalLocation.Region
+	if region == nil || region.StartLine != 3 {
+		t.Errorf("Expected a region on line 3, got %+v", region)
+	}
+	if results[1].Locations[0].PhysicalLocation.Region != nil {
+		t.Errorf("Expected no region for a diagnostic without a line, got %+v", results[1].Locations[0])
+	}
+
+	_, err = newDiagnostics(nil).ToSARIFWithArtifact("hedl-lint", "")
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrInvalidArgument {
+		t.Errorf("Expected ErrInvalidArgument for an empty artifact URI, got %v", err)
+	}
+}
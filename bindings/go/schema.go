@@ -0,0 +1,249 @@
+package hedl
+
+import "fmt"
+
+// schemaColumns returns the columns of schemaName, declared or inline.
+func (m *docModel) schemaColumns(schemaName string) ([]string, error) {
+	for _, def := range m.allSchemas() {
+		if def.name == schemaName {
+			return def.columns, nil
+		}
+	}
+	return nil, &HedlError{
+		Message: fmt.Sprintf("schema %q not found", schemaName),
+		Code:    ErrNotFound,
+	}
+}
+
+// setColumns replaces the columns of schemaName in its declaration and in
+// every list of that type.
+func (m *docModel) setColumns(schemaName string, columns []string) {
+	for i := range m.structs {
+		if m.structs[i].name == schemaName {
+			m.structs[i].columns = columns
+		}
+	}
+	m.eachList(func(list *matrixList) {
+		if list.typeName == schemaName {
+			list.schema = columns
+		}
+	})
+}
+
+// fieldIndex returns the index of field in columns, or an ErrNotFound error.
+func fieldIndex(schemaName string, columns []string, field string) (int, error) {
+	for i, col := range columns {
+		if col == field {
+			return i, nil
+		}
+	}
+	return -1, &HedlError{
+		Message: fmt.Sprintf("field %q not found in schema %q", field, schemaName),
+		Code:    ErrNotFound,
+	}
+}
+
+// AddField appends field to schemaName and sets it to defaultValue in every
+// existing row. defaultValue is read as HEDL cell text, so "0" is an
+// integer, "~" is null and "@User:alice" is a reference. It returns
+// ErrNotFound for an unknown schema and ErrConflict if the field exists.
+func (d *Document) AddField(schemaName, field, defaultValue string) error {
+	m, err := d.model()
+	if err != nil {
+		return err
+	}
+	columns, err := m.schemaColumns(schemaName)
+	if err != nil {
+		return err
+	}
+	if _, err := fieldIndex(schemaName, columns, field); err == nil {
+		return &HedlError{
+			Message: fmt.Sprintf("field %q already exists in schema %q", field, schemaName),
+			Code:    ErrConflict,
+		}
+	}
+
+	value := parseCell(defaultValue)
+	m.setColumns(schemaName, append(append([]string(nil), columns...), field))
+	m.eachList(func(list *matrixList) {
+		if list.typeName != schemaName {
+			return
+		}
+		for _, row := range list.rows {
+			row.values = append(row.values, value)
+		}
+	})
+	return d.replaceWith(m)
+}
+
+// DropField removes field from schemaName and from every row. It returns
+// ErrNotFound for an unknown schema or field and ErrInvalidArgument for the
+// ID column, which every schema must keep.
+func (d *Document) DropField(schemaName, field string) error {
+	m, err := d.model()
+	if err != nil {
+		return err
+	}
+	columns, err := m.schemaColumns(schemaName)
+	if err != nil {
+		return err
+	}
+	idx, err := fieldIndex(schemaName, columns, field)
+	if err != nil {
+		return err
+	}
+	if idx == 0 {
+		return &HedlError{
+			Message: fmt.Sprintf("cannot drop ID field %q of schema %q", field, schemaName),
+			Code:    ErrInvalidArgument,
+		}
+	}
+
+	dropped := append(append([]string(nil), columns[:idx]...), columns[idx+1:]...)
+	m.setColumns(schemaName, dropped)
+	m.eachList(func(list *matrixList) {
+		if list.typeName != schemaName {
+			return
+		}
+		for _, row := range list.rows {
+			if idx < len(row.values) {
+				row.values = append(row.values[:idx], row.values[idx+1:]...)
+			}
+		}
+	})
+	return d.replaceWith(m)
+}
+
+// CommonFields returns the fields present in every schema in schemas, in the
+// order they appear in the first. An unknown schema returns ErrNotFound and
+// an empty list of schemas returns ErrInvalidArgument.
+func CommonFields(doc *Document, schemas []string) ([]string, error) {
+	if len(schemas) == 0 {
+		return nil, &HedlError{Message: "no schemas given", Code: ErrInvalidArgument}
+	}
+	m, err := doc.model()
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	var first []string
+	for i, name := range schemas {
+		columns, err := m.schemaColumns(name)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			first = columns
+		}
+		seen := make(map[string]bool, len(columns))
+		for _, col := range columns {
+			if !seen[col] {
+				seen[col] = true
+				counts[col]++
+			}
+		}
+	}
+
+	common := make([]string, 0, len(first))
+	for _, col := range first {
+		if counts[col] == len(schemas) {
+			common = append(common, col)
+		}
+	}
+	return common, nil
+}
+
+// SchemaCompatible reports whether the rows of other could be merged into d:
+// every schema defined in both documents must have the same fields with
+// compatible inferred types (see FieldDescriptor). Schemas defined in only
+// one document do not affect compatibility.
+//
+// Each field missing from one side and each type mismatch is an error
+// diagnostic. Fields in a different order are a warning, since the rows
+// still merge by name. Types are compatible when equal, when either is
+// "any", or when one is "int" and the other "float".
+func (d *Document) SchemaCompatible(other *Document) (bool, *Diagnostics, error) {
+	mine, err := d.SchemaDescriptors()
+	if err != nil {
+		return false, nil, err
+	}
+	theirs, err := other.SchemaDescriptors()
+	if err != nil {
+		return false, nil, err
+	}
+	byName := make(map[string]SchemaDescriptor, len(theirs))
+	for _, desc := range theirs {
+		byName[desc.Name] = desc
+	}
+
+	var items []*Diagnostic
+	compatible := true
+	for _, a := range mine {
+		b, ok := byName[a.Name]
+		if !ok {
+			continue
+		}
+		mismatch := false
+		fieldsA := make(map[string]FieldDescriptor, len(a.Fields))
+		for _, f := range a.Fields {
+			fieldsA[f.Name] = f
+		}
+		fieldsB := make(map[string]FieldDescriptor, len(b.Fields))
+		for _, f := range b.Fields {
+			fieldsB[f.Name] = f
+		}
+
+		for _, fa := range a.Fields {
+			fb, ok := fieldsB[fa.Name]
+			if !ok {
+				mismatch = true
+				items = append(items, newDiagnostic(SeverityError, "schema-missing-field",
+					"%s field %q is missing from the other document", a.Name, fa.Name))
+				continue
+			}
+			if !compatibleTypes(fa.Type, fb.Type) {
+				mismatch = true
+				items = append(items, newDiagnostic(SeverityError, "schema-type-mismatch",
+					"%s field %q is %s here but %s in the other document", a.Name, fa.Name, fa.Type, fb.Type))
+			}
+		}
+		for _, fb := range b.Fields {
+			if _, ok := fieldsA[fb.Name]; !ok {
+				mismatch = true
+				items = append(items, newDiagnostic(SeverityError, "schema-missing-field",
+					"%s field %q exists only in the other document", a.Name, fb.Name))
+			}
+		}
+		if mismatch {
+			compatible = false
+		} else if !sameFieldOrder(a.Fields, b.Fields) {
+			items = append(items, newDiagnostic(SeverityWarning, "schema-field-order",
+				"%s fields are declared in a different order", a.Name))
+		}
+	}
+	return compatible, newDiagnostics(items), nil
+}
+
+// compatibleTypes reports whether values of inferred types a and b can share
+// a field.
+func compatibleTypes(a, b string) bool {
+	if a == b || a == "any" || b == "any" {
+		return true
+	}
+	return (a == "int" && b == "float") || (a == "float" && b == "int")
+}
+
+// sameFieldOrder reports whether a and b list the same field names in the
+// same order.
+func sameFieldOrder(a, b []FieldDescriptor) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,332 @@
+package hedl
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSchemaDescriptors(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	descriptors, err := doc.SchemaDescriptors()
+	if err != nil {
+		t.Fatalf("SchemaDescriptors failed: %v", err)
+	}
+
+	want := []SchemaDescriptor{{
+		Name: "User",
+		Fields: []FieldDescriptor{
+			{Name: "id", Type: "string"},
+			{Name: "name", Type: "string"},
+			{Name: "email", Type: "string"},
+		},
+	}}
+	if !reflect.DeepEqual(descriptors, want) {
+		t.Fatalf("Expected %+v, got %+v", want, descriptors)
+	}
+}
+
+func TestSchemaDescriptorTypes(t *testing.T) {
+	doc, err := Parse(`%VERSION: 1.0
+%STRUCT: Reading: [id, count, value, label, note]
+---
+readings: @Reading
+  | r1, 3, 1, a, ~
+  | r2, 4, 2.5, 7, ~
+`, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	descriptors, err := doc.SchemaDescriptors()
+	if err != nil {
+		t.Fatalf("SchemaDescriptors failed: %v", err)
+	}
+	want := []FieldDescriptor{
+		{Name: "id", Type: "string"},
+		{Name: "count", Type: "int"},
+		{Name: "value", Type: "float"},
+		{Name: "label", Type: "any"},
+		{Name: "note", Type: "any", Optional: true},
+	}
+	if len(descriptors) != 1 || !reflect.DeepEqual(descriptors[0].Fields, want) {
+		t.Errorf("Expected fields %+v, got %+v", want, descriptors)
+	}
+}
+
+func TestSchemaChecksum(t *testing.T) {
+	checksum := func(content string) string {
+		t.Helper()
+		doc, err := Parse(content, true)
+		if err != nil {
+			t.Fatalf("Parse failed: %v", err)
+		}
+		defer doc.Close()
+		sum, err := doc.SchemaChecksum("User")
+		if err != nil {
+			t.Fatalf("SchemaChecksum failed: %v", err)
+		}
+		return sum
+	}
+
+	base := checksum(sampleHEDL)
+	otherRows := checksum(`%VERSION: 1.0
+%STRUCT: User: [id, name, email]
+---
+users: @User
+  | carol, Carol White, carol@example.com
+`)
+	if base != otherRows {
+		t.Errorf("Expected checksum to ignore row values, got %s and %s", base, otherRows)
+	}
+
+	extraField := checksum(`%VERSION: 1.0
+%STRUCT: User: [id, name, email, role]
+---
+users: @User
+  | alice, Alice Smith, alice@example.com, admin
+`)
+	if base == extraField {
+		t.Error("Expected checksum to change when a field is added")
+	}
+
+	mixedTypes := checksum(`%VERSION: 1.0
+%STRUCT: User: [id, name, email]
+---
+users: @User
+  | alice, 42, ~
+  | bob, Bob Jones, 3.5
+`)
+	if base != mixedTypes {
+		t.Errorf("Expected checksum to ignore value types, got %s and %s", base, mixedTypes)
+	}
+}
+
+func TestRenameField(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	if err := doc.RenameField("User", "email", "mail"); err != nil {
+		t.Fatalf("RenameField failed: %v", err)
+	}
+	json, err := doc.ToJSON(false)
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	if !strings.Contains(json, `"mail"`) || strings.Contains(json, `"email"`) {
+		t.Errorf("Expected email to be renamed to mail in:\n%s", json)
+	}
+
+	var hedlErr *HedlError
+	if err := doc.RenameField("User", "name", "id"); !errors.As(err, &hedlErr) || hedlErr.Code != ErrConflict {
+		t.Errorf("Expected ErrConflict, got %v", err)
+	}
+	if err := doc.RenameField("User", "email", "address"); !errors.As(err, &hedlErr) || hedlErr.Code != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for a missing field, got %v", err)
+	}
+	if err := doc.RenameField("Missing", "id", "key"); !errors.As(err, &hedlErr) || hedlErr.Code != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for a missing schema, got %v", err)
+	}
+}
+
+func TestRenameSchema(t *testing.T) {
+	doc, err := Parse(orderRefsHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	if err := doc.RenameSchema("Customer", "Client"); err != nil {
+		t.Fatalf("RenameSchema failed: %v", err)
+	}
+	json, err := doc.ToJSON(true)
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	if !strings.Contains(json, "@Client:c1") || strings.Contains(json, "Customer\"") {
+		t.Errorf("Expected Customer to be renamed to Client in:\n%s", json)
+	}
+
+	var hedlErr *HedlError
+	if err := doc.RenameSchema("Client", "Order"); !errors.As(err, &hedlErr) || hedlErr.Code != ErrConflict {
+		t.Errorf("Expected ErrConflict, got %v", err)
+	}
+}
+
+func TestAddField(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	if err := doc.AddField("User", "active", "true"); err != nil {
+		t.Fatalf("AddField failed: %v", err)
+	}
+	m, err := doc.model()
+	if err != nil {
+		t.Fatalf("model failed: %v", err)
+	}
+	lists, err := m.listsOf("User")
+	if err != nil {
+		t.Fatalf("listsOf failed: %v", err)
+	}
+	if got := strings.Join(lists[0].schema, ","); got != "id,name,email,active" {
+		t.Errorf("Expected active to be appended to the schema, got %s", got)
+	}
+	for i, row := range lists[0].rows {
+		if row.values[3] != true {
+			t.Errorf("Expected row %d to be backfilled with true, got %v", i, row.values[3])
+		}
+	}
+
+	var hedlErr *HedlError
+	if err := doc.AddField("User", "name", "x"); !errors.As(err, &hedlErr) || hedlErr.Code != ErrConflict {
+		t.Errorf("Expected ErrConflict, got %v", err)
+	}
+}
+
+func TestDropField(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	if err := doc.DropField("User", "email"); err != nil {
+		t.Fatalf("DropField failed: %v", err)
+	}
+	json, err := doc.ToJSON(false)
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+	if strings.Contains(json, "email") {
+		t.Errorf("Expected email to be dropped from:\n%s", json)
+	}
+
+	var hedlErr *HedlError
+	if err := doc.DropField("User", "id"); !errors.As(err, &hedlErr) || hedlErr.Code != ErrInvalidArgument {
+		t.Errorf("Expected ErrInvalidArgument for the ID field, got %v", err)
+	}
+	if err := doc.DropField("User", "email"); !errors.As(err, &hedlErr) || hedlErr.Code != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for a dropped field, got %v", err)
+	}
+}
+
+func TestSchemaCompatible(t *testing.T) {
+	const otherUsers = `%VERSION: 1.0
+%STRUCT: User: [id, name, email]
+%STRUCT: Team: [id, label]
+---
+users: @User
+  | carol, Carol White, carol@example.com
+teams: @Team
+  | t1, Core
+`
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+	other, err := Parse(otherUsers, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer other.Close()
+
+	ok, diag, err := doc.SchemaCompatible(other)
+	if err != nil {
+		t.Fatalf("SchemaCompatible failed: %v", err)
+	}
+	defer diag.Close()
+	if !ok || diag.Count() != 0 {
+		all, _ := diag.All()
+		t.Errorf("Expected compatible schemas without diagnostics, got %v", all)
+	}
+}
+
+func TestSchemaCompatibleMismatch(t *testing.T) {
+	const mismatched = `%VERSION: 1.0
+%STRUCT: User: [id, name, age]
+---
+users: @User
+  | carol, 42, 30
+`
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+	other, err := Parse(mismatched, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer other.Close()
+
+	ok, diag, err := doc.SchemaCompatible(other)
+	if err != nil {
+		t.Fatalf("SchemaCompatible failed: %v", err)
+	}
+	defer diag.Close()
+	if ok {
+		t.Error("Expected incompatible schemas")
+	}
+	errs, err := diag.Errors()
+	if err != nil {
+		t.Fatalf("Errors failed: %v", err)
+	}
+	for _, want := range []string{`"email" is missing`, `"age" exists only`, `"name" is string here but int`} {
+		found := false
+		for _, msg := range errs {
+			if strings.Contains(msg, want) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected a diagnostic containing %q, got %v", want, errs)
+		}
+	}
+}
+
+// staffHEDL has two schemas sharing id and name, in different positions.
+const staffHEDL = `%VERSION: 1.0
+%STRUCT: Employee: [id, name, email, team]
+%STRUCT: Contractor: [id, agency, name, email_domain]
+---
+employees: @Employee
+  | e1, Alice, alice@example.com, core
+contractors: @Contractor
+  | c1, Acme, Bob, acme.example
+`
+
+func TestCommonFields(t *testing.T) {
+	doc, err := Parse(staffHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	fields, err := CommonFields(doc, []string{"Employee", "Contractor"})
+	if err != nil {
+		t.Fatalf("CommonFields failed: %v", err)
+	}
+	if strings.Join(fields, ",") != "id,name" {
+		t.Errorf("Expected [id name], got %q", fields)
+	}
+
+	_, err = CommonFields(doc, []string{"Employee", "Vendor"})
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for an unknown schema, got %v", err)
+	}
+}
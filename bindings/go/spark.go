@@ -0,0 +1,165 @@
+package hedl
+
+import (
+	"encoding/json"
+)
+
+// Spark StructType JSON, as produced by StructType.json and read by
+// DataType.fromJson.
+type sparkStruct struct {
+	Type   string       `json:"type"`
+	Fields []sparkField `json:"fields"`
+}
+
+type sparkField struct {
+	Name     string                 `json:"name"`
+	Type     interface{}            `json:"type"`
+	Nullable bool                   `json:"nullable"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+type sparkArray struct {
+	Type         string      `json:"type"`
+	ElementType  interface{} `json:"elementType"`
+	ContainsNull bool        `json:"containsNull"`
+}
+
+// ToSparkSchema returns a Spark StructType, in Spark's JSON schema format,
+// describing the JSON export without metadata, so the output of ToJSON(false)
+// can be read with an explicit schema.
+//
+// Matrix lists become arrays of structs whose fields follow the HEDL schema,
+// with nested children as array fields named by child type, and objects
+// become structs. Field types are inferred as in SchemaDescriptors: int maps
+// to long, float to double, bool to boolean, and string, expression and
+// mixed ("any") fields to string. References become a struct with a single
+// "@ref" string field and tensors become nested arrays of double. Fields
+// holding null are nullable.
+func (d *Document) ToSparkSchema() (string, error) {
+	m, err := d.model()
+	if err != nil {
+		return "", err
+	}
+	schemas, err := d.SchemaDescriptors()
+	if err != nil {
+		return "", err
+	}
+	descriptors := make(map[string]SchemaDescriptor)
+	for _, desc := range schemas {
+		descriptors[desc.Name] = desc
+	}
+
+	data, err := json.MarshalIndent(m.sparkObject(m.root, descriptors), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	out := string(data)
+	if err := checkStringOutputSize(out); err != nil {
+		return "", err
+	}
+	return out, nil
+}
+
+// sparkObject describes an object as a struct.
+func (m *docModel) sparkObject(obj *object, descriptors map[string]SchemaDescriptor) sparkStruct {
+	st := sparkStruct{Type: "struct", Fields: []sparkField{}}
+	for _, key := range obj.keys {
+		field := sparkField{Name: key, Metadata: map[string]interface{}{}}
+		switch v := obj.values[key].(type) {
+		case *object:
+			field.Type = m.sparkObject(v, descriptors)
+		case *matrixList:
+			field.Type = sparkArray{Type: "array", ElementType: m.sparkRow(v, descriptors)}
+		case nil:
+			field.Type, field.Nullable = "string", true
+		default:
+			field.Type = sparkValueType(valueType(v), v)
+		}
+		st.Fields = append(st.Fields, field)
+	}
+	return st
+}
+
+// sparkRow describes the rows of a list as a struct.
+func (m *docModel) sparkRow(list *matrixList, descriptors map[string]SchemaDescriptor) sparkStruct {
+	st := sparkStruct{Type: "struct", Fields: []sparkField{}}
+	desc := descriptors[list.typeName]
+	for i, col := range list.schema {
+		typ, nullable := "any", false
+		if i < len(desc.Fields) && desc.Fields[i].Name == col {
+			typ, nullable = desc.Fields[i].Type, desc.Fields[i].Optional
+		}
+		var sample interface{}
+		for _, row := range list.rows {
+			if i < len(row.values) && row.values[i] != nil {
+				sample = row.values[i]
+				break
+			}
+		}
+		st.Fields = append(st.Fields, sparkField{
+			Name:     col,
+			Type:     sparkValueType(typ, sample),
+			Nullable: nullable,
+			Metadata: map[string]interface{}{},
+		})
+	}
+
+	// Child lists of the same type share one array field, in the order the
+	// types first appear.
+	children := make(map[string]*matrixList)
+	var order []string
+	for _, row := range list.rows {
+		for _, child := range row.children {
+			if existing, ok := children[child.typeName]; ok {
+				existing.rows = append(existing.rows, child.rows...)
+				continue
+			}
+			merged := &matrixList{typeName: child.typeName, schema: child.schema}
+			merged.rows = append(merged.rows, child.rows...)
+			children[child.typeName] = merged
+			order = append(order, child.typeName)
+		}
+	}
+	for _, typeName := range order {
+		st.Fields = append(st.Fields, sparkField{
+			Name:     typeName,
+			Type:     sparkArray{Type: "array", ElementType: m.sparkRow(children[typeName], descriptors)},
+			Nullable: true,
+			Metadata: map[string]interface{}{},
+		})
+	}
+	return st
+}
+
+// sparkValueType maps an inferred HEDL type to a Spark type. sample is a
+// value of that type, used to find the depth of tensors.
+func sparkValueType(typ string, sample interface{}) interface{} {
+	switch typ {
+	case "int":
+		return "long"
+	case "float":
+		return "double"
+	case "bool":
+		return "boolean"
+	case "reference":
+		return sparkStruct{Type: "struct", Fields: []sparkField{{
+			Name: "@ref", Type: "string", Metadata: map[string]interface{}{},
+		}}}
+	case "tensor":
+		var elem interface{} = "double"
+		items, _ := sample.([]interface{})
+		for {
+			elem = sparkArray{Type: "array", ElementType: elem}
+			if len(items) == 0 {
+				break
+			}
+			inner, ok := items[0].([]interface{})
+			if !ok {
+				break
+			}
+			items = inner
+		}
+		return elem
+	}
+	return "string"
+}
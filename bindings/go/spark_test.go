@@ -0,0 +1,78 @@
+package hedl
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestToSparkSchema(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	schema, err := doc.ToSparkSchema()
+	if err != nil {
+		t.Fatalf("ToSparkSchema failed: %v", err)
+	}
+
+	type field struct {
+		Name     string          `json:"name"`
+		Type     json.RawMessage `json:"type"`
+		Nullable bool            `json:"nullable"`
+	}
+	var root struct {
+		Type   string  `json:"type"`
+		Fields []field `json:"fields"`
+	}
+	if err := json.Unmarshal([]byte(schema), &root); err != nil {
+		t.Fatalf("Output is not valid JSON: %v\n%s", err, schema)
+	}
+	if root.Type != "struct" || len(root.Fields) != 1 || root.Fields[0].Name != "users" {
+		t.Fatalf("Unexpected root struct:\n%s", schema)
+	}
+
+	var users struct {
+		Type        string `json:"type"`
+		ElementType struct {
+			Type   string  `json:"type"`
+			Fields []field `json:"fields"`
+		} `json:"elementType"`
+	}
+	if err := json.Unmarshal(root.Fields[0].Type, &users); err != nil || users.Type != "array" {
+		t.Fatalf("Expected users to be an array of structs:\n%s", schema)
+	}
+	want := []string{"id", "name", "email"}
+	if len(users.ElementType.Fields) != len(want) {
+		t.Fatalf("Expected %d row fields, got %d", len(want), len(users.ElementType.Fields))
+	}
+	for i, f := range users.ElementType.Fields {
+		if f.Name != want[i] || string(f.Type) != `"string"` || f.Nullable {
+			t.Errorf("Field %d: expected non-nullable string %q, got %s %s (nullable %v)", i, want[i], f.Name, f.Type, f.Nullable)
+		}
+	}
+}
+
+func TestSparkValueType(t *testing.T) {
+	tests := []struct {
+		typ    string
+		sample interface{}
+		want   string
+	}{
+		{"int", json.Number("1"), `"long"`},
+		{"float", json.Number("1.5"), `"double"`},
+		{"bool", true, `"boolean"`},
+		{"any", nil, `"string"`},
+		{"tensor", []interface{}{[]interface{}{json.Number("1")}}, `{"type":"array","elementType":{"type":"array","elementType":"double","containsNull":false},"containsNull":false}`},
+	}
+	for _, tt := range tests {
+		data, err := json.Marshal(sparkValueType(tt.typ, tt.sample))
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("sparkValueType(%q) = %s, want %s", tt.typ, data, tt.want)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package hedl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// sqlDialect describes the identifier quoting, string escaping, upsert
+// clause and column types of a SQL dialect.
+type sqlDialect struct {
+	quote            func(name string) string
+	upsert           func(key string, columns []string) string
+	backslashEscapes bool
+	columnType       func(fieldType string) string
+}
+
+func quoteDouble(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func quoteBacktick(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// onConflict builds the PostgreSQL / SQLite upsert clause.
+func onConflict(key string, columns []string) string {
+	if len(columns) == 0 {
+		return fmt.Sprintf(" ON CONFLICT (%s) DO NOTHING", quoteDouble(key))
+	}
+	sets := make([]string, len(columns))
+	for i, col := range columns {
+		sets[i] = fmt.Sprintf("%s = EXCLUDED.%s", quoteDouble(col), quoteDouble(col))
+	}
+	return fmt.Sprintf(" ON CONFLICT (%s) DO UPDATE SET %s", quoteDouble(key), strings.Join(sets, ", "))
+}
+
+// onDuplicateKey builds the MySQL upsert clause. MySQL infers the conflict
+// target from the table's unique keys.
+func onDuplicateKey(key string, columns []string) string {
+	if len(columns) == 0 {
+		columns = []string{key}
+	}
+	sets := make([]string, len(columns))
+	for i, col := range columns {
+		sets[i] = fmt.Sprintf("%s = VALUES(%s)", quoteBacktick(col), quoteBacktick(col))
+	}
+	return " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+}
+
+// postgresColumnType returns the PostgreSQL type for an inferred field type.
+func postgresColumnType(fieldType string) string {
+	switch fieldType {
+	case "int":
+		return "BIGINT"
+	case "float":
+		return "DOUBLE PRECISION"
+	case "bool":
+		return "BOOLEAN"
+	}
+	return "TEXT"
+}
+
+// sqliteAffinity returns the SQLite column type for an inferred field type,
+// leaving fields of mixed type untyped.
+func sqliteAffinity(fieldType string) string {
+	switch fieldType {
+	case "int", "bool":
+		return "INTEGER"
+	case "float":
+		return "REAL"
+	case "any":
+		return ""
+	}
+	return "TEXT"
+}
+
+// mysqlColumnType returns the MySQL type for an inferred field type.
+func mysqlColumnType(fieldType string) string {
+	switch fieldType {
+	case "int":
+		return "BIGINT"
+	case "float":
+		return "DOUBLE"
+	case "bool":
+		return "BOOLEAN"
+	}
+	return "TEXT"
+}
+
+var sqlDialects = map[string]sqlDialect{
+	"postgres":   {quote: quoteDouble, upsert: onConflict, columnType: postgresColumnType},
+	"postgresql": {quote: quoteDouble, upsert: onConflict, columnType: postgresColumnType},
+	"sqlite":     {quote: quoteDouble, upsert: onConflict, columnType: sqliteAffinity},
+	"mysql":      {quote: quoteBacktick, upsert: onDuplicateKey, backslashEscapes: true, columnType: mysqlColumnType},
+}
+
+// lookupSQLDialect returns the dialect named by dialect, case-insensitively,
+// or an ErrInvalidArgument error.
+func lookupSQLDialect(dialect string) (sqlDialect, error) {
+	sqlDialect, ok := sqlDialects[strings.ToLower(dialect)]
+	if !ok {
+		return sqlDialect, &HedlError{
+			Message: fmt.Sprintf("unsupported SQL dialect %q", dialect),
+			Code:    ErrInvalidArgument,
+		}
+	}
+	return sqlDialect, nil
+}
+
+// ToSQLUpsert converts every matrix row to an idempotent SQL upsert, one
+// statement per row into a table named after the row's schema.
+//
+// dialect is one of "postgres" (or "postgresql"), "sqlite" or "mysql"; the
+// first two emit INSERT ... ON CONFLICT (key) DO UPDATE and MySQL emits
+// INSERT ... ON DUPLICATE KEY UPDATE. keyField names the conflict column and
+// must exist in every schema with rows; an empty keyField uses each schema's
+// ID column. Unsupported dialects and missing key fields return a HedlError
+// with code ErrInvalidArgument.
+func (d *Document) ToSQLUpsert(dialect string, keyField string) (string, error) {
+	sqlDialect, err := lookupSQLDialect(dialect)
+	if err != nil {
+		return "", err
+	}
+
+	m, err := d.model()
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	var walkErr error
+	m.eachList(func(list *matrixList) {
+		if walkErr != nil || len(list.rows) == 0 {
+			return
+		}
+		key := keyField
+		if key == "" {
+			key = list.schema[0]
+		}
+		if list.column(key) < 0 {
+			walkErr = &HedlError{
+				Message: fmt.Sprintf("key field %q not found in schema %q", key, list.typeName),
+				Code:    ErrInvalidArgument,
+			}
+			return
+		}
+
+		columns := make([]string, len(list.schema))
+		var updates []string
+		for i, col := range list.schema {
+			columns[i] = sqlDialect.quote(col)
+			if col != key {
+				updates = append(updates, col)
+			}
+		}
+		prefix := fmt.Sprintf("INSERT INTO %s (%s) VALUES (", sqlDialect.quote(list.typeName), strings.Join(columns, ", "))
+		suffix := ")" + sqlDialect.upsert(key, updates) + ";\n"
+
+		for _, row := range list.rows {
+			values := make([]string, len(row.values))
+			for i, value := range row.values {
+				literal, err := sqlLiteral(value, sqlDialect.backslashEscapes)
+				if err != nil {
+					walkErr = err
+					return
+				}
+				values[i] = literal
+			}
+			b.WriteString(prefix)
+			b.WriteString(strings.Join(values, ", "))
+			b.WriteString(suffix)
+		}
+	})
+	if walkErr != nil {
+		return "", walkErr
+	}
+
+	output := b.String()
+	if err := checkStringOutputSize(output); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// SchemaMigration returns the SQL statements that migrate tables created
+// from the schemas of oldDoc to the schemas of newDoc, one table per schema
+// as in ToSQLite, for dialect "postgres" (or "postgresql"), "sqlite" or
+// "mysql".
+//
+// Schemas only in newDoc get a CREATE TABLE and schemas only in oldDoc a
+// DROP TABLE. For schemas in both, added and removed fields become ALTER
+// TABLE ... ADD COLUMN and DROP COLUMN. When exactly one field was removed
+// and one added at the same position, it is taken as a rename and emitted
+// as RENAME COLUMN, which keeps the column's data. Column types come from
+// the inferred field types of newDoc (see FieldDescriptor); changes to the
+// type of an existing field are not migrated. Identical schemas give an
+// empty script, and an unsupported dialect returns ErrInvalidArgument.
+func SchemaMigration(oldDoc, newDoc *Document, dialect string) (string, error) {
+	sqlDialect, err := lookupSQLDialect(dialect)
+	if err != nil {
+		return "", err
+	}
+	before, err := oldDoc.SchemaDescriptors()
+	if err != nil {
+		return "", err
+	}
+	after, err := newDoc.SchemaDescriptors()
+	if err != nil {
+		return "", err
+	}
+	oldByName := make(map[string]SchemaDescriptor, len(before))
+	for _, desc := range before {
+		oldByName[desc.Name] = desc
+	}
+
+	var b strings.Builder
+	column := func(field FieldDescriptor) string {
+		if columnType := sqlDialect.columnType(field.Type); columnType != "" {
+			return sqlDialect.quote(field.Name) + " " + columnType
+		}
+		return sqlDialect.quote(field.Name)
+	}
+	kept := make(map[string]bool, len(after))
+	for _, desc := range after {
+		kept[desc.Name] = true
+		table := sqlDialect.quote(desc.Name)
+		old, ok := oldByName[desc.Name]
+		if !ok {
+			columns := make([]string, len(desc.Fields))
+			for i, field := range desc.Fields {
+				columns[i] = column(field)
+			}
+			fmt.Fprintf(&b, "CREATE TABLE %s (%s);\n", table, strings.Join(columns, ", "))
+			continue
+		}
+
+		dropped := missingFields(old.Fields, desc.Fields)
+		added := missingFields(desc.Fields, old.Fields)
+		if len(dropped) == 1 && len(added) == 1 && dropped[0] == added[0] {
+			fmt.Fprintf(&b, "ALTER TABLE %s RENAME COLUMN %s TO %s;\n", table,
+				sqlDialect.quote(old.Fields[dropped[0]].Name), sqlDialect.quote(desc.Fields[added[0]].Name))
+			continue
+		}
+		for _, i := range dropped {
+			fmt.Fprintf(&b, "ALTER TABLE %s DROP COLUMN %s;\n", table, sqlDialect.quote(old.Fields[i].Name))
+		}
+		for _, i := range added {
+			fmt.Fprintf(&b, "ALTER TABLE %s ADD COLUMN %s;\n", table, column(desc.Fields[i]))
+		}
+	}
+	for _, desc := range before {
+		if !kept[desc.Name] {
+			fmt.Fprintf(&b, "DROP TABLE %s;\n", sqlDialect.quote(desc.Name))
+		}
+	}
+	return b.String(), nil
+}
+
+// missingFields returns the positions in fields of the fields whose names
+// are not in others.
+func missingFields(fields, others []FieldDescriptor) []int {
+	var missing []int
+	for i, field := range fields {
+		found := false
+		for _, other := range others {
+			if other.Name == field.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, i)
+		}
+	}
+	return missing
+}
+
+// sqlLiteral renders a value as a SQL literal. References, expressions and
+// tensors are stored as their HEDL text. backslashEscapes doubles
+// backslashes for dialects that treat them as escapes in string literals.
+func sqlLiteral(value interface{}, backslashEscapes bool) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "NULL", nil
+	case bool:
+		if v {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	case json.Number:
+		return formatNumber(v), nil
+	case string:
+		if backslashEscapes {
+			v = strings.ReplaceAll(v, `\`, `\\`)
+		}
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'", nil
+	}
+	text, err := formatScalar(value)
+	if err != nil {
+		return "", err
+	}
+	return sqlLiteral(text, backslashEscapes)
+}
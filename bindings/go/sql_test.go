@@ -0,0 +1,126 @@
+package hedl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestToSQLUpsert(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	sql, err := doc.ToSQLUpsert("postgres", "id")
+	if err != nil {
+		t.Fatalf("ToSQLUpsert failed: %v", err)
+	}
+	if !strings.Contains(sql, `ON CONFLICT ("id")`) {
+		t.Errorf("Expected ON CONFLICT (\"id\") in:\n%s", sql)
+	}
+	if strings.Count(sql, "INSERT INTO \"User\"") != 2 {
+		t.Errorf("Expected one statement per row in:\n%s", sql)
+	}
+
+	mysql, err := doc.ToSQLUpsert("mysql", "")
+	if err != nil {
+		t.Fatalf("ToSQLUpsert failed: %v", err)
+	}
+	if !strings.Contains(mysql, "ON DUPLICATE KEY UPDATE `name` = VALUES(`name`)") {
+		t.Errorf("Expected MySQL upsert clause in:\n%s", mysql)
+	}
+}
+
+func TestToSQLUpsertInvalidArgument(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	for _, args := range [][2]string{{"oracle", "id"}, {"postgres", "missing"}} {
+		_, err := doc.ToSQLUpsert(args[0], args[1])
+		var hedlErr *HedlError
+		if !errors.As(err, &hedlErr) || hedlErr.Code != ErrInvalidArgument {
+			t.Errorf("ToSQLUpsert(%q, %q): expected ErrInvalidArgument, got %v", args[0], args[1], err)
+		}
+	}
+}
+
+// usersV2HEDL is sampleHEDL with an age column and a new Team schema.
+const usersV2HEDL = `%VERSION: 1.0
+%STRUCT: User: [id, name, email, age]
+%STRUCT: Team: [id, title]
+---
+users: @User
+  | alice, Alice Smith, alice@example.com, 34
+  | bob, Bob Jones, bob@example.com, 29
+teams: @Team
+  | core, Core
+`
+
+func TestSchemaMigration(t *testing.T) {
+	oldDoc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer oldDoc.Close()
+	newDoc, err := Parse(usersV2HEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer newDoc.Close()
+
+	script, err := SchemaMigration(oldDoc, newDoc, "postgres")
+	if err != nil {
+		t.Fatalf("SchemaMigration failed: %v", err)
+	}
+	for _, stmt := range []string{
+		`ALTER TABLE "User" ADD COLUMN "age" BIGINT;`,
+		`CREATE TABLE "Team" ("id" TEXT, "title" TEXT);`,
+	} {
+		if !strings.Contains(script, stmt) {
+			t.Errorf("Expected %s in:\n%s", stmt, script)
+		}
+	}
+
+	back, err := SchemaMigration(newDoc, oldDoc, "mysql")
+	if err != nil {
+		t.Fatalf("SchemaMigration failed: %v", err)
+	}
+	want := "ALTER TABLE `User` DROP COLUMN `age`;\nDROP TABLE `Team`;\n"
+	if back != want {
+		t.Errorf("Unexpected reverse migration:\ngot:\n%s\nwant:\n%s", back, want)
+	}
+
+	same, err := SchemaMigration(oldDoc, oldDoc, "sqlite")
+	if err != nil {
+		t.Fatalf("SchemaMigration failed: %v", err)
+	}
+	if same != "" {
+		t.Errorf("Expected an empty script for identical schemas, got:\n%s", same)
+	}
+}
+
+func TestSchemaMigrationRename(t *testing.T) {
+	oldDoc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer oldDoc.Close()
+	newDoc, err := Parse(strings.Replace(sampleHEDL, "email]", "mail]", 1), true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer newDoc.Close()
+
+	script, err := SchemaMigration(oldDoc, newDoc, "postgres")
+	if err != nil {
+		t.Fatalf("SchemaMigration failed: %v", err)
+	}
+	if want := "ALTER TABLE \"User\" RENAME COLUMN \"email\" TO \"mail\";\n"; script != want {
+		t.Errorf("Expected %q, got %q", want, script)
+	}
+}
@@ -0,0 +1,87 @@
+package hedl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestToSQLite(t *testing.T) {
+	doc, err := Parse(orderRefsHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	data, err := doc.ToSQLite()
+	if err != nil {
+		t.Fatalf("ToSQLite failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "orders.db")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+
+	if !bytes.HasPrefix(written, []byte("SQLite format 3\x00")) {
+		t.Fatalf("Expected the SQLite magic header, got %q", written[:16])
+	}
+	pageSize := int(binary.BigEndian.Uint16(written[16:]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pages := binary.BigEndian.Uint32(written[28:]); int(pages)*pageSize != len(written) {
+		t.Errorf("Header page count %d does not match file size %d", pages, len(written))
+	}
+	for _, table := range []string{`CREATE TABLE "Customer"`, `CREATE TABLE "Order"`} {
+		if !bytes.Contains(written, []byte(table)) {
+			t.Errorf("Expected %s in the schema table", table)
+		}
+	}
+}
+
+func TestToSQLiteReadable(t *testing.T) {
+	sqlite3, err := exec.LookPath("sqlite3")
+	if err != nil {
+		t.Skip("sqlite3 command-line shell not available")
+	}
+
+	doc, err := Parse(orderRefsHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+	data, err := doc.ToSQLite()
+	if err != nil {
+		t.Fatalf("ToSQLite failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "orders.db")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	query := func(sql string) string {
+		t.Helper()
+		out, err := exec.Command(sqlite3, "-readonly", path, sql).CombinedOutput()
+		if err != nil {
+			t.Fatalf("sqlite3 %q failed: %v\n%s", sql, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	if got := query("PRAGMA integrity_check"); got != "ok" {
+		t.Errorf("Expected integrity_check ok, got %q", got)
+	}
+	if got := query(`SELECT id, name FROM "Customer"`); got != "c1|Alice" {
+		t.Errorf("Unexpected Customer rows: %q", got)
+	}
+	if got := query(`SELECT o.id, c.name FROM "Order" o JOIN "Customer" c ON '@Customer:' || c.id = o.customer`); got != "o1|Alice" {
+		t.Errorf("Unexpected joined rows: %q", got)
+	}
+}
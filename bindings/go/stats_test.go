@@ -0,0 +1,111 @@
+package hedl
+
+import "testing"
+
+func TestFieldStats(t *testing.T) {
+	fixtures := GetGlobalFixtures()
+	medium, err := fixtures.MediumHEDL()
+	if err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+	doc, err := Parse(medium, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	stats, err := doc.FieldStats("Employee")
+	if err != nil {
+		t.Fatalf("FieldStats failed: %v", err)
+	}
+	if len(stats) != 4 {
+		t.Fatalf("Expected 4 field stats, got %d", len(stats))
+	}
+
+	salary := stats[3]
+	if salary.Name != "salary" || salary.NonNullCount != 50 {
+		t.Errorf("Unexpected salary stats: %+v", salary)
+	}
+	if salary.FillRate < 0.99 || salary.FillRate > 1.0 {
+		t.Errorf("Expected salary fill rate near 1.0, got %f", salary.FillRate)
+	}
+	if dept := stats[2]; dept.Name != "dept" || dept.DistinctCount != 10 {
+		t.Errorf("Expected 10 distinct departments, got %+v", dept)
+	}
+}
+
+func TestFieldStatsNulls(t *testing.T) {
+	doc, err := Parse(incompleteRowsHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	stats, err := doc.FieldStats("User")
+	if err != nil {
+		t.Fatalf("FieldStats failed: %v", err)
+	}
+	name := stats[1]
+	if name.NonNullCount != 3 || name.DistinctCount != 3 {
+		t.Errorf("Unexpected name stats: %+v", name)
+	}
+	if name.FillRate != 0.75 {
+		t.Errorf("Expected name fill rate 0.75, got %f", name.FillRate)
+	}
+	if email := stats[2]; email.NonNullCount != 4 || email.DistinctCount != 3 {
+		t.Errorf("Unexpected email stats: %+v", email)
+	}
+}
+
+const emptyColumnHEDL = `%VERSION: 1.0
+%STRUCT: Contact: [id, name, fax, phone]
+---
+contacts: @Contact
+  | c1, Alice, ~, 555-0100
+  | c2, Bob, "", ~
+  | c3, Carol, ~, 555-0102
+`
+
+func TestUnusedFields(t *testing.T) {
+	doc, err := Parse(emptyColumnHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	unused, err := doc.UnusedFields("Contact")
+	if err != nil {
+		t.Fatalf("UnusedFields failed: %v", err)
+	}
+	if len(unused) != 1 || unused[0] != "fax" {
+		t.Errorf("Expected only fax to be unused, got %v", unused)
+	}
+
+	if _, err := doc.UnusedFields("Lead"); err == nil {
+		t.Error("Expected error for an unknown schema")
+	}
+}
+
+func TestMixedTypeFields(t *testing.T) {
+	doc, err := FromJSON(`{"readings": [
+		{"id": "r1", "sensor": "s1", "value": 21.5, "count": 3},
+		{"id": "r2", "sensor": "s2", "value": "offline", "count": 2.5},
+		{"id": "r3", "sensor": "s1", "value": 19, "count": 4}
+	]}`)
+	if err != nil {
+		t.Fatalf("FromJSON failed: %v", err)
+	}
+	defer doc.Close()
+
+	mixed, err := doc.MixedTypeFields("Reading")
+	if err != nil {
+		t.Fatalf("MixedTypeFields failed: %v", err)
+	}
+	if len(mixed) != 1 {
+		t.Fatalf("Expected only value to be flagged, got %v", mixed)
+	}
+	types := mixed["value"]
+	if len(types) != 2 || types[0] != "float" || types[1] != "string" {
+		t.Errorf("Expected [float string] for value, got %v", types)
+	}
+}
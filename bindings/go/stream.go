@@ -0,0 +1,36 @@
+package hedl
+
+/*
+#include <stddef.h>
+*/
+import "C"
+
+import (
+	"io"
+	"runtime/cgo"
+	"unsafe"
+)
+
+// chunkSink receives the output of a chunked FFI export, writing each chunk
+// to w as it arrives.
+type chunkSink struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+// hedlWriteChunk is the hedl_chunk_callback passed to chunked exports.
+// userData points at a cgo.Handle for a *chunkSink. A write error is kept
+// on the sink and stops the conversion.
+//
+//export hedlWriteChunk
+func hedlWriteChunk(data *C.char, length C.size_t, userData unsafe.Pointer) C.int {
+	sink := (*(*cgo.Handle)(userData)).Value().(*chunkSink)
+	n, err := sink.w.Write(unsafe.Slice((*byte)(unsafe.Pointer(data)), int(length)))
+	sink.n += int64(n)
+	if err != nil {
+		sink.err = err
+		return 1
+	}
+	return 0
+}
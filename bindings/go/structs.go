@@ -0,0 +1,412 @@
+package hedl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// structField maps a schema column to a struct field.
+type structField struct {
+	name  string
+	index []int
+}
+
+// structFields returns the columns of a struct type: its exported fields in
+// declaration order, named by their `hedl:"name"` tag or the field name in
+// snake_case. Fields tagged `hedl:"-"` are skipped.
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := snakeCase(f.Name)
+		if tag, ok := f.Tag.Lookup("hedl"); ok {
+			tag = strings.Split(tag, ",")[0]
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		fields = append(fields, structField{name: name, index: f.Index})
+	}
+	return fields
+}
+
+// snakeCase converts a Go identifier to snake_case, keeping acronyms
+// together: "UserID" becomes "user_id" and "HTTPStatus" "http_status".
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// isKeyToken reports whether s is a valid HEDL key: [a-z_][a-z0-9_]*.
+func isKeyToken(s string) bool {
+	if s == "" || !(s[0] == '_' || s[0] >= 'a' && s[0] <= 'z') {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if c := s[i]; !(c == '_' || c >= 'a' && c <= 'z' || isDigit(c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// isTypeName reports whether s is a valid HEDL type name: [A-Z][A-Za-z0-9]*.
+func isTypeName(s string) bool {
+	if s == "" || s[0] < 'A' || s[0] > 'Z' {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if c := s[i]; !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || isDigit(c)) {
+			return false
+		}
+	}
+	return true
+}
+
+// sliceElemType returns the struct type held by a slice of structs or of
+// struct pointers.
+func sliceElemType(t reflect.Type) (reflect.Type, bool) {
+	if t.Kind() != reflect.Slice {
+		return nil, false
+	}
+	elem := t.Elem()
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	return elem, elem.Kind() == reflect.Struct
+}
+
+// FromStructs builds a Document from a slice of structs (or struct
+// pointers), or a pointer to one.
+//
+// The struct type name becomes the schema and its exported fields the
+// columns, named by their `hedl:"name"` tag when present and by the field
+// name in snake_case otherwise; `hedl:"-"` skips a field. The first column is
+// the row ID. The rows are stored under a root key made from the type name in
+// snake_case with an "s" suffix, so []User is stored as "users" and
+// []OrderItem as "order_items".
+//
+// The type name must be a valid HEDL type name and every column name a valid
+// key (lowercase letters, digits and underscores, not starting with a digit),
+// with no name used twice, and the first field, which holds the row ID, must
+// be of string kind; otherwise ErrInvalidArgument is returned.
+//
+// Supported field types are strings, bools, integers, floats and
+// time.Time (stored as an RFC 3339 string), or pointers to them; nil
+// pointers become null.
+func FromStructs(v interface{}) (*Document, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil, fmt.Errorf("FromStructs: expected a slice of structs, got %T", v)
+	}
+	elem, ok := sliceElemType(rv.Type())
+	if !ok {
+		return nil, fmt.Errorf("FromStructs: expected a slice of structs, got %T", v)
+	}
+
+	fields := structFields(elem)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("FromStructs: %s has no exported fields", elem.Name())
+	}
+	if !isTypeName(elem.Name()) {
+		return nil, &HedlError{
+			Message: fmt.Sprintf("FromStructs: %q is not a valid type name", elem.Name()),
+			Code:    ErrInvalidArgument,
+		}
+	}
+	columns := make([]string, len(fields))
+	seen := make(map[string]bool, len(fields))
+	for i, f := range fields {
+		if !isKeyToken(f.name) || seen[f.name] {
+			return nil, &HedlError{
+				Message: fmt.Sprintf("FromStructs: %s column %q is not a valid unique key", elem.Name(), f.name),
+				Code:    ErrInvalidArgument,
+			}
+		}
+		seen[f.name] = true
+		columns[i] = f.name
+	}
+	if idField := elem.FieldByIndex(fields[0].index); idField.Type.Kind() != reflect.String {
+		return nil, &HedlError{
+			Message: fmt.Sprintf("FromStructs: %s ID field %s must be a string, got %s", elem.Name(), idField.Name, idField.Type),
+			Code:    ErrInvalidArgument,
+		}
+	}
+
+	list := &matrixList{typeName: elem.Name(), schema: columns}
+	for i := 0; i < rv.Len(); i++ {
+		item := rv.Index(i)
+		if item.Kind() == reflect.Ptr {
+			if item.IsNil() {
+				return nil, fmt.Errorf("FromStructs: element %d is nil", i)
+			}
+			item = item.Elem()
+		}
+		row := &matrixRow{values: make([]interface{}, len(fields))}
+		for j, f := range fields {
+			value, err := structValue(item.FieldByIndex(f.index))
+			if err != nil {
+				return nil, fmt.Errorf("FromStructs: element %d field %q: %w", i, f.name, err)
+			}
+			row.values[j] = value
+		}
+		list.rows = append(list.rows, row)
+	}
+
+	key := snakeCase(elem.Name()) + "s"
+
+	m := &docModel{major: 1, root: newObject()}
+	m.structs = []schemaDef{{name: list.typeName, columns: columns}}
+	m.root.set(key, list)
+	return documentFromModel(m)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// structValue converts a struct field to a model value.
+func structValue(v reflect.Value) (interface{}, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+
+	if v.Type() == timeType {
+		return v.Interface().(time.Time).Format(time.RFC3339Nano), nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return json.Number(strconv.FormatInt(v.Int(), 10)), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return json.Number(strconv.FormatUint(v.Uint(), 10)), nil
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("cannot represent %v", f)
+		}
+		text := strconv.FormatFloat(f, 'f', -1, v.Type().Bits())
+		if !strings.Contains(text, ".") {
+			text += ".0"
+		}
+		return json.Number(text), nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", v.Type())
+}
+
+// DecodeOptions configures DecodeIntoWithOptions.
+type DecodeOptions struct {
+	// DisallowUnknownFields makes decoding fail when the schema has a field
+	// with no matching struct field, like json.Decoder.DisallowUnknownFields.
+	DisallowUnknownFields bool
+}
+
+// DecodeInto stores the rows of schemaName in out, which must be a pointer
+// to a slice of structs or struct pointers. Columns are matched to struct
+// fields the same way FromStructs names them; columns without a matching
+// field are ignored. Use DecodeIntoWithOptions to reject them instead.
+//
+// Numbers can be decoded into numeric fields and, as their text, into string
+// fields; references and expressions decode into string fields; null leaves
+// the zero value. Every value that cannot be converted is reported, each
+// error naming its row and field.
+func (d *Document) DecodeInto(schemaName string, out interface{}) error {
+	return d.DecodeIntoWithOptions(schemaName, out, DecodeOptions{})
+}
+
+// DecodeIntoWithOptions is DecodeInto with options. With
+// DisallowUnknownFields set, a schema field that has no matching struct field
+// is an error and out is left unchanged.
+func (d *Document) DecodeIntoWithOptions(schemaName string, out interface{}, opts DecodeOptions) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("DecodeInto: expected a pointer to a slice of structs, got %T", out)
+	}
+	slice := rv.Elem()
+	elem, ok := sliceElemType(slice.Type())
+	if !ok {
+		return fmt.Errorf("DecodeInto: expected a pointer to a slice of structs, got %T", out)
+	}
+
+	m, err := d.model()
+	if err != nil {
+		return err
+	}
+	lists, err := m.listsOf(schemaName)
+	if err != nil {
+		return err
+	}
+
+	byName := make(map[string]structField)
+	for _, f := range structFields(elem) {
+		byName[f.name] = f
+	}
+	if opts.DisallowUnknownFields {
+		for _, list := range lists {
+			for _, name := range list.schema {
+				if _, ok := byName[name]; !ok {
+					return fmt.Errorf("DecodeInto: schema %q field %q has no matching field in %s", schemaName, name, elem)
+				}
+			}
+		}
+	}
+
+	var errs []error
+	result := reflect.MakeSlice(slice.Type(), 0, 0)
+	index := 0
+	for _, list := range lists {
+		for _, row := range list.rows {
+			item := reflect.New(elem).Elem()
+			for col, name := range list.schema {
+				f, ok := byName[name]
+				if !ok || col >= len(row.values) {
+					continue
+				}
+				if err := assignValue(item.FieldByIndex(f.index), row.values[col]); err != nil {
+					errs = append(errs, fmt.Errorf("row %d field %q: %w", index, name, err))
+				}
+			}
+			if slice.Type().Elem().Kind() == reflect.Ptr {
+				item = item.Addr()
+			}
+			result = reflect.Append(result, item)
+			index++
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	slice.Set(result)
+	return nil
+}
+
+// assignValue converts a model value and stores it in dst.
+func assignValue(dst reflect.Value, value interface{}) error {
+	if value == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	if dst.Kind() == reflect.Ptr {
+		target := reflect.New(dst.Type().Elem())
+		if err := assignValue(target.Elem(), value); err != nil {
+			return err
+		}
+		dst.Set(target)
+		return nil
+	}
+
+	mismatch := fmt.Errorf("cannot decode %s into %s", valueType(value), dst.Type())
+	if dst.Type() == timeType {
+		s, ok := value.(string)
+		if !ok {
+			return mismatch
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return err
+		}
+		dst.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch v := value.(type) {
+	case string:
+		if dst.Kind() == reflect.String {
+			dst.SetString(v)
+			return nil
+		}
+	case reference:
+		if dst.Kind() == reflect.String {
+			dst.SetString(string(v))
+			return nil
+		}
+	case expression:
+		if dst.Kind() == reflect.String {
+			dst.SetString(string(v))
+			return nil
+		}
+	case bool:
+		if dst.Kind() == reflect.Bool {
+			dst.SetBool(v)
+			return nil
+		}
+	case json.Number:
+		return assignNumber(dst, v, mismatch)
+	}
+	if dst.Kind() == reflect.Interface && dst.NumMethod() == 0 {
+		switch v := value.(type) {
+		case reference:
+			value = string(v)
+		case expression:
+			value = string(v)
+		}
+		dst.Set(reflect.ValueOf(value))
+		return nil
+	}
+	return mismatch
+}
+
+// assignNumber stores a number in a numeric or string field.
+func assignNumber(dst reflect.Value, n json.Number, mismatch error) error {
+	switch dst.Kind() {
+	case reflect.String:
+		dst.SetString(n.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(n.String(), 10, 64)
+		if err != nil || dst.OverflowInt(i) {
+			return fmt.Errorf("value %s does not fit in %s", n, dst.Type())
+		}
+		dst.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(n.String(), 10, 64)
+		if err != nil || dst.OverflowUint(u) {
+			return fmt.Errorf("value %s does not fit in %s", n, dst.Type())
+		}
+		dst.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := n.Float64()
+		if err != nil || dst.OverflowFloat(f) {
+			return fmt.Errorf("value %s does not fit in %s", n, dst.Type())
+		}
+		dst.SetFloat(f)
+	case reflect.Interface:
+		if dst.NumMethod() != 0 {
+			return mismatch
+		}
+		dst.Set(reflect.ValueOf(n))
+	default:
+		return mismatch
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package hedl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type User struct {
+	ID       string  `hedl:"id"`
+	Name     string  `hedl:"name"`
+	Age      int     `hedl:"age"`
+	Email    *string `hedl:"email"`
+	Password string  `hedl:"-"`
+	note     string
+}
+
+func TestFromStructs(t *testing.T) {
+	email := "alice@example.com"
+	users := []User{
+		{ID: "alice", Name: "Alice Smith", Age: 30, Email: &email, Password: "secret"},
+		{ID: "bob", Name: "Bob Jones", Age: 25},
+	}
+
+	doc, err := FromStructs(users)
+	if err != nil {
+		t.Fatalf("FromStructs failed: %v", err)
+	}
+	defer doc.Close()
+
+	schemas, err := doc.SchemaDescriptors()
+	if err != nil {
+		t.Fatalf("SchemaDescriptors failed: %v", err)
+	}
+	if len(schemas) != 1 || schemas[0].Name != "User" {
+		t.Fatalf("Expected a single User schema, got %+v", schemas)
+	}
+	if got := fmt.Sprint(schemas[0].Fields); got != "[{id string false} {name string false} {age int false} {email string true}]" {
+		t.Errorf("Unexpected fields: %s", got)
+	}
+
+	m, err := doc.model()
+	if err != nil {
+		t.Fatalf("model failed: %v", err)
+	}
+	lists, err := m.listsOf("User")
+	if err != nil {
+		t.Fatalf("listsOf failed: %v", err)
+	}
+	if len(lists) != 1 || len(lists[0].rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %+v", lists)
+	}
+	if got := fmt.Sprint(lists[0].rows[1].values); got != "[bob Bob Jones 25 <nil>]" {
+		t.Errorf("Unexpected second row: %s", got)
+	}
+	if _, ok := m.root.get("users"); !ok {
+		t.Errorf("Expected rows under users, got keys %v", m.root.keys)
+	}
+}
+
+func TestFromStructsInvalid(t *testing.T) {
+	if _, err := FromStructs(nil); err == nil {
+		t.Error("Expected error for nil input")
+	}
+	if _, err := FromStructs([]int{1, 2}); err == nil {
+		t.Error("Expected error for a slice of non-structs")
+	}
+
+	type Numbered struct {
+		ID   int
+		Name string
+	}
+	_, err := FromStructs([]Numbered{{ID: 1, Name: "one"}})
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrInvalidArgument || !strings.Contains(hedlErr.Message, "ID") {
+		t.Errorf("Expected ErrInvalidArgument naming the ID field, got %v", err)
+	}
+}
+
+func TestFromStructsKeys(t *testing.T) {
+	type OrderItem struct {
+		ItemID    string
+		HTTPCode  int
+		UnitPrice float64
+	}
+	doc, err := FromStructs([]OrderItem{{ItemID: "a1", HTTPCode: 200, UnitPrice: 1.5}})
+	if err != nil {
+		t.Fatalf("FromStructs failed: %v", err)
+	}
+	defer doc.Close()
+	m, err := doc.model()
+	if err != nil {
+		t.Fatalf("model failed: %v", err)
+	}
+	if _, ok := m.root.get("order_items"); !ok {
+		t.Errorf("Expected rows under order_items, got keys %v", m.root.keys)
+	}
+	if got := fmt.Sprint(m.structs[0].columns); got != "[item_id http_code unit_price]" {
+		t.Errorf("Unexpected columns: %s", got)
+	}
+
+	type BadTag struct {
+		ID   string `hedl:"id"`
+		Name string `hedl:"full name"`
+	}
+	type Duplicate struct {
+		ID    string `hedl:"id"`
+		Other string `hedl:"id"`
+	}
+	type lowercase struct {
+		ID string `hedl:"id"`
+	}
+	for _, v := range []interface{}{[]BadTag{{}}, []Duplicate{{}}, []lowercase{{}}} {
+		_, err := FromStructs(v)
+		var hedlErr *HedlError
+		if !errors.As(err, &hedlErr) || hedlErr.Code != ErrInvalidArgument {
+			t.Errorf("FromStructs(%T): expected ErrInvalidArgument, got %v", v, err)
+		}
+	}
+}
+
+func TestDecodeInto(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	var users []User
+	if err := doc.DecodeInto("User", &users); err != nil {
+		t.Fatalf("DecodeInto failed: %v", err)
+	}
+	if len(users) != 2 {
+		t.Fatalf("Expected 2 users, got %d", len(users))
+	}
+	if users[0].ID != "alice" || users[0].Name != "Alice Smith" {
+		t.Errorf("Unexpected first user: %+v", users[0])
+	}
+	if users[1].Email == nil || *users[1].Email != "bob@example.com" {
+		t.Errorf("Unexpected email for second user: %+v", users[1])
+	}
+}
+
+func TestDecodeIntoConversionError(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	var rows []struct {
+		Name bool `hedl:"name"`
+	}
+	err = doc.DecodeInto("User", &rows)
+	if err == nil {
+		t.Fatal("Expected conversion error")
+	}
+	if !strings.Contains(err.Error(), `row 0 field "name"`) || !strings.Contains(err.Error(), `row 1 field "name"`) {
+		t.Errorf("Expected an error for each row, got %v", err)
+	}
+}
+
+func TestDecodeIntoWithOptionsUnknownFields(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	type partialUser struct {
+		ID   string `hedl:"id"`
+		Name string `hedl:"name"`
+	}
+
+	var strict []partialUser
+	err = doc.DecodeIntoWithOptions("User", &strict, DecodeOptions{DisallowUnknownFields: true})
+	if err == nil || !strings.Contains(err.Error(), `"email"`) {
+		t.Fatalf("Expected an unknown field error naming email, got %v", err)
+	}
+	if strict != nil {
+		t.Errorf("Expected output to be left unchanged, got %+v", strict)
+	}
+
+	var lenient []partialUser
+	if err := doc.DecodeIntoWithOptions("User", &lenient, DecodeOptions{}); err != nil {
+		t.Fatalf("DecodeIntoWithOptions failed: %v", err)
+	}
+	if len(lenient) != 2 || lenient[0].Name != "Alice Smith" {
+		t.Errorf("Unexpected users: %+v", lenient)
+	}
+}
@@ -0,0 +1,78 @@
+package hedl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestFitToTokens(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("%VERSION: 1.0\n%STRUCT: Event: [id, kind, message]\n---\nsource: sensors\nevents: @Event\n")
+	for i := 0; i < 200; i++ {
+		fmt.Fprintf(&b, "  | e%d, reading, Temperature within expected range\n", i)
+	}
+	doc, err := Parse(b.String(), true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	canonical, err := doc.Canonicalize()
+	if err != nil {
+		t.Fatalf("Canonicalize failed: %v", err)
+	}
+	full, err := TokenCount("gpt-4", canonical)
+	if err != nil {
+		t.Fatalf("TokenCount failed: %v", err)
+	}
+	budget := full / 4
+
+	text, diag, err := doc.FitToTokens("gpt-4", budget)
+	if err != nil {
+		t.Fatalf("FitToTokens failed: %v", err)
+	}
+	defer diag.Close()
+
+	count, err := TokenCount("gpt-4", text)
+	if err != nil {
+		t.Fatalf("TokenCount failed: %v", err)
+	}
+	if count > budget {
+		t.Errorf("Expected at most %d tokens, got %d", budget, count)
+	}
+	if !strings.Contains(text, "source: sensors") || !strings.Contains(text, "e0,") {
+		t.Errorf("Expected the key-values and leading rows to be kept, got:\n%s", text)
+	}
+	all, err := diag.All()
+	if err != nil {
+		t.Fatalf("All failed: %v", err)
+	}
+	if len(all) != 1 || !strings.Contains(all[0].Message, "Event list truncated from 200 to") {
+		t.Errorf("Expected one truncation diagnostic, got %v", all)
+	}
+
+	fitted, diag, err := doc.FitToTokens("gpt-4", full)
+	if err != nil {
+		t.Fatalf("FitToTokens failed: %v", err)
+	}
+	defer diag.Close()
+	if fitted != canonical || diag.Count() != 0 {
+		t.Errorf("Expected the full document without diagnostics when it fits")
+	}
+}
+
+func TestFitToTokensUnknownModel(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	_, _, err = doc.FitToTokens("no-such-model", 100)
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrInvalidArgument {
+		t.Errorf("Expected ErrInvalidArgument for an unknown model, got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package hedl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestToTOML(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	toml, err := doc.ToTOML()
+	if err != nil {
+		t.Fatalf("ToTOML failed: %v", err)
+	}
+
+	rows, err := doc.model()
+	if err != nil {
+		t.Fatalf("model failed: %v", err)
+	}
+	lists, err := rows.listsOf("User")
+	if err != nil {
+		t.Fatalf("listsOf failed: %v", err)
+	}
+	if got := strings.Count(toml, "[[users]]"); got != len(lists[0].rows) {
+		t.Errorf("Expected %d [[users]] blocks, got %d in:\n%s", len(lists[0].rows), got, toml)
+	}
+	if !strings.Contains(toml, `name = "Alice Smith"`) {
+		t.Errorf("Expected row fields as table keys in:\n%s", toml)
+	}
+}
+
+func TestToTOMLEscaping(t *testing.T) {
+	doc, err := FromTOML("\"first name\" = \"a \\\"q\\\"\\\\\\n\"\n")
+	if err != nil {
+		t.Fatalf("FromTOML failed: %v", err)
+	}
+	defer doc.Close()
+
+	toml, err := doc.ToTOML()
+	if err != nil {
+		t.Fatalf("ToTOML failed: %v", err)
+	}
+	if !strings.Contains(toml, `"first name" = "a \"q\"\\\n"`) {
+		t.Errorf("Expected an escaped string under a quoted key in:\n%s", toml)
+	}
+}
+
+const serviceTOML = `# Service configuration
+name = "api"
+
+[server]
+host = "0.0.0.0"
+port = 8_080
+
+[server.tls]
+enabled = true
+
+[[users]]
+id = "alice"
+role = "admin"
+
+[[users]]
+id = "bob"
+role = 'viewer'
+`
+
+func TestFromTOML(t *testing.T) {
+	doc, err := FromTOML(serviceTOML)
+	if err != nil {
+		t.Fatalf("FromTOML failed: %v", err)
+	}
+	defer doc.Close()
+
+	names, err := doc.SchemaNames()
+	if err != nil {
+		t.Fatalf("SchemaNames failed: %v", err)
+	}
+	if strings.Join(names, ",") != "User" {
+		t.Errorf("Expected [[users]] to become a User list, got schemas %q", names)
+	}
+
+	canonical, err := doc.Canonicalize()
+	if err != nil {
+		t.Fatalf("Canonicalize failed: %v", err)
+	}
+	for _, want := range []string{"users: @User", "server:", "tls:", "port: 8080", "bob"} {
+		if !strings.Contains(canonical, want) {
+			t.Errorf("Expected %q in:\n%s", want, canonical)
+		}
+	}
+}
+
+func TestFromTOMLSyntaxError(t *testing.T) {
+	_, err := FromTOML("[server]\nport = 80 80\n")
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrTOML {
+		t.Fatalf("Expected ErrTOML, got %v", err)
+	}
+	if !strings.Contains(hedlErr.Message, "line 2") {
+		t.Errorf("Expected the line number in %q", hedlErr.Message)
+	}
+	if CategoryOf(err) != CategoryFormat {
+		t.Errorf("Expected category %s, got %s", CategoryFormat, CategoryOf(err))
+	}
+}
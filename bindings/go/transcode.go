@@ -0,0 +1,132 @@
+package hedl
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// Transcode converts content from one format to another without exposing
+// the intermediate Document, which is freed before Transcode returns.
+//
+// fromFmt is one of "hedl", "json", "yaml", "xml" or "parquet"; strict only
+// applies to "hedl" input. toFmt is one of "hedl" (canonical form), "json",
+// "yaml", "xml", "csv", "parquet" or "cypher". JSON and YAML output omit
+// metadata. Format names are case-insensitive; an unknown name returns an
+// ErrInvalidArgument error.
+func Transcode(content string, fromFmt, toFmt string, strict bool) ([]byte, error) {
+	export, err := exporterFor(toFmt)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := importAs(content, fromFmt, strict)
+	if err != nil {
+		return nil, err
+	}
+	defer doc.Close()
+
+	return export(doc)
+}
+
+// TranscodeStream is Transcode reading its input from r and writing the
+// converted output to w. It returns the number of bytes written.
+//
+// The native library only parses whole documents, so the input is read in
+// full before conversion; reading stops one byte past the maximum input size,
+// which bounds memory for oversized streams and fails with ErrAlloc. Errors
+// reading r are returned as a HedlError with code ErrIO that wraps them. JSON
+// output is streamed to w in chunks as WriteJSON does, so it is never held
+// in memory as a whole; other formats are converted in memory and then
+// written. The HEDL_MAX_OUTPUT_SIZE limit applies to the total written:
+// once the next chunk would exceed it, writing stops with an ErrAlloc
+// error, leaving the output truncated.
+func TranscodeStream(r io.Reader, w io.Writer, fromFmt, toFmt string, strict bool) (int64, error) {
+	export, err := exporterFor(toFmt)
+	if err != nil {
+		return 0, err
+	}
+	data, err := io.ReadAll(io.LimitReader(r, math.MaxInt32+1))
+	if err != nil {
+		return 0, newIOError(err)
+	}
+	if len(data) > math.MaxInt32 {
+		return 0, &HedlError{
+			Message: fmt.Sprintf("Input exceeds the maximum supported input size (%d bytes)", math.MaxInt32),
+			Code:    ErrAlloc,
+		}
+	}
+
+	doc, err := importAs(string(data), fromFmt, strict)
+	if err != nil {
+		return 0, err
+	}
+	defer doc.Close()
+
+	out := &limitedWriter{w: w, limit: maxOutputSize}
+	if strings.EqualFold(toFmt, "json") {
+		return doc.WriteJSON(out, false)
+	}
+	converted, err := export(doc)
+	if err != nil {
+		return 0, err
+	}
+	_, err = out.Write(converted)
+	return out.n, err
+}
+
+// importAs reads content in a Transcode input format.
+func importAs(content string, format string, strict bool) (*Document, error) {
+	switch strings.ToLower(format) {
+	case "hedl":
+		return Parse(content, strict)
+	case "json":
+		return FromJSON(content)
+	case "yaml":
+		return FromYAML(content)
+	case "xml":
+		return FromXML(content)
+	case "parquet":
+		return FromParquet([]byte(content))
+	}
+	return nil, unknownFormat(format)
+}
+
+// exporterFor returns the export function for a Transcode output format.
+func exporterFor(format string) (func(*Document) ([]byte, error), error) {
+	text := func(fn func(*Document) (string, error)) func(*Document) ([]byte, error) {
+		return func(d *Document) ([]byte, error) {
+			s, err := fn(d)
+			if err != nil {
+				return nil, err
+			}
+			return []byte(s), nil
+		}
+	}
+
+	switch strings.ToLower(format) {
+	case "hedl":
+		return text((*Document).Canonicalize), nil
+	case "json":
+		return text(func(d *Document) (string, error) { return d.ToJSON(false) }), nil
+	case "yaml":
+		return text(func(d *Document) (string, error) { return d.ToYAML(false) }), nil
+	case "xml":
+		return text((*Document).ToXML), nil
+	case "csv":
+		return text((*Document).ToCSV), nil
+	case "parquet":
+		return (*Document).ToParquet, nil
+	case "cypher":
+		return text(func(d *Document) (string, error) { return d.ToCypher(false) }), nil
+	}
+	return nil, unknownFormat(format)
+}
+
+// unknownFormat reports an unsupported Transcode format name.
+func unknownFormat(format string) error {
+	return &HedlError{
+		Message: fmt.Sprintf("unknown format %q", format),
+		Code:    ErrInvalidArgument,
+	}
+}
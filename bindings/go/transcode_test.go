@@ -0,0 +1,107 @@
+package hedl
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestTranscode(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want, err := doc.ToJSON(false)
+	doc.Close()
+	if err != nil {
+		t.Fatalf("ToJSON failed: %v", err)
+	}
+
+	before := OpenDocuments()
+	got, err := Transcode(sampleHEDL, "hedl", "json", true)
+	if err != nil {
+		t.Fatalf("Transcode failed: %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("Transcode output differs from Parse+ToJSON:\ngot:  %s\nwant: %s", got, want)
+	}
+	if after := OpenDocuments(); after != before {
+		t.Errorf("Expected %d open documents after Transcode, got %d", before, after)
+	}
+}
+
+func TestTranscodeUnknownFormat(t *testing.T) {
+	for _, formats := range [][2]string{{"toml", "json"}, {"hedl", "toml"}} {
+		_, err := Transcode(sampleHEDL, formats[0], formats[1], true)
+		var hedlErr *HedlError
+		if !errors.As(err, &hedlErr) || hedlErr.Code != ErrInvalidArgument {
+			t.Errorf("Transcode(%s -> %s): expected ErrInvalidArgument, got %v", formats[0], formats[1], err)
+		}
+	}
+}
+
+func TestTranscodeStream(t *testing.T) {
+	basic, err := GetGlobalFixtures().BasicHEDL()
+	if err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+	want, err := Transcode(basic, "hedl", "json", true)
+	if err != nil {
+		t.Fatalf("Transcode failed: %v", err)
+	}
+
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	go func() {
+		_, err := io.WriteString(inW, basic)
+		inW.CloseWithError(err)
+	}()
+	type result struct {
+		n   int64
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		n, err := TranscodeStream(inR, outW, "hedl", "json", true)
+		outW.CloseWithError(err)
+		done <- result{n, err}
+	}()
+
+	got, err := io.ReadAll(outR)
+	if err != nil {
+		t.Fatalf("Reading output failed: %v", err)
+	}
+	res := <-done
+	if res.err != nil {
+		t.Fatalf("TranscodeStream failed: %v", res.err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("TranscodeStream output differs from Transcode:\ngot:  %s\nwant: %s", got, want)
+	}
+	if res.n != int64(len(got)) {
+		t.Errorf("Expected %d bytes written, got %d", len(got), res.n)
+	}
+
+	defer func(limit int64) { maxOutputSize = limit }(maxOutputSize)
+	maxOutputSize = 16
+	for _, format := range []string{"json", "hedl"} {
+		var out strings.Builder
+		_, err := TranscodeStream(strings.NewReader(basic), &out, "hedl", format, true)
+		var hedlErr *HedlError
+		if !errors.As(err, &hedlErr) || hedlErr.Code != ErrAlloc {
+			t.Errorf("Expected ErrAlloc past the output limit for %s, got %v", format, err)
+		}
+		if int64(out.Len()) > maxOutputSize {
+			t.Errorf("Expected at most %d bytes of %s written, got %d", maxOutputSize, format, out.Len())
+		}
+	}
+
+	readErr := errors.New("connection reset")
+	_, err = TranscodeStream(iotest.ErrReader(readErr), io.Discard, "hedl", "json", true)
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrIO || !errors.Is(err, readErr) {
+		t.Errorf("Expected ErrIO wrapping the read error, got %v", err)
+	}
+}
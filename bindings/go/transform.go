@@ -0,0 +1,35 @@
+package hedl
+
+import "strings"
+
+// rowKey builds a comparison key from the given columns of row.
+func rowKey(row *matrixRow, columns []int) (string, error) {
+	parts := make([]string, len(columns))
+	for i, col := range columns {
+		cell, err := formatCell(row.values[col])
+		if err != nil {
+			return "", err
+		}
+		parts[i] = cell
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// EscapeNewlines returns a new document with every line break in string
+// values, whether "\r\n", "\n" or "\r", replaced by replacement, for
+// consumers that cannot handle multi-line values. References, expressions
+// and other non-string values are unchanged.
+func (d *Document) EscapeNewlines(replacement string) (*Document, error) {
+	m, err := d.model()
+	if err != nil {
+		return nil, err
+	}
+	newlines := strings.NewReplacer("\r\n", replacement, "\n", replacement, "\r", replacement)
+	m.mapScalars(func(value interface{}) interface{} {
+		if s, ok := value.(string); ok {
+			return newlines.Replace(s)
+		}
+		return value
+	})
+	return documentFromModel(m)
+}
@@ -0,0 +1,379 @@
+package hedl
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+const readingsHEDL = `%VERSION: 1.0
+%STRUCT: Reading: [id, sensor, value]
+---
+readings: @Reading
+  | r1, temp, 20
+  | r2, temp, 20
+  | r3, temp, 21
+  | r4, temp, 21
+  | r5, temp, 21
+  | r6, temp, 20
+`
+
+func TestDedup(t *testing.T) {
+	doc, err := Parse(readingsHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	removed, err := doc.Dedup("Reading", nil)
+	if err != nil {
+		t.Fatalf("Dedup failed: %v", err)
+	}
+	if removed != 3 {
+		t.Fatalf("Expected 3 rows removed, got %d", removed)
+	}
+
+	m, err := doc.model()
+	if err != nil {
+		t.Fatalf("model failed: %v", err)
+	}
+	lists, err := m.listsOf("Reading")
+	if err != nil {
+		t.Fatalf("listsOf failed: %v", err)
+	}
+	if len(lists) != 1 || len(lists[0].rows) != 3 {
+		t.Fatalf("Expected 3 remaining rows, got %d", len(lists[0].rows))
+	}
+}
+
+func TestDedupFields(t *testing.T) {
+	doc, err := Parse(readingsHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	removed, err := doc.Dedup("Reading", []string{"sensor"})
+	if err != nil {
+		t.Fatalf("Dedup failed: %v", err)
+	}
+	if removed != 5 {
+		t.Fatalf("Expected 5 rows removed, got %d", removed)
+	}
+
+	if _, err := doc.Dedup("Reading", []string{"missing"}); err == nil {
+		t.Fatal("Expected error for unknown field")
+	}
+	if _, err := doc.Dedup("Missing", nil); err == nil {
+		t.Fatal("Expected error for unknown schema")
+	}
+}
+
+func TestTail(t *testing.T) {
+	fixtures := GetGlobalFixtures()
+	large, err := fixtures.LargeHEDL()
+	if err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+
+	doc, err := Parse(large, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	tail, err := doc.Tail(5)
+	if err != nil {
+		t.Fatalf("Tail failed: %v", err)
+	}
+	defer tail.Close()
+
+	m, err := tail.model()
+	if err != nil {
+		t.Fatalf("model failed: %v", err)
+	}
+	for schema, want := range map[string]string{
+		"User":    "[u6 u7 u8 u9 u10]",
+		"Product": "[SKU006 SKU007 SKU008 SKU009 SKU010]",
+		"Order":   "[o1016 o1017 o1018 o1019 o1020]",
+	} {
+		lists, err := m.listsOf(schema)
+		if err != nil {
+			t.Fatalf("listsOf(%q) failed: %v", schema, err)
+		}
+		var ids []interface{}
+		for _, row := range lists[0].rows {
+			ids = append(ids, row.values[0])
+		}
+		if got := fmt.Sprint(ids); got != want {
+			t.Errorf("Expected %s ids %s, got %s", schema, want, got)
+		}
+	}
+
+	all, err := doc.Tail(100)
+	if err != nil {
+		t.Fatalf("Tail failed: %v", err)
+	}
+	defer all.Close()
+	m, err = all.model()
+	if err != nil {
+		t.Fatalf("model failed: %v", err)
+	}
+	if lists, _ := m.listsOf("Order"); len(lists) != 1 || len(lists[0].rows) != 20 {
+		t.Error("Expected Tail beyond the row count to keep every row")
+	}
+}
+
+func TestHead(t *testing.T) {
+	fixtures := GetGlobalFixtures()
+	medium, err := fixtures.MediumHEDL()
+	if err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+
+	doc, err := Parse(medium, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	head, err := doc.Head(3)
+	if err != nil {
+		t.Fatalf("Head failed: %v", err)
+	}
+	defer head.Close()
+
+	m, err := head.model()
+	if err != nil {
+		t.Fatalf("model failed: %v", err)
+	}
+	lists, err := m.listsOf("Employee")
+	if err != nil {
+		t.Fatalf("listsOf failed: %v", err)
+	}
+	var ids []interface{}
+	for _, row := range lists[0].rows {
+		ids = append(ids, row.values[0])
+	}
+	if got := fmt.Sprint(ids); got != "[e0 e1 e2]" {
+		t.Errorf("Expected ids [e0 e1 e2], got %s", got)
+	}
+
+	var hedlErr *HedlError
+	if _, err := doc.Head(-1); !errors.As(err, &hedlErr) || hedlErr.Code != ErrInvalidArgument {
+		t.Errorf("Expected ErrInvalidArgument for a negative count, got %v", err)
+	}
+}
+
+func TestJoin(t *testing.T) {
+	const profiles = `%VERSION: 1.0
+%STRUCT: User: [id, age, country]
+---
+users: @User
+  | alice, 30, NL
+  | carol, 41, DE
+`
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+	other, err := Parse(profiles, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer other.Close()
+
+	joined, err := doc.Join(other, "User", "id", []string{"age", "country"})
+	if err != nil {
+		t.Fatalf("Join failed: %v", err)
+	}
+	defer joined.Close()
+
+	canonical := mustCanonicalize(t, joined)
+	for _, want := range []string{"[id,name,email,age,country]", "alice,Alice Smith,alice@example.com,30,NL", "bob,Bob Jones,bob@example.com,~,~"} {
+		if !strings.Contains(canonical, want) {
+			t.Errorf("Expected %q in joined document:\n%s", want, canonical)
+		}
+	}
+	if strings.Contains(canonical, "carol") {
+		t.Errorf("Expected unmatched right rows to be dropped:\n%s", canonical)
+	}
+}
+
+func TestJoinMixedSchemas(t *testing.T) {
+	const left = `%VERSION: 1.0
+%STRUCT: User: [id, name]
+---
+users: @User
+  | u1, Alice
+  | u3, Carol
+`
+	const right = `%VERSION: 1.0
+---
+a: @User[id, email]
+  | u1, alice@example.com
+b: @User[id, name, email]
+  | u3, Carol, carol@example.com
+`
+	doc, err := Parse(left, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+	other, err := Parse(right, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer other.Close()
+
+	joined, err := doc.Join(other, "User", "id", []string{"email"})
+	if err != nil {
+		t.Fatalf("Join failed: %v", err)
+	}
+	defer joined.Close()
+	canonical := mustCanonicalize(t, joined)
+	for _, want := range []string{"u1,Alice,alice@example.com", "u3,Carol,carol@example.com"} {
+		if !strings.Contains(canonical, want) {
+			t.Errorf("Expected %q in joined document:\n%s", want, canonical)
+		}
+	}
+
+	_, err = other.Join(doc, "User", "id", []string{"name"})
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrInvalidArgument {
+		t.Errorf("Expected ErrInvalidArgument for left lists with differing schemas, got %v", err)
+	}
+}
+
+func TestJoinNullKeys(t *testing.T) {
+	const left = `%VERSION: 1.0
+%STRUCT: Order: [id, customer]
+---
+orders: @Order
+  | o1, ~
+  | o2, c1
+`
+	const right = `%VERSION: 1.0
+%STRUCT: Order: [id, customer, region]
+---
+orders: @Order
+  | x1, ~, north
+  | x2, ~, south
+  | x3, c1, east
+`
+	doc, err := Parse(left, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+	other, err := Parse(right, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer other.Close()
+
+	// The two null keys on the right neither conflict nor match o1.
+	joined, err := doc.Join(other, "Order", "customer", []string{"region"})
+	if err != nil {
+		t.Fatalf("Join failed: %v", err)
+	}
+	defer joined.Close()
+
+	canonical := mustCanonicalize(t, joined)
+	for _, want := range []string{"|o1,~,~", "|o2,c1,east"} {
+		if !strings.Contains(canonical, want) {
+			t.Errorf("Expected %q in joined document:\n%s", want, canonical)
+		}
+	}
+}
+
+func TestJoinConflict(t *testing.T) {
+	doc, err := Parse(sampleHEDL, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	_, err = doc.Join(doc, "User", "id", []string{"email"})
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrConflict {
+		t.Errorf("Expected ErrConflict for an existing field, got %v", err)
+	}
+}
+
+func TestNormalizeDates(t *testing.T) {
+	doc, err := Parse(`%VERSION: 1.0
+%STRUCT: Order: [id, placed, note]
+---
+orders: @Order
+  | o1, 01/02/2006, first
+  | o2, 12/31/2023, second
+  | o3, someday, third
+  | o4, ~, fourth
+`, true)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	defer doc.Close()
+
+	normalized, diag, err := doc.NormalizeDates([]string{"Order.placed"}, time.RFC3339)
+	if err != nil {
+		t.Fatalf("NormalizeDates failed: %v", err)
+	}
+	defer normalized.Close()
+	defer diag.Close()
+
+	canonical, err := normalized.Canonicalize()
+	if err != nil {
+		t.Fatalf("Canonicalize failed: %v", err)
+	}
+	for _, want := range []string{"2006-01-02T00:00:00Z", "2023-12-31T00:00:00Z", "someday"} {
+		if !strings.Contains(canonical, want) {
+			t.Errorf("Expected %q in normalized document:\n%s", want, canonical)
+		}
+	}
+	all, err := diag.All()
+	if err != nil {
+		t.Fatalf("All failed: %v", err)
+	}
+	if len(all) != 1 || all[0].Severity != SeverityWarning || !strings.Contains(all[0].Message, `Order row 2 field "placed"`) {
+		t.Errorf("Expected one warning for row 2, got %v", all)
+	}
+
+	_, _, err = doc.NormalizeDates([]string{"Order.missing"}, time.RFC3339)
+	var hedlErr *HedlError
+	if !errors.As(err, &hedlErr) || hedlErr.Code != ErrNotFound {
+		t.Errorf("Expected ErrNotFound for an unknown field, got %v", err)
+	}
+}
+
+func TestStrftimeFormat(t *testing.T) {
+	tests := []struct {
+		layout string
+		want   string
+	}{
+		{time.RFC3339, "%Y-%m-%dT%H:%M:%S%#z"},
+		{time.RFC3339Nano, "%Y-%m-%dT%H:%M:%S%.f%#z"},
+		{"2006-01-02", "%Y-%m-%d"},
+		{"Jan _2 15:04:05.000000", "%b %e %H:%M:%S%.6f"},
+		{"Monday, 2 January 06 3:04PM -0700", "%A, %-d %B %y %-I:%M%p %z"},
+		{"day 002 at 100%", "day %j at %-m00%%"},
+	}
+	for _, tt := range tests {
+		got, err := strftimeFormat(tt.layout)
+		if err != nil || got != tt.want {
+			t.Errorf("strftimeFormat(%q) = %q, %v; want %q", tt.layout, got, err, tt.want)
+		}
+	}
+
+	for _, layout := range []string{"", time.RFC1123, "15:04:05.0000", "2006-01-02Z0700"} {
+		_, err := strftimeFormat(layout)
+		var hedlErr *HedlError
+		if !errors.As(err, &hedlErr) || hedlErr.Code != ErrInvalidArgument {
+			t.Errorf("Expected ErrInvalidArgument for %q, got %v", layout, err)
+		}
+	}
+}
@@ -51,7 +51,10 @@ mod value;
 pub use document::{Document, Item, MatrixList, Node};
 pub use error::{HedlError, HedlErrorKind, HedlResult};
 pub use limits::Limits;
-pub use parser::{parse, parse_with_limits, ParseOptions, ParseOptionsBuilder};
+pub use parser::{
+    parse, parse_with_deadline, parse_with_limits, ParseOptions, ParseOptionsBuilder,
+    DEADLINE_EXCEEDED,
+};
 pub use traverse::{traverse, DocumentVisitor, StatsCollector, VisitorContext};
 pub use value::{Reference, Value};
 
@@ -76,6 +76,7 @@ use crate::value::Value;
 use crate::lex::{calculate_indent, is_valid_key_token, is_valid_type_name, strip_comment};
 use crate::lex::row::parse_csv_row;
 use std::collections::BTreeMap;
+use std::time::Instant;
 
 /// Parsing options for configuring HEDL document parsing behavior.
 ///
@@ -419,6 +420,46 @@ pub fn parse(input: &[u8]) -> HedlResult<Document> {
 
 /// Parse a HEDL document with custom options.
 pub fn parse_with_limits(input: &[u8], options: ParseOptions) -> HedlResult<Document> {
+    parse_document(input, options, None)
+}
+
+/// Parse a HEDL document with custom options, giving up once `deadline` has
+/// passed.
+///
+/// The deadline is checked between parsing phases and every
+/// [`DEADLINE_CHECK_INTERVAL`] body lines, so an overrunning parse is aborted
+/// with a security error instead of running to completion.
+pub fn parse_with_deadline(
+    input: &[u8],
+    options: ParseOptions,
+    deadline: Instant,
+) -> HedlResult<Document> {
+    parse_document(input, options, Some(deadline))
+}
+
+/// Number of body lines parsed between deadline checks.
+pub const DEADLINE_CHECK_INTERVAL: usize = 256;
+
+/// Message of the security error returned once a parse deadline has passed.
+pub const DEADLINE_EXCEEDED: &str = "parse deadline exceeded";
+
+/// Fail with a security error if `deadline` has passed.
+fn check_deadline(deadline: Option<Instant>, line: usize) -> HedlResult<()> {
+    match deadline {
+        Some(deadline) if Instant::now() >= deadline => {
+            Err(HedlError::security(DEADLINE_EXCEEDED, line))
+        }
+        _ => Ok(()),
+    }
+}
+
+fn parse_document(
+    input: &[u8],
+    options: ParseOptions,
+    deadline: Option<Instant>,
+) -> HedlResult<Document> {
+    check_deadline(deadline, 0)?;
+
     // Phase 1: Preprocess (zero-copy line splitting)
     let preprocessed = preprocess(input, &options.limits)?;
 
@@ -427,11 +468,19 @@ pub fn parse_with_limits(input: &[u8], options: ParseOptions) -> HedlResult<Docu
 
     // Phase 2: Parse header
     let (header, body_start_idx) = parse_header(&lines, &options.limits)?;
+    check_deadline(deadline, 0)?;
 
     // Phase 3: Parse body
     let body_lines = &lines[body_start_idx..];
     let mut type_registries = TypeRegistry::new();
-    let root = parse_body(body_lines, &header, &options.limits, &mut type_registries)?;
+    let root = parse_body(
+        body_lines,
+        &header,
+        &options.limits,
+        &mut type_registries,
+        deadline,
+    )?;
+    check_deadline(deadline, 0)?;
 
     // Build document
     let mut doc = Document::new(header.version);
@@ -478,6 +527,7 @@ fn parse_body(
     header: &crate::header::Header,
     limits: &Limits,
     type_registries: &mut TypeRegistry,
+    deadline: Option<Instant>,
 ) -> HedlResult<BTreeMap<String, Item>> {
     let mut stack: Vec<Frame> = vec![Frame::Root {
         object: BTreeMap::new(),
@@ -486,7 +536,11 @@ fn parse_body(
     let mut total_keys = 0usize;
     let mut block_string: Option<BlockStringState> = None;
 
-    for &(line_num, line) in lines {
+    for (idx, &(line_num, line)) in lines.iter().enumerate() {
+        if idx % DEADLINE_CHECK_INTERVAL == 0 {
+            check_deadline(deadline, line_num)?;
+        }
+
         // Handle block string accumulation mode
         if let Some(ref mut state) = block_string {
             // Process the line and check if block string is complete
@@ -1692,4 +1746,22 @@ mod tests {
         assert_eq!(opts.limits.max_nodes, 1000);
         assert!(opts.strict_refs);
     }
+
+    // ==================== parse_with_deadline tests ====================
+
+    #[test]
+    fn test_parse_with_deadline_in_future() {
+        let input = b"%VERSION: 1.0\n---\nkey: value\n";
+        let deadline = Instant::now() + std::time::Duration::from_secs(60);
+        let doc = parse_with_deadline(input, ParseOptions::default(), deadline).unwrap();
+        assert_eq!(doc.root.len(), 1);
+    }
+
+    #[test]
+    fn test_parse_with_deadline_in_past() {
+        let input = b"%VERSION: 1.0\n---\nkey: value\n";
+        let err = parse_with_deadline(input, ParseOptions::default(), Instant::now()).unwrap_err();
+        assert_eq!(err.kind, crate::error::HedlErrorKind::Security);
+        assert_eq!(err.message, DEADLINE_EXCEEDED);
+    }
 }
@@ -30,9 +30,11 @@
  * **IMPORTANT:** Memory ownership follows strict rules:
  *
  * - Strings returned by hedl_* functions MUST be freed with hedl_free_string()
- * - Byte arrays returned by hedl_to_parquet() MUST be freed with hedl_free_bytes()
+ * - Byte arrays returned by hedl_to_parquet() and hedl_to_sqlite() MUST be freed with
+ *   hedl_free_bytes()
  * - Documents MUST be freed with hedl_free_document()
  * - Diagnostics MUST be freed with hedl_free_diagnostics()
+ * - CSV cursors MUST be freed with hedl_free_csv_cursor()
  *
  * **WARNING:** The hedl_free_* functions ONLY accept pointers allocated by HEDL.
  * Passing pointers from malloc/calloc or other libraries causes undefined behavior.
@@ -137,6 +139,23 @@ namespace hedl {
 
 #define HEDL_ERR_NEO4J -12
 
+#define HEDL_ERR_TIMEOUT -13
+
+#define HEDL_ERR_NOT_FOUND -14
+
+#define HEDL_ERR_TOML -15
+
+#define HEDL_ERR_CONFLICT -16
+
+#define HEDL_ERR_INVALID_ARGUMENT -17
+
+#define HEDL_ERR_SQLITE -18
+
+/*
+ Opaque handle to a cursor over the rows of one struct type, read as CSV
+ */
+typedef struct HedlCsvCursor HedlCsvCursor;
+
 /*
  Opaque handle to lint diagnostics
  */
@@ -158,10 +177,332 @@ typedef struct HedlDocument HedlDocument;
  */
 typedef void (*HedlOutputCallback)(const char *data, uintptr_t len, void *user_data);
 
+/*
+ Chunk callback function type for streaming output.
+
+ Called once per chunk of output, in order. Returning 0 continues the
+ conversion; any other value stops it.
+
+ # Safety
+ - The `data` pointer is only valid during the callback execution
+ - Do NOT store the pointer for later use
+ - The data is not null-terminated
+ - The callback MUST NOT call back into HEDL functions
+ */
+typedef int (*HedlChunkCallback)(const char *data, uintptr_t len, void *user_data);
+
 #ifdef __cplusplus
 extern "C" {
 #endif // __cplusplus
 
+/*
+ Check that every qualified reference names a defined type.
+
+ Strict parsing rejects a reference `@Type:id` whose type is neither
+ declared with `%STRUCT` nor used by a matrix list, but in non-strict mode
+ it parses silently. Each one is reported as an error with rule ID
+ "undefined-schema": key-value pairs first, by dot-separated key path,
+ then matrix cells, by type, row index counted across the type's lists in
+ document order, and field.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `out_diag` - Pointer to store diagnostics handle (must be freed with hedl_free_diagnostics)
+
+ # Returns
+ HEDL_OK on success, error code on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_check_schema_references(const struct HedlDocument *doc, struct HedlDiagnostics **out_diag);
+
+/*
+ Get the indices of the rows of one struct type that hold a null or empty
+ string in any field.
+
+ HEDL schemas have no optional fields, so every field counts as required.
+ Indices count the rows of the type across its lists in document order,
+ including nested lists, and are written one per line; an empty string
+ means no row is incomplete.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `schema_name` - NUL-terminated name of the struct type
+ * `out_str` - Pointer to store the indices (must be freed with hedl_free_string)
+
+ # Returns
+ HEDL_OK on success, HEDL_ERR_NOT_FOUND if the type is neither declared
+ nor used by a list, error code on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_rows_with_missing(const struct HedlDocument *doc, const char *schema_name, char **out_str);
+
+/*
+ Get the fields of a struct type that hold a null or empty string in
+ every row of the type.
+
+ Such fields carry no data and are candidates for removal. Rows are read
+ by the schema of their own list, across all of the type's lists including
+ nested ones. The fields are written one per line in schema order; an
+ empty string means every field is used or the type has no rows.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `schema_name` - NUL-terminated name of the struct type
+ * `out_str` - Pointer to store the field names (must be freed with hedl_free_string)
+
+ # Returns
+ HEDL_OK on success, HEDL_ERR_NOT_FOUND if the type is neither declared
+ nor used by a list, error code on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_unused_fields(const struct HedlDocument *doc, const char *schema_name, char **out_str);
+
+/*
+ Get the fields of a struct type whose non-null values span more than one
+ type.
+
+ Types are named as `hedl_to_mermaid_er` names them, and as there, ints
+ and floats together count as float. Rows are read by the schema of their
+ own list, across all of the type's lists including nested ones. Each
+ mixed field is written on its own line, sorted by name, followed by the
+ sorted names of the types seen, separated by tabs; an empty string means
+ every field holds a single type.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `schema_name` - NUL-terminated name of the struct type
+ * `out_str` - Pointer to store the fields (must be freed with hedl_free_string)
+
+ # Returns
+ HEDL_OK on success, HEDL_ERR_NOT_FOUND if the type is neither declared
+ nor used by a list, error code on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_mixed_type_fields(const struct HedlDocument *doc, const char *schema_name, char **out_str);
+
+/*
+ Profile the fields of a struct type in a single pass over its rows.
+
+ Rows are read by the schema of their own list, across all of the type's
+ lists including nested ones. Each field of the type is written on its
+ own line, in schema order, followed by its number of non-null values and
+ its number of distinct non-null values, separated by tabs. Values are
+ compared by their HEDL text, so 1 and 1.0 are distinct.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `schema_name` - NUL-terminated name of the struct type
+ * `out_rows` - Pointer to store the number of rows of the type
+ * `out_str` - Pointer to store the field statistics (must be freed with hedl_free_string)
+
+ # Returns
+ HEDL_OK on success, HEDL_ERR_NOT_FOUND if the type is neither declared
+ nor used by a list, error code on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_field_stats(const struct HedlDocument *doc,
+                     const char *schema_name,
+                     int *out_rows,
+                     char **out_str);
+
+/*
+ Find the first cycle of references between rows, without modifying the
+ document.
+
+ Each row is identified as "Type:id", and an unqualified reference names a
+ row of the type of the row holding it; references to rows that do not
+ exist are ignored. Rows are visited in document order: each list, then
+ the lists nested under its rows. The rows of the first cycle found are
+ written one per line in reference order, so a row referencing itself is
+ a cycle of one; an empty string means there is no cycle.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `out_str` - Pointer to store the cycle (must be freed with hedl_free_string)
+
+ # Returns
+ HEDL_OK on success, error code on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_find_reference_cycle(const struct HedlDocument *doc, char **out_str);
+
+/*
+ Check that no two rows of a struct type share a value in one field.
+
+ Each value held by more than one row is reported once, as an error with
+ rule ID "unique" naming the indices of the rows holding it. Indices count
+ the rows of the type across its lists in document order, including nested
+ lists. Values of different types never match, so the string "1" and the
+ integer 1 are distinct, and null values are skipped, as in a SQL unique
+ constraint. Rows of a list whose schema lacks the field are skipped too.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `schema_name` - NUL-terminated name of the struct type
+ * `field` - NUL-terminated name of the field
+ * `out_diag` - Pointer to store diagnostics handle (must be freed with hedl_free_diagnostics)
+
+ # Returns
+ HEDL_OK on success, HEDL_ERR_NOT_FOUND if the type or field does not
+ exist, error code on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_check_unique(const struct HedlDocument *doc, const char *schema_name, const char *field, struct HedlDiagnostics **out_diag);
+
+/*
+ Check that the numeric values of some fields fall within inclusive
+ ranges.
+
+ HEDL has no syntax for range annotations, so each field is named
+ "Type.field" and given its bounds by the caller. Every value below its
+ minimum or above its maximum is reported as an error with rule ID
+ "range", and so is every value that is not a number; null values are
+ skipped. Diagnostics follow the order of `fields`, then the row index,
+ counted across the type's lists in document order. Rows of a list whose
+ schema lacks the field are skipped.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `fields` - Array of `field_count` NUL-terminated "Type.field" names
+ * `mins` - Array of `field_count` lower bounds
+ * `maxs` - Array of `field_count` upper bounds
+ * `field_count` - Number of fields
+ * `out_diag` - Pointer to store diagnostics handle (must be freed with hedl_free_diagnostics)
+
+ # Returns
+ HEDL_OK on success, HEDL_ERR_INVALID_ARGUMENT for a field name without a
+ type or a minimum above its maximum, HEDL_ERR_NOT_FOUND if a type or
+ field does not exist, error code on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_check_ranges(const struct HedlDocument *doc,
+                      const char *const *fields,
+                      const double *mins,
+                      const double *maxs,
+                      int field_count,
+                      struct HedlDiagnostics **out_diag);
+
+/*
+ Check that the values of some fields match a key of another struct type.
+
+ Each foreign key pairs a "Type.field" name in `from_fields` with the
+ "Type.field" name of the key it refers to in `to_fields`. Every row
+ whose value has no match among the key values of the referenced type is
+ reported as an error with rule ID "foreign-key", naming the row index
+ counted across the type's lists in document order. Values are compared
+ by their plain text; a reference matches by its ID, and a qualified
+ reference to another type never matches. Null values are skipped, as
+ are rows of a list whose schema lacks the field.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `from_fields` - Array of `key_count` NUL-terminated "Type.field" names holding the keys
+ * `to_fields` - Array of `key_count` NUL-terminated "Type.field" names of the referenced keys
+ * `key_count` - Number of foreign keys
+ * `out_diag` - Pointer to store diagnostics handle (must be freed with hedl_free_diagnostics)
+
+ # Returns
+ HEDL_OK on success, HEDL_ERR_INVALID_ARGUMENT for a field name without a
+ type, HEDL_ERR_NOT_FOUND if a type or field does not exist, error code
+ on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_check_foreign_keys(const struct HedlDocument *doc,
+                            const char *const *from_fields,
+                            const char *const *to_fields,
+                            int key_count,
+                            struct HedlDiagnostics **out_diag);
+
+/*
+ Check for string values with leading or trailing whitespace.
+
+ Each one is reported as a warning with rule ID "whitespace": key-value
+ pairs first, by dot-separated key path, then matrix cells, by type, row
+ index counted across the type's lists in document order, and field.
+ `hedl_trim_strings` removes them.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `out_diag` - Pointer to store diagnostics handle (must be freed with hedl_free_diagnostics)
+
+ # Returns
+ HEDL_OK on success, error code on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_check_whitespace(const struct HedlDocument *doc, struct HedlDiagnostics **out_diag);
+
+/*
+ Copy a document with leading and trailing whitespace removed from every
+ string value, in key-value pairs and matrix cells, fixing what
+ `hedl_check_whitespace` reports. References, expressions and other
+ values are unchanged.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `out_doc` - Pointer to store the new document handle (must be freed with hedl_free_document)
+
+ # Returns
+ HEDL_OK on success, error code on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_trim_strings(const struct HedlDocument *doc, struct HedlDocument **out_doc);
+
+/*
+ Copy a document with safe fixes applied, and describe each change, for a
+ "hedl fix" command. The fixes are, in order:
+
+ - CRLF and lone CR line endings in strings become LF.
+ - Leading and trailing whitespace is trimmed from strings.
+ - A string in a column whose other values are all numbers, or all
+   booleans, becomes that type if it reads as one, so `"42"` becomes 42
+   as it would unquoted and `"False"` becomes false. Key-value pairs have
+   no column to go by and are left as strings.
+ - %STRUCT and %NEST declarations no list uses are dropped, which clears
+   the unused-schema lint warning.
+
+ Object keys need no fixing, since documents keep them sorted by name.
+
+ The changelog has one change per line: string fixes in key-value pairs
+ first, by dot-separated key path, then in matrix cells, by type, row
+ index counted across the type's lists in document order, and field;
+ then coercions, by type, field and row; then removed types. It is empty
+ when nothing needs fixing.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `out_doc` - Pointer to store the fixed document handle (must be freed with hedl_free_document)
+ * `out_changes` - Pointer to store the changelog (must be freed with hedl_free_string)
+
+ # Returns
+ HEDL_OK on success, error code on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_auto_fix(const struct HedlDocument *doc, struct HedlDocument **out_doc, char **out_changes);
+
 /*
  Parse JSON into a HEDL document.
 
@@ -219,6 +560,32 @@ int hedl_from_yaml(const char *yaml, int yaml_len, struct HedlDocument **out_doc
  */
 int hedl_from_xml(const char *xml, int xml_len, struct HedlDocument **out_doc);
 
+/*
+ Parse CSV with a header row into a HEDL document.
+
+ The header defines a single `Row` struct whose first column is the row ID
+ and is always named `id`; the other header names are lowercased, with
+ characters not valid in a key replaced by underscores. Every following
+ record becomes a row of the `rows` list. Quoted fields may contain commas
+ and newlines. A record with a different number of fields than the header
+ fails with HEDL_ERR_CSV.
+
+ # Arguments
+ * `csv` - UTF-8 encoded CSV string
+ * `csv_len` - Length of input in bytes, or -1 for null-terminated
+ * `out_doc` - Pointer to store document handle
+
+ # Returns
+ HEDL_OK on success, error code on failure.
+
+ # Safety
+ All pointers must be valid.
+
+ # Feature
+ Requires the "csv" feature to be enabled.
+ */
+int hedl_from_csv(const char *csv, int csv_len, struct HedlDocument **out_doc);
+
 /*
  Parse Parquet bytes into a HEDL document.
 
@@ -239,31 +606,38 @@ int hedl_from_xml(const char *xml, int xml_len, struct HedlDocument **out_doc);
 int hedl_from_parquet(const uint8_t *data, uintptr_t len, struct HedlDocument **out_doc);
 
 /*
- Convert a HEDL document to JSON.
+ Parse TOML into a HEDL document.
+
+ Tables become nested objects and arrays of tables become matrix lists,
+ typed as `hedl_from_json` types arrays of objects: the key is singularized
+ and capitalized for the struct name, so `[[users]]` becomes
+ `users: @User`. Dates and times are kept as strings. Infinite and NaN
+ floats have no JSON form and are rejected.
 
  # Arguments
- * `doc` - Document handle from hedl_parse
- * `include_metadata` - Non-zero to include HEDL metadata (__type__, __schema__)
- * `out_str` - Pointer to store JSON output (must be freed with hedl_free_string)
+ * `toml` - UTF-8 encoded TOML string
+ * `toml_len` - Length of input in bytes, or -1 for null-terminated
+ * `out_doc` - Pointer to store document handle
 
  # Returns
- HEDL_OK on success, error code on failure.
+ HEDL_OK on success, HEDL_ERR_TOML if the input is not valid TOML or
+ cannot be converted, error code on failure.
 
  # Safety
  All pointers must be valid.
 
  # Feature
- Requires the "json" feature to be enabled.
+ Requires the "toml" feature to be enabled.
  */
-int hedl_to_json(const struct HedlDocument *doc, int include_metadata, char **out_str);
+int hedl_from_toml(const char *toml, int toml_len, struct HedlDocument **out_doc);
 
 /*
- Convert a HEDL document to YAML.
+ Convert a HEDL document to JSON.
 
  # Arguments
  * `doc` - Document handle from hedl_parse
- * `include_metadata` - Non-zero to include HEDL metadata
- * `out_str` - Pointer to store YAML output (must be freed with hedl_free_string)
+ * `include_metadata` - Non-zero to include HEDL metadata (__type__, __schema__)
+ * `out_str` - Pointer to store JSON output (must be freed with hedl_free_string)
 
  # Returns
  HEDL_OK on success, error code on failure.
@@ -272,527 +646,1595 @@ int hedl_to_json(const struct HedlDocument *doc, int include_metadata, char **ou
  All pointers must be valid.
 
  # Feature
- Requires the "yaml" feature to be enabled.
+ Requires the "json" feature to be enabled.
  */
-int hedl_to_yaml(const struct HedlDocument *doc, int include_metadata, char **out_str);
+int hedl_to_json(const struct HedlDocument *doc, int include_metadata, char **out_str);
 
 /*
- Convert a HEDL document to XML.
+ Convert the rows of one struct type to JSON, grouped by the value of a
+ field.
+
+ The output is an object with one key per distinct value of `group_by`,
+ in sorted order, each holding the array of rows with that value in
+ document order, formatted as by `hedl_to_json` without metadata. Rows are
+ collected from every list of the type, including nested ones, and keep
+ their own nested children. Group keys are the HEDL text of the value, so
+ a null value is grouped under "~".
 
  # Arguments
  * `doc` - Document handle from hedl_parse
- * `out_str` - Pointer to store XML output (must be freed with hedl_free_string)
+ * `schema_name` - NUL-terminated name of the struct type to group
+ * `group_by` - NUL-terminated name of the field to group by
+ * `out_str` - Pointer to store JSON output (must be freed with hedl_free_string)
 
  # Returns
- HEDL_OK on success, error code on failure.
+ HEDL_OK on success, error code on failure. HEDL_ERR_NOT_FOUND is returned
+ when the type or field does not exist.
 
  # Safety
  All pointers must be valid.
 
  # Feature
- Requires the "xml" feature to be enabled.
+ Requires the "json" feature to be enabled.
  */
-int hedl_to_xml(const struct HedlDocument *doc, char **out_str);
+int hedl_to_grouped_json(const struct HedlDocument *doc,
+                         const char *schema_name,
+                         const char *group_by,
+                         char **out_str);
 
 /*
- Convert a HEDL document to CSV.
+ Convert a page of the rows of one struct type to a JSON array.
 
- Note: Only works for documents with matrix lists.
+ Rows are counted across every list of the type in document order,
+ including nested ones, and rows `[offset, offset + limit)` are written as
+ by `hedl_to_json` without metadata, keeping their own nested children,
+ in compact form. Negative values count as zero and the page is clamped
+ to the available rows, so a page past the end is `[]`. Only the rows of
+ the page are copied.
 
  # Arguments
  * `doc` - Document handle from hedl_parse
- * `out_str` - Pointer to store CSV output (must be freed with hedl_free_string)
+ * `schema_name` - NUL-terminated name of the struct type
+ * `offset` - Index of the first row of the page
+ * `limit` - Maximum number of rows in the page
+ * `out_str` - Pointer to store JSON output (must be freed with hedl_free_string)
 
  # Returns
- HEDL_OK on success, error code on failure.
+ HEDL_OK on success, error code on failure. HEDL_ERR_NOT_FOUND is returned
+ when the type does not exist.
 
  # Safety
  All pointers must be valid.
 
  # Feature
- Requires the "csv" feature to be enabled.
+ Requires the "json" feature to be enabled.
  */
-int hedl_to_csv(const struct HedlDocument *doc, char **out_str);
+int hedl_to_json_page(const struct HedlDocument *doc,
+                      const char *schema_name,
+                      int offset,
+                      int limit,
+                      char **out_str);
 
 /*
- Convert a HEDL document to Parquet bytes.
+ Look up the row of one struct type whose key field has a given value and
+ convert it to a JSON object.
 
- Note: Only works for documents with matrix lists.
+ The row is written as by `hedl_to_json` without metadata, keeping its
+ nested children, in compact form. Values are compared by their plain
+ text, so strings are unquoted and the integer 7 matches "7"; rows with a
+ null key never match. Rows are searched in every list of the type in
+ document order, including nested ones.
 
  # Arguments
  * `doc` - Document handle from hedl_parse
- * `out_data` - Pointer to store output data pointer
- * `out_len` - Pointer to store output length
+ * `schema_name` - NUL-terminated name of the struct type
+ * `key_field` - NUL-terminated name of the field to match
+ * `key_value` - NUL-terminated value to look up
+ * `first_wins` - Non-zero to return the first of several matching rows
+ * `out_str` - Pointer to store JSON output (must be freed with hedl_free_string)
 
  # Returns
- HEDL_OK on success, error code on failure.
- The output data must be freed with hedl_free_bytes.
+ HEDL_OK on success, error code on failure. HEDL_ERR_NOT_FOUND is returned
+ when the type or field does not exist or no row matches, and
+ HEDL_ERR_CONFLICT when several rows match and `first_wins` is zero.
 
  # Safety
  All pointers must be valid.
 
  # Feature
- Requires the "parquet" feature to be enabled.
+ Requires the "json" feature to be enabled.
  */
-int hedl_to_parquet(const struct HedlDocument *doc, uint8_t **out_data, uintptr_t *out_len);
+int hedl_get_by_key(const struct HedlDocument *doc,
+                    const char *schema_name,
+                    const char *key_field,
+                    const char *key_value,
+                    int first_wins,
+                    char **out_str);
 
 /*
- Convert a HEDL document to Cypher queries for Neo4j.
+ Index the rows of one struct type by a key field, as a JSON object
+ mapping each key to its row.
 
- Generates CREATE/MERGE statements, constraints, and relationships.
+ Keys are the plain text of the key field, as in a CSV field, so the
+ integer 7 and the string "7" share a key. Rows are written as by
+ `hedl_get_by_key`. Rows with a null key, and rows of lists whose inline
+ schema lacks the key field, are skipped. Rows are searched in every
+ list of the type in document order, including nested ones.
 
  # Arguments
  * `doc` - Document handle from hedl_parse
- * `use_merge` - Non-zero to use MERGE (idempotent), zero for CREATE
- * `out_str` - Pointer to store Cypher output (must be freed with hedl_free_string)
+ * `schema_name` - NUL-terminated name of the struct type
+ * `key_field` - NUL-terminated name of the field to index by
+ * `allow_duplicates` - Non-zero to keep the last of several rows with a key
+ * `out_str` - Pointer to store JSON output (must be freed with hedl_free_string)
 
  # Returns
- HEDL_OK on success, error code on failure.
+ HEDL_OK on success, error code on failure. HEDL_ERR_NOT_FOUND is returned
+ when the type or field does not exist, and HEDL_ERR_CONFLICT when several
+ rows share a key and `allow_duplicates` is zero.
 
  # Safety
  All pointers must be valid.
 
  # Feature
- Requires the "neo4j" feature to be enabled.
+ Requires the "json" feature to be enabled.
  */
-int hedl_to_neo4j_cypher(const struct HedlDocument *doc, int use_merge, char **out_str);
+int hedl_index_by(const struct HedlDocument *doc,
+                  const char *schema_name,
+                  const char *key_field,
+                  int allow_duplicates,
+                  char **out_str);
 
 /*
- Convert a HEDL document to JSON using zero-copy callback pattern.
+ Generate an example JSON document with one object per struct type.
 
- For outputs >1MB, this avoids memory allocation by passing data directly
- to the callback. For smaller outputs, consider using `hedl_to_json`.
+ The output is an object keyed by struct type name, in sorted order, each
+ holding one example row with every declared field, formatted as by
+ `hedl_to_json` without metadata and without nested children. A field
+ takes its value from the first row of the type where it is not null;
+ fields that are null in every row, and all fields of a type without
+ rows, get the string "example".
 
  # Arguments
  * `doc` - Document handle from hedl_parse
- * `include_metadata` - Non-zero to include HEDL metadata (__type__, __schema__)
- * `callback` - Function to receive the output data
- * `user_data` - User context pointer passed to callback
+ * `out_str` - Pointer to store JSON output (must be freed with hedl_free_string)
 
  # Returns
  HEDL_OK on success, error code on failure.
 
  # Safety
- - All pointers must be valid
- - The callback MUST NOT call back into HEDL functions
- - The data pointer passed to callback is only valid during the callback
+ All pointers must be valid.
 
  # Feature
  Requires the "json" feature to be enabled.
  */
-int hedl_to_json_callback(const struct HedlDocument *doc,
-                          int include_metadata,
-                          HedlOutputCallback callback,
-                          void *user_data);
+int hedl_example_json(const struct HedlDocument *doc, char **out_str);
 
 /*
- Convert a HEDL document to YAML using zero-copy callback pattern.
-
- For outputs >1MB, this avoids memory allocation by passing data directly
- to the callback. For smaller outputs, consider using `hedl_to_yaml`.
+ Convert a HEDL document to YAML.
 
  # Arguments
  * `doc` - Document handle from hedl_parse
  * `include_metadata` - Non-zero to include HEDL metadata
- * `callback` - Function to receive the output data
- * `user_data` - User context pointer passed to callback
+ * `out_str` - Pointer to store YAML output (must be freed with hedl_free_string)
 
  # Returns
  HEDL_OK on success, error code on failure.
 
  # Safety
- - All pointers must be valid
- - The callback MUST NOT call back into HEDL functions
- - The data pointer passed to callback is only valid during the callback
+ All pointers must be valid.
 
  # Feature
  Requires the "yaml" feature to be enabled.
  */
-int hedl_to_yaml_callback(const struct HedlDocument *doc,
-                          int include_metadata,
-                          HedlOutputCallback callback,
-                          void *user_data);
+int hedl_to_yaml(const struct HedlDocument *doc, int include_metadata, char **out_str);
 
 /*
- Convert a HEDL document to XML using zero-copy callback pattern.
+ Convert a HEDL document to a multi-document YAML stream, with one YAML
+ document per root item.
 
- For outputs >1MB, this avoids memory allocation by passing data directly
- to the callback. For smaller outputs, consider using `hedl_to_xml`.
+ Root items are taken in key order and converted without metadata, each
+ ending with a newline; documents are separated by `---` lines. A
+ document without root items yields an empty string.
 
  # Arguments
  * `doc` - Document handle from hedl_parse
- * `callback` - Function to receive the output data
- * `user_data` - User context pointer passed to callback
+ * `out_str` - Pointer to store YAML output (must be freed with hedl_free_string)
 
  # Returns
  HEDL_OK on success, error code on failure.
 
  # Safety
- - All pointers must be valid
- - The callback MUST NOT call back into HEDL functions
- - The data pointer passed to callback is only valid during the callback
+ All pointers must be valid.
+
+ # Feature
+ Requires the "yaml" feature to be enabled.
+ */
+int hedl_to_yaml_multi(const struct HedlDocument *doc, char **out_str);
+
+/*
+ Convert a HEDL document to TOML.
+
+ Objects become tables and every matrix row becomes an entry in an array
+ of tables named after its key, so `users: @User` rows are written as
+ `[[users]]` blocks with their fields in schema order. Nested child rows
+ are written as arrays of tables under their parent row, named after the
+ child type. Within a table, key-value pairs come before subtables. TOML
+ has no null, so null values are omitted; references and expressions are
+ written as strings of their HEDL text and tensors as arrays.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `out_str` - Pointer to store TOML output (must be freed with hedl_free_string)
+
+ # Returns
+ HEDL_OK on success, error code on failure.
+
+ # Safety
+ All pointers must be valid.
+
+ # Feature
+ Requires the "toml" feature to be enabled.
+ */
+int hedl_to_toml(const struct HedlDocument *doc, char **out_str);
+
+/*
+ Convert a HEDL document to XML.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `out_str` - Pointer to store XML output (must be freed with hedl_free_string)
+
+ # Returns
+ HEDL_OK on success, error code on failure.
+
+ # Safety
+ All pointers must be valid.
 
  # Feature
  Requires the "xml" feature to be enabled.
  */
-int hedl_to_xml_callback(const struct HedlDocument *doc,
-                         HedlOutputCallback callback,
-                         void *user_data);
+int hedl_to_xml(const struct HedlDocument *doc, char **out_str);
 
 /*
- Convert a HEDL document to CSV using zero-copy callback pattern.
+ Convert a HEDL document to CSV.
 
  Note: Only works for documents with matrix lists.
 
- For outputs >1MB, this avoids memory allocation by passing data directly
- to the callback. For smaller outputs, consider using `hedl_to_csv`.
-
  # Arguments
  * `doc` - Document handle from hedl_parse
- * `callback` - Function to receive the output data
- * `user_data` - User context pointer passed to callback
+ * `out_str` - Pointer to store CSV output (must be freed with hedl_free_string)
 
  # Returns
  HEDL_OK on success, error code on failure.
 
  # Safety
- - All pointers must be valid
- - The callback MUST NOT call back into HEDL functions
- - The data pointer passed to callback is only valid during the callback
+ All pointers must be valid.
 
  # Feature
  Requires the "csv" feature to be enabled.
  */
-int hedl_to_csv_callback(const struct HedlDocument *doc,
-                         HedlOutputCallback callback,
-                         void *user_data);
+int hedl_to_csv(const struct HedlDocument *doc, char **out_str);
 
 /*
- Convert a HEDL document to Cypher queries using zero-copy callback pattern.
+ Convert a HEDL document to a zip archive holding one CSV file per struct
+ type.
 
- Generates CREATE/MERGE statements, constraints, and relationships.
+ Each type becomes a deflated entry named `<Type>.csv`. Declared types
+ come first in name order, followed by types only used with inline schemas
+ in document order. Each file starts with a header row of the type's
+ fields, followed by every row of the type, including rows nested under
+ other rows, in document order. Records are written as by
+ `hedl_csv_cursor_next`; fields missing from a list's inline schema are
+ empty.
 
- For outputs >1MB, this avoids memory allocation by passing data directly
- to the callback. For smaller outputs, consider using `hedl_to_neo4j_cypher`.
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `out_data` - Pointer to store output data pointer
+ * `out_len` - Pointer to store output length
+
+ # Returns
+ HEDL_OK on success, HEDL_ERR_CSV if the archive exceeds the limits of the
+ zip format, error code on failure.
+ The output data must be freed with hedl_free_bytes.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_to_csv_zip(const struct HedlDocument *doc, uint8_t **out_data, uintptr_t *out_len);
+
+/*
+ Open a cursor over the rows of one struct type for CSV export.
+
+ Rows are taken from every list of the type, including nested ones, in
+ document order. The cursor refers to the document's rows, so the
+ document must stay alive and unmodified until the cursor is freed.
 
  # Arguments
  * `doc` - Document handle from hedl_parse
- * `use_merge` - Non-zero to use MERGE (idempotent), zero for CREATE
- * `callback` - Function to receive the output data
- * `user_data` - User context pointer passed to callback
+ * `schema_name` - NUL-terminated name of the struct type
+ * `out_cursor` - Pointer to store the cursor (must be freed with hedl_free_csv_cursor)
 
  # Returns
- HEDL_OK on success, error code on failure.
+ HEDL_OK on success, HEDL_ERR_NOT_FOUND if the type is not declared,
+ error code on failure.
 
  # Safety
- - All pointers must be valid
- - The callback MUST NOT call back into HEDL functions
- - The data pointer passed to callback is only valid during the callback
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_csv_cursor_open(const struct HedlDocument *doc,
+                         const char *schema_name,
+                         struct HedlCsvCursor **out_cursor);
 
- # Feature
- Requires the "neo4j" feature to be enabled.
+/*
+ Read the next rows of a cursor as CSV.
+
+ The first call also writes the header row of the type's fields. Each
+ record is terminated by `\n`; strings are written as-is, null as an
+ empty field and other values as HEDL text, and fields containing a
+ comma, quote or line break, or starting with whitespace, are quoted.
+ Once every row has been read the output is empty and 0 is returned.
+
+ # Arguments
+ * `cursor` - Cursor from hedl_csv_cursor_open
+ * `max_rows` - Most rows to read; at least one is read while rows remain
+ * `out_str` - Pointer to store the CSV text (must be freed with hedl_free_string)
+
+ # Returns
+ The number of rows read, 0 at the end, or a negative error code on failure.
+
+ # Safety
+ All pointers must be valid, and the cursor's document must not have been
+ freed or modified. Returns HEDL_ERR_NULL_PTR if cursor is NULL or poisoned.
  */
-int hedl_to_neo4j_cypher_callback(const struct HedlDocument *doc,
-                                  int use_merge,
-                                  HedlOutputCallback callback,
-                                  void *user_data);
+int hedl_csv_cursor_next(struct HedlCsvCursor *cursor, int max_rows, char **out_str);
 
 /*
- Canonicalize a HEDL document using zero-copy callback pattern.
+ Convert a HEDL document to Parquet bytes.
 
- For outputs >1MB, this avoids memory allocation by passing data directly
- to the callback. For smaller outputs, consider using `hedl_canonicalize`.
+ Note: Only works for documents with matrix lists.
 
  # Arguments
  * `doc` - Document handle from hedl_parse
- * `callback` - Function to receive the output data
- * `user_data` - User context pointer passed to callback
+ * `out_data` - Pointer to store output data pointer
+ * `out_len` - Pointer to store output length
 
  # Returns
  HEDL_OK on success, error code on failure.
+ The output data must be freed with hedl_free_bytes.
 
  # Safety
- - All pointers must be valid
- - The callback MUST NOT call back into HEDL functions
- - The data pointer passed to callback is only valid during the callback
+ All pointers must be valid.
+
+ # Feature
+ Requires the "parquet" feature to be enabled.
  */
-int hedl_canonicalize_callback(const struct HedlDocument *doc,
-                               HedlOutputCallback callback,
-                               void *user_data);
+int hedl_to_parquet(const struct HedlDocument *doc, uint8_t **out_data, uintptr_t *out_len);
 
 /*
- Get the number of diagnostics.
+ Convert a HEDL document to the bytes of a SQLite 3 database file.
+
+ The database is built in memory with SQLite and serialized. It holds one
+ table per struct type, named after the type, with a column per field.
+ Declared types come first in name order, followed by types that only have
+ inline schemas. Every row of the type, including rows nested under other
+ rows, becomes a table row in document order; fields missing from a list's
+ inline schema are NULL.
+
+ Column types are inferred from the values: integers and booleans make an
+ INTEGER column and floats a REAL column, and integers mixed with floats a
+ REAL column. Columns with values of several other types, or no non-null
+ values, are left untyped, and all others are TEXT. Booleans are stored as
+ 0 and 1, and references, expressions and tensors as their HEDL text.
+ Tables have no keys or indexes.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `out_data` - Pointer to store output data pointer
+ * `out_len` - Pointer to store output length
+
+ # Returns
+ HEDL_OK on success, error code on failure.
+ The output data must be freed with hedl_free_bytes.
 
  # Safety
- Pointer must be valid. Returns -1 if diag is NULL or poisoned.
+ All pointers must be valid.
+
+ # Feature
+ Requires the "sqlite" feature to be enabled.
  */
-int hedl_diagnostics_count(const struct HedlDiagnostics *diag);
+int hedl_to_sqlite(const struct HedlDocument *doc, uint8_t **out_data, uintptr_t *out_len);
 
 /*
- Get a diagnostic message.
+ Convert a HEDL document to Cypher queries for Neo4j.
+
+ Generates CREATE/MERGE statements, constraints, and relationships.
 
  # Arguments
- * `diag` - Diagnostics handle
- * `index` - Diagnostic index
- * `out_str` - Pointer to store message (must be freed with hedl_free_string)
+ * `doc` - Document handle from hedl_parse
+ * `use_merge` - Non-zero to use MERGE (idempotent), zero for CREATE
+ * `out_str` - Pointer to store Cypher output (must be freed with hedl_free_string)
 
  # Returns
  HEDL_OK on success, error code on failure.
 
  # Safety
- All pointers must be valid. Returns HEDL_ERR_NULL_PTR if diag is NULL or poisoned.
+ All pointers must be valid.
+
+ # Feature
+ Requires the "neo4j" feature to be enabled.
  */
-int hedl_diagnostics_get(const struct HedlDiagnostics *diag, int index, char **out_str);
+int hedl_to_neo4j_cypher(const struct HedlDocument *doc, int use_merge, char **out_str);
 
 /*
- Get a diagnostic severity (0=Hint, 1=Warning, 2=Error).
+ Describe every struct type of a HEDL document with the inferred types of
+ its fields.
+
+ HEDL schemas do not declare field types, so each field is given the type
+ of its non-null values as in `hedl_to_mermaid_er`: bool, int, float,
+ string, tensor, reference or expression, float for a mix of ints and
+ floats, and any for other mixes or fields that are always null. Declared
+ types come first, by name, followed by types only used with inline
+ schemas in document order. Each type is written on a line of its own,
+ followed by one line per field in schema order holding a tab, the field
+ name, its type and 1 if some row holds null or 0 otherwise, separated by
+ tabs.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `out_str` - Pointer to store the descriptors (must be freed with hedl_free_string)
+
+ # Returns
+ HEDL_OK on success, error code on failure.
 
  # Safety
- Pointer must be valid. Returns -1 if diag is NULL or poisoned.
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
  */
-int hedl_diagnostics_severity(const struct HedlDiagnostics *diag, int index);
+int hedl_schema_descriptors(const struct HedlDocument *doc, char **out_str);
 
 /*
- Get the last error message for the current thread.
+ Convert the schemas of a HEDL document to a Mermaid `erDiagram`.
 
- Returns NULL if no error occurred on this thread.
+ Every struct type becomes an entity listing its fields with the type of
+ their non-null values: bool, int, float, string, tensor, reference or
+ expression, float for a mix of ints and floats, and any for other mixes
+ or fields that are always null. Declared types come first, by name,
+ followed by types only used with inline schemas in document order. Each
+ `%NEST` rule becomes a one-to-many relationship labelled "contains", and
+ each field holding a qualified reference `@Type:id` a many-to-one
+ relationship labelled with the field name.
 
- # Thread Safety
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `out_str` - Pointer to store the diagram (must be freed with hedl_free_string)
 
- Error messages are stored in thread-local storage. This function returns
- the error message for the CALLING THREAD ONLY. You must call this function
- from the same thread that received the error code.
+ # Returns
+ HEDL_OK on success, error code on failure.
 
- Each thread maintains its own independent error state. Errors in one thread
- do not affect or overwrite errors in other threads. This makes the FFI safe
- to use in multi-threaded applications without external synchronization for
- error handling.
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_to_mermaid_er(const struct HedlDocument *doc, char **out_str);
 
- # Lifetime
+/*
+ Convert a HEDL document to a Java `.properties` file.
 
- The returned pointer is valid until the next `hedl_*` call on this thread.
- Copy the string immediately if you need to preserve it.
+ Nested keys are flattened with dots, so `database: host: x` becomes
+ `database.host=x`. Matrix rows are numbered from zero under their list key
+ and keyed by field, as in `users.0.name=Alice`, with nested child rows
+ continuing the path under their type name. Values are plain text as in
+ CSV exports; properties have no null, so null values are omitted. Keys
+ and values are escaped as by `java.util.Properties.store`, including
+ `\uXXXX` escapes for non-ASCII characters, so the output loads under the
+ ISO-8859-1 default.
 
- # Example (C)
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `out_str` - Pointer to store the properties (must be freed with hedl_free_string)
 
- ```c
- // Thread 1
- if (hedl_parse(input1, -1, 0, &doc1) != HEDL_OK) {
-     const char* err1 = hedl_get_last_error();
-     printf("Thread 1 error: %s\n", err1);
- }
+ # Returns
+ HEDL_OK on success, error code on failure.
 
- // Thread 2 (concurrent with Thread 1)
- if (hedl_parse(input2, -1, 0, &doc2) != HEDL_OK) {
-     const char* err2 = hedl_get_last_error();
-     printf("Thread 2 error: %s\n", err2);
- }
- // err1 and err2 are independent - no cross-thread pollution
- ```
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
  */
-const char *hedl_get_last_error(void);
+int hedl_to_properties(const struct HedlDocument *doc, char **out_str);
 
 /*
- Clear the last error for the current thread.
+ Convert a HEDL document to InfluxDB line protocol, one line per row of
+ every type that has `time_field`, in document order with nested rows
+ included.
+
+ Each line is the measurement, the tag fields present in the row as tags
+ in the given order, every other non-null field as a field, and the
+ timestamp taken from `time_field`. Integer timestamps are written as they
+ are, so they should be nanoseconds since the epoch; strings are read as
+ RFC 3339 times or YYYY-MM-DD dates and converted to nanoseconds. Rows with
+ a null timestamp are written without one, so the server assigns it.
+
+ Field values follow the inferred type of their column, with ints and
+ floats together inferring float: ints are written with the `i` suffix,
+ numbers in float columns as floats, booleans as true/false and everything
+ else, including columns of mixed type, as strings. Rows with no non-null
+ fields cannot be represented and are skipped.
 
- This function explicitly clears any error message stored for the calling thread.
- It is generally not necessary to call this function, as successful operations
- automatically clear errors. However, it can be useful in specific scenarios:
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `measurement` - Measurement name written at the start of every line
+ * `time_field` - Field holding the timestamp
+ * `tag_fields` - Array of `tag_count` fields written as tags
+ * `tag_count` - Number of tag fields
+ * `out_str` - Pointer to store the lines (must be freed with hedl_free_string)
 
- - Testing error handling logic
- - Clearing stale errors before a sequence of operations
- - Resetting error state in long-running thread pools
+ # Returns
+ HEDL_OK on success, HEDL_ERR_NOT_FOUND if no type has `time_field` or no
+ type with it has a tag field, HEDL_ERR_INVALID_ARGUMENT for an empty
+ measurement, a timestamp that cannot be read or a line break in a tag,
+ or another error code on failure.
 
- # Thread Safety
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_to_influx_line_protocol(const struct HedlDocument *doc,
+                                 const char *measurement,
+                                 const char *time_field,
+                                 const char *const *tag_fields,
+                                 int tag_count,
+                                 char **out_str);
 
- Like `hedl_get_last_error()`, this function operates on thread-local storage
- and only affects the error state of the calling thread. Other threads' error
- states remain unchanged.
+/*
+ Convert a HEDL document to JSON using zero-copy callback pattern.
 
- # Example (C)
+ For outputs >1MB, this avoids memory allocation by passing data directly
+ to the callback. For smaller outputs, consider using `hedl_to_json`.
 
- ```c
- // Clear any previous errors
- hedl_clear_error_threadsafe();
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `include_metadata` - Non-zero to include HEDL metadata (__type__, __schema__)
+ * `callback` - Function to receive the output data
+ * `user_data` - User context pointer passed to callback
 
- // Now perform operations with a clean error state
- if (hedl_parse(input, -1, 0, &doc) != HEDL_OK) {
-     // This error is definitely from hedl_parse, not a previous operation
-     const char* err = hedl_get_last_error();
-     handle_error(err);
- }
+ # Returns
+ HEDL_OK on success, error code on failure.
+
+ # Safety
+ - All pointers must be valid
+ - The callback MUST NOT call back into HEDL functions
+ - The data pointer passed to callback is only valid during the callback
+
+ # Feature
+ Requires the "json" feature to be enabled.
+ */
+int hedl_to_json_callback(const struct HedlDocument *doc,
+                          int include_metadata,
+                          HedlOutputCallback callback,
+                          void *user_data);
+
+/*
+ Convert a HEDL document to JSON, passing the output to a callback in
+ chunks.
+
+ Unlike `hedl_to_json_callback`, the JSON text is never held in memory as
+ a whole: it is serialized straight into a buffer of about `chunk_size`
+ bytes that is handed to the callback each time it fills. The output is
+ identical to `hedl_to_json`.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `include_metadata` - Non-zero to include HEDL metadata (__type__, __schema__)
+ * `chunk_size` - Preferred chunk size in bytes, or 0 for 64 KiB
+ * `callback` - Function to receive each chunk; a non-zero return stops the conversion
+ * `user_data` - User context pointer passed to callback
+
+ # Returns
+ HEDL_OK on success, error code on failure. HEDL_ERR_JSON is returned when
+ the callback stops the conversion.
+
+ # Safety
+ - All pointers must be valid
+ - The callback MUST NOT call back into HEDL functions
+ - The data pointer passed to callback is only valid during the callback
+
+ # Feature
+ Requires the "json" feature to be enabled.
+ */
+int hedl_to_json_chunked(const struct HedlDocument *doc,
+                         int include_metadata,
+                         uintptr_t chunk_size,
+                         HedlChunkCallback callback,
+                         void *user_data);
+
+/*
+ Convert a HEDL document to YAML using zero-copy callback pattern.
+
+ For outputs >1MB, this avoids memory allocation by passing data directly
+ to the callback. For smaller outputs, consider using `hedl_to_yaml`.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `include_metadata` - Non-zero to include HEDL metadata
+ * `callback` - Function to receive the output data
+ * `user_data` - User context pointer passed to callback
+
+ # Returns
+ HEDL_OK on success, error code on failure.
+
+ # Safety
+ - All pointers must be valid
+ - The callback MUST NOT call back into HEDL functions
+ - The data pointer passed to callback is only valid during the callback
+
+ # Feature
+ Requires the "yaml" feature to be enabled.
+ */
+int hedl_to_yaml_callback(const struct HedlDocument *doc,
+                          int include_metadata,
+                          HedlOutputCallback callback,
+                          void *user_data);
+
+/*
+ Convert a HEDL document to XML using zero-copy callback pattern.
+
+ For outputs >1MB, this avoids memory allocation by passing data directly
+ to the callback. For smaller outputs, consider using `hedl_to_xml`.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `callback` - Function to receive the output data
+ * `user_data` - User context pointer passed to callback
+
+ # Returns
+ HEDL_OK on success, error code on failure.
+
+ # Safety
+ - All pointers must be valid
+ - The callback MUST NOT call back into HEDL functions
+ - The data pointer passed to callback is only valid during the callback
+
+ # Feature
+ Requires the "xml" feature to be enabled.
+ */
+int hedl_to_xml_callback(const struct HedlDocument *doc,
+                         HedlOutputCallback callback,
+                         void *user_data);
+
+/*
+ Convert a HEDL document to CSV using zero-copy callback pattern.
+
+ Note: Only works for documents with matrix lists.
+
+ For outputs >1MB, this avoids memory allocation by passing data directly
+ to the callback. For smaller outputs, consider using `hedl_to_csv`.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `callback` - Function to receive the output data
+ * `user_data` - User context pointer passed to callback
+
+ # Returns
+ HEDL_OK on success, error code on failure.
+
+ # Safety
+ - All pointers must be valid
+ - The callback MUST NOT call back into HEDL functions
+ - The data pointer passed to callback is only valid during the callback
+
+ # Feature
+ Requires the "csv" feature to be enabled.
+ */
+int hedl_to_csv_callback(const struct HedlDocument *doc,
+                         HedlOutputCallback callback,
+                         void *user_data);
+
+/*
+ Convert a HEDL document to Cypher queries using zero-copy callback pattern.
+
+ Generates CREATE/MERGE statements, constraints, and relationships.
+
+ For outputs >1MB, this avoids memory allocation by passing data directly
+ to the callback. For smaller outputs, consider using `hedl_to_neo4j_cypher`.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `use_merge` - Non-zero to use MERGE (idempotent), zero for CREATE
+ * `callback` - Function to receive the output data
+ * `user_data` - User context pointer passed to callback
+
+ # Returns
+ HEDL_OK on success, error code on failure.
+
+ # Safety
+ - All pointers must be valid
+ - The callback MUST NOT call back into HEDL functions
+ - The data pointer passed to callback is only valid during the callback
+
+ # Feature
+ Requires the "neo4j" feature to be enabled.
+ */
+int hedl_to_neo4j_cypher_callback(const struct HedlDocument *doc,
+                                  int use_merge,
+                                  HedlOutputCallback callback,
+                                  void *user_data);
+
+/*
+ Canonicalize a HEDL document using zero-copy callback pattern.
+
+ For outputs >1MB, this avoids memory allocation by passing data directly
+ to the callback. For smaller outputs, consider using `hedl_canonicalize`.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `callback` - Function to receive the output data
+ * `user_data` - User context pointer passed to callback
+
+ # Returns
+ HEDL_OK on success, error code on failure.
+
+ # Safety
+ - All pointers must be valid
+ - The callback MUST NOT call back into HEDL functions
+ - The data pointer passed to callback is only valid during the callback
+ */
+int hedl_canonicalize_callback(const struct HedlDocument *doc,
+                               HedlOutputCallback callback,
+                               void *user_data);
+
+/*
+ Get the number of diagnostics.
+
+ # Safety
+ Pointer must be valid. Returns -1 if diag is NULL or poisoned.
+ */
+int hedl_diagnostics_count(const struct HedlDiagnostics *diag);
+
+/*
+ Get a diagnostic message.
+
+ # Arguments
+ * `diag` - Diagnostics handle
+ * `index` - Diagnostic index
+ * `out_str` - Pointer to store message (must be freed with hedl_free_string)
+
+ # Returns
+ HEDL_OK on success, error code on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if diag is NULL or poisoned.
+ */
+int hedl_diagnostics_get(const struct HedlDiagnostics *diag, int index, char **out_str);
+
+/*
+ Get a diagnostic severity (0=Hint, 1=Warning, 2=Error).
+
+ # Safety
+ Pointer must be valid. Returns -1 if diag is NULL or poisoned.
+ */
+int hedl_diagnostics_severity(const struct HedlDiagnostics *diag, int index);
+
+/*
+ Get the 1-based source line of a diagnostic, or 0 if it has none.
+
+ # Safety
+ Pointer must be valid. Returns -1 if diag is NULL or poisoned, or if
+ index is out of range.
+ */
+int hedl_diagnostics_line(const struct HedlDiagnostics *diag, int index);
+
+/*
+ Get the 1-based source column of a diagnostic, or 0 if it has none.
+
+ Lint rules currently report whole lines, so this is 0 for every
+ diagnostic; it is provided so callers need not change when rules start
+ reporting columns.
+
+ # Safety
+ Pointer must be valid. Returns -1 if diag is NULL or poisoned, or if
+ index is out of range.
+ */
+int hedl_diagnostics_column(const struct HedlDiagnostics *diag, int index);
+
+/*
+ Get the ID of the lint rule that produced a diagnostic, such as
+ "unused-schema".
+
+ # Arguments
+ * `diag` - Diagnostics handle
+ * `index` - Diagnostic index
+ * `out_str` - Pointer to store the rule ID (must be freed with hedl_free_string)
+
+ # Returns
+ HEDL_OK on success, error code on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if diag is NULL or poisoned.
+ */
+int hedl_diagnostics_rule(const struct HedlDiagnostics *diag, int index, char **out_str);
+
+/*
+ Get the last error message for the current thread.
+
+ Returns NULL if no error occurred on this thread.
+
+ # Thread Safety
+
+ Error messages are stored in thread-local storage. This function returns
+ the error message for the CALLING THREAD ONLY. You must call this function
+ from the same thread that received the error code.
+
+ Each thread maintains its own independent error state. Errors in one thread
+ do not affect or overwrite errors in other threads. This makes the FFI safe
+ to use in multi-threaded applications without external synchronization for
+ error handling.
+
+ # Lifetime
+
+ The returned pointer is valid until the next `hedl_*` call on this thread.
+ Copy the string immediately if you need to preserve it.
+
+ # Example (C)
+
+ ```c
+ // Thread 1
+ if (hedl_parse(input1, -1, 0, &doc1) != HEDL_OK) {
+     const char* err1 = hedl_get_last_error();
+     printf("Thread 1 error: %s\n", err1);
+ }
+
+ // Thread 2 (concurrent with Thread 1)
+ if (hedl_parse(input2, -1, 0, &doc2) != HEDL_OK) {
+     const char* err2 = hedl_get_last_error();
+     printf("Thread 2 error: %s\n", err2);
+ }
+ // err1 and err2 are independent - no cross-thread pollution
+ ```
+ */
+const char *hedl_get_last_error(void);
+
+/*
+ Get the source position of the last error for the current thread.
+
+ Parse errors record the 1-based line and, when known, column of the
+ offending input. Both are set to 0 when the last error has no position,
+ or when no error occurred on this thread. Like `hedl_get_last_error()`,
+ the position is per thread and is reset by the next `hedl_*` call.
+
+ # Arguments
+ * `line` - Pointer to store the line number
+ * `column` - Pointer to store the column number
+
+ # Returns
+ HEDL_OK on success, HEDL_ERR_NULL_PTR if either pointer is NULL.
+
+ # Safety
+ All pointers must be valid.
+
+ # Example (C)
+
+ ```c
+ if (hedl_parse(input, -1, 0, &doc) != HEDL_OK) {
+     int line, column;
+     hedl_get_last_error_location(&line, &column);
+     fprintf(stderr, "%d:%d: %s", line, column, hedl_get_last_error());
+ }
+ ```
+ */
+int hedl_get_last_error_location(int *line, int *column);
+
+/*
+ Clear the last error for the current thread.
+
+ This function explicitly clears any error message stored for the calling thread.
+ It is generally not necessary to call this function, as successful operations
+ automatically clear errors. However, it can be useful in specific scenarios:
+
+ - Testing error handling logic
+ - Clearing stale errors before a sequence of operations
+ - Resetting error state in long-running thread pools
+
+ # Thread Safety
+
+ Like `hedl_get_last_error()`, this function operates on thread-local storage
+ and only affects the error state of the calling thread. Other threads' error
+ states remain unchanged.
+
+ # Example (C)
+
+ ```c
+ // Clear any previous errors
+ hedl_clear_error_threadsafe();
+
+ // Now perform operations with a clean error state
+ if (hedl_parse(input, -1, 0, &doc) != HEDL_OK) {
+     // This error is definitely from hedl_parse, not a previous operation
+     const char* err = hedl_get_last_error();
+     handle_error(err);
+ }
+ ```
+ */
+void hedl_clear_error_threadsafe(void);
+
+/*
+ Get the last error message for the current thread (thread-safe variant).
+
+ This is an explicitly named alias of `hedl_get_last_error()` to make the
+ thread-safety guarantee clear in the function name.
+
+ Returns NULL if no error occurred on this thread.
+
+ # Thread Safety
+
+ This function is fully thread-safe. Each thread maintains its own independent
+ error state in thread-local storage. Concurrent calls from multiple threads
+ will not interfere with each other.
+
+ **Guarantees:**
+ - Errors from thread A will never appear in thread B
+ - No synchronization primitives (mutexes, locks) are required
+ - Zero contention between threads accessing errors
+ - Lock-free, wait-free operation
+
+ # Lifetime
+
+ The returned pointer is valid until the next `hedl_*` call on this thread.
+ Copy the string immediately if you need to preserve it.
+
+ # Example (C with pthreads)
+
+ ```c
+ void* worker_thread(void* arg) {
+     HedlDocument* doc = NULL;
+     const char* input = (const char*)arg;
+
+     if (hedl_parse(input, -1, 0, &doc) != HEDL_OK) {
+         // Get error for THIS thread only
+         const char* err = hedl_get_last_error_threadsafe();
+         fprintf(stderr, "Worker thread error: %s\n", err);
+         return NULL;
+     }
+
+     // Process document...
+     hedl_free_document(doc);
+     return (void*)1;
+ }
+
+ int main() {
+     pthread_t threads[4];
+     const char* inputs[4] = { input1, input2, input3, input4 };
+
+     for (int i = 0; i < 4; i++) {
+         pthread_create(&threads[i], NULL, worker_thread, (void*)inputs[i]);
+     }
+
+     for (int i = 0; i < 4; i++) {
+         pthread_join(threads[i], NULL);
+     }
+ }
  ```
  */
-void hedl_clear_error_threadsafe(void);
+const char *hedl_get_last_error_threadsafe(void);
+
+/*
+ Free a string allocated by HEDL functions.
+
+ # Safety
+
+ **CRITICAL:** The pointer MUST have been returned by a `hedl_*` function
+ that allocates strings (e.g., `hedl_to_json`, `hedl_canonicalize`).
+
+ **Undefined behavior will occur if you pass:**
+ - Pointers from `malloc`/`calloc`/`realloc`
+ - Stack-allocated strings
+ - Already-freed pointers (double free)
+ - Pointers from other libraries
+
+ NULL pointers are safely ignored.
+ */
+void hedl_free_string(char *s);
+
+/*
+ Free a document handle.
+
+ # Safety
+
+ The pointer must have been returned by hedl_parse or hedl_from_*.
+
+ **Double-free protection:** If the pointer is NULL or the poison value,
+ this function returns safely without attempting to free.
+
+ **Note**: Since C passes pointers by value, we cannot modify the caller's
+ pointer. Callers should manually set their pointers to NULL after freeing
+ to avoid use-after-free bugs.
+ */
+void hedl_free_document(struct HedlDocument *doc);
+
+/*
+ Count the document handles that have been returned and not yet freed.
+
+ Every document handed out by hedl_parse, hedl_from_* or another function
+ returning a new document counts until it is passed to hedl_free_document,
+ so bindings can check in tests that no error path leaks one.
+ */
+long long hedl_live_document_count(void);
+
+/*
+ Free a diagnostics handle.
+
+ # Safety
+
+ The pointer must have been returned by hedl_lint.
+
+ **Double-free protection:** If the pointer is NULL or the poison value,
+ this function returns safely without attempting to free.
+
+ **Note**: Since C passes pointers by value, we cannot modify the caller's
+ pointer. Callers should manually set their pointers to NULL after freeing
+ to avoid use-after-free bugs.
+ */
+void hedl_free_diagnostics(struct HedlDiagnostics *diag);
+
+/*
+ Free a CSV cursor.
+
+ # Safety
+
+ The pointer must have been returned by hedl_csv_cursor_open. The cursor
+ may be freed before or after its document.
+
+ **Double-free protection:** If the pointer is NULL or the poison value,
+ this function returns safely without attempting to free.
+ */
+void hedl_free_csv_cursor(struct HedlCsvCursor *cursor);
+
+/*
+ Free byte array allocated by HEDL functions (e.g., `hedl_to_parquet`).
+
+ # Arguments
+ * `data` - Pointer to the byte array
+ * `len` - Length that was returned with the data (MUST match exactly)
+
+ # Safety
+
+ **CRITICAL:** The pointer MUST have been returned by a `hedl_*` function
+ that allocates byte arrays (e.g., `hedl_to_parquet`).
+
+ **Undefined behavior will occur if you pass:**
+ - Pointers from `malloc`/`calloc`/`realloc`
+ - Stack-allocated arrays
+ - Already-freed pointers (double free)
+ - Pointers from other libraries
+ - Incorrect length (MUST match the length returned by the allocating function)
+
+ NULL pointers are safely ignored (when len is 0).
+
+ # Feature
+ Always available, but only useful with "parquet" feature.
+ */
+void hedl_free_bytes(uint8_t *data, uintptr_t len);
+
+/*
+ Canonicalize a HEDL document.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `out_str` - Pointer to store canonical output (must be freed with hedl_free_string)
+
+ # Returns
+ HEDL_OK on success, error code on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_canonicalize(const struct HedlDocument *doc, char **out_str);
+
+/*
+ Canonicalize the subtree of a HEDL document at a key path.
+
+ The result is a standalone document holding only the item at `path`, a
+ dot-separated key path such as "config.database", under its own key. Its
+ header keeps the version and aliases of the document, and only the
+ `%STRUCT` and `%NEST` declarations of the types its lists use.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `path` - NUL-terminated dot-separated key path
+ * `out_str` - Pointer to store canonical output (must be freed with hedl_free_string)
+
+ # Returns
+ HEDL_OK on success, HEDL_ERR_NOT_FOUND if no item is at `path`, error
+ code on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_canonicalize_path(const struct HedlDocument *doc, const char *path, char **out_str);
+
+/*
+ Write a HEDL document laid out for minimal diffs in version control.
+
+ The output is canonical HEDL with every row written in full rather than
+ with ditto markers, so editing one row never changes the next, and with
+ date-like strings normalized: `YYYY-M-D` dates are zero-padded and RFC
+ 3339 timestamps are rewritten with `Z` for UTC and without trailing
+ zeros in fractional seconds. Row IDs, which references may point at, are
+ left as written. The output parses to the same document.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `out_str` - Pointer to store the output (must be freed with hedl_free_string)
+
+ # Returns
+ HEDL_OK on success, error code on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_to_git_friendly(const struct HedlDocument *doc, char **out_str);
+
+/*
+ Copy a document with its date-like strings normalized as
+ `hedl_to_git_friendly` does: `YYYY-M-D` dates are zero-padded and RFC
+ 3339 timestamps are rewritten with `Z` for UTC and without trailing zeros
+ in fractional seconds. Row IDs, which references may point at, and
+ references themselves are left as written. Numbers need no rewriting, as
+ they are held as parsed values and canonicalize in their shortest form.
+ The input document is left unchanged.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `out_doc` - Pointer to store the new document handle (must be freed with hedl_free_document)
+
+ # Returns
+ HEDL_OK on success, error code on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_normalize_scalars(const struct HedlDocument *doc, struct HedlDocument **out_doc);
+
+/*
+ Lint a HEDL document.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `out_diag` - Pointer to store diagnostics handle
+
+ # Returns
+ HEDL_OK on success, error code on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_lint(const struct HedlDocument *doc, struct HedlDiagnostics **out_diag);
+
+/*
+ Count the lint warnings of a HEDL document.
+
+ Runs the linter like `hedl_lint` but returns only the number of
+ warning-level diagnostics, without handing the messages to the caller.
+
+ # Returns
+ The warning count, or a negative error code on failure.
+
+ # Safety
+ Doc pointer must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_lint_warning_count(const struct HedlDocument *doc);
+
+/*
+ Check that every string value is in a Unicode normalization form.
+
+ `form` is "NFC", "NFD", "NFKC" or "NFKD", case-insensitively. Each string
+ value not in that form is reported as an error with rule ID
+ "unicode-normalization": key-value pairs first, by dot-separated key
+ path, then matrix cells, by type, row index counted across the type's
+ lists in document order, and field. Characters outside printable ASCII
+ are shown as `\u{...}` escapes.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `form` - NUL-terminated normalization form name
+ * `out_diag` - Pointer to store diagnostics handle (must be freed with hedl_free_diagnostics)
+
+ # Returns
+ HEDL_OK on success, HEDL_ERR_INVALID_ARGUMENT for an unknown form, error
+ code on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_check_unicode_normalization(const struct HedlDocument *doc, const char *form, struct HedlDiagnostics **out_diag);
+
+/*
+ Get the distinct values of a field, for use as `hedl_partition` keys.
+
+ Each value is written on its own line as its HEDL text, in sorted order.
+ Strings are always quoted, with `"` doubled, so every key is non-empty
+ and values of different types never share a key: null is `~`, the
+ string "~" is `"~"` and the empty string is `""`. Backslashes, tabs and
+ newlines are then escaped as `\\`, `\t` and `\n`, as in `hedl_aliases`.
+ Rows are collected from every list of the type, including nested ones;
+ an empty string means the type has no rows.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `schema_name` - NUL-terminated name of the struct type
+ * `field` - NUL-terminated name of the field
+ * `out_str` - Pointer to store the keys (must be freed with hedl_free_string)
+
+ # Returns
+ HEDL_OK on success, HEDL_ERR_NOT_FOUND if the type or field is not
+ declared, error code on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_partition_keys(const struct HedlDocument *doc,
+                        const char *schema_name,
+                        const char *field,
+                        char **out_str);
+
+/*
+ Copy a document, keeping only the rows of one struct type whose field
+ has a given value.
+
+ `key` is compared with the HEDL text of the field, as listed by
+ `hedl_partition_keys` and unescaped, so strings must be quoted. Rows of
+ the type are filtered in every list, including nested ones; kept rows
+ keep their nested children, and everything else in the document is
+ copied unchanged.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `schema_name` - NUL-terminated name of the struct type
+ * `field` - NUL-terminated name of the field
+ * `key` - NUL-terminated field value to keep
+ * `out_doc` - Pointer to store the new document handle (must be freed with hedl_free_document)
+
+ # Returns
+ HEDL_OK on success, HEDL_ERR_NOT_FOUND if the type or field is not
+ declared, error code on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_partition(const struct HedlDocument *doc,
+                   const char *schema_name,
+                   const char *field,
+                   const char *key,
+                   struct HedlDocument **out_doc);
+
+/*
+ Remove rows of one struct type that are equal to the row before them on
+ a set of fields, modifying the document in place.
+
+ Each list, including lists nested in objects and under other rows, is
+ deduplicated on its own. With a negative `field_count` every field except
+ the ID is compared, since IDs are unique per row; with zero fields all
+ rows of a run compare equal. Count hints of the affected lists are
+ updated, and references to removed rows are left as they are.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `schema_name` - NUL-terminated name of the struct type
+ * `fields` - Array of `field_count` NUL-terminated field names
+ * `field_count` - Number of fields, or -1 to compare all but the ID
+ * `out_removed` - Pointer to store the number of rows removed
+
+ # Returns
+ HEDL_OK on success, HEDL_ERR_NOT_FOUND if the type or a field is not
+ declared, error code on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_dedup(struct HedlDocument *doc,
+               const char *schema_name,
+               const char *const *fields,
+               int field_count,
+               int *out_removed);
 
 /*
- Get the last error message for the current thread (thread-safe variant).
+ Copy a document with the date values of some fields rewritten in one
+ format, reporting the values that are not dates.
+
+ Each field is named "Type.field". String values are trimmed and read as
+ RFC 3339 timestamps, `YYYY-MM-DD` and US-style `MM/DD/YYYY` dates with an
+ optional `HH:MM[:SS]` time, `YYYY/MM/DD`, `2 Jan 2006`, `Jan 2, 2006` and
+ `January 2, 2006` dates, or RFC 2822 timestamps; values without a zone
+ are taken as UTC. Recognized values are rewritten with `target_format`, a
+ strftime format in which `%#z` writes the offset as RFC 3339 does: `Z`
+ for UTC and `+hh:mm` otherwise. Other non-null values are left as they
+ are and reported as warnings with rule ID "date", naming the row index
+ counted across the type's lists in document order.
 
- This is an explicitly named alias of `hedl_get_last_error()` to make the
- thread-safety guarantee clear in the function name.
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `fields` - Array of `field_count` NUL-terminated "Type.field" names
+ * `field_count` - Number of fields
+ * `target_format` - NUL-terminated strftime format, such as "%Y-%m-%dT%H:%M:%S%#z"
+ * `out_doc` - Pointer to store the new document handle (must be freed with hedl_free_document)
+ * `out_diag` - Pointer to store diagnostics handle (must be freed with hedl_free_diagnostics)
 
- Returns NULL if no error occurred on this thread.
+ # Returns
+ HEDL_OK on success, HEDL_ERR_INVALID_ARGUMENT for an empty or invalid
+ format or a field name without a type, HEDL_ERR_NOT_FOUND if a type or
+ field is not declared, error code on failure.
 
- # Thread Safety
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_normalize_dates(const struct HedlDocument *doc,
+                         const char *const *fields,
+                         int field_count,
+                         const char *target_format,
+                         struct HedlDocument **out_doc,
+                         struct HedlDiagnostics **out_diag);
 
- This function is fully thread-safe. Each thread maintains its own independent
- error state in thread-local storage. Concurrent calls from multiple threads
- will not interfere with each other.
+/*
+ Keep only the last `n` top-level rows of each struct type, for previewing
+ the end of a large document.
 
- **Guarantees:**
- - Errors from thread A will never appear in thread B
- - No synchronization primitives (mutexes, locks) are required
- - Zero contention between threads accessing errors
- - Lock-free, wait-free operation
+ Rows are counted across the lists of a type held by objects, in document
+ order; rows nested under other rows stay with their parents, and an `n`
+ larger than the number of rows keeps them all. Count hints of the lists
+ are updated. The input document is left unchanged.
 
- # Lifetime
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `n` - Number of rows of each type to keep
+ * `out_doc` - Pointer to store the new document handle (must be freed with hedl_free_document)
 
- The returned pointer is valid until the next `hedl_*` call on this thread.
- Copy the string immediately if you need to preserve it.
+ # Returns
+ HEDL_OK on success, HEDL_ERR_INVALID_ARGUMENT if `n` is negative, error
+ code on failure.
 
- # Example (C with pthreads)
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_tail(const struct HedlDocument *doc, int n, struct HedlDocument **out_doc);
 
- ```c
- void* worker_thread(void* arg) {
-     HedlDocument* doc = NULL;
-     const char* input = (const char*)arg;
+/*
+ Keep only the first `n` top-level rows of each struct type, the
+ counterpart of `hedl_tail`.
 
-     if (hedl_parse(input, -1, 0, &doc) != HEDL_OK) {
-         // Get error for THIS thread only
-         const char* err = hedl_get_last_error_threadsafe();
-         fprintf(stderr, "Worker thread error: %s\n", err);
-         return NULL;
-     }
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `n` - Number of rows of each type to keep
+ * `out_doc` - Pointer to store the new document handle (must be freed with hedl_free_document)
 
-     // Process document...
-     hedl_free_document(doc);
-     return (void*)1;
- }
+ # Returns
+ HEDL_OK on success, HEDL_ERR_INVALID_ARGUMENT if `n` is negative, error
+ code on failure.
 
- int main() {
-     pthread_t threads[4];
-     const char* inputs[4] = { input1, input2, input3, input4 };
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_head(const struct HedlDocument *doc, int n, struct HedlDocument **out_doc);
 
-     for (int i = 0; i < 4; i++) {
-         pthread_create(&threads[i], NULL, worker_thread, (void*)inputs[i]);
-     }
+/*
+ Estimate the number of tokens text takes up in the context of a model.
 
-     for (int i = 0; i < 4; i++) {
-         pthread_join(threads[i], NULL);
-     }
- }
- ```
+ The estimate is the heuristic of `hedl stats --tokens`: about four bytes
+ per token of content and three whitespace characters per token. It is
+ meant for budgeting, not billing.
+
+ # Arguments
+ * `model` - NUL-terminated model name, such as "gpt-4"
+ * `text` - UTF-8 encoded text
+ * `text_len` - Length of text in bytes, or -1 for null-terminated
+ * `out_count` - Pointer to store the token count
+
+ # Returns
+ HEDL_OK on success, HEDL_ERR_INVALID_ARGUMENT for an unsupported model,
+ error code on failure.
+
+ # Safety
+ All pointers must be valid.
  */
-const char *hedl_get_last_error_threadsafe(void);
+int hedl_token_count(const char *model, const char *text, int text_len, int *out_count);
 
 /*
- Free a string allocated by HEDL functions.
+ Canonicalize a HEDL document to fit in a token budget, as counted by
+ `hedl_token_count`, for use in an LLM context of fixed size.
 
- # Safety
+ When the whole document fits it is written unchanged with no
+ diagnostics. Otherwise trailing top-level rows are dropped, together with
+ the rows nested under them, keeping the first rows of each struct type
+ as `hedl_head` does and as many of them as fit; key-value pairs and
+ schemas are always kept. Each truncated list is reported as a warning
+ with rule ID "truncated".
 
- **CRITICAL:** The pointer MUST have been returned by a `hedl_*` function
- that allocates strings (e.g., `hedl_to_json`, `hedl_canonicalize`).
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `model` - NUL-terminated model name, as for `hedl_token_count`
+ * `max_tokens` - Token budget
+ * `out_str` - Pointer to store the canonical HEDL (must be freed with hedl_free_string)
+ * `out_diag` - Pointer to store diagnostics handle (must be freed with hedl_free_diagnostics)
 
- **Undefined behavior will occur if you pass:**
- - Pointers from `malloc`/`calloc`/`realloc`
- - Stack-allocated strings
- - Already-freed pointers (double free)
- - Pointers from other libraries
+ # Returns
+ HEDL_OK on success, HEDL_ERR_INVALID_ARGUMENT for an unsupported model, a
+ budget that is not positive or a document that does not fit even with
+ every list emptied, error code on failure.
 
- NULL pointers are safely ignored.
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
  */
-void hedl_free_string(char *s);
+int hedl_fit_to_tokens(const struct HedlDocument *doc,
+                       const char *model,
+                       int max_tokens,
+                       char **out_str,
+                       struct HedlDiagnostics **out_diag);
 
 /*
- Free a document handle.
+ Rename a field of one struct type, modifying the document in place.
 
- # Safety
+ The field is renamed in the type's declaration and in the schema of every
+ list of the type. Renaming a field to its own name does nothing.
 
- The pointer must have been returned by hedl_parse or hedl_from_*.
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `schema_name` - NUL-terminated name of the struct type
+ * `old_name` - NUL-terminated name of the field to rename
+ * `new_name` - NUL-terminated new name of the field
 
- **Double-free protection:** If the pointer is NULL or the poison value,
- this function returns safely without attempting to free.
+ # Returns
+ HEDL_OK on success, HEDL_ERR_NOT_FOUND if the type or field does not
+ exist, HEDL_ERR_CONFLICT if the type already has a field named
+ `new_name`, error code on failure.
 
- **Note**: Since C passes pointers by value, we cannot modify the caller's
- pointer. Callers should manually set their pointers to NULL after freeing
- to avoid use-after-free bugs.
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
  */
-void hedl_free_document(struct HedlDocument *doc);
+int hedl_rename_field(struct HedlDocument *doc,
+                      const char *schema_name,
+                      const char *old_name,
+                      const char *new_name);
 
 /*
- Free a diagnostics handle.
+ Rename a struct type, modifying the document in place.
 
- # Safety
+ The type is renamed in its declaration, its lists and rows, `%NEST`
+ declarations and qualified references, so that `@Old:id` becomes
+ `@New:id`. Renaming a type to its own name does nothing.
 
- The pointer must have been returned by hedl_lint.
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `old_name` - NUL-terminated name of the struct type to rename
+ * `new_name` - NUL-terminated new name of the type
 
- **Double-free protection:** If the pointer is NULL or the poison value,
- this function returns safely without attempting to free.
+ # Returns
+ HEDL_OK on success, HEDL_ERR_NOT_FOUND if the type is neither declared
+ nor used by a list, HEDL_ERR_CONFLICT if a type named `new_name` already
+ exists, error code on failure.
 
- **Note**: Since C passes pointers by value, we cannot modify the caller's
- pointer. Callers should manually set their pointers to NULL after freeing
- to avoid use-after-free bugs.
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
  */
-void hedl_free_diagnostics(struct HedlDiagnostics *diag);
+int hedl_rename_schema(struct HedlDocument *doc, const char *old_name, const char *new_name);
 
 /*
- Free byte array allocated by HEDL functions (e.g., `hedl_to_parquet`).
+ Rewrite the references of a document to one style, modifying the
+ document in place.
+
+ With mode "inline", every key-value reference that resolves to a row is
+ replaced with a copy of that row: an object holding the row's fields
+ under its column names, plus a "__type__" key naming its type as in JSON
+ exported with metadata. Nested child rows are not copied. Matrix cells
+ cannot hold objects, so a resolvable reference in a cell fails the call.
+
+ With mode "id", objects whose "__type__" and ID field name an existing
+ row are turned back into references, and every reference that resolves
+ is written in the qualified `@Type:id` form. An unqualified reference in
+ a cell names a row of the cell's type; in a key-value pair, it resolves
+ only if its ID belongs to a single type. References that do not resolve
+ are left as written.
 
  # Arguments
- * `data` - Pointer to the byte array
- * `len` - Length that was returned with the data (MUST match exactly)
+ * `doc` - Document handle from hedl_parse
+ * `mode` - NUL-terminated reference style, "inline" or "id"
+
+ # Returns
+ HEDL_OK on success, HEDL_ERR_INVALID_ARGUMENT for an unknown mode or a
+ resolvable reference in a cell in "inline" mode, error code on failure.
+ The document is left unchanged on failure.
 
  # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_normalize_references(struct HedlDocument *doc, const char *mode);
 
- **CRITICAL:** The pointer MUST have been returned by a `hedl_*` function
- that allocates byte arrays (e.g., `hedl_to_parquet`).
+/*
+ Left-join the rows of one struct type with those of another document.
 
- **Undefined behavior will occur if you pass:**
- - Pointers from `malloc`/`calloc`/`realloc`
- - Stack-allocated arrays
- - Already-freed pointers (double free)
- - Pointers from other libraries
- - Incorrect length (MUST match the length returned by the allocating function)
+ Every row of the type in `doc` gains the `add_fields` columns of the row
+ of `other` with the same `key_field` value, or nulls when there is none.
+ Keys match only when their values have the same type and text, so the
+ string "1" never matches the integer 1; a null key matches nothing, so
+ rows of `doc` without a key get nulls and rows of `other` without one are
+ ignored. Rows of `other` are read by the schema of their own list, and
+ neither document is modified.
 
- NULL pointers are safely ignored (when len is 0).
+ # Arguments
+ * `doc` - Document handle from hedl_parse holding the rows to enrich
+ * `other` - Document handle from hedl_parse holding the rows to join
+ * `schema_name` - NUL-terminated name of the struct type
+ * `key_field` - NUL-terminated name of the field to join on
+ * `add_fields` - Array of `field_count` NUL-terminated field names of `other`
+ * `field_count` - Number of fields
+ * `out_doc` - Pointer to store the new document handle (must be freed with hedl_free_document)
 
- # Feature
- Always available, but only useful with "parquet" feature.
+ # Returns
+ HEDL_OK on success, HEDL_ERR_NOT_FOUND if either document lacks the
+ type, the key field or one of `add_fields`, HEDL_ERR_CONFLICT if one of
+ `add_fields` is already a field of the type in `doc` or a key repeats
+ among the rows of `other`, HEDL_ERR_INVALID_ARGUMENT if a list of the
+ type in `doc` has a schema other than the type's columns, error code on
+ failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc or other is
+ NULL or poisoned.
  */
-void hedl_free_bytes(uint8_t *data, uintptr_t len);
+int hedl_join(const struct HedlDocument *doc,
+              const struct HedlDocument *other,
+              const char *schema_name,
+              const char *key_field,
+              const char *const *add_fields,
+              int field_count,
+              struct HedlDocument **out_doc);
 
 /*
- Canonicalize a HEDL document.
+ Parse a HEDL document from a string.
 
  # Arguments
- * `doc` - Document handle from hedl_parse
- * `out_str` - Pointer to store canonical output (must be freed with hedl_free_string)
+ * `input` - UTF-8 encoded HEDL document
+ * `input_len` - Length of input in bytes, or -1 for null-terminated
+ * `strict` - Non-zero for strict mode (validate references)
+ * `out_doc` - Pointer to store document handle
 
  # Returns
  HEDL_OK on success, error code on failure.
 
  # Safety
- All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ All pointers must be valid.
  */
-int hedl_canonicalize(const struct HedlDocument *doc, char **out_str);
+int hedl_parse(const char *input, int input_len, int strict, struct HedlDocument **out_doc);
 
 /*
- Lint a HEDL document.
+ Parse a HEDL document from a string, giving up after a time budget.
+
+ The parser checks the deadline periodically and aborts cleanly once it
+ has passed, so callers get real interruption instead of having to abandon
+ a blocked thread.
 
  # Arguments
- * `doc` - Document handle from hedl_parse
- * `out_diag` - Pointer to store diagnostics handle
+ * `input` - UTF-8 encoded HEDL document
+ * `input_len` - Length of input in bytes, or -1 for null-terminated
+ * `strict` - Non-zero for strict mode (validate references)
+ * `timeout_ms` - Time budget in milliseconds; zero or negative fails immediately
+ * `out_doc` - Pointer to store document handle
 
  # Returns
- HEDL_OK on success, error code on failure.
+ HEDL_OK on success, HEDL_ERR_TIMEOUT if the budget ran out, or another
+ error code on failure.
 
  # Safety
- All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ All pointers must be valid.
  */
-int hedl_lint(const struct HedlDocument *doc, struct HedlDiagnostics **out_diag);
+int hedl_parse_with_deadline(const char *input,
+                             int input_len,
+                             int strict,
+                             long long timeout_ms,
+                             struct HedlDocument **out_doc);
 
 /*
- Parse a HEDL document from a string.
+ Parse a HEDL document from a string, recording the source line of every
+ matrix row for `hedl_row_source_line`.
+
+ Lines are found by scanning the input for row lines and are kept by type
+ name and row ID, so they stay correct after in-place edits that keep IDs
+ but always refer to the original text. Lines inside block strings are
+ not taken for rows.
 
  # Arguments
  * `input` - UTF-8 encoded HEDL document
@@ -806,7 +2248,10 @@ int hedl_lint(const struct HedlDocument *doc, struct HedlDiagnostics **out_diag)
  # Safety
  All pointers must be valid.
  */
-int hedl_parse(const char *input, int input_len, int strict, struct HedlDocument **out_doc);
+int hedl_parse_with_provenance(const char *input,
+                               int input_len,
+                               int strict,
+                               struct HedlDocument **out_doc);
 
 /*
  Validate a HEDL document string.
@@ -824,6 +2269,50 @@ int hedl_parse(const char *input, int input_len, int strict, struct HedlDocument
  */
 int hedl_validate(const char *input, int input_len, int strict);
 
+/*
+ Validate the lines of a HEDL document string in a range, without parsing
+ the rest of the document.
+
+ Only an excerpt of the document is parsed: the header, the lines of the
+ range, and the context they need to parse as they do in place. The context
+ is the chain of less-indented lines the range sits under, such as the list
+ a row belongs to and the rows it is nested under, the rows before a row
+ that fills cells with the ditto marker, and the rest of a block string the
+ range starts or ends in. The rest of the document is only scanned for
+ indentation and block string quotes, so the cost is proportional to the
+ range and its context rather than to the document.
+
+ A parse failure in the excerpt is reported as a single error diagnostic
+ with rule ID "parse" and the line it occurred on. That line may be in the
+ header or the context rather than the range, since an error there keeps
+ the range from being validated. Errors on lines outside the excerpt are not
+ detected; use `hedl_validate` for the whole document. References are not
+ resolved, as they may point at rows outside the excerpt.
+
+ # Arguments
+ * `input` - UTF-8 encoded HEDL document
+ * `input_len` - Length of input in bytes, or -1 for null-terminated
+ * `start_line` - First line of the range (1-based)
+ * `end_line` - Last line of the range (inclusive)
+ * `strict` - Accepted for symmetry with `hedl_validate`; the range is
+   validated without resolving references either way
+ * `out_diag` - Pointer to store diagnostics handle
+
+ # Returns
+ HEDL_OK on success, even when the range has errors;
+ HEDL_ERR_INVALID_ARGUMENT if `start_line` is below 1 or `end_line` is
+ before `start_line`; error code on other failures.
+
+ # Safety
+ All pointers must be valid.
+ */
+int hedl_validate_range(const char *input,
+                        int input_len,
+                        int start_line,
+                        int end_line,
+                        int strict,
+                        struct HedlDiagnostics **out_diag);
+
 /*
  Get the HEDL version of a parsed document.
 
@@ -840,6 +2329,48 @@ int hedl_get_version(const struct HedlDocument *doc, int *major, int *minor);
  */
 int hedl_schema_count(const struct HedlDocument *doc);
 
+/*
+ Get the names of the struct definitions in a document.
+
+ The names are written one per line, sorted by name as in canonical
+ output. A document without struct definitions yields an empty string.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `out_str` - Pointer to store the names (must be freed with hedl_free_string)
+
+ # Returns
+ HEDL_OK on success, error code on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_schema_names(const struct HedlDocument *doc, char **out_str);
+
+/*
+ Get a checksum of one struct type's definition, for detecting schema
+ drift between versions of a document.
+
+ The checksum is the hex SHA-256 digest of the type's declaration as
+ written in canonical output without its row count, such as
+ `%STRUCT: User: [id,name]`. Only the type name and the ordered field
+ names are hashed, so row values never change it. A type used only with
+ an inline schema is hashed with that schema.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `schema_name` - NUL-terminated name of the struct type
+ * `out_str` - Pointer to store the checksum (must be freed with hedl_free_string)
+
+ # Returns
+ HEDL_OK on success, HEDL_ERR_NOT_FOUND if the type is neither declared
+ nor used by a list, error code on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_schema_checksum(const struct HedlDocument *doc, const char *schema_name, char **out_str);
+
 /*
  Get the number of aliases in a document.
 
@@ -848,6 +2379,69 @@ int hedl_schema_count(const struct HedlDocument *doc);
  */
 int hedl_alias_count(const struct HedlDocument *doc);
 
+/*
+ Get the alias definitions of a document.
+
+ Each alias is written on its own line as the name, a tab, and the
+ expansion, sorted by name. Backslashes, tabs and newlines in expansions
+ are escaped as `\\`, `\t` and `\n`. A document without aliases yields
+ an empty string.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `out_str` - Pointer to store the aliases (must be freed with hedl_free_string)
+
+ # Returns
+ HEDL_OK on success, error code on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_aliases(const struct HedlDocument *doc, char **out_str);
+
+/*
+ Get the largest scalar values of a document by byte size.
+
+ Each value is written on its own line as the struct type, field name,
+ row index and size in bytes, separated by tabs, largest first; values
+ of equal size keep their order. Row indexes count every row of the type,
+ including nested rows, list by list as the Go bindings do. Key-value
+ pairs outside matrix lists have an empty type, their dot-separated key
+ path as the field and a row index of -1. The size of a string is its
+ length in UTF-8 bytes; other values are measured by their HEDL text.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `n` - Maximum number of values to return
+ * `out_str` - Pointer to store the values (must be freed with hedl_free_string)
+
+ # Returns
+ HEDL_OK on success, error code on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_largest_values(const struct HedlDocument *doc, int n, char **out_str);
+
+/*
+ Get the approximate memory held by a parsed document.
+
+ The size covers the document's strings, vectors and maps as allocated,
+ plus a fixed per-entry estimate for map nodes; allocator overhead is not
+ included.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse
+ * `out_bytes` - Pointer to store the size in bytes
+
+ # Returns
+ HEDL_OK on success, error code on failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_document_memory(const struct HedlDocument *doc, long long *out_bytes);
+
 /*
  Get the number of root items in a document.
 
@@ -856,6 +2450,34 @@ int hedl_alias_count(const struct HedlDocument *doc);
  */
 int hedl_root_item_count(const struct HedlDocument *doc);
 
+/*
+ Get the source line of a row of a struct type, for a document parsed with
+ `hedl_parse_with_provenance`.
+
+ Rows are indexed across the type's lists in document order, including
+ nested ones, as `hedl_rows_with_missing` counts them, and matched to the
+ source by ID.
+
+ # Arguments
+ * `doc` - Document handle from hedl_parse_with_provenance
+ * `schema_name` - NUL-terminated name of the struct type
+ * `index` - Row index
+ * `out_line` - Pointer to store the 1-based source line
+
+ # Returns
+ HEDL_OK on success, HEDL_ERR_NOT_FOUND if the document was parsed
+ without provenance, the type is unknown or the row has no recorded line,
+ HEDL_ERR_INVALID_ARGUMENT if the index is out of range, error code on
+ failure.
+
+ # Safety
+ All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+ */
+int hedl_row_source_line(const struct HedlDocument *doc,
+                         const char *schema_name,
+                         int index,
+                         int *out_line);
+
 #ifdef __cplusplus
 }  // extern "C"
 #endif  // __cplusplus
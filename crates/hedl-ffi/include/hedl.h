@@ -19,6 +19,7 @@
  * - Strings returned by hedl_* functions must be freed with hedl_free_string()
  * - Documents must be freed with hedl_free_document()
  * - Diagnostics must be freed with hedl_free_diagnostics()
+ * - CSV cursors must be freed with hedl_free_csv_cursor()
  * - Byte arrays must be freed with hedl_free_bytes()
  */
 
@@ -48,6 +49,13 @@ extern "C" {
 #define HEDL_ERR_CSV         -9
 #define HEDL_ERR_PARQUET     -10
 #define HEDL_ERR_LINT        -11
+#define HEDL_ERR_NEO4J       -12
+#define HEDL_ERR_TIMEOUT     -13
+#define HEDL_ERR_NOT_FOUND   -14
+#define HEDL_ERR_TOML        -15
+#define HEDL_ERR_CONFLICT    -16
+#define HEDL_ERR_INVALID_ARGUMENT -17
+#define HEDL_ERR_SQLITE      -18
 
 /* ==========================================================================
  * Opaque Types
@@ -59,6 +67,9 @@ typedef struct HedlDocument HedlDocument;
 /** Opaque handle to lint diagnostics */
 typedef struct HedlDiagnostics HedlDiagnostics;
 
+/** Opaque handle to a cursor over the rows of one struct type, read as CSV */
+typedef struct HedlCsvCursor HedlCsvCursor;
+
 /* ==========================================================================
  * Error Management
  * ========================================================================== */
@@ -70,6 +81,13 @@ typedef struct HedlDiagnostics HedlDiagnostics;
  */
 const char* hedl_get_last_error(void);
 
+/**
+ * Get the 1-based line and column of the last parse error.
+ * Both are 0 when the last error has no position.
+ * @return HEDL_OK, or HEDL_ERR_NULL_PTR if a pointer is NULL
+ */
+int hedl_get_last_error_location(int* line, int* column);
+
 /* ==========================================================================
  * Memory Management
  * ========================================================================== */
@@ -80,10 +98,16 @@ void hedl_free_string(char* s);
 /** Free a document handle. */
 void hedl_free_document(HedlDocument* doc);
 
+/** Count the document handles returned and not yet freed, for leak checks. */
+long long hedl_live_document_count(void);
+
 /** Free a diagnostics handle. */
 void hedl_free_diagnostics(HedlDiagnostics* diag);
 
-/** Free bytes allocated by hedl functions (e.g., hedl_to_parquet). */
+/** Free a CSV cursor. */
+void hedl_free_csv_cursor(HedlCsvCursor* cursor);
+
+/** Free bytes allocated by hedl functions (e.g., hedl_to_parquet, hedl_to_sqlite). */
 void hedl_free_bytes(uint8_t* data, size_t len);
 
 /* ==========================================================================
@@ -100,12 +124,35 @@ void hedl_free_bytes(uint8_t* data, size_t len);
  */
 int hedl_parse(const char* input, int input_len, int strict, HedlDocument** out_doc);
 
+/**
+ * Parse a HEDL document, aborting once a time budget runs out.
+ * @param timeout_ms Time budget in milliseconds; zero or negative fails immediately
+ * @return HEDL_OK on success, HEDL_ERR_TIMEOUT if the budget ran out
+ */
+int hedl_parse_with_deadline(const char* input, int input_len, int strict, long long timeout_ms, HedlDocument** out_doc);
+
+/**
+ * Parse a HEDL document, recording the source line of every matrix row for hedl_row_source_line.
+ * @return HEDL_OK on success, error code on failure
+ */
+int hedl_parse_with_provenance(const char* input, int input_len, int strict, HedlDocument** out_doc);
+
 /**
  * Validate a HEDL document string.
  * @return HEDL_OK if valid, error code if invalid
  */
 int hedl_validate(const char* input, int input_len, int strict);
 
+/**
+ * Validate a line range of a HEDL document, parsing only the header, the range and the lines it sits under.
+ * @param start_line First line of the range (1-based)
+ * @param end_line Last line of the range (inclusive)
+ * @param strict Ignored; references are not resolved for a range
+ * @param out_diag A parse error in the validated lines as one "parse" error, which may be on a header or context line
+ * @return HEDL_OK on success, even when the range has errors; HEDL_ERR_INVALID_ARGUMENT for an empty or invalid range
+ */
+int hedl_validate_range(const char* input, int input_len, int start_line, int end_line, int strict, HedlDiagnostics** out_diag);
+
 /* ==========================================================================
  * Document Information
  * ========================================================================== */
@@ -116,12 +163,39 @@ int hedl_get_version(const HedlDocument* doc, int* major, int* minor);
 /** Get the number of struct definitions. Returns -1 on error. */
 int hedl_schema_count(const HedlDocument* doc);
 
+/** Get the struct names, one per line, sorted by name. Free with hedl_free_string. */
+int hedl_schema_names(const HedlDocument* doc, char** out_str);
+
+/**
+ * Get the hex SHA-256 of one struct type's declaration, without its row count.
+ * @param schema_name Name of the struct type
+ * @param out_str The checksum. Free with hedl_free_string.
+ * @return HEDL_OK on success, HEDL_ERR_NOT_FOUND if the type is neither declared nor used by a list
+ */
+int hedl_schema_checksum(const HedlDocument* doc, const char* schema_name, char** out_str);
+
 /** Get the number of aliases. Returns -1 on error. */
 int hedl_alias_count(const HedlDocument* doc);
 
+/** Get the aliases as "name\tvalue" lines, sorted by name. Free with hedl_free_string. */
+int hedl_aliases(const HedlDocument* doc, char** out_str);
+
+/** Get the n largest scalar values as "type\tfield\trow\tbytes" lines, largest first. Free with hedl_free_string. */
+int hedl_largest_values(const HedlDocument* doc, int n, char** out_str);
+
+/** Get the approximate memory held by the document, in bytes. */
+int hedl_document_memory(const HedlDocument* doc, long long* out_bytes);
+
 /** Get the number of root items. Returns -1 on error. */
 int hedl_root_item_count(const HedlDocument* doc);
 
+/**
+ * Get the 1-based source line of row index of a struct type, counted across the type's lists, for a document parsed with hedl_parse_with_provenance.
+ * @param out_line Pointer to store the line
+ * @return HEDL_OK, HEDL_ERR_NOT_FOUND without provenance, for an unknown type or a row with no recorded line, or HEDL_ERR_INVALID_ARGUMENT for an index out of range
+ */
+int hedl_row_source_line(const HedlDocument* doc, const char* schema_name, int index, int* out_line);
+
 /* ==========================================================================
  * Callback Type for Zero-Copy Output
  * ========================================================================== */
@@ -142,6 +216,15 @@ int hedl_root_item_count(const HedlDocument* doc);
  */
 typedef void (*hedl_output_callback)(const char* data, size_t len, void* user_data);
 
+/**
+ * Chunk callback function type for streaming output.
+ *
+ * Called once per chunk, in order, with the same data lifetime rules as
+ * hedl_output_callback. Return 0 to continue or any other value to stop
+ * the conversion.
+ */
+typedef int (*hedl_chunk_callback)(const char* data, size_t len, void* user_data);
+
 /* ==========================================================================
  * Canonicalization
  * ========================================================================== */
@@ -160,6 +243,25 @@ int hedl_canonicalize(const HedlDocument* doc, char** out_str);
  */
 int hedl_canonicalize_callback(const HedlDocument* doc, hedl_output_callback callback, void* user_data);
 
+/**
+ * Canonicalize the item at a dot-separated key path as a standalone document, keeping only the declarations of the types its lists use.
+ * @param out_str Pointer to store output (must free with hedl_free_string)
+ * @return HEDL_OK on success, HEDL_ERR_NOT_FOUND if no item is at the path
+ */
+int hedl_canonicalize_path(const HedlDocument* doc, const char* path, char** out_str);
+
+/**
+ * Write canonical HEDL laid out for minimal diffs: rows in full without ditto markers, and date-like strings normalized.
+ * @param out_str Pointer to store output (must free with hedl_free_string)
+ */
+int hedl_to_git_friendly(const HedlDocument* doc, char** out_str);
+
+/**
+ * Copy a document with date-like strings normalized as hedl_to_git_friendly does, leaving row IDs and references as written.
+ * @param out_doc Pointer to store the new document (must free with hedl_free_document)
+ */
+int hedl_normalize_scalars(const HedlDocument* doc, HedlDocument** out_doc);
+
 /* ==========================================================================
  * JSON Conversion
  * ========================================================================== */
@@ -171,6 +273,50 @@ int hedl_canonicalize_callback(const HedlDocument* doc, hedl_output_callback cal
  */
 int hedl_to_json(const HedlDocument* doc, int include_metadata, char** out_str);
 
+/**
+ * Convert the rows of one struct type to JSON, grouped by a field's value.
+ * @param schema_name Struct type whose rows are grouped
+ * @param group_by Field to group by; each distinct value becomes a key
+ * @param out_str Pointer to store output (must free with hedl_free_string)
+ * @return HEDL_OK on success, HEDL_ERR_NOT_FOUND for an unknown type or field
+ */
+int hedl_to_grouped_json(const HedlDocument* doc, const char* schema_name, const char* group_by, char** out_str);
+
+/**
+ * Convert rows [offset, offset + limit) of one struct type to a compact JSON array.
+ * Out-of-range values are clamped, so a page past the end is "[]".
+ * @param out_str Pointer to store output (must free with hedl_free_string)
+ * @return HEDL_OK on success, HEDL_ERR_NOT_FOUND for an unknown type
+ */
+int hedl_to_json_page(const HedlDocument* doc, const char* schema_name, int offset, int limit, char** out_str);
+
+/**
+ * Convert the row of a struct type whose key field has the value key_value to a compact JSON object.
+ * Values are compared by their plain text; rows with a null key never match.
+ * @param first_wins Non-zero to return the first of several matching rows
+ * @param out_str Pointer to store output (must free with hedl_free_string)
+ * @return HEDL_OK on success, HEDL_ERR_NOT_FOUND for an unknown type or field or no match,
+ *         HEDL_ERR_CONFLICT for several matches
+ */
+int hedl_get_by_key(const HedlDocument* doc, const char* schema_name, const char* key_field, const char* key_value, int first_wins, char** out_str);
+
+/*
+ * Convert the rows of a struct type to a compact JSON object keyed by the plain text of key_field.
+ * Rows with a null key are skipped.
+ * @param allow_duplicates Non-zero to keep the last of several rows with a key
+ * @param out_str Pointer to store output (must free with hedl_free_string)
+ * @return HEDL_OK on success, HEDL_ERR_NOT_FOUND for an unknown type or field,
+ *         HEDL_ERR_CONFLICT for a repeated key
+ */
+int hedl_index_by(const HedlDocument* doc, const char* schema_name, const char* key_field, int allow_duplicates, char** out_str);
+
+/**
+ * Generate an example JSON object per struct type, from the first non-null
+ * value of each field or a placeholder.
+ * @param out_str Pointer to store output (must free with hedl_free_string)
+ */
+int hedl_example_json(const HedlDocument* doc, char** out_str);
+
 /**
  * Convert a HEDL document to JSON using zero-copy callback.
  * Recommended for large outputs (>1MB) to avoid memory allocation.
@@ -180,6 +326,15 @@ int hedl_to_json(const HedlDocument* doc, int include_metadata, char** out_str);
  */
 int hedl_to_json_callback(const HedlDocument* doc, int include_metadata, hedl_output_callback callback, void* user_data);
 
+/**
+ * Convert a HEDL document to JSON, streaming it to a callback in chunks
+ * without holding the whole output in memory.
+ * @param chunk_size Preferred chunk size in bytes, or 0 for 64 KiB
+ * @param callback Function to receive each chunk; non-zero return stops
+ * @param user_data User context pointer passed to callback
+ */
+int hedl_to_json_chunked(const HedlDocument* doc, int include_metadata, size_t chunk_size, hedl_chunk_callback callback, void* user_data);
+
 /**
  * Parse JSON into a HEDL document.
  * @param json_len Length in bytes, or -1 for null-terminated
@@ -197,6 +352,12 @@ int hedl_from_json(const char* json, int json_len, HedlDocument** out_doc);
  */
 int hedl_to_yaml(const HedlDocument* doc, int include_metadata, char** out_str);
 
+/**
+ * Convert a HEDL document to a multi-document YAML stream, one document per root item, separated by "---" lines.
+ * @param out_str Pointer to store output (must free with hedl_free_string)
+ */
+int hedl_to_yaml_multi(const HedlDocument* doc, char** out_str);
+
 /**
  * Convert a HEDL document to YAML using zero-copy callback.
  * Recommended for large outputs (>1MB) to avoid memory allocation.
@@ -247,6 +408,29 @@ int hedl_from_xml(const char* xml, int xml_len, HedlDocument** out_doc);
  */
 int hedl_to_csv(const HedlDocument* doc, char** out_str);
 
+/**
+ * Convert a HEDL document to a zip archive holding one deflated "<Type>.csv" file per struct type.
+ * @param out_data Pointer to store output data (must free with hedl_free_bytes)
+ * @param out_len Pointer to store output length
+ * @return HEDL_OK on success, HEDL_ERR_CSV if the archive exceeds the limits of the zip format
+ */
+int hedl_to_csv_zip(const HedlDocument* doc, uint8_t** out_data, size_t* out_len);
+
+/**
+ * Open a cursor over the rows of a type for CSV export. The document must
+ * stay alive and unmodified until the cursor is freed.
+ * @param out_cursor Pointer to store the cursor (must free with hedl_free_csv_cursor)
+ * @return HEDL_OK on success, HEDL_ERR_NOT_FOUND for an unknown type
+ */
+int hedl_csv_cursor_open(const HedlDocument* doc, const char* schema_name, HedlCsvCursor** out_cursor);
+
+/**
+ * Read up to max_rows rows of a cursor as CSV, preceded by the header row on the first call.
+ * @param out_str Pointer to store the CSV text (must free with hedl_free_string)
+ * @return The number of rows read, 0 at the end, or a negative error code
+ */
+int hedl_csv_cursor_next(HedlCsvCursor* cursor, int max_rows, char** out_str);
+
 /**
  * Convert a HEDL document to CSV using zero-copy callback.
  * Note: Only works for documents with matrix lists.
@@ -256,6 +440,13 @@ int hedl_to_csv(const HedlDocument* doc, char** out_str);
  */
 int hedl_to_csv_callback(const HedlDocument* doc, hedl_output_callback callback, void* user_data);
 
+/**
+ * Parse CSV with a header row into a HEDL document with a single Row struct.
+ * The first column holds the row IDs; ragged records fail with HEDL_ERR_CSV.
+ * @param csv_len Length in bytes, or -1 for null-terminated
+ */
+int hedl_from_csv(const char* csv, int csv_len, HedlDocument** out_doc);
+
 /* ==========================================================================
  * Parquet Conversion
  * ========================================================================== */
@@ -275,6 +466,36 @@ int hedl_to_parquet(const HedlDocument* doc, uint8_t** out_data, size_t* out_len
  */
 int hedl_from_parquet(const uint8_t* data, size_t len, HedlDocument** out_doc);
 
+/* ==========================================================================
+ * SQLite Conversion
+ * ========================================================================== */
+
+/**
+ * Convert a HEDL document to the bytes of a SQLite 3 database file.
+ * Builds an in-memory database with one table per struct type and serializes it.
+ * @param out_data Pointer to store output data (must free with hedl_free_bytes)
+ * @param out_len Pointer to store output length
+ */
+int hedl_to_sqlite(const HedlDocument* doc, uint8_t** out_data, size_t* out_len);
+
+/* ==========================================================================
+ * TOML Conversion
+ * ========================================================================== */
+
+/**
+ * Convert a HEDL document to TOML. Matrix rows become arrays of tables named after their key;
+ * null values are omitted.
+ * @param out_str Pointer to store output (must free with hedl_free_string)
+ */
+int hedl_to_toml(const HedlDocument* doc, char** out_str);
+
+/**
+ * Parse TOML into a HEDL document. Arrays of tables become typed lists.
+ * @param toml_len Length in bytes, or -1 for null-terminated
+ * @return HEDL_OK on success, HEDL_ERR_TOML for invalid or unconvertible TOML
+ */
+int hedl_from_toml(const char* toml, int toml_len, HedlDocument** out_doc);
+
 /* ==========================================================================
  * Neo4j/Cypher Conversion
  * ========================================================================== */
@@ -296,6 +517,45 @@ int hedl_to_neo4j_cypher(const HedlDocument* doc, int use_merge, char** out_str)
  */
 int hedl_to_neo4j_cypher_callback(const HedlDocument* doc, int use_merge, hedl_output_callback callback, void* user_data);
 
+/* ==========================================================================
+ * Schema Descriptors
+ * ========================================================================== */
+
+/**
+ * Describe every struct type with the types of its fields, inferred from their non-null values as in hedl_to_mermaid_er.
+ * @param out_str Pointer to store a line per type followed by "\tfield\ttype\toptional" lines, with optional 1 or 0 (must free with hedl_free_string)
+ */
+int hedl_schema_descriptors(const HedlDocument* doc, char** out_str);
+
+/* ==========================================================================
+ * Mermaid Conversion
+ * ========================================================================== */
+
+/**
+ * Convert the schemas of a HEDL document to a Mermaid erDiagram.
+ * Struct types become entities with inferred field types; %NEST rules and qualified references become relationships.
+ * @param out_str Pointer to store the diagram (must free with hedl_free_string)
+ */
+int hedl_to_mermaid_er(const HedlDocument* doc, char** out_str);
+
+/**
+ * Convert a HEDL document to a Java .properties file, flattening nested keys and matrix rows with dots and escaping as java.util.Properties.store does.
+ * @param out_str Pointer to store the properties (must free with hedl_free_string)
+ */
+int hedl_to_properties(const HedlDocument* doc, char** out_str);
+
+/**
+ * Convert a HEDL document to InfluxDB line protocol, one line per row of every type that has time_field.
+ * Tag fields become tags, other non-null fields become fields typed by their column, and the timestamp is read as nanoseconds or an RFC 3339 time.
+ * @param measurement Measurement name written at the start of every line
+ * @param time_field Field holding the timestamp
+ * @param tag_fields Array of tag_count fields written as tags
+ * @param tag_count Number of tag fields
+ * @param out_str Pointer to store the lines (must free with hedl_free_string)
+ * @return HEDL_OK, HEDL_ERR_NOT_FOUND for an unknown time or tag field, or HEDL_ERR_INVALID_ARGUMENT for an empty measurement, an unreadable timestamp or a line break in a tag
+ */
+int hedl_to_influx_line_protocol(const HedlDocument* doc, const char* measurement, const char* time_field, const char* const* tag_fields, int tag_count, char** out_str);
+
 /* ==========================================================================
  * Linting
  * ========================================================================== */
@@ -306,6 +566,108 @@ int hedl_to_neo4j_cypher_callback(const HedlDocument* doc, int use_merge, hedl_o
  */
 int hedl_lint(const HedlDocument* doc, HedlDiagnostics** out_diag);
 
+/** Count lint warnings without collecting messages. Returns a negative error code on failure. */
+int hedl_lint_warning_count(const HedlDocument* doc);
+
+/**
+ * Check that every string value is in a Unicode normalization form ("NFC", "NFD", "NFKC" or "NFKD").
+ * @param out_diag Pointer to store diagnostics handle (must free with hedl_free_diagnostics)
+ * @return HEDL_OK on success, HEDL_ERR_INVALID_ARGUMENT for an unknown form
+ */
+int hedl_check_unicode_normalization(const HedlDocument* doc, const char* form, HedlDiagnostics** out_diag);
+
+/**
+ * Get the distinct values of a field as HEDL text, strings always quoted.
+ * @param out_str Pointer to store one escaped value per line, sorted (must free with hedl_free_string)
+ * @return HEDL_OK on success, HEDL_ERR_NOT_FOUND for an unknown type or field
+ */
+int hedl_partition_keys(const HedlDocument* doc, const char* schema_name, const char* field, char** out_str);
+
+/**
+ * Copy a document keeping only the rows of a type whose field has the value key.
+ * @param key A value as listed by hedl_partition_keys, unescaped
+ * @param out_doc Pointer to store the new document (must free with hedl_free_document)
+ * @return HEDL_OK on success, HEDL_ERR_NOT_FOUND for an unknown type or field
+ */
+int hedl_partition(const HedlDocument* doc, const char* schema_name, const char* field, const char* key, HedlDocument** out_doc);
+
+/**
+ * Remove rows of a type equal to the row before them on the given fields, in place.
+ * @param fields Array of field_count field names
+ * @param field_count Number of fields, or -1 to compare every field except the ID
+ * @param out_removed Pointer to store the number of rows removed
+ * @return HEDL_OK on success, HEDL_ERR_NOT_FOUND for an unknown type or field
+ */
+int hedl_dedup(HedlDocument* doc, const char* schema_name, const char* const* fields, int field_count, int* out_removed);
+
+/**
+ * Copy a document with the date values of "Type.field" fields rewritten in one format.
+ * @param fields Array of field_count "Type.field" names
+ * @param target_format strftime format; %#z writes Z for UTC and +hh:mm otherwise
+ * @param out_diag Pointer to store a warning for each value that is not a date
+ * @return HEDL_OK on success, HEDL_ERR_INVALID_ARGUMENT for an invalid format or field name, HEDL_ERR_NOT_FOUND for an unknown type or field
+ */
+int hedl_normalize_dates(const HedlDocument* doc, const char* const* fields, int field_count, const char* target_format, HedlDocument** out_doc, HedlDiagnostics** out_diag);
+
+/**
+ * Copy a document keeping only the last n top-level rows of each struct type.
+ * @param n Number of rows of each type to keep; rows nested under other rows stay with their parents
+ * @param out_doc Pointer to store the new document handle (must free with hedl_free_document)
+ * @return HEDL_OK on success, HEDL_ERR_INVALID_ARGUMENT if n is negative
+ */
+int hedl_tail(const HedlDocument* doc, int n, HedlDocument** out_doc);
+
+/**
+ * Copy a document keeping only the first n top-level rows of each struct type, the counterpart of hedl_tail.
+ * @param out_doc Pointer to store the new document handle (must free with hedl_free_document)
+ * @return HEDL_OK on success, HEDL_ERR_INVALID_ARGUMENT if n is negative
+ */
+int hedl_head(const HedlDocument* doc, int n, HedlDocument** out_doc);
+
+/**
+ * Estimate the tokens text takes up for a model, as hedl stats --tokens does.
+ * @param model Model name, one of cl100k_base, gpt-4, gpt-4-turbo or gpt-3.5-turbo
+ * @param text_len Length of text in bytes, or -1 for null-terminated
+ * @param out_count Pointer to store the token count
+ * @return HEDL_OK, or HEDL_ERR_INVALID_ARGUMENT for an unsupported model
+ */
+int hedl_token_count(const char* model, const char* text, int text_len, int* out_count);
+
+/**
+ * Canonicalize a document to fit in a token budget, dropping trailing top-level rows and reporting each truncated list as a warning.
+ * @param out_str Pointer to store the canonical HEDL (must free with hedl_free_string)
+ * @param out_diag Pointer to store the truncation warnings (must free with hedl_free_diagnostics)
+ * @return HEDL_OK, or HEDL_ERR_INVALID_ARGUMENT for an unsupported model, a budget that is not positive or a document that does not fit without rows
+ */
+int hedl_fit_to_tokens(const HedlDocument* doc, const char* model, int max_tokens, char** out_str, HedlDiagnostics** out_diag);
+
+/**
+ * Rename a field of a struct type in its declaration and every list of the type. Modifies doc in place.
+ * @return HEDL_OK on success, HEDL_ERR_NOT_FOUND for an unknown type or field, HEDL_ERR_CONFLICT if the type already has a field named new_name
+ */
+int hedl_rename_field(HedlDocument* doc, const char* schema_name, const char* old_name, const char* new_name);
+
+/**
+ * Rename a struct type in its declaration, lists, rows, %NEST declarations and qualified references. Modifies doc in place.
+ * @return HEDL_OK on success, HEDL_ERR_NOT_FOUND for an unknown type, HEDL_ERR_CONFLICT if a type named new_name already exists
+ */
+int hedl_rename_schema(HedlDocument* doc, const char* old_name, const char* new_name);
+
+/**
+ * Rewrite references in place: "inline" replaces key-value references with copies of the rows they name, "id" turns such copies back into references and qualifies every reference as @Type:id.
+ * @param mode "inline" or "id"
+ * @return HEDL_OK on success, HEDL_ERR_INVALID_ARGUMENT for an unknown mode or, in "inline" mode, a resolvable reference in a matrix cell
+ */
+int hedl_normalize_references(HedlDocument* doc, const char* mode);
+
+/**
+ * Copy a document with every row of a type extended by fields of the row of another document with the same key value, or nulls when there is none (a left join).
+ * @param add_fields Array of field_count field names of other
+ * @param out_doc Pointer to store the joined document (must free with hedl_free_document)
+ * @return HEDL_OK on success, HEDL_ERR_NOT_FOUND for an unknown type or field, HEDL_ERR_CONFLICT for an added field the type already has or a key repeated in other, HEDL_ERR_INVALID_ARGUMENT if a list of the type in doc has other columns
+ */
+int hedl_join(const HedlDocument* doc, const HedlDocument* other, const char* schema_name, const char* key_field, const char* const* add_fields, int field_count, HedlDocument** out_doc);
+
 /** Get the number of diagnostics. Returns -1 on error. */
 int hedl_diagnostics_count(const HedlDiagnostics* diag);
 
@@ -321,6 +683,114 @@ int hedl_diagnostics_get(const HedlDiagnostics* diag, int index, char** out_str)
  */
 int hedl_diagnostics_severity(const HedlDiagnostics* diag, int index);
 
+/**
+ * Get a diagnostic's 1-based source line.
+ * @return Line number, 0 if the diagnostic has none, or -1 on error
+ */
+int hedl_diagnostics_line(const HedlDiagnostics* diag, int index);
+
+/**
+ * Get a diagnostic's 1-based source column.
+ * @return Column number, 0 if the diagnostic has none, or -1 on error
+ */
+int hedl_diagnostics_column(const HedlDiagnostics* diag, int index);
+
+/**
+ * Get the ID of the lint rule that produced a diagnostic.
+ * @param out_str Pointer to store the rule ID (must free with hedl_free_string)
+ */
+int hedl_diagnostics_rule(const HedlDiagnostics* diag, int index, char** out_str);
+
+/* ==========================================================================
+ * Data-Quality Checks
+ * ========================================================================== */
+
+/**
+ * Report qualified references (@Type:id) whose type is not defined in the document.
+ * @param out_diag Pointer to store diagnostics handle (must free with hedl_free_diagnostics)
+ */
+int hedl_check_schema_references(const HedlDocument* doc, HedlDiagnostics** out_diag);
+
+/**
+ * Get the indices of the rows of a type that hold a null or empty string in any field.
+ * @param out_str Pointer to store one row index per line (must free with hedl_free_string)
+ * @return HEDL_OK on success, HEDL_ERR_NOT_FOUND for an unknown type
+ */
+int hedl_rows_with_missing(const HedlDocument* doc, const char* schema_name, char** out_str);
+
+/**
+ * Get the fields of a type that hold a null or empty string in every row, in schema order.
+ * @param out_str Pointer to store one field name per line, or an empty string if the type has none or no rows (must free with hedl_free_string)
+ * @return HEDL_OK on success, HEDL_ERR_NOT_FOUND for an unknown type
+ */
+int hedl_unused_fields(const HedlDocument* doc, const char* schema_name, char** out_str);
+
+/**
+ * Get the fields of a type whose non-null values span more than one type, with ints and floats together counting as float.
+ * @param out_str Pointer to store "field\ttype\ttype..." lines sorted by field, or an empty string if there are none (must free with hedl_free_string)
+ * @return HEDL_OK on success, HEDL_ERR_NOT_FOUND for an unknown type
+ */
+int hedl_mixed_type_fields(const HedlDocument* doc, const char* schema_name, char** out_str);
+
+/**
+ * Profile the fields of a type in a single pass, comparing values by their HEDL text.
+ * @param out_rows Pointer to store the number of rows of the type
+ * @param out_str Pointer to store "field\tnon_null\tdistinct" lines in schema order (must free with hedl_free_string)
+ * @return HEDL_OK on success, HEDL_ERR_NOT_FOUND for an unknown type
+ */
+int hedl_field_stats(const HedlDocument* doc, const char* schema_name, int* out_rows, char** out_str);
+
+/**
+ * Find the first cycle of references between rows, without modifying the document.
+ * @param out_str Pointer to store the rows of the cycle as "Type:id", one per line, or an empty string if there is none (must free with hedl_free_string)
+ */
+int hedl_find_reference_cycle(const HedlDocument* doc, char** out_str);
+
+/**
+ * Report each value of a field held by more than one row of a type, naming the indices of those rows. Null values are skipped.
+ * @param out_diag Pointer to store diagnostics handle (must free with hedl_free_diagnostics)
+ * @return HEDL_OK on success, HEDL_ERR_NOT_FOUND for an unknown type or field
+ */
+int hedl_check_unique(const HedlDocument* doc, const char* schema_name, const char* field, HedlDiagnostics** out_diag);
+
+/**
+ * Report values of "Type.field" fields outside their inclusive [min, max] range, or that are not numbers. Null values are skipped.
+ * @param fields Array of field_count "Type.field" names
+ * @param mins Array of field_count lower bounds
+ * @param maxs Array of field_count upper bounds
+ * @param out_diag Pointer to store diagnostics handle (must free with hedl_free_diagnostics)
+ * @return HEDL_OK on success, HEDL_ERR_INVALID_ARGUMENT for a field name without a type or a min above its max, HEDL_ERR_NOT_FOUND for an unknown type or field
+ */
+int hedl_check_ranges(const HedlDocument* doc, const char* const* fields, const double* mins, const double* maxs, int field_count, HedlDiagnostics** out_diag);
+
+/**
+ * Report rows whose "Type.field" value in from_fields has no match among the values of the "Type.field" key at the same position in to_fields. Null values are skipped.
+ * @param from_fields Array of key_count "Type.field" names holding the keys
+ * @param to_fields Array of key_count "Type.field" names of the referenced keys
+ * @param out_diag Pointer to store diagnostics handle (must free with hedl_free_diagnostics)
+ * @return HEDL_OK on success, HEDL_ERR_INVALID_ARGUMENT for a field name without a type, HEDL_ERR_NOT_FOUND for an unknown type or field
+ */
+int hedl_check_foreign_keys(const HedlDocument* doc, const char* const* from_fields, const char* const* to_fields, int key_count, HedlDiagnostics** out_diag);
+
+/**
+ * Report string values, in key-value pairs and matrix cells, with leading or trailing whitespace.
+ * @param out_diag Pointer to store diagnostics handle (must free with hedl_free_diagnostics)
+ */
+int hedl_check_whitespace(const HedlDocument* doc, HedlDiagnostics** out_diag);
+
+/**
+ * Copy a document with leading and trailing whitespace removed from every string value.
+ * @param out_doc Pointer to store the trimmed document (must free with hedl_free_document)
+ */
+int hedl_trim_strings(const HedlDocument* doc, HedlDocument** out_doc);
+
+/**
+ * Copy a document with safe fixes applied: line endings normalized, strings trimmed, strings coerced to their column's number or boolean type, and unused schemas dropped.
+ * @param out_doc Pointer to store the fixed document (must free with hedl_free_document)
+ * @param out_changes Pointer to store the changelog, one change per line (must free with hedl_free_string)
+ */
+int hedl_auto_fix(const HedlDocument* doc, HedlDocument** out_doc, char** out_changes);
+
 #ifdef __cplusplus
 }
 #endif
@@ -154,6 +154,25 @@ pub unsafe fn sanitize_c_string(ptr: *const c_char, max_len: usize) -> String {
     }
 }
 
+/// Sanitize a length-delimited C string for logging.
+///
+/// Reads at most `max_len` bytes, so the input does not need a NUL
+/// terminator. A negative `len` means the string is NUL-terminated, as in
+/// the `input_len` argument of the parse functions.
+pub unsafe fn sanitize_c_string_len(ptr: *const c_char, len: c_int, max_len: usize) -> String {
+    if ptr.is_null() || len < 0 {
+        return sanitize_c_string(ptr, max_len);
+    }
+    let len = len as usize;
+    let preview = std::slice::from_raw_parts(ptr as *const u8, len.min(max_len));
+    let preview = String::from_utf8_lossy(preview);
+    if len <= max_len {
+        format!("{:?}", preview)
+    } else {
+        format!("{:?}... ({} bytes total)", preview, len)
+    }
+}
+
 /// Sanitize byte data for logging.
 ///
 /// Shows a hex preview of the first few bytes.
@@ -490,6 +509,25 @@ mod tests {
         );
     }
 
+    #[test]
+    fn test_sanitize_c_string_len() {
+        // Not NUL-terminated: only the given length may be read.
+        let input = b"hello world";
+        let ptr = input.as_ptr() as *const c_char;
+        unsafe {
+            assert_eq!(sanitize_c_string_len(ptr, 5, 10), "\"hello\"");
+            assert_eq!(
+                sanitize_c_string_len(ptr, 11, 5),
+                "\"hello\"... (11 bytes total)"
+            );
+            assert_eq!(
+                sanitize_c_string_len(b"hi\0".as_ptr() as *const c_char, -1, 10),
+                "\"hi\""
+            );
+            assert_eq!(sanitize_c_string_len(std::ptr::null(), 3, 10), "NULL");
+        }
+    }
+
     #[test]
     fn test_sanitize_bytes() {
         assert_eq!(sanitize_bytes(&[], 4), "[]");
@@ -0,0 +1,1532 @@
+// Dweve HEDL - Hierarchical Entity Data Language
+//
+// Copyright (c) 2025 Dweve IP B.V. and individual contributors.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License in the LICENSE file at the
+// root of this repository or at: http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//! Data-quality checks for FFI.
+
+use crate::audit::{audit_call_failure, audit_call_start, audit_call_success, sanitize_pointer};
+use crate::conversions::csv_cursor::value_text;
+use crate::error::{clear_error, set_error};
+use crate::memory::is_valid_document_ptr;
+use crate::operations::{c_str_arg, collect_types, partition_key};
+use crate::types::{
+    HedlDiagnostics, HedlDocument, HEDL_ERR_ALLOC, HEDL_ERR_INVALID_ARGUMENT, HEDL_ERR_NOT_FOUND,
+    HEDL_ERR_NULL_PTR, HEDL_OK,
+};
+use crate::utils::allocate_output_string;
+use hedl_core::{Document, Item, MatrixList, Node, Value};
+use hedl_lint::{Diagnostic, DiagnosticKind};
+use std::collections::{BTreeMap, BTreeSet, HashMap, HashSet};
+use std::os::raw::{c_char, c_int};
+use std::ptr;
+use std::time::Instant;
+
+// =============================================================================
+// Schema References
+// =============================================================================
+
+/// Check that every qualified reference names a defined type.
+///
+/// Strict parsing rejects a reference `@Type:id` whose type is neither
+/// declared with `%STRUCT` nor used by a matrix list, but in non-strict mode
+/// it parses silently. Each one is reported as an error with rule ID
+/// "undefined-schema": key-value pairs first, by dot-separated key path,
+/// then matrix cells, by type, row index counted across the type's lists in
+/// document order, and field.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `out_diag` - Pointer to store diagnostics handle (must be freed with hedl_free_diagnostics)
+///
+/// # Returns
+/// HEDL_OK on success, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_check_schema_references(
+    doc: *const HedlDocument,
+    out_diag: *mut *mut HedlDiagnostics,
+) -> c_int {
+    const FUNC: &str = "hedl_check_schema_references";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("out_diag", &sanitize_pointer(out_diag)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_diag.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+    *out_diag = ptr::null_mut();
+
+    let doc_ref = &(*doc).inner;
+    let mut defined: BTreeSet<&str> = doc_ref.structs.keys().map(String::as_str).collect();
+    visit_lists(&doc_ref.root, &mut |list| {
+        defined.insert(&list.type_name);
+    });
+    let undefined = |value: &Value| match value {
+        Value::Reference(r) => r
+            .type_name
+            .as_deref()
+            .filter(|type_name| !defined.contains(type_name))
+            .map(str::to_string),
+        _ => None,
+    };
+
+    let mut diagnostics = Vec::new();
+    let mut report = |location: String, type_name: String, value: &Value| {
+        diagnostics.push(Diagnostic::error(
+            DiagnosticKind::Custom("undefined-schema".to_string()),
+            format!(
+                "{} references undefined schema \"{}\" ({})",
+                location, type_name, value
+            ),
+            "undefined-schema",
+        ));
+    };
+    visit_key_values("", &doc_ref.root, &mut |path, value| {
+        if let Some(type_name) = undefined(value) {
+            report(path.to_string(), type_name, value);
+        }
+    });
+    visit_indexed_rows(doc_ref, &mut |schema, index, row| {
+        for (field, value) in schema.iter().zip(&row.fields) {
+            if let Some(type_name) = undefined(value) {
+                let location = format!("{} row {} field \"{}\"", row.type_name, index, field);
+                report(location, type_name, value);
+            }
+        }
+    });
+
+    *out_diag = Box::into_raw(Box::new(HedlDiagnostics { inner: diagnostics }));
+    audit_call_success(FUNC, start.elapsed());
+    HEDL_OK
+}
+
+// =============================================================================
+// Missing Values
+// =============================================================================
+
+/// Get the indices of the rows of one struct type that hold a null or empty
+/// string in any field.
+///
+/// HEDL schemas have no optional fields, so every field counts as required.
+/// Indices count the rows of the type across its lists in document order,
+/// including nested lists, and are written one per line; an empty string
+/// means no row is incomplete.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `schema_name` - NUL-terminated name of the struct type
+/// * `out_str` - Pointer to store the indices (must be freed with hedl_free_string)
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_NOT_FOUND if the type is neither declared
+/// nor used by a list, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_rows_with_missing(
+    doc: *const HedlDocument,
+    schema_name: *const c_char,
+    out_str: *mut *mut c_char,
+) -> c_int {
+    const FUNC: &str = "hedl_rows_with_missing";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("schema_name", &sanitize_pointer(schema_name)),
+            ("out_str", &sanitize_pointer(out_str)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_str.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let doc_ref = &(*doc).inner;
+    let schema_name = match schema_arg(FUNC, doc_ref, schema_name, start) {
+        Ok((schema_name, _)) => schema_name,
+        Err(code) => return code,
+    };
+
+    let mut indices = Vec::new();
+    visit_indexed_rows(doc_ref, &mut |_, index, row| {
+        let missing = |value: &Value| match value {
+            Value::Null => true,
+            Value::String(s) => s.is_empty(),
+            _ => false,
+        };
+        if row.type_name == schema_name && row.fields.iter().any(missing) {
+            indices.push(index.to_string());
+        }
+    });
+
+    let result = allocate_output_string(&indices.join("\n"), out_str, HEDL_ERR_ALLOC);
+    if result == HEDL_OK {
+        audit_call_success(FUNC, start.elapsed());
+    } else {
+        audit_call_failure(FUNC, result, "Allocation failed", start.elapsed());
+    }
+    result
+}
+
+/// Get the fields of a struct type that hold a null or empty string in
+/// every row of the type.
+///
+/// Such fields carry no data and are candidates for removal. Rows are read
+/// by the schema of their own list, across all of the type's lists including
+/// nested ones. The fields are written one per line in schema order; an
+/// empty string means every field is used or the type has no rows.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `schema_name` - NUL-terminated name of the struct type
+/// * `out_str` - Pointer to store the field names (must be freed with hedl_free_string)
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_NOT_FOUND if the type is neither declared
+/// nor used by a list, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_unused_fields(
+    doc: *const HedlDocument,
+    schema_name: *const c_char,
+    out_str: *mut *mut c_char,
+) -> c_int {
+    const FUNC: &str = "hedl_unused_fields";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("schema_name", &sanitize_pointer(schema_name)),
+            ("out_str", &sanitize_pointer(out_str)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_str.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let doc_ref = &(*doc).inner;
+    let (schema_name, columns) = match schema_arg(FUNC, doc_ref, schema_name, start) {
+        Ok(schema) => schema,
+        Err(code) => return code,
+    };
+
+    let mut rows = 0;
+    let mut used = HashSet::new();
+    visit_indexed_rows(doc_ref, &mut |schema, _, row| {
+        if row.type_name != schema_name {
+            return;
+        }
+        rows += 1;
+        for (field, value) in schema.iter().zip(&row.fields) {
+            let empty = match value {
+                Value::Null => true,
+                Value::String(s) => s.is_empty(),
+                _ => false,
+            };
+            if !empty {
+                used.insert(field.as_str());
+            }
+        }
+    });
+
+    let unused: Vec<&str> = match rows {
+        0 => Vec::new(),
+        _ => columns
+            .iter()
+            .map(String::as_str)
+            .filter(|column| !used.contains(column))
+            .collect(),
+    };
+    let result = allocate_output_string(&unused.join("\n"), out_str, HEDL_ERR_ALLOC);
+    if result == HEDL_OK {
+        audit_call_success(FUNC, start.elapsed());
+    } else {
+        audit_call_failure(FUNC, result, "Allocation failed", start.elapsed());
+    }
+    result
+}
+
+/// Get the fields of a struct type whose non-null values span more than one
+/// type.
+///
+/// Types are named as `hedl_to_mermaid_er` names them, and as there, ints
+/// and floats together count as float. Rows are read by the schema of their
+/// own list, across all of the type's lists including nested ones. Each
+/// mixed field is written on its own line, sorted by name, followed by the
+/// sorted names of the types seen, separated by tabs; an empty string means
+/// every field holds a single type.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `schema_name` - NUL-terminated name of the struct type
+/// * `out_str` - Pointer to store the fields (must be freed with hedl_free_string)
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_NOT_FOUND if the type is neither declared
+/// nor used by a list, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_mixed_type_fields(
+    doc: *const HedlDocument,
+    schema_name: *const c_char,
+    out_str: *mut *mut c_char,
+) -> c_int {
+    const FUNC: &str = "hedl_mixed_type_fields";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("schema_name", &sanitize_pointer(schema_name)),
+            ("out_str", &sanitize_pointer(out_str)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_str.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let doc_ref = &(*doc).inner;
+    let schema_name = match schema_arg(FUNC, doc_ref, schema_name, start) {
+        Ok((schema_name, _)) => schema_name,
+        Err(code) => return code,
+    };
+
+    let mut seen: BTreeMap<&str, BTreeSet<&str>> = BTreeMap::new();
+    visit_indexed_rows(doc_ref, &mut |schema, _, row| {
+        if row.type_name != schema_name {
+            return;
+        }
+        for (field, value) in schema.iter().zip(&row.fields) {
+            if !matches!(value, Value::Null) {
+                seen.entry(field).or_default().insert(value_type(value));
+            }
+        }
+    });
+
+    let mut lines = Vec::new();
+    for (field, mut types) in seen {
+        if types.contains("int") && types.contains("float") {
+            types.remove("int");
+        }
+        if types.len() > 1 {
+            let mut line = vec![field];
+            line.extend(types);
+            lines.push(line.join("\t"));
+        }
+    }
+
+    let result = allocate_output_string(&lines.join("\n"), out_str, HEDL_ERR_ALLOC);
+    if result == HEDL_OK {
+        audit_call_success(FUNC, start.elapsed());
+    } else {
+        audit_call_failure(FUNC, result, "Allocation failed", start.elapsed());
+    }
+    result
+}
+
+/// Profile the fields of a struct type in a single pass over its rows.
+///
+/// Rows are read by the schema of their own list, across all of the type's
+/// lists including nested ones. Each field of the type is written on its
+/// own line, in schema order, followed by its number of non-null values and
+/// its number of distinct non-null values, separated by tabs. Values are
+/// compared by their HEDL text, so 1 and 1.0 are distinct.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `schema_name` - NUL-terminated name of the struct type
+/// * `out_rows` - Pointer to store the number of rows of the type
+/// * `out_str` - Pointer to store the field statistics (must be freed with hedl_free_string)
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_NOT_FOUND if the type is neither declared
+/// nor used by a list, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_field_stats(
+    doc: *const HedlDocument,
+    schema_name: *const c_char,
+    out_rows: *mut c_int,
+    out_str: *mut *mut c_char,
+) -> c_int {
+    const FUNC: &str = "hedl_field_stats";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("schema_name", &sanitize_pointer(schema_name)),
+            ("out_rows", &sanitize_pointer(out_rows)),
+            ("out_str", &sanitize_pointer(out_str)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_rows.is_null() || out_str.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+    *out_rows = 0;
+
+    let doc_ref = &(*doc).inner;
+    let (schema_name, columns) = match schema_arg(FUNC, doc_ref, schema_name, start) {
+        Ok(schema) => schema,
+        Err(code) => return code,
+    };
+
+    let mut rows: usize = 0;
+    let mut non_null: HashMap<&str, usize> = HashMap::new();
+    let mut distinct: HashMap<&str, HashSet<String>> = HashMap::new();
+    visit_indexed_rows(doc_ref, &mut |schema, _, row| {
+        if row.type_name != schema_name {
+            return;
+        }
+        rows += 1;
+        for (i, (field, value)) in schema.iter().zip(&row.fields).enumerate() {
+            if !matches!(value, Value::Null) {
+                *non_null.entry(field).or_default() += 1;
+                distinct
+                    .entry(field)
+                    .or_default()
+                    .insert(partition_key(row, i));
+            }
+        }
+    });
+
+    let lines: Vec<String> = columns
+        .iter()
+        .map(|column| {
+            format!(
+                "{}\t{}\t{}",
+                column,
+                non_null.get(column.as_str()).copied().unwrap_or(0),
+                distinct.get(column.as_str()).map_or(0, HashSet::len)
+            )
+        })
+        .collect();
+
+    *out_rows = rows.min(c_int::MAX as usize) as c_int;
+    let result = allocate_output_string(&lines.join("\n"), out_str, HEDL_ERR_ALLOC);
+    if result == HEDL_OK {
+        audit_call_success(FUNC, start.elapsed());
+    } else {
+        audit_call_failure(FUNC, result, "Allocation failed", start.elapsed());
+    }
+    result
+}
+
+// =============================================================================
+// Reference Cycles
+// =============================================================================
+
+/// Find the first cycle of references between rows, without modifying the
+/// document.
+///
+/// Each row is identified as "Type:id", and an unqualified reference names a
+/// row of the type of the row holding it; references to rows that do not
+/// exist are ignored. Rows are visited in document order: each list, then
+/// the lists nested under its rows. The rows of the first cycle found are
+/// written one per line in reference order, so a row referencing itself is
+/// a cycle of one; an empty string means there is no cycle.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `out_str` - Pointer to store the cycle (must be freed with hedl_free_string)
+///
+/// # Returns
+/// HEDL_OK on success, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_find_reference_cycle(
+    doc: *const HedlDocument,
+    out_str: *mut *mut c_char,
+) -> c_int {
+    const FUNC: &str = "hedl_find_reference_cycle";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("out_str", &sanitize_pointer(out_str)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_str.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let doc_ref = &(*doc).inner;
+    let mut order = Vec::new();
+    let mut graph: HashMap<String, Vec<String>> = HashMap::new();
+    visit_row_lists(doc_ref, &mut |rows| {
+        for row in rows {
+            let key = format!("{}:{}", row.type_name, row.id);
+            if !graph.contains_key(&key) {
+                graph.insert(key.clone(), Vec::new());
+                order.push(key);
+            }
+        }
+    });
+    visit_row_lists(doc_ref, &mut |rows| {
+        for row in rows {
+            let targets: Vec<String> = row
+                .fields
+                .iter()
+                .skip(1)
+                .filter_map(|value| match value {
+                    Value::Reference(r) => Some(format!(
+                        "{}:{}",
+                        r.type_name.as_deref().unwrap_or(&row.type_name),
+                        r.id
+                    )),
+                    _ => None,
+                })
+                .filter(|target| graph.contains_key(target))
+                .collect();
+            if let Some(edges) = graph.get_mut(&format!("{}:{}", row.type_name, row.id)) {
+                edges.extend(targets);
+            }
+        }
+    });
+
+    let mut done = HashSet::new();
+    let mut cycle = Vec::new();
+    for key in &order {
+        if !done.contains(key.as_str()) {
+            if let Some(found) = find_cycle(&graph, key, &mut Vec::new(), &mut done) {
+                cycle = found;
+                break;
+            }
+        }
+    }
+
+    let result = allocate_output_string(&cycle.join("\n"), out_str, HEDL_ERR_ALLOC);
+    if result == HEDL_OK {
+        audit_call_success(FUNC, start.elapsed());
+    } else {
+        audit_call_failure(FUNC, result, "Allocation failed", start.elapsed());
+    }
+    result
+}
+
+// =============================================================================
+// Uniqueness
+// =============================================================================
+
+/// Check that no two rows of a struct type share a value in one field.
+///
+/// Each value held by more than one row is reported once, as an error with
+/// rule ID "unique" naming the indices of the rows holding it. Indices count
+/// the rows of the type across its lists in document order, including nested
+/// lists. Values of different types never match, so the string "1" and the
+/// integer 1 are distinct, and null values are skipped, as in a SQL unique
+/// constraint. Rows of a list whose schema lacks the field are skipped too.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `schema_name` - NUL-terminated name of the struct type
+/// * `field` - NUL-terminated name of the field
+/// * `out_diag` - Pointer to store diagnostics handle (must be freed with hedl_free_diagnostics)
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_NOT_FOUND if the type or field does not
+/// exist, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_check_unique(
+    doc: *const HedlDocument,
+    schema_name: *const c_char,
+    field: *const c_char,
+    out_diag: *mut *mut HedlDiagnostics,
+) -> c_int {
+    const FUNC: &str = "hedl_check_unique";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("schema_name", &sanitize_pointer(schema_name)),
+            ("field", &sanitize_pointer(field)),
+            ("out_diag", &sanitize_pointer(out_diag)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_diag.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+    *out_diag = ptr::null_mut();
+
+    let doc_ref = &(*doc).inner;
+    let (schema_name, columns) = match schema_arg(FUNC, doc_ref, schema_name, start) {
+        Ok(schema) => schema,
+        Err(code) => return code,
+    };
+    let field = match c_str_arg(field) {
+        Ok(field) => field,
+        Err(code) => {
+            audit_call_failure(FUNC, code, "Invalid field argument", start.elapsed());
+            return code;
+        }
+    };
+    if !columns.iter().any(|column| column == field) {
+        let err_msg = format!("Unknown field {} in type {}", field, schema_name);
+        set_error(&err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NOT_FOUND, &err_msg, start.elapsed());
+        return HEDL_ERR_NOT_FOUND;
+    }
+
+    let mut order = Vec::new();
+    let mut rows: HashMap<String, Vec<String>> = HashMap::new();
+    visit_indexed_rows(doc_ref, &mut |schema, index, row| {
+        if row.type_name != schema_name {
+            return;
+        }
+        let Some(col) = schema.iter().position(|column| column == field) else {
+            return;
+        };
+        if matches!(row.fields.get(col), None | Some(Value::Null)) {
+            return;
+        }
+        let key = partition_key(row, col);
+        if !rows.contains_key(&key) {
+            order.push(key.clone());
+        }
+        rows.entry(key).or_default().push(index.to_string());
+    });
+
+    let diagnostics = order
+        .into_iter()
+        .filter(|key| rows[key].len() > 1)
+        .map(|key| {
+            Diagnostic::error(
+                DiagnosticKind::Custom("unique".to_string()),
+                format!(
+                    "{} field \"{}\" value {} is repeated in rows {}",
+                    schema_name,
+                    field,
+                    key,
+                    rows[&key].join(", ")
+                ),
+                "unique",
+            )
+        })
+        .collect();
+
+    *out_diag = Box::into_raw(Box::new(HedlDiagnostics { inner: diagnostics }));
+    audit_call_success(FUNC, start.elapsed());
+    HEDL_OK
+}
+
+// =============================================================================
+// Ranges
+// =============================================================================
+
+/// Check that the numeric values of some fields fall within inclusive
+/// ranges.
+///
+/// HEDL has no syntax for range annotations, so each field is named
+/// "Type.field" and given its bounds by the caller. Every value below its
+/// minimum or above its maximum is reported as an error with rule ID
+/// "range", and so is every value that is not a number; null values are
+/// skipped. Diagnostics follow the order of `fields`, then the row index,
+/// counted across the type's lists in document order. Rows of a list whose
+/// schema lacks the field are skipped.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `fields` - Array of `field_count` NUL-terminated "Type.field" names
+/// * `mins` - Array of `field_count` lower bounds
+/// * `maxs` - Array of `field_count` upper bounds
+/// * `field_count` - Number of fields
+/// * `out_diag` - Pointer to store diagnostics handle (must be freed with hedl_free_diagnostics)
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_INVALID_ARGUMENT for a field name without a
+/// type or a minimum above its maximum, HEDL_ERR_NOT_FOUND if a type or
+/// field does not exist, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_check_ranges(
+    doc: *const HedlDocument,
+    fields: *const *const c_char,
+    mins: *const f64,
+    maxs: *const f64,
+    field_count: c_int,
+    out_diag: *mut *mut HedlDiagnostics,
+) -> c_int {
+    const FUNC: &str = "hedl_check_ranges";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("fields", &sanitize_pointer(fields)),
+            ("mins", &sanitize_pointer(mins)),
+            ("maxs", &sanitize_pointer(maxs)),
+            ("field_count", &field_count.to_string()),
+            ("out_diag", &sanitize_pointer(out_diag)),
+        ],
+    );
+
+    clear_error();
+
+    let arrays_missing = field_count > 0 && (fields.is_null() || mins.is_null() || maxs.is_null());
+    if !is_valid_document_ptr(doc) || out_diag.is_null() || arrays_missing {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+    *out_diag = ptr::null_mut();
+
+    let doc_ref = &(*doc).inner;
+    let mut ranges = Vec::new();
+    for i in 0..field_count.max(0) as usize {
+        let (min, max) = (*mins.add(i), *maxs.add(i));
+        let (schema_name, field) = match qualified_field_arg(FUNC, doc_ref, *fields.add(i), start) {
+            Ok(parts) => parts,
+            Err(code) => return code,
+        };
+        if min > max {
+            let err_msg = format!(
+                "Range for {}.{} has min {} above max {}",
+                schema_name, field, min, max
+            );
+            set_error(&err_msg);
+            audit_call_failure(FUNC, HEDL_ERR_INVALID_ARGUMENT, &err_msg, start.elapsed());
+            return HEDL_ERR_INVALID_ARGUMENT;
+        }
+        ranges.push((schema_name, field, min, max));
+    }
+
+    let mut diagnostics = Vec::new();
+    for (schema_name, field, min, max) in ranges {
+        visit_indexed_rows(doc_ref, &mut |schema, index, row| {
+            if row.type_name != schema_name {
+                return;
+            }
+            let Some(col) = schema.iter().position(|column| column == field) else {
+                return;
+            };
+            let number = match row.fields.get(col) {
+                None | Some(Value::Null) => return,
+                Some(Value::Int(n)) => Some(*n as f64),
+                Some(Value::Float(f)) => Some(*f),
+                Some(_) => None,
+            };
+            let problem = match number {
+                None => "is not a number".to_string(),
+                Some(n) if n < min || n > max => format!("is outside [{}, {}]", min, max),
+                Some(_) => return,
+            };
+            diagnostics.push(Diagnostic::error(
+                DiagnosticKind::Custom("range".to_string()),
+                format!(
+                    "{} row {} field \"{}\" {} (got {})",
+                    schema_name,
+                    index,
+                    field,
+                    problem,
+                    value_text(&row.fields[col])
+                ),
+                "range",
+            ));
+        });
+    }
+
+    *out_diag = Box::into_raw(Box::new(HedlDiagnostics { inner: diagnostics }));
+    audit_call_success(FUNC, start.elapsed());
+    HEDL_OK
+}
+
+// =============================================================================
+// Foreign Keys
+// =============================================================================
+
+/// Check that the values of some fields match a key of another struct type.
+///
+/// Each foreign key pairs a "Type.field" name in `from_fields` with the
+/// "Type.field" name of the key it refers to in `to_fields`. Every row
+/// whose value has no match among the key values of the referenced type is
+/// reported as an error with rule ID "foreign-key", naming the row index
+/// counted across the type's lists in document order. Values are compared
+/// by their plain text; a reference matches by its ID, and a qualified
+/// reference to another type never matches. Null values are skipped, as
+/// are rows of a list whose schema lacks the field.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `from_fields` - Array of `key_count` NUL-terminated "Type.field" names holding the keys
+/// * `to_fields` - Array of `key_count` NUL-terminated "Type.field" names of the referenced keys
+/// * `key_count` - Number of foreign keys
+/// * `out_diag` - Pointer to store diagnostics handle (must be freed with hedl_free_diagnostics)
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_INVALID_ARGUMENT for a field name without a
+/// type, HEDL_ERR_NOT_FOUND if a type or field does not exist, error code
+/// on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_check_foreign_keys(
+    doc: *const HedlDocument,
+    from_fields: *const *const c_char,
+    to_fields: *const *const c_char,
+    key_count: c_int,
+    out_diag: *mut *mut HedlDiagnostics,
+) -> c_int {
+    const FUNC: &str = "hedl_check_foreign_keys";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("from_fields", &sanitize_pointer(from_fields)),
+            ("to_fields", &sanitize_pointer(to_fields)),
+            ("key_count", &key_count.to_string()),
+            ("out_diag", &sanitize_pointer(out_diag)),
+        ],
+    );
+
+    clear_error();
+
+    let arrays_missing = key_count > 0 && (from_fields.is_null() || to_fields.is_null());
+    if !is_valid_document_ptr(doc) || out_diag.is_null() || arrays_missing {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+    *out_diag = ptr::null_mut();
+
+    let doc_ref = &(*doc).inner;
+    let mut keys = Vec::new();
+    for i in 0..key_count.max(0) as usize {
+        let to = match qualified_field_arg(FUNC, doc_ref, *to_fields.add(i), start) {
+            Ok(parts) => parts,
+            Err(code) => return code,
+        };
+        let from = match qualified_field_arg(FUNC, doc_ref, *from_fields.add(i), start) {
+            Ok(parts) => parts,
+            Err(code) => return code,
+        };
+        keys.push((from, to));
+    }
+
+    let mut diagnostics = Vec::new();
+    for ((from_type, from_field), (to_type, to_key)) in keys {
+        let mut targets = HashSet::new();
+        visit_indexed_rows(doc_ref, &mut |schema, _, row| {
+            if row.type_name != to_type {
+                return;
+            }
+            let col = schema.iter().position(|column| column == to_key);
+            match col.and_then(|col| row.fields.get(col)) {
+                None | Some(Value::Null) => {}
+                Some(value) => {
+                    targets.insert(value_text(value).into_owned());
+                }
+            }
+        });
+
+        visit_indexed_rows(doc_ref, &mut |schema, index, row| {
+            if row.type_name != from_type {
+                return;
+            }
+            let col = schema.iter().position(|column| column == from_field);
+            let value = match col.and_then(|col| row.fields.get(col)) {
+                None | Some(Value::Null) => return,
+                Some(value) => value,
+            };
+            let matched = match value {
+                Value::Reference(r) if r.type_name.as_deref().unwrap_or(to_type) != to_type => {
+                    false
+                }
+                Value::Reference(r) => targets.contains(&r.id),
+                value => targets.contains(value_text(value).as_ref()),
+            };
+            if !matched {
+                diagnostics.push(Diagnostic::error(
+                    DiagnosticKind::Custom("foreign-key".to_string()),
+                    format!(
+                        "{} row {} has no match for {}.{} -> {}.{} (got {})",
+                        from_type,
+                        index,
+                        from_type,
+                        from_field,
+                        to_type,
+                        to_key,
+                        value_text(value)
+                    ),
+                    "foreign-key",
+                ));
+            }
+        });
+    }
+
+    *out_diag = Box::into_raw(Box::new(HedlDiagnostics { inner: diagnostics }));
+    audit_call_success(FUNC, start.elapsed());
+    HEDL_OK
+}
+
+// =============================================================================
+// Whitespace
+// =============================================================================
+
+/// Check for string values with leading or trailing whitespace.
+///
+/// Each one is reported as a warning with rule ID "whitespace": key-value
+/// pairs first, by dot-separated key path, then matrix cells, by type, row
+/// index counted across the type's lists in document order, and field.
+/// `hedl_trim_strings` removes them.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `out_diag` - Pointer to store diagnostics handle (must be freed with hedl_free_diagnostics)
+///
+/// # Returns
+/// HEDL_OK on success, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_check_whitespace(
+    doc: *const HedlDocument,
+    out_diag: *mut *mut HedlDiagnostics,
+) -> c_int {
+    const FUNC: &str = "hedl_check_whitespace";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("out_diag", &sanitize_pointer(out_diag)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_diag.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+    *out_diag = ptr::null_mut();
+
+    let doc_ref = &(*doc).inner;
+    let padded = |value: &Value| match value {
+        Value::String(s) if s.trim() != s => Some(format!("{:?}", s)),
+        _ => None,
+    };
+
+    let mut diagnostics = Vec::new();
+    let mut report = |location: String, text: String| {
+        diagnostics.push(Diagnostic::warning(
+            DiagnosticKind::Custom("whitespace".to_string()),
+            format!("{} has leading or trailing whitespace ({})", location, text),
+            "whitespace",
+        ));
+    };
+    visit_key_values("", &doc_ref.root, &mut |path, value| {
+        if let Some(text) = padded(value) {
+            report(path.to_string(), text);
+        }
+    });
+    visit_indexed_rows(doc_ref, &mut |schema, index, row| {
+        for (field, value) in schema.iter().zip(&row.fields) {
+            if let Some(text) = padded(value) {
+                let location = format!("{} row {} field \"{}\"", row.type_name, index, field);
+                report(location, text);
+            }
+        }
+    });
+
+    *out_diag = Box::into_raw(Box::new(HedlDiagnostics { inner: diagnostics }));
+    audit_call_success(FUNC, start.elapsed());
+    HEDL_OK
+}
+
+/// Copy a document with leading and trailing whitespace removed from every
+/// string value, in key-value pairs and matrix cells, fixing what
+/// `hedl_check_whitespace` reports. References, expressions and other
+/// values are unchanged.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `out_doc` - Pointer to store the new document handle (must be freed with hedl_free_document)
+///
+/// # Returns
+/// HEDL_OK on success, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_trim_strings(
+    doc: *const HedlDocument,
+    out_doc: *mut *mut HedlDocument,
+) -> c_int {
+    const FUNC: &str = "hedl_trim_strings";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("out_doc", &sanitize_pointer(out_doc)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_doc.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let mut trimmed = (*doc).inner.clone();
+    map_values(&mut trimmed.root, &mut |value| {
+        if let Value::String(s) = value {
+            if s.trim() != s {
+                *s = s.trim().to_string();
+            }
+        }
+    });
+
+    *out_doc = Box::into_raw(Box::new(HedlDocument::new(trimmed)));
+    audit_call_success(FUNC, start.elapsed());
+    HEDL_OK
+}
+
+// =============================================================================
+// Fixes
+// =============================================================================
+
+/// Copy a document with safe fixes applied, and describe each change, for a
+/// "hedl fix" command. The fixes are, in order:
+///
+/// - CRLF and lone CR line endings in strings become LF.
+/// - Leading and trailing whitespace is trimmed from strings.
+/// - A string in a column whose other values are all numbers, or all
+///   booleans, becomes that type if it reads as one, so `"42"` becomes 42
+///   as it would unquoted and `"False"` becomes false. Key-value pairs have
+///   no column to go by and are left as strings.
+/// - %STRUCT and %NEST declarations no list uses are dropped, which clears
+///   the unused-schema lint warning.
+///
+/// Object keys need no fixing, since documents keep them sorted by name.
+///
+/// The changelog has one change per line: string fixes in key-value pairs
+/// first, by dot-separated key path, then in matrix cells, by type, row
+/// index counted across the type's lists in document order, and field;
+/// then coercions, by type, field and row; then removed types. It is empty
+/// when nothing needs fixing.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `out_doc` - Pointer to store the fixed document handle (must be freed with hedl_free_document)
+/// * `out_changes` - Pointer to store the changelog (must be freed with hedl_free_string)
+///
+/// # Returns
+/// HEDL_OK on success, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_auto_fix(
+    doc: *const HedlDocument,
+    out_doc: *mut *mut HedlDocument,
+    out_changes: *mut *mut c_char,
+) -> c_int {
+    const FUNC: &str = "hedl_auto_fix";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("out_doc", &sanitize_pointer(out_doc)),
+            ("out_changes", &sanitize_pointer(out_changes)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_doc.is_null() || out_changes.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+    *out_doc = ptr::null_mut();
+    *out_changes = ptr::null_mut();
+
+    let doc_ref = &(*doc).inner;
+    let mut changes = Vec::new();
+    visit_key_values("", &doc_ref.root, &mut |path, value| {
+        if let Value::String(s) = value {
+            fix_string(path, s, &mut changes);
+        }
+    });
+
+    let mut column_types: HashMap<(&str, &str), BTreeSet<&str>> = HashMap::new();
+    visit_indexed_rows(doc_ref, &mut |schema, _, row| {
+        for (field, value) in schema.iter().zip(&row.fields) {
+            if !matches!(value, Value::Null | Value::String(_)) {
+                let seen = column_types.entry((&row.type_name, field)).or_default();
+                seen.insert(value_type(value));
+            }
+        }
+    });
+    let coerce = |type_name: &str, field: &str, s: &str| {
+        let seen = column_types.get(&(type_name, field))?;
+        let numeric = seen.iter().all(|t| *t == "int" || *t == "float");
+        if seen.len() == 1 && seen.contains("bool") {
+            ["true", "false"]
+                .into_iter()
+                .find(|b| s.eq_ignore_ascii_case(b))
+                .map(|b| Value::Bool(b == "true"))
+        } else if numeric {
+            number_value(s)
+        } else {
+            None
+        }
+    };
+
+    let mut coercions = Vec::new();
+    visit_indexed_rows(doc_ref, &mut |schema, index, row| {
+        for (column, (field, value)) in schema.iter().zip(&row.fields).enumerate() {
+            let Value::String(s) = value else {
+                continue;
+            };
+            let location = format!("{} row {} field \"{}\"", row.type_name, index, field);
+            let fixed = fix_string(&location, s, &mut changes);
+            if let Some(coerced) = coerce(&row.type_name, field, &fixed) {
+                let change = format!(
+                    "coerced {} from {:?} to {}",
+                    location,
+                    fixed,
+                    value_type(&coerced)
+                );
+                coercions.push((row.type_name.as_str(), column, change));
+            }
+        }
+    });
+    coercions.sort_by(|a, b| (a.0, a.1).cmp(&(b.0, b.1)));
+    changes.extend(coercions.into_iter().map(|(_, _, change)| change));
+
+    let mut fixed_doc = doc_ref.clone();
+    map_values(&mut fixed_doc.root, &mut |value| {
+        if let Value::String(s) = value {
+            *s = fix_string("", s, &mut Vec::new());
+        }
+    });
+    let mut coerce_cell = |type_name: &str, field: &str, value: &mut Value| {
+        if let Value::String(s) = value {
+            if let Some(coerced) = coerce(type_name, field, s) {
+                *value = coerced;
+            }
+        }
+    };
+    map_cells(&fixed_doc.structs, &mut fixed_doc.root, &mut coerce_cell);
+
+    let mut used = HashSet::new();
+    collect_types(&doc_ref.root, &mut used);
+    for name in doc_ref.structs.keys() {
+        if !used.contains(name.as_str()) {
+            changes.push(format!("removed unused schema {}", name));
+        }
+    }
+    fixed_doc
+        .structs
+        .retain(|name, _| used.contains(name.as_str()));
+    fixed_doc
+        .nests
+        .retain(|parent, child| used.contains(parent.as_str()) && used.contains(child.as_str()));
+
+    let result = allocate_output_string(&changes.join("\n"), out_changes, HEDL_ERR_ALLOC);
+    if result != HEDL_OK {
+        audit_call_failure(FUNC, result, "Allocation failed", start.elapsed());
+        return result;
+    }
+    *out_doc = Box::into_raw(Box::new(HedlDocument::new(fixed_doc)));
+    audit_call_success(FUNC, start.elapsed());
+    HEDL_OK
+}
+
+// =============================================================================
+// Helpers
+// =============================================================================
+
+/// Read the type argument of a check and find its columns, recording the
+/// failure for `func` if it is invalid or the type is neither declared nor
+/// used by a list.
+pub(crate) unsafe fn schema_arg<'a>(
+    func: &'static str,
+    doc: &'a Document,
+    schema_name: *const c_char,
+    start: Instant,
+) -> Result<(&'a str, &'a [String]), c_int> {
+    let schema_name = match c_str_arg(schema_name) {
+        Ok(schema_name) => schema_name,
+        Err(code) => {
+            audit_call_failure(func, code, "Invalid name argument", start.elapsed());
+            return Err(code);
+        }
+    };
+    match schema_columns(doc, schema_name) {
+        Some(columns) => Ok((schema_name, columns)),
+        None => {
+            let err_msg = format!("Unknown type: {}", schema_name);
+            set_error(&err_msg);
+            audit_call_failure(func, HEDL_ERR_NOT_FOUND, &err_msg, start.elapsed());
+            Err(HEDL_ERR_NOT_FOUND)
+        }
+    }
+}
+
+/// Read a "Type.field" argument of a check, recording the failure for
+/// `func` if it is invalid, has no type, or the type or field does not
+/// exist.
+pub(crate) unsafe fn qualified_field_arg<'a>(
+    func: &'static str,
+    doc: &'a Document,
+    name: *const c_char,
+    start: Instant,
+) -> Result<(&'a str, &'a str), c_int> {
+    let name = match c_str_arg(name) {
+        Ok(name) => name,
+        Err(code) => {
+            audit_call_failure(func, code, "Invalid field argument", start.elapsed());
+            return Err(code);
+        }
+    };
+    let (code, err_msg) = match name.rsplit_once('.') {
+        None => (
+            HEDL_ERR_INVALID_ARGUMENT,
+            format!("Field {:?} is not of the form Type.field", name),
+        ),
+        Some((schema_name, field)) => match schema_columns(doc, schema_name) {
+            None => (HEDL_ERR_NOT_FOUND, format!("Unknown type: {}", schema_name)),
+            Some(columns) if columns.iter().any(|column| column == field) => {
+                return Ok((schema_name, field));
+            }
+            Some(_) => (
+                HEDL_ERR_NOT_FOUND,
+                format!("Unknown field {} in type {}", field, schema_name),
+            ),
+        },
+    };
+    set_error(&err_msg);
+    audit_call_failure(func, code, &err_msg, start.elapsed());
+    Err(code)
+}
+
+/// The columns of struct type `schema_name`: its declaration, or the inline
+/// schema of the first list of the type if it has none.
+pub(crate) fn schema_columns<'a>(doc: &'a Document, schema_name: &str) -> Option<&'a [String]> {
+    if let Some(columns) = doc.structs.get(schema_name) {
+        return Some(columns);
+    }
+    let mut columns = None;
+    visit_lists(&doc.root, &mut |list| {
+        if columns.is_none() && list.type_name == schema_name {
+            columns = Some(list.schema.as_slice());
+        }
+    });
+    columns
+}
+
+/// Call `f` for every scalar key-value pair under `items`, outside matrix
+/// lists, with its dot-separated key path.
+fn visit_key_values(prefix: &str, items: &BTreeMap<String, Item>, f: &mut dyn FnMut(&str, &Value)) {
+    for (key, item) in items {
+        match item {
+            Item::Scalar(value) => f(&format!("{}{}", prefix, key), value),
+            Item::Object(obj) => visit_key_values(&format!("{}{}.", prefix, key), obj, f),
+            Item::List(_) => {}
+        }
+    }
+}
+
+/// Call `f` for every scalar value under `items`, in key-value pairs and in
+/// the cells of matrix rows, including nested rows.
+fn map_values(items: &mut BTreeMap<String, Item>, f: &mut dyn FnMut(&mut Value)) {
+    fn map_rows(rows: &mut [Node], f: &mut dyn FnMut(&mut Value)) {
+        for row in rows {
+            row.fields.iter_mut().for_each(&mut *f);
+            for children in row.children.values_mut() {
+                map_rows(children, f);
+            }
+        }
+    }
+
+    for item in items.values_mut() {
+        match item {
+            Item::Scalar(value) => f(value),
+            Item::Object(obj) => map_values(obj, f),
+            Item::List(list) => map_rows(&mut list.rows, f),
+        }
+    }
+}
+
+/// Call `f` for the cells of every matrix row under `items`, including
+/// nested rows, with the row's type and the cell's field. Nested rows take
+/// the declared schema of their type.
+fn map_cells(
+    structs: &BTreeMap<String, Vec<String>>,
+    items: &mut BTreeMap<String, Item>,
+    f: &mut dyn FnMut(&str, &str, &mut Value),
+) {
+    fn map_rows(
+        structs: &BTreeMap<String, Vec<String>>,
+        schema: &[String],
+        rows: &mut [Node],
+        f: &mut dyn FnMut(&str, &str, &mut Value),
+    ) {
+        for row in rows {
+            for (field, value) in schema.iter().zip(&mut row.fields) {
+                f(&row.type_name, field, value);
+            }
+            for (child_type, children) in &mut row.children {
+                let child_schema = structs.get(child_type).map(Vec::as_slice).unwrap_or(&[]);
+                map_rows(structs, child_schema, children, f);
+            }
+        }
+    }
+
+    for item in items.values_mut() {
+        match item {
+            Item::Scalar(_) => {}
+            Item::Object(obj) => map_cells(structs, obj, f),
+            Item::List(list) => map_rows(structs, &list.schema, &mut list.rows, f),
+        }
+    }
+}
+
+/// Normalize the line endings of `s` and trim it, as `hedl_auto_fix` does,
+/// adding a change naming `location` for each fix that applies.
+fn fix_string(location: &str, s: &str, changes: &mut Vec<String>) -> String {
+    let mut fixed = s.replace("\r\n", "\n").replace('\r', "\n");
+    if fixed != s {
+        changes.push(format!("normalized line endings in {}", location));
+    }
+    if fixed.trim() != fixed {
+        changes.push(format!("trimmed whitespace in {}", location));
+        fixed = fixed.trim().to_string();
+    }
+    fixed
+}
+
+/// Read `s` as a number the way the parser reads an unquoted one: an
+/// integer, or a finite float if it has a decimal point.
+fn number_value(s: &str) -> Option<Value> {
+    if !s.starts_with(|c: char| c == '-' || c.is_ascii_digit()) {
+        return None;
+    }
+    if s.contains('.') {
+        s.parse::<f64>()
+            .ok()
+            .filter(|f| f.is_finite() && !s.ends_with('.'))
+            .map(Value::Float)
+    } else {
+        s.parse::<i64>().ok().map(Value::Int)
+    }
+}
+
+/// Call `f` for every matrix list held by an object under `items`, in
+/// document order, skipping lists of rows nested under other rows.
+pub(crate) fn visit_lists<'a>(
+    items: &'a BTreeMap<String, Item>,
+    f: &mut dyn FnMut(&'a MatrixList),
+) {
+    for item in items.values() {
+        match item {
+            Item::List(list) => f(list),
+            Item::Object(obj) => visit_lists(obj, f),
+            Item::Scalar(_) => {}
+        }
+    }
+}
+
+/// Call `f` for every row of `doc` with the schema of its list and its row
+/// index, counting the rows of each type across its lists in document order:
+/// each list, then the lists nested under its rows, as `hedl_largest_values`
+/// does. Nested rows take the declared schema of their type.
+pub(crate) fn visit_indexed_rows<'a>(
+    doc: &'a Document,
+    f: &mut dyn FnMut(&'a [String], usize, &'a Node),
+) {
+    fn visit<'a>(
+        doc: &'a Document,
+        schema: &'a [String],
+        rows: &'a [Node],
+        rows_seen: &mut HashMap<String, usize>,
+        f: &mut dyn FnMut(&'a [String], usize, &'a Node),
+    ) {
+        let Some(first_row) = rows.first() else {
+            return;
+        };
+        let seen = rows_seen.entry(first_row.type_name.clone()).or_insert(0);
+        let first = *seen;
+        *seen += rows.len();
+
+        for (i, row) in rows.iter().enumerate() {
+            f(schema, first + i, row);
+        }
+        for row in rows {
+            for (child_type, children) in &row.children {
+                let child_schema = doc
+                    .structs
+                    .get(child_type)
+                    .map(Vec::as_slice)
+                    .unwrap_or(&[]);
+                visit(doc, child_schema, children, rows_seen, f);
+            }
+        }
+    }
+
+    let mut rows_seen = HashMap::new();
+    visit_lists(&doc.root, &mut |list| {
+        visit(doc, &list.schema, &list.rows, &mut rows_seen, f);
+    });
+}
+
+/// The name of a value's type, as reported by the checks and exports that
+/// infer field types.
+pub(crate) fn value_type(value: &Value) -> &'static str {
+    match value {
+        Value::Null => "null",
+        Value::Bool(_) => "bool",
+        Value::Int(_) => "int",
+        Value::Float(_) => "float",
+        Value::String(_) => "string",
+        Value::Tensor(_) => "tensor",
+        Value::Reference(_) => "reference",
+        Value::Expression(_) => "expression",
+    }
+}
+
+/// Call `f` for the rows of every matrix list of `doc` in document order:
+/// each list, then the lists nested under its rows.
+fn visit_row_lists(doc: &Document, f: &mut dyn FnMut(&[Node])) {
+    fn visit(rows: &[Node], f: &mut dyn FnMut(&[Node])) {
+        f(rows);
+        for row in rows {
+            for children in row.children.values() {
+                visit(children, f);
+            }
+        }
+    }
+
+    visit_lists(&doc.root, &mut |list| visit(&list.rows, f));
+}
+
+/// Search the reference graph depth-first from `key`, returning the rows of
+/// the first cycle reached. `path` holds the rows being visited and `done`
+/// the rows fully explored.
+fn find_cycle<'a>(
+    graph: &'a HashMap<String, Vec<String>>,
+    key: &'a str,
+    path: &mut Vec<&'a str>,
+    done: &mut HashSet<&'a str>,
+) -> Option<Vec<&'a str>> {
+    path.push(key);
+    for next in &graph[key] {
+        if let Some(i) = path.iter().position(|k| *k == next.as_str()) {
+            return Some(path[i..].to_vec());
+        }
+        if !done.contains(next.as_str()) {
+            if let Some(cycle) = find_cycle(graph, next, path, done) {
+                return Some(cycle);
+            }
+        }
+    }
+    path.pop();
+    done.insert(key);
+    None
+}
@@ -0,0 +1,225 @@
+// Dweve HEDL - Hierarchical Entity Data Language
+//
+// Copyright (c) 2025 Dweve IP B.V. and individual contributors.
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License in the LICENSE file at the
+// root of this repository or at: http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//! Row cursors for exporting one struct type as CSV a few rows at a time.
+//!
+//! A cursor walks the rows of a type without building the whole export, so
+//! callers can write each chunk out and free it before asking for the next:
+//!
+//! ```c
+//! HedlCsvCursor* cursor = NULL;
+//! if (hedl_csv_cursor_open(doc, "User", &cursor) == HEDL_OK) {
+//!     char* chunk = NULL;
+//!     int rows;
+//!     do {
+//!         rows = hedl_csv_cursor_next(cursor, 1000, &chunk);
+//!         if (rows < 0) break;
+//!         fputs(chunk, out);
+//!         hedl_free_string(chunk);
+//!     } while (rows > 0);
+//!     hedl_free_csv_cursor(cursor);
+//! }
+//! ```
+
+use crate::audit::{audit_call_failure, audit_call_start, audit_call_success, sanitize_pointer};
+use crate::error::{clear_error, set_error};
+use crate::memory::{is_valid_csv_cursor_ptr, is_valid_document_ptr};
+use crate::operations::{c_str_arg, visit_rows};
+use crate::types::{
+    HedlCsvCursor, HedlDocument, HEDL_ERR_ALLOC, HEDL_ERR_NOT_FOUND, HEDL_ERR_NULL_PTR, HEDL_OK,
+};
+use crate::utils::allocate_output_string;
+use hedl_core::{Node, Value};
+use std::borrow::Cow;
+use std::os::raw::{c_char, c_int};
+use std::ptr;
+use std::time::Instant;
+
+/// Open a cursor over the rows of one struct type for CSV export.
+///
+/// Rows are taken from every list of the type, including nested ones, in
+/// document order. The cursor refers to the document's rows, so the
+/// document must stay alive and unmodified until the cursor is freed.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `schema_name` - NUL-terminated name of the struct type
+/// * `out_cursor` - Pointer to store the cursor (must be freed with hedl_free_csv_cursor)
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_NOT_FOUND if the type is not declared,
+/// error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_csv_cursor_open(
+    doc: *const HedlDocument,
+    schema_name: *const c_char,
+    out_cursor: *mut *mut HedlCsvCursor,
+) -> c_int {
+    const FUNC: &str = "hedl_csv_cursor_open";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("schema_name", &sanitize_pointer(schema_name)),
+            ("out_cursor", &sanitize_pointer(out_cursor)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_cursor.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+    *out_cursor = ptr::null_mut();
+
+    let doc_ref = &(*doc).inner;
+    let schema_name = match c_str_arg(schema_name) {
+        Ok(name) => name,
+        Err(code) => {
+            audit_call_failure(FUNC, code, "Invalid name argument", start.elapsed());
+            return code;
+        }
+    };
+    let schema = match doc_ref.structs.get(schema_name) {
+        Some(schema) => schema,
+        None => {
+            let err_msg = format!("Unknown type: {}", schema_name);
+            set_error(&err_msg);
+            audit_call_failure(FUNC, HEDL_ERR_NOT_FOUND, &err_msg, start.elapsed());
+            return HEDL_ERR_NOT_FOUND;
+        }
+    };
+
+    let mut rows = Vec::new();
+    visit_rows(&doc_ref.root, schema_name, &mut |row| {
+        rows.push(row as *const Node)
+    });
+    let header: Vec<Cow<str>> = schema.iter().map(|field| csv_field(field)).collect();
+
+    *out_cursor = Box::into_raw(Box::new(HedlCsvCursor {
+        header: Some(header.join(",") + "\n"),
+        rows,
+        next: 0,
+    }));
+    audit_call_success(FUNC, start.elapsed());
+    HEDL_OK
+}
+
+/// Read the next rows of a cursor as CSV.
+///
+/// The first call also writes the header row of the type's fields. Each
+/// record is terminated by `\n`; strings are written as-is, null as an
+/// empty field and other values as HEDL text, and fields containing a
+/// comma, quote or line break, or starting with whitespace, are quoted.
+/// Once every row has been read the output is empty and 0 is returned.
+///
+/// # Arguments
+/// * `cursor` - Cursor from hedl_csv_cursor_open
+/// * `max_rows` - Most rows to read; at least one is read while rows remain
+/// * `out_str` - Pointer to store the CSV text (must be freed with hedl_free_string)
+///
+/// # Returns
+/// The number of rows read, 0 at the end, or a negative error code on failure.
+///
+/// # Safety
+/// All pointers must be valid, and the cursor's document must not have been
+/// freed or modified. Returns HEDL_ERR_NULL_PTR if cursor is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_csv_cursor_next(
+    cursor: *mut HedlCsvCursor,
+    max_rows: c_int,
+    out_str: *mut *mut c_char,
+) -> c_int {
+    const FUNC: &str = "hedl_csv_cursor_next";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("cursor", &sanitize_pointer(cursor)),
+            ("max_rows", &max_rows.to_string()),
+            ("out_str", &sanitize_pointer(out_str)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_csv_cursor_ptr(cursor) || out_str.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let cursor = &mut *cursor;
+    let mut out = cursor.header.clone().unwrap_or_default();
+    let end = cursor
+        .rows
+        .len()
+        .min(cursor.next + max_rows.max(1) as usize);
+    for &row in &cursor.rows[cursor.next..end] {
+        for (i, value) in (*row).fields.iter().enumerate() {
+            if i > 0 {
+                out.push(',');
+            }
+            out.push_str(&csv_field(&value_text(value)));
+        }
+        out.push('\n');
+    }
+
+    let result = allocate_output_string(&out, out_str, HEDL_ERR_ALLOC);
+    if result != HEDL_OK {
+        audit_call_failure(FUNC, result, "Allocation failed", start.elapsed());
+        return result;
+    }
+    let read = end - cursor.next;
+    cursor.header = None;
+    cursor.next = end;
+    audit_call_success(FUNC, start.elapsed());
+    read as c_int
+}
+
+/// The plain text of a value in a CSV field.
+pub(crate) fn value_text(value: &Value) -> Cow<'_, str> {
+    match value {
+        Value::Null => Cow::Borrowed(""),
+        Value::String(s) => Cow::Borrowed(s),
+        Value::Float(f) if f.is_finite() && f.fract() == 0.0 => Cow::Owned(format!("{:.1}", f)),
+        Value::Tensor(t) => Cow::Owned(t.to_string()),
+        value => Cow::Owned(value.to_string()),
+    }
+}
+
+/// Quote `text` for a CSV field if it holds a comma, quote or line break or
+/// starts with whitespace.
+pub(crate) fn csv_field(text: &str) -> Cow<'_, str> {
+    let needs_quotes =
+        text.contains([',', '"', '\r', '\n']) || text.starts_with(char::is_whitespace);
+    if needs_quotes {
+        Cow::Owned(format!("\"{}\"", text.replace('"', "\"\"")))
+    } else {
+        Cow::Borrowed(text)
+    }
+}
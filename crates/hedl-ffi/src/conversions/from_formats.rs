@@ -19,8 +19,8 @@
 
 use crate::error::{clear_error, set_error};
 use crate::types::{
-    HedlDocument, HEDL_ERR_JSON, HEDL_ERR_NULL_PTR, HEDL_ERR_PARQUET, HEDL_ERR_XML, HEDL_ERR_YAML,
-    HEDL_OK,
+    HedlDocument, HEDL_ERR_CSV, HEDL_ERR_JSON, HEDL_ERR_NULL_PTR, HEDL_ERR_PARQUET, HEDL_ERR_TOML,
+    HEDL_ERR_XML, HEDL_ERR_YAML, HEDL_OK,
 };
 use crate::utils::get_input_string;
 use std::os::raw::{c_char, c_int};
@@ -85,7 +85,7 @@ pub unsafe extern "C" fn hedl_from_json(
 
     match hedl_json::json_to_hedl(&json_str) {
         Ok(doc) => {
-            let handle = Box::new(HedlDocument { inner: doc });
+            let handle = Box::new(HedlDocument::new(doc));
             *out_doc = Box::into_raw(handle);
             audit_call_success("hedl_from_json", start.elapsed());
             HEDL_OK
@@ -156,7 +156,7 @@ pub unsafe extern "C" fn hedl_from_yaml(
 
     match hedl_yaml::yaml_to_hedl(&yaml_str) {
         Ok(doc) => {
-            let handle = Box::new(HedlDocument { inner: doc });
+            let handle = Box::new(HedlDocument::new(doc));
             *out_doc = Box::into_raw(handle);
             audit_call_success("hedl_from_yaml", start.elapsed());
             HEDL_OK
@@ -227,7 +227,7 @@ pub unsafe extern "C" fn hedl_from_xml(
 
     match hedl_xml::xml_to_hedl(&xml_str) {
         Ok(doc) => {
-            let handle = Box::new(HedlDocument { inner: doc });
+            let handle = Box::new(HedlDocument::new(doc));
             *out_doc = Box::into_raw(handle);
             audit_call_success("hedl_from_xml", start.elapsed());
             HEDL_OK
@@ -243,6 +243,125 @@ pub unsafe extern "C" fn hedl_from_xml(
     }
 }
 
+// =============================================================================
+// CSV Conversion (requires "csv" feature)
+// =============================================================================
+
+/// Parse CSV with a header row into a HEDL document.
+///
+/// The header defines a single `Row` struct whose first column is the row ID
+/// and is always named `id`; the other header names are lowercased, with
+/// characters not valid in a key replaced by underscores. Every following
+/// record becomes a row of the `rows` list. Quoted fields may contain commas
+/// and newlines. A record with a different number of fields than the header
+/// fails with HEDL_ERR_CSV.
+///
+/// # Arguments
+/// * `csv` - UTF-8 encoded CSV string
+/// * `csv_len` - Length of input in bytes, or -1 for null-terminated
+/// * `out_doc` - Pointer to store document handle
+///
+/// # Returns
+/// HEDL_OK on success, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid.
+///
+/// # Feature
+/// Requires the "csv" feature to be enabled.
+#[cfg(feature = "csv")]
+#[no_mangle]
+pub unsafe extern "C" fn hedl_from_csv(
+    csv: *const c_char,
+    csv_len: c_int,
+    out_doc: *mut *mut HedlDocument,
+) -> c_int {
+    use crate::audit::{audit_call_failure, audit_call_start, audit_call_success, sanitize_pointer};
+    use std::time::Instant;
+
+    let start = Instant::now();
+    let csv_ptr_str = sanitize_pointer(csv);
+    let csv_len_str = csv_len.to_string();
+    audit_call_start("hedl_from_csv", &[("csv_ptr", &csv_ptr_str), ("csv_len", &csv_len_str)]);
+
+    clear_error();
+
+    if csv.is_null() || out_doc.is_null() {
+        let duration = start.elapsed();
+        set_error("Null pointer argument");
+        audit_call_failure("hedl_from_csv", HEDL_ERR_NULL_PTR, "NULL pointer", duration);
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let csv_str = match get_input_string(csv, csv_len) {
+        Ok(s) => s,
+        Err(code) => {
+            let duration = start.elapsed();
+            let msg = crate::error::get_thread_local_error();
+            audit_call_failure("hedl_from_csv", code, &msg, duration);
+            return code;
+        }
+    };
+
+    let result = csv_header_fields(&csv_str).and_then(|fields| {
+        let schema: Vec<&str> = fields.iter().map(String::as_str).collect();
+        hedl_csv::from_csv(&csv_str, "Row", &schema).map_err(|e| e.to_string())
+    });
+
+    match result {
+        Ok(doc) => {
+            let handle = Box::new(HedlDocument::new(doc));
+            *out_doc = Box::into_raw(handle);
+            audit_call_success("hedl_from_csv", start.elapsed());
+            HEDL_OK
+        }
+        Err(e) => {
+            let duration = start.elapsed();
+            let msg = format!("CSV parse error: {}", e);
+            set_error(&msg);
+            *out_doc = ptr::null_mut();
+            audit_call_failure("hedl_from_csv", HEDL_ERR_CSV, &msg, duration);
+            HEDL_ERR_CSV
+        }
+    }
+}
+
+/// Read the header row of CSV input and turn every name after the ID
+/// column into a unique field name.
+#[cfg(feature = "csv")]
+fn csv_header_fields(csv: &str) -> Result<Vec<String>, String> {
+    let mut reader = csv::ReaderBuilder::new()
+        .trim(csv::Trim::All)
+        .from_reader(csv.as_bytes());
+    let headers = reader.headers().map_err(|e| e.to_string())?;
+    if headers.is_empty() {
+        return Err("missing header row".to_string());
+    }
+
+    let mut seen = std::collections::HashSet::from(["id".to_string()]);
+    let mut fields = Vec::with_capacity(headers.len() - 1);
+    for (i, header) in headers.iter().enumerate().skip(1) {
+        let mut name: String = header
+            .to_lowercase()
+            .chars()
+            .map(|c| if c.is_ascii_lowercase() || c.is_ascii_digit() { c } else { '_' })
+            .collect();
+        if name.is_empty() {
+            name = format!("column_{}", i + 1);
+        } else if name.starts_with(|c: char| c.is_ascii_digit()) {
+            name.insert(0, '_');
+        }
+        let base = name.clone();
+        let mut n = 2;
+        while !seen.insert(name.clone()) {
+            name = format!("{}_{}", base, n);
+            n += 1;
+        }
+        fields.push(name);
+    }
+    Ok(fields)
+}
+
 // =============================================================================
 // Parquet Conversion (requires "parquet" feature)
 // =============================================================================
@@ -290,7 +409,7 @@ pub unsafe extern "C" fn hedl_from_parquet(
 
     match hedl_parquet::from_parquet_bytes(bytes) {
         Ok(doc) => {
-            let handle = Box::new(HedlDocument { inner: doc });
+            let handle = Box::new(HedlDocument::new(doc));
             *out_doc = Box::into_raw(handle);
             audit_call_success("hedl_from_parquet", start.elapsed());
             HEDL_OK
@@ -305,3 +424,128 @@ pub unsafe extern "C" fn hedl_from_parquet(
         }
     }
 }
+
+// =============================================================================
+// TOML Conversion (requires "toml" feature)
+// =============================================================================
+
+/// Parse TOML into a HEDL document.
+///
+/// Tables become nested objects and arrays of tables become matrix lists,
+/// typed as `hedl_from_json` types arrays of objects: the key is singularized
+/// and capitalized for the struct name, so `[[users]]` becomes
+/// `users: @User`. Dates and times are kept as strings. Infinite and NaN
+/// floats have no JSON form and are rejected.
+///
+/// # Arguments
+/// * `toml` - UTF-8 encoded TOML string
+/// * `toml_len` - Length of input in bytes, or -1 for null-terminated
+/// * `out_doc` - Pointer to store document handle
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_TOML if the input is not valid TOML or
+/// cannot be converted, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid.
+///
+/// # Feature
+/// Requires the "toml" feature to be enabled.
+#[cfg(feature = "toml")]
+#[no_mangle]
+pub unsafe extern "C" fn hedl_from_toml(
+    toml: *const c_char,
+    toml_len: c_int,
+    out_doc: *mut *mut HedlDocument,
+) -> c_int {
+    use crate::audit::{
+        audit_call_failure, audit_call_start, audit_call_success, sanitize_pointer,
+    };
+    use std::time::Instant;
+
+    let start = Instant::now();
+    let toml_ptr_str = sanitize_pointer(toml);
+    let toml_len_str = toml_len.to_string();
+    audit_call_start(
+        "hedl_from_toml",
+        &[("toml_ptr", &toml_ptr_str), ("toml_len", &toml_len_str)],
+    );
+
+    clear_error();
+
+    if toml.is_null() || out_doc.is_null() {
+        let duration = start.elapsed();
+        set_error("Null pointer argument");
+        audit_call_failure(
+            "hedl_from_toml",
+            HEDL_ERR_NULL_PTR,
+            "NULL pointer",
+            duration,
+        );
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let toml_str = match get_input_string(toml, toml_len) {
+        Ok(s) => s,
+        Err(code) => {
+            let duration = start.elapsed();
+            let msg = crate::error::get_thread_local_error();
+            audit_call_failure("hedl_from_toml", code, &msg, duration);
+            return code;
+        }
+    };
+
+    let converted = toml_str
+        .parse::<toml::Table>()
+        .map_err(|e| e.to_string())
+        .and_then(|table| toml_to_json(toml::Value::Table(table)))
+        .and_then(|value| {
+            hedl_json::from_json_value(&value, &hedl_json::FromJsonConfig::default())
+                .map_err(|e| e.to_string())
+        });
+
+    match converted {
+        Ok(doc) => {
+            let handle = Box::new(HedlDocument::new(doc));
+            *out_doc = Box::into_raw(handle);
+            audit_call_success("hedl_from_toml", start.elapsed());
+            HEDL_OK
+        }
+        Err(e) => {
+            let duration = start.elapsed();
+            let msg = format!("TOML parse error: {}", e);
+            set_error(&msg);
+            *out_doc = ptr::null_mut();
+            audit_call_failure("hedl_from_toml", HEDL_ERR_TOML, &msg, duration);
+            HEDL_ERR_TOML
+        }
+    }
+}
+
+/// Convert a TOML value to the JSON value `hedl_from_json` would read.
+#[cfg(feature = "toml")]
+fn toml_to_json(value: toml::Value) -> Result<serde_json::Value, String> {
+    use serde_json::Value as Json;
+
+    Ok(match value {
+        toml::Value::String(s) => Json::String(s),
+        toml::Value::Integer(n) => Json::from(n),
+        toml::Value::Float(f) => serde_json::Number::from_f64(f)
+            .map(Json::Number)
+            .ok_or_else(|| format!("float {} has no JSON form", f))?,
+        toml::Value::Boolean(b) => Json::Bool(b),
+        toml::Value::Datetime(dt) => Json::String(dt.to_string()),
+        toml::Value::Array(items) => Json::Array(
+            items
+                .into_iter()
+                .map(toml_to_json)
+                .collect::<Result<_, _>>()?,
+        ),
+        toml::Value::Table(table) => Json::Object(
+            table
+                .into_iter()
+                .map(|(key, value)| Ok((key, toml_to_json(value)?)))
+                .collect::<Result<_, String>>()?,
+        ),
+    })
+}
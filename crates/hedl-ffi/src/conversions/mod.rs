@@ -17,6 +17,7 @@
 
 //! Conversion functions for FFI.
 
+pub mod csv_cursor;
 pub mod from_formats;
 pub mod to_formats;
 pub mod to_formats_callback;
@@ -20,13 +20,34 @@
 use crate::audit::{
     audit_call_failure, audit_call_start, audit_call_success, sanitize_pointer,
 };
+#[cfg(feature = "json")]
+use crate::checks::schema_arg;
+use crate::checks::{value_type, visit_indexed_rows, visit_lists};
+use crate::conversions::csv_cursor::{csv_field, value_text};
 use crate::error::{clear_error, set_error};
 use crate::memory::is_valid_document_ptr;
+use crate::operations::c_str_arg;
 use crate::types::{
-    HedlDocument, HEDL_ERR_CSV, HEDL_ERR_JSON, HEDL_ERR_NEO4J, HEDL_ERR_NULL_PTR,
-    HEDL_ERR_PARQUET, HEDL_ERR_XML, HEDL_ERR_YAML, HEDL_OK,
+    HedlDocument, HEDL_ERR_ALLOC, HEDL_ERR_CSV, HEDL_ERR_INVALID_ARGUMENT, HEDL_ERR_JSON,
+    HEDL_ERR_NEO4J, HEDL_ERR_NOT_FOUND, HEDL_ERR_NULL_PTR, HEDL_ERR_PARQUET, HEDL_ERR_SQLITE,
+    HEDL_ERR_XML, HEDL_ERR_YAML, HEDL_OK,
 };
+#[cfg(feature = "json")]
+use crate::types::{HEDL_ERR_CONFLICT, HEDL_ERR_INVALID_UTF8};
 use crate::utils::allocate_output_string;
+use chrono::{DateTime, NaiveDate};
+use flate2::write::DeflateEncoder;
+use flate2::{Compression, Crc};
+#[cfg(feature = "json")]
+use hedl_core::MatrixList;
+#[cfg(feature = "toml")]
+use hedl_core::Tensor;
+use hedl_core::{Document, Item, Node, Value};
+use std::borrow::Cow;
+use std::collections::{BTreeMap, BTreeSet, HashMap, HashSet};
+#[cfg(feature = "json")]
+use std::ffi::CStr;
+use std::io::{self, Write};
 use std::os::raw::{c_char, c_int};
 use std::ptr;
 use std::time::Instant;
@@ -106,297 +127,488 @@ pub unsafe extern "C" fn hedl_to_json(
     }
 }
 
-// =============================================================================
-// YAML Conversion (requires "yaml" feature)
-// =============================================================================
-
-/// Convert a HEDL document to YAML.
+/// Convert the rows of one struct type to JSON, grouped by the value of a
+/// field.
+///
+/// The output is an object with one key per distinct value of `group_by`,
+/// in sorted order, each holding the array of rows with that value in
+/// document order, formatted as by `hedl_to_json` without metadata. Rows are
+/// collected from every list of the type, including nested ones, and keep
+/// their own nested children. Group keys are the HEDL text of the value, so
+/// a null value is grouped under "~".
 ///
 /// # Arguments
 /// * `doc` - Document handle from hedl_parse
-/// * `include_metadata` - Non-zero to include HEDL metadata
-/// * `out_str` - Pointer to store YAML output (must be freed with hedl_free_string)
+/// * `schema_name` - NUL-terminated name of the struct type to group
+/// * `group_by` - NUL-terminated name of the field to group by
+/// * `out_str` - Pointer to store JSON output (must be freed with hedl_free_string)
 ///
 /// # Returns
-/// HEDL_OK on success, error code on failure.
+/// HEDL_OK on success, error code on failure. HEDL_ERR_NOT_FOUND is returned
+/// when the type or field does not exist.
 ///
 /// # Safety
 /// All pointers must be valid.
 ///
 /// # Feature
-/// Requires the "yaml" feature to be enabled.
-#[cfg(feature = "yaml")]
+/// Requires the "json" feature to be enabled.
+#[cfg(feature = "json")]
 #[no_mangle]
-pub unsafe extern "C" fn hedl_to_yaml(
+pub unsafe extern "C" fn hedl_to_grouped_json(
     doc: *const HedlDocument,
-    include_metadata: c_int,
+    schema_name: *const c_char,
+    group_by: *const c_char,
     out_str: *mut *mut c_char,
 ) -> c_int {
+    const FUNC: &str = "hedl_to_grouped_json";
     let start = Instant::now();
 
     audit_call_start(
-        "hedl_to_yaml",
+        FUNC,
         &[
             ("doc", &sanitize_pointer(doc)),
-            ("include_metadata", &include_metadata.to_string()),
+            ("schema_name", &sanitize_pointer(schema_name)),
+            ("group_by", &sanitize_pointer(group_by)),
             ("out_str", &sanitize_pointer(out_str)),
         ],
     );
 
     clear_error();
 
-    if !is_valid_document_ptr(doc) || out_str.is_null() {
-        let duration = start.elapsed();
+    if !is_valid_document_ptr(doc)
+        || schema_name.is_null()
+        || group_by.is_null()
+        || out_str.is_null()
+    {
         set_error("Null pointer argument");
-        audit_call_failure("hedl_to_yaml", HEDL_ERR_NULL_PTR, "Null pointer argument", duration);
+        audit_call_failure(
+            FUNC,
+            HEDL_ERR_NULL_PTR,
+            "Null pointer argument",
+            start.elapsed(),
+        );
         return HEDL_ERR_NULL_PTR;
     }
 
+    let (schema_name, group_by) = match (
+        CStr::from_ptr(schema_name).to_str(),
+        CStr::from_ptr(group_by).to_str(),
+    ) {
+        (Ok(schema_name), Ok(group_by)) => (schema_name, group_by),
+        _ => {
+            set_error("Invalid UTF-8 in argument");
+            *out_str = ptr::null_mut();
+            audit_call_failure(
+                FUNC,
+                HEDL_ERR_INVALID_UTF8,
+                "Invalid UTF-8 in argument",
+                start.elapsed(),
+            );
+            return HEDL_ERR_INVALID_UTF8;
+        }
+    };
+
     let doc_ref = &(*doc).inner;
-    let config = hedl_yaml::ToYamlConfig {
-        include_metadata: include_metadata != 0,
-        ..Default::default()
+    let Some(schema) = doc_ref.structs.get(schema_name) else {
+        let msg = format!("Unknown struct type: {}", schema_name);
+        set_error(&msg);
+        *out_str = ptr::null_mut();
+        audit_call_failure(FUNC, HEDL_ERR_NOT_FOUND, &msg, start.elapsed());
+        return HEDL_ERR_NOT_FOUND;
+    };
+    let Some(index) = schema.iter().position(|field| field == group_by) else {
+        let msg = format!("Unknown field {} in struct type {}", group_by, schema_name);
+        set_error(&msg);
+        *out_str = ptr::null_mut();
+        audit_call_failure(FUNC, HEDL_ERR_NOT_FOUND, &msg, start.elapsed());
+        return HEDL_ERR_NOT_FOUND;
     };
 
-    match hedl_yaml::to_yaml(doc_ref, &config) {
-        Ok(yaml) => {
-            let result = allocate_output_string(&yaml, out_str, HEDL_ERR_YAML);
-            if result == HEDL_OK {
-                audit_call_success("hedl_to_yaml", start.elapsed());
-            } else {
-                let duration = start.elapsed();
-                let msg = crate::error::get_thread_local_error();
-                audit_call_failure("hedl_to_yaml", result, &msg, duration);
-            }
-            result
-        }
-        Err(e) => {
-            let duration = start.elapsed();
-            let msg = format!("YAML conversion error: {}", e);
-            set_error(&msg);
-            *out_str = ptr::null_mut();
-            audit_call_failure("hedl_to_yaml", HEDL_ERR_YAML, &msg, duration);
-            HEDL_ERR_YAML
+    let mut rows = Vec::new();
+    collect_rows(&doc_ref.root, schema_name, &mut rows);
+
+    // Each group becomes a list of its own under the group key, so the
+    // regular JSON conversion renders the rows.
+    let mut root: BTreeMap<String, Item> = BTreeMap::new();
+    for row in rows {
+        let key = row
+            .fields
+            .get(index)
+            .map(Value::to_string)
+            .unwrap_or_default();
+        let entry = root
+            .entry(key)
+            .or_insert_with(|| Item::List(MatrixList::new(schema_name, schema.clone())));
+        if let Item::List(list) = entry {
+            list.add_row(row.clone());
         }
     }
-}
+    let grouped = with_root(doc_ref, root);
 
-// =============================================================================
-// XML Conversion (requires "xml" feature)
-// =============================================================================
+    json_output(FUNC, &grouped, out_str, start)
+}
 
-/// Convert a HEDL document to XML.
+/// Convert a page of the rows of one struct type to a JSON array.
+///
+/// Rows are counted across every list of the type in document order,
+/// including nested ones, and rows `[offset, offset + limit)` are written as
+/// by `hedl_to_json` without metadata, keeping their own nested children,
+/// in compact form. Negative values count as zero and the page is clamped
+/// to the available rows, so a page past the end is `[]`. Only the rows of
+/// the page are copied.
 ///
 /// # Arguments
 /// * `doc` - Document handle from hedl_parse
-/// * `out_str` - Pointer to store XML output (must be freed with hedl_free_string)
+/// * `schema_name` - NUL-terminated name of the struct type
+/// * `offset` - Index of the first row of the page
+/// * `limit` - Maximum number of rows in the page
+/// * `out_str` - Pointer to store JSON output (must be freed with hedl_free_string)
 ///
 /// # Returns
-/// HEDL_OK on success, error code on failure.
+/// HEDL_OK on success, error code on failure. HEDL_ERR_NOT_FOUND is returned
+/// when the type does not exist.
 ///
 /// # Safety
 /// All pointers must be valid.
 ///
 /// # Feature
-/// Requires the "xml" feature to be enabled.
-#[cfg(feature = "xml")]
+/// Requires the "json" feature to be enabled.
+#[cfg(feature = "json")]
 #[no_mangle]
-pub unsafe extern "C" fn hedl_to_xml(doc: *const HedlDocument, out_str: *mut *mut c_char) -> c_int {
+pub unsafe extern "C" fn hedl_to_json_page(
+    doc: *const HedlDocument,
+    schema_name: *const c_char,
+    offset: c_int,
+    limit: c_int,
+    out_str: *mut *mut c_char,
+) -> c_int {
+    const FUNC: &str = "hedl_to_json_page";
     let start = Instant::now();
 
     audit_call_start(
-        "hedl_to_xml",
+        FUNC,
         &[
             ("doc", &sanitize_pointer(doc)),
+            ("schema_name", &sanitize_pointer(schema_name)),
+            ("offset", &offset.to_string()),
+            ("limit", &limit.to_string()),
             ("out_str", &sanitize_pointer(out_str)),
         ],
     );
 
     clear_error();
 
-    if !is_valid_document_ptr(doc) || out_str.is_null() {
-        let duration = start.elapsed();
+    if !is_valid_document_ptr(doc) || schema_name.is_null() || out_str.is_null() {
         set_error("Null pointer argument");
-        audit_call_failure("hedl_to_xml", HEDL_ERR_NULL_PTR, "Null pointer argument", duration);
+        audit_call_failure(
+            FUNC,
+            HEDL_ERR_NULL_PTR,
+            "Null pointer argument",
+            start.elapsed(),
+        );
         return HEDL_ERR_NULL_PTR;
     }
+    *out_str = ptr::null_mut();
+
+    let Ok(schema_name) = CStr::from_ptr(schema_name).to_str() else {
+        set_error("Invalid UTF-8 in argument");
+        audit_call_failure(
+            FUNC,
+            HEDL_ERR_INVALID_UTF8,
+            "Invalid UTF-8 in argument",
+            start.elapsed(),
+        );
+        return HEDL_ERR_INVALID_UTF8;
+    };
 
     let doc_ref = &(*doc).inner;
+    let Some(schema) = doc_ref.structs.get(schema_name) else {
+        let msg = format!("Unknown struct type: {}", schema_name);
+        set_error(&msg);
+        audit_call_failure(FUNC, HEDL_ERR_NOT_FOUND, &msg, start.elapsed());
+        return HEDL_ERR_NOT_FOUND;
+    };
 
-    match hedl_xml::hedl_to_xml(doc_ref) {
-        Ok(xml) => {
-            let result = allocate_output_string(&xml, out_str, HEDL_ERR_XML);
-            if result == HEDL_OK {
-                audit_call_success("hedl_to_xml", start.elapsed());
-            } else {
-                let duration = start.elapsed();
-                let msg = crate::error::get_thread_local_error();
-                audit_call_failure("hedl_to_xml", result, &msg, duration);
-            }
-            result
-        }
-        Err(e) => {
-            let duration = start.elapsed();
-            let msg = format!("XML conversion error: {}", e);
-            set_error(&msg);
-            *out_str = ptr::null_mut();
-            audit_call_failure("hedl_to_xml", HEDL_ERR_XML, &msg, duration);
-            HEDL_ERR_XML
-        }
-    }
-}
+    let mut rows = Vec::new();
+    collect_rows(&doc_ref.root, schema_name, &mut rows);
+    let first = (offset.max(0) as usize).min(rows.len());
+    let end = first + (limit.max(0) as usize).min(rows.len() - first);
 
-// =============================================================================
-// CSV Conversion (requires "csv" feature)
-// =============================================================================
+    let page = rows_to_json(doc_ref, schema_name, schema, &rows[first..end]);
+    compact_json_output(FUNC, page, out_str, start)
+}
 
-/// Convert a HEDL document to CSV.
+/// Look up the row of one struct type whose key field has a given value and
+/// convert it to a JSON object.
 ///
-/// Note: Only works for documents with matrix lists.
+/// The row is written as by `hedl_to_json` without metadata, keeping its
+/// nested children, in compact form. Values are compared by their plain
+/// text, so strings are unquoted and the integer 7 matches "7"; rows with a
+/// null key never match. Rows are searched in every list of the type in
+/// document order, including nested ones.
 ///
 /// # Arguments
 /// * `doc` - Document handle from hedl_parse
-/// * `out_str` - Pointer to store CSV output (must be freed with hedl_free_string)
+/// * `schema_name` - NUL-terminated name of the struct type
+/// * `key_field` - NUL-terminated name of the field to match
+/// * `key_value` - NUL-terminated value to look up
+/// * `first_wins` - Non-zero to return the first of several matching rows
+/// * `out_str` - Pointer to store JSON output (must be freed with hedl_free_string)
 ///
 /// # Returns
-/// HEDL_OK on success, error code on failure.
+/// HEDL_OK on success, error code on failure. HEDL_ERR_NOT_FOUND is returned
+/// when the type or field does not exist or no row matches, and
+/// HEDL_ERR_CONFLICT when several rows match and `first_wins` is zero.
 ///
 /// # Safety
 /// All pointers must be valid.
 ///
 /// # Feature
-/// Requires the "csv" feature to be enabled.
-#[cfg(feature = "csv")]
+/// Requires the "json" feature to be enabled.
+#[cfg(feature = "json")]
 #[no_mangle]
-pub unsafe extern "C" fn hedl_to_csv(doc: *const HedlDocument, out_str: *mut *mut c_char) -> c_int {
+pub unsafe extern "C" fn hedl_get_by_key(
+    doc: *const HedlDocument,
+    schema_name: *const c_char,
+    key_field: *const c_char,
+    key_value: *const c_char,
+    first_wins: c_int,
+    out_str: *mut *mut c_char,
+) -> c_int {
+    const FUNC: &str = "hedl_get_by_key";
     let start = Instant::now();
 
     audit_call_start(
-        "hedl_to_csv",
+        FUNC,
         &[
             ("doc", &sanitize_pointer(doc)),
+            ("schema_name", &sanitize_pointer(schema_name)),
+            ("key_field", &sanitize_pointer(key_field)),
+            ("key_value", &sanitize_pointer(key_value)),
+            ("first_wins", &first_wins.to_string()),
             ("out_str", &sanitize_pointer(out_str)),
         ],
     );
 
     clear_error();
 
-    if !is_valid_document_ptr(doc) || out_str.is_null() {
-        let duration = start.elapsed();
+    if !is_valid_document_ptr(doc)
+        || schema_name.is_null()
+        || key_field.is_null()
+        || key_value.is_null()
+        || out_str.is_null()
+    {
         set_error("Null pointer argument");
-        audit_call_failure("hedl_to_csv", HEDL_ERR_NULL_PTR, "Null pointer argument", duration);
+        audit_call_failure(
+            FUNC,
+            HEDL_ERR_NULL_PTR,
+            "Null pointer argument",
+            start.elapsed(),
+        );
         return HEDL_ERR_NULL_PTR;
     }
+    *out_str = ptr::null_mut();
+
+    let (schema_name, key_field, key_value) = match (
+        CStr::from_ptr(schema_name).to_str(),
+        CStr::from_ptr(key_field).to_str(),
+        CStr::from_ptr(key_value).to_str(),
+    ) {
+        (Ok(schema_name), Ok(key_field), Ok(key_value)) => (schema_name, key_field, key_value),
+        _ => {
+            set_error("Invalid UTF-8 in argument");
+            audit_call_failure(
+                FUNC,
+                HEDL_ERR_INVALID_UTF8,
+                "Invalid UTF-8 in argument",
+                start.elapsed(),
+            );
+            return HEDL_ERR_INVALID_UTF8;
+        }
+    };
 
     let doc_ref = &(*doc).inner;
+    let Some(schema) = doc_ref.structs.get(schema_name) else {
+        let msg = format!("Unknown struct type: {}", schema_name);
+        set_error(&msg);
+        audit_call_failure(FUNC, HEDL_ERR_NOT_FOUND, &msg, start.elapsed());
+        return HEDL_ERR_NOT_FOUND;
+    };
+    let Some(index) = schema.iter().position(|field| field == key_field) else {
+        let msg = format!("Unknown field {} in struct type {}", key_field, schema_name);
+        set_error(&msg);
+        audit_call_failure(FUNC, HEDL_ERR_NOT_FOUND, &msg, start.elapsed());
+        return HEDL_ERR_NOT_FOUND;
+    };
 
-    match hedl_csv::to_csv(doc_ref) {
-        Ok(csv) => {
-            let result = allocate_output_string(&csv, out_str, HEDL_ERR_CSV);
-            if result == HEDL_OK {
-                audit_call_success("hedl_to_csv", start.elapsed());
-            } else {
-                let duration = start.elapsed();
-                let msg = crate::error::get_thread_local_error();
-                audit_call_failure("hedl_to_csv", result, &msg, duration);
-            }
-            result
-        }
-        Err(e) => {
-            let duration = start.elapsed();
-            let msg = format!("CSV conversion error: {}", e);
-            set_error(&msg);
-            *out_str = ptr::null_mut();
-            audit_call_failure("hedl_to_csv", HEDL_ERR_CSV, &msg, duration);
-            HEDL_ERR_CSV
+    let mut rows = Vec::new();
+    collect_rows(&doc_ref.root, schema_name, &mut rows);
+    let mut matches = rows.into_iter().filter(|row| match row.fields.get(index) {
+        Some(Value::String(s)) => s == key_value,
+        Some(Value::Null) | None => false,
+        Some(value) => value.to_string() == key_value,
+    });
+
+    let (code, what) = match (matches.next(), matches.next()) {
+        (Some(row), second) if second.is_none() || first_wins != 0 => {
+            let row = rows_to_json(doc_ref, schema_name, schema, &[row]).map(|mut rows| {
+                rows.get_mut(0)
+                    .map(serde_json::Value::take)
+                    .unwrap_or_default()
+            });
+            return compact_json_output(FUNC, row, out_str, start);
         }
-    }
+        (Some(_), _) => (HEDL_ERR_CONFLICT, "Several"),
+        (None, _) => (HEDL_ERR_NOT_FOUND, "No"),
+    };
+    let msg = format!(
+        "{} {} rows have {} {:?}",
+        what, schema_name, key_field, key_value
+    );
+    set_error(&msg);
+    audit_call_failure(FUNC, code, &msg, start.elapsed());
+    code
 }
 
-// =============================================================================
-// Parquet Conversion (requires "parquet" feature)
-// =============================================================================
-
-/// Convert a HEDL document to Parquet bytes.
+/// Index the rows of one struct type by a key field, as a JSON object
+/// mapping each key to its row.
 ///
-/// Note: Only works for documents with matrix lists.
+/// Keys are the plain text of the key field, as in a CSV field, so the
+/// integer 7 and the string "7" share a key. Rows are written as by
+/// `hedl_get_by_key`. Rows with a null key, and rows of lists whose inline
+/// schema lacks the key field, are skipped. Rows are searched in every
+/// list of the type in document order, including nested ones.
 ///
 /// # Arguments
 /// * `doc` - Document handle from hedl_parse
-/// * `out_data` - Pointer to store output data pointer
-/// * `out_len` - Pointer to store output length
+/// * `schema_name` - NUL-terminated name of the struct type
+/// * `key_field` - NUL-terminated name of the field to index by
+/// * `allow_duplicates` - Non-zero to keep the last of several rows with a key
+/// * `out_str` - Pointer to store JSON output (must be freed with hedl_free_string)
 ///
 /// # Returns
-/// HEDL_OK on success, error code on failure.
-/// The output data must be freed with hedl_free_bytes.
+/// HEDL_OK on success, error code on failure. HEDL_ERR_NOT_FOUND is returned
+/// when the type or field does not exist, and HEDL_ERR_CONFLICT when several
+/// rows share a key and `allow_duplicates` is zero.
 ///
 /// # Safety
 /// All pointers must be valid.
 ///
 /// # Feature
-/// Requires the "parquet" feature to be enabled.
-#[cfg(feature = "parquet")]
+/// Requires the "json" feature to be enabled.
+#[cfg(feature = "json")]
 #[no_mangle]
-pub unsafe extern "C" fn hedl_to_parquet(
+pub unsafe extern "C" fn hedl_index_by(
     doc: *const HedlDocument,
-    out_data: *mut *mut u8,
-    out_len: *mut usize,
+    schema_name: *const c_char,
+    key_field: *const c_char,
+    allow_duplicates: c_int,
+    out_str: *mut *mut c_char,
 ) -> c_int {
+    const FUNC: &str = "hedl_index_by";
     let start = Instant::now();
 
     audit_call_start(
-        "hedl_to_parquet",
+        FUNC,
         &[
             ("doc", &sanitize_pointer(doc)),
-            ("out_data", &sanitize_pointer(out_data)),
-            ("out_len", &sanitize_pointer(out_len)),
+            ("schema_name", &sanitize_pointer(schema_name)),
+            ("key_field", &sanitize_pointer(key_field)),
+            ("allow_duplicates", &allow_duplicates.to_string()),
+            ("out_str", &sanitize_pointer(out_str)),
         ],
     );
 
     clear_error();
 
-    if !is_valid_document_ptr(doc) || out_data.is_null() || out_len.is_null() {
-        let duration = start.elapsed();
+    if !is_valid_document_ptr(doc) || key_field.is_null() || out_str.is_null() {
         set_error("Null pointer argument");
-        audit_call_failure("hedl_to_parquet", HEDL_ERR_NULL_PTR, "Null pointer argument", duration);
+        audit_call_failure(
+            FUNC,
+            HEDL_ERR_NULL_PTR,
+            "Null pointer argument",
+            start.elapsed(),
+        );
         return HEDL_ERR_NULL_PTR;
     }
+    *out_str = ptr::null_mut();
 
     let doc_ref = &(*doc).inner;
+    let (schema_name, columns) = match schema_arg(FUNC, doc_ref, schema_name, start) {
+        Ok(schema) => schema,
+        Err(code) => return code,
+    };
+    let key_field = match c_str_arg(key_field) {
+        Ok(key_field) => key_field,
+        Err(code) => {
+            audit_call_failure(FUNC, code, "Invalid field argument", start.elapsed());
+            return code;
+        }
+    };
+    if !columns.iter().any(|column| column == key_field) {
+        let msg = format!("Unknown field {} in type {}", key_field, schema_name);
+        set_error(&msg);
+        audit_call_failure(FUNC, HEDL_ERR_NOT_FOUND, &msg, start.elapsed());
+        return HEDL_ERR_NOT_FOUND;
+    }
 
-    match hedl_parquet::to_parquet_bytes(doc_ref) {
-        Ok(bytes) => {
-            let len = bytes.len();
-            let ptr = Box::into_raw(bytes.into_boxed_slice()) as *mut u8;
-            *out_data = ptr;
-            *out_len = len;
-            audit_call_success("hedl_to_parquet", start.elapsed());
-            HEDL_OK
+    let mut rows: HashMap<String, (&[String], &Node)> = HashMap::new();
+    let mut duplicate = None;
+    visit_indexed_rows(doc_ref, &mut |schema, _, row| {
+        if row.type_name != schema_name {
+            return;
         }
-        Err(e) => {
-            let duration = start.elapsed();
-            let msg = format!("Parquet conversion error: {}", e);
-            set_error(&msg);
-            *out_data = ptr::null_mut();
-            *out_len = 0;
-            audit_call_failure("hedl_to_parquet", HEDL_ERR_PARQUET, &msg, duration);
-            HEDL_ERR_PARQUET
+        let Some(col) = schema.iter().position(|column| column == key_field) else {
+            return;
+        };
+        let key = match row.fields.get(col) {
+            None | Some(Value::Null) => return,
+            Some(value) => value_text(value).into_owned(),
+        };
+        if rows.insert(key.clone(), (schema, row)).is_some() && duplicate.is_none() {
+            duplicate = Some(key);
         }
+    });
+    if let (Some(key), 0) = (duplicate, allow_duplicates) {
+        let msg = format!("Several {} rows have {} {:?}", schema_name, key_field, key);
+        set_error(&msg);
+        audit_call_failure(FUNC, HEDL_ERR_CONFLICT, &msg, start.elapsed());
+        return HEDL_ERR_CONFLICT;
     }
-}
 
-// =============================================================================
-// Neo4j/Cypher Conversion (requires "neo4j" feature)
-// =============================================================================
+    // Rows of lists with the same schema are rendered together.
+    let mut groups: Vec<(&[String], Vec<(String, &Node)>)> = Vec::new();
+    for (key, (schema, row)) in rows {
+        match groups.iter_mut().find(|(s, _)| *s == schema) {
+            Some((_, group)) => group.push((key, row)),
+            None => groups.push((schema, vec![(key, row)])),
+        }
+    }
+    let index = groups
+        .into_iter()
+        .try_fold(serde_json::Map::new(), |mut index, (schema, group)| {
+            let (keys, rows): (Vec<String>, Vec<&Node>) = group.into_iter().unzip();
+            let mut json = rows_to_json(doc_ref, schema_name, schema, &rows)?;
+            let json = json.as_array_mut().map(std::mem::take).unwrap_or_default();
+            index.extend(keys.into_iter().zip(json));
+            Ok(index)
+        })
+        .map(serde_json::Value::Object);
+    compact_json_output(FUNC, index, out_str, start)
+}
 
-/// Convert a HEDL document to Cypher queries for Neo4j.
+/// Generate an example JSON document with one object per struct type.
 ///
-/// Generates CREATE/MERGE statements, constraints, and relationships.
+/// The output is an object keyed by struct type name, in sorted order, each
+/// holding one example row with every declared field, formatted as by
+/// `hedl_to_json` without metadata and without nested children. A field
+/// takes its value from the first row of the type where it is not null;
+/// fields that are null in every row, and all fields of a type without
+/// rows, get the string "example".
 ///
 /// # Arguments
 /// * `doc` - Document handle from hedl_parse
-/// * `use_merge` - Non-zero to use MERGE (idempotent), zero for CREATE
-/// * `out_str` - Pointer to store Cypher output (must be freed with hedl_free_string)
+/// * `out_str` - Pointer to store JSON output (must be freed with hedl_free_string)
 ///
 /// # Returns
 /// HEDL_OK on success, error code on failure.
@@ -405,21 +617,20 @@ pub unsafe extern "C" fn hedl_to_parquet(
 /// All pointers must be valid.
 ///
 /// # Feature
-/// Requires the "neo4j" feature to be enabled.
-#[cfg(feature = "neo4j")]
+/// Requires the "json" feature to be enabled.
+#[cfg(feature = "json")]
 #[no_mangle]
-pub unsafe extern "C" fn hedl_to_neo4j_cypher(
+pub unsafe extern "C" fn hedl_example_json(
     doc: *const HedlDocument,
-    use_merge: c_int,
     out_str: *mut *mut c_char,
 ) -> c_int {
+    const FUNC: &str = "hedl_example_json";
     let start = Instant::now();
 
     audit_call_start(
-        "hedl_to_neo4j_cypher",
+        FUNC,
         &[
             ("doc", &sanitize_pointer(doc)),
-            ("use_merge", &use_merge.to_string()),
             ("out_str", &sanitize_pointer(out_str)),
         ],
     );
@@ -427,38 +638,1904 @@ pub unsafe extern "C" fn hedl_to_neo4j_cypher(
     clear_error();
 
     if !is_valid_document_ptr(doc) || out_str.is_null() {
-        let duration = start.elapsed();
-        set_error("Null pointer argument");
-        audit_call_failure("hedl_to_neo4j_cypher", HEDL_ERR_NULL_PTR, "Null pointer argument", duration);
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
         return HEDL_ERR_NULL_PTR;
     }
 
     let doc_ref = &(*doc).inner;
-    let config = if use_merge != 0 {
-        hedl_neo4j::ToCypherConfig::default()
-    } else {
-        hedl_neo4j::ToCypherConfig::new().with_create()
+    let mut root: BTreeMap<String, Item> = BTreeMap::new();
+    for (type_name, schema) in &doc_ref.structs {
+        let mut rows = Vec::new();
+        collect_rows(&doc_ref.root, type_name, &mut rows);
+
+        let fields = (0..schema.len())
+            .map(|i| {
+                rows.iter()
+                    .filter_map(|row| row.fields.get(i))
+                    .find(|value| !value.is_null())
+                    .cloned()
+                    .unwrap_or_else(|| Value::String("example".to_string()))
+            })
+            .collect();
+        let id = rows.first().map(|row| row.id.as_str()).unwrap_or_default();
+        let example = Node::new(type_name.as_str(), id, fields);
+        root.insert(
+            type_name.clone(),
+            Item::List(MatrixList::with_rows(
+                type_name,
+                schema.clone(),
+                vec![example],
+            )),
+        );
+    }
+    let examples = with_root(doc_ref, root);
+
+    // Each type is rendered as a one-row list; unwrap the row from its array.
+    let config = hedl_json::ToJsonConfig::default();
+    let mut value = match hedl_json::to_json_value(&examples, &config) {
+        Ok(value) => value,
+        Err(e) => {
+            let msg = format!("JSON conversion error: {}", e);
+            set_error(&msg);
+            *out_str = ptr::null_mut();
+            audit_call_failure(FUNC, HEDL_ERR_JSON, &msg, start.elapsed());
+            return HEDL_ERR_JSON;
+        }
     };
+    if let Some(types) = value.as_object_mut() {
+        for example in types.values_mut() {
+            if let Some(row) = example.as_array_mut().and_then(|rows| rows.pop()) {
+                *example = row;
+            }
+        }
+    }
+    let result = allocate_output_string(&format!("{:#}", value), out_str, HEDL_ERR_JSON);
+    if result != HEDL_OK {
+        audit_call_failure(FUNC, result, "Allocation failed", start.elapsed());
+        return result;
+    }
+    audit_call_success(FUNC, start.elapsed());
+    HEDL_OK
+}
 
-    match hedl_neo4j::to_cypher(doc_ref, &config) {
-        Ok(cypher) => {
-            let result = allocate_output_string(&cypher.to_string(), out_str, HEDL_ERR_NEO4J);
+/// Build a document with the header of `doc` (version, aliases, structs and
+/// nests) around `root`, without copying the body of `doc`.
+#[cfg(feature = "json")]
+fn with_root(doc: &Document, root: BTreeMap<String, Item>) -> Document {
+    Document {
+        version: doc.version,
+        aliases: doc.aliases.clone(),
+        structs: doc.structs.clone(),
+        nests: doc.nests.clone(),
+        root,
+    }
+}
+
+/// Convert `doc` to JSON without metadata into `out_str`, recording the
+/// outcome of `func` in the audit log.
+#[cfg(feature = "json")]
+unsafe fn json_output(
+    func: &'static str,
+    doc: &Document,
+    out_str: *mut *mut c_char,
+    start: Instant,
+) -> c_int {
+    match hedl_json::to_json(doc, &hedl_json::ToJsonConfig::default()) {
+        Ok(json) => {
+            let result = allocate_output_string(&json, out_str, HEDL_ERR_JSON);
             if result == HEDL_OK {
-                audit_call_success("hedl_to_neo4j_cypher", start.elapsed());
+                audit_call_success(func, start.elapsed());
             } else {
-                let duration = start.elapsed();
                 let msg = crate::error::get_thread_local_error();
-                audit_call_failure("hedl_to_neo4j_cypher", result, &msg, duration);
+                audit_call_failure(func, result, &msg, start.elapsed());
             }
             result
         }
         Err(e) => {
-            let duration = start.elapsed();
-            let msg = format!("Neo4j conversion error: {}", e);
+            let msg = format!("JSON conversion error: {}", e);
             set_error(&msg);
             *out_str = ptr::null_mut();
-            audit_call_failure("hedl_to_neo4j_cypher", HEDL_ERR_NEO4J, &msg, duration);
-            HEDL_ERR_NEO4J
+            audit_call_failure(func, HEDL_ERR_JSON, &msg, start.elapsed());
+            HEDL_ERR_JSON
         }
     }
 }
+
+/// Render `rows` of struct type `schema_name` as a JSON array, as by
+/// `hedl_to_json` without metadata, copying only those rows.
+#[cfg(feature = "json")]
+fn rows_to_json(
+    doc: &Document,
+    schema_name: &str,
+    schema: &[String],
+    rows: &[&Node],
+) -> Result<serde_json::Value, String> {
+    // The rows become a list of their own, so the regular JSON conversion
+    // renders them; the array is then taken out of its wrapping object.
+    let rows = rows.iter().map(|&row| row.clone()).collect();
+    let mut root: BTreeMap<String, Item> = BTreeMap::new();
+    root.insert(
+        schema_name.to_string(),
+        Item::List(MatrixList::with_rows(schema_name, schema.to_vec(), rows)),
+    );
+    let mut value = hedl_json::to_json_value(&with_root(doc, root), &Default::default())?;
+    Ok(value
+        .get_mut(schema_name)
+        .map(serde_json::Value::take)
+        .unwrap_or_default())
+}
+
+/// Write `value` as compact JSON into `out_str`, recording the outcome of
+/// `func` in the audit log.
+#[cfg(feature = "json")]
+unsafe fn compact_json_output(
+    func: &'static str,
+    value: Result<serde_json::Value, String>,
+    out_str: *mut *mut c_char,
+    start: Instant,
+) -> c_int {
+    match value {
+        Ok(value) => {
+            let result = allocate_output_string(&value.to_string(), out_str, HEDL_ERR_JSON);
+            if result == HEDL_OK {
+                audit_call_success(func, start.elapsed());
+            } else {
+                let msg = crate::error::get_thread_local_error();
+                audit_call_failure(func, result, &msg, start.elapsed());
+            }
+            result
+        }
+        Err(e) => {
+            let msg = format!("JSON conversion error: {}", e);
+            set_error(&msg);
+            audit_call_failure(func, HEDL_ERR_JSON, &msg, start.elapsed());
+            HEDL_ERR_JSON
+        }
+    }
+}
+
+/// Collect the rows of `type_name` from every list under `items`, including
+/// lists nested in objects and rows nested under other rows.
+#[cfg(feature = "json")]
+fn collect_rows<'a>(items: &'a BTreeMap<String, Item>, type_name: &str, out: &mut Vec<&'a Node>) {
+    fn visit<'a>(nodes: &'a [Node], type_name: &str, out: &mut Vec<&'a Node>) {
+        for node in nodes {
+            if node.type_name == type_name {
+                out.push(node);
+            }
+            for children in node.children.values() {
+                visit(children, type_name, out);
+            }
+        }
+    }
+
+    for item in items.values() {
+        match item {
+            Item::List(list) => visit(&list.rows, type_name, out),
+            Item::Object(obj) => collect_rows(obj, type_name, out),
+            Item::Scalar(_) => {}
+        }
+    }
+}
+
+// =============================================================================
+// YAML Conversion (requires "yaml" feature)
+// =============================================================================
+
+/// Convert a HEDL document to YAML.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `include_metadata` - Non-zero to include HEDL metadata
+/// * `out_str` - Pointer to store YAML output (must be freed with hedl_free_string)
+///
+/// # Returns
+/// HEDL_OK on success, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid.
+///
+/// # Feature
+/// Requires the "yaml" feature to be enabled.
+#[cfg(feature = "yaml")]
+#[no_mangle]
+pub unsafe extern "C" fn hedl_to_yaml(
+    doc: *const HedlDocument,
+    include_metadata: c_int,
+    out_str: *mut *mut c_char,
+) -> c_int {
+    let start = Instant::now();
+
+    audit_call_start(
+        "hedl_to_yaml",
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("include_metadata", &include_metadata.to_string()),
+            ("out_str", &sanitize_pointer(out_str)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_str.is_null() {
+        let duration = start.elapsed();
+        set_error("Null pointer argument");
+        audit_call_failure("hedl_to_yaml", HEDL_ERR_NULL_PTR, "Null pointer argument", duration);
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let doc_ref = &(*doc).inner;
+    let config = hedl_yaml::ToYamlConfig {
+        include_metadata: include_metadata != 0,
+        ..Default::default()
+    };
+
+    match hedl_yaml::to_yaml(doc_ref, &config) {
+        Ok(yaml) => {
+            let result = allocate_output_string(&yaml, out_str, HEDL_ERR_YAML);
+            if result == HEDL_OK {
+                audit_call_success("hedl_to_yaml", start.elapsed());
+            } else {
+                let duration = start.elapsed();
+                let msg = crate::error::get_thread_local_error();
+                audit_call_failure("hedl_to_yaml", result, &msg, duration);
+            }
+            result
+        }
+        Err(e) => {
+            let duration = start.elapsed();
+            let msg = format!("YAML conversion error: {}", e);
+            set_error(&msg);
+            *out_str = ptr::null_mut();
+            audit_call_failure("hedl_to_yaml", HEDL_ERR_YAML, &msg, duration);
+            HEDL_ERR_YAML
+        }
+    }
+}
+
+/// Convert a HEDL document to a multi-document YAML stream, with one YAML
+/// document per root item.
+///
+/// Root items are taken in key order and converted without metadata, each
+/// ending with a newline; documents are separated by `---` lines. A
+/// document without root items yields an empty string.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `out_str` - Pointer to store YAML output (must be freed with hedl_free_string)
+///
+/// # Returns
+/// HEDL_OK on success, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid.
+///
+/// # Feature
+/// Requires the "yaml" feature to be enabled.
+#[cfg(feature = "yaml")]
+#[no_mangle]
+pub unsafe extern "C" fn hedl_to_yaml_multi(
+    doc: *const HedlDocument,
+    out_str: *mut *mut c_char,
+) -> c_int {
+    const FUNC: &str = "hedl_to_yaml_multi";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("out_str", &sanitize_pointer(out_str)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_str.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let doc_ref = &(*doc).inner;
+    let config = hedl_yaml::ToYamlConfig {
+        include_metadata: false,
+        ..Default::default()
+    };
+    let mut single = Document::new(doc_ref.version);
+    single.aliases = doc_ref.aliases.clone();
+    single.structs = doc_ref.structs.clone();
+    single.nests = doc_ref.nests.clone();
+
+    let mut parts = Vec::with_capacity(doc_ref.root.len());
+    for (key, item) in &doc_ref.root {
+        single.root = [(key.clone(), item.clone())].into_iter().collect();
+        match hedl_yaml::to_yaml(&single, &config) {
+            Ok(mut yaml) => {
+                if !yaml.ends_with('\n') {
+                    yaml.push('\n');
+                }
+                parts.push(yaml);
+            }
+            Err(e) => {
+                let msg = format!("YAML conversion error: {}", e);
+                set_error(&msg);
+                *out_str = ptr::null_mut();
+                audit_call_failure(FUNC, HEDL_ERR_YAML, &msg, start.elapsed());
+                return HEDL_ERR_YAML;
+            }
+        }
+    }
+
+    let result = allocate_output_string(&parts.join("---\n"), out_str, HEDL_ERR_YAML);
+    if result == HEDL_OK {
+        audit_call_success(FUNC, start.elapsed());
+    } else {
+        let msg = crate::error::get_thread_local_error();
+        audit_call_failure(FUNC, result, &msg, start.elapsed());
+    }
+    result
+}
+
+// =============================================================================
+// TOML Conversion (requires "toml" feature)
+// =============================================================================
+
+/// Convert a HEDL document to TOML.
+///
+/// Objects become tables and every matrix row becomes an entry in an array
+/// of tables named after its key, so `users: @User` rows are written as
+/// `[[users]]` blocks with their fields in schema order. Nested child rows
+/// are written as arrays of tables under their parent row, named after the
+/// child type. Within a table, key-value pairs come before subtables. TOML
+/// has no null, so null values are omitted; references and expressions are
+/// written as strings of their HEDL text and tensors as arrays.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `out_str` - Pointer to store TOML output (must be freed with hedl_free_string)
+///
+/// # Returns
+/// HEDL_OK on success, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid.
+///
+/// # Feature
+/// Requires the "toml" feature to be enabled.
+#[cfg(feature = "toml")]
+#[no_mangle]
+pub unsafe extern "C" fn hedl_to_toml(
+    doc: *const HedlDocument,
+    out_str: *mut *mut c_char,
+) -> c_int {
+    const FUNC: &str = "hedl_to_toml";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("out_str", &sanitize_pointer(out_str)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_str.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let doc_ref = &(*doc).inner;
+    let mut out = String::new();
+    toml_table(doc_ref, &[], &doc_ref.root, &mut out);
+
+    let result = allocate_output_string(out.trim_start_matches('\n'), out_str, HEDL_ERR_ALLOC);
+    if result == HEDL_OK {
+        audit_call_success(FUNC, start.elapsed());
+    } else {
+        audit_call_failure(FUNC, result, "Allocation failed", start.elapsed());
+    }
+    result
+}
+
+/// Write the pairs of the table at `path` followed by its subtables and
+/// arrays of tables.
+#[cfg(feature = "toml")]
+fn toml_table(doc: &Document, path: &[&str], items: &BTreeMap<String, Item>, out: &mut String) {
+    for (key, item) in items {
+        if let Item::Scalar(value) = item {
+            toml_pair(key, value, out);
+        }
+    }
+    for (key, item) in items {
+        let mut child = path.to_vec();
+        child.push(key);
+        match item {
+            Item::Scalar(_) => {}
+            Item::Object(obj) => {
+                out.push_str(&format!("\n[{}]\n", toml_path(&child)));
+                toml_table(doc, &child, obj, out);
+            }
+            Item::List(list) => toml_rows(doc, &child, &list.schema, &list.rows, out),
+        }
+    }
+}
+
+/// Write each of `rows` as an entry of the array of tables at `path`,
+/// followed by its nested child rows. Nested rows take the declared schema
+/// of their type.
+#[cfg(feature = "toml")]
+fn toml_rows(doc: &Document, path: &[&str], schema: &[String], rows: &[Node], out: &mut String) {
+    for row in rows {
+        out.push_str(&format!("\n[[{}]]\n", toml_path(path)));
+        for (field, value) in schema.iter().zip(&row.fields) {
+            toml_pair(field, value, out);
+        }
+        for (child_type, children) in &row.children {
+            let mut child = path.to_vec();
+            child.push(child_type);
+            let child_schema = doc
+                .structs
+                .get(child_type)
+                .map(Vec::as_slice)
+                .unwrap_or(&[]);
+            toml_rows(doc, &child, child_schema, children, out);
+        }
+    }
+}
+
+/// Write `key = value`, skipping nulls.
+#[cfg(feature = "toml")]
+fn toml_pair(key: &str, value: &Value, out: &mut String) {
+    let text = match value {
+        Value::Null => return,
+        Value::Bool(b) => b.to_string(),
+        Value::Int(n) => n.to_string(),
+        Value::Float(f) => toml_float(*f),
+        Value::String(s) => toml_string(s),
+        Value::Tensor(t) => toml_tensor(t),
+        value => toml_string(&value.to_string()),
+    };
+    out.push_str(&format!("{} = {}\n", toml_key(key), text));
+}
+
+/// Format a float so TOML reads it back as a float.
+#[cfg(feature = "toml")]
+fn toml_float(f: f64) -> String {
+    if f.is_nan() {
+        "nan".to_string()
+    } else if f.is_infinite() {
+        if f > 0.0 { "inf" } else { "-inf" }.to_string()
+    } else if f.fract() == 0.0 {
+        format!("{:.1}", f)
+    } else {
+        f.to_string()
+    }
+}
+
+/// Format a tensor as a TOML array, with whole numbers as integers as in
+/// HEDL tensor literals.
+#[cfg(feature = "toml")]
+fn toml_tensor(tensor: &Tensor) -> String {
+    match tensor {
+        Tensor::Scalar(n) if n.fract() == 0.0 && n.abs() < i64::MAX as f64 => {
+            (*n as i64).to_string()
+        }
+        Tensor::Scalar(n) => toml_float(*n),
+        Tensor::Array(items) => {
+            let items: Vec<String> = items.iter().map(toml_tensor).collect();
+            format!("[{}]", items.join(", "))
+        }
+    }
+}
+
+/// Quote `s` as a TOML basic string.
+#[cfg(feature = "toml")]
+fn toml_string(s: &str) -> String {
+    let mut out = String::with_capacity(s.len() + 2);
+    out.push('"');
+    for c in s.chars() {
+        match c {
+            '"' => out.push_str("\\\""),
+            '\\' => out.push_str("\\\\"),
+            '\n' => out.push_str("\\n"),
+            '\t' => out.push_str("\\t"),
+            '\r' => out.push_str("\\r"),
+            c if c < '\u{20}' || c == '\u{7f}' => out.push_str(&format!("\\u{:04X}", c as u32)),
+            c => out.push(c),
+        }
+    }
+    out.push('"');
+    out
+}
+
+/// Write `key` bare when TOML allows it and quoted otherwise.
+#[cfg(feature = "toml")]
+fn toml_key(key: &str) -> Cow<'_, str> {
+    let bare = !key.is_empty()
+        && key
+            .chars()
+            .all(|c| c.is_ascii_alphanumeric() || c == '_' || c == '-');
+    if bare {
+        Cow::Borrowed(key)
+    } else {
+        Cow::Owned(toml_string(key))
+    }
+}
+
+/// Join the keys of `path` with dots, quoting them as needed.
+#[cfg(feature = "toml")]
+fn toml_path(path: &[&str]) -> String {
+    let keys: Vec<Cow<str>> = path.iter().map(|key| toml_key(key)).collect();
+    keys.join(".")
+}
+
+// =============================================================================
+// XML Conversion (requires "xml" feature)
+// =============================================================================
+
+/// Convert a HEDL document to XML.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `out_str` - Pointer to store XML output (must be freed with hedl_free_string)
+///
+/// # Returns
+/// HEDL_OK on success, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid.
+///
+/// # Feature
+/// Requires the "xml" feature to be enabled.
+#[cfg(feature = "xml")]
+#[no_mangle]
+pub unsafe extern "C" fn hedl_to_xml(doc: *const HedlDocument, out_str: *mut *mut c_char) -> c_int {
+    let start = Instant::now();
+
+    audit_call_start(
+        "hedl_to_xml",
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("out_str", &sanitize_pointer(out_str)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_str.is_null() {
+        let duration = start.elapsed();
+        set_error("Null pointer argument");
+        audit_call_failure("hedl_to_xml", HEDL_ERR_NULL_PTR, "Null pointer argument", duration);
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let doc_ref = &(*doc).inner;
+
+    match hedl_xml::hedl_to_xml(doc_ref) {
+        Ok(xml) => {
+            let result = allocate_output_string(&xml, out_str, HEDL_ERR_XML);
+            if result == HEDL_OK {
+                audit_call_success("hedl_to_xml", start.elapsed());
+            } else {
+                let duration = start.elapsed();
+                let msg = crate::error::get_thread_local_error();
+                audit_call_failure("hedl_to_xml", result, &msg, duration);
+            }
+            result
+        }
+        Err(e) => {
+            let duration = start.elapsed();
+            let msg = format!("XML conversion error: {}", e);
+            set_error(&msg);
+            *out_str = ptr::null_mut();
+            audit_call_failure("hedl_to_xml", HEDL_ERR_XML, &msg, duration);
+            HEDL_ERR_XML
+        }
+    }
+}
+
+// =============================================================================
+// CSV Conversion (requires "csv" feature)
+// =============================================================================
+
+/// Convert a HEDL document to CSV.
+///
+/// Note: Only works for documents with matrix lists.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `out_str` - Pointer to store CSV output (must be freed with hedl_free_string)
+///
+/// # Returns
+/// HEDL_OK on success, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid.
+///
+/// # Feature
+/// Requires the "csv" feature to be enabled.
+#[cfg(feature = "csv")]
+#[no_mangle]
+pub unsafe extern "C" fn hedl_to_csv(doc: *const HedlDocument, out_str: *mut *mut c_char) -> c_int {
+    let start = Instant::now();
+
+    audit_call_start(
+        "hedl_to_csv",
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("out_str", &sanitize_pointer(out_str)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_str.is_null() {
+        let duration = start.elapsed();
+        set_error("Null pointer argument");
+        audit_call_failure("hedl_to_csv", HEDL_ERR_NULL_PTR, "Null pointer argument", duration);
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let doc_ref = &(*doc).inner;
+
+    match hedl_csv::to_csv(doc_ref) {
+        Ok(csv) => {
+            let result = allocate_output_string(&csv, out_str, HEDL_ERR_CSV);
+            if result == HEDL_OK {
+                audit_call_success("hedl_to_csv", start.elapsed());
+            } else {
+                let duration = start.elapsed();
+                let msg = crate::error::get_thread_local_error();
+                audit_call_failure("hedl_to_csv", result, &msg, duration);
+            }
+            result
+        }
+        Err(e) => {
+            let duration = start.elapsed();
+            let msg = format!("CSV conversion error: {}", e);
+            set_error(&msg);
+            *out_str = ptr::null_mut();
+            audit_call_failure("hedl_to_csv", HEDL_ERR_CSV, &msg, duration);
+            HEDL_ERR_CSV
+        }
+    }
+}
+
+// =============================================================================
+// CSV Archive Conversion
+// =============================================================================
+
+/// Convert a HEDL document to a zip archive holding one CSV file per struct
+/// type.
+///
+/// Each type becomes a deflated entry named `<Type>.csv`. Declared types
+/// come first in name order, followed by types only used with inline schemas
+/// in document order. Each file starts with a header row of the type's
+/// fields, followed by every row of the type, including rows nested under
+/// other rows, in document order. Records are written as by
+/// `hedl_csv_cursor_next`; fields missing from a list's inline schema are
+/// empty.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `out_data` - Pointer to store output data pointer
+/// * `out_len` - Pointer to store output length
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_CSV if the archive exceeds the limits of the
+/// zip format, error code on failure.
+/// The output data must be freed with hedl_free_bytes.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_to_csv_zip(
+    doc: *const HedlDocument,
+    out_data: *mut *mut u8,
+    out_len: *mut usize,
+) -> c_int {
+    const FUNC: &str = "hedl_to_csv_zip";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("out_data", &sanitize_pointer(out_data)),
+            ("out_len", &sanitize_pointer(out_len)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_data.is_null() || out_len.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+    *out_data = ptr::null_mut();
+    *out_len = 0;
+
+    let doc_ref = &(*doc).inner;
+    let types = struct_types(doc_ref);
+    let index: HashMap<&str, usize> = types
+        .iter()
+        .enumerate()
+        .map(|(i, (name, _))| (*name, i))
+        .collect();
+
+    let mut files: Vec<String> = types
+        .iter()
+        .map(|(_, columns)| {
+            let header: Vec<Cow<str>> = columns.iter().map(|field| csv_field(field)).collect();
+            header.join(",") + "\n"
+        })
+        .collect();
+    visit_indexed_rows(doc_ref, &mut |schema, _, row| {
+        let Some(&i) = index.get(row.type_name.as_str()) else {
+            return;
+        };
+        let record: Vec<Cow<str>> = types[i]
+            .1
+            .iter()
+            .map(|column| {
+                let position = schema.iter().position(|field| field == column);
+                match position.and_then(|p| row.fields.get(p)) {
+                    Some(value) => csv_field(&value_text(value)).into_owned().into(),
+                    None => Cow::Borrowed(""),
+                }
+            })
+            .collect();
+        files[i].push_str(&record.join(","));
+        files[i].push('\n');
+    });
+
+    let entries = types
+        .iter()
+        .zip(&files)
+        .map(|((name, _), file)| (format!("{}.csv", name), file.as_bytes()));
+    match zip_archive(entries) {
+        Ok(bytes) => {
+            *out_len = bytes.len();
+            *out_data = Box::into_raw(bytes.into_boxed_slice()) as *mut u8;
+            audit_call_success(FUNC, start.elapsed());
+            HEDL_OK
+        }
+        Err(e) => {
+            let msg = format!("CSV archive error: {}", e);
+            set_error(&msg);
+            audit_call_failure(FUNC, HEDL_ERR_CSV, &msg, start.elapsed());
+            HEDL_ERR_CSV
+        }
+    }
+}
+
+/// Write a zip archive of deflated `entries`, each a file name and its
+/// contents. Names are marked as UTF-8 and every entry is dated 1980-01-01,
+/// so the same entries always give the same archive.
+fn zip_archive<'a>(entries: impl Iterator<Item = (String, &'a [u8])>) -> io::Result<Vec<u8>> {
+    const VERSION: u16 = 20;
+    const UTF8_NAMES: u16 = 1 << 11;
+    const DEFLATED: u16 = 8;
+    const DATE_1980_01_01: u16 = (1 << 5) | 1;
+
+    fn too_large(what: &str) -> io::Error {
+        io::Error::new(
+            io::ErrorKind::InvalidInput,
+            format!("{} exceeds the limits of the zip format", what),
+        )
+    }
+    fn fits<T: TryFrom<usize>>(n: usize, what: &str) -> io::Result<T> {
+        T::try_from(n).map_err(|_| too_large(what))
+    }
+
+    let mut out = Vec::new();
+    let mut central = Vec::new();
+    let mut count = 0usize;
+    for (name, data) in entries {
+        let mut encoder = DeflateEncoder::new(Vec::new(), Compression::default());
+        encoder.write_all(data)?;
+        let compressed = encoder.finish()?;
+        let mut crc = Crc::new();
+        crc.update(data);
+
+        let offset: u32 = fits(out.len(), "archive")?;
+        let name_len: u16 = fits(name.len(), &name)?;
+        let mut fields = Vec::with_capacity(26);
+        fields.extend_from_slice(&VERSION.to_le_bytes());
+        fields.extend_from_slice(&UTF8_NAMES.to_le_bytes());
+        fields.extend_from_slice(&DEFLATED.to_le_bytes());
+        fields.extend_from_slice(&0u16.to_le_bytes());
+        fields.extend_from_slice(&DATE_1980_01_01.to_le_bytes());
+        fields.extend_from_slice(&crc.sum().to_le_bytes());
+        fields.extend_from_slice(&fits::<u32>(compressed.len(), &name)?.to_le_bytes());
+        fields.extend_from_slice(&fits::<u32>(data.len(), &name)?.to_le_bytes());
+        fields.extend_from_slice(&name_len.to_le_bytes());
+        fields.extend_from_slice(&0u16.to_le_bytes());
+
+        out.extend_from_slice(&0x0403_4b50u32.to_le_bytes());
+        out.extend_from_slice(&fields);
+        out.extend_from_slice(name.as_bytes());
+        out.extend_from_slice(&compressed);
+
+        // The central directory repeats the local header with the entry's
+        // version made by, comment length, disk, attributes and offset.
+        central.extend_from_slice(&0x0201_4b50u32.to_le_bytes());
+        central.extend_from_slice(&VERSION.to_le_bytes());
+        central.extend_from_slice(&fields);
+        central.extend_from_slice(&[0; 10]);
+        central.extend_from_slice(&offset.to_le_bytes());
+        central.extend_from_slice(name.as_bytes());
+        count += 1;
+    }
+
+    let count: u16 = fits(count, "number of entries")?;
+    let central_offset: u32 = fits(out.len(), "archive")?;
+    let central_len: u32 = fits(central.len(), "central directory")?;
+    out.extend_from_slice(&central);
+    out.extend_from_slice(&0x0605_4b50u32.to_le_bytes());
+    out.extend_from_slice(&[0; 4]);
+    out.extend_from_slice(&count.to_le_bytes());
+    out.extend_from_slice(&count.to_le_bytes());
+    out.extend_from_slice(&central_len.to_le_bytes());
+    out.extend_from_slice(&central_offset.to_le_bytes());
+    out.extend_from_slice(&0u16.to_le_bytes());
+    Ok(out)
+}
+
+// =============================================================================
+// Parquet Conversion (requires "parquet" feature)
+// =============================================================================
+
+/// Convert a HEDL document to Parquet bytes.
+///
+/// Note: Only works for documents with matrix lists.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `out_data` - Pointer to store output data pointer
+/// * `out_len` - Pointer to store output length
+///
+/// # Returns
+/// HEDL_OK on success, error code on failure.
+/// The output data must be freed with hedl_free_bytes.
+///
+/// # Safety
+/// All pointers must be valid.
+///
+/// # Feature
+/// Requires the "parquet" feature to be enabled.
+#[cfg(feature = "parquet")]
+#[no_mangle]
+pub unsafe extern "C" fn hedl_to_parquet(
+    doc: *const HedlDocument,
+    out_data: *mut *mut u8,
+    out_len: *mut usize,
+) -> c_int {
+    let start = Instant::now();
+
+    audit_call_start(
+        "hedl_to_parquet",
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("out_data", &sanitize_pointer(out_data)),
+            ("out_len", &sanitize_pointer(out_len)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_data.is_null() || out_len.is_null() {
+        let duration = start.elapsed();
+        set_error("Null pointer argument");
+        audit_call_failure("hedl_to_parquet", HEDL_ERR_NULL_PTR, "Null pointer argument", duration);
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let doc_ref = &(*doc).inner;
+
+    match hedl_parquet::to_parquet_bytes(doc_ref) {
+        Ok(bytes) => {
+            let len = bytes.len();
+            let ptr = Box::into_raw(bytes.into_boxed_slice()) as *mut u8;
+            *out_data = ptr;
+            *out_len = len;
+            audit_call_success("hedl_to_parquet", start.elapsed());
+            HEDL_OK
+        }
+        Err(e) => {
+            let duration = start.elapsed();
+            let msg = format!("Parquet conversion error: {}", e);
+            set_error(&msg);
+            *out_data = ptr::null_mut();
+            *out_len = 0;
+            audit_call_failure("hedl_to_parquet", HEDL_ERR_PARQUET, &msg, duration);
+            HEDL_ERR_PARQUET
+        }
+    }
+}
+
+// =============================================================================
+// SQLite Conversion (requires "sqlite" feature)
+// =============================================================================
+
+/// Convert a HEDL document to the bytes of a SQLite 3 database file.
+///
+/// The database is built in memory with SQLite and serialized. It holds one
+/// table per struct type, named after the type, with a column per field.
+/// Declared types come first in name order, followed by types that only have
+/// inline schemas. Every row of the type, including rows nested under other
+/// rows, becomes a table row in document order; fields missing from a list's
+/// inline schema are NULL.
+///
+/// Column types are inferred from the values: integers and booleans make an
+/// INTEGER column and floats a REAL column, and integers mixed with floats a
+/// REAL column. Columns with values of several other types, or no non-null
+/// values, are left untyped, and all others are TEXT. Booleans are stored as
+/// 0 and 1, and references, expressions and tensors as their HEDL text.
+/// Tables have no keys or indexes.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `out_data` - Pointer to store output data pointer
+/// * `out_len` - Pointer to store output length
+///
+/// # Returns
+/// HEDL_OK on success, error code on failure.
+/// The output data must be freed with hedl_free_bytes.
+///
+/// # Safety
+/// All pointers must be valid.
+///
+/// # Feature
+/// Requires the "sqlite" feature to be enabled.
+#[cfg(feature = "sqlite")]
+#[no_mangle]
+pub unsafe extern "C" fn hedl_to_sqlite(
+    doc: *const HedlDocument,
+    out_data: *mut *mut u8,
+    out_len: *mut usize,
+) -> c_int {
+    let start = Instant::now();
+
+    audit_call_start(
+        "hedl_to_sqlite",
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("out_data", &sanitize_pointer(out_data)),
+            ("out_len", &sanitize_pointer(out_len)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_data.is_null() || out_len.is_null() {
+        let duration = start.elapsed();
+        set_error("Null pointer argument");
+        audit_call_failure(
+            "hedl_to_sqlite",
+            HEDL_ERR_NULL_PTR,
+            "Null pointer argument",
+            duration,
+        );
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let doc_ref = &(*doc).inner;
+
+    match sqlite_bytes(doc_ref) {
+        Ok(bytes) => {
+            let len = bytes.len();
+            let ptr = Box::into_raw(bytes.into_boxed_slice()) as *mut u8;
+            *out_data = ptr;
+            *out_len = len;
+            audit_call_success("hedl_to_sqlite", start.elapsed());
+            HEDL_OK
+        }
+        Err(e) => {
+            let duration = start.elapsed();
+            let msg = format!("SQLite conversion error: {}", e);
+            set_error(&msg);
+            *out_data = ptr::null_mut();
+            *out_len = 0;
+            audit_call_failure("hedl_to_sqlite", HEDL_ERR_SQLITE, &msg, duration);
+            HEDL_ERR_SQLITE
+        }
+    }
+}
+
+/// The rows of one struct type, each with the schema of the list it is in.
+#[cfg(feature = "sqlite")]
+struct SqliteTable<'a> {
+    columns: &'a [String],
+    rows: Vec<(&'a [String], &'a Node)>,
+}
+
+#[cfg(feature = "sqlite")]
+impl<'a> SqliteTable<'a> {
+    fn new(columns: &'a [String]) -> Self {
+        SqliteTable {
+            columns,
+            rows: Vec::new(),
+        }
+    }
+}
+
+/// Build the database for `hedl_to_sqlite` and serialize it.
+#[cfg(feature = "sqlite")]
+fn sqlite_bytes(doc: &Document) -> rusqlite::Result<Vec<u8>> {
+    fn collect<'a>(
+        doc: &'a Document,
+        type_name: &'a str,
+        schema: &'a [String],
+        rows: &'a [Node],
+        tables: &mut Vec<(&'a str, SqliteTable<'a>)>,
+    ) {
+        let index = match tables.iter().position(|(name, _)| *name == type_name) {
+            Some(index) => index,
+            None => {
+                tables.push((type_name, SqliteTable::new(schema)));
+                tables.len() - 1
+            }
+        };
+        let table = &mut tables[index].1;
+        table.rows.extend(rows.iter().map(|row| (schema, row)));
+        for row in rows {
+            for (child_type, children) in &row.children {
+                let child_schema = doc
+                    .structs
+                    .get(child_type)
+                    .map(Vec::as_slice)
+                    .unwrap_or(&[]);
+                collect(doc, child_type, child_schema, children, tables);
+            }
+        }
+    }
+
+    fn walk<'a>(
+        doc: &'a Document,
+        items: &'a BTreeMap<String, Item>,
+        tables: &mut Vec<(&'a str, SqliteTable<'a>)>,
+    ) {
+        for item in items.values() {
+            match item {
+                Item::List(list) => collect(doc, &list.type_name, &list.schema, &list.rows, tables),
+                Item::Object(obj) => walk(doc, obj, tables),
+                Item::Scalar(_) => {}
+            }
+        }
+    }
+
+    let mut tables: Vec<(&str, SqliteTable)> = doc
+        .structs
+        .iter()
+        .map(|(name, columns)| (name.as_str(), SqliteTable::new(columns)))
+        .collect();
+    walk(doc, &doc.root, &mut tables);
+
+    let conn = rusqlite::Connection::open_in_memory()?;
+    conn.execute_batch("BEGIN")?;
+    for (name, table) in &tables {
+        let mut columns = Vec::with_capacity(table.columns.len());
+        for column in table.columns {
+            let values = table
+                .rows
+                .iter()
+                .filter_map(|(schema, row)| sqlite_field(schema, row, column));
+            let mut definition = quote_identifier(column);
+            if let Some(affinity) = sqlite_affinity(values) {
+                definition = format!("{} {}", definition, affinity);
+            }
+            columns.push(definition);
+        }
+        let quoted = quote_identifier(name);
+        conn.execute_batch(&format!("CREATE TABLE {} ({})", quoted, columns.join(", ")))?;
+
+        let placeholders = vec!["?"; table.columns.len()].join(", ");
+        let mut insert =
+            conn.prepare(&format!("INSERT INTO {} VALUES ({})", quoted, placeholders))?;
+        for (schema, row) in &table.rows {
+            let values = table.columns.iter().map(|column| {
+                sqlite_value(sqlite_field(schema, row, column).unwrap_or(&Value::Null))
+            });
+            insert.execute(rusqlite::params_from_iter(values))?;
+        }
+    }
+    conn.execute_batch("COMMIT")?;
+
+    let data = conn.serialize(rusqlite::DatabaseName::Main)?;
+    Ok(data.to_vec())
+}
+
+/// The value of `column` in a row of a list with `schema`, if the list has it.
+#[cfg(feature = "sqlite")]
+fn sqlite_field<'a>(schema: &[String], row: &'a Node, column: &str) -> Option<&'a Value> {
+    let index = schema.iter().position(|field| field == column)?;
+    row.fields.get(index)
+}
+
+/// The declared type of a column holding `values`, or None to leave it
+/// untyped.
+#[cfg(feature = "sqlite")]
+fn sqlite_affinity<'a>(values: impl Iterator<Item = &'a Value>) -> Option<&'static str> {
+    let mut affinity = None;
+    for value in values {
+        let next = match value {
+            Value::Null => continue,
+            Value::Bool(_) => "bool",
+            Value::Int(_) => "int",
+            Value::Float(_) => "float",
+            Value::String(_) => "string",
+            Value::Tensor(_) => "tensor",
+            Value::Reference(_) => "reference",
+            Value::Expression(_) => "expression",
+        };
+        affinity = match (affinity, next) {
+            (None, next) => Some(next),
+            (Some("int"), "float") | (Some("float"), "int") => Some("float"),
+            (Some(seen), next) if seen == next => Some(seen),
+            _ => return None,
+        };
+    }
+    match affinity? {
+        "int" | "bool" => Some("INTEGER"),
+        "float" => Some("REAL"),
+        _ => Some("TEXT"),
+    }
+}
+
+/// Convert a value to the SQLite value stored for it.
+#[cfg(feature = "sqlite")]
+fn sqlite_value(value: &Value) -> rusqlite::types::Value {
+    use rusqlite::types::Value as Sql;
+    match value {
+        Value::Null => Sql::Null,
+        Value::Bool(b) => Sql::Integer(i64::from(*b)),
+        Value::Int(n) => Sql::Integer(*n),
+        Value::Float(f) => Sql::Real(*f),
+        Value::String(s) => Sql::Text(s.clone()),
+        Value::Tensor(t) => Sql::Text(t.to_string()),
+        other => Sql::Text(other.to_string()),
+    }
+}
+
+/// Quote an SQL identifier, doubling embedded quotes.
+#[cfg(feature = "sqlite")]
+fn quote_identifier(name: &str) -> String {
+    format!("\"{}\"", name.replace('"', "\"\""))
+}
+// =============================================================================
+// Neo4j/Cypher Conversion (requires "neo4j" feature)
+// =============================================================================
+
+/// Convert a HEDL document to Cypher queries for Neo4j.
+///
+/// Generates CREATE/MERGE statements, constraints, and relationships.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `use_merge` - Non-zero to use MERGE (idempotent), zero for CREATE
+/// * `out_str` - Pointer to store Cypher output (must be freed with hedl_free_string)
+///
+/// # Returns
+/// HEDL_OK on success, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid.
+///
+/// # Feature
+/// Requires the "neo4j" feature to be enabled.
+#[cfg(feature = "neo4j")]
+#[no_mangle]
+pub unsafe extern "C" fn hedl_to_neo4j_cypher(
+    doc: *const HedlDocument,
+    use_merge: c_int,
+    out_str: *mut *mut c_char,
+) -> c_int {
+    let start = Instant::now();
+
+    audit_call_start(
+        "hedl_to_neo4j_cypher",
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("use_merge", &use_merge.to_string()),
+            ("out_str", &sanitize_pointer(out_str)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_str.is_null() {
+        let duration = start.elapsed();
+        set_error("Null pointer argument");
+        audit_call_failure("hedl_to_neo4j_cypher", HEDL_ERR_NULL_PTR, "Null pointer argument", duration);
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let doc_ref = &(*doc).inner;
+    let config = if use_merge != 0 {
+        hedl_neo4j::ToCypherConfig::default()
+    } else {
+        hedl_neo4j::ToCypherConfig::new().with_create()
+    };
+
+    match hedl_neo4j::to_cypher(doc_ref, &config) {
+        Ok(cypher) => {
+            let result = allocate_output_string(&cypher.to_string(), out_str, HEDL_ERR_NEO4J);
+            if result == HEDL_OK {
+                audit_call_success("hedl_to_neo4j_cypher", start.elapsed());
+            } else {
+                let duration = start.elapsed();
+                let msg = crate::error::get_thread_local_error();
+                audit_call_failure("hedl_to_neo4j_cypher", result, &msg, duration);
+            }
+            result
+        }
+        Err(e) => {
+            let duration = start.elapsed();
+            let msg = format!("Neo4j conversion error: {}", e);
+            set_error(&msg);
+            *out_str = ptr::null_mut();
+            audit_call_failure("hedl_to_neo4j_cypher", HEDL_ERR_NEO4J, &msg, duration);
+            HEDL_ERR_NEO4J
+        }
+    }
+}
+
+// =============================================================================
+// Schema Descriptors
+// =============================================================================
+
+/// Describe every struct type of a HEDL document with the inferred types of
+/// its fields.
+///
+/// HEDL schemas do not declare field types, so each field is given the type
+/// of its non-null values as in `hedl_to_mermaid_er`: bool, int, float,
+/// string, tensor, reference or expression, float for a mix of ints and
+/// floats, and any for other mixes or fields that are always null. Declared
+/// types come first, by name, followed by types only used with inline
+/// schemas in document order. Each type is written on a line of its own,
+/// followed by one line per field in schema order holding a tab, the field
+/// name, its type and 1 if some row holds null or 0 otherwise, separated by
+/// tabs.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `out_str` - Pointer to store the descriptors (must be freed with hedl_free_string)
+///
+/// # Returns
+/// HEDL_OK on success, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_schema_descriptors(
+    doc: *const HedlDocument,
+    out_str: *mut *mut c_char,
+) -> c_int {
+    const FUNC: &str = "hedl_schema_descriptors";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("out_str", &sanitize_pointer(out_str)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_str.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let mut lines = Vec::new();
+    for (name, columns, fields) in inferred_schemas(&(*doc).inner) {
+        lines.push(name.to_string());
+        for (column, (type_name, optional)) in columns.iter().zip(fields) {
+            lines.push(format!("\t{}\t{}\t{}", column, type_name, optional as u8));
+        }
+    }
+    let result = allocate_output_string(&lines.join("\n"), out_str, HEDL_ERR_ALLOC);
+    if result == HEDL_OK {
+        audit_call_success(FUNC, start.elapsed());
+    } else {
+        audit_call_failure(FUNC, result, "Allocation failed", start.elapsed());
+    }
+    result
+}
+
+// =============================================================================
+// Mermaid Conversion
+// =============================================================================
+
+/// Convert the schemas of a HEDL document to a Mermaid `erDiagram`.
+///
+/// Every struct type becomes an entity listing its fields with the type of
+/// their non-null values: bool, int, float, string, tensor, reference or
+/// expression, float for a mix of ints and floats, and any for other mixes
+/// or fields that are always null. Declared types come first, by name,
+/// followed by types only used with inline schemas in document order. Each
+/// `%NEST` rule becomes a one-to-many relationship labelled "contains", and
+/// each field holding a qualified reference `@Type:id` a many-to-one
+/// relationship labelled with the field name.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `out_str` - Pointer to store the diagram (must be freed with hedl_free_string)
+///
+/// # Returns
+/// HEDL_OK on success, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_to_mermaid_er(
+    doc: *const HedlDocument,
+    out_str: *mut *mut c_char,
+) -> c_int {
+    const FUNC: &str = "hedl_to_mermaid_er";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("out_str", &sanitize_pointer(out_str)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_str.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let diagram = mermaid_er(&(*doc).inner);
+    let result = allocate_output_string(&diagram, out_str, HEDL_ERR_ALLOC);
+    if result == HEDL_OK {
+        audit_call_success(FUNC, start.elapsed());
+    } else {
+        audit_call_failure(FUNC, result, "Allocation failed", start.elapsed());
+    }
+    result
+}
+
+/// Build the diagram for `hedl_to_mermaid_er`.
+fn mermaid_er(doc: &Document) -> String {
+    let mut relationships = Vec::new();
+    let mut seen = HashSet::new();
+    for (parent, child) in &doc.nests {
+        relationships.push(format!("    {} ||--o{{ {} : \"contains\"\n", parent, child));
+    }
+    visit_indexed_rows(doc, &mut |schema, _, row| {
+        for (field, value) in schema.iter().zip(&row.fields) {
+            if let Value::Reference(r) = value {
+                if let Some(target) = &r.type_name {
+                    let line =
+                        format!("    {} }}o--|| {} : \"{}\"\n", row.type_name, target, field);
+                    if seen.insert(line.clone()) {
+                        relationships.push(line);
+                    }
+                }
+            }
+        }
+    });
+
+    let mut out = String::from("erDiagram\n");
+    for (name, columns, fields) in inferred_schemas(doc) {
+        out.push_str(&format!("    {} {{\n", name));
+        for (column, (type_name, _)) in columns.iter().zip(fields) {
+            out.push_str(&format!("        {} {}\n", type_name, column));
+        }
+        out.push_str("    }\n");
+    }
+    for line in relationships {
+        out.push_str(&line);
+    }
+    out
+}
+
+/// The struct types of `doc` with their columns: declared types first, by
+/// name, followed by types only used with inline schemas in document order,
+/// with the inline schema of their first list.
+fn struct_types(doc: &Document) -> Vec<(&str, &[String])> {
+    let mut types: Vec<(&str, &[String])> = doc
+        .structs
+        .iter()
+        .map(|(name, columns)| (name.as_str(), columns.as_slice()))
+        .collect();
+    visit_lists(&doc.root, &mut |list| {
+        if !types.iter().any(|(name, _)| *name == list.type_name) {
+            types.push((&list.type_name, &list.schema));
+        }
+    });
+    types
+}
+
+/// Infer the fields of every struct type of `doc`, as `hedl_to_mermaid_er`
+/// and `hedl_schema_descriptors` describe them: each field with the type of
+/// its non-null values, float for a mix of ints and floats and any for
+/// other mixes or fields that are always null, and whether it holds null.
+/// Declared types come first, by name, followed by types only used with
+/// inline schemas in document order. Rows are read by the schema of their
+/// own list.
+fn inferred_schemas(doc: &Document) -> Vec<(&str, &[String], Vec<(&'static str, bool)>)> {
+    let entities = struct_types(doc);
+    let index: HashMap<&str, usize> = entities
+        .iter()
+        .enumerate()
+        .map(|(i, (name, _))| (*name, i))
+        .collect();
+
+    let mut types: Vec<Vec<(BTreeSet<&str>, bool)>> = entities
+        .iter()
+        .map(|(_, columns)| vec![(BTreeSet::new(), false); columns.len()])
+        .collect();
+    visit_indexed_rows(doc, &mut |schema, _, row| {
+        let Some(&i) = index.get(row.type_name.as_str()) else {
+            return;
+        };
+        for (column, (seen_types, optional)) in entities[i].1.iter().zip(&mut types[i]) {
+            let position = schema.iter().position(|field| field == column);
+            match position.and_then(|p| row.fields.get(p)) {
+                None => {}
+                Some(Value::Null) => *optional = true,
+                Some(value) => {
+                    seen_types.insert(value_type(value));
+                }
+            }
+        }
+    });
+
+    entities
+        .into_iter()
+        .zip(types)
+        .map(|((name, columns), fields)| {
+            let fields = fields
+                .into_iter()
+                .map(|(mut seen_types, optional)| {
+                    if seen_types.contains("int") && seen_types.contains("float") {
+                        seen_types.remove("int");
+                    }
+                    let type_name = match seen_types.len() {
+                        1 => seen_types.into_iter().next().unwrap_or("any"),
+                        _ => "any",
+                    };
+                    (type_name, optional)
+                })
+                .collect();
+            (name, columns, fields)
+        })
+        .collect()
+}
+
+// =============================================================================
+// Properties Conversion
+// =============================================================================
+
+/// Convert a HEDL document to a Java `.properties` file.
+///
+/// Nested keys are flattened with dots, so `database: host: x` becomes
+/// `database.host=x`. Matrix rows are numbered from zero under their list key
+/// and keyed by field, as in `users.0.name=Alice`, with nested child rows
+/// continuing the path under their type name. Values are plain text as in
+/// CSV exports; properties have no null, so null values are omitted. Keys
+/// and values are escaped as by `java.util.Properties.store`, including
+/// `\uXXXX` escapes for non-ASCII characters, so the output loads under the
+/// ISO-8859-1 default.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `out_str` - Pointer to store the properties (must be freed with hedl_free_string)
+///
+/// # Returns
+/// HEDL_OK on success, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_to_properties(
+    doc: *const HedlDocument,
+    out_str: *mut *mut c_char,
+) -> c_int {
+    const FUNC: &str = "hedl_to_properties";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("out_str", &sanitize_pointer(out_str)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_str.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let doc_ref = &(*doc).inner;
+    let mut out = String::new();
+    write_properties_object(&mut out, doc_ref, "", &doc_ref.root);
+    let result = allocate_output_string(&out, out_str, HEDL_ERR_ALLOC);
+    if result == HEDL_OK {
+        audit_call_success(FUNC, start.elapsed());
+    } else {
+        audit_call_failure(FUNC, result, "Allocation failed", start.elapsed());
+    }
+    result
+}
+
+/// Write every value under `items`, prefixing keys with `prefix`.
+fn write_properties_object(
+    out: &mut String,
+    doc: &Document,
+    prefix: &str,
+    items: &BTreeMap<String, Item>,
+) {
+    for (key, item) in items {
+        let path = format!("{}{}", prefix, key);
+        match item {
+            Item::Scalar(value) => write_property(out, &path, value),
+            Item::Object(obj) => write_properties_object(out, doc, &format!("{}.", path), obj),
+            Item::List(list) => {
+                write_properties_rows(out, doc, &format!("{}.", path), &list.schema, &list.rows)
+            }
+        }
+    }
+}
+
+/// Write the fields of each row under its index, followed by its child rows
+/// under their type name.
+fn write_properties_rows(
+    out: &mut String,
+    doc: &Document,
+    prefix: &str,
+    schema: &[String],
+    rows: &[Node],
+) {
+    for (i, row) in rows.iter().enumerate() {
+        let row_prefix = format!("{}{}.", prefix, i);
+        for (field, value) in schema.iter().zip(&row.fields) {
+            write_property(out, &format!("{}{}", row_prefix, field), value);
+        }
+        for (child_type, children) in &row.children {
+            let child_schema = doc
+                .structs
+                .get(child_type)
+                .map(Vec::as_slice)
+                .unwrap_or(&[]);
+            let child_prefix = format!("{}{}.", row_prefix, child_type);
+            write_properties_rows(out, doc, &child_prefix, child_schema, children);
+        }
+    }
+}
+
+/// Write a single `key=value` line, skipping null values.
+fn write_property(out: &mut String, key: &str, value: &Value) {
+    if matches!(value, Value::Null) {
+        return;
+    }
+    out.push_str(&properties_escape(key, true));
+    out.push('=');
+    out.push_str(&properties_escape(&value_text(value), false));
+    out.push('\n');
+}
+
+/// Escape `s` for a `.properties` file. Every space in a key is escaped, but
+/// only a leading space in a value, since the format strips leading
+/// whitespace and ends keys at the first unescaped space.
+fn properties_escape(s: &str, is_key: bool) -> String {
+    let mut out = String::with_capacity(s.len());
+    for (i, c) in s.chars().enumerate() {
+        match c {
+            ' ' if is_key || i == 0 => out.push_str("\\ "),
+            '\\' | '=' | ':' | '#' | '!' => {
+                out.push('\\');
+                out.push(c);
+            }
+            '\t' => out.push_str("\\t"),
+            '\n' => out.push_str("\\n"),
+            '\r' => out.push_str("\\r"),
+            '\u{c}' => out.push_str("\\f"),
+            ' '..='~' => out.push(c),
+            _ => {
+                for unit in c.encode_utf16(&mut [0; 2]) {
+                    out.push_str(&format!("\\u{:04X}", unit));
+                }
+            }
+        }
+    }
+    out
+}
+
+// =============================================================================
+// InfluxDB Line Protocol Conversion
+// =============================================================================
+
+/// Convert a HEDL document to InfluxDB line protocol, one line per row of
+/// every type that has `time_field`, in document order with nested rows
+/// included.
+///
+/// Each line is the measurement, the tag fields present in the row as tags
+/// in the given order, every other non-null field as a field, and the
+/// timestamp taken from `time_field`. Integer timestamps are written as they
+/// are, so they should be nanoseconds since the epoch; strings are read as
+/// RFC 3339 times or YYYY-MM-DD dates and converted to nanoseconds. Rows with
+/// a null timestamp are written without one, so the server assigns it.
+///
+/// Field values follow the inferred type of their column, with ints and
+/// floats together inferring float: ints are written with the `i` suffix,
+/// numbers in float columns as floats, booleans as true/false and everything
+/// else, including columns of mixed type, as strings. Rows with no non-null
+/// fields cannot be represented and are skipped.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `measurement` - Measurement name written at the start of every line
+/// * `time_field` - Field holding the timestamp
+/// * `tag_fields` - Array of `tag_count` fields written as tags
+/// * `tag_count` - Number of tag fields
+/// * `out_str` - Pointer to store the lines (must be freed with hedl_free_string)
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_NOT_FOUND if no type has `time_field` or no
+/// type with it has a tag field, HEDL_ERR_INVALID_ARGUMENT for an empty
+/// measurement, a timestamp that cannot be read or a line break in a tag,
+/// or another error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_to_influx_line_protocol(
+    doc: *const HedlDocument,
+    measurement: *const c_char,
+    time_field: *const c_char,
+    tag_fields: *const *const c_char,
+    tag_count: c_int,
+    out_str: *mut *mut c_char,
+) -> c_int {
+    const FUNC: &str = "hedl_to_influx_line_protocol";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("measurement", &sanitize_pointer(measurement)),
+            ("time_field", &sanitize_pointer(time_field)),
+            ("tag_fields", &sanitize_pointer(tag_fields)),
+            ("tag_count", &tag_count.to_string()),
+            ("out_str", &sanitize_pointer(out_str)),
+        ],
+    );
+
+    clear_error();
+
+    let tags_missing = tag_count > 0 && tag_fields.is_null();
+    if !is_valid_document_ptr(doc) || out_str.is_null() || tags_missing {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+    *out_str = ptr::null_mut();
+
+    let fail = |code: c_int, err_msg: &str| {
+        set_error(err_msg);
+        audit_call_failure(FUNC, code, err_msg, start.elapsed());
+        code
+    };
+
+    let doc_ref = &(*doc).inner;
+    let (measurement, time_field) = match (c_str_arg(measurement), c_str_arg(time_field)) {
+        (Ok(measurement), Ok(time_field)) => (measurement, time_field),
+        (Err(code), _) | (_, Err(code)) => {
+            audit_call_failure(FUNC, code, "Invalid name argument", start.elapsed());
+            return code;
+        }
+    };
+    if measurement.is_empty() {
+        return fail(HEDL_ERR_INVALID_ARGUMENT, "Measurement name is empty");
+    }
+    let mut tags = Vec::with_capacity(tag_count.max(0) as usize);
+    for i in 0..tag_count.max(0) as usize {
+        match c_str_arg(*tag_fields.add(i)) {
+            Ok(tag) => tags.push(tag),
+            Err(code) => {
+                audit_call_failure(FUNC, code, "Invalid field argument", start.elapsed());
+                return code;
+            }
+        }
+    }
+
+    let mut schemas: Vec<&[String]> = doc_ref.structs.values().map(Vec::as_slice).collect();
+    visit_lists(&doc_ref.root, &mut |list| schemas.push(&list.schema));
+    let timed_fields: HashSet<&str> = schemas
+        .into_iter()
+        .filter(|columns| columns.iter().any(|column| column == time_field))
+        .flatten()
+        .map(String::as_str)
+        .collect();
+    if timed_fields.is_empty() {
+        let err_msg = format!("No type has time field {}", time_field);
+        return fail(HEDL_ERR_NOT_FOUND, &err_msg);
+    }
+    if let Some(tag) = tags.iter().find(|tag| !timed_fields.contains(**tag)) {
+        let err_msg = format!(
+            "No type with time field {} has tag field {}",
+            time_field, tag
+        );
+        return fail(HEDL_ERR_NOT_FOUND, &err_msg);
+    }
+
+    let lines = match influx_lines(doc_ref, measurement, time_field, &tags) {
+        Ok(lines) => lines,
+        Err(err_msg) => return fail(HEDL_ERR_INVALID_ARGUMENT, &err_msg),
+    };
+    let result = allocate_output_string(&lines, out_str, HEDL_ERR_ALLOC);
+    if result == HEDL_OK {
+        audit_call_success(FUNC, start.elapsed());
+    } else {
+        audit_call_failure(FUNC, result, "Allocation failed", start.elapsed());
+    }
+    result
+}
+
+/// Build the lines for `hedl_to_influx_line_protocol`, or describe the row
+/// that cannot be written.
+fn influx_lines(
+    doc: &Document,
+    measurement: &str,
+    time_field: &str,
+    tags: &[&str],
+) -> Result<String, String> {
+    let mut column_types: HashMap<(&str, &str), BTreeSet<&str>> = HashMap::new();
+    visit_indexed_rows(doc, &mut |schema, _, row| {
+        for (field, value) in schema.iter().zip(&row.fields) {
+            if !matches!(value, Value::Null) {
+                let seen = column_types.entry((&row.type_name, field)).or_default();
+                seen.insert(value_type(value));
+            }
+        }
+    });
+    let column_type = |type_name: &str, field: &str| {
+        let seen = column_types.get(&(type_name, field))?;
+        match seen.len() {
+            1 => seen.iter().next().copied(),
+            2 if seen.contains("int") && seen.contains("float") => Some("float"),
+            _ => None,
+        }
+    };
+
+    let mut out = String::new();
+    let mut error = None;
+    visit_indexed_rows(doc, &mut |schema, index, row| {
+        if error.is_some() || !schema.iter().any(|field| field == time_field) {
+            return;
+        }
+        let value = |field: &str| {
+            let position = schema.iter().position(|column| column == field)?;
+            row.fields
+                .get(position)
+                .filter(|v| !matches!(v, Value::Null))
+        };
+
+        let mut line = influx_escape(measurement, ", ");
+        for tag in tags {
+            let Some(text) = value(tag).map(value_text).filter(|text| !text.is_empty()) else {
+                continue;
+            };
+            if text.contains(['\r', '\n']) {
+                error = Some(format!(
+                    "{} row {} tag {} contains a newline",
+                    row.type_name, index, tag
+                ));
+                return;
+            }
+            line.push_str(&format!(
+                ",{}={}",
+                influx_escape(tag, ",= "),
+                influx_escape(&text, ",= ")
+            ));
+        }
+
+        let mut fields = Vec::new();
+        for (field, value) in schema.iter().zip(&row.fields) {
+            if matches!(value, Value::Null) || field == time_field || tags.contains(&field.as_str())
+            {
+                continue;
+            }
+            let text = influx_field_value(value, column_type(&row.type_name, field));
+            fields.push(format!("{}={}", influx_escape(field, ",= "), text));
+        }
+        if fields.is_empty() {
+            return;
+        }
+        line.push(' ');
+        line.push_str(&fields.join(","));
+
+        if let Some(timestamp) = value(time_field) {
+            match influx_timestamp(timestamp) {
+                Some(nanos) => line.push_str(&format!(" {}", nanos)),
+                None => {
+                    error = Some(format!(
+                        "{} row {}: timestamp {:?} is not an integer or RFC 3339 time",
+                        row.type_name,
+                        index,
+                        value_text(timestamp)
+                    ));
+                    return;
+                }
+            }
+        }
+        out.push_str(&line);
+        out.push('\n');
+    });
+    match error {
+        Some(err_msg) => Err(err_msg),
+        None => Ok(out),
+    }
+}
+
+/// Backslash-escape each character of `special` in `s`.
+fn influx_escape(s: &str, special: &str) -> String {
+    let mut out = String::with_capacity(s.len());
+    for c in s.chars() {
+        if special.contains(c) {
+            out.push('\\');
+        }
+        out.push(c);
+    }
+    out
+}
+
+/// Format a non-null field value for a column of the given inferred type.
+fn influx_field_value(value: &Value, column_type: Option<&str>) -> String {
+    match (value, column_type) {
+        (Value::Bool(b), Some("bool")) => b.to_string(),
+        (Value::Int(n), Some("int")) => format!("{}i", n),
+        (Value::Int(_) | Value::Float(_), Some("float")) => value_text(value).into_owned(),
+        _ => format!(
+            "\"{}\"",
+            value_text(value).replace('\\', "\\\\").replace('"', "\\\"")
+        ),
+    }
+}
+
+/// Convert a timestamp value to nanoseconds since the epoch.
+fn influx_timestamp(value: &Value) -> Option<i64> {
+    match value {
+        Value::Int(n) => Some(*n),
+        Value::String(s) => match DateTime::parse_from_rfc3339(s) {
+            Ok(time) => time.timestamp_nanos_opt(),
+            Err(_) => NaiveDate::parse_from_str(s, "%Y-%m-%d")
+                .ok()?
+                .and_hms_opt(0, 0, 0)?
+                .and_utc()
+                .timestamp_nanos_opt(),
+        },
+        _ => None,
+    }
+}
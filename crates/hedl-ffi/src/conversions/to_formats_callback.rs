@@ -73,6 +73,68 @@ use std::os::raw::{c_char, c_int, c_void};
 /// - The callback MUST NOT call back into HEDL functions
 pub type HedlOutputCallback = unsafe extern "C" fn(data: *const c_char, len: usize, user_data: *mut c_void);
 
+/// Chunk callback function type for streaming output.
+///
+/// Called once per chunk of output, in order. Returning 0 continues the
+/// conversion; any other value stops it.
+///
+/// # Safety
+/// - The `data` pointer is only valid during the callback execution
+/// - Do NOT store the pointer for later use
+/// - The data is not null-terminated
+/// - The callback MUST NOT call back into HEDL functions
+pub type HedlChunkCallback =
+    unsafe extern "C" fn(data: *const c_char, len: usize, user_data: *mut c_void) -> c_int;
+
+/// Default chunk size for chunked output when the caller passes 0.
+const DEFAULT_CHUNK_SIZE: usize = 64 * 1024;
+
+/// Writer that buffers output and hands it to a chunk callback whenever at
+/// least `chunk_size` bytes are pending.
+struct ChunkWriter {
+    buf: Vec<u8>,
+    chunk_size: usize,
+    callback: HedlChunkCallback,
+    user_data: *mut c_void,
+    stopped: bool,
+}
+
+impl ChunkWriter {
+    fn emit(&mut self) -> std::io::Result<()> {
+        if self.buf.is_empty() {
+            return Ok(());
+        }
+        // SAFETY: the caller of the chunked export guarantees the callback
+        // and user_data are valid for the duration of the call.
+        let status = unsafe {
+            (self.callback)(self.buf.as_ptr() as *const c_char, self.buf.len(), self.user_data)
+        };
+        self.buf.clear();
+        if status != 0 {
+            self.stopped = true;
+            return Err(std::io::Error::new(
+                std::io::ErrorKind::Other,
+                "output callback stopped the conversion",
+            ));
+        }
+        Ok(())
+    }
+}
+
+impl std::io::Write for ChunkWriter {
+    fn write(&mut self, data: &[u8]) -> std::io::Result<usize> {
+        self.buf.extend_from_slice(data);
+        if self.buf.len() >= self.chunk_size {
+            self.emit()?;
+        }
+        Ok(data.len())
+    }
+
+    fn flush(&mut self) -> std::io::Result<()> {
+        self.emit()
+    }
+}
+
 // =============================================================================
 // Helper Functions
 // =============================================================================
@@ -163,6 +225,104 @@ pub unsafe extern "C" fn hedl_to_json_callback(
     }
 }
 
+/// Convert a HEDL document to JSON, passing the output to a callback in
+/// chunks.
+///
+/// Unlike `hedl_to_json_callback`, the JSON text is never held in memory as
+/// a whole: it is serialized straight into a buffer of about `chunk_size`
+/// bytes that is handed to the callback each time it fills. The output is
+/// identical to `hedl_to_json`.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `include_metadata` - Non-zero to include HEDL metadata (__type__, __schema__)
+/// * `chunk_size` - Preferred chunk size in bytes, or 0 for 64 KiB
+/// * `callback` - Function to receive each chunk; a non-zero return stops the conversion
+/// * `user_data` - User context pointer passed to callback
+///
+/// # Returns
+/// HEDL_OK on success, error code on failure. HEDL_ERR_JSON is returned when
+/// the callback stops the conversion.
+///
+/// # Safety
+/// - All pointers must be valid
+/// - The callback MUST NOT call back into HEDL functions
+/// - The data pointer passed to callback is only valid during the callback
+///
+/// # Feature
+/// Requires the "json" feature to be enabled.
+#[cfg(feature = "json")]
+#[no_mangle]
+pub unsafe extern "C" fn hedl_to_json_chunked(
+    doc: *const HedlDocument,
+    include_metadata: c_int,
+    chunk_size: usize,
+    callback: HedlChunkCallback,
+    user_data: *mut c_void,
+) -> c_int {
+    use crate::audit::{audit_call_failure, audit_call_start, audit_call_success, sanitize_pointer};
+    use std::io::Write;
+    use std::time::Instant;
+    let start = Instant::now();
+    let doc_ptr_str = sanitize_pointer(doc);
+    let include_metadata_str = include_metadata.to_string();
+    let chunk_size_str = chunk_size.to_string();
+    audit_call_start("hedl_to_json_chunked", &[
+        ("doc_ptr", &doc_ptr_str),
+        ("include_metadata", &include_metadata_str),
+        ("chunk_size", &chunk_size_str),
+    ]);
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) {
+        set_error("Null or invalid document pointer");
+        let duration = start.elapsed();
+        audit_call_failure("hedl_to_json_chunked", HEDL_ERR_NULL_PTR, "NULL or invalid pointer", duration);
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let doc_ref = &(*doc).inner;
+    let config = hedl_json::ToJsonConfig {
+        include_metadata: include_metadata != 0,
+        ..Default::default()
+    };
+
+    let chunk_size = if chunk_size == 0 { DEFAULT_CHUNK_SIZE } else { chunk_size };
+    let mut writer = ChunkWriter {
+        buf: Vec::with_capacity(chunk_size),
+        chunk_size,
+        callback,
+        user_data,
+        stopped: false,
+    };
+
+    // The alternate form of Value's Display matches to_string_pretty, as
+    // used by hedl_to_json, without building the whole string.
+    let result = hedl_json::to_json_value(doc_ref, &config).and_then(|value| {
+        write!(writer, "{:#}", value)
+            .and_then(|_| writer.flush())
+            .map_err(|e| e.to_string())
+    });
+
+    match result {
+        Ok(()) => {
+            audit_call_success("hedl_to_json_chunked", start.elapsed());
+            HEDL_OK
+        }
+        Err(e) => {
+            let msg = if writer.stopped {
+                "output callback stopped the conversion".to_string()
+            } else {
+                format!("JSON conversion error: {}", e)
+            };
+            set_error(&msg);
+            audit_call_failure("hedl_to_json_chunked", HEDL_ERR_JSON, &msg, start.elapsed());
+            HEDL_ERR_JSON
+        }
+    }
+}
+
 // =============================================================================
 // YAML Conversion with Callback
 // =============================================================================
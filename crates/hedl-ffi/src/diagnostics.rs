@@ -97,3 +97,90 @@ pub unsafe extern "C" fn hedl_diagnostics_severity(
         hedl_lint::Severity::Error => 2,
     }
 }
+
+/// Get the 1-based source line of a diagnostic, or 0 if it has none.
+///
+/// # Safety
+/// Pointer must be valid. Returns -1 if diag is NULL or poisoned, or if
+/// index is out of range.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_diagnostics_line(
+    diag: *const HedlDiagnostics,
+    index: c_int,
+) -> c_int {
+    if !is_valid_diagnostics_ptr(diag) {
+        return -1;
+    }
+
+    let diagnostics = &(*diag).inner;
+    if index < 0 || index as usize >= diagnostics.len() {
+        return -1;
+    }
+
+    diagnostics[index as usize]
+        .line()
+        .map_or(0, |line| c_int::try_from(line).unwrap_or(c_int::MAX))
+}
+
+/// Get the 1-based source column of a diagnostic, or 0 if it has none.
+///
+/// Lint rules currently report whole lines, so this is 0 for every
+/// diagnostic; it is provided so callers need not change when rules start
+/// reporting columns.
+///
+/// # Safety
+/// Pointer must be valid. Returns -1 if diag is NULL or poisoned, or if
+/// index is out of range.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_diagnostics_column(
+    diag: *const HedlDiagnostics,
+    index: c_int,
+) -> c_int {
+    if !is_valid_diagnostics_ptr(diag) {
+        return -1;
+    }
+
+    let diagnostics = &(*diag).inner;
+    if index < 0 || index as usize >= diagnostics.len() {
+        return -1;
+    }
+
+    0
+}
+
+/// Get the ID of the lint rule that produced a diagnostic, such as
+/// "unused-schema".
+///
+/// # Arguments
+/// * `diag` - Diagnostics handle
+/// * `index` - Diagnostic index
+/// * `out_str` - Pointer to store the rule ID (must be freed with hedl_free_string)
+///
+/// # Returns
+/// HEDL_OK on success, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if diag is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_diagnostics_rule(
+    diag: *const HedlDiagnostics,
+    index: c_int,
+    out_str: *mut *mut c_char,
+) -> c_int {
+    if !is_valid_diagnostics_ptr(diag) || out_str.is_null() {
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let diagnostics = &(*diag).inner;
+    if index < 0 || index as usize >= diagnostics.len() {
+        set_error("Diagnostic index out of range");
+        *out_str = ptr::null_mut();
+        return HEDL_ERR_LINT;
+    }
+
+    allocate_output_string(
+        diagnostics[index as usize].rule_id(),
+        out_str,
+        HEDL_ERR_LINT,
+    )
+}
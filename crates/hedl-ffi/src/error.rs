@@ -17,8 +17,10 @@
 
 //! Error handling for FFI.
 
+use crate::types::{HEDL_ERR_NULL_PTR, HEDL_OK};
+use std::cell::Cell;
 use std::ffi::CString;
-use std::os::raw::c_char;
+use std::os::raw::{c_char, c_int};
 use std::ptr;
 
 // =============================================================================
@@ -27,6 +29,7 @@ use std::ptr;
 
 thread_local! {
     static LAST_ERROR: std::cell::RefCell<Option<CString>> = const { std::cell::RefCell::new(None) };
+    static LAST_ERROR_LOCATION: Cell<(usize, usize)> = const { Cell::new((0, 0)) };
 }
 
 pub(crate) fn set_error(msg: &str) {
@@ -35,10 +38,16 @@ pub(crate) fn set_error(msg: &str) {
     });
 }
 
+/// Record the 1-based line and column of the last error; 0 means unknown.
+pub(crate) fn set_error_location(line: usize, column: usize) {
+    LAST_ERROR_LOCATION.with(|l| l.set((line, column)));
+}
+
 pub(crate) fn clear_error() {
     LAST_ERROR.with(|e| {
         *e.borrow_mut() = None;
     });
+    LAST_ERROR_LOCATION.with(|l| l.set((0, 0)));
 }
 
 /// Get the last error message for the current thread.
@@ -85,6 +94,46 @@ pub extern "C" fn hedl_get_last_error() -> *const c_char {
     })
 }
 
+/// Get the source position of the last error for the current thread.
+///
+/// Parse errors record the 1-based line and, when known, column of the
+/// offending input. Both are set to 0 when the last error has no position,
+/// or when no error occurred on this thread. Like `hedl_get_last_error()`,
+/// the position is per thread and is reset by the next `hedl_*` call.
+///
+/// # Arguments
+/// * `line` - Pointer to store the line number
+/// * `column` - Pointer to store the column number
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_NULL_PTR if either pointer is NULL.
+///
+/// # Safety
+/// All pointers must be valid.
+///
+/// # Example (C)
+///
+/// ```c
+/// if (hedl_parse(input, -1, 0, &doc) != HEDL_OK) {
+///     int line, column;
+///     hedl_get_last_error_location(&line, &column);
+///     fprintf(stderr, "%d:%d: %s", line, column, hedl_get_last_error());
+/// }
+/// ```
+#[no_mangle]
+pub unsafe extern "C" fn hedl_get_last_error_location(
+    line: *mut c_int,
+    column: *mut c_int,
+) -> c_int {
+    if line.is_null() || column.is_null() {
+        return HEDL_ERR_NULL_PTR;
+    }
+    let (l, c) = LAST_ERROR_LOCATION.with(Cell::get);
+    *line = c_int::try_from(l).unwrap_or(c_int::MAX);
+    *column = c_int::try_from(c).unwrap_or(c_int::MAX);
+    HEDL_OK
+}
+
 /// Clear the last error for the current thread.
 ///
 /// This function explicitly clears any error message stored for the calling thread.
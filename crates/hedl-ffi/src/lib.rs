@@ -25,9 +25,11 @@
 //! **IMPORTANT:** Memory ownership follows strict rules:
 //!
 //! - Strings returned by `hedl_*` functions MUST be freed with `hedl_free_string`
-//! - Byte arrays returned by `hedl_to_parquet` MUST be freed with `hedl_free_bytes`
+//! - Byte arrays returned by `hedl_to_parquet` and `hedl_to_sqlite` MUST be freed with
+//!   `hedl_free_bytes`
 //! - Documents MUST be freed with `hedl_free_document`
 //! - Diagnostics MUST be freed with `hedl_free_diagnostics`
+//! - CSV cursors MUST be freed with `hedl_free_csv_cursor`
 //!
 //! **WARNING - Memory Safety Requirements:**
 //!
@@ -59,6 +61,7 @@
 //! **Thread-Safe Functions:**
 //! - `hedl_get_last_error()` - Get error for current thread
 //! - `hedl_get_last_error_threadsafe()` - Explicit thread-safe alias
+//! - `hedl_get_last_error_location()` - Get the line and column of the error
 //! - `hedl_clear_error_threadsafe()` - Clear error for current thread
 //!
 //! **Example (Multi-threaded C with pthreads):**
@@ -200,6 +203,7 @@
 // =============================================================================
 
 pub mod audit;
+mod checks;
 mod conversions;
 mod diagnostics;
 mod error;
@@ -215,37 +219,79 @@ mod utils;
 
 // Types and error codes
 pub use types::{
-    HedlDiagnostics, HedlDocument, HEDL_ERR_ALLOC, HEDL_ERR_CANONICALIZE, HEDL_ERR_CSV,
-    HEDL_ERR_INVALID_UTF8, HEDL_ERR_JSON, HEDL_ERR_LINT, HEDL_ERR_NEO4J, HEDL_ERR_NULL_PTR,
-    HEDL_ERR_PARQUET, HEDL_ERR_PARSE, HEDL_ERR_XML, HEDL_ERR_YAML, HEDL_OK,
+    HedlCsvCursor, HedlDiagnostics, HedlDocument, HEDL_ERR_ALLOC, HEDL_ERR_CANONICALIZE,
+    HEDL_ERR_CONFLICT, HEDL_ERR_CSV, HEDL_ERR_INVALID_ARGUMENT, HEDL_ERR_INVALID_UTF8,
+    HEDL_ERR_JSON, HEDL_ERR_LINT, HEDL_ERR_NEO4J, HEDL_ERR_NOT_FOUND, HEDL_ERR_NULL_PTR,
+    HEDL_ERR_PARQUET, HEDL_ERR_PARSE, HEDL_ERR_SQLITE, HEDL_ERR_TIMEOUT, HEDL_ERR_TOML,
+    HEDL_ERR_XML, HEDL_ERR_YAML, HEDL_OK,
 };
 
 // Error handling
 pub use error::{
-    hedl_clear_error_threadsafe, hedl_get_last_error, hedl_get_last_error_threadsafe,
+    hedl_clear_error_threadsafe, hedl_get_last_error, hedl_get_last_error_location,
+    hedl_get_last_error_threadsafe,
 };
 
 // Memory management
-pub use memory::{hedl_free_bytes, hedl_free_diagnostics, hedl_free_document, hedl_free_string};
+pub use memory::{
+    hedl_free_bytes, hedl_free_csv_cursor, hedl_free_diagnostics, hedl_free_document,
+    hedl_free_string, hedl_live_document_count,
+};
 
 // Parsing functions
 pub use parsing::{
-    hedl_alias_count, hedl_get_version, hedl_parse, hedl_root_item_count, hedl_schema_count,
-    hedl_validate,
+    hedl_alias_count, hedl_aliases, hedl_document_memory, hedl_get_version, hedl_largest_values,
+    hedl_parse, hedl_parse_with_deadline, hedl_parse_with_provenance, hedl_root_item_count,
+    hedl_row_source_line, hedl_schema_checksum, hedl_schema_count, hedl_schema_names,
+    hedl_validate, hedl_validate_range,
 };
 
 // Operations
-pub use operations::{hedl_canonicalize, hedl_lint};
+pub use operations::{
+    hedl_canonicalize, hedl_canonicalize_path, hedl_check_unicode_normalization, hedl_dedup,
+    hedl_fit_to_tokens, hedl_head, hedl_join, hedl_lint, hedl_lint_warning_count,
+    hedl_normalize_dates, hedl_normalize_references, hedl_normalize_scalars, hedl_partition,
+    hedl_partition_keys, hedl_rename_field, hedl_rename_schema, hedl_tail, hedl_to_git_friendly,
+    hedl_token_count,
+};
+
+// Checks
+pub use checks::{
+    hedl_auto_fix, hedl_check_foreign_keys, hedl_check_ranges, hedl_check_schema_references,
+    hedl_check_unique, hedl_check_whitespace, hedl_field_stats, hedl_find_reference_cycle,
+    hedl_mixed_type_fields, hedl_rows_with_missing, hedl_trim_strings, hedl_unused_fields,
+};
 
 // Diagnostics
-pub use diagnostics::{hedl_diagnostics_count, hedl_diagnostics_get, hedl_diagnostics_severity};
+pub use diagnostics::{
+    hedl_diagnostics_column, hedl_diagnostics_count, hedl_diagnostics_get, hedl_diagnostics_line,
+    hedl_diagnostics_rule, hedl_diagnostics_severity,
+};
 
 // Conversion functions (to_*)
 #[cfg(feature = "json")]
 pub use conversions::to_formats::hedl_to_json;
 
+#[cfg(feature = "json")]
+pub use conversions::to_formats::hedl_to_grouped_json;
+
+#[cfg(feature = "json")]
+pub use conversions::to_formats::hedl_to_json_page;
+
+#[cfg(feature = "json")]
+pub use conversions::to_formats::hedl_get_by_key;
+
+#[cfg(feature = "json")]
+pub use conversions::to_formats::hedl_index_by;
+
+#[cfg(feature = "json")]
+pub use conversions::to_formats::hedl_example_json;
+
 #[cfg(feature = "yaml")]
-pub use conversions::to_formats::hedl_to_yaml;
+pub use conversions::to_formats::{hedl_to_yaml, hedl_to_yaml_multi};
+
+#[cfg(feature = "toml")]
+pub use conversions::to_formats::hedl_to_toml;
 
 #[cfg(feature = "xml")]
 pub use conversions::to_formats::hedl_to_xml;
@@ -256,15 +302,26 @@ pub use conversions::to_formats::hedl_to_csv;
 #[cfg(feature = "parquet")]
 pub use conversions::to_formats::hedl_to_parquet;
 
+#[cfg(feature = "sqlite")]
+pub use conversions::to_formats::hedl_to_sqlite;
+
 #[cfg(feature = "neo4j")]
 pub use conversions::to_formats::hedl_to_neo4j_cypher;
 
+pub use conversions::to_formats::{
+    hedl_schema_descriptors, hedl_to_csv_zip, hedl_to_influx_line_protocol, hedl_to_mermaid_er,
+    hedl_to_properties,
+};
+
 // Zero-copy callback functions (to_*_callback)
-pub use conversions::to_formats_callback::HedlOutputCallback;
+pub use conversions::to_formats_callback::{HedlChunkCallback, HedlOutputCallback};
 
 #[cfg(feature = "json")]
 pub use conversions::to_formats_callback::hedl_to_json_callback;
 
+#[cfg(feature = "json")]
+pub use conversions::to_formats_callback::hedl_to_json_chunked;
+
 #[cfg(feature = "yaml")]
 pub use conversions::to_formats_callback::hedl_to_yaml_callback;
 
@@ -279,6 +336,9 @@ pub use conversions::to_formats_callback::hedl_to_neo4j_cypher_callback;
 
 pub use conversions::to_formats_callback::hedl_canonicalize_callback;
 
+// Row cursors
+pub use conversions::csv_cursor::{hedl_csv_cursor_next, hedl_csv_cursor_open};
+
 // Conversion functions (from_*)
 #[cfg(feature = "json")]
 pub use conversions::from_formats::hedl_from_json;
@@ -289,9 +349,15 @@ pub use conversions::from_formats::hedl_from_yaml;
 #[cfg(feature = "xml")]
 pub use conversions::from_formats::hedl_from_xml;
 
+#[cfg(feature = "csv")]
+pub use conversions::from_formats::hedl_from_csv;
+
 #[cfg(feature = "parquet")]
 pub use conversions::from_formats::hedl_from_parquet;
 
+#[cfg(feature = "toml")]
+pub use conversions::from_formats::hedl_from_toml;
+
 // =============================================================================
 // Tests
 // =============================================================================
@@ -300,11 +366,13 @@ pub use conversions::from_formats::hedl_from_parquet;
 mod tests {
     use super::*;
     use std::ffi::CStr;
-    use std::os::raw::c_char;
+    use std::os::raw::{c_char, c_int};
     use std::ptr;
 
     const VALID_HEDL: &[u8] = b"%VERSION: 1.0\n---\nkey: value\0";
     const INVALID_HEDL: &[u8] = b"not valid hedl\0";
+    const TABLE_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: User: [id, name, role]\n---\n\
+        users: @User\n  | alice, Alice, admin\n  | bob, Bob, user\n  | carol, Carol, admin\n\0";
 
     #[test]
     fn test_parse_and_free() {
@@ -339,6 +407,198 @@ mod tests {
         }
     }
 
+    #[test]
+    fn test_validate_range() {
+        const PROJECTS_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: Project: [id, name]\n\
+            %STRUCT: Task: [id, title, done]\n%NEST: Project > Task\n---\n\
+            notes: \"\"\"\n| not, a row\n\"\"\"\nprojects: @Project\n\
+            \x20 |[2] apollo, Apollo\n    | t1, Design, true\n    | t2, Build, ^\n\
+            \x20 |[1] zephyr, Zephyr\n    | t3, Launch\n\0";
+
+        unsafe fn range_lines(input: &[u8], start: c_int, end: c_int) -> Vec<c_int> {
+            let mut diag: *mut HedlDiagnostics = ptr::null_mut();
+            let result = hedl_validate_range(
+                input.as_ptr() as *const c_char,
+                -1,
+                start,
+                end,
+                1,
+                &mut diag,
+            );
+            assert_eq!(result, HEDL_OK);
+            let lines = (0..hedl_diagnostics_count(diag))
+                .map(|i| hedl_diagnostics_line(diag, i))
+                .collect();
+            hedl_free_diagnostics(diag);
+            lines
+        }
+
+        unsafe {
+            // Line 14 is missing a field.
+            assert_eq!(range_lines(PROJECTS_HEDL, 14, 14), vec![14]);
+            assert_eq!(range_lines(PROJECTS_HEDL, 1, 13), Vec::<c_int>::new());
+            // A ditto marker, a nested row and block string content, each
+            // validated in context.
+            assert_eq!(range_lines(PROJECTS_HEDL, 12, 12), Vec::<c_int>::new());
+            assert_eq!(range_lines(PROJECTS_HEDL, 11, 11), Vec::<c_int>::new());
+            assert_eq!(range_lines(PROJECTS_HEDL, 7, 7), Vec::<c_int>::new());
+
+            // An error in the header is reported for any range.
+            let broken =
+                b"%VERSION: 1.0\n%STRUCT: User: [id, name\n---\nusers: @User\n  | a, A\n\0";
+            assert_eq!(range_lines(broken, 5, 5), vec![2]);
+
+            let mut diag: *mut HedlDiagnostics = ptr::null_mut();
+            for (start, end) in [(0, 3), (3, 2)] {
+                let result = hedl_validate_range(
+                    PROJECTS_HEDL.as_ptr() as *const c_char,
+                    -1,
+                    start,
+                    end,
+                    1,
+                    &mut diag,
+                );
+                assert_eq!(result, HEDL_ERR_INVALID_ARGUMENT);
+            }
+        }
+    }
+
+    #[test]
+    fn test_schema_names() {
+        const SCHEMAS_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: User: [id]\n%STRUCT: Post: [id]\n\
+            ---\nusers: @User\n  | u1\nposts: @Post\n  | p1\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(SCHEMAS_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+
+            let mut out_str: *mut c_char = ptr::null_mut();
+            let result = hedl_schema_names(doc, &mut out_str);
+            assert_eq!(result, HEDL_OK);
+            assert_eq!(CStr::from_ptr(out_str).to_str().unwrap(), "Post\nUser");
+            hedl_free_string(out_str);
+
+            let result = hedl_schema_names(ptr::null(), &mut out_str);
+            assert_eq!(result, HEDL_ERR_NULL_PTR);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_aliases() {
+        const ALIASES_HEDL: &[u8] =
+            b"%VERSION: 1.0\n%ALIAS: %tab: \"a\tb\"\n%ALIAS: %env: \"prod\"\n---\nkey: value\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(ALIASES_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+
+            let mut out_str: *mut c_char = ptr::null_mut();
+            let result = hedl_aliases(doc, &mut out_str);
+            assert_eq!(result, HEDL_OK);
+            let aliases = CStr::from_ptr(out_str).to_str().unwrap();
+            assert_eq!(aliases, "env\tprod\ntab\ta\\tb");
+            hedl_free_string(out_str);
+
+            let result = hedl_aliases(ptr::null(), &mut out_str);
+            assert_eq!(result, HEDL_ERR_NULL_PTR);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_largest_values() {
+        const SIZES_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: Note: [id, body]\n---\ntitle: hi\n\
+            notes: @Note\n  | n1, short\n  | n2, a longer body\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(SIZES_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+
+            let mut out_str: *mut c_char = ptr::null_mut();
+            let result = hedl_largest_values(doc, 2, &mut out_str);
+            assert_eq!(result, HEDL_OK);
+            let values = CStr::from_ptr(out_str).to_str().unwrap();
+            assert_eq!(values, "Note\tbody\t1\t13\nNote\tbody\t0\t5");
+            hedl_free_string(out_str);
+
+            let result = hedl_largest_values(ptr::null(), 2, &mut out_str);
+            assert_eq!(result, HEDL_ERR_NULL_PTR);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_document_memory() {
+        const SHORT_HEDL: &[u8] = b"%VERSION: 1.0\n---\nbody: short\n\0";
+        let long_hedl = format!("%VERSION: 1.0\n---\nbody: {}\n\0", "x".repeat(10_000));
+        unsafe {
+            let mut short: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(SHORT_HEDL.as_ptr() as *const c_char, -1, 1, &mut short);
+            let mut long: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(long_hedl.as_ptr() as *const c_char, -1, 1, &mut long);
+
+            let mut short_bytes: i64 = 0;
+            let mut long_bytes: i64 = 0;
+            assert_eq!(hedl_document_memory(short, &mut short_bytes), HEDL_OK);
+            assert_eq!(hedl_document_memory(long, &mut long_bytes), HEDL_OK);
+            assert!(short_bytes > 0);
+            assert!(long_bytes > short_bytes + 9_000);
+
+            let result = hedl_document_memory(ptr::null(), &mut short_bytes);
+            assert_eq!(result, HEDL_ERR_NULL_PTR);
+            hedl_free_document(short);
+            hedl_free_document(long);
+        }
+    }
+
+    #[test]
+    fn test_live_document_count() {
+        const HEDL: &[u8] = b"%VERSION: 1.0\n---\nname: test\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            assert_eq!(
+                hedl_parse(HEDL.as_ptr() as *const c_char, -1, 1, &mut doc),
+                HEDL_OK
+            );
+            // Other tests run concurrently, so only the lower bound is fixed.
+            assert!(hedl_live_document_count() >= 1);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_check_unicode_normalization() {
+        const DECOMPOSED_HEDL: &str = "%VERSION: 1.0\n%STRUCT: Place: [id, name]\n---\n\
+            title: Cafe\u{301}\nplaces: @Place\n  | p1, Caf\u{e9}\n  | p2, Cafe\u{301}\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(DECOMPOSED_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+
+            let mut diag: *mut HedlDiagnostics = ptr::null_mut();
+            let result = hedl_check_unicode_normalization(
+                doc,
+                b"nfc\0".as_ptr() as *const c_char,
+                &mut diag,
+            );
+            assert_eq!(result, HEDL_OK);
+            assert_eq!(hedl_diagnostics_count(diag), 2);
+            let mut message: *mut c_char = ptr::null_mut();
+            assert_eq!(hedl_diagnostics_get(diag, 1, &mut message), HEDL_OK);
+            assert_eq!(
+                CStr::from_ptr(message).to_str().unwrap(),
+                "[unicode-normalization] error: Place row 1 field \"name\" is not in NFC form (\"Cafe\\u{301}\")"
+            );
+            hedl_free_string(message);
+            hedl_free_diagnostics(diag);
+
+            let result = hedl_check_unicode_normalization(
+                doc,
+                b"NFX\0".as_ptr() as *const c_char,
+                &mut diag,
+            );
+            assert_eq!(result, HEDL_ERR_INVALID_ARGUMENT);
+            hedl_free_document(doc);
+        }
+    }
+
     #[test]
     fn test_null_ptr_handling() {
         unsafe {
@@ -424,6 +684,29 @@ mod tests {
         }
     }
 
+    #[cfg(feature = "yaml")]
+    #[test]
+    fn test_to_yaml_multi() {
+        const ITEMS_HEDL: &[u8] = b"%VERSION: 1.0\n---\nname: demo\nowner: ops\nreplicas: 3\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(ITEMS_HEDL.as_ptr() as *const c_char, -1, 0, &mut doc);
+
+            let mut out_str: *mut c_char = ptr::null_mut();
+            let result = hedl_to_yaml_multi(doc, &mut out_str);
+            assert_eq!(result, HEDL_OK);
+
+            let yaml = CStr::from_ptr(out_str).to_str().unwrap();
+            let documents: Vec<&str> = yaml.split("---\n").collect();
+            assert_eq!(documents.len(), 3, "{}", yaml);
+            assert!(documents[0].starts_with("name:"), "{}", yaml);
+            assert!(documents.iter().all(|d| d.ends_with('\n')), "{}", yaml);
+
+            hedl_free_string(out_str);
+            hedl_free_document(doc);
+        }
+    }
+
     #[cfg(feature = "xml")]
     #[test]
     fn test_to_xml() {
@@ -465,6 +748,27 @@ mod tests {
         }
     }
 
+    #[test]
+    fn test_lint_warning_count() {
+        const UNUSED_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: Unused: [id, name]\n---\nkey: value\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(UNUSED_HEDL.as_ptr() as *const c_char, -1, 0, &mut doc);
+
+            let mut diag: *mut HedlDiagnostics = ptr::null_mut();
+            hedl_lint(doc, &mut diag);
+            let warnings = (0..hedl_diagnostics_count(diag))
+                .filter(|&i| hedl_diagnostics_severity(diag, i) == 1)
+                .count();
+            assert!(warnings > 0);
+            assert_eq!(hedl_lint_warning_count(doc) as usize, warnings);
+            assert_eq!(hedl_lint_warning_count(ptr::null()), HEDL_ERR_NULL_PTR);
+
+            hedl_free_diagnostics(diag);
+            hedl_free_document(doc);
+        }
+    }
+
     #[cfg(feature = "json")]
     #[test]
     fn test_from_json_roundtrip() {
@@ -490,22 +794,1357 @@ mod tests {
         }
     }
 
-    #[cfg(feature = "neo4j")]
+    #[cfg(feature = "toml")]
     #[test]
-    fn test_to_neo4j_cypher() {
+    fn test_to_toml() {
+        const TEAM_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: User: [id, name, manager]\n\
+            %STRUCT: Post: [id, title]\n%NEST: User > Post\n---\n\
+            server:\n  host: localhost\n  port: 8080\n\
+            users: @User\n  |[1] alice, Alice, ~\n    | p1, Hello\n  | bob, Bob, @User:alice\n\0";
         unsafe {
             let mut doc: *mut HedlDocument = ptr::null_mut();
-            hedl_parse(VALID_HEDL.as_ptr() as *const c_char, -1, 0, &mut doc);
+            let result = hedl_parse(TEAM_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+            assert_eq!(result, HEDL_OK);
 
             let mut out_str: *mut c_char = ptr::null_mut();
-            let result = hedl_to_neo4j_cypher(doc, 1, &mut out_str);
+            assert_eq!(hedl_to_toml(doc, &mut out_str), HEDL_OK);
+            assert_eq!(
+                CStr::from_ptr(out_str).to_str().unwrap(),
+                "[server]\nhost = \"localhost\"\nport = 8080\n\n\
+                 [[users]]\nid = \"alice\"\nname = \"Alice\"\n\n\
+                 [[users.Post]]\nid = \"p1\"\ntitle = \"Hello\"\n\n\
+                 [[users]]\nid = \"bob\"\nname = \"Bob\"\nmanager = \"@User:alice\"\n"
+            );
+
+            let mut round_trip: *mut HedlDocument = ptr::null_mut();
+            assert_eq!(hedl_from_toml(out_str, -1, &mut round_trip), HEDL_OK);
+            hedl_free_document(round_trip);
+            hedl_free_string(out_str);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[cfg(feature = "toml")]
+    #[test]
+    fn test_from_toml() {
+        const SERVICE_TOML: &[u8] = b"title = \"svc\"\n\n[server]\nport = 8080\n\
+            started = 2024-01-02T03:04:05Z\n\n[[users]]\nid = \"alice\"\n\n[[users]]\nid = \"bob\"\n\0";
 
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            let result = hedl_from_toml(SERVICE_TOML.as_ptr() as *const c_char, -1, &mut doc);
             assert_eq!(result, HEDL_OK);
-            assert!(!out_str.is_null());
 
-            // The simple key: value doc doesn't produce Cypher nodes,
-            // but the function should succeed
+            let inner = &(*doc).inner;
+            assert!(inner.structs.contains_key("User"));
+            match inner.root.get("users") {
+                Some(hedl_core::Item::List(list)) => assert_eq!(list.rows.len(), 2),
+                other => panic!("expected users list, got {:?}", other),
+            }
+            match inner.root.get("server") {
+                Some(hedl_core::Item::Object(server)) => match server.get("started") {
+                    Some(hedl_core::Item::Scalar(hedl_core::Value::String(s))) => {
+                        assert_eq!(s, "2024-01-02T03:04:05Z")
+                    }
+                    other => panic!("expected the datetime as a string, got {:?}", other),
+                },
+                other => panic!("expected server object, got {:?}", other),
+            }
+            hedl_free_document(doc);
+
+            for invalid in [&b"[server]\nport = 80 80\n\0"[..], b"x = inf\n\0"] {
+                let mut doc: *mut HedlDocument = ptr::null_mut();
+                let result = hedl_from_toml(invalid.as_ptr() as *const c_char, -1, &mut doc);
+                assert_eq!(result, HEDL_ERR_TOML);
+                assert!(doc.is_null());
+            }
+        }
+    }
+
+    #[cfg(feature = "sqlite")]
+    #[test]
+    fn test_to_sqlite() {
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(TABLE_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+
+            let mut data: *mut u8 = ptr::null_mut();
+            let mut len: usize = 0;
+            let result = hedl_to_sqlite(doc, &mut data, &mut len);
+            assert_eq!(result, HEDL_OK);
+            let bytes = std::slice::from_raw_parts(data, len);
+            assert!(bytes.starts_with(b"SQLite format 3\0"));
+            let ddl = b"CREATE TABLE \"User\" (\"id\" TEXT, \"name\" TEXT, \"role\" TEXT)";
+            assert!(bytes.windows(ddl.len()).any(|w| w == ddl));
+            hedl_free_bytes(data, len);
+
+            let result = hedl_to_sqlite(ptr::null(), &mut data, &mut len);
+            assert_eq!(result, HEDL_ERR_NULL_PTR);
+
+            hedl_free_document(doc);
+        }
+    }
+
+    #[cfg(feature = "json")]
+    #[test]
+    fn test_to_grouped_json() {
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(TABLE_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+
+            let mut out_str: *mut c_char = ptr::null_mut();
+            let result = hedl_to_grouped_json(
+                doc,
+                b"User\0".as_ptr() as *const c_char,
+                b"role\0".as_ptr() as *const c_char,
+                &mut out_str,
+            );
+            assert_eq!(result, HEDL_OK);
+
+            let json = CStr::from_ptr(out_str).to_str().unwrap();
+            let admin = json.find("\"admin\"").expect("admin group");
+            let user = json.find("\"user\"").expect("user group");
+            assert!(admin < user);
+            assert!(json.contains("carol"));
+            hedl_free_string(out_str);
+
+            for (schema, field) in [
+                (&b"User\0"[..], &b"missing\0"[..]),
+                (b"Missing\0", b"role\0"),
+            ] {
+                let result = hedl_to_grouped_json(
+                    doc,
+                    schema.as_ptr() as *const c_char,
+                    field.as_ptr() as *const c_char,
+                    &mut out_str,
+                );
+                assert_eq!(result, HEDL_ERR_NOT_FOUND);
+                assert!(out_str.is_null());
+            }
+
+            hedl_free_document(doc);
+        }
+    }
+
+    #[cfg(feature = "json")]
+    #[test]
+    fn test_example_json() {
+        const EXAMPLE_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: User: [id, email]\n\
+            %STRUCT: Tag: [id]\n---\nusers: @User\n  | u1, ~\n  | u2, b@example.com\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(EXAMPLE_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+
+            let mut out_str: *mut c_char = ptr::null_mut();
+            let result = hedl_example_json(doc, &mut out_str);
+            assert_eq!(result, HEDL_OK);
+            let json: serde_json::Value =
+                serde_json::from_str(CStr::from_ptr(out_str).to_str().unwrap()).unwrap();
+            assert_eq!(json["User"]["id"], "u1");
+            assert_eq!(json["User"]["email"], "b@example.com");
+            assert_eq!(json["Tag"]["id"], "example");
+            hedl_free_string(out_str);
+
+            let result = hedl_example_json(ptr::null(), &mut out_str);
+            assert_eq!(result, HEDL_ERR_NULL_PTR);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[cfg(feature = "json")]
+    #[test]
+    fn test_to_json_page() {
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(TABLE_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+            let schema = b"User\0".as_ptr() as *const c_char;
+
+            for (offset, limit, ids) in [
+                (1, 5, &["bob", "carol"][..]),
+                (-2, 1, &["alice"]),
+                (10, 1, &[]),
+                (0, -1, &[]),
+            ] {
+                let mut out_str: *mut c_char = ptr::null_mut();
+                let result = hedl_to_json_page(doc, schema, offset, limit, &mut out_str);
+                assert_eq!(result, HEDL_OK);
+                let json = CStr::from_ptr(out_str).to_str().unwrap().to_string();
+                hedl_free_string(out_str);
+
+                assert!(json.starts_with('[') && json.ends_with(']'), "{}", json);
+                assert_eq!(json.matches("\"id\":").count(), ids.len(), "{}", json);
+                for id in ids {
+                    assert!(json.contains(&format!("\"id\":\"{}\"", id)), "{}", json);
+                }
+            }
+
+            let mut out_str: *mut c_char = ptr::null_mut();
+            let missing = b"Missing\0".as_ptr() as *const c_char;
+            let result = hedl_to_json_page(doc, missing, 0, 1, &mut out_str);
+            assert_eq!(result, HEDL_ERR_NOT_FOUND);
+            assert!(out_str.is_null());
+
+            hedl_free_document(doc);
+        }
+    }
+
+    #[cfg(feature = "json")]
+    #[test]
+    fn test_get_by_key() {
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(TABLE_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+            let schema = b"User\0".as_ptr() as *const c_char;
+            let id = b"id\0".as_ptr() as *const c_char;
+            let role = b"role\0".as_ptr() as *const c_char;
+
+            let mut out_str: *mut c_char = ptr::null_mut();
+            let bob = b"bob\0".as_ptr() as *const c_char;
+            let result = hedl_get_by_key(doc, schema, id, bob, 0, &mut out_str);
+            assert_eq!(result, HEDL_OK);
+            let json = CStr::from_ptr(out_str).to_str().unwrap();
+            assert!(
+                json.starts_with('{') && json.contains("\"name\":\"Bob\""),
+                "{}",
+                json
+            );
+            hedl_free_string(out_str);
+
+            let admin = b"admin\0".as_ptr() as *const c_char;
+            let result = hedl_get_by_key(doc, schema, role, admin, 0, &mut out_str);
+            assert_eq!(result, HEDL_ERR_CONFLICT);
+            assert!(out_str.is_null());
+
+            let result = hedl_get_by_key(doc, schema, role, admin, 1, &mut out_str);
+            assert_eq!(result, HEDL_OK);
+            let json = CStr::from_ptr(out_str).to_str().unwrap();
+            assert!(json.contains("\"id\":\"alice\""), "{}", json);
+            hedl_free_string(out_str);
+
+            let mallory = b"mallory\0".as_ptr() as *const c_char;
+            let missing = b"missing\0".as_ptr() as *const c_char;
+            for (field, value) in [(id, mallory), (missing, bob)] {
+                let result = hedl_get_by_key(doc, schema, field, value, 0, &mut out_str);
+                assert_eq!(result, HEDL_ERR_NOT_FOUND);
+                assert!(out_str.is_null());
+            }
+
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_partition() {
+        const TAGS_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: Item: [id, tag]\n---\n\
+            items: @Item\n  | a, ~\n  | b, \"~\"\n  | c, \"\"\n  | d, true\n  | e, \"true\"\n\
+            \x20 | f, 1\n  | g, \"1\"\n\0";
+
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            let result = hedl_parse(TAGS_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+            assert_eq!(result, HEDL_OK);
+
+            let schema = b"Item\0".as_ptr() as *const c_char;
+            let field = b"tag\0".as_ptr() as *const c_char;
+            let mut out_str: *mut c_char = ptr::null_mut();
+            let result = hedl_partition_keys(doc, schema, field, &mut out_str);
+            assert_eq!(result, HEDL_OK);
+            let keys = CStr::from_ptr(out_str).to_str().unwrap().to_string();
             hedl_free_string(out_str);
+            assert_eq!(
+                keys.split('\n').collect::<Vec<_>>(),
+                ["\"\"", "\"1\"", "\"true\"", "\"~\"", "1", "true", "~"]
+            );
+
+            for (key, id) in [
+                (&b"~\0"[..], "a"),
+                (b"\"~\"\0", "b"),
+                (b"\"\"\0", "c"),
+                (b"true\0", "d"),
+                (b"\"1\"\0", "g"),
+            ] {
+                let mut part: *mut HedlDocument = ptr::null_mut();
+                let result =
+                    hedl_partition(doc, schema, field, key.as_ptr() as *const c_char, &mut part);
+                assert_eq!(result, HEDL_OK);
+                match (*part).inner.root.get("items") {
+                    Some(hedl_core::Item::List(list)) => {
+                        assert_eq!(list.rows.len(), 1);
+                        assert_eq!(list.rows[0].id, id);
+                    }
+                    other => panic!("expected items list, got {:?}", other),
+                }
+                hedl_free_document(part);
+            }
+
+            for (schema, field) in [
+                (&b"Item\0"[..], &b"missing\0"[..]),
+                (b"Missing\0", b"tag\0"),
+            ] {
+                let schema = schema.as_ptr() as *const c_char;
+                let field = field.as_ptr() as *const c_char;
+                let result = hedl_partition_keys(doc, schema, field, &mut out_str);
+                assert_eq!(result, HEDL_ERR_NOT_FOUND);
+
+                let mut part: *mut HedlDocument = ptr::null_mut();
+                let key = b"~\0".as_ptr() as *const c_char;
+                let result = hedl_partition(doc, schema, field, key, &mut part);
+                assert_eq!(result, HEDL_ERR_NOT_FOUND);
+                assert!(part.is_null());
+            }
+
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_csv_cursor() {
+        const NOTES_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: Note: [id, body, score]\n---\n\
+            notes: @Note\n  | n1, \"a, b\", 1.0\n  | n2, plain, ~\n  | n3, \"say \"\"hi\"\"\", 2\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(NOTES_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+
+            let mut cursor: *mut HedlCsvCursor = ptr::null_mut();
+            let schema = b"Note\0".as_ptr() as *const c_char;
+            assert_eq!(hedl_csv_cursor_open(doc, schema, &mut cursor), HEDL_OK);
+
+            let mut csv = String::new();
+            let mut out_str: *mut c_char = ptr::null_mut();
+            let mut reads = Vec::new();
+            loop {
+                let rows = hedl_csv_cursor_next(cursor, 2, &mut out_str);
+                csv.push_str(CStr::from_ptr(out_str).to_str().unwrap());
+                hedl_free_string(out_str);
+                reads.push(rows);
+                if rows <= 0 {
+                    break;
+                }
+            }
+            hedl_free_csv_cursor(cursor);
+            assert_eq!(reads, vec![2, 1, 0]);
+            assert_eq!(
+                csv,
+                "id,body,score\nn1,\"a, b\",1.0\nn2,plain,\nn3,\"say \"\"hi\"\"\",2\n"
+            );
+
+            let missing = b"Missing\0".as_ptr() as *const c_char;
+            let result = hedl_csv_cursor_open(doc, missing, &mut cursor);
+            assert_eq!(result, HEDL_ERR_NOT_FOUND);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_dedup() {
+        const READINGS_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: Reading: [id, sensor, value]\n---\n\
+            readings: @Reading\n  | r1, temp, 20\n  | r2, temp, 20\n  | r3, temp, 21\n\
+            \x20 | r4, temp, 21\n  | r5, temp, 21\n  | r6, temp, 20\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(READINGS_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+            let schema = b"Reading\0".as_ptr() as *const c_char;
+
+            let mut removed = 0;
+            let result = hedl_dedup(doc, schema, ptr::null(), -1, &mut removed);
+            assert_eq!((result, removed), (HEDL_OK, 3));
+            let result = hedl_dedup(doc, schema, ptr::null(), -1, &mut removed);
+            assert_eq!((result, removed), (HEDL_OK, 0));
+
+            let fields = [b"sensor\0".as_ptr() as *const c_char];
+            let result = hedl_dedup(doc, schema, fields.as_ptr(), 1, &mut removed);
+            assert_eq!((result, removed), (HEDL_OK, 2));
+
+            let fields = [b"missing\0".as_ptr() as *const c_char];
+            let result = hedl_dedup(doc, schema, fields.as_ptr(), 1, &mut removed);
+            assert_eq!(result, HEDL_ERR_NOT_FOUND);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_normalize_dates() {
+        const ORDERS_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: Order: [id, placed, note]\n---\n\
+            orders: @Order\n  | o1, 01/02/2006, first\n  | o2, 2023-12-31T10:00:00+02:00, second\n\
+            \x20 | o3, someday, third\n  | o4, ~, fourth\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(ORDERS_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+            let fields = [b"Order.placed\0".as_ptr() as *const c_char];
+            let format = b"%Y-%m-%dT%H:%M:%S%#z\0".as_ptr() as *const c_char;
+
+            let mut normalized: *mut HedlDocument = ptr::null_mut();
+            let mut diag: *mut HedlDiagnostics = ptr::null_mut();
+            let result =
+                hedl_normalize_dates(doc, fields.as_ptr(), 1, format, &mut normalized, &mut diag);
+            assert_eq!(result, HEDL_OK);
+            assert_eq!(hedl_diagnostics_count(diag), 1);
+
+            let mut out_str: *mut c_char = ptr::null_mut();
+            hedl_canonicalize(normalized, &mut out_str);
+            let canonical = CStr::from_ptr(out_str).to_str().unwrap();
+            for want in [
+                "2006-01-02T00:00:00Z",
+                "2023-12-31T10:00:00+02:00",
+                "someday",
+            ] {
+                assert!(canonical.contains(want), "{}", canonical);
+            }
+            hedl_free_string(out_str);
+            hedl_free_diagnostics(diag);
+            hedl_free_document(normalized);
+
+            let missing = [b"Order.missing\0".as_ptr() as *const c_char];
+            let result =
+                hedl_normalize_dates(doc, missing.as_ptr(), 1, format, &mut normalized, &mut diag);
+            assert_eq!(result, HEDL_ERR_NOT_FOUND);
+            assert!(normalized.is_null() && diag.is_null());
+
+            let format = b"%Q\0".as_ptr() as *const c_char;
+            let result =
+                hedl_normalize_dates(doc, fields.as_ptr(), 1, format, &mut normalized, &mut diag);
+            assert_eq!(result, HEDL_ERR_INVALID_ARGUMENT);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[cfg(feature = "neo4j")]
+    #[test]
+    fn test_to_neo4j_cypher() {
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(VALID_HEDL.as_ptr() as *const c_char, -1, 0, &mut doc);
+
+            let mut out_str: *mut c_char = ptr::null_mut();
+            let result = hedl_to_neo4j_cypher(doc, 1, &mut out_str);
+
+            assert_eq!(result, HEDL_OK);
+            assert!(!out_str.is_null());
+
+            // The simple key: value doc doesn't produce Cypher nodes,
+            // but the function should succeed
+            hedl_free_string(out_str);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_check_schema_references() {
+        const UNDEFINED_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: Order: [id, customer]\n---\n\
+            owner: @Team:t1\norders: @Order\n  | o1, @Customer:c1\n  | o2, @Order:o1\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(UNDEFINED_HEDL.as_ptr() as *const c_char, -1, 0, &mut doc);
+
+            let mut diag: *mut HedlDiagnostics = ptr::null_mut();
+            assert_eq!(hedl_check_schema_references(doc, &mut diag), HEDL_OK);
+            assert_eq!(hedl_diagnostics_count(diag), 2);
+            let mut message: *mut c_char = ptr::null_mut();
+            assert_eq!(hedl_diagnostics_get(diag, 1, &mut message), HEDL_OK);
+            assert_eq!(
+                CStr::from_ptr(message).to_str().unwrap(),
+                "[undefined-schema] error: Order row 0 field \"customer\" references undefined schema \"Customer\" (@Customer:c1)"
+            );
+            hedl_free_string(message);
+            hedl_free_diagnostics(diag);
+            hedl_free_document(doc);
+
+            assert_eq!(
+                hedl_check_schema_references(ptr::null(), &mut diag),
+                HEDL_ERR_NULL_PTR
+            );
+        }
+    }
+
+    #[test]
+    fn test_to_mermaid_er() {
+        const ORDERS_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: Customer: [id, name]\n\
+            %STRUCT: Order: [id, customer, total]\n---\ncustomers: @Customer\n  | c1, Ann\n\
+            orders: @Order\n  | o1, @Customer:c1, 5\n  | o2, @Customer:c1, 7.5\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(ORDERS_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+
+            let mut out_str: *mut c_char = ptr::null_mut();
+            assert_eq!(hedl_to_mermaid_er(doc, &mut out_str), HEDL_OK);
+            assert_eq!(
+                CStr::from_ptr(out_str).to_str().unwrap(),
+                "erDiagram\n    Customer {\n        string id\n        string name\n    }\n\
+                 \x20   Order {\n        string id\n        reference customer\n        float total\n\
+                 \x20   }\n    Order }o--|| Customer : \"customer\"\n"
+            );
+            hedl_free_string(out_str);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_rows_with_missing() {
+        const INCOMPLETE_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: User: [id, name, email]\n---\n\
+            users: @User\n  | alice, Alice, a@example.com\n  | bob, ~, b@example.com\n\
+            \x20 | carol, Carol, c@example.com\n  | dave, Dave, \"\"\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(INCOMPLETE_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+
+            let mut out_str: *mut c_char = ptr::null_mut();
+            let user = b"User\0".as_ptr() as *const c_char;
+            assert_eq!(hedl_rows_with_missing(doc, user, &mut out_str), HEDL_OK);
+            assert_eq!(CStr::from_ptr(out_str).to_str().unwrap(), "1\n3");
+            hedl_free_string(out_str);
+
+            let missing = b"Missing\0".as_ptr() as *const c_char;
+            let result = hedl_rows_with_missing(doc, missing, &mut out_str);
+            assert_eq!(result, HEDL_ERR_NOT_FOUND);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_schema_checksum() {
+        unsafe fn checksum(hedl: &[u8]) -> String {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(hedl.as_ptr() as *const c_char, -1, 1, &mut doc);
+            let mut out_str: *mut c_char = ptr::null_mut();
+            let user = b"User\0".as_ptr() as *const c_char;
+            assert_eq!(hedl_schema_checksum(doc, user, &mut out_str), HEDL_OK);
+            let sum = CStr::from_ptr(out_str).to_str().unwrap().to_string();
+            hedl_free_string(out_str);
+            hedl_free_document(doc);
+            sum
+        }
+
+        unsafe {
+            let base = checksum(TABLE_HEDL);
+            assert_eq!(base.len(), 64);
+            let other_rows = b"%VERSION: 1.0\n%STRUCT: User: [id, name, role]\n---\n\
+                users: @User\n  | dave, Dave, user\n\0";
+            assert_eq!(checksum(other_rows), base);
+            let added_field = b"%VERSION: 1.0\n%STRUCT: User: [id, name, role, email]\n---\n\
+                users: @User\n  | dave, Dave, user, ~\n\0";
+            assert_ne!(checksum(added_field), base);
+
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(TABLE_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+            let mut out_str: *mut c_char = ptr::null_mut();
+            let missing = b"Missing\0".as_ptr() as *const c_char;
+            let result = hedl_schema_checksum(doc, missing, &mut out_str);
+            assert_eq!(result, HEDL_ERR_NOT_FOUND);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_head_and_tail() {
+        unsafe fn ids(doc: *const HedlDocument) -> Vec<String> {
+            match (*doc).inner.root.get("users") {
+                Some(hedl_core::Item::List(list)) => {
+                    assert_eq!(list.count_hint, None);
+                    list.rows.iter().map(|row| row.id.clone()).collect()
+                }
+                other => panic!("expected users list, got {:?}", other),
+            }
+        }
+
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(TABLE_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+
+            let mut kept: *mut HedlDocument = ptr::null_mut();
+            assert_eq!(hedl_tail(doc, 2, &mut kept), HEDL_OK);
+            assert_eq!(ids(kept), ["bob", "carol"]);
+            hedl_free_document(kept);
+
+            assert_eq!(hedl_head(doc, 2, &mut kept), HEDL_OK);
+            assert_eq!(ids(kept), ["alice", "bob"]);
+            hedl_free_document(kept);
+
+            assert_eq!(hedl_tail(doc, 10, &mut kept), HEDL_OK);
+            assert_eq!(ids(kept), ["alice", "bob", "carol"]);
+            hedl_free_document(kept);
+            assert_eq!(ids(doc).len(), 3);
+
+            assert_eq!(hedl_head(doc, 0, &mut kept), HEDL_OK);
+            assert!(ids(kept).is_empty());
+            hedl_free_document(kept);
+
+            assert_eq!(hedl_tail(doc, -1, &mut kept), HEDL_ERR_INVALID_ARGUMENT);
+            assert!(kept.is_null());
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_rename() {
+        const TEAMS_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: Team: [id, name]\n\
+            %STRUCT: Member: [id, team]\n%NEST: Team > Member\n---\nlead: @Team:core\n\
+            teams: @Team\n  | core, Core\n    | m1, @Team:core\n\0";
+        unsafe fn canonical(doc: *const HedlDocument) -> String {
+            let mut out_str: *mut c_char = ptr::null_mut();
+            hedl_canonicalize(doc, &mut out_str);
+            let canonical = CStr::from_ptr(out_str).to_str().unwrap().to_string();
+            hedl_free_string(out_str);
+            canonical
+        }
+
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(TEAMS_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+            let team = b"Team\0".as_ptr() as *const c_char;
+            let name = b"name\0".as_ptr() as *const c_char;
+            let title = b"title\0".as_ptr() as *const c_char;
+            let id = b"id\0".as_ptr() as *const c_char;
+            let squad = b"Squad\0".as_ptr() as *const c_char;
+            let member = b"Member\0".as_ptr() as *const c_char;
+
+            assert_eq!(hedl_rename_field(doc, team, name, title), HEDL_OK);
+            assert_eq!(hedl_rename_field(doc, team, title, id), HEDL_ERR_CONFLICT);
+            assert_eq!(hedl_rename_field(doc, team, name, id), HEDL_ERR_NOT_FOUND);
+            assert_eq!(hedl_rename_schema(doc, team, member), HEDL_ERR_CONFLICT);
+            assert_eq!(hedl_rename_schema(doc, team, squad), HEDL_OK);
+            assert_eq!(hedl_rename_schema(doc, team, squad), HEDL_ERR_NOT_FOUND);
+
+            let canonical = canonical(doc);
+            assert!(canonical.contains("%STRUCT: Squad"), "{}", canonical);
+            assert!(canonical.contains("[id,title]"), "{}", canonical);
+            assert!(canonical.contains("%NEST: Squad > Member"), "{}", canonical);
+            assert!(!canonical.contains("Team"), "{}", canonical);
+            assert_eq!(canonical.matches("@Squad:core").count(), 2, "{}", canonical);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_find_reference_cycle() {
+        unsafe fn cycle(hedl: &[u8]) -> String {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(hedl.as_ptr() as *const c_char, -1, 0, &mut doc);
+            let mut out_str: *mut c_char = ptr::null_mut();
+            assert_eq!(hedl_find_reference_cycle(doc, &mut out_str), HEDL_OK);
+            let cycle = CStr::from_ptr(out_str).to_str().unwrap().to_string();
+            hedl_free_string(out_str);
+            hedl_free_document(doc);
+            cycle
+        }
+
+        unsafe {
+            let cyclic = b"%VERSION: 1.0\n%STRUCT: Person: [id, friend]\n---\n\
+                people: @Person\n  | ann, @bob\n  | bob, @Person:cat\n  | cat, @bob\n\0";
+            assert_eq!(cycle(cyclic), "Person:bob\nPerson:cat");
+            let own = b"%VERSION: 1.0\n%STRUCT: Person: [id, friend]\n---\n\
+                people: @Person\n  | ann, @ann\n\0";
+            assert_eq!(cycle(own), "Person:ann");
+            let acyclic = b"%VERSION: 1.0\n%STRUCT: Person: [id, friend]\n---\n\
+                people: @Person\n  | ann, @bob\n  | bob, @nobody\n\0";
+            assert_eq!(cycle(acyclic), "");
+        }
+    }
+
+    #[test]
+    fn test_canonicalize_path() {
+        const CONFIG_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: Host: [id, port]\n\
+            %STRUCT: User: [id]\n---\nconfig:\n  name: demo\n  database:\n\
+            \x20   hosts: @Host\n      | primary, 5432\nusers: @User\n  | alice\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(CONFIG_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+
+            let mut out_str: *mut c_char = ptr::null_mut();
+            let path = b"config.database\0".as_ptr() as *const c_char;
+            assert_eq!(hedl_canonicalize_path(doc, path, &mut out_str), HEDL_OK);
+            let canonical = CStr::from_ptr(out_str).to_str().unwrap().to_string();
+            hedl_free_string(out_str);
+            assert!(canonical.contains("%STRUCT: Host"), "{}", canonical);
+            assert!(canonical.contains("database:"), "{}", canonical);
+            assert!(canonical.contains("primary"), "{}", canonical);
+            assert!(!canonical.contains("User"), "{}", canonical);
+            assert!(!canonical.contains("config"), "{}", canonical);
+
+            for missing in [&b"config.missing\0"[..], b"config.name.value\0", b"\0"] {
+                let path = missing.as_ptr() as *const c_char;
+                let result = hedl_canonicalize_path(doc, path, &mut out_str);
+                assert_eq!(result, HEDL_ERR_NOT_FOUND);
+            }
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_to_git_friendly() {
+        const EVENTS_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: Event: [id, day, at, room]\n---\n\
+            name: launch\nevents: @Event\n  | e1, 2024-1-5, 2024-01-05T10:00:00.500+00:00, a\n\
+            \x20 | e2, 2024-2-30, 2024-01-05T10:00:00-05:00, a\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(EVENTS_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+
+            let mut out_str: *mut c_char = ptr::null_mut();
+            assert_eq!(hedl_to_git_friendly(doc, &mut out_str), HEDL_OK);
+            let output = CStr::from_ptr(out_str).to_str().unwrap().to_string();
+            hedl_free_string(out_str);
+            hedl_free_document(doc);
+
+            for want in [
+                "|e1,2024-01-05,2024-01-05T10:00:00.5Z,a",
+                "|e2,2024-2-30,2024-01-05T10:00:00-05:00,a",
+            ] {
+                assert!(output.contains(want), "{}", output);
+            }
+            assert!(!output.contains('^'), "{}", output);
+        }
+    }
+
+    #[test]
+    fn test_normalize_references() {
+        const OWNERS_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: User: [id, name]\n---\n\
+            owner: @alice\nbackup: @User:bob\nmissing: @User:nobody\n\
+            users: @User\n  | alice, Alice\n  | bob, Bob\n\0";
+        unsafe fn canonical(doc: *const HedlDocument) -> String {
+            let mut out_str: *mut c_char = ptr::null_mut();
+            hedl_canonicalize(doc, &mut out_str);
+            let canonical = CStr::from_ptr(out_str).to_str().unwrap().to_string();
+            hedl_free_string(out_str);
+            canonical
+        }
+
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(OWNERS_HEDL.as_ptr() as *const c_char, -1, 0, &mut doc);
+            let inline = b"inline\0".as_ptr() as *const c_char;
+            let id = b"id\0".as_ptr() as *const c_char;
+
+            assert_eq!(hedl_normalize_references(doc, id), HEDL_OK);
+            let qualified = canonical(doc);
+            assert!(qualified.contains("owner: @User:alice"), "{}", qualified);
+
+            assert_eq!(hedl_normalize_references(doc, inline), HEDL_OK);
+            let inlined = canonical(doc);
+            assert!(inlined.contains("__type__: User"), "{}", inlined);
+            assert!(inlined.contains("name: Bob"), "{}", inlined);
+            assert!(inlined.contains("missing: @User:nobody"), "{}", inlined);
+
+            assert_eq!(hedl_normalize_references(doc, id), HEDL_OK);
+            assert_eq!(canonical(doc), qualified);
+
+            let mode = b"pointer\0".as_ptr() as *const c_char;
+            let result = hedl_normalize_references(doc, mode);
+            assert_eq!(result, HEDL_ERR_INVALID_ARGUMENT);
+            hedl_free_document(doc);
+
+            const CELLS_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: User: [id, manager]\n---\n\
+                users: @User\n  | alice, ~\n  | bob, @alice\n\0";
+            hedl_parse(CELLS_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+            let before = canonical(doc);
+            let result = hedl_normalize_references(doc, inline);
+            assert_eq!(result, HEDL_ERR_INVALID_ARGUMENT);
+            assert_eq!(canonical(doc), before);
+            assert_eq!(hedl_normalize_references(doc, id), HEDL_OK);
+            assert!(canonical(doc).contains("bob,@User:alice"));
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_check_unique() {
+        const EMAILS_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: User: [id, email]\n---\n\
+            users: @User\n  | u1, a@example.com\n  | u2, ~\n  | u3, a@example.com\n  | u4, ~\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(EMAILS_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+
+            let email = b"email\0".as_ptr() as *const c_char;
+            let user = b"User\0".as_ptr() as *const c_char;
+            let mut diag: *mut HedlDiagnostics = ptr::null_mut();
+            assert_eq!(hedl_check_unique(doc, user, email, &mut diag), HEDL_OK);
+            assert_eq!(hedl_diagnostics_count(diag), 1);
+            let mut message: *mut c_char = ptr::null_mut();
+            assert_eq!(hedl_diagnostics_get(diag, 0, &mut message), HEDL_OK);
+            assert_eq!(
+                CStr::from_ptr(message).to_str().unwrap(),
+                "[unique] error: User field \"email\" value \"a@example.com\" is repeated in rows 0, 2"
+            );
+            hedl_free_string(message);
+            hedl_free_diagnostics(diag);
+
+            let name = b"name\0".as_ptr() as *const c_char;
+            let result = hedl_check_unique(doc, user, name, &mut diag);
+            assert_eq!(result, HEDL_ERR_NOT_FOUND);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_join() {
+        const USERS_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: User: [id, name]\n---\n\
+            users: @User\n  | alice, Alice\n  | bob, Bob\n\0";
+        const PROFILES_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: User: [id, age, country]\n---\n\
+            users: @User\n  | alice, 30, NL\n  | carol, 41, DE\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(USERS_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+            let mut other: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(PROFILES_HEDL.as_ptr() as *const c_char, -1, 1, &mut other);
+
+            let user = b"User\0".as_ptr() as *const c_char;
+            let id = b"id\0".as_ptr() as *const c_char;
+            let fields = [
+                b"age\0".as_ptr() as *const c_char,
+                b"country\0".as_ptr() as *const c_char,
+            ];
+            let mut joined: *mut HedlDocument = ptr::null_mut();
+            let result = hedl_join(doc, other, user, id, fields.as_ptr(), 2, &mut joined);
+            assert_eq!(result, HEDL_OK);
+            let mut out_str: *mut c_char = ptr::null_mut();
+            assert_eq!(hedl_canonicalize(joined, &mut out_str), HEDL_OK);
+            let canonical = CStr::from_ptr(out_str).to_str().unwrap();
+            assert!(canonical.contains("[id,name,age,country]"));
+            assert!(canonical.contains("|alice,Alice,30,NL"));
+            assert!(canonical.contains("|bob,Bob,~,~"));
+            assert!(!canonical.contains("carol"));
+            hedl_free_string(out_str);
+            hedl_free_document(joined);
+
+            let result = hedl_join(doc, doc, user, id, fields.as_ptr(), 2, &mut joined);
+            assert_eq!(result, HEDL_ERR_NOT_FOUND);
+            let result = hedl_join(other, doc, user, id, fields.as_ptr(), 1, &mut joined);
+            assert_eq!(result, HEDL_ERR_CONFLICT);
+            hedl_free_document(other);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_check_ranges() {
+        const SALARIES_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: Employee: [id, salary]\n---\n\
+            staff: @Employee\n  | e1, 52000\n  | e2, -100\n  | e3, 4.5\n  | e4, high\n  | e5, ~\n\0";
+        unsafe fn check(
+            doc: *const HedlDocument,
+            field: &[u8],
+            min: f64,
+            max: f64,
+            diag: &mut *mut HedlDiagnostics,
+        ) -> c_int {
+            let fields = [field.as_ptr() as *const c_char];
+            hedl_check_ranges(doc, fields.as_ptr(), &min, &max, 1, diag)
+        }
+
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(SALARIES_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+
+            let mut diag: *mut HedlDiagnostics = ptr::null_mut();
+            let salary = b"Employee.salary\0";
+            assert_eq!(check(doc, salary, 0.0, 50000.0, &mut diag), HEDL_OK);
+            assert_eq!(hedl_diagnostics_count(diag), 3);
+            let mut message: *mut c_char = ptr::null_mut();
+            assert_eq!(hedl_diagnostics_get(diag, 0, &mut message), HEDL_OK);
+            assert_eq!(
+                CStr::from_ptr(message).to_str().unwrap(),
+                "[range] error: Employee row 0 field \"salary\" is outside [0, 50000] (got 52000)"
+            );
+            hedl_free_string(message);
+            assert_eq!(hedl_diagnostics_get(diag, 2, &mut message), HEDL_OK);
+            assert!(CStr::from_ptr(message)
+                .to_str()
+                .unwrap()
+                .ends_with("row 3 field \"salary\" is not a number (got high)"));
+            hedl_free_string(message);
+            hedl_free_diagnostics(diag);
+
+            let result = check(doc, salary, 10.0, 0.0, &mut diag);
+            assert_eq!(result, HEDL_ERR_INVALID_ARGUMENT);
+            let result = check(doc, b"Employee.bonus\0", 0.0, 1.0, &mut diag);
+            assert_eq!(result, HEDL_ERR_NOT_FOUND);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_to_properties() {
+        const CONFIG_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: Replica: [id, host]\n---\n\
+            config:\n  database:\n    host: localhost\n    port: 5432\n    replicas: @Replica\n\
+            \x20     | r1, db1.internal\n  note: \"url=http://x#y\"\n  padded: \" padded\"\n\
+            \x20 city: caf\xc3\xa9\n  owner: ~\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(CONFIG_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+
+            let mut out_str: *mut c_char = ptr::null_mut();
+            assert_eq!(hedl_to_properties(doc, &mut out_str), HEDL_OK);
+            assert_eq!(
+                CStr::from_ptr(out_str).to_str().unwrap(),
+                "config.city=caf\\u00E9\nconfig.database.host=localhost\n\
+                 config.database.port=5432\nconfig.database.replicas.0.id=r1\n\
+                 config.database.replicas.0.host=db1.internal\n\
+                 config.note=url\\=http\\://x\\#y\nconfig.padded=\\ padded\n"
+            );
+            hedl_free_string(out_str);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_check_foreign_keys() {
+        const ORDERS_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: User: [id, name]\n\
+            %STRUCT: Order: [id, user]\n---\nusers: @User\n  | alice, Alice\n  | bob, Bob\n\
+            orders: @Order\n  | o1, alice\n  | o2, zed\n  | o3, @User:bob\n  | o4, @Order:o1\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(ORDERS_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+
+            let from = [b"Order.user\0".as_ptr() as *const c_char];
+            let to = [b"User.id\0".as_ptr() as *const c_char];
+            let mut diag: *mut HedlDiagnostics = ptr::null_mut();
+            let result = hedl_check_foreign_keys(doc, from.as_ptr(), to.as_ptr(), 1, &mut diag);
+            assert_eq!(result, HEDL_OK);
+            assert_eq!(hedl_diagnostics_count(diag), 2);
+            let mut message: *mut c_char = ptr::null_mut();
+            assert_eq!(hedl_diagnostics_get(diag, 0, &mut message), HEDL_OK);
+            assert_eq!(
+                CStr::from_ptr(message).to_str().unwrap(),
+                "[foreign-key] error: Order row 1 has no match for Order.user -> User.id (got zed)"
+            );
+            hedl_free_string(message);
+            hedl_free_diagnostics(diag);
+
+            let to = [b"Customer.id\0".as_ptr() as *const c_char];
+            let result = hedl_check_foreign_keys(doc, from.as_ptr(), to.as_ptr(), 1, &mut diag);
+            assert_eq!(result, HEDL_ERR_NOT_FOUND);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_unused_fields() {
+        const SPARSE_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: User: [id, name, fax, note]\n---\n\
+            users: @User\n  | alice, Alice, ~, \"\"\n  | bob, ~, ~, hi\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(SPARSE_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+
+            let mut out_str: *mut c_char = ptr::null_mut();
+            let user = b"User\0".as_ptr() as *const c_char;
+            assert_eq!(hedl_unused_fields(doc, user, &mut out_str), HEDL_OK);
+            assert_eq!(CStr::from_ptr(out_str).to_str().unwrap(), "fax");
+            hedl_free_string(out_str);
+
+            let missing = b"Team\0".as_ptr() as *const c_char;
+            let result = hedl_unused_fields(doc, missing, &mut out_str);
+            assert_eq!(result, HEDL_ERR_NOT_FOUND);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_mixed_type_fields() {
+        const READINGS_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: Reading: [id, value, count]\n---\n\
+            readings: @Reading\n  | r1, 21.5, 3\n  | r2, offline, 2.5\n  | r3, 19, ~\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(READINGS_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+
+            let mut out_str: *mut c_char = ptr::null_mut();
+            let reading = b"Reading\0".as_ptr() as *const c_char;
+            assert_eq!(hedl_mixed_type_fields(doc, reading, &mut out_str), HEDL_OK);
+            assert_eq!(
+                CStr::from_ptr(out_str).to_str().unwrap(),
+                "value\tfloat\tstring"
+            );
+            hedl_free_string(out_str);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_check_whitespace_and_trim_strings() {
+        const PADDED_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: User: [id, name]\n---\n\
+            team: \" Core\"\nusers: @User\n  | u1, \" Alice \"\n  | u2, Bob\n\0";
+        unsafe fn warnings(doc: *const HedlDocument) -> Vec<String> {
+            let mut diag: *mut HedlDiagnostics = ptr::null_mut();
+            assert_eq!(hedl_check_whitespace(doc, &mut diag), HEDL_OK);
+            let mut messages = Vec::new();
+            for i in 0..hedl_diagnostics_count(diag) {
+                let mut message: *mut c_char = ptr::null_mut();
+                assert_eq!(hedl_diagnostics_get(diag, i, &mut message), HEDL_OK);
+                messages.push(CStr::from_ptr(message).to_str().unwrap().to_string());
+                hedl_free_string(message);
+            }
+            hedl_free_diagnostics(diag);
+            messages
+        }
+
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(PADDED_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+            assert_eq!(
+                warnings(doc),
+                [
+                    "[whitespace] warning: team has leading or trailing whitespace (\" Core\")",
+                    "[whitespace] warning: User row 0 field \"name\" has leading or trailing \
+                     whitespace (\" Alice \")",
+                ]
+            );
+
+            let mut trimmed: *mut HedlDocument = ptr::null_mut();
+            assert_eq!(hedl_trim_strings(doc, &mut trimmed), HEDL_OK);
+            assert!(warnings(trimmed).is_empty());
+            let mut out_str: *mut c_char = ptr::null_mut();
+            assert_eq!(hedl_canonicalize(trimmed, &mut out_str), HEDL_OK);
+            let canonical = CStr::from_ptr(out_str).to_str().unwrap();
+            assert!(canonical.contains("|u1,Alice"));
+            hedl_free_string(out_str);
+            hedl_free_document(trimmed);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_to_influx_line_protocol() {
+        const READINGS_HEDL: &[u8] = b"%VERSION: 1.0\n\
+            %STRUCT: Reading: [id, sensor, site, temp, ok, time]\n---\nreadings: @Reading\n\
+            \x20 | r1, s1, north, 21.5, true, 1700000000000000000\n\
+            \x20 | r2, s2, \"south east\", 19, false, \"2024-01-02\"\n\
+            \x20 | r3, s3, west, ~, ~, ~\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(READINGS_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+
+            let measurement = b"weather\0".as_ptr() as *const c_char;
+            let time = b"time\0".as_ptr() as *const c_char;
+            let tags = [b"sensor\0".as_ptr() as *const c_char];
+            let mut out_str: *mut c_char = ptr::null_mut();
+            let result = hedl_to_influx_line_protocol(
+                doc,
+                measurement,
+                time,
+                tags.as_ptr(),
+                1,
+                &mut out_str,
+            );
+            assert_eq!(result, HEDL_OK);
+            assert_eq!(
+                CStr::from_ptr(out_str).to_str().unwrap(),
+                "weather,sensor=s1 id=\"r1\",site=\"north\",temp=21.5,ok=true \
+                 1700000000000000000\n\
+                 weather,sensor=s2 id=\"r2\",site=\"south east\",temp=19,ok=false \
+                 1704153600000000000\n\
+                 weather,sensor=s3 id=\"r3\",site=\"west\"\n"
+            );
+            hedl_free_string(out_str);
+
+            let missing = b"timestamp\0".as_ptr() as *const c_char;
+            let result = hedl_to_influx_line_protocol(
+                doc,
+                measurement,
+                missing,
+                tags.as_ptr(),
+                1,
+                &mut out_str,
+            );
+            assert_eq!(result, HEDL_ERR_NOT_FOUND);
+            let empty = b"\0".as_ptr() as *const c_char;
+            let result =
+                hedl_to_influx_line_protocol(doc, empty, time, ptr::null(), 0, &mut out_str);
+            assert_eq!(result, HEDL_ERR_INVALID_ARGUMENT);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_auto_fix() {
+        const MESSY_HEDL: &[u8] = b"%VERSION: 1.0\n\
+            %STRUCT: User: [id, name, age, active, notes]\n%STRUCT: Tag: [id, label]\n---\n\
+            team: \"  Core  \"\nusers: @User\n\
+            \x20 | alice, \" Alice \", 30, true, \"line one\\r\\nline two\"\n\
+            \x20 | bob, Bob, \"41\", \"False\", ~\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(MESSY_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+
+            let mut fixed: *mut HedlDocument = ptr::null_mut();
+            let mut changes: *mut c_char = ptr::null_mut();
+            assert_eq!(hedl_auto_fix(doc, &mut fixed, &mut changes), HEDL_OK);
+            assert_eq!(
+                CStr::from_ptr(changes).to_str().unwrap(),
+                "trimmed whitespace in team\n\
+                 trimmed whitespace in User row 0 field \"name\"\n\
+                 normalized line endings in User row 0 field \"notes\"\n\
+                 coerced User row 1 field \"age\" from \"41\" to int\n\
+                 coerced User row 1 field \"active\" from \"False\" to bool\n\
+                 removed unused schema Tag"
+            );
+            hedl_free_string(changes);
+            assert_eq!(hedl_lint_warning_count(fixed), 0);
+
+            let mut again: *mut HedlDocument = ptr::null_mut();
+            assert_eq!(hedl_auto_fix(fixed, &mut again, &mut changes), HEDL_OK);
+            assert_eq!(CStr::from_ptr(changes).to_str().unwrap(), "");
+            hedl_free_string(changes);
+            hedl_free_document(again);
+            hedl_free_document(fixed);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_row_source_line() {
+        const PROJECTS_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: Project: [id, name]\n\
+            %STRUCT: Task: [id, title]\n%NEST: Project > Task\n---\n\
+            notes: \"\"\"\n| not, a row\n\"\"\"\nprojects: @Project\n  # Active projects\n\
+            \x20 |[2] apollo, Apollo\n    | t1, Design\n    | t2, Build\n\
+            \x20 |[1] zephyr, Zephyr\n    | t3, Launch\n\0";
+        unsafe {
+            let input = PROJECTS_HEDL.as_ptr() as *const c_char;
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            assert_eq!(hedl_parse_with_provenance(input, -1, 1, &mut doc), HEDL_OK);
+
+            let project = b"Project\0".as_ptr() as *const c_char;
+            let task = b"Task\0".as_ptr() as *const c_char;
+            let mut line: c_int = 0;
+            for (schema, index, want) in [(project, 0, 11), (project, 1, 14), (task, 2, 15)] {
+                assert_eq!(hedl_row_source_line(doc, schema, index, &mut line), HEDL_OK);
+                assert_eq!(line, want);
+            }
+            let result = hedl_row_source_line(doc, task, 3, &mut line);
+            assert_eq!(result, HEDL_ERR_INVALID_ARGUMENT);
+            hedl_free_document(doc);
+
+            hedl_parse(input, -1, 1, &mut doc);
+            let result = hedl_row_source_line(doc, project, 0, &mut line);
+            assert_eq!(result, HEDL_ERR_NOT_FOUND);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_fit_to_tokens() {
+        let mut input = String::from(
+            "%VERSION: 1.0\n%STRUCT: Event: [id, kind, message]\n---\n\
+             source: sensors\nevents: @Event\n",
+        );
+        for i in 0..200 {
+            input.push_str(&format!("  | e{}, reading, Temperature in range\n", i));
+        }
+        input.push('\0');
+        let model = b"gpt-4\0".as_ptr() as *const c_char;
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(input.as_ptr() as *const c_char, -1, 1, &mut doc);
+
+            let mut out_str: *mut c_char = ptr::null_mut();
+            let mut full: c_int = 0;
+            hedl_canonicalize(doc, &mut out_str);
+            assert_eq!(hedl_token_count(model, out_str, -1, &mut full), HEDL_OK);
+            hedl_free_string(out_str);
+
+            let mut diag: *mut HedlDiagnostics = ptr::null_mut();
+            let result = hedl_fit_to_tokens(doc, model, full / 4, &mut out_str, &mut diag);
+            assert_eq!(result, HEDL_OK);
+            let mut tokens: c_int = 0;
+            assert_eq!(hedl_token_count(model, out_str, -1, &mut tokens), HEDL_OK);
+            assert!(tokens <= full / 4);
+            let text = CStr::from_ptr(out_str).to_str().unwrap();
+            assert!(text.contains("source: sensors") && text.contains("e0,"));
+            hedl_free_string(out_str);
+            assert_eq!(hedl_diagnostics_count(diag), 1);
+            let mut message: *mut c_char = ptr::null_mut();
+            assert_eq!(hedl_diagnostics_get(diag, 0, &mut message), HEDL_OK);
+            let message_str = CStr::from_ptr(message).to_str().unwrap();
+            assert!(
+                message_str.starts_with("[truncated] warning: Event list truncated from 200 to")
+            );
+            hedl_free_string(message);
+            hedl_free_diagnostics(diag);
+
+            let result = hedl_fit_to_tokens(doc, model, full, &mut out_str, &mut diag);
+            assert_eq!(result, HEDL_OK);
+            assert_eq!(hedl_diagnostics_count(diag), 0);
+            hedl_free_string(out_str);
+            hedl_free_diagnostics(diag);
+
+            let unknown = b"no-such-model\0".as_ptr() as *const c_char;
+            let result = hedl_fit_to_tokens(doc, unknown, 100, &mut out_str, &mut diag);
+            assert_eq!(result, HEDL_ERR_INVALID_ARGUMENT);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_index_by() {
+        const MIXED_HEDL: &[u8] = b"%VERSION: 1.0\n---\n\
+            a: @User[id, name]\n  | u1, Alice\n  | u2, Alice\n\
+            b: @User[id, email]\n  | u3, carol@example.com\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(TABLE_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+            let schema = b"User\0".as_ptr() as *const c_char;
+            let id = b"id\0".as_ptr() as *const c_char;
+            let role = b"role\0".as_ptr() as *const c_char;
+
+            let mut out_str: *mut c_char = ptr::null_mut();
+            let result = hedl_index_by(doc, schema, id, 0, &mut out_str);
+            assert_eq!(result, HEDL_OK);
+            let json = CStr::from_ptr(out_str).to_str().unwrap();
+            assert!(
+                json.starts_with("{\"alice\":{") && json.contains("\"bob\":{\"id\":\"bob\""),
+                "{}",
+                json
+            );
+            hedl_free_string(out_str);
+
+            let result = hedl_index_by(doc, schema, role, 0, &mut out_str);
+            assert_eq!(result, HEDL_ERR_CONFLICT);
+            assert!(out_str.is_null());
+
+            let result = hedl_index_by(doc, schema, role, 1, &mut out_str);
+            assert_eq!(result, HEDL_OK);
+            let json = CStr::from_ptr(out_str).to_str().unwrap();
+            assert!(json.contains("\"admin\":{\"id\":\"carol\""), "{}", json);
+            hedl_free_string(out_str);
+
+            let missing = b"missing\0".as_ptr() as *const c_char;
+            let result = hedl_index_by(doc, schema, missing, 0, &mut out_str);
+            assert_eq!(result, HEDL_ERR_NOT_FOUND);
+            hedl_free_document(doc);
+
+            hedl_parse(MIXED_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+            let name = b"name\0".as_ptr() as *const c_char;
+            let result = hedl_index_by(doc, schema, name, 1, &mut out_str);
+            assert_eq!(result, HEDL_OK);
+            let json = CStr::from_ptr(out_str).to_str().unwrap();
+            assert_eq!(json, "{\"Alice\":{\"id\":\"u2\",\"name\":\"Alice\"}}");
+            hedl_free_string(out_str);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_field_stats() {
+        const READINGS_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: Reading: [id, value, count]\n---\n\
+            readings: @Reading\n  | r1, 1, 3\n  | r2, 1.0, 3\n  | r3, ~, 3\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(READINGS_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+
+            let mut rows: c_int = 0;
+            let mut out_str: *mut c_char = ptr::null_mut();
+            let reading = b"Reading\0".as_ptr() as *const c_char;
+            assert_eq!(
+                hedl_field_stats(doc, reading, &mut rows, &mut out_str),
+                HEDL_OK
+            );
+            assert_eq!(rows, 3);
+            assert_eq!(
+                CStr::from_ptr(out_str).to_str().unwrap(),
+                "id\t3\t3\nvalue\t2\t2\ncount\t3\t1"
+            );
+            hedl_free_string(out_str);
+
+            let missing = b"Missing\0".as_ptr() as *const c_char;
+            let result = hedl_field_stats(doc, missing, &mut rows, &mut out_str);
+            assert_eq!(result, HEDL_ERR_NOT_FOUND);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_schema_descriptors() {
+        const ORDERS_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: Order: [id, total]\n---\n\
+            orders: @Order\n  | o1, 5\n  | o2, ~\n  | o3, 7.5\n\
+            notes: @Note[id, text]\n  | n1, Hello\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(ORDERS_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+
+            let mut out_str: *mut c_char = ptr::null_mut();
+            assert_eq!(hedl_schema_descriptors(doc, &mut out_str), HEDL_OK);
+            assert_eq!(
+                CStr::from_ptr(out_str).to_str().unwrap(),
+                "Order\n\tid\tstring\t0\n\ttotal\tfloat\t1\nNote\n\tid\tstring\t0\n\ttext\tstring\t0"
+            );
+            hedl_free_string(out_str);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_to_csv_zip() {
+        use flate2::read::DeflateDecoder;
+        use std::io::Read;
+
+        const SHOP_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: User: [id, name]\n---\n\
+            users: @User\n  | u1, \"Ann, Jr.\"\n  | u2, ~\n\
+            tags: @Tag[id, label]\n  | t1, new\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(SHOP_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+
+            let mut data: *mut u8 = ptr::null_mut();
+            let mut len: usize = 0;
+            assert_eq!(hedl_to_csv_zip(doc, &mut data, &mut len), HEDL_OK);
+            let bytes = std::slice::from_raw_parts(data, len);
+
+            // Walk the local file headers in order.
+            let mut files = Vec::new();
+            let mut pos = 0;
+            while bytes[pos..pos + 4] == [0x50, 0x4b, 0x03, 0x04] {
+                let field = |at: usize, n: usize| {
+                    bytes[pos + at..pos + at + n]
+                        .iter()
+                        .rev()
+                        .fold(0usize, |acc, b| (acc << 8) | *b as usize)
+                };
+                let (compressed, name_len) = (field(18, 4), field(26, 2));
+                let name = std::str::from_utf8(&bytes[pos + 30..pos + 30 + name_len]).unwrap();
+                let start = pos + 30 + name_len;
+                let mut text = String::new();
+                DeflateDecoder::new(&bytes[start..start + compressed])
+                    .read_to_string(&mut text)
+                    .unwrap();
+                files.push((name.to_string(), text));
+                pos = start + compressed;
+            }
+            assert_eq!(
+                files,
+                [
+                    (
+                        "User.csv".to_string(),
+                        "id,name\nu1,\"Ann, Jr.\"\nu2,\n".to_string()
+                    ),
+                    ("Tag.csv".to_string(), "id,label\nt1,new\n".to_string()),
+                ]
+            );
+            assert_eq!(&bytes[len - 22..len - 18], &[0x50, 0x4b, 0x05, 0x06]);
+            assert_eq!(&bytes[len - 12..len - 10], &[2, 0]);
+
+            hedl_free_bytes(data, len);
+            hedl_free_document(doc);
+        }
+    }
+
+    #[test]
+    fn test_normalize_scalars() {
+        const EVENTS_HEDL: &[u8] = b"%VERSION: 1.0\n%STRUCT: Event: [id, day, owner]\n---\n\
+            day: 2024-1-5\nevents: @Event\n  | e1, 2024-1-5, @Event:e1\n\0";
+        unsafe {
+            let mut doc: *mut HedlDocument = ptr::null_mut();
+            hedl_parse(EVENTS_HEDL.as_ptr() as *const c_char, -1, 1, &mut doc);
+
+            let mut normalized: *mut HedlDocument = ptr::null_mut();
+            assert_eq!(hedl_normalize_scalars(doc, &mut normalized), HEDL_OK);
+            let mut out_str: *mut c_char = ptr::null_mut();
+            assert_eq!(hedl_canonicalize(normalized, &mut out_str), HEDL_OK);
+            let output = CStr::from_ptr(out_str).to_str().unwrap().to_string();
+            hedl_free_string(out_str);
+            hedl_free_document(normalized);
+
+            assert!(output.contains("day: 2024-01-05"), "{}", output);
+            assert!(output.contains("|e1,2024-01-05,@Event:e1"), "{}", output);
+
+            assert_eq!(
+                hedl_normalize_scalars(doc, ptr::null_mut()),
+                HEDL_ERR_NULL_PTR
+            );
             hedl_free_document(doc);
         }
     }
@@ -17,9 +17,10 @@
 
 //! Memory management functions for FFI.
 
-use crate::types::{HedlDiagnostics, HedlDocument};
+use crate::types::{HedlCsvCursor, HedlDiagnostics, HedlDocument, LIVE_DOCUMENTS};
 use std::ffi::CString;
-use std::os::raw::c_char;
+use std::os::raw::{c_char, c_longlong};
+use std::sync::atomic::Ordering;
 
 // =============================================================================
 // Security Constants
@@ -36,6 +37,7 @@ use std::os::raw::c_char;
 /// to track freed memory and detect bugs in accessor functions.
 pub(crate) const POISON_PTR_DOCUMENT: usize = 0xDEADBEEF;
 pub(crate) const POISON_PTR_DIAGNOSTICS: usize = 0xDEADC0DE;
+pub(crate) const POISON_PTR_CSV_CURSOR: usize = 0xDEADCAFE;
 
 // =============================================================================
 // Pointer Validation
@@ -61,6 +63,16 @@ pub(crate) unsafe fn is_valid_diagnostics_ptr(diag: *const HedlDiagnostics) -> b
     !diag.is_null() && (diag as usize) != POISON_PTR_DIAGNOSTICS
 }
 
+/// Check if a CSV cursor pointer is valid (not NULL and not poisoned).
+///
+/// # Safety
+/// This function performs basic pointer validation but does not guarantee
+/// the pointer points to valid memory. It only checks for NULL and poison values.
+#[inline]
+pub(crate) unsafe fn is_valid_csv_cursor_ptr(cursor: *const HedlCsvCursor) -> bool {
+    !cursor.is_null() && (cursor as usize) != POISON_PTR_CSV_CURSOR
+}
+
 // =============================================================================
 // Memory Management Functions
 // =============================================================================
@@ -113,6 +125,16 @@ pub unsafe extern "C" fn hedl_free_document(doc: *mut HedlDocument) {
     // if the caller maintains a poisoned pointer.
 }
 
+/// Count the document handles that have been returned and not yet freed.
+///
+/// Every document handed out by hedl_parse, hedl_from_* or another function
+/// returning a new document counts until it is passed to hedl_free_document,
+/// so bindings can check in tests that no error path leaks one.
+#[no_mangle]
+pub extern "C" fn hedl_live_document_count() -> c_longlong {
+    LIVE_DOCUMENTS.load(Ordering::Relaxed)
+}
+
 /// Free a diagnostics handle.
 ///
 /// # Safety
@@ -140,6 +162,23 @@ pub unsafe extern "C" fn hedl_free_diagnostics(diag: *mut HedlDiagnostics) {
     // if the caller maintains a poisoned pointer.
 }
 
+/// Free a CSV cursor.
+///
+/// # Safety
+///
+/// The pointer must have been returned by hedl_csv_cursor_open. The cursor
+/// may be freed before or after its document.
+///
+/// **Double-free protection:** If the pointer is NULL or the poison value,
+/// this function returns safely without attempting to free.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_free_csv_cursor(cursor: *mut HedlCsvCursor) {
+    if cursor.is_null() || (cursor as usize) == POISON_PTR_CSV_CURSOR {
+        return;
+    }
+    let _ = Box::from_raw(cursor);
+}
+
 /// Free byte array allocated by HEDL functions (e.g., `hedl_to_parquet`).
 ///
 /// # Arguments
@@ -15,17 +15,27 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-//! Operations (canonicalize, lint, validate) for FFI.
+//! Operations (canonicalize, lint, validate, partition, dedup, dates, head/tail, token
+//! budgets, renaming, reference styles, joins) for FFI.
 
-use crate::audit::{
-    audit_call_failure, audit_call_start, audit_call_success, sanitize_pointer,
-};
+use crate::audit::{audit_call_failure, audit_call_start, audit_call_success, sanitize_pointer};
+use crate::checks::{schema_arg, schema_columns, visit_indexed_rows, visit_lists};
 use crate::error::{clear_error, set_error};
 use crate::memory::is_valid_document_ptr;
 use crate::types::{
-    HedlDiagnostics, HedlDocument, HEDL_ERR_CANONICALIZE, HEDL_ERR_NULL_PTR, HEDL_OK,
+    HedlDiagnostics, HedlDocument, HEDL_ERR_ALLOC, HEDL_ERR_CANONICALIZE, HEDL_ERR_CONFLICT,
+    HEDL_ERR_INVALID_ARGUMENT, HEDL_ERR_INVALID_UTF8, HEDL_ERR_NOT_FOUND, HEDL_ERR_NULL_PTR,
+    HEDL_OK,
 };
-use crate::utils::allocate_output_string;
+use crate::utils::{allocate_output_string, get_input_string};
+use chrono::format::{Fixed, Item as FormatItem, StrftimeItems};
+use chrono::{DateTime, FixedOffset, NaiveDate, NaiveDateTime, NaiveTime};
+use hedl_c14n::CanonicalConfig;
+use hedl_core::{Document, Item, MatrixList, Node, Reference, Value};
+use hedl_lint::{Diagnostic, DiagnosticKind};
+use std::collections::{BTreeMap, BTreeSet, HashMap, HashSet};
+use std::ffi::CStr;
+use std::fmt::Write;
 use std::os::raw::{c_char, c_int};
 use std::ptr;
 use std::time::Instant;
@@ -99,6 +109,233 @@ pub unsafe extern "C" fn hedl_canonicalize(
     }
 }
 
+/// Canonicalize the subtree of a HEDL document at a key path.
+///
+/// The result is a standalone document holding only the item at `path`, a
+/// dot-separated key path such as "config.database", under its own key. Its
+/// header keeps the version and aliases of the document, and only the
+/// `%STRUCT` and `%NEST` declarations of the types its lists use.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `path` - NUL-terminated dot-separated key path
+/// * `out_str` - Pointer to store canonical output (must be freed with hedl_free_string)
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_NOT_FOUND if no item is at `path`, error
+/// code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_canonicalize_path(
+    doc: *const HedlDocument,
+    path: *const c_char,
+    out_str: *mut *mut c_char,
+) -> c_int {
+    const FUNC: &str = "hedl_canonicalize_path";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("path", &sanitize_pointer(path)),
+            ("out_str", &sanitize_pointer(out_str)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_str.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let path = match c_str_arg(path) {
+        Ok(path) => path,
+        Err(code) => {
+            audit_call_failure(FUNC, code, "Invalid path argument", start.elapsed());
+            return code;
+        }
+    };
+    let doc_ref = &(*doc).inner;
+    let keys: Vec<&str> = path.split('.').collect();
+    let key = keys[keys.len() - 1];
+    let mut item = doc_ref.root.get(keys[0]);
+    for part in &keys[1..] {
+        item = match item {
+            Some(Item::Object(obj)) => obj.get(*part),
+            _ => None,
+        };
+    }
+    let item = match item {
+        Some(item) => item,
+        None => {
+            let err_msg = format!("Path not found: {}", path);
+            set_error(&err_msg);
+            audit_call_failure(FUNC, HEDL_ERR_NOT_FOUND, &err_msg, start.elapsed());
+            return HEDL_ERR_NOT_FOUND;
+        }
+    };
+
+    let mut subtree = Document::new(doc_ref.version);
+    subtree.aliases = doc_ref.aliases.clone();
+    subtree.root.insert(key.to_string(), item.clone());
+    let mut used = HashSet::new();
+    collect_types(&subtree.root, &mut used);
+    subtree.structs = doc_ref
+        .structs
+        .iter()
+        .filter(|(name, _)| used.contains(name.as_str()))
+        .map(|(name, columns)| (name.clone(), columns.clone()))
+        .collect();
+    subtree.nests = doc_ref
+        .nests
+        .iter()
+        .filter(|(parent, child)| used.contains(parent.as_str()) && used.contains(child.as_str()))
+        .map(|(parent, child)| (parent.clone(), child.clone()))
+        .collect();
+
+    match hedl_c14n::canonicalize(&subtree) {
+        Ok(canonical) => {
+            let result = allocate_output_string(&canonical, out_str, HEDL_ERR_CANONICALIZE);
+            if result == HEDL_OK {
+                audit_call_success(FUNC, start.elapsed());
+            } else {
+                let msg = crate::error::get_thread_local_error();
+                audit_call_failure(FUNC, result, &msg, start.elapsed());
+            }
+            result
+        }
+        Err(e) => {
+            let msg = format!("Canonicalization error: {}", e);
+            set_error(&msg);
+            audit_call_failure(FUNC, HEDL_ERR_CANONICALIZE, &msg, start.elapsed());
+            HEDL_ERR_CANONICALIZE
+        }
+    }
+}
+
+/// Write a HEDL document laid out for minimal diffs in version control.
+///
+/// The output is canonical HEDL with every row written in full rather than
+/// with ditto markers, so editing one row never changes the next, and with
+/// date-like strings normalized: `YYYY-M-D` dates are zero-padded and RFC
+/// 3339 timestamps are rewritten with `Z` for UTC and without trailing
+/// zeros in fractional seconds. Row IDs, which references may point at, are
+/// left as written. The output parses to the same document.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `out_str` - Pointer to store the output (must be freed with hedl_free_string)
+///
+/// # Returns
+/// HEDL_OK on success, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_to_git_friendly(
+    doc: *const HedlDocument,
+    out_str: *mut *mut c_char,
+) -> c_int {
+    const FUNC: &str = "hedl_to_git_friendly";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("out_str", &sanitize_pointer(out_str)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_str.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let mut normalized = (*doc).inner.clone();
+    normalize_scalars(&mut normalized.root);
+    let config = CanonicalConfig::new().with_ditto(false);
+
+    match hedl_c14n::canonicalize_with_config(&normalized, &config) {
+        Ok(output) => {
+            let result = allocate_output_string(&output, out_str, HEDL_ERR_CANONICALIZE);
+            if result == HEDL_OK {
+                audit_call_success(FUNC, start.elapsed());
+            } else {
+                let msg = crate::error::get_thread_local_error();
+                audit_call_failure(FUNC, result, &msg, start.elapsed());
+            }
+            result
+        }
+        Err(e) => {
+            let msg = format!("Canonicalization error: {}", e);
+            set_error(&msg);
+            audit_call_failure(FUNC, HEDL_ERR_CANONICALIZE, &msg, start.elapsed());
+            HEDL_ERR_CANONICALIZE
+        }
+    }
+}
+
+/// Copy a document with its date-like strings normalized as
+/// `hedl_to_git_friendly` does: `YYYY-M-D` dates are zero-padded and RFC
+/// 3339 timestamps are rewritten with `Z` for UTC and without trailing zeros
+/// in fractional seconds. Row IDs, which references may point at, and
+/// references themselves are left as written. Numbers need no rewriting, as
+/// they are held as parsed values and canonicalize in their shortest form.
+/// The input document is left unchanged.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `out_doc` - Pointer to store the new document handle (must be freed with hedl_free_document)
+///
+/// # Returns
+/// HEDL_OK on success, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_normalize_scalars(
+    doc: *const HedlDocument,
+    out_doc: *mut *mut HedlDocument,
+) -> c_int {
+    const FUNC: &str = "hedl_normalize_scalars";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("out_doc", &sanitize_pointer(out_doc)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_doc.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let mut normalized = (*doc).inner.clone();
+    normalize_scalars(&mut normalized.root);
+
+    *out_doc = Box::into_raw(Box::new(HedlDocument::new(normalized)));
+    audit_call_success(FUNC, start.elapsed());
+    HEDL_OK
+}
+
 // =============================================================================
 // Linting
 // =============================================================================
@@ -146,3 +383,2012 @@ pub unsafe extern "C" fn hedl_lint(
     audit_call_success("hedl_lint", start.elapsed());
     HEDL_OK
 }
+
+/// Count the lint warnings of a HEDL document.
+///
+/// Runs the linter like `hedl_lint` but returns only the number of
+/// warning-level diagnostics, without handing the messages to the caller.
+///
+/// # Returns
+/// The warning count, or a negative error code on failure.
+///
+/// # Safety
+/// Doc pointer must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_lint_warning_count(doc: *const HedlDocument) -> c_int {
+    const FUNC: &str = "hedl_lint_warning_count";
+    let start = Instant::now();
+
+    audit_call_start(FUNC, &[("doc", &sanitize_pointer(doc))]);
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let diagnostics = hedl_lint::lint(&(*doc).inner);
+    let count = diagnostics
+        .iter()
+        .filter(|d| matches!(d.severity(), hedl_lint::Severity::Warning))
+        .count();
+    audit_call_success(FUNC, start.elapsed());
+    c_int::try_from(count).unwrap_or(c_int::MAX)
+}
+
+/// Check that every string value is in a Unicode normalization form.
+///
+/// `form` is "NFC", "NFD", "NFKC" or "NFKD", case-insensitively. Each string
+/// value not in that form is reported as an error with rule ID
+/// "unicode-normalization": key-value pairs first, by dot-separated key
+/// path, then matrix cells, by type, row index counted across the type's
+/// lists in document order, and field. Characters outside printable ASCII
+/// are shown as `\u{...}` escapes.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `form` - NUL-terminated normalization form name
+/// * `out_diag` - Pointer to store diagnostics handle (must be freed with hedl_free_diagnostics)
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_INVALID_ARGUMENT for an unknown form, error
+/// code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_check_unicode_normalization(
+    doc: *const HedlDocument,
+    form: *const c_char,
+    out_diag: *mut *mut HedlDiagnostics,
+) -> c_int {
+    const FUNC: &str = "hedl_check_unicode_normalization";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("form", &sanitize_pointer(form)),
+            ("out_diag", &sanitize_pointer(out_diag)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_diag.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+    *out_diag = ptr::null_mut();
+
+    let form = match c_str_arg(form) {
+        Ok(form) => form.to_ascii_uppercase(),
+        Err(code) => {
+            audit_call_failure(FUNC, code, "Invalid form argument", start.elapsed());
+            return code;
+        }
+    };
+    let is_normalized: fn(&str) -> bool = match form.as_str() {
+        "NFC" => unicode_normalization::is_nfc,
+        "NFD" => unicode_normalization::is_nfd,
+        "NFKC" => unicode_normalization::is_nfkc,
+        "NFKD" => unicode_normalization::is_nfkd,
+        _ => {
+            let err_msg = format!("Unknown normalization form: {:?}", form);
+            set_error(&err_msg);
+            audit_call_failure(FUNC, HEDL_ERR_INVALID_ARGUMENT, &err_msg, start.elapsed());
+            return HEDL_ERR_INVALID_ARGUMENT;
+        }
+    };
+
+    let doc_ref = &(*doc).inner;
+    let mut check = NormalizationCheck {
+        structs: &doc_ref.structs,
+        form: &form,
+        is_normalized,
+        diagnostics: Vec::new(),
+        rows_seen: HashMap::new(),
+    };
+    check.visit_key_values("", &doc_ref.root);
+    check.visit_lists(&doc_ref.root);
+
+    *out_diag = Box::into_raw(Box::new(HedlDiagnostics {
+        inner: check.diagnostics,
+    }));
+    audit_call_success(FUNC, start.elapsed());
+    HEDL_OK
+}
+
+/// Collects the diagnostics of `hedl_check_unicode_normalization`.
+struct NormalizationCheck<'a> {
+    structs: &'a BTreeMap<String, Vec<String>>,
+    form: &'a str,
+    is_normalized: fn(&str) -> bool,
+    diagnostics: Vec<Diagnostic>,
+    rows_seen: HashMap<String, usize>,
+}
+
+impl NormalizationCheck<'_> {
+    fn report(&mut self, location: String, value: &Value) {
+        if let Value::String(s) = value {
+            if !(self.is_normalized)(s) {
+                self.diagnostics.push(Diagnostic::error(
+                    DiagnosticKind::Custom("unicode-normalization".to_string()),
+                    format!(
+                        "{} is not in {} form ({})",
+                        location,
+                        self.form,
+                        escape_non_ascii(s)
+                    ),
+                    "unicode-normalization",
+                ));
+            }
+        }
+    }
+
+    fn visit_key_values(&mut self, prefix: &str, items: &BTreeMap<String, Item>) {
+        for (key, item) in items {
+            match item {
+                Item::Scalar(value) => self.report(format!("{}{}", prefix, key), value),
+                Item::Object(obj) => self.visit_key_values(&format!("{}{}.", prefix, key), obj),
+                Item::List(_) => {}
+            }
+        }
+    }
+
+    fn visit_lists(&mut self, items: &BTreeMap<String, Item>) {
+        for item in items.values() {
+            match item {
+                Item::Object(obj) => self.visit_lists(obj),
+                Item::List(list) => self.visit_rows(&list.type_name, &list.schema, &list.rows),
+                Item::Scalar(_) => {}
+            }
+        }
+    }
+
+    /// Visit one list of rows, then the lists nested under each of its rows,
+    /// numbering rows as `hedl_largest_values` does.
+    fn visit_rows(&mut self, type_name: &str, schema: &[String], rows: &[Node]) {
+        let seen = self.rows_seen.entry(type_name.to_string()).or_insert(0);
+        let first = *seen;
+        *seen += rows.len();
+
+        for (i, row) in rows.iter().enumerate() {
+            for (field, value) in schema.iter().zip(&row.fields) {
+                let location = format!("{} row {} field \"{}\"", type_name, first + i, field);
+                self.report(location, value);
+            }
+        }
+        for row in rows {
+            for (child_type, children) in &row.children {
+                let structs = self.structs;
+                let child_schema = structs.get(child_type).map(Vec::as_slice).unwrap_or(&[]);
+                self.visit_rows(child_type, child_schema, children);
+            }
+        }
+    }
+}
+
+/// Quote `s`, escaping quotes and backslashes and writing characters outside
+/// printable ASCII as `\u{...}`.
+fn escape_non_ascii(s: &str) -> String {
+    let mut out = String::with_capacity(s.len() + 2);
+    out.push('"');
+    for c in s.chars() {
+        match c {
+            '"' | '\\' => {
+                out.push('\\');
+                out.push(c);
+            }
+            ' '..='~' => out.push(c),
+            _ => out.extend(c.escape_unicode()),
+        }
+    }
+    out.push('"');
+    out
+}
+
+// =============================================================================
+// Partitioning
+// =============================================================================
+
+/// Get the distinct values of a field, for use as `hedl_partition` keys.
+///
+/// Each value is written on its own line as its HEDL text, in sorted order.
+/// Strings are always quoted, with `"` doubled, so every key is non-empty
+/// and values of different types never share a key: null is `~`, the
+/// string "~" is `"~"` and the empty string is `""`. Backslashes, tabs and
+/// newlines are then escaped as `\\`, `\t` and `\n`, as in `hedl_aliases`.
+/// Rows are collected from every list of the type, including nested ones;
+/// an empty string means the type has no rows.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `schema_name` - NUL-terminated name of the struct type
+/// * `field` - NUL-terminated name of the field
+/// * `out_str` - Pointer to store the keys (must be freed with hedl_free_string)
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_NOT_FOUND if the type or field is not
+/// declared, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_partition_keys(
+    doc: *const HedlDocument,
+    schema_name: *const c_char,
+    field: *const c_char,
+    out_str: *mut *mut c_char,
+) -> c_int {
+    const FUNC: &str = "hedl_partition_keys";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("schema_name", &sanitize_pointer(schema_name)),
+            ("field", &sanitize_pointer(field)),
+            ("out_str", &sanitize_pointer(out_str)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_str.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let doc_ref = &(*doc).inner;
+    let (schema_name, index) = match partition_field(FUNC, doc_ref, schema_name, field, start) {
+        Ok(found) => found,
+        Err(code) => return code,
+    };
+
+    let mut keys = BTreeSet::new();
+    visit_rows(&doc_ref.root, schema_name, &mut |row| {
+        keys.insert(partition_key(row, index));
+    });
+
+    let lines: Vec<String> = keys
+        .iter()
+        .map(|key| {
+            key.replace('\\', "\\\\")
+                .replace('\t', "\\t")
+                .replace('\n', "\\n")
+        })
+        .collect();
+    let result = allocate_output_string(&lines.join("\n"), out_str, HEDL_ERR_ALLOC);
+    if result == HEDL_OK {
+        audit_call_success(FUNC, start.elapsed());
+    } else {
+        audit_call_failure(FUNC, result, "Allocation failed", start.elapsed());
+    }
+    result
+}
+
+/// Copy a document, keeping only the rows of one struct type whose field
+/// has a given value.
+///
+/// `key` is compared with the HEDL text of the field, as listed by
+/// `hedl_partition_keys` and unescaped, so strings must be quoted. Rows of
+/// the type are filtered in every list, including nested ones; kept rows
+/// keep their nested children, and everything else in the document is
+/// copied unchanged.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `schema_name` - NUL-terminated name of the struct type
+/// * `field` - NUL-terminated name of the field
+/// * `key` - NUL-terminated field value to keep
+/// * `out_doc` - Pointer to store the new document handle (must be freed with hedl_free_document)
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_NOT_FOUND if the type or field is not
+/// declared, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_partition(
+    doc: *const HedlDocument,
+    schema_name: *const c_char,
+    field: *const c_char,
+    key: *const c_char,
+    out_doc: *mut *mut HedlDocument,
+) -> c_int {
+    const FUNC: &str = "hedl_partition";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("schema_name", &sanitize_pointer(schema_name)),
+            ("field", &sanitize_pointer(field)),
+            ("key", &sanitize_pointer(key)),
+            ("out_doc", &sanitize_pointer(out_doc)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_doc.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+    *out_doc = ptr::null_mut();
+
+    let doc_ref = &(*doc).inner;
+    let (schema_name, index) = match partition_field(FUNC, doc_ref, schema_name, field, start) {
+        Ok(found) => found,
+        Err(code) => return code,
+    };
+    let key = match c_str_arg(key) {
+        Ok(key) => key,
+        Err(code) => {
+            audit_call_failure(FUNC, code, "Invalid key argument", start.elapsed());
+            return code;
+        }
+    };
+
+    let mut partition = doc_ref.clone();
+    retain_rows(&mut partition.root, schema_name, &|row| {
+        partition_key(row, index) == key
+    });
+
+    *out_doc = Box::into_raw(Box::new(HedlDocument::new(partition)));
+    audit_call_success(FUNC, start.elapsed());
+    HEDL_OK
+}
+
+// =============================================================================
+// Deduplication
+// =============================================================================
+
+/// Remove rows of one struct type that are equal to the row before them on
+/// a set of fields, modifying the document in place.
+///
+/// Each list, including lists nested in objects and under other rows, is
+/// deduplicated on its own. With a negative `field_count` every field except
+/// the ID is compared, since IDs are unique per row; with zero fields all
+/// rows of a run compare equal. Count hints of the affected lists are
+/// updated, and references to removed rows are left as they are.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `schema_name` - NUL-terminated name of the struct type
+/// * `fields` - Array of `field_count` NUL-terminated field names
+/// * `field_count` - Number of fields, or -1 to compare all but the ID
+/// * `out_removed` - Pointer to store the number of rows removed
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_NOT_FOUND if the type or a field is not
+/// declared, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_dedup(
+    doc: *mut HedlDocument,
+    schema_name: *const c_char,
+    fields: *const *const c_char,
+    field_count: c_int,
+    out_removed: *mut c_int,
+) -> c_int {
+    const FUNC: &str = "hedl_dedup";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("schema_name", &sanitize_pointer(schema_name)),
+            ("fields", &sanitize_pointer(fields)),
+            ("field_count", &field_count.to_string()),
+            ("out_removed", &sanitize_pointer(out_removed)),
+        ],
+    );
+
+    clear_error();
+
+    let fields_missing = field_count > 0 && fields.is_null();
+    if !is_valid_document_ptr(doc) || out_removed.is_null() || fields_missing {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let doc_ref = &mut (*doc).inner;
+    let schema_name = match c_str_arg(schema_name) {
+        Ok(name) => name,
+        Err(code) => {
+            audit_call_failure(FUNC, code, "Invalid name argument", start.elapsed());
+            return code;
+        }
+    };
+    let schema = match doc_ref.structs.get(schema_name) {
+        Some(schema) => schema,
+        None => {
+            let err_msg = format!("Unknown type: {}", schema_name);
+            set_error(&err_msg);
+            audit_call_failure(FUNC, HEDL_ERR_NOT_FOUND, &err_msg, start.elapsed());
+            return HEDL_ERR_NOT_FOUND;
+        }
+    };
+
+    let columns: Vec<usize> = if field_count < 0 {
+        (1..schema.len()).collect()
+    } else {
+        let mut columns = Vec::with_capacity(field_count as usize);
+        for i in 0..field_count as usize {
+            let field = match c_str_arg(*fields.add(i)) {
+                Ok(field) => field,
+                Err(code) => {
+                    audit_call_failure(FUNC, code, "Invalid field argument", start.elapsed());
+                    return code;
+                }
+            };
+            match schema.iter().position(|name| name == field) {
+                Some(index) => columns.push(index),
+                None => {
+                    let err_msg = format!("Unknown field {} in type {}", field, schema_name);
+                    set_error(&err_msg);
+                    audit_call_failure(FUNC, HEDL_ERR_NOT_FOUND, &err_msg, start.elapsed());
+                    return HEDL_ERR_NOT_FOUND;
+                }
+            }
+        }
+        columns
+    };
+
+    let same = |a: &Node, b: &Node| columns.iter().all(|&c| a.fields.get(c) == b.fields.get(c));
+    let removed = dedup_rows(&mut doc_ref.root, schema_name, &same);
+
+    *out_removed = removed.min(c_int::MAX as usize) as c_int;
+    audit_call_success(FUNC, start.elapsed());
+    HEDL_OK
+}
+
+// =============================================================================
+// Date Normalization
+// =============================================================================
+
+/// Date-and-time formats recognized by `hedl_normalize_dates`, after RFC 3339.
+const DATE_TIME_FORMATS: &[&str] = &[
+    "%Y-%m-%dT%H:%M:%S%.f",
+    "%Y-%m-%d %H:%M:%S%.f",
+    "%Y-%m-%d %H:%M",
+    "%m/%d/%Y %H:%M:%S",
+    "%m/%d/%Y %H:%M",
+];
+
+/// Date-only formats recognized by `hedl_normalize_dates`.
+const DATE_FORMATS: &[&str] = &[
+    "%Y-%m-%d",
+    "%Y/%m/%d",
+    "%m/%d/%Y",
+    "%d %b %Y",
+    "%b %d, %Y",
+    "%B %d, %Y",
+];
+
+/// Copy a document with the date values of some fields rewritten in one
+/// format, reporting the values that are not dates.
+///
+/// Each field is named "Type.field". String values are trimmed and read as
+/// RFC 3339 timestamps, `YYYY-MM-DD` and US-style `MM/DD/YYYY` dates with an
+/// optional `HH:MM[:SS]` time, `YYYY/MM/DD`, `2 Jan 2006`, `Jan 2, 2006` and
+/// `January 2, 2006` dates, or RFC 2822 timestamps; values without a zone
+/// are taken as UTC. Recognized values are rewritten with `target_format`, a
+/// strftime format in which `%#z` writes the offset as RFC 3339 does: `Z`
+/// for UTC and `+hh:mm` otherwise. Other non-null values are left as they
+/// are and reported as warnings with rule ID "date", naming the row index
+/// counted across the type's lists in document order.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `fields` - Array of `field_count` NUL-terminated "Type.field" names
+/// * `field_count` - Number of fields
+/// * `target_format` - NUL-terminated strftime format, such as "%Y-%m-%dT%H:%M:%S%#z"
+/// * `out_doc` - Pointer to store the new document handle (must be freed with hedl_free_document)
+/// * `out_diag` - Pointer to store diagnostics handle (must be freed with hedl_free_diagnostics)
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_INVALID_ARGUMENT for an empty or invalid
+/// format or a field name without a type, HEDL_ERR_NOT_FOUND if a type or
+/// field is not declared, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_normalize_dates(
+    doc: *const HedlDocument,
+    fields: *const *const c_char,
+    field_count: c_int,
+    target_format: *const c_char,
+    out_doc: *mut *mut HedlDocument,
+    out_diag: *mut *mut HedlDiagnostics,
+) -> c_int {
+    const FUNC: &str = "hedl_normalize_dates";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("fields", &sanitize_pointer(fields)),
+            ("field_count", &field_count.to_string()),
+            ("target_format", &sanitize_pointer(target_format)),
+            ("out_doc", &sanitize_pointer(out_doc)),
+            ("out_diag", &sanitize_pointer(out_diag)),
+        ],
+    );
+
+    clear_error();
+
+    let fields_missing = field_count > 0 && fields.is_null();
+    if !is_valid_document_ptr(doc) || out_doc.is_null() || out_diag.is_null() || fields_missing {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+    *out_doc = ptr::null_mut();
+    *out_diag = ptr::null_mut();
+
+    let fail = |code: c_int, err_msg: &str| {
+        set_error(err_msg);
+        audit_call_failure(FUNC, code, err_msg, start.elapsed());
+        code
+    };
+
+    let target_format = match c_str_arg(target_format) {
+        Ok(format) => format,
+        Err(code) => {
+            audit_call_failure(FUNC, code, "Invalid format argument", start.elapsed());
+            return code;
+        }
+    };
+    let items = match date_format_items(target_format) {
+        Some(items) => items,
+        None => {
+            let err_msg = format!("Invalid date format: {:?}", target_format);
+            return fail(HEDL_ERR_INVALID_ARGUMENT, &err_msg);
+        }
+    };
+
+    let doc_ref = &(*doc).inner;
+    let mut targets = Vec::new();
+    for i in 0..field_count.max(0) as usize {
+        let name = match c_str_arg(*fields.add(i)) {
+            Ok(name) => name,
+            Err(code) => {
+                audit_call_failure(FUNC, code, "Invalid field argument", start.elapsed());
+                return code;
+            }
+        };
+        let (schema_name, field) = match name.rsplit_once('.') {
+            Some(parts) => parts,
+            None => {
+                let err_msg = format!("Field {:?} is not of the form Type.field", name);
+                return fail(HEDL_ERR_INVALID_ARGUMENT, &err_msg);
+            }
+        };
+        if !doc_ref.structs.contains_key(schema_name) {
+            let err_msg = format!("Unknown type: {}", schema_name);
+            return fail(HEDL_ERR_NOT_FOUND, &err_msg);
+        }
+        match field_index(doc_ref, schema_name, field) {
+            Some(index) => targets.push((schema_name, field, index)),
+            None => {
+                let err_msg = format!("Unknown field {} in type {}", field, schema_name);
+                return fail(HEDL_ERR_NOT_FOUND, &err_msg);
+            }
+        }
+    }
+
+    let mut normalized = doc_ref.clone();
+    let mut diagnostics = Vec::new();
+    for (schema_name, field, index) in targets {
+        let mut row_index = 0;
+        visit_rows_mut(&mut normalized.root, schema_name, &mut |row| {
+            if let Some(value) = row.fields.get_mut(index) {
+                let date = match value {
+                    Value::Null => None,
+                    Value::String(s) => Some(parse_date(s)),
+                    _ => Some(None),
+                };
+                match date {
+                    None => {}
+                    Some(Some(date)) => {
+                        *value = Value::String(date.format_with_items(items.iter()).to_string());
+                    }
+                    Some(None) => diagnostics.push(Diagnostic::warning(
+                        DiagnosticKind::Custom("date".to_string()),
+                        format!(
+                            "{} row {} field \"{}\" is not a recognized date (got {})",
+                            schema_name, row_index, field, value
+                        ),
+                        "date",
+                    )),
+                }
+            }
+            row_index += 1;
+        });
+    }
+
+    *out_doc = Box::into_raw(Box::new(HedlDocument::new(normalized)));
+    *out_diag = Box::into_raw(Box::new(HedlDiagnostics { inner: diagnostics }));
+    audit_call_success(FUNC, start.elapsed());
+    HEDL_OK
+}
+
+// =============================================================================
+// Head and Tail
+// =============================================================================
+
+/// Keep only the last `n` top-level rows of each struct type, for previewing
+/// the end of a large document.
+///
+/// Rows are counted across the lists of a type held by objects, in document
+/// order; rows nested under other rows stay with their parents, and an `n`
+/// larger than the number of rows keeps them all. Count hints of the lists
+/// are updated. The input document is left unchanged.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `n` - Number of rows of each type to keep
+/// * `out_doc` - Pointer to store the new document handle (must be freed with hedl_free_document)
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_INVALID_ARGUMENT if `n` is negative, error
+/// code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_tail(
+    doc: *const HedlDocument,
+    n: c_int,
+    out_doc: *mut *mut HedlDocument,
+) -> c_int {
+    keep_rows_call("hedl_tail", doc, n, true, out_doc)
+}
+
+/// Keep only the first `n` top-level rows of each struct type, the
+/// counterpart of `hedl_tail`.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `n` - Number of rows of each type to keep
+/// * `out_doc` - Pointer to store the new document handle (must be freed with hedl_free_document)
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_INVALID_ARGUMENT if `n` is negative, error
+/// code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_head(
+    doc: *const HedlDocument,
+    n: c_int,
+    out_doc: *mut *mut HedlDocument,
+) -> c_int {
+    keep_rows_call("hedl_head", doc, n, false, out_doc)
+}
+
+/// The body of `hedl_head` and `hedl_tail`, keeping `n` top-level rows of each type from the
+/// end when `from_end` is set and from the start otherwise.
+unsafe fn keep_rows_call(
+    func: &'static str,
+    doc: *const HedlDocument,
+    n: c_int,
+    from_end: bool,
+    out_doc: *mut *mut HedlDocument,
+) -> c_int {
+    let start = Instant::now();
+
+    audit_call_start(
+        func,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("n", &n.to_string()),
+            ("out_doc", &sanitize_pointer(out_doc)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_doc.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(func, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+    *out_doc = ptr::null_mut();
+
+    if n < 0 {
+        let err_msg = format!("Row count {} is negative", n);
+        set_error(&err_msg);
+        audit_call_failure(func, HEDL_ERR_INVALID_ARGUMENT, &err_msg, start.elapsed());
+        return HEDL_ERR_INVALID_ARGUMENT;
+    }
+
+    let mut kept = (*doc).inner.clone();
+    keep_rows(&mut kept.root, n as usize, from_end);
+
+    *out_doc = Box::into_raw(Box::new(HedlDocument::new(kept)));
+    audit_call_success(func, start.elapsed());
+    HEDL_OK
+}
+
+// =============================================================================
+// Token Budgets
+// =============================================================================
+
+/// Models accepted by `hedl_token_count` and `hedl_fit_to_tokens`. They all
+/// use the cl100k_base encoding, so a single estimate serves them all.
+const TOKEN_MODELS: &[&str] = &["cl100k_base", "gpt-4", "gpt-4-turbo", "gpt-3.5-turbo"];
+
+/// Estimate the number of tokens text takes up in the context of a model.
+///
+/// The estimate is the heuristic of `hedl stats --tokens`: about four bytes
+/// per token of content and three whitespace characters per token. It is
+/// meant for budgeting, not billing.
+///
+/// # Arguments
+/// * `model` - NUL-terminated model name, such as "gpt-4"
+/// * `text` - UTF-8 encoded text
+/// * `text_len` - Length of text in bytes, or -1 for null-terminated
+/// * `out_count` - Pointer to store the token count
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_INVALID_ARGUMENT for an unsupported model,
+/// error code on failure.
+///
+/// # Safety
+/// All pointers must be valid.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_token_count(
+    model: *const c_char,
+    text: *const c_char,
+    text_len: c_int,
+    out_count: *mut c_int,
+) -> c_int {
+    const FUNC: &str = "hedl_token_count";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("model", &sanitize_pointer(model)),
+            ("text", &sanitize_pointer(text)),
+            ("text_len", &text_len.to_string()),
+            ("out_count", &sanitize_pointer(out_count)),
+        ],
+    );
+
+    clear_error();
+
+    if text.is_null() || out_count.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    if let Err(code) = token_model_arg(FUNC, model, start) {
+        return code;
+    }
+    let text = match get_input_string(text, text_len) {
+        Ok(text) => text,
+        Err(code) => {
+            let msg = crate::error::get_thread_local_error();
+            audit_call_failure(FUNC, code, &msg, start.elapsed());
+            return code;
+        }
+    };
+
+    *out_count = estimate_tokens(&text).min(c_int::MAX as usize) as c_int;
+    audit_call_success(FUNC, start.elapsed());
+    HEDL_OK
+}
+
+/// Canonicalize a HEDL document to fit in a token budget, as counted by
+/// `hedl_token_count`, for use in an LLM context of fixed size.
+///
+/// When the whole document fits it is written unchanged with no
+/// diagnostics. Otherwise trailing top-level rows are dropped, together with
+/// the rows nested under them, keeping the first rows of each struct type
+/// as `hedl_head` does and as many of them as fit; key-value pairs and
+/// schemas are always kept. Each truncated list is reported as a warning
+/// with rule ID "truncated".
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `model` - NUL-terminated model name, as for `hedl_token_count`
+/// * `max_tokens` - Token budget
+/// * `out_str` - Pointer to store the canonical HEDL (must be freed with hedl_free_string)
+/// * `out_diag` - Pointer to store diagnostics handle (must be freed with hedl_free_diagnostics)
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_INVALID_ARGUMENT for an unsupported model, a
+/// budget that is not positive or a document that does not fit even with
+/// every list emptied, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_fit_to_tokens(
+    doc: *const HedlDocument,
+    model: *const c_char,
+    max_tokens: c_int,
+    out_str: *mut *mut c_char,
+    out_diag: *mut *mut HedlDiagnostics,
+) -> c_int {
+    const FUNC: &str = "hedl_fit_to_tokens";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("model", &sanitize_pointer(model)),
+            ("max_tokens", &max_tokens.to_string()),
+            ("out_str", &sanitize_pointer(out_str)),
+            ("out_diag", &sanitize_pointer(out_diag)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_str.is_null() || out_diag.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+    *out_str = ptr::null_mut();
+    *out_diag = ptr::null_mut();
+
+    let fail = |code: c_int, err_msg: &str| {
+        set_error(err_msg);
+        audit_call_failure(FUNC, code, err_msg, start.elapsed());
+        code
+    };
+
+    if let Err(code) = token_model_arg(FUNC, model, start) {
+        return code;
+    }
+    if max_tokens <= 0 {
+        let err_msg = format!("Token budget {} is not positive", max_tokens);
+        return fail(HEDL_ERR_INVALID_ARGUMENT, &err_msg);
+    }
+    let budget = max_tokens as usize;
+
+    let doc_ref = &(*doc).inner;
+    let render = |n: Option<usize>| {
+        let mut kept = doc_ref.clone();
+        if let Some(n) = n {
+            keep_rows(&mut kept.root, n, false);
+        }
+        hedl_c14n::canonicalize(&kept).map(|text| (estimate_tokens(&text), text, kept))
+    };
+    let (mut tokens, mut text, mut kept) = match render(None) {
+        Ok(rendered) => rendered,
+        Err(e) => {
+            let err_msg = format!("Canonicalization error: {}", e);
+            return fail(HEDL_ERR_CANONICALIZE, &err_msg);
+        }
+    };
+
+    if tokens > budget {
+        let mut rows: HashMap<&str, usize> = HashMap::new();
+        visit_lists(&doc_ref.root, &mut |list| {
+            *rows.entry(&list.type_name).or_insert(0) += list.rows.len();
+        });
+        let longest = rows.values().copied().max().unwrap_or(0);
+
+        // The most rows per type that fit, found by binary search.
+        let (mut low, mut high) = (0, longest);
+        while low < high {
+            let n = (low + high + 1) / 2;
+            match render(Some(n)) {
+                Ok((tokens, _, _)) if tokens <= budget => low = n,
+                Ok(_) => high = n - 1,
+                Err(e) => {
+                    let err_msg = format!("Canonicalization error: {}", e);
+                    return fail(HEDL_ERR_CANONICALIZE, &err_msg);
+                }
+            }
+        }
+        (tokens, text, kept) = match render(Some(low)) {
+            Ok(rendered) => rendered,
+            Err(e) => {
+                let err_msg = format!("Canonicalization error: {}", e);
+                return fail(HEDL_ERR_CANONICALIZE, &err_msg);
+            }
+        };
+        if tokens > budget {
+            let err_msg = format!(
+                "Document needs {} tokens without any rows, over the budget of {}",
+                tokens, budget
+            );
+            return fail(HEDL_ERR_INVALID_ARGUMENT, &err_msg);
+        }
+    }
+
+    let mut kept_rows = Vec::new();
+    visit_lists(&kept.root, &mut |list| kept_rows.push(list.rows.len()));
+    let mut kept_rows = kept_rows.into_iter();
+    let mut diagnostics = Vec::new();
+    visit_lists(&doc_ref.root, &mut |list| {
+        let count = kept_rows.next().unwrap_or(0);
+        if count < list.rows.len() {
+            diagnostics.push(Diagnostic::warning(
+                DiagnosticKind::Custom("truncated".to_string()),
+                format!(
+                    "{} list truncated from {} to {} rows to fit {} tokens",
+                    list.type_name,
+                    list.rows.len(),
+                    count,
+                    budget
+                ),
+                "truncated",
+            ));
+        }
+    });
+
+    let result = allocate_output_string(&text, out_str, HEDL_ERR_ALLOC);
+    if result != HEDL_OK {
+        audit_call_failure(FUNC, result, "Allocation failed", start.elapsed());
+        return result;
+    }
+    *out_diag = Box::into_raw(Box::new(HedlDiagnostics { inner: diagnostics }));
+    audit_call_success(FUNC, start.elapsed());
+    HEDL_OK
+}
+
+/// Read the model argument of a token call, recording the failure for
+/// `func` if it is invalid or not one of `TOKEN_MODELS`.
+unsafe fn token_model_arg(
+    func: &'static str,
+    model: *const c_char,
+    start: Instant,
+) -> Result<(), c_int> {
+    let model = match c_str_arg(model) {
+        Ok(model) => model,
+        Err(code) => {
+            audit_call_failure(func, code, "Invalid model argument", start.elapsed());
+            return Err(code);
+        }
+    };
+    if TOKEN_MODELS.contains(&model) {
+        return Ok(());
+    }
+    let err_msg = format!("Unsupported tokenizer model: {}", model);
+    set_error(&err_msg);
+    audit_call_failure(func, HEDL_ERR_INVALID_ARGUMENT, &err_msg, start.elapsed());
+    Err(HEDL_ERR_INVALID_ARGUMENT)
+}
+
+/// Estimate the tokens of `text` as `hedl stats --tokens` does.
+fn estimate_tokens(text: &str) -> usize {
+    let whitespace = text.chars().filter(|c| c.is_whitespace()).count();
+    (text.len() - whitespace) / 4 + whitespace / 3
+}
+
+// =============================================================================
+// Renaming
+// =============================================================================
+
+/// Rename a field of one struct type, modifying the document in place.
+///
+/// The field is renamed in the type's declaration and in the schema of every
+/// list of the type. Renaming a field to its own name does nothing.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `schema_name` - NUL-terminated name of the struct type
+/// * `old_name` - NUL-terminated name of the field to rename
+/// * `new_name` - NUL-terminated new name of the field
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_NOT_FOUND if the type or field does not
+/// exist, HEDL_ERR_CONFLICT if the type already has a field named
+/// `new_name`, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_rename_field(
+    doc: *mut HedlDocument,
+    schema_name: *const c_char,
+    old_name: *const c_char,
+    new_name: *const c_char,
+) -> c_int {
+    const FUNC: &str = "hedl_rename_field";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("schema_name", &sanitize_pointer(schema_name)),
+            ("old_name", &sanitize_pointer(old_name)),
+            ("new_name", &sanitize_pointer(new_name)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let doc_ref = &mut (*doc).inner;
+    let (schema_name, columns) = match schema_arg(FUNC, doc_ref, schema_name, start) {
+        Ok(found) => found,
+        Err(code) => return code,
+    };
+    let (old_name, new_name) = match (c_str_arg(old_name), c_str_arg(new_name)) {
+        (Ok(old_name), Ok(new_name)) => (old_name, new_name),
+        (Err(code), _) | (_, Err(code)) => {
+            audit_call_failure(FUNC, code, "Invalid field argument", start.elapsed());
+            return code;
+        }
+    };
+    let index = match columns.iter().position(|name| name == old_name) {
+        Some(index) => index,
+        None => {
+            let err_msg = format!("Unknown field {} in type {}", old_name, schema_name);
+            set_error(&err_msg);
+            audit_call_failure(FUNC, HEDL_ERR_NOT_FOUND, &err_msg, start.elapsed());
+            return HEDL_ERR_NOT_FOUND;
+        }
+    };
+    if old_name != new_name && columns.iter().any(|name| name == new_name) {
+        let err_msg = format!("Field {} already exists in type {}", new_name, schema_name);
+        set_error(&err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_CONFLICT, &err_msg, start.elapsed());
+        return HEDL_ERR_CONFLICT;
+    }
+
+    let schema_name = schema_name.to_string();
+    if let Some(columns) = doc_ref.structs.get_mut(&schema_name) {
+        columns[index] = new_name.to_string();
+    }
+    visit_lists_mut(&mut doc_ref.root, &mut |list| {
+        if list.type_name == schema_name {
+            if let Some(column) = list.schema.get_mut(index) {
+                *column = new_name.to_string();
+            }
+        }
+    });
+
+    audit_call_success(FUNC, start.elapsed());
+    HEDL_OK
+}
+
+/// Rename a struct type, modifying the document in place.
+///
+/// The type is renamed in its declaration, its lists and rows, `%NEST`
+/// declarations and qualified references, so that `@Old:id` becomes
+/// `@New:id`. Renaming a type to its own name does nothing.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `old_name` - NUL-terminated name of the struct type to rename
+/// * `new_name` - NUL-terminated new name of the type
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_NOT_FOUND if the type is neither declared
+/// nor used by a list, HEDL_ERR_CONFLICT if a type named `new_name` already
+/// exists, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_rename_schema(
+    doc: *mut HedlDocument,
+    old_name: *const c_char,
+    new_name: *const c_char,
+) -> c_int {
+    const FUNC: &str = "hedl_rename_schema";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("old_name", &sanitize_pointer(old_name)),
+            ("new_name", &sanitize_pointer(new_name)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let doc_ref = &mut (*doc).inner;
+    let old_name = match schema_arg(FUNC, doc_ref, old_name, start) {
+        Ok((old_name, _)) => old_name.to_string(),
+        Err(code) => return code,
+    };
+    let new_name = match c_str_arg(new_name) {
+        Ok(new_name) => new_name,
+        Err(code) => {
+            audit_call_failure(FUNC, code, "Invalid name argument", start.elapsed());
+            return code;
+        }
+    };
+    if old_name == new_name {
+        audit_call_success(FUNC, start.elapsed());
+        return HEDL_OK;
+    }
+    if schema_columns(doc_ref, new_name).is_some() {
+        let err_msg = format!("Type {} already exists", new_name);
+        set_error(&err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_CONFLICT, &err_msg, start.elapsed());
+        return HEDL_ERR_CONFLICT;
+    }
+
+    let rename = |name: &mut String| {
+        if *name == old_name {
+            *name = new_name.to_string();
+        }
+    };
+    if let Some(columns) = doc_ref.structs.remove(&old_name) {
+        doc_ref.structs.insert(new_name.to_string(), columns);
+    }
+    doc_ref.nests = std::mem::take(&mut doc_ref.nests)
+        .into_iter()
+        .map(|(mut parent, mut child)| {
+            rename(&mut parent);
+            rename(&mut child);
+            (parent, child)
+        })
+        .collect();
+    rename_type(&mut doc_ref.root, &rename);
+
+    audit_call_success(FUNC, start.elapsed());
+    HEDL_OK
+}
+
+// =============================================================================
+// Reference Styles
+// =============================================================================
+
+/// Rewrite the references of a document to one style, modifying the
+/// document in place.
+///
+/// With mode "inline", every key-value reference that resolves to a row is
+/// replaced with a copy of that row: an object holding the row's fields
+/// under its column names, plus a "__type__" key naming its type as in JSON
+/// exported with metadata. Nested child rows are not copied. Matrix cells
+/// cannot hold objects, so a resolvable reference in a cell fails the call.
+///
+/// With mode "id", objects whose "__type__" and ID field name an existing
+/// row are turned back into references, and every reference that resolves
+/// is written in the qualified `@Type:id` form. An unqualified reference in
+/// a cell names a row of the cell's type; in a key-value pair, it resolves
+/// only if its ID belongs to a single type. References that do not resolve
+/// are left as written.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `mode` - NUL-terminated reference style, "inline" or "id"
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_INVALID_ARGUMENT for an unknown mode or a
+/// resolvable reference in a cell in "inline" mode, error code on failure.
+/// The document is left unchanged on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_normalize_references(
+    doc: *mut HedlDocument,
+    mode: *const c_char,
+) -> c_int {
+    const FUNC: &str = "hedl_normalize_references";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("mode", &sanitize_pointer(mode)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let fail = |code: c_int, err_msg: &str| {
+        set_error(err_msg);
+        audit_call_failure(FUNC, code, err_msg, start.elapsed());
+        code
+    };
+
+    let inline = match c_str_arg(mode) {
+        Ok("inline") => true,
+        Ok("id") => false,
+        Ok(mode) => {
+            let err_msg = format!("Unknown reference mode: {:?}", mode);
+            return fail(HEDL_ERR_INVALID_ARGUMENT, &err_msg);
+        }
+        Err(code) => {
+            audit_call_failure(FUNC, code, "Invalid mode argument", start.elapsed());
+            return code;
+        }
+    };
+
+    let doc_ref = &mut (*doc).inner;
+    let source = doc_ref.clone();
+    let rows = RowIndex::new(&source);
+
+    let mut cell_error = None;
+    visit_row_lists_mut(&mut doc_ref.root, &source, &mut |schema, row| {
+        for (i, value) in row.fields.iter_mut().enumerate() {
+            let Value::Reference(r) = value else {
+                continue;
+            };
+            let Some(key) = rows.resolve(r, Some(&row.type_name)) else {
+                continue;
+            };
+            if !inline {
+                *r = rows.qualified(&key);
+            } else if cell_error.is_none() {
+                let field = schema.get(i).map(String::as_str).unwrap_or("");
+                cell_error = Some(format!(
+                    "{} row {} field \"{}\" holds reference {}; matrix cells cannot hold an inline row",
+                    row.type_name, row.id, field, r.to_ref_string()
+                ));
+            }
+        }
+    });
+    if let Some(err_msg) = cell_error {
+        return fail(HEDL_ERR_INVALID_ARGUMENT, &err_msg);
+    }
+    rows.rewrite_key_values(&mut doc_ref.root, inline);
+
+    audit_call_success(FUNC, start.elapsed());
+    HEDL_OK
+}
+
+/// The rows of a document by "Type:id", for `hedl_normalize_references`.
+struct RowIndex<'a> {
+    doc: &'a Document,
+    /// The first row with each key, with the schema of its list.
+    rows: HashMap<String, (&'a [String], &'a Node)>,
+    /// The types having a row with each ID.
+    types_by_id: HashMap<&'a str, Vec<&'a str>>,
+}
+
+impl<'a> RowIndex<'a> {
+    fn new(doc: &'a Document) -> Self {
+        let mut index = RowIndex {
+            doc,
+            rows: HashMap::new(),
+            types_by_id: HashMap::new(),
+        };
+        visit_indexed_rows(doc, &mut |schema, _, row| {
+            let key = format!("{}:{}", row.type_name, row.id);
+            if !index.rows.contains_key(&key) {
+                index.rows.insert(key, (schema, row));
+                let types = index.types_by_id.entry(&row.id).or_default();
+                types.push(&row.type_name);
+            }
+        });
+        index
+    }
+
+    /// The key of the row `r` names, if it exists. An unqualified reference
+    /// names a row of `context_type`, the type of the row holding it, or
+    /// outside a row, of the only type with a row of its ID.
+    fn resolve(&self, r: &Reference, context_type: Option<&str>) -> Option<String> {
+        let type_name = match (&r.type_name, context_type) {
+            (Some(type_name), _) => type_name.as_str(),
+            (None, Some(context_type)) => context_type,
+            (None, None) => match self.types_by_id.get(r.id.as_str())?.as_slice() {
+                [type_name] => type_name,
+                _ => return None,
+            },
+        };
+        let key = format!("{}:{}", type_name, r.id);
+        self.rows.contains_key(&key).then_some(key)
+    }
+
+    /// The qualified reference to the row with `key`.
+    fn qualified(&self, key: &str) -> Reference {
+        let (type_name, id) = key.split_once(':').unwrap_or(("", key));
+        Reference::qualified(type_name, id)
+    }
+
+    /// The key of the row `obj` is an inline copy of: an object whose
+    /// "__type__" and ID field name an existing row.
+    fn embedded(&self, obj: &BTreeMap<String, Item>) -> Option<String> {
+        let Some(Item::Scalar(Value::String(type_name))) = obj.get("__type__") else {
+            return None;
+        };
+        let id_field = schema_columns(self.doc, type_name)?.first()?;
+        let Some(Item::Scalar(id)) = obj.get(id_field) else {
+            return None;
+        };
+        let key = format!("{}:{}", type_name, id);
+        self.rows.contains_key(&key).then_some(key)
+    }
+
+    /// Rewrite the references of the key-value pairs under `items`, inlining
+    /// rows if `inline` is set and turning inline rows back into qualified
+    /// references otherwise.
+    fn rewrite_key_values(&self, items: &mut BTreeMap<String, Item>, inline: bool) {
+        for item in items.values_mut() {
+            match item {
+                Item::Object(obj) => match self.embedded(obj) {
+                    Some(key) if !inline => {
+                        *item = Item::Scalar(Value::Reference(self.qualified(&key)));
+                    }
+                    _ => self.rewrite_key_values(obj, inline),
+                },
+                Item::Scalar(Value::Reference(r)) => {
+                    let Some(key) = self.resolve(r, None) else {
+                        continue;
+                    };
+                    if !inline {
+                        *r = self.qualified(&key);
+                        continue;
+                    }
+                    let (schema, row) = self.rows[&key];
+                    let mut copy = BTreeMap::new();
+                    copy.insert(
+                        "__type__".to_string(),
+                        Item::Scalar(Value::String(row.type_name.clone())),
+                    );
+                    for (column, value) in schema.iter().zip(&row.fields) {
+                        copy.insert(column.clone(), Item::Scalar(value.clone()));
+                    }
+                    *item = Item::Object(copy);
+                }
+                Item::Scalar(_) | Item::List(_) => {}
+            }
+        }
+    }
+}
+
+// =============================================================================
+// Joining
+// =============================================================================
+
+/// Left-join the rows of one struct type with those of another document.
+///
+/// Every row of the type in `doc` gains the `add_fields` columns of the row
+/// of `other` with the same `key_field` value, or nulls when there is none.
+/// Keys match only when their values have the same type and text, so the
+/// string "1" never matches the integer 1; a null key matches nothing, so
+/// rows of `doc` without a key get nulls and rows of `other` without one are
+/// ignored. Rows of `other` are read by the schema of their own list, and
+/// neither document is modified.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse holding the rows to enrich
+/// * `other` - Document handle from hedl_parse holding the rows to join
+/// * `schema_name` - NUL-terminated name of the struct type
+/// * `key_field` - NUL-terminated name of the field to join on
+/// * `add_fields` - Array of `field_count` NUL-terminated field names of `other`
+/// * `field_count` - Number of fields
+/// * `out_doc` - Pointer to store the new document handle (must be freed with hedl_free_document)
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_NOT_FOUND if either document lacks the
+/// type, the key field or one of `add_fields`, HEDL_ERR_CONFLICT if one of
+/// `add_fields` is already a field of the type in `doc` or a key repeats
+/// among the rows of `other`, HEDL_ERR_INVALID_ARGUMENT if a list of the
+/// type in `doc` has a schema other than the type's columns, error code on
+/// failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc or other is
+/// NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_join(
+    doc: *const HedlDocument,
+    other: *const HedlDocument,
+    schema_name: *const c_char,
+    key_field: *const c_char,
+    add_fields: *const *const c_char,
+    field_count: c_int,
+    out_doc: *mut *mut HedlDocument,
+) -> c_int {
+    const FUNC: &str = "hedl_join";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("other", &sanitize_pointer(other)),
+            ("schema_name", &sanitize_pointer(schema_name)),
+            ("key_field", &sanitize_pointer(key_field)),
+            ("add_fields", &sanitize_pointer(add_fields)),
+            ("field_count", &field_count.to_string()),
+            ("out_doc", &sanitize_pointer(out_doc)),
+        ],
+    );
+
+    clear_error();
+
+    let fields_missing = field_count > 0 && add_fields.is_null();
+    if !is_valid_document_ptr(doc)
+        || !is_valid_document_ptr(other)
+        || out_doc.is_null()
+        || fields_missing
+    {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+    *out_doc = ptr::null_mut();
+
+    let fail = |code: c_int, err_msg: &str| {
+        set_error(err_msg);
+        audit_call_failure(FUNC, code, err_msg, start.elapsed());
+        code
+    };
+
+    let (left, right) = (&(*doc).inner, &(*other).inner);
+    let (schema_name, columns) = match schema_arg(FUNC, left, schema_name, start) {
+        Ok(schema) => schema,
+        Err(code) => return code,
+    };
+    let Some(right_columns) = schema_columns(right, schema_name) else {
+        let err_msg = format!("Unknown type: {}", schema_name);
+        return fail(HEDL_ERR_NOT_FOUND, &err_msg);
+    };
+    let key_field = match c_str_arg(key_field) {
+        Ok(field) => field,
+        Err(code) => {
+            audit_call_failure(FUNC, code, "Invalid field argument", start.elapsed());
+            return code;
+        }
+    };
+    let unknown_field = |field: &str| format!("Unknown field {} in type {}", field, schema_name);
+    let Some(key_index) = columns.iter().position(|column| column == key_field) else {
+        return fail(HEDL_ERR_NOT_FOUND, &unknown_field(key_field));
+    };
+    if !right_columns.iter().any(|column| column == key_field) {
+        return fail(HEDL_ERR_NOT_FOUND, &unknown_field(key_field));
+    }
+    let mut fields = Vec::new();
+    for i in 0..field_count.max(0) as usize {
+        let field = match c_str_arg(*add_fields.add(i)) {
+            Ok(field) => field,
+            Err(code) => {
+                audit_call_failure(FUNC, code, "Invalid field argument", start.elapsed());
+                return code;
+            }
+        };
+        if columns.iter().any(|column| column == field) {
+            let err_msg = format!("Field {} already exists in type {}", field, schema_name);
+            return fail(HEDL_ERR_CONFLICT, &err_msg);
+        }
+        if !right_columns.iter().any(|column| column == field) {
+            return fail(HEDL_ERR_NOT_FOUND, &unknown_field(field));
+        }
+        fields.push(field.to_string());
+    }
+
+    // The joined rows take the type's columns plus the added fields, so
+    // every list of the type in `doc` must use those columns.
+    let mut mismatched = None;
+    visit_lists(&left.root, &mut |list| {
+        if list.type_name == schema_name && list.schema != columns && mismatched.is_none() {
+            mismatched = Some(list.schema.join(", "));
+        }
+    });
+    if let Some(schema) = mismatched {
+        let err_msg = format!(
+            "A {} list has schema [{}], not the declared [{}]",
+            schema_name,
+            schema,
+            columns.join(", ")
+        );
+        return fail(HEDL_ERR_INVALID_ARGUMENT, &err_msg);
+    }
+
+    let mut matches: HashMap<String, Vec<Value>> = HashMap::new();
+    let mut repeated = None;
+    visit_indexed_rows(right, &mut |schema, _, row| {
+        if row.type_name != schema_name || repeated.is_some() {
+            return;
+        }
+        let position = |field: &str| schema.iter().position(|column| column == field);
+        let Some(key_col) = position(key_field) else {
+            return;
+        };
+        if matches!(row.fields.get(key_col), None | Some(Value::Null)) {
+            return;
+        }
+        let key = partition_key(row, key_col);
+        if matches.contains_key(&key) {
+            repeated = Some(key);
+            return;
+        }
+        let values = fields
+            .iter()
+            .map(|field| {
+                position(field)
+                    .and_then(|col| row.fields.get(col))
+                    .cloned()
+                    .unwrap_or(Value::Null)
+            })
+            .collect();
+        matches.insert(key, values);
+    });
+    if let Some(key) = repeated {
+        let err_msg = format!("Key {} repeats in the joined {} rows", key, schema_name);
+        return fail(HEDL_ERR_CONFLICT, &err_msg);
+    }
+
+    let mut joined = left.clone();
+    if let Some(declared) = joined.structs.get_mut(schema_name) {
+        declared.extend(fields.iter().cloned());
+    }
+    visit_lists_mut(&mut joined.root, &mut |list| {
+        if list.type_name == schema_name {
+            list.schema.extend(fields.iter().cloned());
+        }
+    });
+    visit_rows_mut(&mut joined.root, schema_name, &mut |row| {
+        let found = match row.fields.get(key_index) {
+            None | Some(Value::Null) => None,
+            Some(_) => matches.get(&partition_key(row, key_index)),
+        };
+        match found {
+            Some(values) => row.fields.extend(values.iter().cloned()),
+            None => row.fields.resize(columns.len() + fields.len(), Value::Null),
+        }
+    });
+
+    *out_doc = Box::into_raw(Box::new(HedlDocument::new(joined)));
+    audit_call_success(FUNC, start.elapsed());
+    HEDL_OK
+}
+
+/// Read a NUL-terminated UTF-8 argument.
+pub(crate) unsafe fn c_str_arg<'a>(arg: *const c_char) -> Result<&'a str, c_int> {
+    if arg.is_null() {
+        set_error("Null pointer argument");
+        return Err(HEDL_ERR_NULL_PTR);
+    }
+    CStr::from_ptr(arg).to_str().map_err(|e| {
+        set_error(&format!("Invalid UTF-8: {}", e));
+        HEDL_ERR_INVALID_UTF8
+    })
+}
+
+/// The column of `field` in struct type `schema_name`, if both exist.
+fn field_index(doc: &Document, schema_name: &str, field: &str) -> Option<usize> {
+    doc.structs
+        .get(schema_name)?
+        .iter()
+        .position(|name| name == field)
+}
+
+/// Read the type and field arguments of a partition call and find the
+/// field's column, recording the failure for `func` if either is invalid or
+/// not declared.
+unsafe fn partition_field<'a>(
+    func: &'static str,
+    doc: &Document,
+    schema_name: *const c_char,
+    field: *const c_char,
+    start: Instant,
+) -> Result<(&'a str, usize), c_int> {
+    let (schema_name, field) = match (c_str_arg(schema_name), c_str_arg(field)) {
+        (Ok(schema_name), Ok(field)) => (schema_name, field),
+        (Err(code), _) | (_, Err(code)) => {
+            audit_call_failure(func, code, "Invalid name argument", start.elapsed());
+            return Err(code);
+        }
+    };
+    let err_msg = if !doc.structs.contains_key(schema_name) {
+        format!("Unknown type: {}", schema_name)
+    } else if let Some(index) = field_index(doc, schema_name, field) {
+        return Ok((schema_name, index));
+    } else {
+        format!("Unknown field {} in type {}", field, schema_name)
+    };
+    set_error(&err_msg);
+    audit_call_failure(func, HEDL_ERR_NOT_FOUND, &err_msg, start.elapsed());
+    Err(HEDL_ERR_NOT_FOUND)
+}
+
+/// The partition key of a row: the HEDL text of its field at `index`, with
+/// strings always quoted and whole floats written with a fraction, so that
+/// values of different types have different keys.
+pub(crate) fn partition_key(row: &Node, index: usize) -> String {
+    match row.fields.get(index) {
+        Some(Value::String(s)) => format!("\"{}\"", s.replace('"', "\"\"")),
+        Some(Value::Float(f)) if f.is_finite() && f.fract() == 0.0 => format!("{:.1}", f),
+        Some(Value::Tensor(t)) => t.to_string(),
+        Some(value) => value.to_string(),
+        None => Value::Null.to_string(),
+    }
+}
+
+/// Call `f` for every row of `type_name` under `items`, including rows in
+/// lists nested in objects and under other rows.
+pub(crate) fn visit_rows(
+    items: &BTreeMap<String, Item>,
+    type_name: &str,
+    f: &mut dyn FnMut(&Node),
+) {
+    fn visit(nodes: &[Node], type_name: &str, f: &mut dyn FnMut(&Node)) {
+        for node in nodes {
+            if node.type_name == type_name {
+                f(node);
+            }
+            for children in node.children.values() {
+                visit(children, type_name, f);
+            }
+        }
+    }
+
+    for item in items.values() {
+        match item {
+            Item::List(list) => visit(&list.rows, type_name, f),
+            Item::Object(obj) => visit_rows(obj, type_name, f),
+            Item::Scalar(_) => {}
+        }
+    }
+}
+
+/// Drop the rows of `type_name` under `items` for which `keep` is false,
+/// including rows in lists nested in objects and under other rows.
+fn retain_rows(items: &mut BTreeMap<String, Item>, type_name: &str, keep: &dyn Fn(&Node) -> bool) {
+    fn retain(nodes: &mut Vec<Node>, type_name: &str, keep: &dyn Fn(&Node) -> bool) {
+        nodes.retain(|node| node.type_name != type_name || keep(node));
+        for node in nodes {
+            for children in node.children.values_mut() {
+                retain(children, type_name, keep);
+            }
+        }
+    }
+
+    for item in items.values_mut() {
+        match item {
+            Item::List(list) => retain(&mut list.rows, type_name, keep),
+            Item::Object(obj) => retain_rows(obj, type_name, keep),
+            Item::Scalar(_) => {}
+        }
+    }
+}
+
+/// Drop each row of `type_name` under `items` for which `same` holds with
+/// the row before it in the same list, updating count hints, and return the
+/// number of rows dropped.
+fn dedup_rows(
+    items: &mut BTreeMap<String, Item>,
+    type_name: &str,
+    same: &dyn Fn(&Node, &Node) -> bool,
+) -> usize {
+    fn dedup(nodes: &mut Vec<Node>, type_name: &str, same: &dyn Fn(&Node, &Node) -> bool) -> usize {
+        let before = nodes.len();
+        nodes.dedup_by(|row, prev| {
+            row.type_name == type_name && prev.type_name == type_name && same(prev, row)
+        });
+        let mut removed = before - nodes.len();
+        for node in nodes {
+            for children in node.children.values_mut() {
+                removed += dedup(children, type_name, same);
+            }
+            if node.child_count.is_some() {
+                node.child_count = Some(node.children.values().map(Vec::len).sum());
+            }
+        }
+        removed
+    }
+
+    let mut removed = 0;
+    for item in items.values_mut() {
+        match item {
+            Item::List(list) => {
+                removed += dedup(&mut list.rows, type_name, same);
+                if list.count_hint.is_some() {
+                    list.count_hint = Some(list.rows.len());
+                }
+            }
+            Item::Object(obj) => removed += dedup_rows(obj, type_name, same),
+            Item::Scalar(_) => {}
+        }
+    }
+    removed
+}
+
+/// Call `f` for every row of `type_name` under `items`, as `visit_rows`
+/// does, allowing the row to be modified.
+fn visit_rows_mut(
+    items: &mut BTreeMap<String, Item>,
+    type_name: &str,
+    f: &mut dyn FnMut(&mut Node),
+) {
+    fn visit(nodes: &mut [Node], type_name: &str, f: &mut dyn FnMut(&mut Node)) {
+        for node in nodes {
+            if node.type_name == type_name {
+                f(node);
+            }
+            for children in node.children.values_mut() {
+                visit(children, type_name, f);
+            }
+        }
+    }
+
+    for item in items.values_mut() {
+        match item {
+            Item::List(list) => visit(&mut list.rows, type_name, f),
+            Item::Object(obj) => visit_rows_mut(obj, type_name, f),
+            Item::Scalar(_) => {}
+        }
+    }
+}
+
+/// Parse a strftime format for `hedl_normalize_dates`, making `%#z` write
+/// the offset as RFC 3339 does. Returns None if the format is empty or
+/// invalid, or cannot be used to write a date.
+fn date_format_items(format: &str) -> Option<Vec<FormatItem<'_>>> {
+    let rfc3339_offset = StrftimeItems::new("%#z").next();
+    let items: Vec<FormatItem> = StrftimeItems::new(format)
+        .map(|item| {
+            if Some(&item) == rfc3339_offset.as_ref() {
+                FormatItem::Fixed(Fixed::TimezoneOffsetColonZ)
+            } else {
+                item
+            }
+        })
+        .collect();
+    if items.is_empty() || items.contains(&FormatItem::Error) {
+        return None;
+    }
+    let sample = DateTime::from_timestamp(0, 0)?.fixed_offset();
+    let mut text = String::new();
+    write!(text, "{}", sample.format_with_items(items.iter())).ok()?;
+    Some(items)
+}
+
+/// Read a date in one of the formats recognized by `hedl_normalize_dates`.
+fn parse_date(value: &str) -> Option<DateTime<FixedOffset>> {
+    let value = value.trim();
+    if let Ok(date) = DateTime::parse_from_rfc3339(value) {
+        return Some(date);
+    }
+    for format in DATE_TIME_FORMATS {
+        if let Ok(date) = NaiveDateTime::parse_from_str(value, format) {
+            return Some(date.and_utc().fixed_offset());
+        }
+    }
+    for format in DATE_FORMATS {
+        if let Ok(date) = NaiveDate::parse_from_str(value, format) {
+            return Some(date.and_time(NaiveTime::MIN).and_utc().fixed_offset());
+        }
+    }
+    DateTime::parse_from_rfc2822(value).ok()
+}
+
+/// Keep `n` top-level rows of each type under `items`, counted across the
+/// type's lists held by objects, taken from the end when `from_end` is set
+/// and from the start otherwise. Count hints of the lists are updated.
+fn keep_rows(items: &mut BTreeMap<String, Item>, n: usize, from_end: bool) {
+    let mut skip: HashMap<String, usize> = HashMap::new();
+    if from_end {
+        visit_lists(items, &mut |list| {
+            *skip.entry(list.type_name.clone()).or_insert(0) += list.rows.len();
+        });
+        for total in skip.values_mut() {
+            *total = total.saturating_sub(n);
+        }
+    }
+
+    let mut rows_seen: HashMap<String, usize> = HashMap::new();
+    visit_lists_mut(items, &mut |list| {
+        let skip = skip.get(&list.type_name).copied().unwrap_or(0);
+        let index = rows_seen.entry(list.type_name.clone()).or_insert(0);
+        list.rows.retain(|_| {
+            let keep = *index >= skip && *index - skip < n;
+            *index += 1;
+            keep
+        });
+        if list.count_hint.is_some() {
+            list.count_hint = Some(list.rows.len());
+        }
+    });
+}
+
+/// Call `f` for every matrix list held by an object under `items`, as
+/// `visit_lists` does, allowing the list to be modified.
+fn visit_lists_mut(items: &mut BTreeMap<String, Item>, f: &mut dyn FnMut(&mut MatrixList)) {
+    for item in items.values_mut() {
+        match item {
+            Item::List(list) => f(list),
+            Item::Object(obj) => visit_lists_mut(obj, f),
+            Item::Scalar(_) => {}
+        }
+    }
+}
+
+/// Apply `rename` to every type name under `items`: those of lists, rows
+/// and the children grouped under them, and those of qualified references
+/// in key-value pairs and cells.
+fn rename_type(items: &mut BTreeMap<String, Item>, rename: &dyn Fn(&mut String)) {
+    fn rename_value(value: &mut Value, rename: &dyn Fn(&mut String)) {
+        if let Value::Reference(r) = value {
+            if let Some(type_name) = &mut r.type_name {
+                rename(type_name);
+            }
+        }
+    }
+
+    fn rename_rows(nodes: &mut [Node], rename: &dyn Fn(&mut String)) {
+        for node in nodes {
+            rename(&mut node.type_name);
+            for value in &mut node.fields {
+                rename_value(value, rename);
+            }
+            let children = std::mem::take(&mut node.children);
+            for (mut child_type, mut children) in children {
+                rename(&mut child_type);
+                rename_rows(&mut children, rename);
+                node.children.insert(child_type, children);
+            }
+        }
+    }
+
+    for item in items.values_mut() {
+        match item {
+            Item::Scalar(value) => rename_value(value, rename),
+            Item::Object(obj) => rename_type(obj, rename),
+            Item::List(list) => {
+                rename(&mut list.type_name);
+                rename_rows(&mut list.rows, rename);
+            }
+        }
+    }
+}
+
+/// Add the type of every matrix list under `items` to `types`, including
+/// lists nested under other rows.
+pub(crate) fn collect_types<'a>(items: &'a BTreeMap<String, Item>, types: &mut HashSet<&'a str>) {
+    fn collect<'a>(rows: &'a [Node], types: &mut HashSet<&'a str>) {
+        for row in rows {
+            for (child_type, children) in &row.children {
+                types.insert(child_type);
+                collect(children, types);
+            }
+        }
+    }
+
+    visit_lists(items, &mut |list| {
+        types.insert(&list.type_name);
+        collect(&list.rows, types);
+    });
+}
+
+/// Normalize the date-like strings of every key-value pair and cell under
+/// `items`, except row IDs, for `hedl_to_git_friendly` and
+/// `hedl_normalize_scalars`.
+fn normalize_scalars(items: &mut BTreeMap<String, Item>) {
+    fn normalize_rows(nodes: &mut [Node]) {
+        for node in nodes {
+            node.fields.iter_mut().skip(1).for_each(normalize_scalar);
+            for children in node.children.values_mut() {
+                normalize_rows(children);
+            }
+        }
+    }
+
+    for item in items.values_mut() {
+        match item {
+            Item::Scalar(value) => normalize_scalar(value),
+            Item::Object(obj) => normalize_scalars(obj),
+            Item::List(list) => normalize_rows(&mut list.rows),
+        }
+    }
+}
+
+/// Rewrite a `YYYY-M-D` date zero-padded, or an RFC 3339 timestamp with `Z`
+/// for UTC and without trailing zeros in its fractional seconds. Other
+/// values, including invalid dates, are left as they are.
+fn normalize_scalar(value: &mut Value) {
+    let Value::String(s) = value else {
+        return;
+    };
+    let parts: Vec<&str> = s.split('-').collect();
+    let loose_date = parts.len() == 3
+        && parts[0].len() == 4
+        && parts[1..].iter().all(|part| (1..=2).contains(&part.len()))
+        && s.bytes().all(|b| b.is_ascii_digit() || b == b'-');
+    if loose_date {
+        if let Ok(date) = NaiveDate::parse_from_str(s, "%Y-%m-%d") {
+            *s = date.format("%Y-%m-%d").to_string();
+        }
+    } else if s.contains('T') {
+        if let Ok(date) = DateTime::parse_from_rfc3339(s) {
+            let mut text = date.format("%Y-%m-%dT%H:%M:%S").to_string();
+            let nanos = date.timestamp_subsec_nanos();
+            if nanos > 0 {
+                let fraction = format!("{:09}", nanos);
+                text.push('.');
+                text.push_str(fraction.trim_end_matches('0'));
+            }
+            if date.offset().local_minus_utc() == 0 {
+                text.push('Z');
+            } else {
+                text.push_str(&date.format("%:z").to_string());
+            }
+            *s = text;
+        }
+    }
+}
+
+/// Call `f` for every row under `items` with the schema of its list, as
+/// `visit_indexed_rows` does, allowing the row to be modified. Nested rows
+/// take the declared schema of their type in `doc`.
+fn visit_row_lists_mut(
+    items: &mut BTreeMap<String, Item>,
+    doc: &Document,
+    f: &mut dyn FnMut(&[String], &mut Node),
+) {
+    fn visit(
+        rows: &mut [Node],
+        schema: &[String],
+        doc: &Document,
+        f: &mut dyn FnMut(&[String], &mut Node),
+    ) {
+        for row in rows {
+            f(schema, row);
+            for (child_type, children) in &mut row.children {
+                let child_schema = doc
+                    .structs
+                    .get(child_type)
+                    .map(Vec::as_slice)
+                    .unwrap_or(&[]);
+                visit(children, child_schema, doc, f);
+            }
+        }
+    }
+
+    visit_lists_mut(items, &mut |list| {
+        visit(&mut list.rows, &list.schema, doc, f)
+    });
+}
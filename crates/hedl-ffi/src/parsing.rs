@@ -18,16 +18,27 @@
 //! Parsing functions for FFI.
 
 use crate::audit::{
-    audit_call_failure, audit_call_start, audit_call_success, sanitize_c_string, sanitize_pointer,
+    audit_call_failure, audit_call_start, audit_call_success, sanitize_c_string_len,
+    sanitize_pointer,
 };
-use crate::error::{clear_error, set_error};
+use crate::checks::{schema_arg, visit_indexed_rows};
+use crate::error::{clear_error, set_error, set_error_location};
 use crate::memory::{hedl_free_document, is_valid_document_ptr};
-use crate::types::{HedlDocument, HEDL_ERR_NULL_PTR, HEDL_ERR_PARSE, HEDL_OK};
-use crate::utils::get_input_string;
-use hedl_core::{parse_with_limits, ParseOptions};
-use std::os::raw::{c_char, c_int};
+use crate::types::{
+    HedlDiagnostics, HedlDocument, HEDL_ERR_ALLOC, HEDL_ERR_INVALID_ARGUMENT, HEDL_ERR_NOT_FOUND,
+    HEDL_ERR_NULL_PTR, HEDL_ERR_PARSE, HEDL_ERR_TIMEOUT, HEDL_OK,
+};
+use crate::utils::{allocate_output_string, get_input_string};
+use hedl_core::{
+    parse_with_deadline, parse_with_limits, HedlErrorKind, Item, Node, ParseOptions, Tensor, Value,
+    DEADLINE_EXCEEDED,
+};
+use hedl_lint::{Diagnostic, DiagnosticKind};
+use sha2::{Digest, Sha256};
+use std::collections::{BTreeMap, HashMap};
+use std::os::raw::{c_char, c_int, c_longlong};
 use std::ptr;
-use std::time::Instant;
+use std::time::{Duration, Instant};
 
 // =============================================================================
 // Parsing and Validation
@@ -54,7 +65,7 @@ pub unsafe extern "C" fn hedl_parse(
     out_doc: *mut *mut HedlDocument,
 ) -> c_int {
     let start = Instant::now();
-    let input_preview = sanitize_c_string(input, 64);
+    let input_preview = sanitize_c_string_len(input, input_len, 64);
 
     audit_call_start(
         "hedl_parse",
@@ -93,7 +104,7 @@ pub unsafe extern "C" fn hedl_parse(
 
     match parse_with_limits(input_str.as_bytes(), options) {
         Ok(doc) => {
-            let handle = Box::new(HedlDocument { inner: doc });
+            let handle = Box::new(HedlDocument::new(doc));
             *out_doc = Box::into_raw(handle);
             audit_call_success("hedl_parse", start.elapsed());
             HEDL_OK
@@ -102,6 +113,7 @@ pub unsafe extern "C" fn hedl_parse(
             let duration = start.elapsed();
             let msg = format!("Parse error: {}", e);
             set_error(&msg);
+            set_error_location(e.line, e.column.unwrap_or(0));
             *out_doc = ptr::null_mut();
             audit_call_failure("hedl_parse", HEDL_ERR_PARSE, &msg, duration);
             HEDL_ERR_PARSE
@@ -109,6 +121,295 @@ pub unsafe extern "C" fn hedl_parse(
     }
 }
 
+/// Parse a HEDL document from a string, giving up after a time budget.
+///
+/// The parser checks the deadline periodically and aborts cleanly once it
+/// has passed, so callers get real interruption instead of having to abandon
+/// a blocked thread.
+///
+/// # Arguments
+/// * `input` - UTF-8 encoded HEDL document
+/// * `input_len` - Length of input in bytes, or -1 for null-terminated
+/// * `strict` - Non-zero for strict mode (validate references)
+/// * `timeout_ms` - Time budget in milliseconds; zero or negative fails immediately
+/// * `out_doc` - Pointer to store document handle
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_TIMEOUT if the budget ran out, or another
+/// error code on failure.
+///
+/// # Safety
+/// All pointers must be valid.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_parse_with_deadline(
+    input: *const c_char,
+    input_len: c_int,
+    strict: c_int,
+    timeout_ms: c_longlong,
+    out_doc: *mut *mut HedlDocument,
+) -> c_int {
+    let start = Instant::now();
+
+    audit_call_start(
+        "hedl_parse_with_deadline",
+        &[
+            ("input_ptr", &sanitize_pointer(input)),
+            ("input_len", &input_len.to_string()),
+            ("strict", &strict.to_string()),
+            ("timeout_ms", &timeout_ms.to_string()),
+            ("out_doc", &sanitize_pointer(out_doc)),
+        ],
+    );
+
+    clear_error();
+
+    if input.is_null() || out_doc.is_null() {
+        set_error("Null pointer argument");
+        audit_call_failure(
+            "hedl_parse_with_deadline",
+            HEDL_ERR_NULL_PTR,
+            "Null pointer argument",
+            start.elapsed(),
+        );
+        return HEDL_ERR_NULL_PTR;
+    }
+    *out_doc = ptr::null_mut();
+
+    if timeout_ms <= 0 {
+        let msg = "Parse deadline exceeded before parsing started";
+        set_error(msg);
+        audit_call_failure(
+            "hedl_parse_with_deadline",
+            HEDL_ERR_TIMEOUT,
+            msg,
+            start.elapsed(),
+        );
+        return HEDL_ERR_TIMEOUT;
+    }
+    let deadline = start + Duration::from_millis(timeout_ms as u64);
+
+    let input_str = match get_input_string(input, input_len) {
+        Ok(s) => s,
+        Err(code) => {
+            let msg = crate::error::get_thread_local_error();
+            audit_call_failure("hedl_parse_with_deadline", code, &msg, start.elapsed());
+            return code;
+        }
+    };
+
+    let options = ParseOptions {
+        strict_refs: strict != 0,
+        ..Default::default()
+    };
+
+    match parse_with_deadline(input_str.as_bytes(), options, deadline) {
+        Ok(doc) => {
+            let handle = Box::new(HedlDocument::new(doc));
+            *out_doc = Box::into_raw(handle);
+            audit_call_success("hedl_parse_with_deadline", start.elapsed());
+            HEDL_OK
+        }
+        Err(e) => {
+            // Only the deadline check itself counts as a timeout; other
+            // errors are reported as parse errors even near the deadline.
+            let timed_out = e.kind == HedlErrorKind::Security && e.message == DEADLINE_EXCEEDED;
+            let (code, msg) = if timed_out {
+                (HEDL_ERR_TIMEOUT, format!("Parse deadline exceeded: {}", e))
+            } else {
+                (HEDL_ERR_PARSE, format!("Parse error: {}", e))
+            };
+            set_error(&msg);
+            if code == HEDL_ERR_PARSE {
+                set_error_location(e.line, e.column.unwrap_or(0));
+            }
+            audit_call_failure("hedl_parse_with_deadline", code, &msg, start.elapsed());
+            code
+        }
+    }
+}
+
+/// Parse a HEDL document from a string, recording the source line of every
+/// matrix row for `hedl_row_source_line`.
+///
+/// Lines are found by scanning the input for row lines and are kept by type
+/// name and row ID, so they stay correct after in-place edits that keep IDs
+/// but always refer to the original text. Lines inside block strings are
+/// not taken for rows.
+///
+/// # Arguments
+/// * `input` - UTF-8 encoded HEDL document
+/// * `input_len` - Length of input in bytes, or -1 for null-terminated
+/// * `strict` - Non-zero for strict mode (validate references)
+/// * `out_doc` - Pointer to store document handle
+///
+/// # Returns
+/// HEDL_OK on success, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_parse_with_provenance(
+    input: *const c_char,
+    input_len: c_int,
+    strict: c_int,
+    out_doc: *mut *mut HedlDocument,
+) -> c_int {
+    const FUNC: &str = "hedl_parse_with_provenance";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("input_ptr", &sanitize_pointer(input)),
+            ("input_len", &input_len.to_string()),
+            ("strict", &strict.to_string()),
+            ("out_doc", &sanitize_pointer(out_doc)),
+        ],
+    );
+
+    clear_error();
+
+    if input.is_null() || out_doc.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+    *out_doc = ptr::null_mut();
+
+    let input_str = match get_input_string(input, input_len) {
+        Ok(s) => s,
+        Err(code) => {
+            let msg = crate::error::get_thread_local_error();
+            audit_call_failure(FUNC, code, &msg, start.elapsed());
+            return code;
+        }
+    };
+
+    let options = ParseOptions {
+        strict_refs: strict != 0,
+        ..Default::default()
+    };
+
+    match parse_with_limits(input_str.as_bytes(), options) {
+        Ok(doc) => {
+            let mut handle = HedlDocument::new(doc);
+            handle.row_lines = Some(row_lines(&input_str));
+            *out_doc = Box::into_raw(Box::new(handle));
+            audit_call_success(FUNC, start.elapsed());
+            HEDL_OK
+        }
+        Err(e) => {
+            let msg = format!("Parse error: {}", e);
+            set_error(&msg);
+            set_error_location(e.line, e.column.unwrap_or(0));
+            audit_call_failure(FUNC, HEDL_ERR_PARSE, &msg, start.elapsed());
+            HEDL_ERR_PARSE
+        }
+    }
+}
+
+/// Find the line of every matrix row in `input`, by type name and ID,
+/// keeping the first line of a repeated ID. Row types come from the list a
+/// row belongs to, or from the %NEST rule of its parent row for nested rows.
+fn row_lines(input: &str) -> HashMap<(String, String), usize> {
+    let mut lines = HashMap::new();
+    let mut nests = HashMap::new();
+    // The type of the rows at each open indentation level.
+    let mut stack: Vec<(usize, &str)> = Vec::new();
+    let (mut in_body, mut in_block) = (false, false);
+
+    for (i, line) in input.split('\n').enumerate() {
+        let line = line.trim_end_matches('\r');
+        let trimmed = line.trim_start_matches(' ');
+        let indent = (line.len() - trimmed.len()) / 2;
+
+        if !in_body {
+            if trimmed == "---" {
+                in_body = true;
+            } else if let Some(rest) = trimmed.strip_prefix("%NEST:") {
+                if let Some((parent, child)) = rest.split_once('>') {
+                    nests.insert(parent.trim(), child.trim());
+                }
+            }
+            continue;
+        }
+        if in_block {
+            in_block = trimmed != "\"\"\"";
+            continue;
+        }
+        if trimmed.is_empty() || trimmed.starts_with('#') {
+            continue;
+        }
+
+        while stack.last().is_some_and(|&(level, _)| level > indent) {
+            stack.pop();
+        }
+
+        if let Some(row) = trimmed.strip_prefix('|') {
+            let Some(&(level, type_name)) = stack.last() else {
+                continue;
+            };
+            let type_name = if level + 1 == indent {
+                let child = nests.get(type_name).copied().unwrap_or("");
+                stack.push((indent, child));
+                child
+            } else if level == indent {
+                type_name
+            } else {
+                continue;
+            };
+            let id = row_id(row);
+            if !id.is_empty() && !type_name.is_empty() {
+                let key = (type_name.to_string(), id.to_string());
+                lines.entry(key).or_insert(i + 1);
+            }
+            continue;
+        }
+
+        if let Some((_, value)) = trimmed.split_once(':') {
+            let value = value.trim();
+            if let Some(type_name) = list_type(value) {
+                stack.push((indent + 1, type_name));
+            } else if value == "\"\"\"" {
+                in_block = true;
+            }
+        }
+    }
+    lines
+}
+
+/// The type name of a value that starts a matrix list, `@Type` or
+/// `@Type[a, b]`, optionally followed by a comment.
+fn list_type(value: &str) -> Option<&str> {
+    let rest = value.strip_prefix('@')?;
+    let end = rest
+        .find(|c: char| !c.is_ascii_alphanumeric())
+        .unwrap_or(rest.len());
+    let (name, mut tail) = rest.split_at(end);
+    if !name.starts_with(|c: char| c.is_ascii_uppercase()) {
+        return None;
+    }
+    tail = tail.trim_start();
+    if tail.starts_with('[') {
+        tail = tail[tail.rfind(']')? + 1..].trim_start();
+    }
+    (tail.is_empty() || tail.starts_with('#')).then_some(name)
+}
+
+/// The ID cell of a matrix row line after its `|`, skipping any `[N]` child
+/// count hint.
+fn row_id(row: &str) -> &str {
+    let mut row = row.trim();
+    if row.starts_with('[') {
+        if let Some(end) = row.find(']') {
+            row = row[end + 1..].trim();
+        }
+    }
+    let id = row.split(',').next().unwrap_or("");
+    id.trim().trim_matches('"')
+}
+
 /// Validate a HEDL document string.
 ///
 /// # Arguments
@@ -135,6 +436,245 @@ pub unsafe extern "C" fn hedl_validate(
     result
 }
 
+/// Validate the lines of a HEDL document string in a range, without parsing
+/// the rest of the document.
+///
+/// Only an excerpt of the document is parsed: the header, the lines of the
+/// range, and the context they need to parse as they do in place. The context
+/// is the chain of less-indented lines the range sits under, such as the list
+/// a row belongs to and the rows it is nested under, the rows before a row
+/// that fills cells with the ditto marker, and the rest of a block string the
+/// range starts or ends in. The rest of the document is only scanned for
+/// indentation and block string quotes, so the cost is proportional to the
+/// range and its context rather than to the document.
+///
+/// A parse failure in the excerpt is reported as a single error diagnostic
+/// with rule ID "parse" and the line it occurred on. That line may be in the
+/// header or the context rather than the range, since an error there keeps
+/// the range from being validated. Errors on lines outside the excerpt are not
+/// detected; use `hedl_validate` for the whole document. References are not
+/// resolved, as they may point at rows outside the excerpt.
+///
+/// # Arguments
+/// * `input` - UTF-8 encoded HEDL document
+/// * `input_len` - Length of input in bytes, or -1 for null-terminated
+/// * `start_line` - First line of the range (1-based)
+/// * `end_line` - Last line of the range (inclusive)
+/// * `strict` - Accepted for symmetry with `hedl_validate`; the range is
+///   validated without resolving references either way
+/// * `out_diag` - Pointer to store diagnostics handle
+///
+/// # Returns
+/// HEDL_OK on success, even when the range has errors;
+/// HEDL_ERR_INVALID_ARGUMENT if `start_line` is below 1 or `end_line` is
+/// before `start_line`; error code on other failures.
+///
+/// # Safety
+/// All pointers must be valid.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_validate_range(
+    input: *const c_char,
+    input_len: c_int,
+    start_line: c_int,
+    end_line: c_int,
+    strict: c_int,
+    out_diag: *mut *mut HedlDiagnostics,
+) -> c_int {
+    const FUNC: &str = "hedl_validate_range";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("input_ptr", &sanitize_pointer(input)),
+            ("input_len", &input_len.to_string()),
+            ("start_line", &start_line.to_string()),
+            ("end_line", &end_line.to_string()),
+            ("strict", &strict.to_string()),
+            ("out_diag", &sanitize_pointer(out_diag)),
+        ],
+    );
+
+    clear_error();
+
+    if input.is_null() || out_diag.is_null() {
+        set_error("Null pointer argument");
+        audit_call_failure(
+            FUNC,
+            HEDL_ERR_NULL_PTR,
+            "Null pointer argument",
+            start.elapsed(),
+        );
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    if start_line < 1 || end_line < start_line {
+        let msg = format!("invalid line range {}-{}", start_line, end_line);
+        set_error(&msg);
+        audit_call_failure(FUNC, HEDL_ERR_INVALID_ARGUMENT, &msg, start.elapsed());
+        return HEDL_ERR_INVALID_ARGUMENT;
+    }
+
+    let input_str = match get_input_string(input, input_len) {
+        Ok(s) => s,
+        Err(code) => {
+            let msg = crate::error::get_thread_local_error();
+            audit_call_failure(FUNC, code, &msg, start.elapsed());
+            return code;
+        }
+    };
+
+    let excerpt = range_excerpt(&input_str, start_line as usize, end_line as usize);
+    let options = ParseOptions {
+        strict_refs: false,
+        ..Default::default()
+    };
+    let diagnostics = match parse_with_limits(excerpt.as_bytes(), options) {
+        Ok(_) => Vec::new(),
+        Err(e) => {
+            let diagnostic = Diagnostic::error(
+                DiagnosticKind::Custom("parse".to_string()),
+                format!("Parse error: {}", e),
+                "parse",
+            );
+            if e.line > 0 {
+                vec![diagnostic.with_line(e.line)]
+            } else {
+                vec![diagnostic]
+            }
+        }
+    };
+
+    *out_diag = Box::into_raw(Box::new(HedlDiagnostics { inner: diagnostics }));
+    audit_call_success(FUNC, start.elapsed());
+    HEDL_OK
+}
+
+/// Build the excerpt of `input` that `hedl_validate_range` parses for lines
+/// `start_line` through `end_line`. Lines outside the excerpt are left empty,
+/// so the parser reports errors with their line numbers in `input`.
+fn range_excerpt(input: &str, start_line: usize, end_line: usize) -> String {
+    /// The most recent line at one indentation level, after any rows before
+    /// it that it needs for ditto markers.
+    struct Level<'a> {
+        indent: usize,
+        lines: Vec<(usize, &'a str)>,
+    }
+
+    fn toggles_block_string(line: &str) -> bool {
+        line.matches("\"\"\"").count() % 2 == 1
+    }
+
+    let mut lines = input
+        .split('\n')
+        .map(|line| line.strip_suffix('\r').unwrap_or(line))
+        .enumerate()
+        .map(|(i, line)| (i + 1, line));
+
+    let mut excerpt = String::new();
+    let mut emitted = 0;
+    let mut emit = |excerpt: &mut String, number: usize, line: &str| {
+        while emitted + 1 < number {
+            excerpt.push('\n');
+            emitted += 1;
+        }
+        excerpt.push_str(line);
+        excerpt.push('\n');
+        emitted = number;
+    };
+
+    for (number, line) in lines.by_ref() {
+        emit(&mut excerpt, number, line);
+        let trimmed = line.trim();
+        if trimmed == "---" || trimmed.starts_with("--- ") || trimmed.starts_with("---#") {
+            break;
+        }
+    }
+
+    // Scan the lines before the range, keeping the chain of levels the next
+    // line would sit under and the content of an open block string.
+    let mut levels: Vec<Level> = Vec::new();
+    let mut block: Option<Vec<(usize, &str)>> = None;
+    let mut range: Vec<(usize, &str)> = Vec::new();
+    let mut open = false;
+    for (number, line) in lines {
+        if number >= start_line {
+            if range.is_empty() {
+                open = block.is_some();
+            }
+            if number > end_line && !open {
+                break;
+            }
+            range.push((number, line));
+            open ^= toggles_block_string(line);
+            continue;
+        }
+
+        if let Some(content) = block.as_mut() {
+            content.push((number, line));
+            if toggles_block_string(line) {
+                block = None;
+            }
+            continue;
+        }
+        let trimmed = line.trim_start();
+        if trimmed.is_empty() || trimmed.starts_with('#') {
+            continue;
+        }
+        let indent = line.len() - trimmed.len();
+        while levels.last().is_some_and(|level| level.indent > indent) {
+            levels.pop();
+        }
+        match levels.last_mut() {
+            Some(level) if level.indent == indent => {
+                if !(trimmed.starts_with('|') && line.contains('^')) {
+                    level.lines.clear();
+                }
+                level.lines.push((number, line));
+            }
+            _ => levels.push(Level {
+                indent,
+                lines: vec![(number, line)],
+            }),
+        }
+        if toggles_block_string(line) {
+            block = Some(Vec::new());
+        }
+    }
+
+    // A range that starts in a block string sits under the line opening it;
+    // otherwise it sits under the levels indented less than its first line,
+    // and a row also needs the rows before it for ditto markers.
+    let mut context: Vec<(usize, &str)> = Vec::new();
+    match block {
+        Some(content) => {
+            context.extend(levels.iter().flat_map(|level| level.lines.iter().copied()));
+            context.extend(content);
+        }
+        None => {
+            let first = range.iter().map(|(_, line)| *line).find(|line| {
+                let trimmed = line.trim_start();
+                !trimmed.is_empty() && !trimmed.starts_with('#')
+            });
+            if let Some(line) = first {
+                let trimmed = line.trim_start();
+                let indent = line.len() - trimmed.len();
+                let is_row = trimmed.starts_with('|');
+                for level in &levels {
+                    if level.indent < indent || (is_row && level.indent == indent) {
+                        context.extend(level.lines.iter().copied());
+                    }
+                }
+            }
+        }
+    }
+
+    for (number, line) in context.into_iter().chain(range) {
+        emit(&mut excerpt, number, line);
+    }
+    excerpt
+}
+
 // =============================================================================
 // Document Information
 // =============================================================================
@@ -171,6 +711,121 @@ pub unsafe extern "C" fn hedl_schema_count(doc: *const HedlDocument) -> c_int {
     (*doc).inner.structs.len() as c_int
 }
 
+/// Get the names of the struct definitions in a document.
+///
+/// The names are written one per line, sorted by name as in canonical
+/// output. A document without struct definitions yields an empty string.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `out_str` - Pointer to store the names (must be freed with hedl_free_string)
+///
+/// # Returns
+/// HEDL_OK on success, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_schema_names(
+    doc: *const HedlDocument,
+    out_str: *mut *mut c_char,
+) -> c_int {
+    const FUNC: &str = "hedl_schema_names";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("out_str", &sanitize_pointer(out_str)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_str.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let names: Vec<&str> = (*doc).inner.structs.keys().map(String::as_str).collect();
+    let result = allocate_output_string(&names.join("\n"), out_str, HEDL_ERR_ALLOC);
+    if result != HEDL_OK {
+        audit_call_failure(FUNC, result, "Allocation failed", start.elapsed());
+        return result;
+    }
+    audit_call_success(FUNC, start.elapsed());
+    HEDL_OK
+}
+
+/// Get a checksum of one struct type's definition, for detecting schema
+/// drift between versions of a document.
+///
+/// The checksum is the hex SHA-256 digest of the type's declaration as
+/// written in canonical output without its row count, such as
+/// `%STRUCT: User: [id,name]`. Only the type name and the ordered field
+/// names are hashed, so row values never change it. A type used only with
+/// an inline schema is hashed with that schema.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `schema_name` - NUL-terminated name of the struct type
+/// * `out_str` - Pointer to store the checksum (must be freed with hedl_free_string)
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_NOT_FOUND if the type is neither declared
+/// nor used by a list, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_schema_checksum(
+    doc: *const HedlDocument,
+    schema_name: *const c_char,
+    out_str: *mut *mut c_char,
+) -> c_int {
+    const FUNC: &str = "hedl_schema_checksum";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("schema_name", &sanitize_pointer(schema_name)),
+            ("out_str", &sanitize_pointer(out_str)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_str.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let (schema_name, columns) = match schema_arg(FUNC, &(*doc).inner, schema_name, start) {
+        Ok(found) => found,
+        Err(code) => return code,
+    };
+    let declaration = format!("%STRUCT: {}: [{}]", schema_name, columns.join(","));
+    let checksum: String = Sha256::digest(declaration.as_bytes())
+        .iter()
+        .map(|byte| format!("{:02x}", byte))
+        .collect();
+
+    let result = allocate_output_string(&checksum, out_str, HEDL_ERR_ALLOC);
+    if result != HEDL_OK {
+        audit_call_failure(FUNC, result, "Allocation failed", start.elapsed());
+        return result;
+    }
+    audit_call_success(FUNC, start.elapsed());
+    HEDL_OK
+}
+
 /// Get the number of aliases in a document.
 ///
 /// # Safety
@@ -183,6 +838,333 @@ pub unsafe extern "C" fn hedl_alias_count(doc: *const HedlDocument) -> c_int {
     (*doc).inner.aliases.len() as c_int
 }
 
+/// Get the alias definitions of a document.
+///
+/// Each alias is written on its own line as the name, a tab, and the
+/// expansion, sorted by name. Backslashes, tabs and newlines in expansions
+/// are escaped as `\\`, `\t` and `\n`. A document without aliases yields
+/// an empty string.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `out_str` - Pointer to store the aliases (must be freed with hedl_free_string)
+///
+/// # Returns
+/// HEDL_OK on success, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_aliases(
+    doc: *const HedlDocument,
+    out_str: *mut *mut c_char,
+) -> c_int {
+    const FUNC: &str = "hedl_aliases";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("out_str", &sanitize_pointer(out_str)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_str.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let lines: Vec<String> = (*doc)
+        .inner
+        .aliases
+        .iter()
+        .map(|(name, value)| {
+            let escaped = value
+                .replace('\\', "\\\\")
+                .replace('\t', "\\t")
+                .replace('\n', "\\n");
+            format!("{}\t{}", name, escaped)
+        })
+        .collect();
+    let result = allocate_output_string(&lines.join("\n"), out_str, HEDL_ERR_ALLOC);
+    if result != HEDL_OK {
+        audit_call_failure(FUNC, result, "Allocation failed", start.elapsed());
+        return result;
+    }
+    audit_call_success(FUNC, start.elapsed());
+    HEDL_OK
+}
+
+/// Get the largest scalar values of a document by byte size.
+///
+/// Each value is written on its own line as the struct type, field name,
+/// row index and size in bytes, separated by tabs, largest first; values
+/// of equal size keep their order. Row indexes count every row of the type,
+/// including nested rows, list by list as the Go bindings do. Key-value
+/// pairs outside matrix lists have an empty type, their dot-separated key
+/// path as the field and a row index of -1. The size of a string is its
+/// length in UTF-8 bytes; other values are measured by their HEDL text.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `n` - Maximum number of values to return
+/// * `out_str` - Pointer to store the values (must be freed with hedl_free_string)
+///
+/// # Returns
+/// HEDL_OK on success, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_largest_values(
+    doc: *const HedlDocument,
+    n: c_int,
+    out_str: *mut *mut c_char,
+) -> c_int {
+    const FUNC: &str = "hedl_largest_values";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("n", &n.to_string()),
+            ("out_str", &sanitize_pointer(out_str)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_str.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let doc_ref = &(*doc).inner;
+    let mut sizes = ValueSizes {
+        structs: &doc_ref.structs,
+        values: Vec::new(),
+        rows_seen: HashMap::new(),
+    };
+    sizes.visit_object("", &doc_ref.root);
+    // sort_by is stable, so values of equal size keep their order.
+    sizes.values.sort_by(|a, b| b.3.cmp(&a.3));
+    sizes.values.truncate(n.max(0) as usize);
+
+    let lines: Vec<String> = sizes
+        .values
+        .iter()
+        .map(|(schema, field, row, bytes)| format!("{}\t{}\t{}\t{}", schema, field, row, bytes))
+        .collect();
+    let result = allocate_output_string(&lines.join("\n"), out_str, HEDL_ERR_ALLOC);
+    if result != HEDL_OK {
+        audit_call_failure(FUNC, result, "Allocation failed", start.elapsed());
+        return result;
+    }
+    audit_call_success(FUNC, start.elapsed());
+    HEDL_OK
+}
+
+/// Collects the size of every scalar value for `hedl_largest_values`, as
+/// (type, field, row index, bytes).
+struct ValueSizes<'a> {
+    structs: &'a BTreeMap<String, Vec<String>>,
+    values: Vec<(String, String, i64, usize)>,
+    rows_seen: HashMap<String, i64>,
+}
+
+impl ValueSizes<'_> {
+    fn visit_object(&mut self, prefix: &str, items: &BTreeMap<String, Item>) {
+        for (key, item) in items {
+            match item {
+                Item::Scalar(value) => {
+                    let path = format!("{}{}", prefix, key);
+                    self.values
+                        .push((String::new(), path, -1, value_size(value)));
+                }
+                Item::Object(obj) => self.visit_object(&format!("{}{}.", prefix, key), obj),
+                Item::List(list) => self.visit_rows(&list.type_name, &list.schema, &list.rows),
+            }
+        }
+    }
+
+    /// Visit one list of rows, then the lists nested under each of its rows,
+    /// numbering rows in the same order as the Go bindings do.
+    fn visit_rows(&mut self, type_name: &str, schema: &[String], rows: &[Node]) {
+        let seen = self.rows_seen.entry(type_name.to_string()).or_insert(0);
+        let first = *seen;
+        *seen += rows.len() as i64;
+
+        for (i, row) in rows.iter().enumerate() {
+            for (field, value) in schema.iter().zip(&row.fields) {
+                self.values.push((
+                    type_name.to_string(),
+                    field.clone(),
+                    first + i as i64,
+                    value_size(value),
+                ));
+            }
+        }
+        for row in rows {
+            for (child_type, children) in &row.children {
+                let structs = self.structs;
+                let child_schema = structs.get(child_type).map(Vec::as_slice).unwrap_or(&[]);
+                self.visit_rows(child_type, child_schema, children);
+            }
+        }
+    }
+}
+
+/// The size of a value in bytes, as measured by `hedl_largest_values`.
+fn value_size(value: &Value) -> usize {
+    match value {
+        Value::String(s) => s.len(),
+        Value::Tensor(t) => t.to_string().len(),
+        other => other.to_string().len(),
+    }
+}
+
+/// Get the approximate memory held by a parsed document.
+///
+/// The size covers the document's strings, vectors and maps as allocated,
+/// plus a fixed per-entry estimate for map nodes; allocator overhead is not
+/// included.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse
+/// * `out_bytes` - Pointer to store the size in bytes
+///
+/// # Returns
+/// HEDL_OK on success, error code on failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_document_memory(
+    doc: *const HedlDocument,
+    out_bytes: *mut c_longlong,
+) -> c_int {
+    const FUNC: &str = "hedl_document_memory";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("out_bytes", &sanitize_pointer(out_bytes)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_bytes.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let doc_ref = &(*doc).inner;
+    let mut bytes = std::mem::size_of::<HedlDocument>();
+    for (name, expansion) in &doc_ref.aliases {
+        bytes += MAP_ENTRY_BYTES + name.capacity() + expansion.capacity();
+    }
+    for (name, columns) in &doc_ref.structs {
+        bytes += MAP_ENTRY_BYTES + name.capacity() + strings_memory(columns);
+    }
+    for (parent, child) in &doc_ref.nests {
+        bytes += MAP_ENTRY_BYTES + parent.capacity() + child.capacity();
+    }
+    bytes += object_memory(&doc_ref.root);
+
+    *out_bytes = c_longlong::try_from(bytes).unwrap_or(c_longlong::MAX);
+    audit_call_success(FUNC, start.elapsed());
+    HEDL_OK
+}
+
+/// The estimated cost of one entry in a `BTreeMap`, for the node slot and
+/// its share of the node header.
+const MAP_ENTRY_BYTES: usize = 2 * std::mem::size_of::<usize>();
+
+/// The heap memory of an object's entries, as measured by `hedl_document_memory`.
+fn object_memory(items: &BTreeMap<String, Item>) -> usize {
+    items
+        .iter()
+        .map(|(key, item)| {
+            MAP_ENTRY_BYTES
+                + std::mem::size_of::<(String, Item)>()
+                + key.capacity()
+                + item_memory(item)
+        })
+        .sum()
+}
+
+/// The heap memory owned by an item.
+fn item_memory(item: &Item) -> usize {
+    match item {
+        Item::Scalar(value) => value_memory(value),
+        Item::Object(obj) => object_memory(obj),
+        Item::List(list) => {
+            list.type_name.capacity()
+                + strings_memory(&list.schema)
+                + list.rows.capacity() * std::mem::size_of::<Node>()
+                + list.rows.iter().map(node_memory).sum::<usize>()
+        }
+    }
+}
+
+/// The heap memory owned by a row, including its nested rows.
+fn node_memory(node: &Node) -> usize {
+    let mut bytes = node.type_name.capacity()
+        + node.id.capacity()
+        + node.fields.capacity() * std::mem::size_of::<Value>()
+        + node.fields.iter().map(value_memory).sum::<usize>();
+    for (child_type, children) in &node.children {
+        bytes += MAP_ENTRY_BYTES
+            + std::mem::size_of::<(String, Vec<Node>)>()
+            + child_type.capacity()
+            + children.capacity() * std::mem::size_of::<Node>()
+            + children.iter().map(node_memory).sum::<usize>();
+    }
+    bytes
+}
+
+/// The heap memory owned by a vector of strings.
+fn strings_memory(strings: &[String]) -> usize {
+    strings.len() * std::mem::size_of::<String>()
+        + strings.iter().map(String::capacity).sum::<usize>()
+}
+
+/// The heap memory owned by a value. Expressions are measured by their text,
+/// as their node tree is not exposed.
+fn value_memory(value: &Value) -> usize {
+    match value {
+        Value::String(s) => s.capacity(),
+        Value::Tensor(t) => tensor_memory(t),
+        Value::Reference(r) => r.type_name.as_ref().map_or(0, String::capacity) + r.id.capacity(),
+        Value::Expression(e) => e.to_string().len(),
+        _ => 0,
+    }
+}
+
+/// The heap memory owned by a tensor.
+fn tensor_memory(tensor: &Tensor) -> usize {
+    match tensor {
+        Tensor::Scalar(_) => 0,
+        Tensor::Array(items) => {
+            items.capacity() * std::mem::size_of::<Tensor>()
+                + items.iter().map(tensor_memory).sum::<usize>()
+        }
+    }
+}
+
 /// Get the number of root items in a document.
 ///
 /// # Safety
@@ -194,3 +1176,93 @@ pub unsafe extern "C" fn hedl_root_item_count(doc: *const HedlDocument) -> c_int
     }
     (*doc).inner.root.len() as c_int
 }
+
+/// Get the source line of a row of a struct type, for a document parsed with
+/// `hedl_parse_with_provenance`.
+///
+/// Rows are indexed across the type's lists in document order, including
+/// nested ones, as `hedl_rows_with_missing` counts them, and matched to the
+/// source by ID.
+///
+/// # Arguments
+/// * `doc` - Document handle from hedl_parse_with_provenance
+/// * `schema_name` - NUL-terminated name of the struct type
+/// * `index` - Row index
+/// * `out_line` - Pointer to store the 1-based source line
+///
+/// # Returns
+/// HEDL_OK on success, HEDL_ERR_NOT_FOUND if the document was parsed
+/// without provenance, the type is unknown or the row has no recorded line,
+/// HEDL_ERR_INVALID_ARGUMENT if the index is out of range, error code on
+/// failure.
+///
+/// # Safety
+/// All pointers must be valid. Returns HEDL_ERR_NULL_PTR if doc is NULL or poisoned.
+#[no_mangle]
+pub unsafe extern "C" fn hedl_row_source_line(
+    doc: *const HedlDocument,
+    schema_name: *const c_char,
+    index: c_int,
+    out_line: *mut c_int,
+) -> c_int {
+    const FUNC: &str = "hedl_row_source_line";
+    let start = Instant::now();
+
+    audit_call_start(
+        FUNC,
+        &[
+            ("doc", &sanitize_pointer(doc)),
+            ("schema_name", &sanitize_pointer(schema_name)),
+            ("index", &index.to_string()),
+            ("out_line", &sanitize_pointer(out_line)),
+        ],
+    );
+
+    clear_error();
+
+    if !is_valid_document_ptr(doc) || out_line.is_null() {
+        let err_msg = "Null pointer argument";
+        set_error(err_msg);
+        audit_call_failure(FUNC, HEDL_ERR_NULL_PTR, err_msg, start.elapsed());
+        return HEDL_ERR_NULL_PTR;
+    }
+
+    let fail = |code: c_int, err_msg: &str| {
+        set_error(err_msg);
+        audit_call_failure(FUNC, code, err_msg, start.elapsed());
+        code
+    };
+
+    let Some(row_lines) = &(*doc).row_lines else {
+        let err_msg = "Document was not parsed with hedl_parse_with_provenance";
+        return fail(HEDL_ERR_NOT_FOUND, err_msg);
+    };
+    let doc_ref = &(*doc).inner;
+    let (schema_name, _) = match schema_arg(FUNC, doc_ref, schema_name, start) {
+        Ok(schema) => schema,
+        Err(code) => return code,
+    };
+
+    let wanted = usize::try_from(index).ok();
+    let mut found = None;
+    visit_indexed_rows(doc_ref, &mut |_, i, row| {
+        if row.type_name == schema_name && Some(i) == wanted {
+            found = Some(row);
+        }
+    });
+    let Some(row) = found else {
+        let err_msg = format!("{} row {} out of range", schema_name, index);
+        return fail(HEDL_ERR_INVALID_ARGUMENT, &err_msg);
+    };
+    match row_lines.get(&(row.type_name.clone(), row.id.clone())) {
+        Some(&line) => {
+            *out_line = line as c_int;
+            audit_call_success(FUNC, start.elapsed());
+            HEDL_OK
+        }
+        None => {
+            let err_msg = format!("No source line recorded for {} row {}", schema_name, index);
+            fail(HEDL_ERR_NOT_FOUND, &err_msg)
+        }
+    }
+}
@@ -18,7 +18,9 @@
 //! FFI type definitions and error codes.
 
 use hedl_core::Document;
+use std::collections::HashMap;
 use std::os::raw::c_int;
+use std::sync::atomic::{AtomicI64, Ordering};
 
 // =============================================================================
 // Error Codes
@@ -37,6 +39,12 @@ pub const HEDL_ERR_CSV: c_int = -9;
 pub const HEDL_ERR_PARQUET: c_int = -10;
 pub const HEDL_ERR_LINT: c_int = -11;
 pub const HEDL_ERR_NEO4J: c_int = -12;
+pub const HEDL_ERR_TIMEOUT: c_int = -13;
+pub const HEDL_ERR_NOT_FOUND: c_int = -14;
+pub const HEDL_ERR_TOML: c_int = -15;
+pub const HEDL_ERR_CONFLICT: c_int = -16;
+pub const HEDL_ERR_INVALID_ARGUMENT: c_int = -17;
+pub const HEDL_ERR_SQLITE: c_int = -18;
 
 // =============================================================================
 // Opaque Types
@@ -45,9 +53,42 @@ pub const HEDL_ERR_NEO4J: c_int = -12;
 /// Opaque handle to a HEDL document
 pub struct HedlDocument {
     pub(crate) inner: Document,
+    /// Source line of each row by type name and ID, recorded by
+    /// `hedl_parse_with_provenance`.
+    pub(crate) row_lines: Option<HashMap<(String, String), usize>>,
+}
+
+/// Number of document handles allocated and not yet freed.
+pub(crate) static LIVE_DOCUMENTS: AtomicI64 = AtomicI64::new(0);
+
+impl HedlDocument {
+    /// Wrap a document in a handle, counting it as live until it is dropped.
+    pub(crate) fn new(inner: Document) -> Self {
+        LIVE_DOCUMENTS.fetch_add(1, Ordering::Relaxed);
+        HedlDocument {
+            inner,
+            row_lines: None,
+        }
+    }
+}
+
+impl Drop for HedlDocument {
+    fn drop(&mut self) {
+        LIVE_DOCUMENTS.fetch_sub(1, Ordering::Relaxed);
+    }
 }
 
 /// Opaque handle to lint diagnostics
 pub struct HedlDiagnostics {
     pub(crate) inner: Vec<hedl_lint::Diagnostic>,
 }
+
+/// Opaque handle to a cursor over the rows of one struct type, read as CSV
+pub struct HedlCsvCursor {
+    /// Header row, until the first read takes it
+    pub(crate) header: Option<String>,
+    /// Rows of the type, borrowed from the document the cursor was opened on
+    pub(crate) rows: Vec<*const hedl_core::Node>,
+    /// Index of the next row to read
+    pub(crate) next: usize,
+}